@@ -1,7 +1,11 @@
 package main
 
 import (
+	"context"
+	"log/slog"
+	"os/signal"
 	"sync"
+	"syscall"
 
 	"github.com/bicosteve/booking-system/controllers"
 	_ "github.com/bicosteve/booking-system/docs"
@@ -23,18 +27,37 @@ func main() {
 	var wg sync.WaitGroup
 	var base controllers.Base
 
-	base.Init()
+	// The root context is cancelled on the first SIGINT/SIGTERM, which tells
+	// the servers, consumers and schedulers to wind down. A second signal
+	// kills the process.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		<-ctx.Done()
+		stop()
+		slog.Info("shutdown signal received, draining")
+	}()
 
-	wg.Add(3)
-	go base.AdminServer(&wg, "7002", "admin")
-	go base.UserServer(&wg, "7001", "user")
-	go base.RabbitMQConsumer(&wg)
+	base.Init()
 
-	// go base.Consumer(&wg, base.Topics[0])
-	// go base.Consumer(&wg, base.Topics[1])
+	wg.Add(8)
+	go base.AdminServer(ctx, &wg, "7002", "admin")
+	go base.UserServer(ctx, &wg, "7001", "user")
+	go base.RabbitMQConsumer(ctx, &wg)
+	go base.PayoutScheduler(ctx, &wg)
+	go base.StayCompletionScheduler(ctx, &wg)
+	go base.HealthMonitor(ctx, &wg)
+	go base.WebhookWorker(ctx, &wg)
+	go base.CalendarSyncWorker(ctx, &wg)
 
-	defer base.DB.Close()
+	// go base.Consumer(ctx, &wg, base.Topics[0])
+	// go base.Consumer(ctx, &wg, base.Topics[1])
 
 	wg.Wait()
 
+	// Everything that produced or consumed work has stopped; release the
+	// connections behind it.
+	base.Close()
+
 }
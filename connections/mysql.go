@@ -2,16 +2,17 @@ package connections
 
 import (
 	"database/sql"
-	"fmt"
+	"log/slog"
 	"time"
 
+	"github.com/XSAM/otelsql"
 	"github.com/bicosteve/booking-system/entities"
-	"github.com/bicosteve/booking-system/pkg/utils"
 	_ "github.com/go-sql-driver/mysql"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 )
 
 func DatabaseConnection(dsn string) (*sql.DB, error) {
-	db, err := sql.Open("mysql", dsn)
+	db, err := otelsql.Open("mysql", dsn, otelsql.WithAttributes(semconv.DBSystemMySQL))
 	if err != nil {
 		return nil, err
 	}
@@ -19,7 +20,7 @@ func DatabaseConnection(dsn string) (*sql.DB, error) {
 	err = db.Ping()
 	if err != nil {
 
-		utils.LogError(fmt.Sprintf("%s %s", entities.ErrorDBPing.Error(), err), entities.ErrorLog)
+		slog.Error("mysql ping failed", "error", err)
 		return nil, err
 	}
 
@@ -28,6 +29,6 @@ func DatabaseConnection(dsn string) (*sql.DB, error) {
 	db.SetMaxIdleConns(10)
 	db.SetConnMaxIdleTime(time.Second * 60)
 
-	utils.LogInfo(fmt.Sprintf("%s", entities.SuccessDBPing), entities.InfoLog)
+	slog.Info(entities.SuccessDBPing)
 	return db, nil
 }
@@ -20,7 +20,7 @@ func TestDatabaseConnection_InvalidDSN(t *testing.T) {
 func TestDatabaseConnection_UnreachableHost(t *testing.T) {
 	// A well-formed DSN pointing at a port where nothing is listening should
 	// fail on Ping.
-	dsn := "user:pass@tcp(127.0.0.1:1)/schema?charset=latin1&parseTime=True&loc=Local"
+	dsn := "user:pass@tcp(127.0.0.1:1)/schema?charset=latin1&parseTime=True&loc=UTC&time_zone=%27%2B00%3A00%27"
 	db, err := DatabaseConnection(dsn)
 	assert.Error(t, err)
 	assert.Nil(t, db)
@@ -3,11 +3,11 @@ package connections
 import (
 	"context"
 	"crypto/tls"
-	"fmt"
+	"log/slog"
 	"time"
 
 	"github.com/bicosteve/booking-system/entities"
-	"github.com/bicosteve/booking-system/pkg/utils"
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -30,12 +30,18 @@ func redisOptions(cfg entities.RedisConfig) *redis.Options {
 
 func NewRedisDB(ctx context.Context, cfg entities.RedisConfig) (*redis.Client, error) {
 	client := redis.NewClient(redisOptions(cfg))
+
+	err := redisotel.InstrumentTracing(client)
+	if err != nil {
+		return nil, err
+	}
+
 	pong, err := client.Ping(ctx).Result()
 	if err != nil {
 		return nil, err
 	}
 
-	utils.LogInfo(fmt.Sprintf("REDIS: %v", pong), entities.InfoLog)
+	slog.Info("redis connected", "ping", pong)
 
 	return client, nil
 }
@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+// apiKeyError writes err with the status matching it.
+func apiKeyError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, entities.ErrNoRecord):
+		utils.ErrorJSON(w, errors.New("api key not found"), http.StatusNotFound)
+	case errors.Is(err, entities.ErrInvalidAPIKeyRequest):
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+	default:
+		utils.ErrorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// Create API key godoc
+// @Summary create an API key
+// @Description Issues a key for calling the admin API from scripts and property management systems with the X-API-Key header, limited to the given scopes. The key expires after 90 days unless expires_at (at most a year ahead) is given, and is only shown once. Needs a logged in vendor; API keys cannot create keys.
+// @ID create-api-key
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Param payload body entities.APIKeyPayload true "{"name":"pms","scopes":["rooms:write","bookings:read"]}"
+// @Success 201 {object} entities.APIKey "Created"
+// @Failure 400 {object} entities.JSONResponse "Bad request"
+// @Failure 401 {object} entities.JSONResponse "Unauthorized"
+// @Failure 403 {object} entities.JSONResponse "Forbidden"
+// @Failure 500 {object} entities.JSONResponse "Internal server error"
+// @Router /api/admin/api-keys [post]
+func (b *Base) CreateAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", b.contentType)
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	var payload entities.APIKeyPayload
+	err := utils.SerializeJSON(w, r, &payload)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "create api key failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := r.Context().Value(entities.UseridKeyValue).(string)
+	if !ok {
+		slog.ErrorContext(r.Context(), "could not get user_id from context")
+		utils.ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
+		return
+	}
+	vendorID, _ := strconv.Atoi(userID)
+
+	key, err := b.apiKeyService.CreateAPIKey(ctx, vendorID, payload)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "create api key failed", "error", err)
+		apiKeyError(w, err)
+		return
+	}
+
+	_ = utils.DeserializeJSON(w, http.StatusCreated, map[string]any{"msg": "api key created", "data": key})
+}
+
+// List API keys godoc
+// @Summary list the vendor's API keys
+// @Description Returns the vendor's API keys with their scopes, expiry and when they were last used, without their secrets
+// @ID list-api-keys
+// @Tags api-keys
+// @Produce json
+// @Success 200 {array} entities.APIKey "Success"
+// @Failure 401 {object} entities.JSONResponse "Unauthorized"
+// @Failure 403 {object} entities.JSONResponse "Forbidden"
+// @Failure 500 {object} entities.JSONResponse "Internal server error"
+// @Router /api/admin/api-keys [get]
+func (b *Base) GetAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", b.contentType)
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	userID, ok := r.Context().Value(entities.UseridKeyValue).(string)
+	if !ok {
+		slog.ErrorContext(r.Context(), "could not get user_id from context")
+		utils.ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
+		return
+	}
+	vendorID, _ := strconv.Atoi(userID)
+
+	keys, err := b.apiKeyService.GetAPIKeys(ctx, vendorID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "list api keys failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	_ = utils.DeserializeJSON(w, http.StatusOK, map[string]any{"data": keys})
+}
+
+// Revoke API key godoc
+// @Summary revoke an API key
+// @Description Stops one of the vendor's API keys from working. The key stays in the list, marked revoked.
+// @ID revoke-api-key
+// @Tags api-keys
+// @Produce json
+// @Param key_id path string true "API key ID"
+// @Success 200 {object} entities.JSONResponse "Success"
+// @Failure 400 {object} entities.JSONResponse "Bad request"
+// @Failure 401 {object} entities.JSONResponse "Unauthorized"
+// @Failure 403 {object} entities.JSONResponse "Forbidden"
+// @Failure 404 {object} entities.JSONResponse "Not found"
+// @Router /api/admin/api-keys/{key_id} [delete]
+func (b *Base) RevokeAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", b.contentType)
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	keyID, err := strconv.Atoi(chi.URLParam(r, "key_id"))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "revoke api key failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := r.Context().Value(entities.UseridKeyValue).(string)
+	if !ok {
+		slog.ErrorContext(r.Context(), "could not get user_id from context")
+		utils.ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
+		return
+	}
+	vendorID, _ := strconv.Atoi(userID)
+
+	err = b.apiKeyService.RevokeAPIKey(ctx, vendorID, keyID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "revoke api key failed", "error", err)
+		apiKeyError(w, err)
+		return
+	}
+
+	_ = utils.DeserializeJSON(w, http.StatusOK, map[string]any{"msg": "api key revoked"})
+}
@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/repo"
+	"github.com/bicosteve/booking-system/service"
+	"github.com/stretchr/testify/assert"
+)
+
+var apiKeyColumns = []string{"key_id", "vendor_id", "name", "prefix", "secret_hash", "scopes", "expires_at",
+	"last_used_at", "revoked_at", "created_at"}
+
+func setupAPIKeyBase(t *testing.T) (*Base, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock db: %v", err)
+	}
+
+	base := &Base{
+		apiKeyService: service.NewAPIKeyService(*repo.NewDBRepository(db, nil)),
+		contentType:   "application/json",
+		path:          "/api",
+		DB:            db,
+	}
+	return base, mock
+}
+
+func TestCreateAPIKeyHandler(t *testing.T) {
+	t.Run("created", func(t *testing.T) {
+		base, mock := setupAPIKeyBase(t)
+		mock.ExpectPrepare("INSERT INTO api_key").ExpectExec().
+			WithArgs(7, "pms", sqlmock.AnyArg(), sqlmock.AnyArg(), "rooms:write", sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(4, 1))
+
+		req := httptest.NewRequest(http.MethodPost, "/admin/api-keys", strings.NewReader(`{"name":"pms","scopes":["rooms:write"]}`))
+		req = withUserID(req, "7")
+		w := httptest.NewRecorder()
+
+		base.CreateAPIKeyHandler(w, req)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var resp struct {
+			Data entities.APIKey `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.True(t, strings.HasPrefix(resp.Data.Key, "bk_"))
+		assert.NotContains(t, w.Body.String(), "secret_hash")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("unknown scope", func(t *testing.T) {
+		base, _ := setupAPIKeyBase(t)
+
+		req := httptest.NewRequest(http.MethodPost, "/admin/api-keys", strings.NewReader(`{"name":"pms","scopes":["users:write"]}`))
+		req = withUserID(req, "7")
+		w := httptest.NewRecorder()
+
+		base.CreateAPIKeyHandler(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestRevokeAPIKeyHandler(t *testing.T) {
+	base, mock := setupAPIKeyBase(t)
+	mock.ExpectPrepare("UPDATE api_key SET revoked_at").ExpectExec().WithArgs(4, 7).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	req := httptest.NewRequest(http.MethodDelete, "/admin/api-keys/4", nil)
+	req = withURLParam(withUserID(req, "7"), "key_id", "4")
+	w := httptest.NewRecorder()
+
+	base.RevokeAPIKeyHandler(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAdminRouterAPIKey(t *testing.T) {
+	// bk_abcdef123456_secret, valid for an hour with rooms:read only.
+	expectKey := func(mock sqlmock.Sqlmock) {
+		now := time.Now()
+		mock.ExpectPrepare("SELECT key_id").ExpectQuery().WithArgs("abcdef123456").
+			WillReturnRows(sqlmock.NewRows(apiKeyColumns).
+				AddRow(4, 7, "pms", "abcdef123456", "2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b",
+					"rooms:read", now.Add(time.Hour), nil, nil, now))
+		mock.ExpectPrepare("UPDATE api_key SET last_used_at").ExpectExec().WithArgs(4).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+
+	cases := map[string]struct {
+		method, path string
+		key          string
+		want         int
+	}{
+		"missing scope":   {http.MethodGet, "/api/admin/reviews", "bk_abcdef123456_secret", http.StatusForbidden},
+		"key management":  {http.MethodGet, "/api/admin/api-keys", "bk_abcdef123456_secret", http.StatusForbidden},
+		"wrong secret":    {http.MethodGet, "/api/admin/reviews", "bk_abcdef123456_guess", http.StatusUnauthorized},
+		"no key or token": {http.MethodGet, "/api/admin/reviews", "", http.StatusUnauthorized},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			base, mock := setupAPIKeyBase(t)
+			if tc.key != "" {
+				expectKey(mock)
+			}
+
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			if tc.key != "" {
+				req.Header.Set("X-API-Key", tc.key)
+			}
+			w := httptest.NewRecorder()
+
+			base.adminRouter().ServeHTTP(w, req)
+			assert.Equal(t, tc.want, w.Code)
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package controllers
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/utils"
+)
+
+// auditFilter reads the audit log filters from q for vendorID's entries.
+func auditFilter(q url.Values, vendorID int) (entities.AuditFilter, error) {
+	filter := entities.AuditFilter{
+		VendorID:   vendorID,
+		Action:     q.Get("action"),
+		EntityType: q.Get("entity_type"),
+		EntityID:   q.Get("entity_id"),
+	}
+
+	if v := q.Get("actor_id"); v != "" {
+		actorID, err := strconv.Atoi(v)
+		if err != nil {
+			return filter, errors.New("actor_id must be a number")
+		}
+		filter.ActorID = &actorID
+	}
+
+	if v := q.Get("from"); v != "" {
+		from, err := time.Parse(statementDateLayout, v)
+		if err != nil {
+			return filter, errors.New("from must be a YYYY-MM-DD date")
+		}
+		filter.From = from
+	}
+
+	if v := q.Get("to"); v != "" {
+		to, err := time.Parse(statementDateLayout, v)
+		if err != nil {
+			return filter, errors.New("to must be a YYYY-MM-DD date")
+		}
+		filter.To = to
+	}
+
+	if v := q.Get("before"); v != "" {
+		before, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || before <= 0 {
+			return filter, errors.New("before must be an audit entry id")
+		}
+		filter.BeforeID = before
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			return filter, errors.New("limit must be a positive number")
+		}
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
+
+// Audit log godoc
+// @Summary list the vendor's audit log
+// @Description Returns changes to the vendor's rooms, bookings, payments, payouts and settings, newest first, with who made them, from where and what changed. Platform admins also get every platform admin's changes, such as account unlocks. Pass next as before to get the following page.
+// @ID audit-log
+// @Tags audit
+// @Produce json
+// @Param actor_id query int false "User who made the change"
+// @Param action query string false "Action, e.g. room.update or booking.status"
+// @Param entity_type query string false "Entity type, e.g. room, booking, transaction or payout"
+// @Param entity_id query string false "Entity ID"
+// @Param from query string false "Start date, YYYY-MM-DD"
+// @Param to query string false "End date (exclusive), YYYY-MM-DD"
+// @Param before query int false "Only entries older than this entry ID"
+// @Param limit query int false "Page size, 50 by default and at most 200"
+// @Success 200 {array} entities.AuditEntry "Success"
+// @Failure 400 {object} entities.JSONResponse "Bad request"
+// @Failure 401 {object} entities.JSONResponse "Unauthorized"
+// @Failure 500 {object} entities.JSONResponse "Internal server error"
+// @Router /api/admin/audit [get]
+func (b *Base) GetAuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", b.contentType)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	userID, ok := r.Context().Value(entities.UseridKeyValue).(string)
+	if !ok {
+		slog.ErrorContext(r.Context(), "could not get user_id from context")
+		utils.ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
+		return
+	}
+	vendorID, _ := strconv.Atoi(userID)
+
+	filter, err := auditFilter(r.URL.Query(), vendorID)
+	filter.PlatformAdmin = slices.Contains(b.platformAdmins, vendorID)
+	if err != nil {
+		slog.WarnContext(r.Context(), "list audit log failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	entries, next, err := b.auditService.GetAuditLog(ctx, filter)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "list audit log failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]any{"data": entries}
+	if next != 0 {
+		data["next"] = next
+	}
+
+	_ = utils.DeserializeJSON(w, http.StatusOK, data)
+}
+
+// Audit log export godoc
+// @Summary export the vendor's audit log
+// @Description Exports up to 10000 of the vendor's audit entries matching the filters as CSV, newest first. Platform admins also get every platform admin's entries.
+// @ID audit-log-export
+// @Tags audit
+// @Produce text/csv
+// @Param actor_id query int false "User who made the change"
+// @Param action query string false "Action, e.g. room.update or booking.status"
+// @Param entity_type query string false "Entity type, e.g. room, booking, transaction or payout"
+// @Param entity_id query string false "Entity ID"
+// @Param from query string false "Start date, YYYY-MM-DD"
+// @Param to query string false "End date (exclusive), YYYY-MM-DD"
+// @Success 200 {string} string "CSV audit log"
+// @Failure 400 {object} entities.JSONResponse "Bad request"
+// @Failure 401 {object} entities.JSONResponse "Unauthorized"
+// @Failure 500 {object} entities.JSONResponse "Internal server error"
+// @Router /api/admin/audit/export [get]
+func (b *Base) ExportAuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	userID, ok := r.Context().Value(entities.UseridKeyValue).(string)
+	if !ok {
+		slog.ErrorContext(r.Context(), "could not get user_id from context")
+		utils.ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
+		return
+	}
+	vendorID, _ := strconv.Atoi(userID)
+
+	filter, err := auditFilter(r.URL.Query(), vendorID)
+	filter.PlatformAdmin = slices.Contains(b.platformAdmins, vendorID)
+	if err != nil {
+		slog.WarnContext(r.Context(), "export audit log failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	entries, err := b.auditService.ExportAuditLog(ctx, filter)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "export audit log failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	filename := fmt.Sprintf("audit_%d_%s.csv", vendorID, time.Now().UTC().Format(statementDateLayout))
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+
+	out := csv.NewWriter(w)
+	_ = out.Write([]string{"audit_id", "created_at", "actor_type", "actor_id", "api_key_id", "action",
+		"entity_type", "entity_id", "before", "after", "ip", "request_id"})
+	for _, e := range entries {
+		_ = out.Write([]string{
+			strconv.FormatInt(e.ID, 10),
+			e.CreatedAt.UTC().Format(time.RFC3339Nano),
+			e.ActorType,
+			optionalID(e.ActorID),
+			optionalID(e.APIKeyID),
+			e.Action,
+			e.EntityType,
+			e.EntityID,
+			string(e.Before),
+			string(e.After),
+			e.IP,
+			e.RequestID,
+		})
+	}
+	out.Flush()
+}
+
+func optionalID(id *int) string {
+	if id == nil {
+		return ""
+	}
+
+	return strconv.Itoa(*id)
+}
@@ -0,0 +1,139 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/repo"
+	"github.com/bicosteve/booking-system/service"
+	"github.com/stretchr/testify/assert"
+)
+
+var auditColumns = []string{"audit_id", "actor_type", "actor_id", "api_key_id", "vendor_id", "action", "entity_type",
+	"entity_id", "before_state", "after_state", "ip", "request_id", "created_at"}
+
+func setupAuditBase(t *testing.T) (*Base, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock db: %v", err)
+	}
+
+	base := &Base{
+		auditService: service.NewAuditService(*repo.NewDBRepository(db, nil)),
+		contentType:  "application/json",
+		path:         "/api",
+		DB:           db,
+	}
+	return base, mock
+}
+
+// expectAuditInsert expects a handler called without the audit middleware
+// to write its entry straight away. It is for mocks matching whole queries.
+func expectAuditInsert(mock sqlmock.Sqlmock, action string) {
+	mock.ExpectPrepare("INSERT INTO audit_log(actor_type, actor_id, api_key_id, vendor_id, action, entity_type, entity_id, "+
+		"before_state, after_state, ip, request_id, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NOW(3))").ExpectExec().
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), action,
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+}
+
+func TestGetAuditLogHandler(t *testing.T) {
+	t.Run("filtered page", func(t *testing.T) {
+		base, mock := setupAuditBase(t)
+		created := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+		mock.ExpectPrepare("FROM audit_log WHERE vendor_id = \\? AND entity_type = \\? AND created_at >= \\? AND created_at < \\?").
+			ExpectQuery().
+			WithArgs(7, "room", time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC), 1).
+			WillReturnRows(sqlmock.NewRows(auditColumns).
+				AddRow(12, entities.ActorVendor, 7, nil, 7, entities.AuditRoomUpdate, "room", "3",
+					`{"status":"VACANT"}`, `{"status":"BOOKED"}`, "10.0.0.1", "req-1", created))
+
+		req := httptest.NewRequest(http.MethodGet, "/admin/audit?entity_type=room&from=2026-03-01&to=2026-04-01&limit=1", nil)
+		req = withUserID(req, "7")
+		w := httptest.NewRecorder()
+
+		base.GetAuditLogHandler(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var body struct {
+			Data []entities.AuditEntry `json:"data"`
+			Next int64                 `json:"next"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Len(t, body.Data, 1)
+		assert.Equal(t, int64(12), body.Next)
+		assert.JSONEq(t, `{"status":"BOOKED"}`, string(body.Data[0].After))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("platform admin also sees platform admin entries", func(t *testing.T) {
+		base, mock := setupAuditBase(t)
+		base.platformAdmins = []int{1}
+		created := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+		mock.ExpectPrepare("FROM audit_log WHERE \\(vendor_id = \\? OR actor_type = \\?\\) AND action = \\?").
+			ExpectQuery().
+			WithArgs(1, entities.ActorPlatformAdmin, entities.AuditUserUnlock, 50).
+			WillReturnRows(sqlmock.NewRows(auditColumns).
+				AddRow(13, entities.ActorPlatformAdmin, 1, nil, nil, entities.AuditUserUnlock, "user", "5",
+					nil, `{"email":"guest@gmail.com","user_id":"5"}`, "10.0.0.1", "req-2", created))
+
+		req := httptest.NewRequest(http.MethodGet, "/admin/audit?action="+entities.AuditUserUnlock, nil)
+		req = withUserID(req, "1")
+		w := httptest.NewRecorder()
+
+		base.GetAuditLogHandler(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var body struct {
+			Data []entities.AuditEntry `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Len(t, body.Data, 1)
+		assert.Equal(t, "5", body.Data[0].EntityID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("invalid filter", func(t *testing.T) {
+		base, _ := setupAuditBase(t)
+		for _, q := range []string{"actor_id=me", "from=yesterday", "before=-1", "limit=0"} {
+			req := httptest.NewRequest(http.MethodGet, "/admin/audit?"+q, nil)
+			req = withUserID(req, "7")
+			w := httptest.NewRecorder()
+
+			base.GetAuditLogHandler(w, req)
+			assert.Equal(t, http.StatusBadRequest, w.Code, q)
+		}
+	})
+}
+
+func TestExportAuditLogHandler(t *testing.T) {
+	base, mock := setupAuditBase(t)
+	created := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	mock.ExpectPrepare("FROM audit_log WHERE vendor_id = \\? AND actor_id = \\?").
+		ExpectQuery().
+		WithArgs(7, 7, service.AuditExportLimit).
+		WillReturnRows(sqlmock.NewRows(auditColumns).
+			AddRow(12, entities.ActorAPIKey, 7, 4, 7, entities.AuditPayoutSettle, "payout", "3",
+				`{"status":"PENDING"}`, `{"status":"PAID"}`, "10.0.0.1", "req-1", created))
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/audit/export?actor_id=7", nil)
+	req = withUserID(req, "7")
+	w := httptest.NewRecorder()
+
+	base.ExportAuditLogHandler(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "audit_7_")
+
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.Equal(t, `12,2026-03-02T10:00:00Z,api_key,7,4,payout.settle,payout,3,"{""status"":""PENDING""}","{""status"":""PAID""}",10.0.0.1,req-1`, lines[1])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
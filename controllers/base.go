@@ -4,16 +4,27 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/bicosteve/booking-system/connections"
 	"github.com/bicosteve/booking-system/entities"
 	"github.com/bicosteve/booking-system/pkg/app"
+	"github.com/bicosteve/booking-system/pkg/audit"
 	"github.com/bicosteve/booking-system/pkg/health"
+	"github.com/bicosteve/booking-system/pkg/jwtkeys"
+	"github.com/bicosteve/booking-system/pkg/metrics"
+	"github.com/bicosteve/booking-system/pkg/money"
+	"github.com/bicosteve/booking-system/pkg/phone"
+	"github.com/bicosteve/booking-system/pkg/producer"
+	"github.com/bicosteve/booking-system/pkg/rabbitmq"
+	"github.com/bicosteve/booking-system/pkg/ratelimit"
+	"github.com/bicosteve/booking-system/pkg/tracing"
 	"github.com/bicosteve/booking-system/pkg/utils"
 	"github.com/bicosteve/booking-system/repo"
 	"github.com/bicosteve/booking-system/service"
@@ -21,47 +32,70 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/redis/go-redis/v9"
-	"github.com/streadway/amqp"
 
 	httpSwagger "github.com/swaggo/http-swagger/v2"
 )
 
 type Base struct {
-	KafkaProducer  *kafka.Producer
-	KafkaConsumer  *kafka.Consumer
-	AuthPort       string
-	AdminPort      string
-	ConsumerPort   string
-	Broker         string
-	Topics         []string
-	Key            string
-	DB             *sql.DB
-	Redis          *redis.Client
-	jwtSecret      string
-	contentType    string
-	path           string
-	sengridkey     string
-	mailfrom       string
-	atklng         string
-	appusername    string
-	userService    *service.UserService
-	roomService    *service.RoomService
-	bookingService *service.BookingService
-	paymentService *service.PaymentService
-	stripesecret   string
-	pubkey         string
-	successURL     string
-	cancelURL      string
-	rabbitConn     *amqp.Connection
-	queueName      string
-	rabbitURL      string
-	rabbitCfg      entities.RabbitMQConfig
-	kafkaCfg       entities.KakfaConfig
+	KafkaProducer    *kafka.Producer
+	KafkaConsumer    *kafka.Consumer
+	producer         producer.Producer
+	publishTimeout   time.Duration
+	AuthPort         string
+	AdminPort        string
+	ConsumerPort     string
+	Broker           string
+	Topics           []string
+	Key              string
+	DB               *sql.DB
+	Redis            *redis.Client
+	jwtKeys          *jwtkeys.Keyring
+	contentType      string
+	path             string
+	sengridkey       string
+	mailfrom         string
+	atklng           string
+	appusername      string
+	phoneRegion      string
+	userService      *service.UserService
+	roomService      *service.RoomService
+	bookingService   *service.BookingService
+	paymentService   *service.PaymentService
+	ledgerService    *service.LedgerService
+	reviewService    *service.ReviewService
+	eventService     *service.EventService
+	webhookService   *service.WebhookService
+	calendarService  *service.CalendarService
+	oidcService      *service.OIDCService
+	apiKeyService    *service.APIKeyService
+	auditService     *service.AuditService
+	trustProxy       bool
+	platformAdmins   []int
+	payoutInterval   time.Duration
+	webhookInterval  time.Duration
+	calendarInterval time.Duration
+	limits           *ratelimit.Policy
+	rates            money.RateProvider
+	stripesecret     string
+	pubkey           string
+	successURL       string
+	cancelURL        string
+	rabbit           *rabbitmq.Client
+	queueName        string
+	rabbitURL        string
+	rabbitCfg        entities.RabbitMQConfig
+	kafkaCfg         entities.KakfaConfig
 	// checkersProvider is overridden in tests; nil means use defaultLiveCheckers(). Used by HealthCheck.
 	checkersProvider func() []health.Checker
-	ctx              context.Context
-	KafkaStatus      int
-	RabbitMQStatus   int
+	// mailer and texter are overridden in tests; nil means SendGrid and
+	// Africa's Talking. Used by the profile handlers.
+	mailer          func(to, subject, text string) error
+	texter          func(phone, text string) error
+	healthMonitor   *health.Monitor
+	shutdownTracing func(context.Context) error
+	ctx             context.Context
+	KafkaStatus     int
+	RabbitMQStatus  int
 }
 
 func (b *Base) Init() {
@@ -73,6 +107,7 @@ func (b *Base) Init() {
 	var port int
 	var adminport int
 	var config entities.Config
+	var payoutConf entities.PayoutConfig
 
 	if os.Getenv("ENV") == "prod" {
 
@@ -82,9 +117,24 @@ func (b *Base) Init() {
 		redisDB, _ := strconv.Atoi(os.Getenv("REDIS_DB"))
 		userPort, _ := strconv.Atoi(os.Getenv("HTTP_PORT"))
 		adminPort, _ := strconv.Atoi(os.Getenv("ADMIN_PORT"))
+		commissionBps, _ := strconv.Atoi(os.Getenv("PAYOUT_COMMISSION_BPS"))
+		payoutMinimum, _ := strconv.ParseInt(os.Getenv("PAYOUT_MINIMUM"), 10, 64)
+		sampleRatio, _ := strconv.ParseFloat(os.Getenv("TRACING_SAMPLE_RATIO"), 64)
+		rabbitPoolSize, _ := strconv.Atoi(os.Getenv("RABBITMQ_POOL_SIZE"))
+		rabbitPrefetch, _ := strconv.Atoi(os.Getenv("RABBITMQ_PREFETCH"))
+		webhookMaxAttempts, _ := strconv.Atoi(os.Getenv("WEBHOOK_MAX_ATTEMPTS"))
+		lockoutThreshold, _ := strconv.Atoi(os.Getenv("LOCKOUT_THRESHOLD"))
 
 		config = entities.Config{
-			Logger: entities.LoggerConfig{Folder: os.Getenv("LOGGER_FOLDER")},
+			App: entities.AppConfig{
+				Admins: envIDs("PLATFORM_ADMINS"),
+			},
+			Logger: entities.LoggerConfig{
+				Folder:  os.Getenv("LOGGER_FOLDER"),
+				Level:   os.Getenv("LOG_LEVEL"),
+				Handler: os.Getenv("LOG_HANDLER"),
+				Writer:  os.Getenv("LOG_WRITER"),
+			},
 			Kafka: []entities.KakfaConfig{
 				{
 					Broker:           os.Getenv("KAFKA_BROKER"),
@@ -97,6 +147,9 @@ func (b *Base) Init() {
 					SaslPassword:     os.Getenv("KAFKA_SASL_PASSWORD"),
 					CaPem:            os.Getenv("KAFKA_CA_PEM"),
 					CaLocation:       os.Getenv("KAFKA_CA_LOCATION"),
+					Idempotent:       envBool("KAFKA_IDEMPOTENT", true),
+					TopicKeys:        envList("KAFKA_TOPIC_KEYS"),
+					PublishTimeout:   os.Getenv("KAFKA_PUBLISH_TIMEOUT"),
 				},
 			},
 			Rabbit: []entities.RabbitMQConfig{
@@ -111,6 +164,8 @@ func (b *Base) Init() {
 					TLS:        envBool("RABBIT_TLS", false),
 					CaPem:      os.Getenv("RABBIT_CA_PEM"),
 					CaLocation: os.Getenv("RABBIT_CA_LOCATION"),
+					PoolSize:   rabbitPoolSize,
+					Prefetch:   rabbitPrefetch,
 				},
 			},
 			Mysql: []entities.MysqlConfig{
@@ -138,12 +193,14 @@ func (b *Base) Init() {
 					AdminPort:   adminPort,
 					ContentType: os.Getenv("CONTENT_TYPE"),
 					Path:        os.Getenv("API_PATH"),
+					TrustProxy:  envBool("HTTP_TRUST_PROXY", false),
 				},
 			},
 			Secrets: []entities.SecretConfig{
 				{
 					Name:           "secrets",
 					JWT:            os.Getenv("JWT_SECRET"),
+					JWTUntil:       os.Getenv("JWT_SECRET_UNTIL"),
 					Sendgrid:       os.Getenv("SENDGRID_KEY"),
 					MailFrom:       os.Getenv("MAIL_FROM"),
 					AfricasTalking: os.Getenv("AT_KEY"),
@@ -162,6 +219,69 @@ func (b *Base) Init() {
 					CancelURL:    os.Getenv("STRIPE_CANCEL_URL"),
 				},
 			},
+			Payouts: []entities.PayoutConfig{
+				{
+					Name:          "payouts",
+					CommissionBps: commissionBps,
+					Interval:      os.Getenv("PAYOUT_INTERVAL"),
+					Minimum:       payoutMinimum,
+				},
+			},
+			Rates: []entities.RatesConfig{
+				{
+					Name: "rates",
+					File: os.Getenv("RATES_FILE"),
+				},
+			},
+			Health: []entities.HealthConfig{
+				{
+					Name:     "health",
+					Interval: os.Getenv("HEALTH_INTERVAL"),
+					Optional: envList("HEALTH_OPTIONAL"),
+				},
+			},
+			Tracing: []entities.TracingConfig{
+				{
+					Name:        "tracing",
+					ServiceName: os.Getenv("TRACING_SERVICE_NAME"),
+					Exporter:    os.Getenv("TRACING_EXPORTER"),
+					Endpoint:    os.Getenv("TRACING_ENDPOINT"),
+					Insecure:    envBool("TRACING_INSECURE", false),
+					File:        os.Getenv("TRACING_FILE"),
+					SampleRatio: sampleRatio,
+				},
+			},
+			Webhook: []entities.WebhookConfig{
+				{
+					Name:         "webhooks",
+					Interval:     os.Getenv("WEBHOOK_INTERVAL"),
+					Timeout:      os.Getenv("WEBHOOK_TIMEOUT"),
+					MaxAttempts:  webhookMaxAttempts,
+					AllowPrivate: envBool("WEBHOOK_ALLOW_PRIVATE", false),
+				},
+			},
+			Calendar: []entities.CalendarConfig{
+				{
+					Name:         "calendars",
+					Interval:     os.Getenv("CALENDAR_SYNC_INTERVAL"),
+					Timeout:      os.Getenv("CALENDAR_SYNC_TIMEOUT"),
+					AllowPrivate: envBool("CALENDAR_ALLOW_PRIVATE", false),
+				},
+			},
+			Limits:  envRateLimits("RATE_LIMITS"),
+			OIDC:    envOIDC(),
+			JWTKeys: envJWTKeys(),
+			Lockout: []entities.LockoutConfig{
+				{
+					Name:      "lockout",
+					Threshold: lockoutThreshold,
+					BaseDelay: os.Getenv("LOCKOUT_BASE_DELAY"),
+					MaxDelay:  os.Getenv("LOCKOUT_MAX_DELAY"),
+				},
+			},
+			Phone: entities.PhoneConfig{
+				DefaultRegion: os.Getenv("PHONE_DEFAULT_REGION"),
+			},
 		}
 
 	} else {
@@ -175,12 +295,24 @@ func (b *Base) Init() {
 
 	}
 
-	err := utils.InitLogger(config.Logger.Folder)
+	err := utils.InitLogger(config.Logger)
 	if err != nil {
-		utils.LogError(err.Error(), entities.ErrorLog)
+		slog.Error("initializing logger failed", "error", err)
 		os.Exit(1)
 	}
 
+	var tracingConf entities.TracingConfig
+	for _, t := range config.Tracing {
+		tracingConf = t
+	}
+
+	shutdownTracing, err := tracing.Setup(ctx, tracingConf)
+	if err != nil {
+		slog.Error("setting up tracing failed", "error", err)
+		os.Exit(1)
+	}
+	b.shutdownTracing = shutdownTracing
+
 	// Wait for backing services to be reachable before connecting, so the app
 	// doesn't exit when docker-compose services come up at different times.
 	b.waitForDependencies(config)
@@ -197,17 +329,19 @@ func (b *Base) Init() {
 	if b.KafkaStatus == 1 {
 		p, err := utils.ProducerConnect(b.kafkaCfg)
 		if err != nil {
-			utils.LogError(err.Error(), entities.ErrorLog)
+			slog.Error("connecting kafka producer failed", "error", err)
 			os.Exit(1)
 		}
 
 		c, err := utils.ConsumerConnect(b.kafkaCfg)
 		if err != nil {
-			utils.LogError(err.Error(), entities.ErrorLog)
+			slog.Error("connecting kafka consumer failed", "error", err)
 			os.Exit(1)
 		}
 
 		b.KafkaProducer = p
+		b.producer = producer.New(p, b.kafkaCfg.Key, topicKeys(b.kafkaCfg.TopicKeys))
+		b.publishTimeout = publishTimeout(b.kafkaCfg.PublishTimeout)
 		b.KafkaConsumer = c
 		b.Broker = brokerURL
 		b.Topics = paymentTopic
@@ -224,36 +358,57 @@ func (b *Base) Init() {
 	if b.RabbitMQStatus == 1 {
 		url := rabbitURL(b.rabbitCfg)
 		b.rabbitURL = url
-		conn, err := utils.NewRabbitMQConnection(url, utils.RabbitTLSConfig(b.rabbitCfg))
+		client, err := rabbitmq.New(rabbitmq.Config{
+			URL:      url,
+			TLS:      utils.RabbitTLSConfig(b.rabbitCfg),
+			PoolSize: b.rabbitCfg.PoolSize,
+			Prefetch: b.rabbitCfg.Prefetch,
+		})
 		if err != nil {
-			utils.LogError(err.Error(), entities.ErrorLog)
+			slog.Error("connecting to rabbitmq failed", "error", err)
 			os.Exit(1)
 		}
 
-		b.rabbitConn = conn
+		err = client.DeclareQueue(b.queueName)
+		if err != nil {
+			slog.Error("declaring rabbitmq queue failed", "queue", b.queueName, "error", err)
+			os.Exit(1)
+		}
+
+		b.rabbit = client
 	}
 	// else: RabbitMQ disabled — no dial, no exit.
 
 	for _, sql := range config.Mysql {
-		dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=latin1&parseTime=True&loc=Local", sql.Username, sql.Password, sql.Host, sql.Port, sql.Schema)
+		dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=latin1&parseTime=True&loc=UTC&time_zone=%%27%%2B00%%3A00%%27", sql.Username, sql.Password, sql.Host, sql.Port, sql.Schema)
 		db, err := connections.DatabaseConnection(dsn)
 		if err != nil {
-			utils.LogError(err.Error(), entities.ErrorLog)
+			slog.Error("connecting to mysql failed", "error", err)
 			os.Exit(1)
 		}
 
 		b.DB = db
 
+		err = metrics.RegisterDB(db, "mysql")
+		if err != nil {
+			slog.Error("registering mysql metrics failed", "error", err)
+		}
+
 	}
 
 	for _, cache := range config.Redis {
 		redisClient, err := connections.NewRedisDB(ctx, cache)
 		if err != nil {
-			utils.LogError(err.Error(), entities.ErrorLog)
+			slog.Error("connecting to redis failed", "error", err)
 			os.Exit(1)
 		}
 
 		b.Redis = redisClient
+
+		err = metrics.RegisterRedis(redisClient)
+		if err != nil {
+			slog.Error("registering redis metrics failed", "error", err)
+		}
 	}
 
 	for _, p := range config.Http {
@@ -261,17 +416,60 @@ func (b *Base) Init() {
 		adminport = p.AdminPort
 		b.contentType = p.ContentType
 		b.path = p.Path
+		b.trustProxy = p.TrustProxy
+
+	}
 
+	b.platformAdmins = config.App.Admins
+
+	rules, err := ratelimit.Rules(config.Limits)
+	if err != nil {
+		slog.Error("loading rate limits failed", "error", err)
+		os.Exit(1)
 	}
 
+	if b.Redis != nil {
+		b.limits = ratelimit.NewPolicy(ratelimit.NewRedisLimiter(b.Redis), rules, b.trustProxy)
+	}
+
+	var jwtSecret, jwtSecretUntil string
 	for _, secret := range config.Secrets {
-		b.jwtSecret = secret.JWT
+		jwtSecret = secret.JWT
+		jwtSecretUntil = secret.JWTUntil
 		b.sengridkey = secret.Sendgrid
 		b.mailfrom = secret.MailFrom
 		b.atklng = secret.AfricasTalking
 		b.appusername = secret.AppUsername
 	}
 
+	b.phoneRegion = strings.ToUpper(config.Phone.DefaultRegion)
+	if b.phoneRegion == "" {
+		b.phoneRegion = phone.DefaultRegion
+	}
+
+	if !phone.ValidRegion(b.phoneRegion) {
+		slog.Error("loading phone settings failed", "default_region", b.phoneRegion, "error", phone.ErrUnknownRegion)
+		os.Exit(1)
+	}
+
+	b.jwtKeys, err = jwtkeys.New(config.JWTKeys, jwtSecret, jwtSecretUntil)
+	if err != nil {
+		slog.Error("loading jwt signing keys failed", "error", err)
+		os.Exit(1)
+	}
+
+	if key := b.jwtKeys.Current(); key != nil {
+		slog.Info("signing auth tokens", "kid", key.ID, "alg", key.Alg)
+	} else if b.jwtKeys.SecretAccepted() {
+		slog.Warn("no active jwt signing key, signing auth tokens with the hmac secret")
+	} else {
+		slog.Error("no active jwt signing key and the hmac secret has expired, no auth tokens can be issued")
+	}
+
+	if b.jwtKeys.SecretAccepted() {
+		slog.Warn("hs256 auth tokens are still accepted, set jwtuntil and then clear the jwt secret", "until", b.jwtKeys.SecretUntil())
+	}
+
 	for _, _stripe := range config.Stripe {
 		b.successURL = _stripe.SuccessURL
 		b.cancelURL = _stripe.CancelURL
@@ -279,6 +477,26 @@ func (b *Base) Init() {
 		b.stripesecret = _stripe.StripeSecret
 	}
 
+	for _, payout := range config.Payouts {
+		payoutConf = payout
+	}
+
+	b.payoutInterval = payoutInterval(payoutConf.Interval)
+
+	for _, rates := range config.Rates {
+		if rates.File == "" {
+			continue
+		}
+
+		provider, err := money.NewFileRateProvider(rates.File)
+		if err != nil {
+			slog.Error("loading exchange rates failed", "file", rates.File, "error", err)
+			os.Exit(1)
+		}
+
+		b.rates = provider
+	}
+
 	b.AuthPort = strconv.Itoa(port)
 	b.AdminPort = strconv.Itoa(adminport)
 
@@ -286,10 +504,24 @@ func (b *Base) Init() {
 	// have a non-nil context to pass down to the service/repo layers.
 	b.ctx = ctx
 
+	var lockoutConf entities.LockoutConfig
+	for _, l := range config.Lockout {
+		lockoutConf = l
+	}
+
 	// Initializing user repo
 	userRepository := repo.NewDBRepository(b.DB, b.Redis)
-	userService := service.NewUserService(*userRepository)
+	userService := service.NewUserService(*userRepository, lockoutConf)
 	b.userService = userService
+	b.oidcService = service.NewOIDCService(*userRepository, userService, config.OIDC)
+
+	// Initializing api key repo
+	apiKeyRepository := repo.NewDBRepository(b.DB, b.Redis)
+	b.apiKeyService = service.NewAPIKeyService(*apiKeyRepository)
+
+	// Initializing audit repo
+	auditRepository := repo.NewDBRepository(b.DB, b.Redis)
+	b.auditService = service.NewAuditService(*auditRepository)
 
 	// Initializing room repo
 	roomRepository := repo.NewDBRepository(b.DB, b.Redis)
@@ -306,12 +538,72 @@ func (b *Base) Init() {
 	paymentService := service.NewPaymentService(*paymentRepository)
 	b.paymentService = paymentService
 
-	_msg := fmt.Sprintf("Connections done in %v\n", time.Since(startTime))
-	utils.LogInfo(_msg, entities.InfoLog)
+	// Initialize review repo
+	reviewRepository := repo.NewDBRepository(b.DB, b.Redis)
+	reviewService := service.NewReviewService(*reviewRepository)
+	b.reviewService = reviewService
+
+	// Initialize ledger repo
+	ledgerRepository := repo.NewDBRepository(b.DB, b.Redis)
+	ledgerService := service.NewLedgerService(*ledgerRepository, payoutConf)
+	b.ledgerService = ledgerService
+
+	// Initialize event repo
+	eventRepository := repo.NewDBRepository(b.DB, b.Redis)
+	b.eventService = service.NewEventService(*eventRepository)
+
+	var webhookConf entities.WebhookConfig
+	for _, wh := range config.Webhook {
+		webhookConf = wh
+	}
+
+	// Initialize webhook repo
+	webhookRepository := repo.NewDBRepository(b.DB, b.Redis)
+	b.webhookService = service.NewWebhookService(*webhookRepository, webhookConf)
+	b.webhookInterval = webhookInterval(webhookConf.Interval)
+
+	var calendarConf entities.CalendarConfig
+	for _, c := range config.Calendar {
+		calendarConf = c
+	}
+
+	// Initialize calendar repo
+	calendarRepository := repo.NewDBRepository(b.DB, b.Redis)
+	b.calendarService = service.NewCalendarService(*calendarRepository, calendarConf)
+	b.calendarInterval = calendarInterval(calendarConf.Interval)
 
+	var healthConf entities.HealthConfig
+	for _, h := range config.Health {
+		healthConf = h
+	}
+
+	b.healthMonitor = health.NewMonitor(
+		markOptional(b.healthCheckers(), healthOptional(healthConf.Optional)),
+		healthInterval(healthConf.Interval),
+	)
+
+	slog.Info("connections done", "took", time.Since(startTime).String())
+
+}
+
+// FlushTraces exports any spans still buffered. Call it before exiting.
+func (b *Base) FlushTraces() {
+	if b.shutdownTracing == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := b.shutdownTracing(ctx)
+	if err != nil {
+		slog.Error("flushing traces failed", "error", err)
+	}
 }
 
-func (b *Base) UserServer(wg *sync.WaitGroup, port, server string) {
+// UserServer serves the user API until ctx is cancelled, then drains in-flight
+// requests before returning.
+func (b *Base) UserServer(ctx context.Context, wg *sync.WaitGroup, port, server string) {
 	defer wg.Done()
 
 	userSRV := &http.Server{
@@ -319,16 +611,19 @@ func (b *Base) UserServer(wg *sync.WaitGroup, port, server string) {
 		Handler: b.userRouter(),
 	}
 
-	fmt.Printf("Listening to %v server on port %s \n", server, port)
-	err := userSRV.ListenAndServe()
+	slog.Info("server listening", "server", server, "port", port)
+	err := serve(ctx, userSRV)
 	if err != nil {
-		utils.LogError(err.Error(), entities.ErrorLog)
+		slog.Error("server stopped", "server", server, "error", err)
 		os.Exit(1)
 	}
 
+	slog.Info("server stopped", "server", server)
 }
 
-func (b *Base) AdminServer(wg *sync.WaitGroup, port, server string) {
+// AdminServer serves the admin API until ctx is cancelled, then drains in-flight
+// requests before returning.
+func (b *Base) AdminServer(ctx context.Context, wg *sync.WaitGroup, port, server string) {
 	defer wg.Done()
 
 	userSRV := &http.Server{
@@ -336,18 +631,22 @@ func (b *Base) AdminServer(wg *sync.WaitGroup, port, server string) {
 		Handler: b.adminRouter(),
 	}
 
-	fmt.Printf("Listening to %v server on port %s \n", server, port)
-	err := userSRV.ListenAndServe()
+	slog.Info("server listening", "server", server, "port", port)
+	err := serve(ctx, userSRV)
 	if err != nil {
-		utils.LogError(err.Error(), entities.ErrorLog)
+		slog.Error("server stopped", "server", server, "error", err)
 		os.Exit(1)
 	}
 
+	slog.Info("server stopped", "server", server)
 }
 
 func (b *Base) userRouter() http.Handler {
 	r := chi.NewRouter()
+	r.Use(utils.RequestID)
 	r.Use(middleware.Recoverer)
+	r.Use(metrics.Middleware("user"))
+	r.Use(tracing.Middleware("user"))
 	utils.SetCors(r)
 
 	swaggerURL := ""
@@ -364,22 +663,47 @@ func (b *Base) userRouter() http.Handler {
 	))
 
 	// Public Routes
-	r.Post(b.path+"/user/register", b.RegisterHandler)
-	r.Post(b.path+"/user/login", b.LoginHandler)
+	r.With(b.limits.For("register")).Post(b.path+"/user/register", b.RegisterHandler)
+	r.With(b.limits.For("login")).Post(b.path+"/user/login", b.LoginHandler)
+	r.With(b.limits.For("mfa")).Post(b.path+"/user/login/mfa", b.MFALoginHandler)
+	r.With(b.limits.For("login")).Get(b.path+"/user/oidc/{provider}/login", b.OIDCLoginHandler)
+	r.With(b.limits.For("login")).Get(b.path+"/user/oidc/{provider}/callback", b.OIDCCallbackHandler)
 	r.Get(b.path+"/user/rooms", b.FindRoomHandler)
+	r.Get(b.path+"/user/rooms/{room_id}/reviews", b.GetRoomReviewsHandler)
+	r.Get(b.path+"/user/rooms/{room_id}/calendar.ics", b.RoomCalendarFeedHandler)
+	r.Get(b.path+"/user/rooms/{room_id}/availability", b.RoomAvailabilityHandler)
+	r.Get(b.path+"/user/vendors/{vendor_id}/rating", b.GetVendorRatingHandler)
 	r.Get(b.path+"/health/test", b.HealthCheck)
+	r.Get("/.well-known/jwks.json", b.JWKSHandler)
+	r.Get("/livez", b.LivezHandler)
+	r.Get("/readyz", b.ReadyzHandler)
+	r.Get("/startupz", b.StartupzHandler)
 
 	// Private routes
 	r.Route(b.path, func(r chi.Router) {
-		r.Use(utils.AuthMiddleware(b.jwtSecret))
+		r.Use(utils.AuthMiddleware(b.jwtKeys, b.userService))
+		r.Use(audit.Middleware(b.auditService, b.trustProxy, false))
 		r.Get("/user/me", b.ProfileHandler)
-		r.Post("/user/reset", b.GenerateResetTokenHandler)
-		r.Post("/user/password-reset", b.ResetPasswordHandler)
+		r.With(b.limits.For("profile")).Patch("/user/me", b.UpdateProfileHandler)
+		r.With(b.limits.For("profile")).Delete("/user/me", b.DeleteAccountHandler)
+		r.With(b.limits.For("profile")).Post("/user/me/phone/code", b.SendPhoneCodeHandler)
+		r.With(b.limits.For("mfa")).Post("/user/me/phone/verify", b.VerifyPhoneHandler)
+		r.With(b.limits.For("profile")).Post("/user/me/email", b.ChangeEmailHandler)
+		r.Post("/user/me/email/confirm", b.ConfirmEmailHandler)
+		r.With(b.limits.For("profile")).Put("/user/me/password", b.ChangePasswordHandler)
+		r.Get("/user/me/export", b.ExportPersonalDataHandler)
+		r.With(b.limits.For("reset")).Post("/user/reset", b.GenerateResetTokenHandler)
+		r.With(b.limits.For("password-reset")).Post("/user/password-reset", b.ResetPasswordHandler)
+		r.Post("/user/mfa/enroll", b.EnrollMFAHandler)
+		r.With(b.limits.For("mfa")).Post("/user/mfa/verify", b.ConfirmMFAHandler)
+		r.With(b.limits.For("mfa")).Delete("/user/mfa", b.DisableMFAHandler)
 		r.Post("/user/book", b.CreateBookingHandler)
 		r.Get("/user/book/verify/{room_id}", b.VerifyBookingHandler)
 		r.Get("/user/book/{room_id}", b.GetBookingHandler)
 		r.Get("/user/book/all", b.GetAllBookingsHandler)
 		r.Put("/user/book/{booking_id}", b.UpdateBooking)
+		r.Put("/user/book/{booking_id}/cancel", b.GuestCancelBookingHandler)
+		r.Post("/user/reviews", b.CreateReviewHandler)
 
 	})
 
@@ -389,7 +713,10 @@ func (b *Base) userRouter() http.Handler {
 
 func (b *Base) adminRouter() http.Handler {
 	router := chi.NewRouter()
+	router.Use(utils.RequestID)
 	router.Use(middleware.Recoverer)
+	router.Use(metrics.Middleware("admin"))
+	router.Use(tracing.Middleware("admin"))
 	utils.SetCors(router)
 	swaggerURL := ""
 	if os.Getenv("ENV") == "prod" {
@@ -404,14 +731,86 @@ func (b *Base) adminRouter() http.Handler {
 		httpSwagger.DocExpansion("none"),
 	))
 
+	// Scraped by Prometheus, which does not carry a vendor token. The admin
+	// server is not exposed publicly.
+	router.Handle("/metrics", metrics.Handler())
+
 	router.Route(b.path, func(r chi.Router) {
-		r.Use(utils.AuthMiddleware(b.jwtSecret))
+		r.Use(utils.AuthOrAPIKeyMiddleware(b.jwtKeys, b.userService, b.apiKeyService))
 		r.Use(utils.AdminMiddlware)
-		r.Post("/admin/rooms", b.CreateRoomHandler)
-		r.Put("/admin/rooms/{room_id}", b.UpdateARoom)
-		r.Delete("/admin/rooms/{room_id}", b.DeleteARoom)
-		r.Get("/admin/book/all", b.GetAllAdminBookingsHandler)
-		r.Delete("/admin/book/{booking_id}/{room_id}", b.DeleteBooking)
+		r.Use(audit.Middleware(b.auditService, b.trustProxy, true))
+
+		r.With(utils.RequireScope(entities.ScopeRoomsRead)).Group(func(r chi.Router) {
+			r.Get("/admin/rooms/{room_id}/calendar", b.VendorRoomCalendarHandler)
+			r.Get("/admin/rooms/{room_id}/calendars", b.GetRoomCalendarsHandler)
+			r.Get("/admin/rooms/{room_id}/blocks", b.GetRoomBlocksHandler)
+		})
+
+		r.With(utils.RequireScope(entities.ScopeRoomsWrite)).Group(func(r chi.Router) {
+			r.Post("/admin/rooms", b.CreateRoomHandler)
+			r.Put("/admin/rooms/{room_id}", b.UpdateARoom)
+			r.Delete("/admin/rooms/{room_id}", b.DeleteARoom)
+			r.Post("/admin/rooms/{room_id}/calendar/feed", b.RotateCalendarFeedHandler)
+			r.Post("/admin/rooms/{room_id}/calendars", b.AddRoomCalendarHandler)
+			r.Delete("/admin/rooms/{room_id}/calendars/{calendar_id}", b.DeleteRoomCalendarHandler)
+			r.Post("/admin/rooms/{room_id}/blocks", b.CreateRoomBlockHandler)
+			r.Put("/admin/rooms/{room_id}/blocks/{block_id}", b.UpdateRoomBlockHandler)
+			r.Delete("/admin/rooms/{room_id}/blocks/{block_id}", b.DeleteRoomBlockHandler)
+		})
+
+		r.With(utils.RequireScope(entities.ScopeBookingsRead)).Get("/admin/book/all", b.GetAllAdminBookingsHandler)
+
+		r.With(utils.RequireScope(entities.ScopeBookingsWrite)).Group(func(r chi.Router) {
+			r.Delete("/admin/book/{booking_id}/{room_id}", b.DeleteBooking)
+			r.Put("/admin/book/{booking_id}/check-in", b.CheckInHandler)
+			r.Put("/admin/book/{booking_id}/check-out", b.CheckOutHandler)
+			r.Put("/admin/book/{booking_id}/cancel", b.CancelBookingHandler)
+			r.Put("/admin/book/{booking_id}/no-show", b.NoShowHandler)
+		})
+
+		r.With(utils.RequireScope(entities.ScopePayoutsRead)).Group(func(r chi.Router) {
+			r.Get("/admin/payouts", b.GetVendorPayoutsHandler)
+			r.Get("/admin/payouts/balance", b.GetVendorBalanceHandler)
+			r.Get("/admin/payouts/statement", b.ExportStatementHandler)
+		})
+
+		r.With(utils.RequireScope(entities.ScopePayoutsWrite)).Group(func(r chi.Router) {
+			r.Post("/admin/transactions/{trx_id}/refund", b.RefundTransactionHandler)
+		})
+
+		r.With(utils.RequireScope(entities.ScopeReviewsRead)).Get("/admin/reviews", b.GetVendorReviewsHandler)
+
+		r.With(utils.RequireScope(entities.ScopeReviewsWrite)).Group(func(r chi.Router) {
+			r.Put("/admin/reviews/{review_id}/reply", b.ReplyToReviewHandler)
+		})
+
+		r.With(utils.RequireScope(entities.ScopeWebhooksRead)).Group(func(r chi.Router) {
+			r.Get("/admin/webhooks", b.GetWebhooksHandler)
+			r.Get("/admin/webhooks/{webhook_id}/deliveries", b.GetWebhookDeliveriesHandler)
+		})
+
+		r.With(utils.RequireScope(entities.ScopeWebhooksWrite)).Group(func(r chi.Router) {
+			r.Post("/admin/webhooks", b.CreateWebhookHandler)
+			r.Put("/admin/webhooks/{webhook_id}", b.UpdateWebhookHandler)
+			r.Delete("/admin/webhooks/{webhook_id}", b.DeleteWebhookHandler)
+			r.Post("/admin/webhooks/{webhook_id}/deliveries/{delivery_id}/redeliver", b.RedeliverWebhookHandler)
+		})
+
+		// Logged in platform admins only.
+		r.With(utils.RequireSession, utils.RequirePlatformAdmin(b.platformAdmins)).Group(func(r chi.Router) {
+			r.Post("/admin/users/unlock", b.UnlockAccountHandler)
+			r.Put("/admin/payouts/{payout_id}/paid", b.SettlePayoutHandler)
+			r.Put("/admin/reviews/{review_id}/flag", b.FlagReviewHandler)
+		})
+
+		// Logged in vendors only, not API keys.
+		r.With(utils.RequireSession).Group(func(r chi.Router) {
+			r.Post("/admin/api-keys", b.CreateAPIKeyHandler)
+			r.Get("/admin/api-keys", b.GetAPIKeysHandler)
+			r.Delete("/admin/api-keys/{key_id}", b.RevokeAPIKeyHandler)
+			r.Get("/admin/audit", b.GetAuditLogHandler)
+			r.Get("/admin/audit/export", b.ExportAuditLogHandler)
+		})
 
 	})
 
@@ -5,11 +5,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/events"
+	"github.com/bicosteve/booking-system/pkg/metrics"
 	"github.com/bicosteve/booking-system/pkg/payments"
 	"github.com/bicosteve/booking-system/pkg/utils"
 	"github.com/go-chi/chi/v5"
@@ -26,6 +29,7 @@ import (
 // @Param  payload body entities.RoomPayload true "Create room"
 // @Success 201 {object} entities.JSONResponse "{"msg":"created"}"
 // @Failure 401 {object} entities.JSONResponse "Unauthorized"
+// @Failure 409 {object} entities.JSONResponse "Room blocked on those dates"
 // @Failure 500 {object} entities.JSONResponse "Internal server error"
 // @Router /api/user/book [post]
 func (b *Base) CreateBookingHandler(w http.ResponseWriter, r *http.Request) {
@@ -37,21 +41,28 @@ func (b *Base) CreateBookingHandler(w http.ResponseWriter, r *http.Request) {
 
 	err := utils.SerializeJSON(w, r, payload)
 	if err != nil {
-		utils.LogError("BOOKING: %s - %s", entities.ErrorLog, err.Error(), http.StatusBadRequest)
+		slog.WarnContext(r.Context(), "create booking failed", "error", err, "status", http.StatusBadRequest)
 		utils.ErrorJSON(w, err, http.StatusBadRequest)
 		return
 	}
 
 	err = utils.ValidateBooking(payload)
 	if err != nil {
-		utils.LogError("BOOKING: %s - %s", entities.ErrorLog, err.Error(), http.StatusBadRequest)
+		slog.WarnContext(r.Context(), "create booking failed", "error", err, "status", http.StatusBadRequest)
 		utils.ErrorJSON(w, err, http.StatusBadRequest)
 		return
 	}
 
+	// New bookings are pending until payment is verified.
+	if payload.Status != nil {
+		slog.WarnContext(r.Context(), "status cannot be set on a new booking", "status", http.StatusBadRequest)
+		utils.ErrorJSON(w, errors.New("booking status cannot be set, new bookings are pending"), http.StatusBadRequest)
+		return
+	}
+
 	userID, ok := r.Context().Value(entities.UseridKeyValue).(string)
 	if !ok {
-		utils.LogError("BOOKING: Failed get user_id from context %s", entities.ErrorLog, http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "could not get user_id from context", "status", http.StatusInternalServerError)
 		utils.ErrorJSON(w, err, http.StatusInternalServerError)
 		return
 
@@ -60,14 +71,44 @@ func (b *Base) CreateBookingHandler(w http.ResponseWriter, r *http.Request) {
 	userid, _ := strconv.Atoi(userID)
 	payload.UserID = &userid
 
+	// The guest is charged in the room's currency; converted prices are for
+	// display only.
+	room, err := b.roomService.FindARoom(ctx, *payload.RoomID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "create booking failed", "room_id", *payload.RoomID, "error", err)
+		utils.ErrorJSON(w, errors.New("room not found"), http.StatusNotFound)
+		return
+	}
+
+	if payload.CheckIn == nil {
+		today := time.Now().Format(entities.DateLayout)
+		payload.CheckIn = &today
+	}
+
+	// Blocked dates are turned down before the guest is asked to pay.
+	checkIn, _ := time.Parse(entities.DateLayout, *payload.CheckIn)
+	err = b.bookingService.CheckAvailability(ctx, *payload.RoomID, checkIn, *payload.Days)
+	if err != nil {
+		slog.WarnContext(r.Context(), "create booking failed", "room_id", *payload.RoomID, "check_in", *payload.CheckIn, "error", err)
+		if errors.Is(err, entities.ErrRoomBlocked) {
+			utils.ErrorJSON(w, err, http.StatusConflict)
+			return
+		}
+		utils.ErrorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	charge := room.Cost.Mul(int64(*payload.Days))
+	payload.Currency = &charge.Currency
+
 	payDetails := entities.TRXPayload{
 		RoomID:  *payload.RoomID,
 		UserID:  *payload.UserID,
 		OrderID: uuid.New().String(),
 		Days:    *payload.Days,
 		Payment: entities.PaymentBody{
-			Amount:      int64(*payload.Amount),
-			Currency:    "kes",
+			Amount:      charge.Amount,
+			Currency:    charge.Currency,
 			Customer:    *payload.UserID,
 			Description: fmt.Sprintf("booking_%d", payload.RoomID),
 		},
@@ -83,23 +124,23 @@ func (b *Base) CreateBookingHandler(w http.ResponseWriter, r *http.Request) {
 	// 1. Check if there is an active payment session or create new payment session
 	active, err := b.paymentService.GetActivePayment(ctx, userID)
 	if err != nil {
-		utils.LogError("BOOKING: %s %s", entities.ErrorLog, err.Error(), http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "create booking failed", "error", err, "status", http.StatusInternalServerError)
 		utils.ErrorJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 
 	// 2. If there is an active payment i.e status='initial' --> client_secret,pub_key
 	if active.Status == "initial" {
-		utils.LogInfo("BOOKING: Active payment ongoing", entities.InfoLog)
+		slog.InfoContext(r.Context(), "active payment ongoing")
 		_ = utils.DeserializeJSON(w, http.StatusOK, map[string]any{"message": "You have an active payment,confirm payment to proceed", "client_secret": active.ClientSecret, "pub_key": b.pubkey})
 		return
 
 	}
 
 	// 3. Create Payment Session on Stripe Before Booking
-	PaymentSession, err := payments.CreateStripePayment(stripeConf, payDetails)
+	PaymentSession, err := payments.CreateStripePayment(ctx, stripeConf, payDetails)
 	if err != nil {
-		utils.LogError("BOOKING: %s %s", entities.ErrorLog, err.Error(), http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "create booking failed", "error", err, "status", http.StatusInternalServerError)
 		utils.ErrorJSON(w, err, http.StatusInternalServerError)
 		return
 	}
@@ -107,21 +148,35 @@ func (b *Base) CreateBookingHandler(w http.ResponseWriter, r *http.Request) {
 	// 4. Store Payments In Redis
 	err = b.paymentService.HoldPayment(ctx, PaymentSession, payDetails)
 	if err != nil {
-		utils.LogError("BOOKING: %s %s", entities.ErrorLog, err.Error(), http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "create booking failed", "error", err, "status", http.StatusInternalServerError)
 		utils.ErrorJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 
 	// 5. Make Booking
-	err = b.bookingService.MakeBooking(ctx, *payload)
+	bookingID, err := b.bookingService.MakeBooking(ctx, *payload)
+	if errors.Is(err, entities.ErrRoomBlocked) {
+		slog.WarnContext(r.Context(), "create booking failed", "room_id", *payload.RoomID, "check_in", *payload.CheckIn, "error", err)
+		utils.ErrorJSON(w, err, http.StatusConflict)
+		return
+	}
 	if err != nil {
-		utils.LogError("BOOKING: %s %s", entities.ErrorLog, err.Error(), http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "create booking failed", "error", err, "status", http.StatusInternalServerError)
 		utils.ErrorJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 
+	booking := &entities.Booking{
+		ID:     bookingID,
+		Days:   *payload.Days,
+		UserID: userid,
+		RoomID: *payload.RoomID,
+		Status: entities.BookingStatusPending,
+	}
+	b.publishEvent(ctx, events.BookingCreated, strconv.Itoa(bookingID), bookingEvent(booking, payDetails.OrderID, ""))
+
 	// 6. Return client_secret, pubkey, room_id
-	_ = utils.DeserializeJSON(w, http.StatusCreated, map[string]any{"msg": "booking created", "pubkey": b.pubkey, "client_secret": PaymentSession.ClientSecret, "room_id": payload.RoomID})
+	_ = utils.DeserializeJSON(w, http.StatusCreated, map[string]any{"msg": "booking created", "pubkey": b.pubkey, "client_secret": PaymentSession.ClientSecret, "room_id": payload.RoomID, "amount": charge})
 
 }
 
@@ -144,7 +199,7 @@ func (b *Base) VerifyBookingHandler(w http.ResponseWriter, r *http.Request) {
 
 	booking_id, err := strconv.Atoi(chi.URLParam(r, "room_id"))
 	if err != nil {
-		utils.LogError(err.Error(), entities.ErrorLog)
+		slog.ErrorContext(r.Context(), "verify booking failed", "error", err)
 		utils.ErrorJSON(w, err, http.StatusBadRequest)
 		return
 	}
@@ -152,7 +207,7 @@ func (b *Base) VerifyBookingHandler(w http.ResponseWriter, r *http.Request) {
 	// 1. Get authorized user
 	user, ok := r.Context().Value(entities.UseridKeyValue).(string)
 	if !ok {
-		utils.LogError("could not get logged in user", entities.ErrorLog)
+		slog.ErrorContext(r.Context(), "could not get logged in user")
 		utils.ErrorJSON(w, errors.New("could not get logged in user"), http.StatusInternalServerError)
 		return
 	}
@@ -160,7 +215,7 @@ func (b *Base) VerifyBookingHandler(w http.ResponseWriter, r *http.Request) {
 	user_id, _ := strconv.Atoi(user)
 	booking, err := b.bookingService.GetUserBooking(ctx, booking_id, user_id)
 	if err != nil {
-		utils.LogError(err.Error(), entities.ErrorLog)
+		slog.ErrorContext(r.Context(), "verify booking failed", "error", err)
 		utils.ErrorJSON(w, err, http.StatusBadRequest)
 		return
 	}
@@ -168,57 +223,66 @@ func (b *Base) VerifyBookingHandler(w http.ResponseWriter, r *http.Request) {
 	// 2. Do we have active payment in Redis?
 	active, err := b.paymentService.GetActivePayment(ctx, user)
 	if err != nil {
-		utils.LogError(err.Error(), entities.ErrorLog)
+		slog.ErrorContext(r.Context(), "verify booking failed", "error", err)
 		utils.ErrorJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 
 	// 3. What is the status
 	if active.Status != "initial" {
-		utils.LogError("VERIFY: no active payment for d% s%", entities.ErrorLog, user_id, http.StatusBadRequest)
+		slog.WarnContext(r.Context(), "no active payment", "user_id", user_id, "status", http.StatusBadRequest)
 		utils.ErrorJSON(w, errors.New("you do not have active payment"), http.StatusBadRequest)
 		return
 	}
 
 	// 4. Fetch payment status from stripe
-	pi, err := payments.GetPaymentStatus(b.stripesecret, active.PaymentId)
+	pi, err := payments.GetPaymentStatus(ctx, b.stripesecret, active.PaymentId)
 	if err != nil {
-		utils.LogError("VERIFY: no payment from stripe s%", entities.ErrorLog, http.StatusBadRequest)
+		slog.WarnContext(r.Context(), "no payment from stripe", "status", http.StatusBadRequest)
 		utils.ErrorJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 
 	// 5. Can be used to store failed transactions
 	payJSON, _ := json.Marshal(pi)
-	paylogs := string(payJSON)
-	utils.LogInfo(paylogs, entities.InfoLog)
+	slog.InfoContext(r.Context(), "stripe payment intent fetched", "payment_id", pi.ID, "payment_intent", json.RawMessage(payJSON))
 
 	if pi.Status != "succeeded" {
-		utils.LogError("payment did not succeed", entities.ErrorLog)
+		metrics.Payments.WithLabelValues("failed").Inc()
+		failed := paymentEvent(entities.TRXPayload{
+			RoomID:    booking.RoomID,
+			UserID:    user_id,
+			OrderID:   active.OrderID,
+			Reference: active.TransactionID,
+			TrxID:     active.PaymentId,
+			Days:      booking.Days,
+			Payment:   entities.PaymentBody{Amount: active.Amount, Currency: active.Currency},
+		})
+		failed.Reason = "payment intent " + string(pi.Status)
+		b.publishEvent(ctx, events.PaymentFailed, active.PaymentId, failed)
+		slog.ErrorContext(r.Context(), "payment did not succeed")
 		utils.ErrorJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 
-	data := entities.BookingPayload{
-		Days:   &booking.Days,
-		UserID: &user_id,
-		RoomID: &booking.RoomID,
-		Status: &entities.BookingStatusConfirmed,
-	}
+	metrics.Payments.WithLabelValues("succeeded").Inc()
 
 	// 6. If payment is successful, confirm booking & send sms/email
-	err = b.bookingService.UpdateABooking(ctx, &data, booking_id)
+	err = b.bookingService.ChangeBookingStatus(ctx, booking, entities.BookingStatusConfirmed,
+		entities.BookingActor{Role: entities.ActorSystem}, "payment "+pi.ID+" succeeded")
 	if err != nil {
-		utils.LogError("VERIFY: %s %s", entities.ErrorLog, err.Error(), http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "verify booking failed", "error", err, "status", http.StatusInternalServerError)
 		utils.ErrorJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 
+	b.publishBookingEvent(ctx, booking, active.OrderID, "")
+
 	var status = entities.BookingStatusConfirmed
 
 	err = b.paymentService.UpdatePayment(ctx, status, pi.ID)
 	if err != nil {
-		utils.LogError("VERIFY: %s %s", entities.ErrorLog, err.Error(), http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "verify booking failed", "error", err, "status", http.StatusInternalServerError)
 		utils.ErrorJSON(w, err, http.StatusInternalServerError)
 		return
 	}
@@ -230,16 +294,28 @@ func (b *Base) VerifyBookingHandler(w http.ResponseWriter, r *http.Request) {
 		Reference: active.TransactionID,
 		TrxID:     active.PaymentId,
 		Status:    status,
+		Days:      booking.Days,
 		Payment: entities.PaymentBody{
-			Amount: int64(active.Amount),
+			Amount:   active.Amount,
+			Currency: active.Currency,
 		},
 	}
 
+	succeeded, err := events.New(events.PaymentSucceeded, paymentEvent(trx))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "building payment event failed", "error", err, "status", http.StatusInternalServerError)
+		utils.ErrorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
 	if b.KafkaStatus == 1 {
 		// Checks if kafka is switched on with 1
-		err = utils.QPublishMessage(b.kafkaCfg, b.Topics[1], b.Key, trx)
+		msg, err := eventMessage(b.eventTopic(succeeded.Type), "", succeeded)
+		if err == nil {
+			err = b.producer.PublishSync(ctx, msg, b.publishTimeout)
+		}
 		if err != nil {
-			utils.LogError("KAFKA: %s %s", entities.ErrorLog, err.Error(), http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "publishing payment to kafka failed", "error", err, "status", http.StatusInternalServerError)
 			utils.ErrorJSON(w, err, http.StatusInternalServerError)
 			return
 		}
@@ -247,9 +323,9 @@ func (b *Base) VerifyBookingHandler(w http.ResponseWriter, r *http.Request) {
 
 	if b.RabbitMQStatus == 1 {
 		// Only publish successful transactions
-		err = utils.PublishToMQ(b.queueName, trx)
+		err = b.rabbit.PublishJSON(ctx, b.queueName, succeeded)
 		if err != nil {
-			utils.LogError("RABBITMQ: Failed to publish %s - %", entities.ErrorLog, err.Error(), http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "publishing payment to rabbitmq failed", "error", err, "status", http.StatusInternalServerError)
 			utils.ErrorJSON(w, err, http.StatusInternalServerError)
 			return
 		}
@@ -257,7 +333,7 @@ func (b *Base) VerifyBookingHandler(w http.ResponseWriter, r *http.Request) {
 
 	err = b.paymentService.RemovePayment(ctx, user)
 	if err != nil {
-		utils.LogError("VERIFY: %s %s", entities.ErrorLog, err.Error(), http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "verify booking failed", "error", err, "status", http.StatusInternalServerError)
 		utils.ErrorJSON(w, err, http.StatusInternalServerError)
 		return
 	}
@@ -268,7 +344,7 @@ func (b *Base) VerifyBookingHandler(w http.ResponseWriter, r *http.Request) {
 
 // Get a booking godoc
 // @Summary get a booking
-// @Description Receives room_id then retrieves a booking
+// @Description Retrieves one of the user's bookings with its status history
 // @ID get-booking
 // @Tags bookings
 // @Accept json
@@ -286,24 +362,35 @@ func (b *Base) GetBookingHandler(w http.ResponseWriter, r *http.Request) {
 
 	bookingID, err := strconv.Atoi(chi.URLParam(r, "room_id"))
 	if err != nil {
-		utils.LogError("GETBOOKING: %s %s", entities.ErrorLog, err.Error(), http.StatusBadRequest)
+		slog.WarnContext(r.Context(), "get booking failed", "error", err, "status", http.StatusBadRequest)
 		utils.ErrorJSON(w, err, http.StatusBadRequest)
 		return
 	}
 
 	userID, ok := r.Context().Value(entities.UseridKeyValue).(string)
 	if !ok {
-		utils.LogError("GETBOOKING: Could not get user_id from context %s", entities.ErrorLog, http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "could not get user_id from context", "status", http.StatusInternalServerError)
 		utils.ErrorJSON(w, err, http.StatusInternalServerError)
 		return
 
 	}
 	userid, _ := strconv.Atoi(userID)
 
-	book, err := b.bookingService.GetUserBooking(ctx, bookingID, userid)
+	book, err := b.bookingService.GetGuestBooking(ctx, bookingID, userid)
 	if err != nil {
-		utils.LogError(err.Error(), entities.ErrorLog)
-		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		slog.ErrorContext(r.Context(), "get booking failed", "error", err)
+		if errors.Is(err, entities.ErrNoRecord) {
+			utils.ErrorJSON(w, errors.New("booking not found"), http.StatusNotFound)
+			return
+		}
+		utils.ErrorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	book.History, err = b.bookingService.GetBookingHistory(ctx, book.ID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "get booking failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -330,7 +417,7 @@ func (b *Base) GetAllBookingsHandler(w http.ResponseWriter, r *http.Request) {
 
 	userID, ok := r.Context().Value(entities.UseridKeyValue).(string)
 	if !ok {
-		utils.LogError("an error occurred", entities.ErrorLog)
+		slog.ErrorContext(r.Context(), "an error occurred")
 		utils.ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
 		return
 
@@ -339,7 +426,7 @@ func (b *Base) GetAllBookingsHandler(w http.ResponseWriter, r *http.Request) {
 
 	bookings, err := b.bookingService.GetUserBookings(ctx, userid)
 	if err != nil {
-		utils.LogError(err.Error(), entities.ErrorLog)
+		slog.ErrorContext(r.Context(), "get all bookings failed", "error", err)
 		utils.ErrorJSON(w, err, http.StatusInternalServerError)
 		return
 	}
@@ -367,7 +454,7 @@ func (b *Base) GetAllAdminBookingsHandler(w http.ResponseWriter, r *http.Request
 
 	userID, ok := r.Context().Value(entities.UseridKeyValue).(string)
 	if !ok {
-		utils.LogError("an error occurred", entities.ErrorLog, http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "an error occurred", "status", http.StatusInternalServerError)
 		utils.ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
 		return
 
@@ -376,7 +463,7 @@ func (b *Base) GetAllAdminBookingsHandler(w http.ResponseWriter, r *http.Request
 
 	bookings, err := b.bookingService.GetVendoerBookings(ctx, userid)
 	if err != nil {
-		utils.LogError(err.Error(), entities.ErrorLog)
+		slog.ErrorContext(r.Context(), "get all admin bookings failed", "error", err)
 		utils.ErrorJSON(w, err, http.StatusInternalServerError)
 		return
 	}
@@ -396,6 +483,7 @@ func (b *Base) GetAllAdminBookingsHandler(w http.ResponseWriter, r *http.Request
 // @Success 200 {object} entities.JSONResponse "Success"
 // @Failure 401 {object} entities.JSONResponse "Unauthorized"
 // @Failure 404 {object} entities.JSONResponse "Bookings not found"
+// @Failure 409 {object} entities.JSONResponse "Nights cannot change"
 // @Failure 500 {object} entities.JSONResponse "Internal server error"
 // @Router /api/user/book/{booking_id} [put]
 func (b *Base) UpdateBooking(w http.ResponseWriter, r *http.Request) {
@@ -405,7 +493,7 @@ func (b *Base) UpdateBooking(w http.ResponseWriter, r *http.Request) {
 
 	bookingID, err := strconv.Atoi(chi.URLParam(r, "booking_id"))
 	if err != nil {
-		utils.LogError(err.Error(), entities.ErrorLog)
+		slog.ErrorContext(r.Context(), "update booking failed", "error", err)
 		utils.ErrorJSON(w, err, http.StatusBadRequest)
 		return
 	}
@@ -414,21 +502,29 @@ func (b *Base) UpdateBooking(w http.ResponseWriter, r *http.Request) {
 
 	err = utils.SerializeJSON(w, r, payload)
 	if err != nil {
-		utils.LogError(err.Error(), entities.ErrorLog)
+		slog.ErrorContext(r.Context(), "update booking failed", "error", err)
 		utils.ErrorJSON(w, err, http.StatusBadRequest)
 		return
 	}
 
-	if *payload.Days < 0 {
-		utils.LogError("days payload cannot be empty", entities.ErrorLog, http.StatusBadRequest)
+	if payload.Days == nil || *payload.Days < 1 {
+		slog.WarnContext(r.Context(), "days payload cannot be empty", "status", http.StatusBadRequest)
 		utils.ErrorJSON(w, errors.New("days in payload cannot be empty"), http.StatusBadRequest)
 		return
 
 	}
 
+	// Status only moves through payment verification and the vendor's
+	// check-in and check-out endpoints.
+	if payload.Status != nil {
+		slog.WarnContext(r.Context(), "status cannot be updated directly", "status", http.StatusBadRequest)
+		utils.ErrorJSON(w, errors.New("booking status cannot be updated directly"), http.StatusBadRequest)
+		return
+	}
+
 	userID, ok := r.Context().Value(entities.UseridKeyValue).(string)
 	if !ok {
-		utils.LogError("BOOKINGUPDATE: failed go get user_id from context %s", entities.ErrorLog, http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "could not get user_id from context", "status", http.StatusInternalServerError)
 		utils.ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
 		return
 
@@ -438,9 +534,18 @@ func (b *Base) UpdateBooking(w http.ResponseWriter, r *http.Request) {
 	payload.UserID = &userid
 
 	err = b.bookingService.UpdateABooking(ctx, payload, bookingID)
-	if err != nil {
-		utils.LogError("BOOKINGUPDATE %s %s", entities.ErrorLog, err.Error(), http.StatusBadRequest)
-		utils.ErrorJSON(w, err, http.StatusBadRequest)
+	switch {
+	case errors.Is(err, entities.ErrNoRecord):
+		slog.WarnContext(r.Context(), "update booking failed", "booking_id", bookingID, "error", err, "status", http.StatusNotFound)
+		utils.ErrorJSON(w, errors.New("booking not found"), http.StatusNotFound)
+		return
+	case errors.Is(err, entities.ErrRoomBlocked), errors.Is(err, entities.ErrBookingLocked):
+		slog.WarnContext(r.Context(), "update booking failed", "booking_id", bookingID, "error", err, "status", http.StatusConflict)
+		utils.ErrorJSON(w, err, http.StatusConflict)
+		return
+	case err != nil:
+		slog.ErrorContext(r.Context(), "update booking failed", "booking_id", bookingID, "error", err, "status", http.StatusInternalServerError)
+		utils.ErrorJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -469,21 +574,21 @@ func (b *Base) DeleteBooking(w http.ResponseWriter, r *http.Request) {
 
 	bookingID, err := strconv.Atoi(chi.URLParam(r, "booking_id"))
 	if err != nil {
-		utils.LogError(err.Error(), entities.ErrorLog, http.StatusBadRequest)
+		slog.WarnContext(r.Context(), "delete booking failed", "error", err, "status", http.StatusBadRequest)
 		utils.ErrorJSON(w, err, http.StatusBadRequest)
 		return
 	}
 
 	roomID, err := strconv.Atoi(chi.URLParam(r, "room_id"))
 	if err != nil {
-		utils.LogError("BOOKINGDELETE: %s %s", entities.ErrorLog, err.Error(), http.StatusBadRequest)
+		slog.WarnContext(r.Context(), "delete booking failed", "error", err, "status", http.StatusBadRequest)
 		utils.ErrorJSON(w, err, http.StatusBadRequest)
 		return
 	}
 
 	userID, ok := r.Context().Value(entities.UseridKeyValue).(string)
 	if !ok {
-		utils.LogError("cannot get user_id from context %s", entities.ErrorLog, http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "could not get user_id from context", "status", http.StatusInternalServerError)
 		utils.ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
 		return
 	}
@@ -492,10 +597,178 @@ func (b *Base) DeleteBooking(w http.ResponseWriter, r *http.Request) {
 
 	err = b.bookingService.DeleteABooking(ctx, bookingID, user_id, roomID)
 	if err != nil {
-		utils.LogError("BOOKINGDELETE: %s %s", entities.ErrorLog, err.Error(), http.StatusBadRequest)
+		slog.WarnContext(r.Context(), "delete booking failed", "error", err, "status", http.StatusBadRequest)
 		utils.ErrorJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 
 	_ = utils.DeserializeJSON(w, http.StatusOK, map[string]any{"msg": "success"})
 }
+
+// Check in godoc
+// @Summary vendor checks a guest in
+// @Description Moves a confirmed booking on one of the vendor's rooms to checked in
+// @ID check-in-booking
+// @Tags bookings
+// @Produce json
+// @Param booking_id path string true "Booking ID"
+// @Param payload body object false "{"reason":"guest asked to leave early"}"
+// @Success 200 {object} entities.Booking "Success"
+// @Failure 400 {object} entities.JSONResponse "Bad request"
+// @Failure 401 {object} entities.JSONResponse "Unauthorized"
+// @Failure 403 {object} entities.JSONResponse "Not allowed to make this change"
+// @Failure 404 {object} entities.JSONResponse "Booking not found"
+// @Failure 409 {object} entities.JSONResponse "Invalid status transition"
+// @Router /api/admin/book/{booking_id}/check-in [put]
+func (b *Base) CheckInHandler(w http.ResponseWriter, r *http.Request) {
+	b.changeBookingStatus(w, r, entities.BookingStatusCheckedIn, entities.ActorVendor)
+}
+
+// Check out godoc
+// @Summary vendor checks a guest out
+// @Description Moves a checked in booking on one of the vendor's rooms to checked out and frees the room
+// @ID check-out-booking
+// @Tags bookings
+// @Produce json
+// @Param booking_id path string true "Booking ID"
+// @Param payload body object false "{"reason":"guest asked to leave early"}"
+// @Success 200 {object} entities.Booking "Success"
+// @Failure 400 {object} entities.JSONResponse "Bad request"
+// @Failure 401 {object} entities.JSONResponse "Unauthorized"
+// @Failure 403 {object} entities.JSONResponse "Not allowed to make this change"
+// @Failure 404 {object} entities.JSONResponse "Booking not found"
+// @Failure 409 {object} entities.JSONResponse "Invalid status transition"
+// @Router /api/admin/book/{booking_id}/check-out [put]
+func (b *Base) CheckOutHandler(w http.ResponseWriter, r *http.Request) {
+	b.changeBookingStatus(w, r, entities.BookingStatusCheckedOut, entities.ActorVendor)
+}
+
+// Cancel booking godoc
+// @Summary vendor cancels a booking
+// @Description Cancels a pending or confirmed booking on one of the vendor's rooms and frees the room
+// @ID cancel-booking
+// @Tags bookings
+// @Produce json
+// @Param booking_id path string true "Booking ID"
+// @Param payload body object false "{"reason":"guest asked to leave early"}"
+// @Success 200 {object} entities.Booking "Success"
+// @Failure 400 {object} entities.JSONResponse "Bad request"
+// @Failure 401 {object} entities.JSONResponse "Unauthorized"
+// @Failure 403 {object} entities.JSONResponse "Not allowed to make this change"
+// @Failure 404 {object} entities.JSONResponse "Booking not found"
+// @Failure 409 {object} entities.JSONResponse "Invalid status transition"
+// @Router /api/admin/book/{booking_id}/cancel [put]
+func (b *Base) CancelBookingHandler(w http.ResponseWriter, r *http.Request) {
+	b.changeBookingStatus(w, r, entities.BookingStatusCancelled, entities.ActorVendor)
+}
+
+// No-show godoc
+// @Summary vendor marks a guest as a no-show
+// @Description Marks a confirmed booking on one of the vendor's rooms as a no-show and frees the room
+// @ID no-show-booking
+// @Tags bookings
+// @Produce json
+// @Param booking_id path string true "Booking ID"
+// @Param payload body object false "{"reason":"guest asked to leave early"}"
+// @Success 200 {object} entities.Booking "Success"
+// @Failure 400 {object} entities.JSONResponse "Bad request"
+// @Failure 401 {object} entities.JSONResponse "Unauthorized"
+// @Failure 403 {object} entities.JSONResponse "Not allowed to make this change"
+// @Failure 404 {object} entities.JSONResponse "Booking not found"
+// @Failure 409 {object} entities.JSONResponse "Invalid status transition"
+// @Router /api/admin/book/{booking_id}/no-show [put]
+func (b *Base) NoShowHandler(w http.ResponseWriter, r *http.Request) {
+	b.changeBookingStatus(w, r, entities.BookingStatusNoShow, entities.ActorVendor)
+}
+
+// Guest cancel booking godoc
+// @Summary user cancels a booking
+// @Description Cancels one of the logged in user's bookings that has not been paid for yet
+// @ID guest-cancel-booking
+// @Tags bookings
+// @Accept json
+// @Produce json
+// @Param booking_id path string true "Booking ID"
+// @Param payload body object false "{"reason":"change of plans"}"
+// @Success 200 {object} entities.Booking "Success"
+// @Failure 400 {object} entities.JSONResponse "Bad request"
+// @Failure 401 {object} entities.JSONResponse "Unauthorized"
+// @Failure 403 {object} entities.JSONResponse "Booking already paid"
+// @Failure 404 {object} entities.JSONResponse "Booking not found"
+// @Failure 409 {object} entities.JSONResponse "Invalid status transition"
+// @Router /api/user/book/{booking_id}/cancel [put]
+func (b *Base) GuestCancelBookingHandler(w http.ResponseWriter, r *http.Request) {
+	b.changeBookingStatus(w, r, entities.BookingStatusCancelled, entities.ActorGuest)
+}
+
+// changeBookingStatus moves the booking in the URL to status to on behalf of
+// the logged in guest or vendor. The body may carry a reason for the change.
+func (b *Base) changeBookingStatus(w http.ResponseWriter, r *http.Request, to int, role string) {
+	w.Header().Set("Content-Type", b.contentType)
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	bookingID, err := strconv.Atoi(chi.URLParam(r, "booking_id"))
+	if err != nil {
+		slog.WarnContext(r.Context(), "change booking status failed", "error", err, "status", http.StatusBadRequest)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	var input struct {
+		Reason string `json:"reason"`
+	}
+
+	if r.ContentLength != 0 {
+		err = utils.SerializeJSON(w, r, &input)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "change booking status failed", "error", err)
+			utils.ErrorJSON(w, err, http.StatusBadRequest)
+			return
+		}
+	}
+
+	userID, ok := r.Context().Value(entities.UseridKeyValue).(string)
+	if !ok {
+		slog.ErrorContext(r.Context(), "could not get user_id from context")
+		utils.ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
+		return
+	}
+	actorID, _ := strconv.Atoi(userID)
+
+	var booking *entities.Booking
+	if role == entities.ActorGuest {
+		booking, err = b.bookingService.GetGuestBooking(ctx, bookingID, actorID)
+	} else {
+		booking, err = b.bookingService.GetVendorBooking(ctx, bookingID, actorID)
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "change booking status failed", "error", err)
+		if errors.Is(err, entities.ErrNoRecord) {
+			utils.ErrorJSON(w, errors.New("booking not found"), http.StatusNotFound)
+			return
+		}
+		utils.ErrorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	actor := entities.BookingActor{Role: role, ID: actorID}
+
+	err = b.bookingService.ChangeBookingStatus(ctx, booking, to, actor, input.Reason)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "change booking status failed", "error", err)
+		switch {
+		case errors.Is(err, entities.ErrInvalidTransition):
+			utils.ErrorJSON(w, err, http.StatusConflict)
+		case errors.Is(err, entities.ErrTransitionForbidden):
+			utils.ErrorJSON(w, err, http.StatusForbidden)
+		default:
+			utils.ErrorJSON(w, err, http.StatusBadRequest)
+		}
+		return
+	}
+
+	b.publishBookingEvent(ctx, booking, "", input.Reason)
+
+	_ = utils.DeserializeJSON(w, http.StatusOK, map[string]any{"msg": "booking " + entities.BookingStatusNames[to], "data": booking})
+}
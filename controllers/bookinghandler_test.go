@@ -3,6 +3,7 @@ package controllers
 import (
 	"bytes"
 	"context"
+	"database/sql"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -10,12 +11,17 @@ import (
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
 	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/events"
+	"github.com/bicosteve/booking-system/pkg/producer"
 	"github.com/bicosteve/booking-system/repo"
 	"github.com/bicosteve/booking-system/service"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-redis/redismock/v9"
+	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
+	"github.com/stripe/stripe-go/v72"
 )
 
 func setupBookingBase(t *testing.T) (*Base, sqlmock.Sqlmock) {
@@ -30,6 +36,7 @@ func setupBookingBase(t *testing.T) (*Base, sqlmock.Sqlmock) {
 	base := &Base{
 		bookingService: service.NewBookingService(repository),
 		paymentService: service.NewPaymentService(repository),
+		roomService:    service.NewRoomService(repository),
 		contentType:    "application/json",
 		DB:             db,
 		KafkaStatus:    0,
@@ -48,28 +55,59 @@ func withBookingUser(r *http.Request, id string) *http.Request {
 	return r.WithContext(context.WithValue(r.Context(), entities.UseridKeyValue, id))
 }
 
+const bookingByIDQuery = "SELECT b.booking_id, b.days, b.user_id, b.room_id, b.currency, b.status, r.vender_id,\n\t\t\t\tb.checked_in_at, b.checked_out_at, b.created_at, b.updated_at\n\t\t\tFROM booking b JOIN room r ON b.room_id = r.room_id\n\t\t\tWHERE b.booking_id = ?"
+
+const bookingHistoryQuery = "SELECT history_id, booking_id, from_status, to_status, actor, actor_id, reason, created_at\n\t\t\tFROM booking_status_history\n\t\t\tWHERE booking_id = ?\n\t\t\tORDER BY created_at, history_id"
+
+const historyInsertQuery = "INSERT INTO booking_status_history (booking_id, from_status, to_status, actor, actor_id, reason)\n\t\t\tVALUES (?, ?, ?, ?, ?, ?)"
+
+func bookingByIDRow(status int, at time.Time) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"booking_id", "days", "user_id", "room_id", "currency", "status", "vender_id", "checked_in_at", "checked_out_at", "created_at", "updated_at"}).
+		AddRow(100, 2, 5, 10, "KES", status, 7, nil, nil, at, at)
+}
+
 func TestGetBookingHandler(t *testing.T) {
 	mockTime := time.Now()
-	getQuery := "SELECT * FROM booking\n\t\t\tWHERE status = 0 \n\t\t\tAND booking_id = ? AND user_id = ?\n\t\t\tORDER BY created_at DESC LIMIT 1"
 
-	t.Run("successful get", func(t *testing.T) {
+	t.Run("successful get with history", func(t *testing.T) {
 		base, mock := setupBookingBase(t)
-		mock.ExpectPrepare(getQuery).
+		mock.ExpectPrepare(bookingByIDQuery).
 			ExpectQuery().
-			WithArgs(1, 5).
-			WillReturnRows(sqlmock.NewRows([]string{"id", "days", "user_id", "room_id", "created_at", "updated_at"}).
-				AddRow(1, 2, 5, 1, mockTime, mockTime))
+			WithArgs(100).
+			WillReturnRows(bookingByIDRow(entities.BookingStatusConfirmed, mockTime))
+		mock.ExpectPrepare(bookingHistoryQuery).
+			ExpectQuery().
+			WithArgs(100).
+			WillReturnRows(sqlmock.NewRows([]string{"history_id", "booking_id", "from_status", "to_status", "actor", "actor_id", "reason", "created_at"}).
+				AddRow(1, 100, entities.BookingStatusPending, entities.BookingStatusConfirmed, entities.ActorSystem, nil, "payment pi_1 succeeded", mockTime))
 
-		req := httptest.NewRequest(http.MethodGet, "/book/1", nil)
-		req = withURLParam(req, "room_id", "1")
+		req := httptest.NewRequest(http.MethodGet, "/book/100", nil)
+		req = withURLParam(req, "room_id", "100")
 		req = withBookingUser(req, "5")
 		w := httptest.NewRecorder()
 
 		base.GetBookingHandler(w, req)
 		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "payment pi_1 succeeded")
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 
+	t.Run("someone else's booking", func(t *testing.T) {
+		base, mock := setupBookingBase(t)
+		mock.ExpectPrepare(bookingByIDQuery).
+			ExpectQuery().
+			WithArgs(100).
+			WillReturnRows(bookingByIDRow(entities.BookingStatusConfirmed, mockTime))
+
+		req := httptest.NewRequest(http.MethodGet, "/book/100", nil)
+		req = withURLParam(req, "room_id", "100")
+		req = withBookingUser(req, "6")
+		w := httptest.NewRecorder()
+
+		base.GetBookingHandler(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
 	t.Run("invalid room id param", func(t *testing.T) {
 		base, _ := setupBookingBase(t)
 		req := httptest.NewRequest(http.MethodGet, "/book/abc", nil)
@@ -84,15 +122,15 @@ func TestGetBookingHandler(t *testing.T) {
 
 func TestGetAllBookingsHandler(t *testing.T) {
 	mockTime := time.Now()
-	q := "SELECT * FROM booking WHERE user_id = ?"
+	q := "SELECT booking_id, days, user_id, room_id, currency, status, checked_in_at, checked_out_at, created_at, updated_at\n\t\t\tFROM booking WHERE user_id = ?"
 
 	t.Run("success", func(t *testing.T) {
 		base, mock := setupBookingBase(t)
 		mock.ExpectPrepare(q).
 			ExpectQuery().
 			WithArgs(5).
-			WillReturnRows(sqlmock.NewRows([]string{"id", "days", "user_id", "room_id", "created_at", "updated_at"}).
-				AddRow(1, 2, 5, 10, mockTime, mockTime))
+			WillReturnRows(sqlmock.NewRows([]string{"id", "days", "user_id", "room_id", "currency", "status", "checked_in_at", "checked_out_at", "created_at", "updated_at"}).
+				AddRow(1, 2, 5, 10, "KES", 0, nil, nil, mockTime, mockTime))
 
 		req := httptest.NewRequest(http.MethodGet, "/book/all", nil)
 		req = withBookingUser(req, "5")
@@ -115,15 +153,15 @@ func TestGetAllBookingsHandler(t *testing.T) {
 
 func TestGetAllAdminBookingsHandler(t *testing.T) {
 	mockTime := time.Now()
-	q := "SELECT b.booking_id, b.days, b.user_id, b.room_id, r.vender_id,\n\t\t\t\tb.created_at, b.updated_at\n\t\t\tFROM booking b JOIN room r ON b.room_id = r.room_id\n\t\t\tWHERE r.vender_id = ?"
+	q := "SELECT b.booking_id, b.days, b.user_id, b.room_id, b.currency, b.status, r.vender_id,\n\t\t\t\tb.checked_in_at, b.checked_out_at, b.created_at, b.updated_at\n\t\t\tFROM booking b JOIN room r ON b.room_id = r.room_id\n\t\t\tWHERE r.vender_id = ?"
 
 	t.Run("success", func(t *testing.T) {
 		base, mock := setupBookingBase(t)
 		mock.ExpectPrepare(q).
 			ExpectQuery().
 			WithArgs(7).
-			WillReturnRows(sqlmock.NewRows([]string{"booking_id", "days", "user_id", "room_id", "vender_id", "created_at", "updated_at"}).
-				AddRow(1, 2, 5, 10, 7, mockTime, mockTime))
+			WillReturnRows(sqlmock.NewRows([]string{"booking_id", "days", "user_id", "room_id", "currency", "status", "vender_id", "checked_in_at", "checked_out_at", "created_at", "updated_at"}).
+				AddRow(1, 2, 5, 10, "KES", 0, 7, nil, nil, mockTime, mockTime))
 
 		req := httptest.NewRequest(http.MethodGet, "/admin/book/all", nil)
 		req = withBookingUser(req, "7")
@@ -136,27 +174,53 @@ func TestGetAllAdminBookingsHandler(t *testing.T) {
 }
 
 func TestUpdateBookingHandler(t *testing.T) {
-	updateQuery := "UPDATE booking SET days = ?, status = ?, updated_at = NOW()\n\t\t  WHERE booking_id = ? AND user_id = ?"
+	selectQuery := "SELECT room_id, days, status, COALESCE(check_in, DATE(created_at))\n\t\t\tFROM booking WHERE booking_id = ? AND user_id = ? FOR UPDATE"
+	lockQuery := "SELECT room_id FROM room WHERE room_id = ? FOR UPDATE"
+	blockQuery := "SELECT COUNT(*) FROM room_block\n\t\tWHERE room_id = ? AND start_date < ? AND end_date > ?"
+	bookedQuery := "SELECT COUNT(*) FROM booking\n\t\tWHERE room_id = ? AND booking_id <> ? AND status IN (?, ?, ?)\n\t\t\tAND COALESCE(check_in, DATE(created_at)) < ?\n\t\t\tAND DATE_ADD(COALESCE(check_in, DATE(created_at)), INTERVAL days DAY) > ?"
+	updateQuery := "UPDATE booking SET days = ?, updated_at = NOW() WHERE booking_id = ?"
+	checkIn := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+
+	newReq := func(days int) *http.Request {
+		payload, _ := json.Marshal(entities.BookingPayload{Days: &days})
+		req := httptest.NewRequest(http.MethodPut, "/book/100", bytes.NewBuffer(payload))
+		req = withURLParam(req, "booking_id", "100")
+		return withBookingUser(req, "5")
+	}
 
 	t.Run("successful update", func(t *testing.T) {
 		base, mock := setupBookingBase(t)
-		mock.ExpectPrepare(updateQuery).
-			ExpectExec().
-			WithArgs(3, sqlmock.AnyArg(), 100, 5).
-			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectBegin()
+		mock.ExpectQuery(selectQuery).WithArgs(100, 5).
+			WillReturnRows(sqlmock.NewRows([]string{"room_id", "days", "status", "check_in"}).AddRow(10, 4, entities.BookingStatusPending, checkIn))
+		mock.ExpectQuery(lockQuery).WithArgs(10).WillReturnRows(sqlmock.NewRows([]string{"room_id"}).AddRow(10))
+		mock.ExpectQuery(blockQuery).WithArgs(10, "2026-11-04", "2026-11-01").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery(bookedQuery).WithArgs(10, 100, entities.BookingStatusPending, entities.BookingStatusConfirmed,
+			entities.BookingStatusCheckedIn, "2026-11-04", "2026-11-01").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectExec(updateQuery).WithArgs(3, 100).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
 
-		days := 3
-		payload, _ := json.Marshal(entities.BookingPayload{Days: &days})
-		req := httptest.NewRequest(http.MethodPut, "/book/100", bytes.NewBuffer(payload))
-		req = withURLParam(req, "booking_id", "100")
-		req = withBookingUser(req, "5")
 		w := httptest.NewRecorder()
-
-		base.UpdateBooking(w, req)
+		base.UpdateBooking(w, newReq(3))
 		assert.Equal(t, http.StatusOK, w.Code)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 
+	t.Run("paid booking cannot change", func(t *testing.T) {
+		base, mock := setupBookingBase(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(selectQuery).WithArgs(100, 5).
+			WillReturnRows(sqlmock.NewRows([]string{"room_id", "days", "status", "check_in"}).AddRow(10, 4, entities.BookingStatusConfirmed, checkIn))
+		mock.ExpectRollback()
+
+		w := httptest.NewRecorder()
+		base.UpdateBooking(w, newReq(3))
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
 	t.Run("invalid booking id", func(t *testing.T) {
 		base, _ := setupBookingBase(t)
 		days := 3
@@ -169,11 +233,26 @@ func TestUpdateBookingHandler(t *testing.T) {
 		base.UpdateBooking(w, req)
 		assert.Equal(t, http.StatusBadRequest, w.Code)
 	})
+	t.Run("status cannot be set", func(t *testing.T) {
+		base, _ := setupBookingBase(t)
+		days, status := 3, entities.BookingStatusCheckedOut
+		payload, _ := json.Marshal(entities.BookingPayload{Days: &days, Status: &status})
+		req := httptest.NewRequest(http.MethodPut, "/book/100", bytes.NewBuffer(payload))
+		req = withURLParam(req, "booking_id", "100")
+		req = withBookingUser(req, "5")
+		w := httptest.NewRecorder()
+
+		base.UpdateBooking(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "booking status cannot be updated directly")
+	})
 }
 
 func TestDeleteBookingHandler(t *testing.T) {
 	roomUpdate := "UPDATE room SET status = 'VACANT'\n\t\t\t\t\tWHERE room_id = ? and vender_id = ?"
 	bookingDelete := "DELETE FROM booking WHERE booking_id = ?"
+	historyDelete := "DELETE FROM booking_status_history WHERE booking_id = ?"
+	reviewDelete := "DELETE FROM review WHERE booking_id = ?"
 
 	newReq := func(bookingID, roomID string) *http.Request {
 		req := httptest.NewRequest(http.MethodDelete, "/admin/book/"+bookingID+"/"+roomID, nil)
@@ -186,12 +265,20 @@ func TestDeleteBookingHandler(t *testing.T) {
 
 	t.Run("successful delete", func(t *testing.T) {
 		base, mock := setupBookingBase(t)
+		mock.ExpectPrepare("SELECT b.booking_id, b.days, b.user_id, b.room_id, b.currency, b.status, r.vender_id, " +
+			"b.checked_in_at, b.checked_out_at, b.created_at, b.updated_at FROM booking b JOIN room r ON b.room_id = r.room_id WHERE b.booking_id = ?").
+			ExpectQuery().WithArgs(100).
+			WillReturnRows(sqlmock.NewRows([]string{"booking_id", "days", "user_id", "room_id", "currency", "status", "vender_id", "checked_in_at", "checked_out_at", "created_at", "updated_at"}).
+				AddRow(100, 2, 5, 10, "KES", entities.BookingStatusConfirmed, 7, nil, nil, time.Now(), time.Now()))
 		mock.ExpectBegin()
 		mock.ExpectPrepare(roomUpdate)
 		mock.ExpectPrepare(bookingDelete)
 		mock.ExpectExec(roomUpdate).WithArgs(10, 7).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(historyDelete).WithArgs(100).WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec(reviewDelete).WithArgs(100).WillReturnResult(sqlmock.NewResult(0, 0))
 		mock.ExpectExec(bookingDelete).WithArgs(100).WillReturnResult(sqlmock.NewResult(0, 1))
 		mock.ExpectCommit()
+		expectAuditInsert(mock, entities.AuditBookingDelete)
 
 		req := newReq("100", "10")
 		w := httptest.NewRecorder()
@@ -224,6 +311,128 @@ func TestCreateBookingHandler_ValidationError(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 }
 
+func TestCreateBookingHandler_StatusRejected(t *testing.T) {
+	// A guest cannot book straight into a paid or finished stay.
+	base, mock := setupBookingBase(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/book", bytes.NewBufferString(`{"room_id":10,"days":2,"status":2}`))
+	req = withBookingUser(req, "5")
+	w := httptest.NewRecorder()
+
+	base.CreateBookingHandler(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "status cannot be set")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateBookingHandler_RoomNotFound(t *testing.T) {
+	// The charge is priced from the room, so an unknown room fails before Stripe.
+	base, mock := setupBookingBase(t)
+	findQuery := "SELECT room_id, cost, currency, status, vender_id, created_at, updated_at\n\t\t\tFROM room WHERE room_id = ?"
+	mock.ExpectPrepare(findQuery).ExpectQuery().WithArgs(99).WillReturnError(sql.ErrNoRows)
+
+	days, roomID := 2, 99
+	payload, _ := json.Marshal(entities.BookingPayload{Days: &days, RoomID: &roomID})
+	req := httptest.NewRequest(http.MethodPost, "/book", bytes.NewBuffer(payload))
+	req = withBookingUser(req, "5")
+	w := httptest.NewRecorder()
+
+	base.CreateBookingHandler(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateBookingHandler_RoomBlocked(t *testing.T) {
+	// Blocked dates are refused before a Stripe payment is created.
+	base, mock := setupBookingBase(t)
+	findQuery := "SELECT room_id, cost, currency, status, vender_id, created_at, updated_at\n\t\t\tFROM room WHERE room_id = ?"
+	mock.ExpectPrepare(findQuery).ExpectQuery().WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"room_id", "cost", "currency", "status", "vender_id", "created_at", "updated_at"}).
+			AddRow("10", 500000, "KES", "VACANT", "7", time.Now(), time.Now()))
+	checkIn := time.Now().AddDate(0, 0, 7)
+	mock.ExpectPrepare("SELECT COUNT(*) FROM room_block\n\t\tWHERE room_id = ? AND start_date < ? AND end_date > ?").ExpectQuery().
+		WithArgs(10, checkIn.AddDate(0, 0, 2).Format(entities.DateLayout), checkIn.Format(entities.DateLayout)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	days, roomID, day := 2, 10, checkIn.Format(entities.DateLayout)
+	payload, _ := json.Marshal(entities.BookingPayload{CheckIn: &day, Days: &days, RoomID: &roomID})
+	req := httptest.NewRequest(http.MethodPost, "/book", bytes.NewBuffer(payload))
+	req = withBookingUser(req, "5")
+	w := httptest.NewRecorder()
+
+	base.CreateBookingHandler(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateBookingHandler_PublishesCreatedBooking(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"pi_1","object":"payment_intent","client_secret":"pi_1_secret"}`))
+	}))
+	defer srv.Close()
+	stripe.SetBackend(stripe.APIBackend, stripe.GetBackendWithConfig(stripe.APIBackend, &stripe.BackendConfig{URL: stripe.String(srv.URL)}))
+	defer stripe.SetBackend(stripe.APIBackend, nil)
+
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	mr := miniredis.RunT(t)
+	repository := *repo.NewDBRepository(db, redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	fake := producer.NewFake()
+	base := &Base{
+		bookingService: service.NewBookingService(repository),
+		paymentService: service.NewPaymentService(repository),
+		roomService:    service.NewRoomService(repository),
+		contentType:    "application/json",
+		KafkaStatus:    1,
+		Topics:         []string{"bookings", "payments"},
+		producer:       fake,
+	}
+
+	checkIn := time.Now().AddDate(0, 0, 7).Format(entities.DateLayout)
+	mock.ExpectPrepare("FROM room WHERE room_id").ExpectQuery().WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"room_id", "cost", "currency", "status", "vender_id", "created_at", "updated_at"}).
+			AddRow("10", 500000, "KES", "VACANT", "7", time.Now(), time.Now()))
+	mock.ExpectPrepare("FROM room_block").ExpectQuery().WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectPrepare("FROM booking").ExpectQuery().WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectBegin()
+	mock.ExpectQuery("FOR UPDATE").WithArgs(10).WillReturnRows(sqlmock.NewRows([]string{"room_id"}).AddRow(10))
+	mock.ExpectQuery("FROM room_block").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("FROM booking").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectPrepare("UPDATE room")
+	mock.ExpectPrepare("INSERT INTO booking")
+	mock.ExpectExec("UPDATE room").WithArgs(10).WillReturnResult(sqlmock.NewResult(0, 1))
+	// The guest has another booking for room 10; the new one is 42.
+	mock.ExpectExec("INSERT INTO booking\\(").WillReturnResult(sqlmock.NewResult(42, 1))
+	mock.ExpectExec("INSERT INTO booking_status_history").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	days, roomID := 2, 10
+	payload, _ := json.Marshal(entities.BookingPayload{CheckIn: &checkIn, Days: &days, RoomID: &roomID})
+	req := httptest.NewRequest(http.MethodPost, "/book", bytes.NewBuffer(payload))
+	req = withBookingUser(req, "5")
+	w := httptest.NewRecorder()
+
+	base.CreateBookingHandler(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	messages := fake.Messages()
+	assert.Len(t, messages, 1)
+	assert.Equal(t, "42", messages[0].Key)
+
+	e, err := events.Decode(messages[0].Value)
+	assert.NoError(t, err)
+	assert.Equal(t, events.BookingCreated, e.Type)
+
+	var created events.BookingEvent
+	assert.NoError(t, e.Decode(&created))
+	assert.Equal(t, 42, created.BookingID)
+	assert.Equal(t, 10, created.RoomID)
+	assert.Equal(t, 5, created.UserID)
+	assert.Equal(t, "pending", created.Status)
+}
+
 func TestVerifyBookingHandler_InvalidParam(t *testing.T) {
 	base, _ := setupBookingBase(t)
 
@@ -235,3 +444,116 @@ func TestVerifyBookingHandler_InvalidParam(t *testing.T) {
 	base.VerifyBookingHandler(w, req)
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 }
+
+func TestCheckInHandler(t *testing.T) {
+	mockTime := time.Now()
+
+	t.Run("successful check in", func(t *testing.T) {
+		base, mock := setupBookingBase(t)
+		mock.ExpectPrepare(bookingByIDQuery).
+			ExpectQuery().
+			WithArgs(100).
+			WillReturnRows(bookingByIDRow(entities.BookingStatusConfirmed, mockTime))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE booking SET status = ?, checked_in_at = NOW(), updated_at = NOW()\n\t\t\tWHERE booking_id = ? AND status = ?").
+			WithArgs(entities.BookingStatusCheckedIn, 100, entities.BookingStatusConfirmed).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(historyInsertQuery).
+			WithArgs(100, entities.BookingStatusConfirmed, entities.BookingStatusCheckedIn, entities.ActorVendor, sql.NullInt64{Int64: 7, Valid: true}, "arrived early").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		req := httptest.NewRequest(http.MethodPut, "/book/100/check-in", bytes.NewBufferString(`{"reason":"arrived early"}`))
+		req = withURLParam(req, "booking_id", "100")
+		req = withBookingUser(req, "7")
+		w := httptest.NewRecorder()
+
+		base.CheckInHandler(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "booking checked in")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("booking of another vendor", func(t *testing.T) {
+		base, mock := setupBookingBase(t)
+		mock.ExpectPrepare(bookingByIDQuery).
+			ExpectQuery().
+			WithArgs(100).
+			WillReturnRows(bookingByIDRow(entities.BookingStatusConfirmed, mockTime))
+
+		req := httptest.NewRequest(http.MethodPut, "/book/100/check-in", nil)
+		req = withURLParam(req, "booking_id", "100")
+		req = withBookingUser(req, "8")
+		w := httptest.NewRecorder()
+
+		base.CheckInHandler(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("booking not confirmed", func(t *testing.T) {
+		base, mock := setupBookingBase(t)
+		mock.ExpectPrepare(bookingByIDQuery).
+			ExpectQuery().
+			WithArgs(100).
+			WillReturnRows(bookingByIDRow(entities.BookingStatusPending, mockTime))
+
+		req := httptest.NewRequest(http.MethodPut, "/book/100/check-in", nil)
+		req = withURLParam(req, "booking_id", "100")
+		req = withBookingUser(req, "7")
+		w := httptest.NewRecorder()
+
+		base.CheckInHandler(w, req)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGuestCancelBookingHandler(t *testing.T) {
+	mockTime := time.Now()
+
+	t.Run("pending booking is cancelled", func(t *testing.T) {
+		base, mock := setupBookingBase(t)
+		mock.ExpectPrepare(bookingByIDQuery).
+			ExpectQuery().
+			WithArgs(100).
+			WillReturnRows(bookingByIDRow(entities.BookingStatusPending, mockTime))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE booking SET status = ?, updated_at = NOW()\n\t\t\tWHERE booking_id = ? AND status = ?").
+			WithArgs(entities.BookingStatusCancelled, 100, entities.BookingStatusPending).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE room SET status = 'VACANT', updated_at = NOW() WHERE room_id = ?").
+			WithArgs(10).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(historyInsertQuery).
+			WithArgs(100, entities.BookingStatusPending, entities.BookingStatusCancelled, entities.ActorGuest, sql.NullInt64{Int64: 5, Valid: true}, "").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		req := httptest.NewRequest(http.MethodPut, "/book/100/cancel", nil)
+		req = withURLParam(req, "booking_id", "100")
+		req = withBookingUser(req, "5")
+		w := httptest.NewRecorder()
+
+		base.GuestCancelBookingHandler(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "booking cancelled")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("paid booking cannot be cancelled by the guest", func(t *testing.T) {
+		base, mock := setupBookingBase(t)
+		mock.ExpectPrepare(bookingByIDQuery).
+			ExpectQuery().
+			WithArgs(100).
+			WillReturnRows(bookingByIDRow(entities.BookingStatusConfirmed, mockTime))
+
+		req := httptest.NewRequest(http.MethodPut, "/book/100/cancel", nil)
+		req = withURLParam(req, "booking_id", "100")
+		req = withBookingUser(req, "5")
+		w := httptest.NewRecorder()
+
+		base.GuestCancelBookingHandler(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
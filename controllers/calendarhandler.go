@@ -0,0 +1,258 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/ical"
+	"github.com/bicosteve/booking-system/pkg/utils"
+	"github.com/bicosteve/booking-system/service"
+	"github.com/go-chi/chi/v5"
+)
+
+// calendarError writes err with the status matching it.
+func calendarError(w http.ResponseWriter, err error, notFound string) {
+	switch {
+	case errors.Is(err, entities.ErrNoRecord):
+		utils.ErrorJSON(w, errors.New(notFound), http.StatusNotFound)
+	case errors.Is(err, entities.ErrInvalidCalendar), errors.Is(err, entities.ErrInvalidBlock):
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+	case errors.Is(err, entities.ErrBlockOverlapsStay), errors.Is(err, entities.ErrBlockImported):
+		utils.ErrorJSON(w, err, http.StatusConflict)
+	default:
+		utils.ErrorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// Room calendar feed godoc
+// @Summary export a room's calendar
+// @Description iCalendar feed of the room's confirmed stays and blocks, for import into other booking sites. The token comes from the feed endpoint on the admin API.
+// @ID room-calendar-feed
+// @Tags calendars
+// @Produce text/calendar
+// @Param room_id path string true "Room ID"
+// @Param token query string true "Feed token"
+// @Success 200 {string} string "iCalendar feed"
+// @Failure 404 {object} entities.JSONResponse "Unknown room or token"
+// @Router /api/user/rooms/{room_id}/calendar.ics [get]
+func (b *Base) RoomCalendarFeedHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	roomID, err := strconv.Atoi(chi.URLParam(r, "room_id"))
+	if err != nil {
+		utils.ErrorJSON(w, errors.New("calendar not found"), http.StatusNotFound)
+		return
+	}
+
+	feed, err := b.calendarService.RoomFeed(ctx, roomID, r.URL.Query().Get("token"))
+	if err != nil {
+		slog.WarnContext(r.Context(), "room calendar feed failed", "room_id", roomID, "error", err)
+		calendarError(w, err, "calendar not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=\"room-%d.ics\"", roomID))
+	w.WriteHeader(http.StatusOK)
+
+	err = ical.Write(w, service.CalendarProdID, fmt.Sprintf("Room %d", roomID), feed, time.Now())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "writing room calendar failed", "room_id", roomID, "error", err)
+	}
+}
+
+// Room calendar feed URL godoc
+// @Summary create or rotate a room's calendar feed URL
+// @Description Issues a new secret token for the room's iCalendar feed and returns the feed path. Any previous feed URL stops working.
+// @ID rotate-room-calendar-feed
+// @Tags calendars
+// @Produce json
+// @Param room_id path string true "Room ID"
+// @Success 200 {object} entities.JSONResponse "Success"
+// @Failure 400 {object} entities.JSONResponse "Bad request"
+// @Failure 401 {object} entities.JSONResponse "Unauthorized"
+// @Failure 404 {object} entities.JSONResponse "Not found"
+// @Router /api/admin/rooms/{room_id}/calendar/feed [post]
+func (b *Base) RotateCalendarFeedHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", b.contentType)
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	roomID, err := strconv.Atoi(chi.URLParam(r, "room_id"))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "rotate calendar feed failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := r.Context().Value(entities.UseridKeyValue).(string)
+	if !ok {
+		slog.ErrorContext(r.Context(), "could not get user_id from context")
+		utils.ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
+		return
+	}
+	vendorID, _ := strconv.Atoi(userID)
+
+	token, err := b.calendarService.RotateFeedToken(ctx, roomID, vendorID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "rotate calendar feed failed", "error", err)
+		calendarError(w, err, "room not found")
+		return
+	}
+
+	path := fmt.Sprintf("%s/user/rooms/%d/calendar.ics?token=%s", b.path, roomID, token)
+	_ = utils.DeserializeJSON(w, http.StatusOK, map[string]any{"msg": "calendar feed created", "data": map[string]string{"token": token, "path": path}})
+}
+
+// Add room calendar godoc
+// @Summary import an external calendar into a room
+// @Description Adds an iCalendar URL (e.g. an Airbnb or Booking.com export) whose events block the room. It is synced right away and then periodically.
+// @ID add-room-calendar
+// @Tags calendars
+// @Accept json
+// @Produce json
+// @Param room_id path string true "Room ID"
+// @Param payload body object true "{"url":"https://www.airbnb.com/calendar/ical/123.ics?s=abc"}"
+// @Success 201 {object} entities.RoomCalendar "Created"
+// @Failure 400 {object} entities.JSONResponse "Bad request"
+// @Failure 401 {object} entities.JSONResponse "Unauthorized"
+// @Failure 404 {object} entities.JSONResponse "Not found"
+// @Router /api/admin/rooms/{room_id}/calendars [post]
+func (b *Base) AddRoomCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", b.contentType)
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	roomID, err := strconv.Atoi(chi.URLParam(r, "room_id"))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "add room calendar failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	var input struct {
+		URL string `json:"url"`
+	}
+
+	err = utils.SerializeJSON(w, r, &input)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "add room calendar failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := r.Context().Value(entities.UseridKeyValue).(string)
+	if !ok {
+		slog.ErrorContext(r.Context(), "could not get user_id from context")
+		utils.ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
+		return
+	}
+	vendorID, _ := strconv.Atoi(userID)
+
+	calendar, err := b.calendarService.AddCalendar(ctx, roomID, vendorID, input.URL)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "add room calendar failed", "error", err)
+		calendarError(w, err, "room not found")
+		return
+	}
+
+	_ = utils.DeserializeJSON(w, http.StatusCreated, map[string]any{"msg": "calendar added", "data": calendar})
+}
+
+// Room calendars godoc
+// @Summary list a room's external calendars
+// @Description Returns the calendars imported into one of the vendor's rooms with their last sync time and error
+// @ID room-calendars
+// @Tags calendars
+// @Produce json
+// @Param room_id path string true "Room ID"
+// @Success 200 {array} entities.RoomCalendar "Success"
+// @Failure 400 {object} entities.JSONResponse "Bad request"
+// @Failure 401 {object} entities.JSONResponse "Unauthorized"
+// @Router /api/admin/rooms/{room_id}/calendars [get]
+func (b *Base) GetRoomCalendarsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", b.contentType)
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	roomID, err := strconv.Atoi(chi.URLParam(r, "room_id"))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "list room calendars failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := r.Context().Value(entities.UseridKeyValue).(string)
+	if !ok {
+		slog.ErrorContext(r.Context(), "could not get user_id from context")
+		utils.ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
+		return
+	}
+	vendorID, _ := strconv.Atoi(userID)
+
+	calendars, err := b.calendarService.GetCalendars(ctx, roomID, vendorID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "list room calendars failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	_ = utils.DeserializeJSON(w, http.StatusOK, map[string]any{"data": calendars})
+}
+
+// Delete room calendar godoc
+// @Summary stop importing an external calendar
+// @Description Removes an external calendar from one of the vendor's rooms and frees the dates it blocked
+// @ID delete-room-calendar
+// @Tags calendars
+// @Produce json
+// @Param room_id path string true "Room ID"
+// @Param calendar_id path string true "Calendar ID"
+// @Success 200 {object} entities.JSONResponse "Success"
+// @Failure 400 {object} entities.JSONResponse "Bad request"
+// @Failure 401 {object} entities.JSONResponse "Unauthorized"
+// @Failure 404 {object} entities.JSONResponse "Not found"
+// @Router /api/admin/rooms/{room_id}/calendars/{calendar_id} [delete]
+func (b *Base) DeleteRoomCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", b.contentType)
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	roomID, err := strconv.Atoi(chi.URLParam(r, "room_id"))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "delete room calendar failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	calendarID, err := strconv.Atoi(chi.URLParam(r, "calendar_id"))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "delete room calendar failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := r.Context().Value(entities.UseridKeyValue).(string)
+	if !ok {
+		slog.ErrorContext(r.Context(), "could not get user_id from context")
+		utils.ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
+		return
+	}
+	vendorID, _ := strconv.Atoi(userID)
+
+	err = b.calendarService.DeleteCalendar(ctx, calendarID, roomID, vendorID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "delete room calendar failed", "error", err)
+		calendarError(w, err, "calendar not found")
+		return
+	}
+
+	_ = utils.DeserializeJSON(w, http.StatusOK, map[string]any{"msg": "calendar removed"})
+}
@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/ical"
+	"github.com/bicosteve/booking-system/repo"
+	"github.com/bicosteve/booking-system/service"
+	"github.com/go-redis/redismock/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupCalendarBase(t *testing.T) (*Base, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock db: %v", err)
+	}
+	rdb, _ := redismock.NewClientMock()
+	repository := *repo.NewDBRepository(db, rdb)
+
+	base := &Base{
+		calendarService: service.NewCalendarService(repository, entities.CalendarConfig{}),
+		contentType:     "application/json",
+		path:            "/api",
+		DB:              db,
+	}
+	return base, mock
+}
+
+func TestRoomCalendarFeedHandler(t *testing.T) {
+	t.Run("valid token", func(t *testing.T) {
+		base, mock := setupCalendarBase(t)
+		start := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+		mock.ExpectPrepare("SELECT ics_token FROM room").ExpectQuery().WithArgs(10).
+			WillReturnRows(sqlmock.NewRows([]string{"ics_token"}).AddRow("secret"))
+		mock.ExpectPrepare("UNION ALL").ExpectQuery().
+			WillReturnRows(sqlmock.NewRows([]string{"kind", "id", "start_date", "end_date", "status", "reason", "note", "calendar_id"}).
+				AddRow("booking", 100, start, start.AddDate(0, 0, 2), entities.BookingStatusConfirmed, "", "", nil).
+				AddRow("block", 3, start.AddDate(0, 0, 5), start.AddDate(0, 0, 7), nil, entities.BlockReasonMaintenance, "Boiler", nil))
+
+		req := httptest.NewRequest(http.MethodGet, "/user/rooms/10/calendar.ics?token=secret", nil)
+		req = withURLParam(req, "room_id", "10")
+		w := httptest.NewRecorder()
+
+		base.RoomCalendarFeedHandler(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/calendar; charset=utf-8", w.Header().Get("Content-Type"))
+
+		events, err := ical.Parse(w.Body)
+		require.NoError(t, err)
+		require.Len(t, events, 2)
+		assert.Equal(t, start, events[0].Start)
+		assert.Equal(t, "Not available", events[1].Summary)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("wrong token", func(t *testing.T) {
+		base, mock := setupCalendarBase(t)
+		mock.ExpectPrepare("SELECT ics_token FROM room").ExpectQuery().WithArgs(10).
+			WillReturnRows(sqlmock.NewRows([]string{"ics_token"}).AddRow("secret"))
+
+		req := httptest.NewRequest(http.MethodGet, "/user/rooms/10/calendar.ics?token=guess", nil)
+		req = withURLParam(req, "room_id", "10")
+		w := httptest.NewRecorder()
+
+		base.RoomCalendarFeedHandler(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestRotateCalendarFeedHandler(t *testing.T) {
+	base, mock := setupCalendarBase(t)
+	mock.ExpectPrepare("UPDATE room SET ics_token").ExpectExec().WithArgs(sqlmock.AnyArg(), 10, 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/rooms/10/calendar/feed", nil)
+	req = withUserID(withURLParam(req, "room_id", "10"), "7")
+	w := httptest.NewRecorder()
+
+	base.RotateCalendarFeedHandler(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Data map[string]string `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.True(t, strings.HasPrefix(resp.Data["path"], "/api/user/rooms/10/calendar.ics?token="+resp.Data["token"]))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAddRoomCalendarHandler_NotVendorsRoom(t *testing.T) {
+	base, mock := setupCalendarBase(t)
+	mock.ExpectPrepare("INSERT INTO room_calendar").ExpectExec().
+		WithArgs("https://www.airbnb.com/calendar/ical/1.ics", 10, 8).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	body := bytes.NewBufferString(`{"url":"https://www.airbnb.com/calendar/ical/1.ics"}`)
+	req := httptest.NewRequest(http.MethodPost, "/admin/rooms/10/calendars", body)
+	req = withUserID(withURLParam(req, "room_id", "10"), "8")
+	w := httptest.NewRecorder()
+
+	base.AddRoomCalendarHandler(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -2,21 +2,27 @@ package controllers
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
-	"os/signal"
 	"sync"
-	"syscall"
 	"time"
 
 	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/events"
+	"github.com/bicosteve/booking-system/pkg/metrics"
+	"github.com/bicosteve/booking-system/pkg/tracing"
 	"github.com/bicosteve/booking-system/pkg/utils"
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func (b *Base) Consumer(wg *sync.WaitGroup, topic string) {
+// Consumer reads topic until ctx is cancelled. The message being handled when
+// the signal arrives is finished first; the consumer itself is closed by Close.
+func (b *Base) Consumer(ctx context.Context, wg *sync.WaitGroup, topic string) {
 	defer wg.Done()
 	if b.KafkaStatus != 1 {
 		return
@@ -26,129 +32,161 @@ func (b *Base) Consumer(wg *sync.WaitGroup, topic string) {
 
 	err := consumer.SubscribeTopics([]string{topic}, nil)
 	if err != nil {
-		_resp := fmt.Sprintf("CONSUMER ERROR: problem %v:\n", err)
-		utils.LogError(_resp, entities.ErrorLog)
+		slog.Error("subscribing to kafka topic failed", "topic", topic, "error", err)
 		os.Exit(1)
 	}
 
-	// Context to handle signal interrupts
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	// Handle an Ctrl + C signals
-	sigchan := make(chan os.Signal, 1)
-	signal.Notify(sigchan, syscall.SIGINT, syscall.SIGTERM)
-
-	go func() {
-		<-sigchan
-		cancel()
-		os.Exit(1)
-	}()
-
 	for {
 		select {
 		case <-ctx.Done():
-			consumer.Close()
-			_msg := fmt.Sprintf("Detected termination signal %v: exiting\n", <-ctx.Done())
-			utils.LogError(_msg, entities.ErrorLog)
-			os.Exit(1)
+			slog.Info("kafka consumer stopped", "topic", topic)
+			return
 		default:
 			msg, err := consumer.ReadMessage(1000 * time.Millisecond)
 			if err != nil {
 				if err.(kafka.Error).IsTimeout() {
 					continue
 				}
-				msg := fmt.Sprintf("Consumer error: %v %v:\n", err, msg)
-				utils.LogError(msg, entities.ErrorLog)
+				slog.Error("reading kafka message failed", "topic", topic, "error", err)
 				return
 
 			}
 
-			_imsg := fmt.Sprintf("Consumed from topic %s key=%-10s value = %s\n", *msg.TopicPartition.Topic, string(msg.Key), string(msg.Value))
-			utils.LogInfo(_imsg, entities.InfoLog)
+			msgCtx := utils.WithMessageID(tracing.ExtractKafka(b.ctx, msg), kafkaMessageID(msg))
+			msgCtx, span := tracing.Tracer().Start(msgCtx, *msg.TopicPartition.Topic+" process",
+				trace.WithSpanKind(trace.SpanKindConsumer),
+				trace.WithAttributes(attribute.String("messaging.system", "kafka"), attribute.String("messaging.destination.name", *msg.TopicPartition.Topic)),
+			)
+
+			err = b.transactionEvents().Dispatch(msgCtx, msg.Value)
+			if err != nil {
+				slog.ErrorContext(msgCtx, "handling kafka event failed", "topic", *msg.TopicPartition.Topic, "key", string(msg.Key), "error", err)
+				tracing.RecordError(span, err)
+			} else {
+				slog.InfoContext(msgCtx, "kafka message consumed", "topic", *msg.TopicPartition.Topic, "key", string(msg.Key))
+			}
+			span.End()
 		}
 
 	}
 
 }
 
-func (b *Base) RabbitMQConsumer(wg *sync.WaitGroup) {
+// kafkaMessageID identifies a Kafka message by where it sits in the log.
+func kafkaMessageID(msg *kafka.Message) string {
+	return fmt.Sprintf("%s/%d/%d", *msg.TopicPartition.Topic, msg.TopicPartition.Partition, msg.TopicPartition.Offset)
+}
+
+// rabbitConsumerTag names the queue subscription so it can be cancelled on
+// shutdown.
+const rabbitConsumerTag = "booking-transactions"
+
+// RabbitMQConsumer handles transaction messages until ctx is cancelled. It
+// resubscribes when the broker connection drops and comes back. On shutdown
+// it stops the delivery of new messages and finishes the one in hand;
+// deliveries that were prefetched but not handled are requeued by the broker.
+func (b *Base) RabbitMQConsumer(ctx context.Context, wg *sync.WaitGroup) {
 	defer wg.Done()
 
-	ch, err := b.rabbitConn.Channel()
-	if err != nil {
-		log.Fatal("Failed to open channel due to: " + err.Error())
-		os.Exit(1)
+	if b.rabbit == nil {
+		return
 	}
 
-	defer ch.Close()
+	slog.Info("rabbitmq consumer listening", "queue", b.queueName)
 
-	q, err := ch.QueueDeclare(
-		b.queueName,
-		true,  // durable
-		false, // delete when unused
-		false, // exclusive:false  multiple consumers can access this
-		false, // no-wait : mq responds allowing error checking
-		nil,
-	)
+	err := b.rabbit.Consume(ctx, b.queueName, rabbitConsumerTag, b.handleDelivery)
 	if err != nil {
-		log.Fatal("Failed to declare queue due to: " + err.Error())
-		os.Exit(1)
+		slog.Error("rabbitmq consumer stopped", "queue", b.queueName, "error", err)
+		return
 	}
 
-	msgs, err := ch.Consume(
-		q.Name,
-		"",    // consumer tag
-		false, // auto-ack
-		false, // exclusive
-		false, // no-local
-		false, // no-wait
-		nil,
-	)
-	if err != nil {
-		os.Exit(1)
-	}
+	slog.Info("rabbitmq consumer stopped", "queue", b.queueName)
+}
 
-	sigs := make(chan os.Signal, 1)
-	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
-	done := make(chan bool)
+// handleDelivery processes one transaction message and acks it, or nacks it
+// without requeueing when it cannot be stored. It runs on b.ctx so that a
+// shutdown signal does not abort the message half way.
+func (b *Base) handleDelivery(data amqp.Delivery) {
+	metrics.RabbitMessages.WithLabelValues(b.queueName, "consumed").Inc()
 
-	go func() {
-		for data := range msgs {
+	ctx := utils.WithMessageID(tracing.ExtractAMQP(b.ctx, data.Headers), data.MessageId)
+	ctx, span := tracing.Tracer().Start(ctx, b.queueName+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attribute.String("messaging.system", "rabbitmq"), attribute.String("messaging.destination.name", b.queueName)),
+	)
+	defer span.End()
 
-			// 1. Extract the values from the data.Body
-			var trx entities.TRXPayload
+	err := b.processTransaction(ctx, data.Body)
+	if err != nil {
+		slog.ErrorContext(ctx, "processing transaction message failed, dropping it", "queue", b.queueName, "error", err)
+		tracing.RecordError(span, err)
+		data.Nack(false, false)
+		metrics.RabbitMessages.WithLabelValues(b.queueName, "nack").Inc()
+		return
+	}
 
-			err = json.Unmarshal(data.Body, &trx)
-			if err != nil {
-				utils.LogError("CONSUMER: Failed to parse message body %s", entities.ErrorLog, err.Error())
-				data.Nack(false, false)
-				continue
-			}
+	// Acknowledge the message so that no data is lost
+	data.Ack(false)
+	metrics.RabbitMessages.WithLabelValues(b.queueName, "ack").Inc()
+}
 
-			// 2. Insert into table
-			err = b.paymentService.AddPayment(b.ctx, &trx)
-			if err != nil {
-				utils.LogError("CONSUMER: Failed to parse message body %s", entities.ErrorLog, err.Error())
-				data.Nack(false, false)
-				continue
+// processTransaction routes an event taken off the transactions queue.
+func (b *Base) processTransaction(ctx context.Context, body []byte) error {
+	err := b.transactionEvents().Dispatch(ctx, body)
+	if err != nil {
+		slog.ErrorContext(ctx, "handling transaction event failed", "error", err)
+		return err
+	}
 
-			}
+	return nil
+}
 
-			// 3. Acknowledge the message so that no data is lost
-			data.Ack(false)
+// handlePaymentSucceeded stores a successful payment and splits it between
+// the platform and the vendor in the ledger.
+func (b *Base) handlePaymentSucceeded(ctx context.Context, e events.Envelope) error {
+	// 1. Extract the values from the payload
+	var p events.PaymentEvent
 
-		}
-	}()
+	err := e.Decode(&p)
+	if err != nil {
+		slog.ErrorContext(ctx, "parsing payment event failed", "event_id", e.ID, "error", err)
+		return err
+	}
 
-	go func() {
-		<-sigs
-		utils.LogError("RABBITCONSUMER: Termination signal received. Exiting...", entities.ErrorLog)
-		done <- true
-	}()
+	trx := entities.TRXPayload{
+		RoomID:    p.RoomID,
+		UserID:    p.UserID,
+		OrderID:   p.OrderID,
+		Reference: p.Reference,
+		TrxID:     p.TrxID,
+		Status:    entities.TransactionStatusPaid,
+		Days:      p.Days,
+		Payment: entities.PaymentBody{
+			Amount:   p.Amount,
+			Currency: p.Currency,
+		},
+	}
 
-	utils.LogInfo("RABBITCONSUMER: Listing to  `%s` queue", entities.InfoLog, b.queueName)
+	// 2. Insert into table. A payment stored by an earlier attempt that failed
+	// further on is left as is and the remaining steps are retried.
+	err = b.paymentService.AddPayment(ctx, &trx)
+	if errors.Is(err, entities.ErrDuplicateTransaction) {
+		slog.InfoContext(ctx, "payment already stored", "order_id", trx.OrderID, "trx_id", trx.TrxID)
+	} else if err != nil {
+		slog.ErrorContext(ctx, "storing payment failed", "order_id", trx.OrderID, "error", err)
+		return err
+	}
 
-	<-done
+	// 3. Split the payment between platform and vendor in the ledger
+	err = b.ledgerService.RecordPayment(ctx, &trx)
+	if errors.Is(err, entities.ErrJournalExists) {
+		slog.InfoContext(ctx, "payment already in ledger", "order_id", trx.OrderID, "trx_id", trx.TrxID)
+		return nil
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "recording payment in ledger failed", "order_id", trx.OrderID, "error", err)
+		return err
+	}
 
+	return nil
 }
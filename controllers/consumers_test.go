@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/events"
+	"github.com/bicosteve/booking-system/repo"
+	"github.com/bicosteve/booking-system/service"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupConsumerBase(t *testing.T) (*Base, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock db: %v", err)
+	}
+	repository := *repo.NewDBRepository(db, nil)
+
+	base := &Base{
+		ledgerService:  service.NewLedgerService(repository, entities.PayoutConfig{CommissionBps: 1500}),
+		paymentService: service.NewPaymentService(repository),
+		eventService:   service.NewEventService(repository),
+		DB:             db,
+	}
+	return base, mock
+}
+
+func paymentSucceededBody(t *testing.T) (events.Envelope, []byte) {
+	t.Helper()
+	e, err := events.New(events.PaymentSucceeded, events.PaymentEvent{
+		OrderID: "order-1", TrxID: "pi_1", RoomID: 3, UserID: 5, Days: 2, Amount: 10000, Currency: "KES",
+	})
+	assert.NoError(t, err)
+
+	body, err := json.Marshal(e)
+	assert.NoError(t, err)
+	return e, body
+}
+
+func TestProcessTransaction_SkipsProcessedEvents(t *testing.T) {
+	base, mock := setupConsumerBase(t)
+	e, body := paymentSucceededBody(t)
+
+	mock.ExpectPrepare("SELECT 1 FROM processed_event").ExpectQuery().
+		WithArgs(paymentsConsumer, e.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"1"}))
+	mock.ExpectPrepare("INSERT INTO transaction").ExpectExec().
+		WithArgs(3, 5, "order-1", "pi_1", "", int64(10000), "KES", entities.TransactionStatusPaid).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectPrepare("FROM room WHERE room_id").ExpectQuery().WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"room_id", "cost", "currency", "status", "vender_id", "created_at", "updated_at"}).
+			AddRow(3, 5000, "KES", "BOOKED", "7", time.Now(), time.Now()))
+	// Stored outside a request, the payment is audited as the system's.
+	mock.ExpectPrepare("INSERT INTO audit_log").ExpectExec().
+		WithArgs(entities.ActorSystem, nil, nil, 7, entities.AuditTransactionCreate, "transaction", "pi_1",
+			nil, sqlmock.AnyArg(), "", "").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectPrepare("FROM room WHERE room_id").ExpectQuery().WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"room_id", "cost", "currency", "status", "vender_id", "created_at", "updated_at"}).
+			AddRow(3, 5000, "KES", "BOOKED", "7", time.Now(), time.Now()))
+	mock.ExpectBegin()
+	prep := mock.ExpectPrepare("INSERT INTO ledger_entry")
+	for i := 0; i < 3; i++ {
+		prep.ExpectExec().WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+	mock.ExpectCommit()
+	mock.ExpectPrepare("INSERT IGNORE INTO processed_event").ExpectExec().
+		WithArgs(paymentsConsumer, e.ID, events.PaymentSucceeded).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// The redelivery is skipped without touching the transaction table.
+	mock.ExpectPrepare("SELECT 1 FROM processed_event").ExpectQuery().
+		WithArgs(paymentsConsumer, e.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"1"}).AddRow(1))
+
+	assert.NoError(t, base.processTransaction(context.Background(), body))
+	assert.NoError(t, base.processTransaction(context.Background(), body))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestProcessTransaction_ResumesPartiallyProcessedEvents(t *testing.T) {
+	base, mock := setupConsumerBase(t)
+	e, body := paymentSucceededBody(t)
+
+	// An earlier attempt stored the payment and posted the ledger but died
+	// before marking the event.
+	duplicate := &mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}
+	mock.ExpectPrepare("SELECT 1 FROM processed_event").ExpectQuery().
+		WillReturnRows(sqlmock.NewRows([]string{"1"}))
+	mock.ExpectPrepare("INSERT INTO transaction").ExpectExec().WillReturnError(duplicate)
+	mock.ExpectPrepare("FROM room WHERE room_id").ExpectQuery().WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"room_id", "cost", "currency", "status", "vender_id", "created_at", "updated_at"}).
+			AddRow(3, 5000, "KES", "BOOKED", "7", time.Now(), time.Now()))
+	mock.ExpectBegin()
+	mock.ExpectPrepare("INSERT INTO ledger_entry").ExpectExec().WillReturnError(duplicate)
+	mock.ExpectRollback()
+	mock.ExpectPrepare("INSERT IGNORE INTO processed_event").ExpectExec().
+		WithArgs(paymentsConsumer, e.ID, events.PaymentSucceeded).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, base.processTransaction(context.Background(), body))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/events"
+	"github.com/bicosteve/booking-system/pkg/producer"
+)
+
+// bookingEventTypes maps the booking statuses that are published to their
+// event type.
+var bookingEventTypes = map[int]string{
+	entities.BookingStatusConfirmed:  events.BookingConfirmed,
+	entities.BookingStatusCancelled:  events.BookingCancelled,
+	entities.BookingStatusCheckedOut: events.BookingCheckedOut,
+}
+
+// eventTopic picks the Kafka topic for eventType: payment events go to the
+// last configured topic, booking events to the first.
+func (b *Base) eventTopic(eventType string) string {
+	if strings.HasPrefix(eventType, "payment.") {
+		return b.Topics[len(b.Topics)-1]
+	}
+	return b.Topics[0]
+}
+
+// eventMessage serializes e for topic. The event id, type and version also
+// go in the headers so consumers can route without parsing the body.
+func eventMessage(topic, key string, e events.Envelope) (producer.Message, error) {
+	value, err := json.Marshal(e)
+	if err != nil {
+		return producer.Message{}, err
+	}
+
+	return producer.Message{
+		Topic: topic,
+		Key:   key,
+		Value: value,
+		Headers: map[string]string{
+			"content-type":  "application/json",
+			"event-id":      e.ID,
+			"event-type":    e.Type,
+			"event-version": strconv.Itoa(e.Version),
+		},
+	}, nil
+}
+
+// publishEvent queues an event for the vendor's webhooks and, when it is
+// switched on, on Kafka. key keeps the events of one booking or payment in
+// order. Delivery is reported in the background, so failures are logged
+// rather than returned.
+func (b *Base) publishEvent(ctx context.Context, eventType, key string, payload any) {
+	e, err := events.New(eventType, payload)
+	if err != nil {
+		slog.ErrorContext(ctx, "building event failed", "event_type", eventType, "error", err)
+		return
+	}
+
+	b.enqueueWebhooks(ctx, e)
+
+	if b.KafkaStatus != 1 {
+		return
+	}
+
+	msg, err := eventMessage(b.eventTopic(eventType), key, e)
+	if err == nil {
+		err = b.producer.Publish(ctx, msg)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "publishing event failed", "event_type", eventType, "event_id", e.ID, "error", err)
+	}
+}
+
+// enqueueWebhooks queues e for the webhooks subscribed to it. The
+// WebhookWorker sends them.
+func (b *Base) enqueueWebhooks(ctx context.Context, e events.Envelope) {
+	if b.webhookService == nil {
+		return
+	}
+
+	_, err := b.webhookService.Enqueue(ctx, e)
+	if err != nil {
+		slog.ErrorContext(ctx, "queueing webhook deliveries failed", "event_type", e.Type, "event_id", e.ID, "error", err)
+	}
+}
+
+// publishBookingEvent publishes the event for the status booking has just
+// moved to, if that status has one.
+func (b *Base) publishBookingEvent(ctx context.Context, booking *entities.Booking, orderID, reason string) {
+	eventType, ok := bookingEventTypes[booking.Status]
+	if !ok {
+		return
+	}
+
+	b.publishEvent(ctx, eventType, strconv.Itoa(booking.ID), bookingEvent(booking, orderID, reason))
+}
+
+func bookingEvent(booking *entities.Booking, orderID, reason string) events.BookingEvent {
+	return events.BookingEvent{
+		BookingID: booking.ID,
+		RoomID:    booking.RoomID,
+		UserID:    booking.UserID,
+		Status:    entities.BookingStatusNames[booking.Status],
+		Days:      booking.Days,
+		OrderID:   orderID,
+		Reason:    reason,
+	}
+}
+
+// paymentEvent describes a transaction in a payment event.
+func paymentEvent(trx entities.TRXPayload) events.PaymentEvent {
+	return events.PaymentEvent{
+		OrderID:   trx.OrderID,
+		TrxID:     trx.TrxID,
+		Reference: trx.Reference,
+		RoomID:    trx.RoomID,
+		UserID:    trx.UserID,
+		Days:      trx.Days,
+		Amount:    trx.Payment.Amount,
+		Currency:  trx.Payment.Currency,
+	}
+}
+
+// paymentsConsumer names the handlers that store payments when recording
+// which events they have processed. The Kafka and RabbitMQ consumers share
+// it, so a payment seen on both is stored once.
+const paymentsConsumer = "payments"
+
+// transactionEvents routes the events taken off the transactions queue and
+// the payments topic.
+func (b *Base) transactionEvents() *events.Router {
+	r := events.NewRouter()
+	r.Handle(events.PaymentSucceeded, events.Idempotent(b.eventService, paymentsConsumer, b.handlePaymentSucceeded))
+	return r
+}
@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/events"
+	"github.com/bicosteve/booking-system/pkg/producer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublishBookingEvent(t *testing.T) {
+	fake := producer.NewFake()
+	b := &Base{KafkaStatus: 1, Topics: []string{"bookings", "payments"}, producer: fake}
+
+	booking := &entities.Booking{ID: 7, RoomID: 3, UserID: 5, Days: 2, Status: entities.BookingStatusCancelled}
+	b.publishBookingEvent(context.Background(), booking, "", "change of plans")
+
+	// Checking in has no event.
+	booking.Status = entities.BookingStatusCheckedIn
+	b.publishBookingEvent(context.Background(), booking, "", "")
+
+	messages := fake.Messages()
+	assert.Len(t, messages, 1)
+
+	msg := messages[0]
+	assert.Equal(t, "bookings", msg.Topic)
+	assert.Equal(t, "7", msg.Key)
+	assert.Equal(t, events.BookingCancelled, msg.Headers["event-type"])
+	assert.Equal(t, "1", msg.Headers["event-version"])
+
+	e, err := events.Decode(msg.Value)
+	assert.NoError(t, err)
+	assert.Equal(t, msg.Headers["event-id"], e.ID)
+
+	var payload events.BookingEvent
+	assert.NoError(t, e.Decode(&payload))
+	assert.Equal(t, "cancelled", payload.Status)
+	assert.Equal(t, "change of plans", payload.Reason)
+}
+
+func TestPublishEvent_PaymentsTopic(t *testing.T) {
+	fake := producer.NewFake()
+	b := &Base{KafkaStatus: 1, Topics: []string{"bookings", "payments"}, producer: fake}
+
+	b.publishEvent(context.Background(), events.PaymentRefunded, "pi_1", events.PaymentEvent{
+		OrderID: "order-1", TrxID: "pi_1", RoomID: 3, UserID: 5, Amount: 1000, Currency: "KES",
+	})
+
+	// Invalid payloads are not published.
+	b.publishEvent(context.Background(), events.PaymentRefunded, "pi_2", events.PaymentEvent{TrxID: "pi_2"})
+
+	messages := fake.Messages()
+	assert.Len(t, messages, 1)
+	assert.Equal(t, "payments", messages[0].Topic)
+}
+
+func TestPublishEvent_KafkaOff(t *testing.T) {
+	fake := producer.NewFake()
+	b := &Base{Topics: []string{"bookings"}, producer: fake}
+
+	b.publishBookingEvent(context.Background(), &entities.Booking{ID: 1, RoomID: 1, UserID: 1, Days: 1, Status: entities.BookingStatusConfirmed}, "", "")
+
+	assert.Empty(t, fake.Messages())
+}
@@ -2,7 +2,11 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"net/http"
+	"slices"
+	"sync"
 
 	"github.com/bicosteve/booking-system/pkg/health"
 	"github.com/bicosteve/booking-system/pkg/utils"
@@ -68,3 +72,71 @@ func (b *Base) healthCheckers() []health.Checker {
 	}
 	return b.defaultLiveCheckers()
 }
+
+// markOptional flags the checkers named in optional as non-critical.
+func markOptional(checkers []health.Checker, optional []string) []health.Checker {
+	for i := range checkers {
+		checkers[i].Optional = slices.Contains(optional, checkers[i].Name)
+	}
+	return checkers
+}
+
+// HealthMonitor keeps the cached readiness report fresh until ctx is
+// cancelled.
+func (b *Base) HealthMonitor(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	if b.healthMonitor == nil {
+		return
+	}
+
+	b.healthMonitor.Run(ctx)
+}
+
+// LivezHandler reports that the process is up and serving. It does not touch
+// any dependency, so a database outage never gets the container restarted.
+func (b *Base) LivezHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// ReadyzHandler serves the last background health report. It answers 200 when
+// every required dependency is up, even if optional ones are down, and 503
+// otherwise or before the first check has finished.
+func (b *Base) ReadyzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if b.healthMonitor == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_ = json.NewEncoder(w).Encode(health.Report{Status: "starting", Checks: []health.Result{}})
+		return
+	}
+
+	report, checked := b.healthMonitor.Report()
+	if !checked {
+		report = health.Report{Status: "starting", Checks: []health.Result{}}
+	}
+
+	status := http.StatusOK
+	if !b.healthMonitor.Ready() {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+// StartupzHandler answers 503 until the dependencies have been reachable once,
+// then 200 for the rest of the process's life.
+func (b *Base) StartupzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	status, body := http.StatusServiceUnavailable, "starting"
+	if b.healthMonitor != nil && b.healthMonitor.Started() {
+		status, body = http.StatusOK, "started"
+	}
+
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"status": body})
+}
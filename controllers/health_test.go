@@ -6,7 +6,9 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/bicosteve/booking-system/pkg/health"
 	"github.com/stretchr/testify/assert"
@@ -83,3 +85,111 @@ func TestHealthCheck_DisabledDeps(t *testing.T) {
 		}
 	}
 }
+
+func newMonitoredBase(t *testing.T, checkers []health.Checker, refresh bool) *Base {
+	t.Helper()
+
+	m := health.NewMonitor(checkers, time.Minute)
+	if refresh {
+		m.Refresh(context.Background())
+	}
+
+	return &Base{healthMonitor: m}
+}
+
+func TestLivez(t *testing.T) {
+	base := newMonitoredBase(t, []health.Checker{
+		{Name: "mysql", Ping: func(context.Context) error { return errors.New("db down") }},
+	}, true)
+
+	w := httptest.NewRecorder()
+	base.LivezHandler(w, httptest.NewRequest(http.MethodGet, "/livez", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestReadyz(t *testing.T) {
+	tests := []struct {
+		name       string
+		checkers   []health.Checker
+		refresh    bool
+		wantCode   int
+		wantStatus string
+	}{
+		{
+			name:       "before first check",
+			checkers:   []health.Checker{{Name: "mysql", Ping: func(context.Context) error { return nil }}},
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: "starting",
+		},
+		{
+			name:       "all up",
+			checkers:   []health.Checker{{Name: "mysql", Ping: func(context.Context) error { return nil }}},
+			refresh:    true,
+			wantCode:   http.StatusOK,
+			wantStatus: "healthy",
+		},
+		{
+			name: "optional down",
+			checkers: []health.Checker{
+				{Name: "mysql", Ping: func(context.Context) error { return nil }},
+				{Name: "kafka", Optional: true, Ping: func(context.Context) error { return errors.New("broker down") }},
+			},
+			refresh:    true,
+			wantCode:   http.StatusOK,
+			wantStatus: "degraded",
+		},
+		{
+			name:       "required down",
+			checkers:   []health.Checker{{Name: "mysql", Ping: func(context.Context) error { return errors.New("db down") }}},
+			refresh:    true,
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: "unhealthy",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := newMonitoredBase(t, tt.checkers, tt.refresh)
+
+			w := httptest.NewRecorder()
+			base.ReadyzHandler(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			var rep health.Report
+			assert.NoError(t, json.NewDecoder(w.Body).Decode(&rep))
+			assert.Equal(t, tt.wantStatus, rep.Status)
+		})
+	}
+}
+
+func TestStartupz(t *testing.T) {
+	var down atomic.Bool
+	base := newMonitoredBase(t, []health.Checker{
+		{Name: "mysql", Ping: func(context.Context) error {
+			if down.Load() {
+				return errors.New("db down")
+			}
+			return nil
+		}},
+	}, false)
+
+	w := httptest.NewRecorder()
+	base.StartupzHandler(w, httptest.NewRequest(http.MethodGet, "/startupz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	base.healthMonitor.Refresh(context.Background())
+	down.Store(true)
+	base.healthMonitor.Refresh(context.Background())
+
+	w = httptest.NewRecorder()
+	base.StartupzHandler(w, httptest.NewRequest(http.MethodGet, "/startupz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestMarkOptional(t *testing.T) {
+	checkers := markOptional([]health.Checker{{Name: "mysql"}, {Name: "kafka"}}, healthOptional(nil))
+
+	assert.False(t, checkers[0].Optional)
+	assert.True(t, checkers[1].Optional)
+}
@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// PayoutScheduler periodically batches vendor balances into pending payouts
+// until ctx is cancelled.
+func (b *Base) PayoutScheduler(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	ticker := time.NewTicker(b.payoutInterval)
+	defer ticker.Stop()
+
+	slog.Info("payout scheduler running", "interval", b.payoutInterval.String())
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("payout scheduler stopped")
+			return
+		case <-ticker.C:
+		}
+
+		created, err := b.ledgerService.SchedulePayouts(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "batching payouts failed", "error", err)
+		}
+
+		if created > 0 {
+			slog.InfoContext(ctx, "payouts created", "count", created)
+		}
+	}
+}
+
+// WebhookWorker sends due webhook deliveries, retrying failed ones, until ctx
+// is cancelled.
+func (b *Base) WebhookWorker(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	ticker := time.NewTicker(b.webhookInterval)
+	defer ticker.Stop()
+
+	slog.Info("webhook worker running", "interval", b.webhookInterval.String())
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("webhook worker stopped")
+			return
+		case <-ticker.C:
+		}
+
+		delivered, err := b.webhookService.DeliverDue(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "sending webhook deliveries failed", "error", err)
+		}
+
+		if delivered > 0 {
+			slog.InfoContext(ctx, "webhooks delivered", "count", delivered)
+		}
+	}
+}
+
+// CalendarSyncWorker imports the rooms' external calendars into their blocks
+// until ctx is cancelled.
+func (b *Base) CalendarSyncWorker(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	ticker := time.NewTicker(b.calendarInterval)
+	defer ticker.Stop()
+
+	slog.Info("calendar sync worker running", "interval", b.calendarInterval.String())
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("calendar sync worker stopped")
+			return
+		case <-ticker.C:
+		}
+
+		synced, err := b.calendarService.SyncAll(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "syncing calendars failed", "error", err)
+		}
+
+		if synced > 0 {
+			slog.InfoContext(ctx, "calendars synced", "count", synced)
+		}
+	}
+}
+
+// stayCompletionHour is the local hour at which overdue stays are completed,
+// after the last guests have normally left.
+const stayCompletionHour = 2
+
+// StayCompletionScheduler checks out guests whose stay has ended once a night
+// and frees their rooms, until ctx is cancelled.
+func (b *Base) StayCompletionScheduler(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	slog.Info("stay completion scheduler running", "hour", stayCompletionHour)
+
+	for {
+		timer := time.NewTimer(time.Until(nextNightlyRun(time.Now(), stayCompletionHour)))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			slog.Info("stay completion scheduler stopped")
+			return
+		case <-timer.C:
+		}
+
+		completed, err := b.bookingService.CompleteOverdueStays(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "completing overdue stays failed", "error", err)
+		}
+
+		for _, booking := range completed {
+			b.publishBookingEvent(ctx, booking, "", "stay ended")
+		}
+
+		if len(completed) > 0 {
+			slog.InfoContext(ctx, "overdue stays checked out", "count", len(completed))
+		}
+	}
+}
+
+// nextNightlyRun returns the next time after now that the clock reads hour:00.
+func nextNightlyRun(now time.Time, hour int) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+
+	return next
+}
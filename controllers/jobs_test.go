@@ -0,0 +1,38 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextNightlyRun(t *testing.T) {
+	tests := []struct {
+		name string
+		now  time.Time
+		want time.Time
+	}{
+		{
+			name: "before the hour",
+			now:  time.Date(2024, 3, 10, 1, 30, 0, 0, time.UTC),
+			want: time.Date(2024, 3, 10, 2, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "exactly on the hour",
+			now:  time.Date(2024, 3, 10, 2, 0, 0, 0, time.UTC),
+			want: time.Date(2024, 3, 11, 2, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "after the hour",
+			now:  time.Date(2024, 3, 31, 14, 0, 0, 0, time.UTC),
+			want: time.Date(2024, 4, 1, 2, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, nextNightlyRun(tt.now, 2))
+		})
+	}
+}
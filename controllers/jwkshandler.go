@@ -0,0 +1,22 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/bicosteve/booking-system/pkg/utils"
+)
+
+// JWKS godoc
+// @Summary public keys for verifying auth tokens
+// @Description Returns the public keys auth tokens are signed with, as a JSON Web Key Set. Keys are listed before they start signing and until they retire, so services verifying tokens can cache this document for a few minutes. Tokens name their key in the kid header.
+// @ID jwks
+// @Tags users
+// @Produce json
+// @Success 200 {object} jwtkeys.JWKS "Success"
+// @Router /.well-known/jwks.json [get]
+func (b *Base) JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	headers := http.Header{}
+	headers.Set("Cache-Control", "public, max-age=300")
+
+	_ = utils.DeserializeJSON(w, http.StatusOK, b.jwtKeys.JWKS(), headers)
+}
@@ -0,0 +1,290 @@
+package controllers
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/events"
+	"github.com/bicosteve/booking-system/pkg/payments"
+	"github.com/bicosteve/booking-system/pkg/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+const statementDateLayout = "2006-01-02"
+
+// Vendor balance godoc
+// @Summary get vendor payout balance
+// @Description Returns the available, pending and paid-out amounts (minor units) per settlement currency for the logged in vendor
+// @ID vendor-balance
+// @Tags payouts
+// @Produce json
+// @Success 200 {array} entities.VendorBalance "Success"
+// @Failure 401 {object} entities.JSONResponse "Unauthorized"
+// @Failure 500 {object} entities.JSONResponse "Internal server error"
+// @Router /api/admin/payouts/balance [get]
+func (b *Base) GetVendorBalanceHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", b.contentType)
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	userID, ok := r.Context().Value(entities.UseridKeyValue).(string)
+	if !ok {
+		slog.ErrorContext(r.Context(), "could not get user_id from context")
+		utils.ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
+		return
+	}
+	vendorID, err := strconv.Atoi(userID)
+	if err != nil {
+		slog.WarnContext(r.Context(), "invalid user_id in context", "user_id", userID)
+		utils.ErrorJSON(w, errors.New("unauthorized"), http.StatusUnauthorized)
+		return
+	}
+
+	balances, err := b.ledgerService.GetVendorBalances(ctx, vendorID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "get vendor balance failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	_ = utils.DeserializeJSON(w, http.StatusOK, map[string]any{"data": balances})
+}
+
+// Vendor payouts godoc
+// @Summary list vendor payouts
+// @Description Returns all payouts batched for the logged in vendor
+// @ID vendor-payouts
+// @Tags payouts
+// @Produce json
+// @Success 200 {array} entities.Payout "Success"
+// @Failure 401 {object} entities.JSONResponse "Unauthorized"
+// @Failure 500 {object} entities.JSONResponse "Internal server error"
+// @Router /api/admin/payouts [get]
+func (b *Base) GetVendorPayoutsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", b.contentType)
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	userID, ok := r.Context().Value(entities.UseridKeyValue).(string)
+	if !ok {
+		slog.ErrorContext(r.Context(), "could not get user_id from context")
+		utils.ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
+		return
+	}
+	vendorID, err := strconv.Atoi(userID)
+	if err != nil {
+		slog.WarnContext(r.Context(), "invalid user_id in context", "user_id", userID)
+		utils.ErrorJSON(w, errors.New("unauthorized"), http.StatusUnauthorized)
+		return
+	}
+
+	payouts, err := b.ledgerService.GetVendorPayouts(ctx, vendorID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "get vendor payouts failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	_ = utils.DeserializeJSON(w, http.StatusOK, map[string]any{"data": payouts})
+}
+
+// Settle payout godoc
+// @Summary mark a payout as sent
+// @Description Marks a pending payout as paid once the platform has sent the money. Platform admins only.
+// @ID settle-payout
+// @Tags payouts
+// @Produce json
+// @Param payout_id path string true "Payout to settle"
+// @Success 200 {object} entities.JSONResponse "Success"
+// @Failure 400 {object} entities.JSONResponse "Bad request"
+// @Failure 401 {object} entities.JSONResponse "Unauthorized"
+// @Failure 403 {object} entities.JSONResponse "Forbidden"
+// @Router /api/admin/payouts/{payout_id}/paid [put]
+func (b *Base) SettlePayoutHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", b.contentType)
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	payoutID, err := strconv.Atoi(chi.URLParam(r, "payout_id"))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "settle payout failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	err = b.ledgerService.SettlePayout(ctx, payoutID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "settle payout failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	_ = utils.DeserializeJSON(w, http.StatusOK, map[string]any{"msg": "payout settled"})
+}
+
+// Vendor statement godoc
+// @Summary export vendor statement
+// @Description Exports the vendor's ledger lines between from (inclusive) and to (exclusive) as CSV
+// @ID vendor-statement
+// @Tags payouts
+// @Produce text/csv
+// @Param from query string true "Start date, YYYY-MM-DD"
+// @Param to query string true "End date, YYYY-MM-DD"
+// @Success 200 {string} string "CSV statement"
+// @Failure 400 {object} entities.JSONResponse "Bad request"
+// @Failure 401 {object} entities.JSONResponse "Unauthorized"
+// @Router /api/admin/payouts/statement [get]
+func (b *Base) ExportStatementHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	from, err := time.Parse(statementDateLayout, r.URL.Query().Get("from"))
+	if err != nil {
+		slog.WarnContext(r.Context(), "invalid from date")
+		utils.ErrorJSON(w, errors.New("from must be a YYYY-MM-DD date"), http.StatusBadRequest)
+		return
+	}
+
+	to, err := time.Parse(statementDateLayout, r.URL.Query().Get("to"))
+	if err != nil {
+		slog.WarnContext(r.Context(), "invalid to date")
+		utils.ErrorJSON(w, errors.New("to must be a YYYY-MM-DD date"), http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := r.Context().Value(entities.UseridKeyValue).(string)
+	if !ok {
+		slog.ErrorContext(r.Context(), "could not get user_id from context")
+		utils.ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
+		return
+	}
+	vendorID, err := strconv.Atoi(userID)
+	if err != nil {
+		slog.WarnContext(r.Context(), "invalid user_id in context", "user_id", userID)
+		utils.ErrorJSON(w, errors.New("unauthorized"), http.StatusUnauthorized)
+		return
+	}
+
+	entries, err := b.ledgerService.GetVendorStatement(ctx, vendorID, from, to)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "export statement failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	filename := fmt.Sprintf("statement_%d_%s_%s.csv", vendorID, from.Format(statementDateLayout), to.Format(statementDateLayout))
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+
+	out := csv.NewWriter(w)
+	_ = out.Write([]string{"entry_id", "created_at", "reference", "kind", "account", "direction", "amount", "currency"})
+	for _, e := range entries {
+		_ = out.Write([]string{
+			strconv.Itoa(e.ID),
+			e.CreatedAt.UTC().Format(time.RFC3339),
+			e.Reference,
+			e.Kind,
+			e.Account,
+			e.Direction,
+			strconv.FormatInt(e.Amount, 10),
+			e.Currency,
+		})
+	}
+	out.Flush()
+}
+
+// Refund transaction godoc
+// @Summary refund a guest payment
+// @Description Refunds a payment made for one of the vendor's rooms on Stripe and reverses it in the ledger
+// @ID refund-transaction
+// @Tags payouts
+// @Produce json
+// @Param trx_id path string true "Transaction (payment intent) to refund"
+// @Success 200 {object} entities.JSONResponse "Success"
+// @Failure 400 {object} entities.JSONResponse "Bad request"
+// @Failure 403 {object} entities.JSONResponse "Not the vendor's transaction"
+// @Failure 500 {object} entities.JSONResponse "Internal server error"
+// @Router /api/admin/transactions/{trx_id}/refund [post]
+func (b *Base) RefundTransactionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", b.contentType)
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	trxID := chi.URLParam(r, "trx_id")
+
+	userID, ok := r.Context().Value(entities.UseridKeyValue).(string)
+	if !ok {
+		slog.ErrorContext(r.Context(), "could not get user_id from context")
+		utils.ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
+		return
+	}
+
+	trx, err := b.paymentService.GetTransaction(ctx, trxID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "refund transaction failed", "error", err)
+		utils.ErrorJSON(w, errors.New("transaction not found"), http.StatusBadRequest)
+		return
+	}
+
+	if trx.Status == entities.TransactionStatusRefunded {
+		utils.ErrorJSON(w, errors.New("transaction already refunded"), http.StatusBadRequest)
+		return
+	}
+
+	if trx.Status != entities.TransactionStatusPaid {
+		utils.ErrorJSON(w, entities.ErrTransactionNotPaid, http.StatusBadRequest)
+		return
+	}
+
+	room, err := b.roomService.FindARoom(ctx, trx.RoomID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "refund transaction failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if room.VenderId != userID {
+		slog.ErrorContext(r.Context(), "vendor does not own room", "user_id", userID, "room_id", trx.RoomID)
+		utils.ErrorJSON(w, errors.New("unauthorized access"), http.StatusForbidden)
+		return
+	}
+
+	// A retry after Stripe refunded but the ledger did not is finished here.
+	_, err = payments.RefundStripePayment(ctx, b.stripesecret, trx.TrxID)
+	if err != nil && !errors.Is(err, entities.ErrAlreadyRefunded) {
+		slog.ErrorContext(r.Context(), "refund transaction failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = b.ledgerService.RecordRefund(ctx, trx.TrxID)
+	if errors.Is(err, entities.ErrTransactionNotPaid) {
+		utils.ErrorJSON(w, errors.New("transaction already refunded"), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "refund transaction failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	b.publishEvent(ctx, events.PaymentRefunded, trx.TrxID, events.PaymentEvent{
+		OrderID:   trx.OrderID,
+		TrxID:     trx.TrxID,
+		Reference: trx.Reference,
+		RoomID:    trx.RoomID,
+		UserID:    trx.UserID,
+		Amount:    trx.Amount.Amount,
+		Currency:  trx.Amount.Currency,
+	})
+
+	_ = utils.DeserializeJSON(w, http.StatusOK, map[string]any{"msg": "refunded"})
+}
@@ -0,0 +1,248 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/repo"
+	"github.com/bicosteve/booking-system/service"
+	"github.com/go-redis/redismock/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stripe/stripe-go/v72"
+)
+
+func setupLedgerBase(t *testing.T) (*Base, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock db: %v", err)
+	}
+	rdb, _ := redismock.NewClientMock()
+	repository := *repo.NewDBRepository(db, rdb)
+
+	base := &Base{
+		ledgerService:  service.NewLedgerService(repository, entities.PayoutConfig{CommissionBps: 1500}),
+		paymentService: service.NewPaymentService(repository),
+		roomService:    service.NewRoomService(repository),
+		contentType:    "application/json",
+		DB:             db,
+	}
+	return base, mock
+}
+
+func TestGetVendorBalanceHandler(t *testing.T) {
+	base, mock := setupLedgerBase(t)
+	mock.ExpectPrepare("SELECT currency").
+		ExpectQuery().
+		WithArgs(7, 7).
+		WillReturnRows(sqlmock.NewRows([]string{"currency", "available", "pending", "paid"}).AddRow("KES", 8500, 0, 0))
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/payouts/balance", nil)
+	req = withUserID(req, "7")
+	w := httptest.NewRecorder()
+
+	base.GetVendorBalanceHandler(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"available": 8500`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetVendorBalanceHandler_InvalidUserID(t *testing.T) {
+	base, mock := setupLedgerBase(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/payouts/balance", nil)
+	req = withUserID(req, "not-a-number")
+	w := httptest.NewRecorder()
+
+	base.GetVendorBalanceHandler(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetVendorPayoutsHandler(t *testing.T) {
+	base, mock := setupLedgerBase(t)
+	mock.ExpectPrepare("SELECT payout_id").
+		ExpectQuery().
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"payout_id", "vendor_id", "amount", "currency", "status", "created_at", "paid_at"}).
+			AddRow(1, 7, 8500, "KES", "PENDING", time.Now(), nil))
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/payouts", nil)
+	req = withUserID(req, "7")
+	w := httptest.NewRecorder()
+
+	base.GetVendorPayoutsHandler(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSettlePayoutHandler(t *testing.T) {
+	t.Run("invalid payout id", func(t *testing.T) {
+		base, _ := setupLedgerBase(t)
+		req := httptest.NewRequest(http.MethodPut, "/admin/payouts/abc/paid", nil)
+		req = withURLParam(req, "payout_id", "abc")
+		req = withUserID(req, "7")
+		w := httptest.NewRecorder()
+
+		base.SettlePayoutHandler(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("payout not pending", func(t *testing.T) {
+		base, mock := setupLedgerBase(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT vendor_id, amount, currency FROM payout").WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"vendor_id", "amount", "currency"}))
+		mock.ExpectRollback()
+
+		req := httptest.NewRequest(http.MethodPut, "/admin/payouts/3/paid", nil)
+		req = withURLParam(req, "payout_id", "3")
+		req = withUserID(req, "7")
+		w := httptest.NewRecorder()
+
+		base.SettlePayoutHandler(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestExportStatementHandler(t *testing.T) {
+	t.Run("writes csv", func(t *testing.T) {
+		base, mock := setupLedgerBase(t)
+		created := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+		mock.ExpectPrepare("SELECT entry_id").
+			ExpectQuery().
+			WithArgs(7, sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"entry_id", "reference", "vendor_id", "account", "direction", "amount", "currency", "kind", "created_at"}).
+				AddRow(3, "pi_1", 7, "VENDOR_PAYABLE", "CREDIT", 8500, "KES", "PAYMENT", created))
+
+		req := httptest.NewRequest(http.MethodGet, "/admin/payouts/statement?from=2026-01-01&to=2026-02-01", nil)
+		req = withUserID(req, "7")
+		w := httptest.NewRecorder()
+
+		base.ExportStatementHandler(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/csv", w.Header().Get("Content-Type"))
+		lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+		assert.Len(t, lines, 2)
+		assert.Equal(t, "3,2026-01-02T10:00:00Z,pi_1,PAYMENT,VENDOR_PAYABLE,CREDIT,8500,KES", lines[1])
+	})
+
+	t.Run("invalid date", func(t *testing.T) {
+		base, _ := setupLedgerBase(t)
+		req := httptest.NewRequest(http.MethodGet, "/admin/payouts/statement?from=yesterday&to=2026-02-01", nil)
+		req = withUserID(req, "7")
+		w := httptest.NewRecorder()
+
+		base.ExportStatementHandler(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestRefundTransactionHandler(t *testing.T) {
+	now := time.Now()
+	trxColumns := []string{"transaction_id", "user_id", "room_id", "order_id", "trx_id", "reference", "amount", "currency", "status", "created_at", "updated_at"}
+
+	t.Run("unknown transaction", func(t *testing.T) {
+		base, mock := setupLedgerBase(t)
+		mock.ExpectPrepare("SELECT transaction_id").ExpectQuery().WithArgs("pi_1").WillReturnRows(sqlmock.NewRows(trxColumns))
+
+		req := httptest.NewRequest(http.MethodPost, "/admin/transactions/pi_1/refund", nil)
+		req = withURLParam(req, "trx_id", "pi_1")
+		req = withUserID(req, "7")
+		w := httptest.NewRecorder()
+
+		base.RefundTransactionHandler(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("already refunded", func(t *testing.T) {
+		base, mock := setupLedgerBase(t)
+		mock.ExpectPrepare("SELECT transaction_id").ExpectQuery().WithArgs("pi_1").
+			WillReturnRows(sqlmock.NewRows(trxColumns).AddRow(1, 5, 10, "o", "pi_1", "r", 100, "KES", entities.TransactionStatusRefunded, now, now))
+
+		req := httptest.NewRequest(http.MethodPost, "/admin/transactions/pi_1/refund", nil)
+		req = withURLParam(req, "trx_id", "pi_1")
+		req = withUserID(req, "7")
+		w := httptest.NewRecorder()
+
+		base.RefundTransactionHandler(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("another vendor's room", func(t *testing.T) {
+		base, mock := setupLedgerBase(t)
+		mock.ExpectPrepare("SELECT transaction_id").ExpectQuery().WithArgs("pi_1").
+			WillReturnRows(sqlmock.NewRows(trxColumns).AddRow(1, 5, 10, "o", "pi_1", "r", 100, "KES", entities.TransactionStatusPaid, now, now))
+		mock.ExpectPrepare("SELECT room_id, cost, currency").ExpectQuery().WithArgs(10).
+			WillReturnRows(sqlmock.NewRows([]string{"room_id", "cost", "currency", "status", "vender_id", "created_at", "updated_at"}).
+				AddRow("10", 10000, "KES", "BOOKED", "9", now, now))
+
+		req := httptest.NewRequest(http.MethodPost, "/admin/transactions/pi_1/refund", nil)
+		req = withURLParam(req, "trx_id", "pi_1")
+		req = withUserID(req, "7")
+		w := httptest.NewRecorder()
+
+		base.RefundTransactionHandler(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("pending payment", func(t *testing.T) {
+		base, mock := setupLedgerBase(t)
+		mock.ExpectPrepare("SELECT transaction_id").ExpectQuery().WithArgs("pi_1").
+			WillReturnRows(sqlmock.NewRows(trxColumns).AddRow(1, 5, 10, "o", "pi_1", "r", 100, "KES", entities.TransactionStatusPending, now, now))
+
+		req := httptest.NewRequest(http.MethodPost, "/admin/transactions/pi_1/refund", nil)
+		req = withURLParam(req, "trx_id", "pi_1")
+		req = withUserID(req, "7")
+		w := httptest.NewRecorder()
+
+		base.RefundTransactionHandler(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("retry after stripe refunded books the ledger", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":{"code":"charge_already_refunded","message":"Charge has already been refunded.","type":"invalid_request_error"}}`))
+		}))
+		defer srv.Close()
+		stripe.SetBackend(stripe.APIBackend, stripe.GetBackendWithConfig(stripe.APIBackend, &stripe.BackendConfig{URL: stripe.String(srv.URL)}))
+		defer stripe.SetBackend(stripe.APIBackend, nil)
+
+		base, mock := setupLedgerBase(t)
+		mock.ExpectPrepare("SELECT transaction_id").ExpectQuery().WithArgs("pi_1").
+			WillReturnRows(sqlmock.NewRows(trxColumns).AddRow(1, 5, 10, "o", "pi_1", "r", 10000, "KES", entities.TransactionStatusPaid, now, now))
+		mock.ExpectPrepare("SELECT room_id, cost, currency").ExpectQuery().WithArgs(10).
+			WillReturnRows(sqlmock.NewRows([]string{"room_id", "cost", "currency", "status", "vender_id", "created_at", "updated_at"}).
+				AddRow("10", 10000, "KES", "BOOKED", "7", now, now))
+		mock.ExpectPrepare("SELECT entry_id").ExpectQuery().WithArgs("pi_1", "PAYMENT").
+			WillReturnRows(sqlmock.NewRows([]string{"entry_id", "reference", "vendor_id", "account", "direction", "amount", "currency", "kind", "created_at"}).
+				AddRow(1, "pi_1", 7, "CASH", "DEBIT", 10000, "KES", "PAYMENT", now).
+				AddRow(2, "pi_1", 7, "VENDOR_PAYABLE", "CREDIT", 10000, "KES", "PAYMENT", now))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE transaction SET status").
+			WithArgs(entities.TransactionStatusRefunded, "pi_1", entities.TransactionStatusPaid).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		prep := mock.ExpectPrepare("INSERT INTO ledger_entry")
+		prep.ExpectExec().WillReturnResult(sqlmock.NewResult(3, 1))
+		prep.ExpectExec().WillReturnResult(sqlmock.NewResult(4, 1))
+		mock.ExpectCommit()
+		mock.ExpectPrepare("INSERT INTO audit_log").ExpectExec().WillReturnResult(sqlmock.NewResult(1, 1))
+
+		req := httptest.NewRequest(http.MethodPost, "/admin/transactions/pi_1/refund", nil)
+		req = withURLParam(req, "trx_id", "pi_1")
+		req = withUserID(req, "7")
+		w := httptest.NewRecorder()
+
+		base.RefundTransactionHandler(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+)
+
+// shutdownTimeout bounds how long in-flight requests, queue messages and
+// producer flushes may take once shutdown has started.
+var shutdownTimeout = shutdownDrainTimeout()
+
+// shutdownDrainTimeout reads SHUTDOWN_TIMEOUT; defaults to 15s.
+func shutdownDrainTimeout() time.Duration {
+	if v := os.Getenv("SHUTDOWN_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+	}
+	return 15 * time.Second
+}
+
+// serve runs srv until ctx is cancelled, then stops accepting connections and
+// waits up to shutdownTimeout for in-flight requests to finish.
+func serve(ctx context.Context, srv *http.Server) error {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	err := srv.Shutdown(shutdownCtx)
+	if err != nil {
+		return err
+	}
+
+	err = <-errs
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// Close releases the connections opened by Init once the servers and
+// consumers have stopped: buffered Kafka messages are flushed first, then
+// the database, cache and broker connections are closed and the remaining
+// spans exported.
+func (b *Base) Close() {
+	if b.producer != nil {
+		remaining := b.producer.Close(shutdownTimeout)
+		if remaining > 0 {
+			slog.Warn("kafka producer closed with undelivered messages", "count", remaining)
+		}
+	}
+
+	if b.KafkaConsumer != nil {
+		err := b.KafkaConsumer.Close()
+		if err != nil {
+			slog.Error("closing kafka consumer failed", "error", err)
+		}
+	}
+
+	if b.DB != nil {
+		err := b.DB.Close()
+		if err != nil {
+			slog.Error("closing mysql failed", "error", err)
+		}
+	}
+
+	if b.Redis != nil {
+		err := b.Redis.Close()
+		if err != nil {
+			slog.Error("closing redis failed", "error", err)
+		}
+	}
+
+	if b.rabbit != nil {
+		err := b.rabbit.Close()
+		if err != nil {
+			slog.Error("closing rabbitmq failed", "error", err)
+		}
+	}
+
+	b.FlushTraces()
+
+	slog.Info("shutdown complete")
+}
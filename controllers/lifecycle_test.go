@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/bicosteve/booking-system/pkg/producer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServe_DrainsInFlightRequests(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+
+	started := make(chan struct{})
+	srv := &http.Server{
+		Addr: addr,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			time.Sleep(100 * time.Millisecond)
+			io.WriteString(w, "done")
+		}),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- serve(ctx, srv) }()
+
+	responses := make(chan string, 1)
+	go func() {
+		var resp *http.Response
+		for i := 0; i < 50; i++ {
+			var getErr error
+			resp, getErr = http.Get("http://" + addr)
+			if getErr == nil {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if resp == nil {
+			responses <- ""
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		responses <- string(body)
+	}()
+
+	<-started
+	cancel()
+
+	assert.Equal(t, "done", <-responses)
+	assert.NoError(t, <-served)
+}
+
+func TestSchedulers_StopOnCancel(t *testing.T) {
+	b := &Base{payoutInterval: time.Hour, ctx: context.Background()}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go b.PayoutScheduler(ctx, &wg)
+	go b.StayCompletionScheduler(ctx, &wg)
+
+	stopped := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("schedulers did not stop after the context was cancelled")
+	}
+}
+
+func TestClose_ClosesDatabase(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	mock.ExpectClose()
+
+	b := &Base{DB: db}
+	b.Close()
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClose_ClosesProducer(t *testing.T) {
+	fake := producer.NewFake()
+
+	b := &Base{producer: fake}
+	b.Close()
+
+	assert.ErrorIs(t, fake.Publish(context.Background(), producer.Message{Topic: "payments"}), producer.ErrClosed)
+}
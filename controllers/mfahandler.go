@@ -0,0 +1,211 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/utils"
+)
+
+// mfaError writes err with the status matching it.
+func mfaError(w http.ResponseWriter, err error) {
+	var lockErr *entities.LockoutError
+
+	switch {
+	case errors.As(err, &lockErr):
+		utils.SetRetryAfter(w, lockErr.RetryAfter)
+		utils.ErrorJSON(w, err, http.StatusTooManyRequests)
+	case errors.Is(err, entities.ErrInvalidMFACode), errors.Is(err, entities.ErrSessionRevoked):
+		utils.ErrorJSON(w, err, http.StatusUnauthorized)
+	case errors.Is(err, entities.ErrMFARequired):
+		utils.ErrorJSON(w, err, http.StatusForbidden)
+	case errors.Is(err, entities.ErrMFAEnabled), errors.Is(err, entities.ErrMFANotEnrolled):
+		utils.ErrorJSON(w, err, http.StatusConflict)
+	default:
+		utils.ErrorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// userFromContext rebuilds the logged-in user from the auth token's claims.
+func userFromContext(ctx context.Context) (entities.User, bool) {
+	id, ok := ctx.Value(entities.UseridKeyValue).(string)
+	if !ok {
+		return entities.User{}, false
+	}
+
+	email, _ := ctx.Value(entities.UsernameKeyValue).(string)
+	isVendor, _ := ctx.Value(entities.IsVendorKeyValue).(string)
+	phone, _ := ctx.Value(entities.PhoneNumberKeyValue).(string)
+
+	return entities.User{ID: id, Email: email, IsVender: isVendor, PhoneNumber: phone}, true
+}
+
+// Enroll MFA godoc
+// @Summary start setting up two-factor authentication
+// @Description Creates a TOTP secret for the user. Show uri as a QR code for an authenticator app, then confirm with a code from it. Calling it again before confirming replaces the secret.
+// @ID enroll-mfa
+// @Tags auth
+// @Produce json
+// @Success 200 {object} entities.MFAEnrolment "Secret and provisioning URI"
+// @Failure 401 {object} entities.JSONResponse "Unauthorized"
+// @Failure 409 {object} entities.JSONResponse "Two-factor already enabled"
+// @Router /api/user/mfa/enroll [post]
+func (b *Base) EnrollMFAHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", b.contentType)
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	user, ok := userFromContext(r.Context())
+	if !ok {
+		slog.ErrorContext(r.Context(), "could not get user_id from context")
+		utils.ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
+		return
+	}
+	userID, _ := strconv.Atoi(user.ID)
+
+	enrolment, err := b.userService.EnrollMFA(ctx, userID, user.Email)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "enroll mfa failed", "error", err)
+		mfaError(w, err)
+		return
+	}
+
+	_ = utils.DeserializeJSON(w, http.StatusOK, map[string]any{"msg": "scan the uri as a QR code, then confirm with a code", "data": enrolment})
+}
+
+// Confirm MFA godoc
+// @Summary turn on two-factor authentication
+// @Description Confirms enrolment with a code from the authenticator app. Returns recovery codes, shown only this once, and a new token that includes the second factor.
+// @ID confirm-mfa
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param payload body entities.MFAPayload true "{"code":"123456"}"
+// @Success 200 {object} entities.JSONResponse "Recovery codes and token"
+// @Failure 401 {object} entities.JSONResponse "Invalid code"
+// @Failure 409 {object} entities.JSONResponse "Not enrolled or already enabled"
+// @Router /api/user/mfa/verify [post]
+func (b *Base) ConfirmMFAHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", b.contentType)
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	var payload entities.MFAPayload
+
+	err := utils.SerializeJSON(w, r, &payload)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "confirm mfa failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	user, ok := userFromContext(r.Context())
+	if !ok {
+		slog.ErrorContext(r.Context(), "could not get user_id from context")
+		utils.ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
+		return
+	}
+
+	codes, token, err := b.userService.ConfirmMFA(ctx, user, payload.Code, b.jwtKeys)
+	if err != nil {
+		slog.WarnContext(r.Context(), "confirm mfa failed", "error", err)
+		mfaError(w, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "mfa enabled", "user_id", user.ID)
+
+	_ = utils.DeserializeJSON(w, http.StatusOK, map[string]any{
+		"msg":  "two-factor authentication enabled, store the recovery codes safely",
+		"data": map[string]any{"recovery_codes": codes, "token": token},
+	})
+}
+
+// Disable MFA godoc
+// @Summary turn off two-factor authentication
+// @Description Removes the user's second factor after checking a code or recovery code. Vendors cannot turn it off.
+// @ID disable-mfa
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param payload body entities.MFAPayload true "{"code":"123456"}"
+// @Success 200 {object} entities.JSONResponse "Success"
+// @Failure 401 {object} entities.JSONResponse "Invalid code"
+// @Failure 403 {object} entities.JSONResponse "Vendors must keep two-factor on"
+// @Failure 409 {object} entities.JSONResponse "Two-factor not enabled"
+// @Router /api/user/mfa [delete]
+func (b *Base) DisableMFAHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", b.contentType)
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	var payload entities.MFAPayload
+
+	err := utils.SerializeJSON(w, r, &payload)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "disable mfa failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	user, ok := userFromContext(r.Context())
+	if !ok {
+		slog.ErrorContext(r.Context(), "could not get user_id from context")
+		utils.ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
+		return
+	}
+	userID, _ := strconv.Atoi(user.ID)
+
+	err = b.userService.DisableMFA(ctx, userID, user.IsVender, payload)
+	if err != nil {
+		slog.WarnContext(r.Context(), "disable mfa failed", "error", err)
+		mfaError(w, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "mfa disabled", "user_id", user.ID)
+
+	_ = utils.DeserializeJSON(w, http.StatusOK, map[string]any{"msg": "two-factor authentication disabled"})
+}
+
+// MFA login godoc
+// @Summary finish logging in with a second factor
+// @Description Trades the mfa_token from login and a code from the authenticator app, or a recovery code, for an auth token
+// @ID login-mfa
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param payload body entities.MFAPayload true "{"mfa_token":"...","code":"123456"}"
+// @Success 200 {object} entities.JSONResponse "Token"
+// @Failure 401 {object} entities.JSONResponse "Invalid code"
+// @Failure 403 {object} entities.JSONResponse "Invalid or expired mfa token"
+// @Failure 429 {object} entities.JSONResponse "Too many attempts or account locked; see Retry-After"
+// @Router /api/user/login/mfa [post]
+func (b *Base) MFALoginHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", b.contentType)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var payload entities.MFAPayload
+
+	err := utils.SerializeJSON(w, r, &payload)
+	if err != nil {
+		slog.WarnContext(r.Context(), "mfa login failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	token, err := b.userService.CompleteMFALogin(ctx, payload, b.jwtKeys)
+	if err != nil {
+		slog.WarnContext(r.Context(), "mfa login failed", "error", err)
+		mfaError(w, err)
+		return
+	}
+
+	_ = utils.DeserializeJSON(w, http.StatusOK, map[string]string{"token": token})
+}
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+func withUser(r *http.Request, id, email, isVendor string) *http.Request {
+	ctx := context.WithValue(r.Context(), entities.UseridKeyValue, id)
+	ctx = context.WithValue(ctx, entities.UsernameKeyValue, email)
+	ctx = context.WithValue(ctx, entities.IsVendorKeyValue, isVendor)
+	return r.WithContext(ctx)
+}
+
+func TestEnrollMFAHandler(t *testing.T) {
+	base, mock := setupTestBase()
+
+	mock.ExpectPrepare("SELECT user_id, secret, enabled_at, last_step FROM user_mfa WHERE user_id = ?").
+		ExpectQuery().WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "secret", "enabled_at", "last_step"}))
+	mock.ExpectPrepare(`INSERT INTO user_mfa(user_id, secret, created_at) VALUES (?, ?, NOW())
+		ON DUPLICATE KEY UPDATE
+			secret = IF(enabled_at IS NULL, VALUES(secret), secret),
+			created_at = IF(enabled_at IS NULL, NOW(), created_at)`).
+		ExpectExec().WithArgs(5, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req := withUser(httptest.NewRequest(http.MethodPost, "/user/mfa/enroll", nil), "5", "vendor@gmail.com", "YES")
+	w := httptest.NewRecorder()
+	base.EnrollMFAHandler(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Data entities.MFAEnrolment `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.NotEmpty(t, response.Data.Secret)
+	assert.Contains(t, response.Data.URI, "otpauth://totp/")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDisableMFAHandler_Vendor(t *testing.T) {
+	base, mock := setupTestBase()
+
+	req := withUser(httptest.NewRequest(http.MethodDelete, "/user/mfa", bytes.NewBufferString(`{"code":"123456"}`)), "5", "vendor@gmail.com", "YES")
+	w := httptest.NewRecorder()
+	base.DisableMFAHandler(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMFALoginHandler_InvalidToken(t *testing.T) {
+	base, _ := setupTestBase()
+
+	req := httptest.NewRequest(http.MethodPost, "/user/login/mfa", bytes.NewBufferString(`{"mfa_token":"not.a.token","code":"123456"}`))
+	w := httptest.NewRecorder()
+	base.MFALoginHandler(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
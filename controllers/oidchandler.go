@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/oidc"
+	"github.com/bicosteve/booking-system/pkg/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+// oidcError writes err with the status matching it.
+func oidcError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, oidc.ErrUnknownProvider):
+		utils.ErrorJSON(w, errors.New("unknown login provider"), http.StatusNotFound)
+	case errors.Is(err, entities.ErrOIDCState):
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+	case errors.Is(err, entities.ErrOIDCLogin):
+		utils.ErrorJSON(w, entities.ErrOIDCLogin, http.StatusUnauthorized)
+	case errors.Is(err, entities.ErrEmailNotVerified):
+		utils.ErrorJSON(w, err, http.StatusForbidden)
+	case errors.Is(err, entities.ErrNoAccount):
+		utils.ErrorJSON(w, err, http.StatusNotFound)
+	default:
+		utils.ErrorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// OIDC login godoc
+// @Summary log in with an external provider
+// @Description Redirects to the provider (google, or another configured OpenID Connect provider) to log in. The provider sends the user back to the callback.
+// @ID oidc-login
+// @Tags auth
+// @Param provider path string true "Provider name"
+// @Success 302 "Redirect to the provider"
+// @Failure 404 {object} entities.JSONResponse "Unknown provider"
+// @Router /api/user/oidc/{provider}/login [get]
+func (b *Base) OIDCLoginHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	authURL, err := b.oidcService.StartLogin(ctx, chi.URLParam(r, "provider"))
+	if err != nil {
+		w.Header().Set("Content-Type", b.contentType)
+		slog.ErrorContext(r.Context(), "oidc login failed", "error", err)
+		oidcError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// OIDC callback godoc
+// @Summary finish logging in with an external provider
+// @Description The provider redirects here after login. The first login links the provider account to the user with the same verified email. Returns a token like /api/user/login, or an mfa_token when two-factor is on.
+// @ID oidc-callback
+// @Tags auth
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Param state query string true "State from the login redirect"
+// @Param code query string true "Authorization code"
+// @Success 200 {object} APIResponse "{"token":"xxxxxxxxxxx"}"
+// @Failure 400 {object} entities.JSONResponse "Login expired, or refused at the provider"
+// @Failure 401 {object} entities.JSONResponse "Provider login could not be verified"
+// @Failure 403 {object} entities.JSONResponse "Email not verified by the provider"
+// @Failure 404 {object} entities.JSONResponse "No account with this email"
+// @Router /api/user/oidc/{provider}/callback [get]
+func (b *Base) OIDCCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", b.contentType)
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	q := r.URL.Query()
+	if q.Get("error") != "" {
+		slog.WarnContext(r.Context(), "oidc login refused by provider", "error", q.Get("error"))
+		utils.ErrorJSON(w, errors.New("login was refused at the provider"), http.StatusBadRequest)
+		return
+	}
+
+	token, mfaPending, err := b.oidcService.FinishLogin(ctx, chi.URLParam(r, "provider"), q.Get("state"), q.Get("code"), b.jwtKeys)
+	if err != nil {
+		slog.WarnContext(r.Context(), "oidc login failed", "error", err)
+		oidcError(w, err)
+		return
+	}
+
+	if mfaPending {
+		_ = utils.DeserializeJSON(w, http.StatusOK, map[string]any{"mfa_required": true, "mfa_token": token})
+		return
+	}
+
+	_ = utils.DeserializeJSON(w, http.StatusOK, map[string]string{"token": token})
+}
@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/jwtkeys"
+	"github.com/bicosteve/booking-system/pkg/oidc/oidctest"
+	"github.com/bicosteve/booking-system/repo"
+	"github.com/bicosteve/booking-system/service"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupOIDCBase(t *testing.T) (*Base, sqlmock.Sqlmock, *oidctest.Issuer) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	iss := oidctest.NewIssuer(t, "client-1")
+	repository := *repo.NewDBRepository(db, client)
+	users := service.NewUserService(repository, entities.LockoutConfig{})
+
+	base := &Base{
+		userService: users,
+		oidcService: service.NewOIDCService(repository, users, []entities.OIDCConfig{
+			{Name: "test", Issuer: iss.URL, ClientID: "client-1", RedirectURL: "http://localhost/api/user/oidc/test/callback"},
+		}),
+		contentType: "application/json",
+		jwtKeys:     jwtkeys.NewHMAC("test-secret"),
+	}
+
+	return base, mock, iss
+}
+
+func oidcRequest(target, provider string) *http.Request {
+	return withURLParam(httptest.NewRequest(http.MethodGet, target, nil), "provider", provider)
+}
+
+func TestOIDCHandlers(t *testing.T) {
+	base, mock, iss := setupOIDCBase(t)
+	now := time.Now()
+
+	w := httptest.NewRecorder()
+	base.OIDCLoginHandler(w, oidcRequest("/api/user/oidc/test/login", "test"))
+	assert.Equal(t, http.StatusFound, w.Code)
+
+	code, state := iss.Authorize(t, w.Header().Get("Location"), oidctest.User{Subject: "sub-1", Email: "test@gmail.com", EmailVerified: true})
+
+	mock.ExpectPrepare(`SELECT u.email FROM user_identity i JOIN user u ON u.user_id = i.user_id
+		WHERE i.provider = ? AND i.subject = ?`).
+		ExpectQuery().WithArgs("test", "sub-1").
+		WillReturnRows(sqlmock.NewRows([]string{"email"}).AddRow("test@gmail.com"))
+	mock.ExpectPrepare("SELECT user_id, email, phone_number, isVender, hashed_password, password_reset_token, created_at, updated_at, password_inserted_at, name, phone_verified_at FROM user WHERE email = ?").
+		ExpectQuery().WithArgs("test@gmail.com").
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "email", "phone_number", "isVender",
+			"password", "password_reset_token",
+			"created_at", "updated_at", "password_inserted_at",
+			"name", "phone_verified_at",
+		}).AddRow("3", "test@gmail.com", "0704961755", "NO", "hash", "", now, now, now, "", nil))
+	mock.ExpectPrepare("SELECT user_id, secret, enabled_at, last_step FROM user_mfa WHERE user_id = ?").
+		ExpectQuery().WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "secret", "enabled_at", "last_step"}))
+
+	w = httptest.NewRecorder()
+	base.OIDCCallbackHandler(w, oidcRequest("/api/user/oidc/test/callback?state="+state+"&code="+code, "test"))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]string
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.NotEmpty(t, response["token"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// The same callback again is refused.
+	w = httptest.NewRecorder()
+	base.OIDCCallbackHandler(w, oidcRequest("/api/user/oidc/test/callback?state="+state+"&code="+code, "test"))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestOIDCHandlers_Errors(t *testing.T) {
+	base, _, _ := setupOIDCBase(t)
+
+	w := httptest.NewRecorder()
+	base.OIDCLoginHandler(w, oidcRequest("/api/user/oidc/facebook/login", "facebook"))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	base.OIDCCallbackHandler(w, oidcRequest("/api/user/oidc/test/callback?error=access_denied", "test"))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
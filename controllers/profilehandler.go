@@ -0,0 +1,477 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/utils"
+)
+
+// profileError writes err with the status matching it.
+func profileError(w http.ResponseWriter, err error) {
+	var lockErr *entities.LockoutError
+
+	switch {
+	case errors.As(err, &lockErr):
+		utils.SetRetryAfter(w, lockErr.RetryAfter)
+		utils.ErrorJSON(w, err, http.StatusTooManyRequests)
+	case errors.Is(err, entities.ErrWrongPassword), errors.Is(err, entities.ErrInvalidCode):
+		utils.ErrorJSON(w, err, http.StatusUnauthorized)
+	case errors.Is(err, entities.ErrInvalidProfile), errors.Is(err, entities.ErrNoPendingChange):
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+	case errors.Is(err, entities.ErrVendorDeletion):
+		utils.ErrorJSON(w, err, http.StatusForbidden)
+	case errors.Is(err, entities.ErrNoRecord):
+		utils.ErrorJSON(w, errors.New("user not found"), http.StatusNotFound)
+	case errors.Is(err, entities.ErrDuplicateEmail), errors.Is(err, entities.ErrDuplicatePhone),
+		errors.Is(err, entities.ErrAccountInUse), errors.Is(err, entities.ErrPhoneVerified):
+		utils.ErrorJSON(w, err, http.StatusConflict)
+	default:
+		utils.ErrorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// sessionFromContext returns the logged-in user's id and whether their
+// token passed a second factor.
+func sessionFromContext(ctx context.Context) (int, bool, bool) {
+	id, ok := ctx.Value(entities.UseridKeyValue).(string)
+	if !ok {
+		return 0, false, false
+	}
+
+	userID, err := strconv.Atoi(id)
+	if err != nil {
+		return 0, false, false
+	}
+
+	mfa, _ := ctx.Value(entities.MFAKeyValue).(bool)
+
+	return userID, mfa, true
+}
+
+// sendMail mails text with SendGrid, or with b.mailer in tests.
+func (b *Base) sendMail(to, subject, text string) error {
+	if b.mailer != nil {
+		return b.mailer(to, subject, text)
+	}
+
+	_, err := utils.SendMailText(b.sengridkey, b.mailfrom, subject, to, text)
+	return err
+}
+
+// sendSMS texts a phone number with Africa's Talking, or with b.texter in
+// tests.
+func (b *Base) sendSMS(phone, text string) error {
+	if b.texter != nil {
+		return b.texter(phone, text)
+	}
+
+	_, err := utils.SendSMS(b.atklng, b.appusername, phone, text)
+	return err
+}
+
+// Update profile godoc
+// @Summary update the logged in user's profile
+// @Description Changes the name right away. A new phone number is sent a six digit code and replaces the old one once the code is confirmed at /api/user/me/phone/verify within 10 minutes.
+// @ID update-profile
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param payload body entities.ProfilePayload true "{"name":"Jane Wanjiku","phone_number":"+254712345678"}"
+// @Success 200 {object} entities.JSONResponse "Updated profile"
+// @Failure 400 {object} entities.JSONResponse "Invalid name or phone number"
+// @Failure 401 {object} entities.JSONResponse "Unauthorized"
+// @Router /api/user/me [patch]
+func (b *Base) UpdateProfileHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", b.contentType)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var payload entities.ProfilePayload
+
+	err := utils.SerializeJSON(w, r, &payload)
+	if err != nil {
+		slog.WarnContext(r.Context(), "update profile failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	err = utils.ValidateProfile(&payload, b.phoneRegion)
+	if err != nil {
+		slog.WarnContext(r.Context(), "update profile failed", "error", err)
+		profileError(w, err)
+		return
+	}
+
+	userID, _, ok := sessionFromContext(r.Context())
+	if !ok {
+		slog.ErrorContext(r.Context(), "could not get user_id from context")
+		utils.ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
+		return
+	}
+
+	user, code, err := b.userService.UpdateProfile(ctx, userID, payload)
+	if err != nil {
+		slog.WarnContext(r.Context(), "update profile failed", "error", err)
+		profileError(w, err)
+		return
+	}
+
+	msg := "profile updated"
+	if code != "" {
+		err = b.sendSMS(*payload.PhoneNumber, fmt.Sprintf("Your Booking System confirmation code is %s. It expires in 10 minutes.", code))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "sending phone confirmation failed", "error", err)
+			utils.ErrorJSON(w, errors.New("could not send the confirmation code, try again"), http.StatusBadGateway)
+			return
+		}
+		msg = "profile updated, confirm the code sent to your new phone number"
+	}
+
+	slog.InfoContext(r.Context(), "profile updated", "user_id", userID, "phone_pending", code != "")
+
+	_ = utils.DeserializeJSON(w, http.StatusOK, map[string]any{
+		"msg":  msg,
+		"data": map[string]any{"user": user, "phone_verification_required": code != ""},
+	})
+}
+
+// Send phone code godoc
+// @Summary text a code to confirm the current phone number
+// @Description Texts a six digit code to the phone number given at registration, to be confirmed at /api/user/me/phone/verify within 10 minutes.
+// @ID send-phone-code
+// @Tags users
+// @Produce json
+// @Success 202 {object} entities.JSONResponse "Code sent"
+// @Failure 401 {object} entities.JSONResponse "Unauthorized"
+// @Failure 409 {object} entities.JSONResponse "Phone number already verified"
+// @Failure 502 {object} entities.JSONResponse "Could not send the code"
+// @Router /api/user/me/phone/code [post]
+func (b *Base) SendPhoneCodeHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", b.contentType)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	userID, _, ok := sessionFromContext(r.Context())
+	if !ok {
+		slog.ErrorContext(r.Context(), "could not get user_id from context")
+		utils.ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
+		return
+	}
+
+	number, code, err := b.userService.RequestPhoneVerification(ctx, userID)
+	if err != nil {
+		slog.WarnContext(r.Context(), "send phone code failed", "error", err)
+		profileError(w, err)
+		return
+	}
+
+	err = b.sendSMS(number, fmt.Sprintf("Your Booking System confirmation code is %s. It expires in 10 minutes.", code))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "sending phone confirmation failed", "error", err)
+		utils.ErrorJSON(w, errors.New("could not send the confirmation code, try again"), http.StatusBadGateway)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "phone code sent", "user_id", userID)
+
+	_ = utils.DeserializeJSON(w, http.StatusAccepted, map[string]any{"msg": "confirm the code sent to your phone number"})
+}
+
+// Verify phone godoc
+// @Summary confirm a phone number
+// @Description Confirms the phone number given at PATCH /api/user/me, or the current one after /api/user/me/phone/code, once the code sent to it matches. Returns a new token carrying the number. After 5 wrong codes a new code has to be asked for.
+// @ID verify-phone
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param payload body object true "{"code":"123456"}"
+// @Success 200 {object} entities.JSONResponse "Token"
+// @Failure 400 {object} entities.JSONResponse "No change waiting"
+// @Failure 401 {object} entities.JSONResponse "Invalid code"
+// @Failure 409 {object} entities.JSONResponse "Number used by another account"
+// @Router /api/user/me/phone/verify [post]
+func (b *Base) VerifyPhoneHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", b.contentType)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var payload struct {
+		Code string `json:"code"`
+	}
+
+	err := utils.SerializeJSON(w, r, &payload)
+	if err != nil {
+		slog.WarnContext(r.Context(), "verify phone failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	userID, mfa, ok := sessionFromContext(r.Context())
+	if !ok {
+		slog.ErrorContext(r.Context(), "could not get user_id from context")
+		utils.ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
+		return
+	}
+
+	token, err := b.userService.ConfirmPhoneChange(ctx, userID, payload.Code, mfa, b.jwtKeys)
+	if err != nil {
+		slog.WarnContext(r.Context(), "verify phone failed", "error", err)
+		profileError(w, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "phone number verified", "user_id", userID)
+
+	_ = utils.DeserializeJSON(w, http.StatusOK, map[string]any{"msg": "phone number verified", "data": map[string]string{"token": token}})
+}
+
+// Change email godoc
+// @Summary start moving the account to a new email
+// @Description Checks the password and mails a confirmation token to the new address, valid for 24 hours. The email changes once the token is posted to /api/user/me/email/confirm. The current address is told about the request.
+// @ID change-email
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param payload body entities.EmailChangePayload true "{"email":"new@gmail.com","password":"..."}"
+// @Success 202 {object} entities.JSONResponse "Confirmation sent"
+// @Failure 400 {object} entities.JSONResponse "Invalid email"
+// @Failure 401 {object} entities.JSONResponse "Wrong password"
+// @Failure 409 {object} entities.JSONResponse "Email used by another account"
+// @Failure 429 {object} entities.JSONResponse "Too many wrong passwords; see Retry-After"
+// @Router /api/user/me/email [post]
+func (b *Base) ChangeEmailHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", b.contentType)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var payload entities.EmailChangePayload
+
+	err := utils.SerializeJSON(w, r, &payload)
+	if err != nil {
+		slog.WarnContext(r.Context(), "change email failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	userID, _, ok := sessionFromContext(r.Context())
+	if !ok {
+		slog.ErrorContext(r.Context(), "could not get user_id from context")
+		utils.ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
+		return
+	}
+
+	token, err := b.userService.RequestEmailChange(ctx, userID, payload)
+	if err != nil {
+		slog.WarnContext(r.Context(), "change email failed", "error", err)
+		profileError(w, err)
+		return
+	}
+
+	err = b.sendMail(payload.Email, "Confirm your new email",
+		fmt.Sprintf("Your Booking System email change token is %s. It expires in 24 hours.", token))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "sending email confirmation failed", "error", err)
+		utils.ErrorJSON(w, errors.New("could not send the confirmation email, try again"), http.StatusBadGateway)
+		return
+	}
+
+	// Best effort: the old address learns of the change in case the
+	// session was stolen.
+	current, _ := r.Context().Value(entities.UsernameKeyValue).(string)
+	if current != "" {
+		err = b.sendMail(current, "Email change requested",
+			"A change of your Booking System email was requested. If it was not you, change your password now.")
+		if err != nil {
+			slog.WarnContext(r.Context(), "sending email change notice failed", "error", err)
+		}
+	}
+
+	slog.InfoContext(r.Context(), "email change requested", "user_id", userID)
+
+	_ = utils.DeserializeJSON(w, http.StatusAccepted, map[string]any{"msg": "confirm the token sent to your new email"})
+}
+
+// Confirm email godoc
+// @Summary finish moving the account to a new email
+// @Description Takes the token mailed by /api/user/me/email and changes the account's email. Returns a new token for the new address.
+// @ID confirm-email
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param payload body object true "{"token":"..."}"
+// @Success 200 {object} entities.JSONResponse "Token"
+// @Failure 400 {object} entities.JSONResponse "Unknown or expired token"
+// @Failure 409 {object} entities.JSONResponse "Email used by another account"
+// @Router /api/user/me/email/confirm [post]
+func (b *Base) ConfirmEmailHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", b.contentType)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var payload struct {
+		Token string `json:"token"`
+	}
+
+	err := utils.SerializeJSON(w, r, &payload)
+	if err != nil {
+		slog.WarnContext(r.Context(), "confirm email failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	userID, mfa, ok := sessionFromContext(r.Context())
+	if !ok {
+		slog.ErrorContext(r.Context(), "could not get user_id from context")
+		utils.ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
+		return
+	}
+
+	token, err := b.userService.ConfirmEmailChange(ctx, userID, payload.Token, mfa, b.jwtKeys)
+	if err != nil {
+		slog.WarnContext(r.Context(), "confirm email failed", "error", err)
+		profileError(w, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "email changed", "user_id", userID)
+
+	_ = utils.DeserializeJSON(w, http.StatusOK, map[string]any{"msg": "email changed", "data": map[string]string{"token": token}})
+}
+
+// Change password godoc
+// @Summary change the password of the logged in user
+// @Description Sets a new password after checking the current one and signs out every session, this one included. Wrong current passwords count towards the login lockout.
+// @ID change-password
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param payload body entities.PasswordChangePayload true "Current and new password"
+// @Success 200 {object} entities.JSONResponse "Success"
+// @Failure 400 {object} entities.JSONResponse "Missing or mismatched passwords"
+// @Failure 401 {object} entities.JSONResponse "Wrong password"
+// @Failure 429 {object} entities.JSONResponse "Too many wrong passwords; see Retry-After"
+// @Router /api/user/me/password [put]
+func (b *Base) ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", b.contentType)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var payload entities.PasswordChangePayload
+
+	err := utils.SerializeJSON(w, r, &payload)
+	if err != nil {
+		slog.WarnContext(r.Context(), "change password failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	userID, _, ok := sessionFromContext(r.Context())
+	if !ok {
+		slog.ErrorContext(r.Context(), "could not get user_id from context")
+		utils.ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
+		return
+	}
+
+	err = b.userService.ChangePassword(ctx, userID, payload)
+	if err != nil {
+		slog.WarnContext(r.Context(), "change password failed", "error", err)
+		profileError(w, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "password changed", "user_id", userID)
+
+	_ = utils.DeserializeJSON(w, http.StatusOK, map[string]any{"msg": "password changed, log in again"})
+}
+
+// Delete account godoc
+// @Summary delete the logged in user's account
+// @Description Checks the password, then anonymizes the account: email, phone number, name and password are replaced, and second factors, linked logins and queued messages are removed. Bookings, transactions and reviews are kept for accounting without the personal data. Guests with confirmed or checked in stays must finish or cancel them first; vendor accounts are closed by support.
+// @ID delete-account
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param payload body object true "{"password":"..."}"
+// @Success 200 {object} entities.JSONResponse "Success"
+// @Failure 401 {object} entities.JSONResponse "Wrong password"
+// @Failure 403 {object} entities.JSONResponse "Vendor account"
+// @Failure 409 {object} entities.JSONResponse "Stays in progress"
+// @Failure 429 {object} entities.JSONResponse "Too many wrong passwords; see Retry-After"
+// @Router /api/user/me [delete]
+func (b *Base) DeleteAccountHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", b.contentType)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var payload struct {
+		Password string `json:"password"`
+	}
+
+	err := utils.SerializeJSON(w, r, &payload)
+	if err != nil {
+		slog.WarnContext(r.Context(), "delete account failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	userID, _, ok := sessionFromContext(r.Context())
+	if !ok {
+		slog.ErrorContext(r.Context(), "could not get user_id from context")
+		utils.ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
+		return
+	}
+
+	err = b.userService.DeleteAccount(ctx, userID, payload.Password)
+	if err != nil {
+		slog.WarnContext(r.Context(), "delete account failed", "error", err)
+		profileError(w, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "account deleted", "user_id", userID)
+
+	_ = utils.DeserializeJSON(w, http.StatusOK, map[string]any{"msg": "account deleted"})
+}
+
+// Export personal data godoc
+// @Summary download everything stored about the logged in user
+// @Description Returns the profile, linked logins, bookings, reviews, transactions, queued messages and, for vendors, API keys as a JSON file, for access requests under Kenya's Data Protection Act.
+// @ID export-personal-data
+// @Tags users
+// @Produce json
+// @Success 200 {object} entities.PersonalDataExport "Personal data"
+// @Failure 401 {object} entities.JSONResponse "Unauthorized"
+// @Router /api/user/me/export [get]
+func (b *Base) ExportPersonalDataHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	userID, _, ok := sessionFromContext(r.Context())
+	if !ok {
+		slog.ErrorContext(r.Context(), "could not get user_id from context")
+		utils.ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
+		return
+	}
+
+	export, err := b.userService.ExportPersonalData(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "export personal data failed", "error", err)
+		profileError(w, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "personal data exported", "user_id", userID)
+
+	headers := http.Header{}
+	headers.Set("Content-Disposition", `attachment; filename="personal-data.json"`)
+	headers.Set("Cache-Control", "no-store")
+
+	_ = utils.DeserializeJSON(w, http.StatusOK, export, headers)
+}
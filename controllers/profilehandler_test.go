@@ -0,0 +1,182 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/jwtkeys"
+	"github.com/bicosteve/booking-system/repo"
+	"github.com/bicosteve/booking-system/service"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+var userColumns = []string{"user_id", "email", "phone_number", "isVender", "hashed_password", "password_reset_token",
+	"created_at", "updated_at", "password_inserted_at", "name", "phone_verified_at"}
+
+// sentMessage is a mail or text message sent by a handler under test.
+type sentMessage struct {
+	to, text string
+}
+
+func setupProfileBase(t *testing.T) (*Base, sqlmock.Sqlmock, *[]sentMessage) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	sent := &[]sentMessage{}
+	base := &Base{
+		userService: service.NewUserService(*repo.NewDBRepository(db, client), entities.LockoutConfig{}),
+		contentType: "application/json",
+		path:        "/api",
+		jwtKeys:     jwtkeys.NewHMAC("test-secret"),
+		mailer: func(to, subject, text string) error {
+			*sent = append(*sent, sentMessage{to, text})
+			return nil
+		},
+		texter: func(phone, text string) error {
+			*sent = append(*sent, sentMessage{phone, text})
+			return nil
+		},
+	}
+
+	return base, mock, sent
+}
+
+func expectUser(mock sqlmock.Sqlmock, userID int) {
+	now := time.Now()
+	mock.ExpectPrepare("SELECT user_id, email").ExpectQuery().WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow(userID, "guest@gmail.com", "+254704961755", "NO",
+			"$2a$10$/r5qIMP1AkNOMdr495Ff0eCdrZWyW79Q5E3RxFVgCbk0ret4j4mDa", "", now, now, now, "", nil))
+}
+
+func TestUpdateProfileHandler(t *testing.T) {
+	base, mock, sent := setupProfileBase(t)
+	expectUser(mock, 7)
+
+	req := httptest.NewRequest(http.MethodPatch, "/api/user/me", strings.NewReader(`{"phone_number":"0712345678"}`))
+	w := httptest.NewRecorder()
+
+	base.UpdateProfileHandler(w, withUserID(req, "7"))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"phone_verification_required": true`)
+
+	// The code goes to the new number, not the old one, in E.164 form.
+	if assert.Len(t, *sent, 1) {
+		assert.Equal(t, "+254712345678", (*sent)[0].to)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateProfileHandler_InvalidPhone(t *testing.T) {
+	base, mock, sent := setupProfileBase(t)
+
+	req := httptest.NewRequest(http.MethodPatch, "/api/user/me", strings.NewReader(`{"phone_number":"0712"}`))
+	w := httptest.NewRecorder()
+
+	base.UpdateProfileHandler(w, withUserID(req, "7"))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Empty(t, *sent)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSendPhoneCodeHandler(t *testing.T) {
+	base, mock, sent := setupProfileBase(t)
+	expectUser(mock, 7)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/user/me/phone/code", nil)
+	w := httptest.NewRecorder()
+
+	base.SendPhoneCodeHandler(w, withUserID(req, "7"))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	if assert.Len(t, *sent, 1) {
+		assert.Equal(t, "+254704961755", (*sent)[0].to)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestChangeEmailHandler(t *testing.T) {
+	base, mock, sent := setupProfileBase(t)
+	expectUser(mock, 7)
+	mock.ExpectPrepare("SELECT COUNT").ExpectQuery().WithArgs("new@gmail.com").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/user/me/email", strings.NewReader(`{"email":"new@gmail.com","password":"1234"}`))
+	w := httptest.NewRecorder()
+
+	base.ChangeEmailHandler(w, withUser(req, "7", "guest@gmail.com", "NO"))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	// The token goes to the new address and a notice to the old one.
+	if assert.Len(t, *sent, 2) {
+		assert.Equal(t, "new@gmail.com", (*sent)[0].to)
+		assert.Equal(t, "guest@gmail.com", (*sent)[1].to)
+		assert.NotContains(t, (*sent)[1].text, "token is")
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteAccountHandler(t *testing.T) {
+	base, mock, _ := setupProfileBase(t)
+	expectUser(mock, 7)
+	mock.ExpectPrepare("SELECT COUNT").ExpectQuery().WithArgs(7, entities.BookingStatusConfirmed, entities.BookingStatusCheckedIn).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/user/me", strings.NewReader(`{"password":"1234"}`))
+	w := httptest.NewRecorder()
+
+	base.DeleteAccountHandler(w, withUserID(req, "7"))
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestExportPersonalDataHandler(t *testing.T) {
+	base, mock, _ := setupProfileBase(t)
+	expectUser(mock, 7)
+	mock.ExpectPrepare("FROM user_mfa").ExpectQuery().WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "secret", "enabled_at", "last_step"}))
+	for _, table := range []string{"FROM user_identity", "FROM booking", "FROM review", "FROM transaction", "FROM sms_outbox"} {
+		mock.ExpectPrepare(table).ExpectQuery().WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/me/export", nil)
+	w := httptest.NewRecorder()
+
+	base.ExportPersonalDataHandler(w, withUserID(req, "7"))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "attachment")
+
+	var export entities.PersonalDataExport
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &export))
+	assert.Equal(t, "guest@gmail.com", export.Profile.Email)
+	assert.NotContains(t, w.Body.String(), "hashed_password")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestProfileRoutesNeedAuth(t *testing.T) {
+	base, _, _ := setupProfileBase(t)
+
+	for _, route := range []struct{ method, path string }{
+		{http.MethodPatch, "/api/user/me"},
+		{http.MethodDelete, "/api/user/me"},
+		{http.MethodPut, "/api/user/me/password"},
+		{http.MethodGet, "/api/user/me/export"},
+	} {
+		w := httptest.NewRecorder()
+		base.userRouter().ServeHTTP(w, httptest.NewRequest(route.method, route.path, nil))
+		assert.Equal(t, http.StatusUnauthorized, w.Code, route.method+" "+route.path)
+	}
+}
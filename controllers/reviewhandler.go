@@ -0,0 +1,287 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+// Create a review godoc
+// @Summary user reviews a stay
+// @Description Rates a checked out booking of the logged in user per category, with a written review. A booking can only be reviewed once.
+// @ID create-review
+// @Tags reviews
+// @Accept json
+// @Produce json
+// @Param  payload body entities.ReviewPayload true "Review"
+// @Success 201 {object} entities.Review "Created"
+// @Failure 400 {object} entities.JSONResponse "Bad request, validation error"
+// @Failure 401 {object} entities.JSONResponse "Unauthorized"
+// @Failure 403 {object} entities.JSONResponse "Booking not checked out"
+// @Failure 404 {object} entities.JSONResponse "Booking not found"
+// @Failure 409 {object} entities.JSONResponse "Booking already reviewed"
+// @Failure 500 {object} entities.JSONResponse "Internal server error"
+// @Router /api/user/reviews [post]
+func (b *Base) CreateReviewHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", b.contentType)
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	var payload = new(entities.ReviewPayload)
+
+	err := utils.SerializeJSON(w, r, payload)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "create review failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	err = utils.ValidateReview(payload)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "create review failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := r.Context().Value(entities.UseridKeyValue).(string)
+	if !ok {
+		slog.ErrorContext(r.Context(), "could not get user_id from context")
+		utils.ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
+		return
+	}
+	userid, _ := strconv.Atoi(userID)
+
+	review, err := b.reviewService.SubmitReview(ctx, userid, *payload)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "create review failed", "error", err)
+		switch {
+		case errors.Is(err, entities.ErrNoRecord):
+			utils.ErrorJSON(w, errors.New("booking not found"), http.StatusNotFound)
+		case errors.Is(err, entities.ErrReviewNotAllowed):
+			utils.ErrorJSON(w, err, http.StatusForbidden)
+		case errors.Is(err, entities.ErrReviewExists):
+			utils.ErrorJSON(w, err, http.StatusConflict)
+		default:
+			utils.ErrorJSON(w, err, http.StatusInternalServerError)
+		}
+		return
+	}
+
+	_ = utils.DeserializeJSON(w, http.StatusCreated, map[string]any{"msg": "review created", "data": review})
+}
+
+// Room reviews godoc
+// @Summary list reviews of a room
+// @Description Returns the visible reviews of a room, newest first. Flagged reviews are left out.
+// @ID room-reviews
+// @Tags reviews
+// @Produce json
+// @Param room_id path string true "Room ID"
+// @Success 200 {array} entities.Review "Success"
+// @Failure 400 {object} entities.JSONResponse "Bad request"
+// @Failure 500 {object} entities.JSONResponse "Internal server error"
+// @Router /api/user/rooms/{room_id}/reviews [get]
+// @Security []
+func (b *Base) GetRoomReviewsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", b.contentType)
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	roomID, err := strconv.Atoi(chi.URLParam(r, "room_id"))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "get room reviews failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	reviews, err := b.reviewService.GetRoomReviews(ctx, roomID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "get room reviews failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	_ = utils.DeserializeJSON(w, http.StatusOK, map[string]any{"data": reviews})
+}
+
+// Vendor rating godoc
+// @Summary get a vendor's rating
+// @Description Returns the overall and per category rating across all visible reviews of a vendor's rooms
+// @ID vendor-rating
+// @Tags reviews
+// @Produce json
+// @Param vendor_id path string true "Vendor ID"
+// @Success 200 {object} entities.RatingSummary "Success"
+// @Failure 400 {object} entities.JSONResponse "Bad request"
+// @Failure 500 {object} entities.JSONResponse "Internal server error"
+// @Router /api/user/vendors/{vendor_id}/rating [get]
+// @Security []
+func (b *Base) GetVendorRatingHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", b.contentType)
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	vendorID, err := strconv.Atoi(chi.URLParam(r, "vendor_id"))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "get vendor rating failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	summary, err := b.reviewService.GetVendorRating(ctx, vendorID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "get vendor rating failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	_ = utils.DeserializeJSON(w, http.StatusOK, map[string]any{"data": summary})
+}
+
+// Vendor reviews godoc
+// @Summary list reviews of the vendor's rooms
+// @Description Returns every review on the logged in vendor's rooms, flagged ones included, for moderation
+// @ID vendor-reviews
+// @Tags reviews
+// @Produce json
+// @Success 200 {array} entities.Review "Success"
+// @Failure 401 {object} entities.JSONResponse "Unauthorized"
+// @Failure 500 {object} entities.JSONResponse "Internal server error"
+// @Router /api/admin/reviews [get]
+func (b *Base) GetVendorReviewsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", b.contentType)
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	userID, ok := r.Context().Value(entities.UseridKeyValue).(string)
+	if !ok {
+		slog.ErrorContext(r.Context(), "could not get user_id from context")
+		utils.ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
+		return
+	}
+	vendorID, _ := strconv.Atoi(userID)
+
+	reviews, err := b.reviewService.GetVendorReviews(ctx, vendorID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "get vendor reviews failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	_ = utils.DeserializeJSON(w, http.StatusOK, map[string]any{"data": reviews})
+}
+
+// Reply to review godoc
+// @Summary vendor replies to a review
+// @Description Sets the vendor's public reply on a review of one of their rooms, replacing any earlier reply
+// @ID reply-review
+// @Tags reviews
+// @Accept json
+// @Produce json
+// @Param review_id path string true "Review ID"
+// @Param payload body object true "{"reply":"Thank you for staying with us"}"
+// @Success 200 {object} entities.JSONResponse "Success"
+// @Failure 400 {object} entities.JSONResponse "Bad request"
+// @Failure 401 {object} entities.JSONResponse "Unauthorized"
+// @Router /api/admin/reviews/{review_id}/reply [put]
+func (b *Base) ReplyToReviewHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", b.contentType)
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	reviewID, err := strconv.Atoi(chi.URLParam(r, "review_id"))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "reply to review failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	var input struct {
+		Reply string `json:"reply"`
+	}
+
+	err = utils.SerializeJSON(w, r, &input)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "reply to review failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := r.Context().Value(entities.UseridKeyValue).(string)
+	if !ok {
+		slog.ErrorContext(r.Context(), "could not get user_id from context")
+		utils.ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
+		return
+	}
+	vendorID, _ := strconv.Atoi(userID)
+
+	err = b.reviewService.ReplyToReview(ctx, reviewID, vendorID, input.Reply)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "reply to review failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	_ = utils.DeserializeJSON(w, http.StatusOK, map[string]any{"msg": "reply saved"})
+}
+
+// Flag review godoc
+// @Summary flag or unflag a review
+// @Description Platform admins only. Flagged reviews are hidden from guests and left out of room and vendor ratings until unflagged. A reason is required to flag.
+// @ID flag-review
+// @Tags reviews
+// @Accept json
+// @Produce json
+// @Param review_id path string true "Review ID"
+// @Param payload body object true "{"flagged":true,"reason":"abusive language"}"
+// @Success 200 {object} entities.JSONResponse "Success"
+// @Failure 400 {object} entities.JSONResponse "Bad request"
+// @Failure 401 {object} entities.JSONResponse "Unauthorized"
+// @Failure 403 {object} entities.JSONResponse "Not a platform admin"
+// @Router /api/admin/reviews/{review_id}/flag [put]
+func (b *Base) FlagReviewHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", b.contentType)
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	reviewID, err := strconv.Atoi(chi.URLParam(r, "review_id"))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "flag review failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	var input struct {
+		Flagged *bool  `json:"flagged"`
+		Reason  string `json:"reason"`
+	}
+
+	err = utils.SerializeJSON(w, r, &input)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "flag review failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	if input.Flagged == nil {
+		slog.ErrorContext(r.Context(), "flagged is required")
+		utils.ErrorJSON(w, errors.New("flagged is required"), http.StatusBadRequest)
+		return
+	}
+
+	err = b.reviewService.FlagReview(ctx, reviewID, *input.Flagged, input.Reason)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "flag review failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	_ = utils.DeserializeJSON(w, http.StatusOK, map[string]any{"msg": "review updated", "flagged": *input.Flagged})
+}
@@ -0,0 +1,181 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/repo"
+	"github.com/bicosteve/booking-system/service"
+	"github.com/go-redis/redismock/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupReviewBase(t *testing.T) (*Base, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock db: %v", err)
+	}
+	rdb, _ := redismock.NewClientMock()
+	repository := *repo.NewDBRepository(db, rdb)
+
+	base := &Base{
+		reviewService: service.NewReviewService(repository),
+		contentType:   "application/json",
+		DB:            db,
+	}
+	return base, mock
+}
+
+func TestCreateReviewHandler(t *testing.T) {
+	body := `{"booking_id":100,"ratings":{"cleanliness":5,"comfort":4,"location":4,"value":3},"text":"Great stay"}`
+	bookingRows := func(status int) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"booking_id", "days", "user_id", "room_id", "currency", "status", "vender_id", "checked_in_at", "checked_out_at", "created_at", "updated_at"}).
+			AddRow(100, 2, 5, 10, "KES", status, 7, nil, nil, time.Now(), time.Now())
+	}
+
+	t.Run("checked out booking", func(t *testing.T) {
+		base, mock := setupReviewBase(t)
+		mock.ExpectPrepare("SELECT b.booking_id").ExpectQuery().WithArgs(100).
+			WillReturnRows(bookingRows(entities.BookingStatusCheckedOut))
+		mock.ExpectPrepare("SELECT COUNT").ExpectQuery().WithArgs(100).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectPrepare("INSERT INTO review").ExpectExec().
+			WithArgs(100, 10, 7, 5, 5, 4, 4, 3, 4.0, "Great stay").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		req := httptest.NewRequest(http.MethodPost, "/user/reviews", strings.NewReader(body))
+		req = withUserID(req, "5")
+		w := httptest.NewRecorder()
+
+		base.CreateReviewHandler(w, req)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("booking not checked out", func(t *testing.T) {
+		base, mock := setupReviewBase(t)
+		mock.ExpectPrepare("SELECT b.booking_id").ExpectQuery().WithArgs(100).
+			WillReturnRows(bookingRows(entities.BookingStatusConfirmed))
+
+		req := httptest.NewRequest(http.MethodPost, "/user/reviews", strings.NewReader(body))
+		req = withUserID(req, "5")
+		w := httptest.NewRecorder()
+
+		base.CreateReviewHandler(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("already reviewed", func(t *testing.T) {
+		base, mock := setupReviewBase(t)
+		mock.ExpectPrepare("SELECT b.booking_id").ExpectQuery().WithArgs(100).
+			WillReturnRows(bookingRows(entities.BookingStatusCheckedOut))
+		mock.ExpectPrepare("SELECT COUNT").ExpectQuery().WithArgs(100).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+		req := httptest.NewRequest(http.MethodPost, "/user/reviews", strings.NewReader(body))
+		req = withUserID(req, "5")
+		w := httptest.NewRecorder()
+
+		base.CreateReviewHandler(w, req)
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("rating out of range", func(t *testing.T) {
+		base, _ := setupReviewBase(t)
+
+		req := httptest.NewRequest(http.MethodPost, "/user/reviews",
+			strings.NewReader(`{"booking_id":100,"ratings":{"cleanliness":6,"comfort":4,"location":4,"value":3},"text":"x"}`))
+		req = withUserID(req, "5")
+		w := httptest.NewRecorder()
+
+		base.CreateReviewHandler(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "cleanliness rating must be between 1 and 5")
+	})
+}
+
+func TestGetRoomReviewsHandler(t *testing.T) {
+	base, mock := setupReviewBase(t)
+	mock.ExpectPrepare("SELECT review_id").ExpectQuery().WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"review_id", "booking_id", "room_id", "vendor_id", "user_id",
+			"cleanliness", "comfort", "location", "value", "rating", "body",
+			"vendor_reply", "replied_at", "flagged", "flag_reason", "created_at", "updated_at",
+		}).AddRow(1, 100, 10, 7, 5, 5, 4, 4, 3, 4.0, "Great stay", nil, nil, false, "", time.Now(), time.Now()))
+
+	req := httptest.NewRequest(http.MethodGet, "/user/rooms/10/reviews", nil)
+	req = withURLParam(req, "room_id", "10")
+	w := httptest.NewRecorder()
+
+	base.GetRoomReviewsHandler(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Great stay")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetVendorRatingHandler(t *testing.T) {
+	base, mock := setupReviewBase(t)
+	mock.ExpectPrepare("FROM review WHERE vendor_id").ExpectQuery().WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"rating", "count", "cleanliness", "comfort", "location", "value"}).
+			AddRow(4.25, 8, 4.5, 4.0, 4.75, 3.75))
+
+	req := httptest.NewRequest(http.MethodGet, "/user/vendors/7/rating", nil)
+	req = withURLParam(req, "vendor_id", "7")
+	w := httptest.NewRecorder()
+
+	base.GetVendorRatingHandler(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"review_count": 8`)
+}
+
+func TestFlagReviewHandler(t *testing.T) {
+	t.Run("flag", func(t *testing.T) {
+		base, mock := setupReviewBase(t)
+		mock.ExpectPrepare("UPDATE review SET flagged").ExpectExec().
+			WithArgs(true, "abusive", 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		req := httptest.NewRequest(http.MethodPut, "/admin/reviews/1/flag", strings.NewReader(`{"flagged":true,"reason":"abusive"}`))
+		req = withURLParam(req, "review_id", "1")
+		req = withUserID(req, "7")
+		w := httptest.NewRecorder()
+
+		base.FlagReviewHandler(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("missing flagged", func(t *testing.T) {
+		base, _ := setupReviewBase(t)
+
+		req := httptest.NewRequest(http.MethodPut, "/admin/reviews/1/flag", strings.NewReader(`{"reason":"abusive"}`))
+		req = withURLParam(req, "review_id", "1")
+		req = withUserID(req, "7")
+		w := httptest.NewRecorder()
+
+		base.FlagReviewHandler(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestReplyToReviewHandler(t *testing.T) {
+	base, mock := setupReviewBase(t)
+	mock.ExpectPrepare("UPDATE review SET vendor_reply").ExpectExec().
+		WithArgs("Thanks!", 1, 8).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	req := httptest.NewRequest(http.MethodPut, "/admin/reviews/1/reply", strings.NewReader(`{"reply":"Thanks!"}`))
+	req = withURLParam(req, "review_id", "1")
+	req = withUserID(req, "8")
+	w := httptest.NewRecorder()
+
+	base.ReplyToReviewHandler(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "review 1 not found for vendor 8")
+}
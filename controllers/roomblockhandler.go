@@ -0,0 +1,324 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+// Create room block godoc
+// @Summary take a room off sale for some dates
+// @Description Blocks one of the vendor's rooms from start_date up to, but not including, end_date. Reason is maintenance, owner_use or external_booking. Dates with confirmed stays cannot be blocked.
+// @ID create-room-block
+// @Tags blocks
+// @Accept json
+// @Produce json
+// @Param room_id path string true "Room ID"
+// @Param payload body entities.RoomBlockPayload true "Block"
+// @Success 201 {object} entities.RoomBlock "Created"
+// @Failure 400 {object} entities.JSONResponse "Bad request"
+// @Failure 401 {object} entities.JSONResponse "Unauthorized"
+// @Failure 404 {object} entities.JSONResponse "Room not found"
+// @Failure 409 {object} entities.JSONResponse "Room has confirmed stays on those dates"
+// @Router /api/admin/rooms/{room_id}/blocks [post]
+func (b *Base) CreateRoomBlockHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", b.contentType)
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	roomID, err := strconv.Atoi(chi.URLParam(r, "room_id"))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "create room block failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	var payload entities.RoomBlockPayload
+
+	err = utils.SerializeJSON(w, r, &payload)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "create room block failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := r.Context().Value(entities.UseridKeyValue).(string)
+	if !ok {
+		slog.ErrorContext(r.Context(), "could not get user_id from context")
+		utils.ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
+		return
+	}
+	vendorID, _ := strconv.Atoi(userID)
+
+	block, err := b.calendarService.CreateBlock(ctx, roomID, vendorID, payload)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "create room block failed", "error", err)
+		calendarError(w, err, "room not found")
+		return
+	}
+
+	_ = utils.DeserializeJSON(w, http.StatusCreated, map[string]any{"msg": "room blocked", "data": block})
+}
+
+// Room blocks godoc
+// @Summary list a room's blocks
+// @Description Returns the blocks of one of the vendor's rooms overlapping from..to, including those imported from external calendars (which carry a calendar_id)
+// @ID room-blocks
+// @Tags blocks
+// @Produce json
+// @Param room_id path string true "Room ID"
+// @Param from query string false "YYYY-MM-DD, default today"
+// @Param to query string false "YYYY-MM-DD, default 90 days after from"
+// @Success 200 {array} entities.RoomBlock "Success"
+// @Failure 400 {object} entities.JSONResponse "Bad request"
+// @Failure 401 {object} entities.JSONResponse "Unauthorized"
+// @Router /api/admin/rooms/{room_id}/blocks [get]
+func (b *Base) GetRoomBlocksHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", b.contentType)
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	roomID, err := strconv.Atoi(chi.URLParam(r, "room_id"))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "list room blocks failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	from, to, err := utils.ParseDateWindow(r.URL.Query().Get("from"), r.URL.Query().Get("to"), time.Now())
+	if err != nil {
+		slog.WarnContext(r.Context(), "list room blocks failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := r.Context().Value(entities.UseridKeyValue).(string)
+	if !ok {
+		slog.ErrorContext(r.Context(), "could not get user_id from context")
+		utils.ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
+		return
+	}
+	vendorID, _ := strconv.Atoi(userID)
+
+	blocks, err := b.calendarService.GetBlocks(ctx, roomID, vendorID, from, to)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "list room blocks failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	_ = utils.DeserializeJSON(w, http.StatusOK, map[string]any{"data": blocks})
+}
+
+// Update room block godoc
+// @Summary change a room block
+// @Description Updates the fields sent of a block the vendor created. Imported blocks change only through their calendar.
+// @ID update-room-block
+// @Tags blocks
+// @Accept json
+// @Produce json
+// @Param room_id path string true "Room ID"
+// @Param block_id path string true "Block ID"
+// @Param payload body entities.RoomBlockPayload true "Fields to change"
+// @Success 200 {object} entities.RoomBlock "Success"
+// @Failure 400 {object} entities.JSONResponse "Bad request"
+// @Failure 401 {object} entities.JSONResponse "Unauthorized"
+// @Failure 404 {object} entities.JSONResponse "Not found"
+// @Failure 409 {object} entities.JSONResponse "Imported block, or room has confirmed stays on those dates"
+// @Router /api/admin/rooms/{room_id}/blocks/{block_id} [put]
+func (b *Base) UpdateRoomBlockHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", b.contentType)
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	roomID, err := strconv.Atoi(chi.URLParam(r, "room_id"))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "update room block failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	blockID, err := strconv.Atoi(chi.URLParam(r, "block_id"))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "update room block failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	var payload entities.RoomBlockPayload
+
+	err = utils.SerializeJSON(w, r, &payload)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "update room block failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := r.Context().Value(entities.UseridKeyValue).(string)
+	if !ok {
+		slog.ErrorContext(r.Context(), "could not get user_id from context")
+		utils.ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
+		return
+	}
+	vendorID, _ := strconv.Atoi(userID)
+
+	block, err := b.calendarService.UpdateBlock(ctx, blockID, roomID, vendorID, payload)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "update room block failed", "error", err)
+		calendarError(w, err, "block not found")
+		return
+	}
+
+	_ = utils.DeserializeJSON(w, http.StatusOK, map[string]any{"msg": "block updated", "data": block})
+}
+
+// Delete room block godoc
+// @Summary remove a room block
+// @Description Puts the room back on sale for the dates of a block the vendor created
+// @ID delete-room-block
+// @Tags blocks
+// @Produce json
+// @Param room_id path string true "Room ID"
+// @Param block_id path string true "Block ID"
+// @Success 200 {object} entities.JSONResponse "Success"
+// @Failure 400 {object} entities.JSONResponse "Bad request"
+// @Failure 401 {object} entities.JSONResponse "Unauthorized"
+// @Failure 404 {object} entities.JSONResponse "Not found"
+// @Failure 409 {object} entities.JSONResponse "Imported block"
+// @Router /api/admin/rooms/{room_id}/blocks/{block_id} [delete]
+func (b *Base) DeleteRoomBlockHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", b.contentType)
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	roomID, err := strconv.Atoi(chi.URLParam(r, "room_id"))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "delete room block failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	blockID, err := strconv.Atoi(chi.URLParam(r, "block_id"))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "delete room block failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := r.Context().Value(entities.UseridKeyValue).(string)
+	if !ok {
+		slog.ErrorContext(r.Context(), "could not get user_id from context")
+		utils.ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
+		return
+	}
+	vendorID, _ := strconv.Atoi(userID)
+
+	err = b.calendarService.DeleteBlock(ctx, blockID, roomID, vendorID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "delete room block failed", "error", err)
+		calendarError(w, err, "block not found")
+		return
+	}
+
+	_ = utils.DeserializeJSON(w, http.StatusOK, map[string]any{"msg": "block removed"})
+}
+
+// Vendor room calendar godoc
+// @Summary show a room's calendar
+// @Description Returns the confirmed stays (kind booking, with status) and blocks (kind block, with reason) of one of the vendor's rooms overlapping from..to
+// @ID vendor-room-calendar
+// @Tags blocks
+// @Produce json
+// @Param room_id path string true "Room ID"
+// @Param from query string false "YYYY-MM-DD, default today"
+// @Param to query string false "YYYY-MM-DD, default 90 days after from"
+// @Success 200 {array} entities.CalendarEntry "Success"
+// @Failure 400 {object} entities.JSONResponse "Bad request"
+// @Failure 401 {object} entities.JSONResponse "Unauthorized"
+// @Failure 404 {object} entities.JSONResponse "Room not found"
+// @Router /api/admin/rooms/{room_id}/calendar [get]
+func (b *Base) VendorRoomCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", b.contentType)
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	roomID, err := strconv.Atoi(chi.URLParam(r, "room_id"))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "room calendar failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	from, to, err := utils.ParseDateWindow(r.URL.Query().Get("from"), r.URL.Query().Get("to"), time.Now())
+	if err != nil {
+		slog.WarnContext(r.Context(), "room calendar failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := r.Context().Value(entities.UseridKeyValue).(string)
+	if !ok {
+		slog.ErrorContext(r.Context(), "could not get user_id from context")
+		utils.ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
+		return
+	}
+	vendorID, _ := strconv.Atoi(userID)
+
+	entries, err := b.calendarService.VendorCalendar(ctx, roomID, vendorID, from, to)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "room calendar failed", "error", err)
+		calendarError(w, err, "room not found")
+		return
+	}
+
+	_ = utils.DeserializeJSON(w, http.StatusOK, map[string]any{"data": entries})
+}
+
+// Room availability godoc
+// @Summary dates a room cannot be booked
+// @Description Returns the date ranges overlapping from..to on which the room is booked or blocked. end_date is the first free day.
+// @ID room-availability
+// @Tags rooms
+// @Produce json
+// @Param room_id path string true "Room ID"
+// @Param from query string false "YYYY-MM-DD, default today"
+// @Param to query string false "YYYY-MM-DD, default 90 days after from"
+// @Success 200 {array} entities.DateRange "Unavailable dates"
+// @Failure 400 {object} entities.JSONResponse "Bad request"
+// @Failure 404 {object} entities.JSONResponse "Room not found"
+// @Router /api/user/rooms/{room_id}/availability [get]
+// @Security []
+func (b *Base) RoomAvailabilityHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", b.contentType)
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	roomID, err := strconv.Atoi(chi.URLParam(r, "room_id"))
+	if err != nil {
+		utils.ErrorJSON(w, errors.New("room not found"), http.StatusNotFound)
+		return
+	}
+
+	from, to, err := utils.ParseDateWindow(r.URL.Query().Get("from"), r.URL.Query().Get("to"), time.Now())
+	if err != nil {
+		slog.WarnContext(r.Context(), "room availability failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	unavailable, err := b.calendarService.Availability(ctx, roomID, from, to)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "room availability failed", "room_id", roomID, "error", err)
+		calendarError(w, err, "room not found")
+		return
+	}
+
+	_ = utils.DeserializeJSON(w, http.StatusOK, map[string]any{"data": map[string]any{"room_id": roomID, "unavailable": unavailable}})
+}
@@ -0,0 +1,161 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateRoomBlockHandler(t *testing.T) {
+	start := time.Now().UTC().AddDate(0, 0, 10).Format(entities.DateLayout)
+	end := time.Now().UTC().AddDate(0, 0, 12).Format(entities.DateLayout)
+	body := func(reason string) *bytes.Buffer {
+		return bytes.NewBufferString(fmt.Sprintf(`{"start_date":%q,"end_date":%q,"reason":%q,"note":"Repainting"}`, start, end, reason))
+	}
+
+	t.Run("created", func(t *testing.T) {
+		base, mock := setupCalendarBase(t)
+		mock.ExpectPrepare("SELECT COUNT\\(\\*\\) FROM booking").ExpectQuery().
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectPrepare("INSERT INTO room_block").ExpectExec().
+			WithArgs(start, end, entities.BlockReasonMaintenance, "Repainting", 10, 7).
+			WillReturnResult(sqlmock.NewResult(12, 1))
+
+		req := httptest.NewRequest(http.MethodPost, "/admin/rooms/10/blocks", body(entities.BlockReasonMaintenance))
+		req = withUserID(withURLParam(req, "room_id", "10"), "7")
+		w := httptest.NewRecorder()
+
+		base.CreateRoomBlockHandler(w, req)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var resp struct {
+			Data entities.RoomBlock `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, 12, resp.Data.ID)
+		assert.Equal(t, entities.BlockReasonMaintenance, resp.Data.Reason)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("overlaps a stay", func(t *testing.T) {
+		base, mock := setupCalendarBase(t)
+		mock.ExpectPrepare("SELECT COUNT\\(\\*\\) FROM booking").ExpectQuery().
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+		req := httptest.NewRequest(http.MethodPost, "/admin/rooms/10/blocks", body(entities.BlockReasonOwnerUse))
+		req = withUserID(withURLParam(req, "room_id", "10"), "7")
+		w := httptest.NewRecorder()
+
+		base.CreateRoomBlockHandler(w, req)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("unknown reason", func(t *testing.T) {
+		base, _ := setupCalendarBase(t)
+
+		req := httptest.NewRequest(http.MethodPost, "/admin/rooms/10/blocks", body("BOOKED"))
+		req = withUserID(withURLParam(req, "room_id", "10"), "7")
+		w := httptest.NewRecorder()
+
+		base.CreateRoomBlockHandler(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestDeleteRoomBlockHandler_Imported(t *testing.T) {
+	base, mock := setupCalendarBase(t)
+	now := time.Now()
+	mock.ExpectPrepare("FROM room_block b").ExpectQuery().WithArgs(13, 10, 7).
+		WillReturnRows(sqlmock.NewRows([]string{"block_id", "room_id", "start_date", "end_date", "reason", "note", "calendar_id", "external_uid", "created_at", "updated_at"}).
+			AddRow(13, 10, now, now.AddDate(0, 0, 2), entities.BlockReasonExternalBooking, "Reserved", 4, "abc@airbnb.com", now, now))
+
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("room_id", "10")
+	rctx.URLParams.Add("block_id", "13")
+	req := httptest.NewRequest(http.MethodDelete, "/admin/rooms/10/blocks/13", nil)
+	req = withUserID(req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx)), "7")
+	w := httptest.NewRecorder()
+
+	base.DeleteRoomBlockHandler(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestVendorRoomCalendarHandler(t *testing.T) {
+	base, mock := setupCalendarBase(t)
+	now := time.Now()
+	start := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectPrepare("SELECT room_id, cost, currency").ExpectQuery().WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"room_id", "cost", "currency", "status", "vender_id", "created_at", "updated_at"}).
+			AddRow("10", 10000, "KES", "VACANT", "7", now, now))
+	mock.ExpectPrepare("UNION ALL").ExpectQuery().
+		WithArgs(10, entities.BookingStatusConfirmed, entities.BookingStatusCheckedIn, "2026-12-01", "2026-11-01", 10, "2026-12-01", "2026-11-01").
+		WillReturnRows(sqlmock.NewRows([]string{"kind", "id", "start_date", "end_date", "status", "reason", "note", "calendar_id"}).
+			AddRow("booking", 100, start, start.AddDate(0, 0, 2), entities.BookingStatusConfirmed, "", "", nil).
+			AddRow("block", 3, start.AddDate(0, 0, 5), start.AddDate(0, 0, 7), nil, entities.BlockReasonMaintenance, "Boiler", nil))
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/rooms/10/calendar?from=2026-11-01&to=2026-12-01", nil)
+	req = withUserID(withURLParam(req, "room_id", "10"), "7")
+	w := httptest.NewRecorder()
+
+	base.VendorRoomCalendarHandler(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Data []entities.CalendarEntry `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Data, 2)
+	assert.Equal(t, entities.CalendarEntryBooking, resp.Data[0].Kind)
+	assert.Equal(t, "confirmed", resp.Data[0].Status)
+	assert.Equal(t, entities.CalendarEntryBlock, resp.Data[1].Kind)
+	assert.Equal(t, entities.BlockReasonMaintenance, resp.Data[1].Reason)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRoomAvailabilityHandler(t *testing.T) {
+	t.Run("unavailable dates", func(t *testing.T) {
+		base, mock := setupCalendarBase(t)
+		now := time.Now()
+		start := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+		mock.ExpectPrepare("SELECT room_id, cost, currency").ExpectQuery().WithArgs(10).
+			WillReturnRows(sqlmock.NewRows([]string{"room_id", "cost", "currency", "status", "vender_id", "created_at", "updated_at"}).
+				AddRow("10", 10000, "KES", "VACANT", "7", now, now))
+		mock.ExpectPrepare("UNION ALL").ExpectQuery().
+			WillReturnRows(sqlmock.NewRows([]string{"kind", "id", "start_date", "end_date", "status", "reason", "note", "calendar_id"}).
+				AddRow("block", 3, start.AddDate(0, 0, 5), start.AddDate(0, 0, 7), nil, entities.BlockReasonOwnerUse, "Family visit", nil))
+
+		req := httptest.NewRequest(http.MethodGet, "/user/rooms/10/availability?from=2026-11-01&to=2026-12-01", nil)
+		req = withURLParam(req, "room_id", "10")
+		w := httptest.NewRecorder()
+
+		base.RoomAvailabilityHandler(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), "Family visit")
+		assert.NotContains(t, w.Body.String(), entities.BlockReasonOwnerUse)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("bad range", func(t *testing.T) {
+		base, _ := setupCalendarBase(t)
+
+		req := httptest.NewRequest(http.MethodGet, "/user/rooms/10/availability?from=2026-11-01&to=2026-10-01", nil)
+		req = withURLParam(req, "room_id", "10")
+		w := httptest.NewRecorder()
+
+		base.RoomAvailabilityHandler(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
@@ -2,12 +2,15 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/money"
 	"github.com/bicosteve/booking-system/pkg/utils"
 	"github.com/go-chi/chi/v5"
 )
@@ -35,43 +38,44 @@ func (b *Base) CreateRoomHandler(w http.ResponseWriter, r *http.Request) {
 	err := utils.SerializeJSON(w, r, payload)
 	if err != nil {
 		utils.ErrorJSON(w, err, http.StatusBadRequest)
-		utils.LogError(err.Error(), entities.ErrorLog, http.StatusBadRequest)
+		slog.WarnContext(r.Context(), "create room failed", "error", err, "status", http.StatusBadRequest)
 		return
 	}
 
 	err = utils.ValidateRoom(payload)
 	if err != nil {
 		utils.ErrorJSON(w, err, http.StatusBadRequest)
-		utils.LogError(err.Error(), entities.ErrorLog, http.StatusBadRequest)
+		slog.WarnContext(r.Context(), "create room failed", "error", err, "status", http.StatusBadRequest)
 		return
 	}
 
 	userID, ok := r.Context().Value(entities.UseridKeyValue).(string)
 	if !ok {
 		utils.ErrorJSON(w, errors.New("internal server error"), http.StatusInternalServerError)
-		utils.LogError("error extracting userid from context", entities.ErrorLog, http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "could not get user_id from context", "status", http.StatusInternalServerError)
 		return
 	}
 
 	user_id, _ := strconv.Atoi(userID)
 
 	p := entities.RoomPayload{
-		Cost:   payload.Cost,
-		Status: payload.Status,
-		Vendor: user_id,
+		Cost:     payload.Cost,
+		Currency: payload.Currency,
+		Status:   payload.Status,
+		Vendor:   user_id,
 	}
 
 	err = b.roomService.CreateRoom(ctx, p)
 	if err != nil {
 		utils.ErrorJSON(w, err, http.StatusBadRequest)
-		utils.LogError(err.Error(), entities.ErrorLog, http.StatusBadRequest)
+		slog.WarnContext(r.Context(), "create room failed", "error", err, "status", http.StatusBadRequest)
 		return
 	}
 
 	err = utils.DeserializeJSON(w, http.StatusCreated, map[string]string{"msg": "created"})
 	if err != nil {
 		utils.ErrorJSON(w, err, http.StatusInternalServerError)
-		utils.LogError("%s %s", entities.ErrorLog, err.Error(), http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "create room failed", "error", err, "status", http.StatusInternalServerError)
 		return
 	}
 }
@@ -85,6 +89,10 @@ func (b *Base) CreateRoomHandler(w http.ResponseWriter, r *http.Request) {
 // @Produce json
 // @Param room_id query string false "Room ID to filter"
 // @Param status query string false "Room status to filter"
+// @Param currency query string false "ISO 4217 currency to show display_cost in"
+// @Param sort query string false "Sort by id, cost, created_at or rating (best rated first)"
+// @Param check_in query string false "Only rooms not blocked from this YYYY-MM-DD date"
+// @Param days query int false "Nights from check_in, default 1"
 // @Success 200 {array} entities.Room "List of rooms (if no filter or multiple matches)"
 // @Success 200 {object} entities.Room "Single room (if exact match)"
 // @Failure 400 {object} entities.JSONResponse "Bad request, validation error"
@@ -101,14 +109,46 @@ func (b *Base) FindRoomHandler(w http.ResponseWriter, r *http.Request) {
 
 	roomId := r.URL.Query().Get("room_id")
 	status := r.URL.Query().Get("status")
+	currency := r.URL.Query().Get("currency")
+	sortBy := r.URL.Query().Get("sort")
+	checkIn := r.URL.Query().Get("check_in")
 
-	rooms, err := b.roomService.FindRooms(ctx)
+	err := utils.ValidateFilters(entities.Filters{Sort: sortBy})
+	if err != nil {
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		slog.WarnContext(r.Context(), "find room failed", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+
+	var rooms []*entities.Room
+	if checkIn != "" {
+		var start, end time.Time
+		start, end, err = utils.ParseStay(checkIn, r.URL.Query().Get("days"), time.Now())
+		if err != nil {
+			utils.ErrorJSON(w, err, http.StatusBadRequest)
+			slog.WarnContext(r.Context(), "find room failed", "error", err, "status", http.StatusBadRequest)
+			return
+		}
+
+		rooms, err = b.roomService.FindAvailableRooms(ctx, start, end)
+	} else {
+		rooms, err = b.roomService.FindRooms(ctx)
+	}
 	if err != nil {
 		utils.ErrorJSON(w, err, http.StatusInternalServerError)
-		utils.LogError("%s %s", entities.ErrorLog, err.Error(), http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "find room failed", "error", err, "status", http.StatusInternalServerError)
 		return
 	}
 
+	if currency != "" {
+		err = b.presentRooms(ctx, rooms, currency)
+		if err != nil {
+			utils.ErrorJSON(w, err, http.StatusBadRequest)
+			slog.WarnContext(r.Context(), "find room failed", "error", err, "status", http.StatusBadRequest)
+			return
+		}
+	}
+
 	if roomId != "" {
 		room, found := utils.FilterRoomByID(rooms, roomId)
 		if found {
@@ -117,7 +157,7 @@ func (b *Base) FindRoomHandler(w http.ResponseWriter, r *http.Request) {
 		}
 
 		utils.ErrorJSON(w, errors.New("error: room id provided not found"), http.StatusNotFound)
-		utils.LogError("room not found %s", entities.ErrorLog, http.StatusNotFound)
+		slog.WarnContext(r.Context(), "room not found", "status", http.StatusNotFound)
 		return
 
 	}
@@ -125,16 +165,18 @@ func (b *Base) FindRoomHandler(w http.ResponseWriter, r *http.Request) {
 	if status != "" {
 		rooms, found := utils.FilterRoomByStatus(rooms, status)
 		if found {
+			utils.SortRooms(rooms, sortBy)
 			_ = utils.DeserializeJSON(w, http.StatusOK, rooms)
 			return
 		}
 
 		utils.ErrorJSON(w, errors.New("error: room status provided not found"), http.StatusNotFound)
-		utils.LogError("room not found %s", entities.ErrorLog, http.StatusNotFound)
+		slog.WarnContext(r.Context(), "room not found", "status", http.StatusNotFound)
 		return
 
 	}
 
+	utils.SortRooms(rooms, sortBy)
 	_ = utils.DeserializeJSON(w, http.StatusOK, rooms)
 
 }
@@ -160,7 +202,7 @@ func (b *Base) UpdateARoom(w http.ResponseWriter, r *http.Request) {
 	roomId, err := strconv.Atoi(chi.URLParam(r, "room_id"))
 	if err != nil {
 		utils.ErrorJSON(w, err, http.StatusBadRequest)
-		utils.LogError(err.Error(), entities.ErrorLog, http.StatusBadRequest)
+		slog.WarnContext(r.Context(), "update room failed", "error", err, "status", http.StatusBadRequest)
 		return
 	}
 
@@ -168,35 +210,65 @@ func (b *Base) UpdateARoom(w http.ResponseWriter, r *http.Request) {
 	userId, _ := strconv.Atoi(userID)
 	if !ok {
 		utils.ErrorJSON(w, errors.New("internal server error"), http.StatusInternalServerError)
-		utils.LogError("error extracting userid from context %s", entities.ErrorLog, http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "could not get user_id from context", "status", http.StatusInternalServerError)
 		return
 	}
 
-	var room entities.Room
 	var input struct {
-		Cost   *float64 `json:"cost"`
-		Status *string  `json:"status"`
+		Cost     *json.Number `json:"cost"`
+		Currency *string      `json:"currency"`
+		Status   *string      `json:"status"`
 	}
 
 	err = utils.SerializeJSON(w, r, &input)
 	if err != nil {
 		utils.ErrorJSON(w, err, http.StatusBadRequest)
-		utils.LogError("%s %s", entities.ErrorLog, err.Error(), http.StatusBadRequest)
+		slog.WarnContext(r.Context(), "update room failed", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+
+	room, err := b.roomService.FindARoom(ctx, roomId)
+	if err != nil {
+		utils.ErrorJSON(w, errors.New("room not found"), http.StatusNotFound)
+		slog.WarnContext(r.Context(), "update room failed", "error", err, "status", http.StatusNotFound)
 		return
 	}
 
-	if input.Cost != nil {
-		room.Cost = *input.Cost
+	if room.VenderId != userID {
+		utils.ErrorJSON(w, errors.New("room belongs to another vendor"), http.StatusForbidden)
+		slog.ErrorContext(r.Context(), "vendor cannot update room", "user_id", userId, "room_id", roomId)
+		return
+	}
+
+	// Cost is given in major units of the room's currency, which may change
+	// in the same request.
+	if input.Cost != nil || input.Currency != nil {
+		currency := room.Cost.Currency
+		if input.Currency != nil {
+			currency = *input.Currency
+		}
+
+		major := room.Cost.Major()
+		if input.Cost != nil {
+			major = input.Cost.String()
+		}
+
+		room.Cost, err = money.FromMajor(major, currency)
+		if err != nil {
+			utils.ErrorJSON(w, err, http.StatusBadRequest)
+			slog.WarnContext(r.Context(), "update room failed", "error", err, "status", http.StatusBadRequest)
+			return
+		}
 	}
 
 	if input.Status != nil {
 		room.Status = *input.Status
 	}
 
-	err = b.roomService.UpdateARoom(ctx, &room, roomId, userId)
+	err = b.roomService.UpdateARoom(ctx, room, roomId, userId)
 	if err != nil {
 		utils.ErrorJSON(w, err, http.StatusInternalServerError)
-		utils.LogError("%s %s", entities.ErrorLog, err.Error(), http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "update room failed", "error", err, "status", http.StatusInternalServerError)
 		return
 
 	}
@@ -204,7 +276,7 @@ func (b *Base) UpdateARoom(w http.ResponseWriter, r *http.Request) {
 	err = utils.DeserializeJSON(w, http.StatusOK, map[string]any{"msg": "room updated"})
 	if err != nil {
 		utils.ErrorJSON(w, err, http.StatusInternalServerError)
-		utils.LogError("%s %s", entities.ErrorLog, err.Error(), http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "update room failed", "error", err, "status", http.StatusInternalServerError)
 		return
 	}
 
@@ -230,14 +302,14 @@ func (b *Base) DeleteARoom(w http.ResponseWriter, r *http.Request) {
 	roomId, err := strconv.Atoi(chi.URLParam(r, "room_id"))
 	if err != nil {
 		utils.ErrorJSON(w, err, http.StatusBadRequest)
-		utils.LogError(err.Error(), entities.ErrorLog, http.StatusBadRequest)
+		slog.WarnContext(r.Context(), "delete room failed", "error", err, "status", http.StatusBadRequest)
 		return
 	}
 
 	userID, ok := r.Context().Value(entities.UseridKeyValue).(string)
 	if !ok {
 		utils.ErrorJSON(w, errors.New("internal server error"), http.StatusInternalServerError)
-		utils.LogError("error extracting userid from context %s", entities.ErrorLog, http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "could not get user_id from context", "status", http.StatusInternalServerError)
 		return
 	}
 
@@ -245,15 +317,34 @@ func (b *Base) DeleteARoom(w http.ResponseWriter, r *http.Request) {
 	err = b.roomService.DeleteARoom(ctx, roomId, id)
 	if err != nil {
 		utils.ErrorJSON(w, err, http.StatusInternalServerError)
-		utils.LogError("%s %s", entities.ErrorLog, err.Error(), http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "delete room failed", "error", err, "status", http.StatusInternalServerError)
 		return
 	}
 
 	err = utils.DeserializeJSON(w, http.StatusOK, map[string]string{"msg": "Deleted"})
 	if err != nil {
 		utils.ErrorJSON(w, err, http.StatusInternalServerError)
-		utils.LogError("%s %s", entities.ErrorLog, err.Error(), http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "delete room failed", "error", err, "status", http.StatusInternalServerError)
 		return
 	}
 
 }
+
+// presentRooms fills in DisplayCost for guests browsing in another currency.
+// Rooms are still charged and settled in their own currency.
+func (b *Base) presentRooms(ctx context.Context, rooms []*entities.Room, currency string) error {
+	if b.rates == nil {
+		return errors.New("currency conversion is not available")
+	}
+
+	for _, room := range rooms {
+		display, err := money.Convert(ctx, b.rates, room.Cost, currency)
+		if err != nil {
+			return err
+		}
+
+		room.DisplayCost = &display
+	}
+
+	return nil
+}
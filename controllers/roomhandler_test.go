@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -11,11 +12,13 @@ import (
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/money"
 	"github.com/bicosteve/booking-system/repo"
 	"github.com/bicosteve/booking-system/service"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-redis/redismock/v9"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func setupRoomBase(t *testing.T) (*Base, sqlmock.Sqlmock) {
@@ -35,6 +38,15 @@ func setupRoomBase(t *testing.T) (*Base, sqlmock.Sqlmock) {
 	return base, mock
 }
 
+// stubRates converts every currency pair at a fixed rate.
+type stubRates struct {
+	rate *big.Rat
+}
+
+func (s stubRates) Rate(context.Context, string, string) (*big.Rat, error) {
+	return s.rate, nil
+}
+
 // withUserID injects the user id into the request context as the handlers expect.
 func withUserID(r *http.Request, id string) *http.Request {
 	ctx := context.WithValue(r.Context(), entities.UseridKeyValue, id)
@@ -42,13 +54,13 @@ func withUserID(r *http.Request, id string) *http.Request {
 }
 
 func TestCreateRoomHandler(t *testing.T) {
-	insertQuery := "\n\t\tINSERT INTO room(cost, status, vender_id, created_at, updated_at)\n\t\tVALUES (?,?,?,NOW(),NOW())\n\t"
+	insertQuery := "\n\t\tINSERT INTO room(cost, currency, status, vender_id, created_at, updated_at)\n\t\tVALUES (?,?,?,?,NOW(),NOW())\n\t"
 
 	t.Run("successful create", func(t *testing.T) {
 		base, mock := setupRoomBase(t)
 		mock.ExpectPrepare(insertQuery).
 			ExpectExec().
-			WithArgs("100", "VACANT", 1).
+			WithArgs(int64(10000), "KES", "VACANT", "1").
 			WillReturnResult(sqlmock.NewResult(1, 1))
 
 		payload, _ := json.Marshal(entities.RoomPayload{Cost: "100", Status: "VACANT"})
@@ -90,12 +102,20 @@ func TestCreateRoomHandler(t *testing.T) {
 
 func TestFindRoomHandler(t *testing.T) {
 	mockTime := time.Now()
-	allRoomsQuery := "SELECT * FROM room ORDER BY room_id DESC"
+	allRoomsQuery := `SELECT r.room_id, r.cost, r.currency, r.status, r.vender_id, r.created_at, r.updated_at,
+		COALESCE(rv.rating, 0), COALESCE(rv.review_count, 0)
+		FROM room r
+		LEFT JOIN (
+		SELECT room_id, ROUND(AVG(rating), 2) AS rating, COUNT(*) AS review_count
+		FROM review WHERE flagged = FALSE GROUP BY room_id
+		) rv ON rv.room_id = r.room_id
+		ORDER BY r.room_id DESC`
 
 	roomRows := func() *sqlmock.Rows {
-		return sqlmock.NewRows([]string{"id", "cost", "status", "vender_id", "created_at", "updated_at"}).
-			AddRow("1", 100.0, "VACANT", "2", mockTime, mockTime).
-			AddRow("2", 200.0, "BOOKED", "2", mockTime, mockTime)
+		return sqlmock.NewRows([]string{"id", "cost", "currency", "status", "vender_id", "created_at", "updated_at", "rating", "review_count"}).
+			AddRow("1", 10000, "KES", "VACANT", "2", mockTime, mockTime, 3.5, 4).
+			AddRow("2", 20000, "KES", "BOOKED", "2", mockTime, mockTime, 4.75, 2).
+			AddRow("3", 15000, "KES", "VACANT", "2", mockTime, mockTime, 4.75, 9)
 	}
 
 	t.Run("all rooms - no filter", func(t *testing.T) {
@@ -143,6 +163,76 @@ func TestFindRoomHandler(t *testing.T) {
 		assert.Equal(t, http.StatusOK, w.Code)
 	})
 
+	t.Run("display prices in guest currency", func(t *testing.T) {
+		base, mock := setupRoomBase(t)
+		base.rates = stubRates{rate: big.NewRat(1, 125)}
+		mock.ExpectPrepare(allRoomsQuery).ExpectQuery().WillReturnRows(roomRows())
+
+		req := httptest.NewRequest(http.MethodGet, "/rooms?room_id=1&currency=usd", nil)
+		w := httptest.NewRecorder()
+
+		base.FindRoomHandler(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var room entities.Room
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &room))
+		assert.Equal(t, money.New(10000, "KES"), room.Cost)
+		assert.Equal(t, &money.Money{Amount: 80, Currency: "USD"}, room.DisplayCost)
+	})
+
+	t.Run("sort by rating", func(t *testing.T) {
+		base, mock := setupRoomBase(t)
+		mock.ExpectPrepare(allRoomsQuery).ExpectQuery().WillReturnRows(roomRows())
+
+		req := httptest.NewRequest(http.MethodGet, "/rooms?sort=rating", nil)
+		w := httptest.NewRecorder()
+
+		base.FindRoomHandler(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var rooms []entities.Room
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &rooms))
+		assert.Len(t, rooms, 3)
+		assert.Equal(t, []string{"3", "2", "1"}, []string{rooms[0].ID, rooms[1].ID, rooms[2].ID})
+		assert.Equal(t, 9, rooms[0].ReviewCount)
+	})
+
+	t.Run("sort by rating with status filter", func(t *testing.T) {
+		base, mock := setupRoomBase(t)
+		mock.ExpectPrepare(allRoomsQuery).ExpectQuery().WillReturnRows(roomRows())
+
+		req := httptest.NewRequest(http.MethodGet, "/rooms?status=VACANT&sort=rating", nil)
+		w := httptest.NewRecorder()
+
+		base.FindRoomHandler(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var rooms []entities.Room
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &rooms))
+		assert.Equal(t, []string{"3", "1"}, []string{rooms[0].ID, rooms[1].ID})
+	})
+
+	t.Run("unsupported sort", func(t *testing.T) {
+		base, _ := setupRoomBase(t)
+
+		req := httptest.NewRequest(http.MethodGet, "/rooms?sort=price", nil)
+		w := httptest.NewRecorder()
+
+		base.FindRoomHandler(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("display currency without rates", func(t *testing.T) {
+		base, mock := setupRoomBase(t)
+		mock.ExpectPrepare(allRoomsQuery).ExpectQuery().WillReturnRows(roomRows())
+
+		req := httptest.NewRequest(http.MethodGet, "/rooms?currency=USD", nil)
+		w := httptest.NewRecorder()
+
+		base.FindRoomHandler(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
 	t.Run("filter by invalid status", func(t *testing.T) {
 		base, mock := setupRoomBase(t)
 		mock.ExpectPrepare(allRoomsQuery).ExpectQuery().WillReturnRows(roomRows())
@@ -153,10 +243,49 @@ func TestFindRoomHandler(t *testing.T) {
 		base.FindRoomHandler(w, req)
 		assert.Equal(t, http.StatusNotFound, w.Code)
 	})
+
+	t.Run("available for a stay", func(t *testing.T) {
+		base, mock := setupRoomBase(t)
+		checkIn := time.Now().UTC().AddDate(0, 0, 7)
+		mock.ExpectPrepare(allRoomsQuery).ExpectQuery().WillReturnRows(roomRows())
+		mock.ExpectPrepare(`SELECT DISTINCT room_id FROM room_block WHERE start_date < ? AND end_date > ?`).ExpectQuery().
+			WithArgs(checkIn.AddDate(0, 0, 3).Format(entities.DateLayout), checkIn.Format(entities.DateLayout)).
+			WillReturnRows(sqlmock.NewRows([]string{"room_id"}).AddRow(3))
+
+		req := httptest.NewRequest(http.MethodGet, "/rooms?check_in="+checkIn.Format(entities.DateLayout)+"&days=3", nil)
+		w := httptest.NewRecorder()
+
+		base.FindRoomHandler(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var rooms []entities.Room
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rooms))
+		assert.Len(t, rooms, 2)
+		for _, room := range rooms {
+			assert.NotEqual(t, "3", room.ID)
+		}
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("invalid check in", func(t *testing.T) {
+		base, _ := setupRoomBase(t)
+
+		req := httptest.NewRequest(http.MethodGet, "/rooms?check_in=2020-01-01", nil)
+		w := httptest.NewRecorder()
+
+		base.FindRoomHandler(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
 }
 
 func TestUpdateARoomHandler(t *testing.T) {
-	updateQuery := "\n\t\tUPDATE room SET cost = ?, status = ?, updated_at = ? WHERE room_id = ? AND vender_id = ?\n\t"
+	updateQuery := "\n\t\tUPDATE room SET cost = ?, currency = ?, status = ?, updated_at = ? WHERE room_id = ? AND vender_id = ?\n\t"
+	findQuery := "SELECT room_id, cost, currency, status, vender_id, created_at, updated_at\n\t\t\tFROM room WHERE room_id = ?"
+	mockTime := time.Now()
+	roomRow := func(vendor string) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"room_id", "cost", "currency", "status", "vender_id", "created_at", "updated_at"}).
+			AddRow("1", 10000, "KES", "VACANT", vendor, mockTime, mockTime)
+	}
 
 	newReq := func(body string, roomID string) *http.Request {
 		req := httptest.NewRequest(http.MethodPut, "/rooms/"+roomID, bytes.NewBufferString(body))
@@ -168,10 +297,13 @@ func TestUpdateARoomHandler(t *testing.T) {
 
 	t.Run("successful update", func(t *testing.T) {
 		base, mock := setupRoomBase(t)
+		mock.ExpectPrepare(findQuery).ExpectQuery().WithArgs(1).WillReturnRows(roomRow("2"))
+		mock.ExpectPrepare(findQuery).ExpectQuery().WithArgs(1).WillReturnRows(roomRow("2"))
 		mock.ExpectPrepare(updateQuery).
 			ExpectExec().
-			WithArgs(150.0, "BOOKED", sqlmock.AnyArg(), 1, 2).
+			WithArgs(int64(15000), "KES", "BOOKED", sqlmock.AnyArg(), 1, 2).
 			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectAuditInsert(mock, entities.AuditRoomUpdate)
 
 		// preserve user id in context alongside chi route context
 		req := newReq(`{"cost":150,"status":"BOOKED"}`, "1")
@@ -183,6 +315,54 @@ func TestUpdateARoomHandler(t *testing.T) {
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 
+	t.Run("status only keeps cost", func(t *testing.T) {
+		base, mock := setupRoomBase(t)
+		mock.ExpectPrepare(findQuery).ExpectQuery().WithArgs(1).WillReturnRows(roomRow("2"))
+		mock.ExpectPrepare(findQuery).ExpectQuery().WithArgs(1).WillReturnRows(roomRow("2"))
+		mock.ExpectPrepare(updateQuery).
+			ExpectExec().
+			WithArgs(int64(10000), "KES", "BOOKED", sqlmock.AnyArg(), 1, 2).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectAuditInsert(mock, entities.AuditRoomUpdate)
+
+		req := newReq(`{"status":"BOOKED"}`, "1")
+		w := httptest.NewRecorder()
+
+		base.UpdateARoom(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("change currency", func(t *testing.T) {
+		base, mock := setupRoomBase(t)
+		mock.ExpectPrepare(findQuery).ExpectQuery().WithArgs(1).WillReturnRows(roomRow("2"))
+		mock.ExpectPrepare(findQuery).ExpectQuery().WithArgs(1).WillReturnRows(roomRow("2"))
+		mock.ExpectPrepare(updateQuery).
+			ExpectExec().
+			WithArgs(int64(12000), "JPY", "VACANT", sqlmock.AnyArg(), 1, 2).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectAuditInsert(mock, entities.AuditRoomUpdate)
+
+		req := newReq(`{"cost":"12000","currency":"JPY"}`, "1")
+		w := httptest.NewRecorder()
+
+		base.UpdateARoom(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("another vendor's room", func(t *testing.T) {
+		base, mock := setupRoomBase(t)
+		mock.ExpectPrepare(findQuery).ExpectQuery().WithArgs(1).WillReturnRows(roomRow("9"))
+
+		req := newReq(`{"cost":150}`, "1")
+		w := httptest.NewRecorder()
+
+		base.UpdateARoom(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
 	t.Run("invalid room id", func(t *testing.T) {
 		base, _ := setupRoomBase(t)
 		req := newReq(`{"cost":150}`, "abc")
@@ -207,10 +387,16 @@ func TestDeleteARoomHandler(t *testing.T) {
 
 	t.Run("successful delete", func(t *testing.T) {
 		base, mock := setupRoomBase(t)
+		mock.ExpectPrepare("SELECT room_id, cost, currency, status, vender_id, created_at, updated_at FROM room WHERE room_id = ?").
+			ExpectQuery().
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"room_id", "cost", "currency", "status", "vender_id", "created_at", "updated_at"}).
+				AddRow("1", 10000, "KES", "VACANT", "2", time.Now(), time.Now()))
 		mock.ExpectPrepare(deleteQuery).
 			ExpectExec().
 			WithArgs(1, 2).
 			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectAuditInsert(mock, entities.AuditRoomDelete)
 
 		req := newReq("1")
 		w := httptest.NewRecorder()
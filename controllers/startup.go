@@ -3,7 +3,10 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/bicosteve/booking-system/entities"
@@ -22,7 +25,7 @@ func (b *Base) waitForDependencies(cfg entities.Config) {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 	if err := health.Await(ctx, checkers, 2*time.Second, timeout); err != nil {
-		utils.LogError(err.Error(), entities.ErrorLog)
+		slog.Error("dependencies not ready", "error", err)
 		os.Exit(1)
 	}
 }
@@ -42,7 +45,7 @@ func startupTimeout() time.Duration {
 func buildStartupProbes(cfg entities.Config, b *Base) []health.Checker {
 	var cs []health.Checker
 	for _, m := range cfg.Mysql {
-		dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=latin1&parseTime=True&loc=Local",
+		dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=latin1&parseTime=True&loc=UTC&time_zone=%%27%%2B00%%3A00%%27",
 			m.Username, m.Password, m.Host, m.Port, m.Schema)
 		cs = append(cs, health.MySQLProbe(dsn))
 	}
@@ -64,6 +67,175 @@ func buildStartupProbes(cfg entities.Config, b *Base) []health.Checker {
 	return cs
 }
 
+// payoutInterval parses the configured payout batch interval; defaults to 24h.
+func payoutInterval(v string) time.Duration {
+	if d, err := time.ParseDuration(v); err == nil && d > 0 {
+		return d
+	}
+	return 24 * time.Hour
+}
+
+// healthInterval parses the readiness check interval; defaults to 15s.
+func healthInterval(v string) time.Duration {
+	if d, err := time.ParseDuration(v); err == nil && d > 0 {
+		return d
+	}
+	return 15 * time.Second
+}
+
+// webhookInterval parses how often due webhook deliveries are sent; defaults
+// to 10s.
+func webhookInterval(v string) time.Duration {
+	if d, err := time.ParseDuration(v); err == nil && d > 0 {
+		return d
+	}
+	return 10 * time.Second
+}
+
+// calendarInterval parses how often external calendars are imported;
+// defaults to 30m.
+func calendarInterval(v string) time.Duration {
+	if d, err := time.ParseDuration(v); err == nil && d > 0 {
+		return d
+	}
+	return 30 * time.Minute
+}
+
+// topicKeys parses "topic=key" pairs into the per-topic keys of the Kafka
+// producer. Malformed pairs are skipped.
+func topicKeys(pairs []string) map[string]string {
+	keys := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		topic, key, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(topic) == "" {
+			continue
+		}
+		keys[strings.TrimSpace(topic)] = strings.TrimSpace(key)
+	}
+	return keys
+}
+
+// publishTimeout parses how long a synchronous Kafka publish waits for its
+// delivery report; defaults to 10s.
+func publishTimeout(v string) time.Duration {
+	if d, err := time.ParseDuration(v); err == nil && d > 0 {
+		return d
+	}
+	return 10 * time.Second
+}
+
+// healthOptional returns the dependencies that only degrade readiness;
+// defaults to Kafka.
+func healthOptional(names []string) []string {
+	if len(names) == 0 {
+		return []string{"kafka"}
+	}
+	return names
+}
+
+// envList reads a comma-separated env var; returns nil when unset.
+func envList(name string) []string {
+	var out []string
+	for _, v := range strings.Split(os.Getenv(name), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// envIDs reads a comma separated list of ids, skipping any that are not
+// numbers.
+func envIDs(name string) []int {
+	var out []int
+	for _, v := range envList(name) {
+		if id, err := strconv.Atoi(v); err == nil {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// envRateLimits reads rate limit rules written as
+// "login=10/1m:ip,email;register=5/1h:ip". A malformed rule keeps an empty
+// window so startup reports it instead of dropping it.
+func envRateLimits(name string) []entities.RateLimitConfig {
+	var out []entities.RateLimitConfig
+	for _, rule := range strings.Split(os.Getenv(name), ";") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+
+		route, spec, _ := strings.Cut(rule, "=")
+		rate, keys, _ := strings.Cut(spec, ":")
+		limit, window, _ := strings.Cut(rate, "/")
+		n, _ := strconv.Atoi(limit)
+
+		out = append(out, entities.RateLimitConfig{
+			Name:   strings.TrimSpace(route),
+			Limit:  n,
+			Window: window,
+			Keys:   strings.Split(keys, ","),
+		})
+	}
+	return out
+}
+
+// envOIDC reads the login providers: Google from OIDC_GOOGLE_*, and one
+// generic provider from OIDC_* when OIDC_ISSUER is set. Providers without a
+// client id are left out.
+func envOIDC() []entities.OIDCConfig {
+	var out []entities.OIDCConfig
+	if os.Getenv("OIDC_GOOGLE_CLIENT_ID") != "" {
+		out = append(out, entities.OIDCConfig{
+			Name:         "google",
+			ClientID:     os.Getenv("OIDC_GOOGLE_CLIENT_ID"),
+			ClientSecret: os.Getenv("OIDC_GOOGLE_CLIENT_SECRET"),
+			RedirectURL:  os.Getenv("OIDC_GOOGLE_REDIRECT_URL"),
+		})
+	}
+
+	if os.Getenv("OIDC_ISSUER") != "" && os.Getenv("OIDC_CLIENT_ID") != "" {
+		name := os.Getenv("OIDC_NAME")
+		if name == "" {
+			name = "oidc"
+		}
+
+		out = append(out, entities.OIDCConfig{
+			Name:         name,
+			Issuer:       os.Getenv("OIDC_ISSUER"),
+			ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+			ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+			RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+			Scopes:       envList("OIDC_SCOPES"),
+		})
+	}
+	return out
+}
+
+// envJWTKeys reads the token signing keys from JWT_KEY_1_*, JWT_KEY_2_* and
+// so on, stopping at the first number without a JWT_KEY_<n>_ID. Each key
+// takes its PEM from JWT_KEY_<n>_PEM or a file at JWT_KEY_<n>_FILE.
+func envJWTKeys() []entities.SigningKeyConfig {
+	var out []entities.SigningKeyConfig
+	for n := 1; ; n++ {
+		prefix := fmt.Sprintf("JWT_KEY_%d_", n)
+		id := os.Getenv(prefix + "ID")
+		if id == "" {
+			return out
+		}
+
+		out = append(out, entities.SigningKeyConfig{
+			ID:         id,
+			File:       os.Getenv(prefix + "FILE"),
+			PEM:        os.Getenv(prefix + "PEM"),
+			ActiveFrom: os.Getenv(prefix + "ACTIVE_FROM"),
+			RetireAt:   os.Getenv(prefix + "RETIRE_AT"),
+		})
+	}
+}
+
 // envBool reads a boolean env var; returns def when unset/unrecognized.
 func envBool(name string, def bool) bool {
 	switch os.Getenv(name) {
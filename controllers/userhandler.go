@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"time"
 
@@ -51,6 +52,7 @@ func (b *Base) HealthCheck(w http.ResponseWriter, r *http.Request) {
 // @Param payload body entities.UserPayload true "Register User"
 // @Success 201 {object} APIResponse "User registered"
 // @Failure 400 {object} entities.JSONResponse "Bad request, validation error"
+// @Failure 429 {object} entities.JSONResponse "Too many attempts; see Retry-After"
 // @Failure 500 {object} entities.JSONResponse "Internal server error"
 // @Router /api/user/register [post]
 // @Security []
@@ -63,35 +65,35 @@ func (b *Base) RegisterHandler(w http.ResponseWriter, r *http.Request) {
 	err := utils.SerializeJSON(w, r, payload)
 	if err != nil {
 		utils.ErrorJSON(w, err, http.StatusBadRequest)
-		utils.LogError("%s %d", entities.ErrorLog, err.Error(), http.StatusBadRequest)
+		slog.WarnContext(r.Context(), "register failed", "error", err, "status", http.StatusBadRequest)
 		return
 	}
 
-	err = utils.ValidateUser(payload)
+	err = utils.ValidateUser(payload, b.phoneRegion)
 	if err != nil {
 		utils.ErrorJSON(w, err, http.StatusBadRequest)
-		utils.LogError("%s %d", entities.ErrorLog, err.Error(), http.StatusBadRequest)
+		slog.WarnContext(r.Context(), "register failed", "error", err, "status", http.StatusBadRequest)
 		return
 	}
 
 	err = b.userService.SubmitRegistrationRequest(ctx, *payload)
 	if err != nil {
 		utils.ErrorJSON(w, err, http.StatusBadRequest)
-		utils.LogError("%s %d", entities.ErrorLog, err.Error(), http.StatusBadRequest)
+		slog.WarnContext(r.Context(), "register failed", "error", err, "status", http.StatusBadRequest)
 		return
 	}
 
 	err = utils.DeserializeJSON(w, http.StatusCreated, map[string]string{"msg": "success"})
 	if err != nil {
 		utils.ErrorJSON(w, err, http.StatusInternalServerError)
-		utils.LogError("%s %d", entities.ErrorLog, err.Error(), http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "register failed", "error", err, "status", http.StatusInternalServerError)
 		return
 	}
 }
 
 // Generate auth token godoc
 // @Summary Authorize User
-// @Description Receives user payload, validate it then send it to service
+// @Description Receives user payload, validate it then send it to service. Users with two-factor on get {"mfa_required":true,"mfa_token":"..."} instead, to finish at /api/user/login/mfa.
 // @ID login-user
 // @Tags auth
 // @Accept json
@@ -100,6 +102,7 @@ func (b *Base) RegisterHandler(w http.ResponseWriter, r *http.Request) {
 // @Success 200 {object} APIResponse "{"token":"xxxxxxxxxxx"}"
 // @Failure 400 {object} entities.JSONResponse "Bad Request, validation error"
 // @Failure 404 {object} entities.JSONResponse "Bad Request, user not found"
+// @Failure 429 {object} entities.JSONResponse "Too many attempts or account locked; see Retry-After"
 // @Failure 500 {object} entities.JSONResponse "Internal server error"
 // @Router /api/user/login [post]
 // @Security []
@@ -112,21 +115,28 @@ func (b *Base) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	err := utils.SerializeJSON(w, r, payload)
 	if err != nil {
 		utils.ErrorJSON(w, err, http.StatusBadRequest)
-		utils.LogError("%s %d", entities.ErrorLog, err.Error(), http.StatusBadRequest)
+		slog.WarnContext(r.Context(), "login failed", "error", err, "status", http.StatusBadRequest)
 		return
 	}
 
 	err = utils.ValidateLogin(payload)
 	if err != nil {
 		utils.ErrorJSON(w, err, http.StatusBadRequest)
-		utils.LogError("%s %d", entities.ErrorLog, err.Error(), http.StatusBadRequest)
+		slog.WarnContext(r.Context(), "login failed", "error", err, "status", http.StatusBadRequest)
 		return
 	}
 
-	token, err := b.userService.SubmitLoginRequest(ctx, *payload, b.jwtSecret)
+	token, mfaPending, err := b.userService.SubmitLoginRequest(ctx, *payload, b.jwtKeys)
+	var lockErr *entities.LockoutError
+	if errors.As(err, &lockErr) {
+		utils.SetRetryAfter(w, lockErr.RetryAfter)
+		utils.ErrorJSON(w, err, http.StatusTooManyRequests)
+		slog.WarnContext(r.Context(), "login failed", "error", err, "status", http.StatusTooManyRequests)
+		return
+	}
 	if err != nil {
 		utils.ErrorJSON(w, err, http.StatusBadRequest)
-		utils.LogError("%s %d", entities.ErrorLog, err.Error(), http.StatusBadRequest)
+		slog.WarnContext(r.Context(), "login failed", "error", err, "status", http.StatusBadRequest)
 		return
 	}
 
@@ -135,22 +145,28 @@ func (b *Base) LoginHandler(w http.ResponseWriter, r *http.Request) {
 		err = utils.DeserializeJSON(w, http.StatusBadRequest, map[string]string{"msg": "password does not match username"})
 		if err != nil {
 			utils.ErrorJSON(w, err, http.StatusBadRequest)
-			utils.LogError("%s %d", entities.ErrorLog, err.Error(), http.StatusBadRequest)
+			slog.WarnContext(r.Context(), "login failed", "error", err, "status", http.StatusBadRequest)
 			return
 		}
 		return
 	}
 
+	if mfaPending {
+		_ = utils.DeserializeJSON(w, http.StatusOK, map[string]any{"mfa_required": true, "mfa_token": token})
+		slog.InfoContext(r.Context(), "login awaiting second factor", "email", payload.Email)
+		return
+	}
+
 	r.Header.Set("Authorization", "Bearer "+token)
 
 	err = utils.DeserializeJSON(w, http.StatusOK, map[string]string{"token": token})
 	if err != nil {
 		utils.ErrorJSON(w, err, http.StatusBadRequest)
-		utils.LogError("%s %d", entities.ErrorLog, err.Error(), http.StatusBadRequest)
+		slog.WarnContext(r.Context(), "login failed", "error", err, "status", http.StatusBadRequest)
 		return
 	}
 
-	utils.LogInfo("login success 'user' %s STATUS %d", entities.InfoLog, payload.Email, http.StatusOK)
+	slog.InfoContext(r.Context(), "login succeeded", "email", payload.Email)
 }
 
 type APIUserResponse struct {
@@ -164,6 +180,7 @@ type APIUserResponse struct {
 // @Tags auth
 // @Produce json
 // @Success 200 {object} APIUserResponse "User retrieved successfully"
+// @Failure 404 {object} entities.JSONResponse "Account deleted"
 // @Failure 500 {object} entities.JSONResponse "Internal server error"
 // @Router /api/user/me [get]
 func (b *Base) ProfileHandler(w http.ResponseWriter, r *http.Request) {
@@ -171,31 +188,31 @@ func (b *Base) ProfileHandler(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), time.Second*5)
 	defer cancel()
 
-	userName, ok := r.Context().Value(entities.UsernameKeyValue).(string)
+	userID, _, ok := sessionFromContext(r.Context())
 	if !ok {
 		utils.ErrorJSON(w, errors.New("internal server error"), http.StatusInternalServerError)
-		utils.LogError("error extracting username from context %d", entities.ErrorLog, http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "could not get user_id from context", "status", http.StatusInternalServerError)
 		return
 	}
 
-	user, err := b.userService.SubmitProfileRequest(ctx, userName)
+	user, err := b.userService.GetProfile(ctx, userID)
 	if err != nil {
-		utils.ErrorJSON(w, err, http.StatusNotFound)
-		utils.LogError("%s %d", entities.ErrorLog, err.Error(), http.StatusNotFound)
+		profileError(w, err)
+		slog.WarnContext(r.Context(), "profile failed", "error", err)
 		return
 
 	}
 
-	utils.LogInfo("fetch user success %s %d", entities.InfoLog, user.ID, http.StatusOK)
+	slog.InfoContext(r.Context(), "user fetched", "user_id", user.ID)
 
 	err = utils.DeserializeJSON(w, http.StatusOK, map[string]any{"user": user})
 	if err != nil {
 		utils.ErrorJSON(w, err, http.StatusBadRequest)
-		utils.LogError("%s %d", entities.ErrorLog, err.Error(), http.StatusBadRequest)
+		slog.WarnContext(r.Context(), "profile failed", "error", err, "status", http.StatusBadRequest)
 		return
 	}
 
-	utils.LogInfo("send user success %s %d ", entities.InfoLog, user.ID, http.StatusOK)
+	slog.InfoContext(r.Context(), "user sent", "user_id", user.ID)
 }
 
 // @Summary Generate Password Reset Token
@@ -216,21 +233,21 @@ func (b *Base) GenerateResetTokenHandler(w http.ResponseWriter, r *http.Request)
 	userName, ok := r.Context().Value(entities.UsernameKeyValue).(string)
 	if !ok {
 		utils.ErrorJSON(w, errors.New("internal server error"), http.StatusInternalServerError)
-		utils.LogError("error extracting username from context %d", entities.ErrorLog, http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "could not get username from context", "status", http.StatusInternalServerError)
 		return
 	}
 
 	phoneNumber, ok := r.Context().Value(entities.PhoneNumberKeyValue).(string)
 	if !ok {
 		utils.ErrorJSON(w, errors.New("internal server error"), http.StatusInternalServerError)
-		utils.LogError("error extracting phonenumber from context %d", entities.ErrorLog, http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "could not get phone number from context", "status", http.StatusInternalServerError)
 		return
 	}
 
 	userID, ok := r.Context().Value(entities.UseridKeyValue).(string)
 	if !ok {
 		utils.ErrorJSON(w, errors.New("internal server error"), http.StatusInternalServerError)
-		utils.LogError("error extracting userid from context %d", entities.ErrorLog, http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "could not get user_id from context", "status", http.StatusInternalServerError)
 		return
 	}
 
@@ -245,33 +262,33 @@ func (b *Base) GenerateResetTokenHandler(w http.ResponseWriter, r *http.Request)
 	err := utils.SerializeJSON(w, r, &payload)
 	if err != nil {
 		utils.ErrorJSON(w, errors.New(err.Error()), http.StatusBadRequest)
-		utils.LogError(err.Error(), entities.ErrorLog, http.StatusBadRequest)
+		slog.WarnContext(r.Context(), "generate reset token failed", "error", err, "status", http.StatusBadRequest)
 		return
 	}
 
 	if payload.Email == nil {
 		utils.ErrorJSON(w, errors.New("bad request"), http.StatusBadRequest)
-		utils.LogError("email is required field %d", entities.ErrorLog, http.StatusBadRequest)
+		slog.WarnContext(r.Context(), "email is required field", "status", http.StatusBadRequest)
 		return
 	}
 
 	if *payload.Email != userName {
 		utils.ErrorJSON(w, errors.New("bad request"), http.StatusBadRequest)
-		utils.LogError("Session username mismatch! %d", entities.ErrorLog, http.StatusBadRequest)
+		slog.WarnContext(r.Context(), "session username mismatch", "status", http.StatusBadRequest)
 		return
 	}
 
 	user, err := b.userService.SubmitProfileRequest(ctx, userName)
 	if err != nil {
 		utils.ErrorJSON(w, err, http.StatusInternalServerError)
-		utils.LogError("%s %d", entities.ErrorLog, err.Error(), http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "generate reset token failed", "error", err, "status", http.StatusInternalServerError)
 		return
 	}
 
 	tkn, err := b.userService.InsertPasswordResetToken(ctx, b.DB, *user)
 	if err != nil {
 		utils.ErrorJSON(w, err, http.StatusInternalServerError)
-		utils.LogError("%s %d", entities.ErrorLog, err.Error(), http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "generate reset token failed", "error", err, "status", http.StatusInternalServerError)
 		return
 	}
 
@@ -283,7 +300,7 @@ func (b *Base) GenerateResetTokenHandler(w http.ResponseWriter, r *http.Request)
 	err = b.userService.SubmitMessage(ctx, msg)
 	if err != nil {
 		utils.ErrorJSON(w, err, http.StatusInternalServerError)
-		utils.LogError("%s %d", entities.ErrorLog, err.Error(), http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "generate reset token failed", "error", err, "status", http.StatusInternalServerError)
 		return
 	}
 
@@ -292,21 +309,21 @@ func (b *Base) GenerateResetTokenHandler(w http.ResponseWriter, r *http.Request)
 	_, err = utils.SendMail(b.sengridkey, b.mailfrom, "Reset Token", *payload.Email, ujumbe)
 	if err != nil {
 		utils.ErrorJSON(w, err, http.StatusInternalServerError)
-		utils.LogError("%s %d", entities.ErrorLog, err.Error(), http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "generate reset token failed", "error", err, "status", http.StatusInternalServerError)
 		return
 	}
 
 	_, err = utils.SendSMS(b.atklng, b.appusername, phoneNumber, ujumbe)
 	if err != nil {
 		utils.ErrorJSON(w, err, http.StatusInternalServerError)
-		utils.LogError("%s %d", entities.ErrorLog, err.Error(), http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "generate reset token failed", "error", err, "status", http.StatusInternalServerError)
 		return
 	}
 
 	err = utils.DeserializeJSON(w, http.StatusCreated, map[string]interface{}{"reset_tkn": tkn})
 	if err != nil {
 		utils.ErrorJSON(w, err, http.StatusBadRequest)
-		utils.LogError("%s %d", entities.ErrorLog, err.Error(), http.StatusBadRequest)
+		slog.WarnContext(r.Context(), "generate reset token failed", "error", err, "status", http.StatusBadRequest)
 		return
 	}
 
@@ -331,7 +348,7 @@ func (b *Base) ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
 	tkn := r.URL.Query().Get("token")
 	if len(tkn) < 1 {
 		utils.ErrorJSON(w, errors.New("reset token is required"), http.StatusBadRequest)
-		utils.LogError("reset token not provided %d", entities.ErrorLog, http.StatusBadRequest)
+		slog.WarnContext(r.Context(), "reset token not provided", "status", http.StatusBadRequest)
 		return
 	}
 
@@ -343,40 +360,86 @@ func (b *Base) ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
 	err := utils.SerializeJSON(w, r, &payload)
 	if err != nil {
 		utils.ErrorJSON(w, err, http.StatusBadRequest)
-		utils.LogError("%s %d", entities.ErrorLog, err.Error(), http.StatusBadRequest)
+		slog.WarnContext(r.Context(), "reset password failed", "error", err, "status", http.StatusBadRequest)
 		return
 	}
 
 	if *payload.Password != *payload.ConfirmPassword {
 		utils.ErrorJSON(w, errors.New("confirm password and password  mismatch"), http.StatusBadRequest)
-		utils.LogError("confirm password and password are required %d", entities.ErrorLog, http.StatusBadRequest)
+		slog.WarnContext(r.Context(), "confirm password and password are required", "status", http.StatusBadRequest)
 		return
 	}
 
 	if payload.Password == nil {
 		utils.ErrorJSON(w, errors.New("password  is required"), http.StatusBadRequest)
-		utils.LogError("confirm password and password are required %d", entities.ErrorLog, http.StatusBadRequest)
+		slog.WarnContext(r.Context(), "confirm password and password are required", "status", http.StatusBadRequest)
 		return
 	}
 
 	if payload.ConfirmPassword == nil {
 		utils.ErrorJSON(w, errors.New("confirm password  is required"), http.StatusBadRequest)
-		utils.LogError("confirm password and password are required %d", entities.ErrorLog, http.StatusBadRequest)
+		slog.WarnContext(r.Context(), "confirm password and password are required", "status", http.StatusBadRequest)
 		return
 	}
 
 	err = b.userService.SubmitPasswordResetRequest(ctx, b.DB, payload.Password, tkn)
 	if err != nil {
 		utils.ErrorJSON(w, err, http.StatusBadRequest)
-		utils.LogError("%s %d", entities.ErrorLog, err.Error(), http.StatusBadRequest)
+		slog.WarnContext(r.Context(), "reset password failed", "error", err, "status", http.StatusBadRequest)
 		return
 	}
 
 	err = utils.DeserializeJSON(w, http.StatusCreated, map[string]interface{}{"msg": "password successfully reset"})
 	if err != nil {
 		utils.ErrorJSON(w, err, http.StatusBadRequest)
-		utils.LogError("%s %d", entities.ErrorLog, err.Error(), http.StatusBadRequest)
+		slog.WarnContext(r.Context(), "reset password failed", "error", err, "status", http.StatusBadRequest)
+		return
+	}
+
+}
+
+// Unlock account godoc
+// @Summary unlock an account locked after failed logins
+// @Description Lifts the login lock on an account and resets its failed login count. Platform admins only.
+// @ID unlock-account
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param payload body object true "{"email":"guest@gmail.com"}"
+// @Success 200 {object} entities.JSONResponse "Success"
+// @Failure 400 {object} entities.JSONResponse "Bad request"
+// @Failure 401 {object} entities.JSONResponse "Unauthorized"
+// @Failure 403 {object} entities.JSONResponse "Forbidden"
+// @Router /api/admin/users/unlock [post]
+func (b *Base) UnlockAccountHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", b.contentType)
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	var input struct {
+		Email string `json:"email"`
+	}
+
+	err := utils.SerializeJSON(w, r, &input)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "unlock account failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	if !entities.EmailRegex.MatchString(input.Email) {
+		utils.ErrorJSON(w, errors.New("valid email needed"), http.StatusBadRequest)
+		return
+	}
+
+	err = b.userService.UnlockAccount(ctx, input.Email)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "unlock account failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 
+	slog.InfoContext(r.Context(), "account unlocked", "unlocked_by", r.Context().Value(entities.UseridKeyValue), "email", input.Email)
+
+	_ = utils.DeserializeJSON(w, http.StatusOK, map[string]any{"msg": "account unlocked"})
 }
@@ -2,7 +2,6 @@ package controllers
 
 import (
 	"bytes"
-	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -11,6 +10,8 @@ import (
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/jwtkeys"
+	"github.com/bicosteve/booking-system/pkg/phone"
 	"github.com/bicosteve/booking-system/repo"
 	"github.com/bicosteve/booking-system/service"
 	"github.com/go-redis/redismock/v9"
@@ -25,12 +26,12 @@ func setupTestBase() (*Base, sqlmock.Sqlmock) {
 	}
 
 	repository := *repo.NewDBRepository(db, _db)
-	userService := service.NewUserService(repository)
+	userService := service.NewUserService(repository, entities.LockoutConfig{})
 	base := &Base{
 		userService: userService,
 		contentType: "application/json",
 		DB:          db,
-		jwtSecret:   "test-secret",
+		jwtKeys:     jwtkeys.NewHMAC("test-secret"),
 		sengridkey:  "test-key",
 		mailfrom:    "test@example.com",
 		atklng:      "test-key",
@@ -42,7 +43,7 @@ func setupTestBase() (*Base, sqlmock.Sqlmock) {
 func TestRegisterHandler(t *testing.T) {
 	var insertQuery = "" +
 		"INSERT INTO user(email,phone_number,isVender,hashed_password, " +
-		"created_at, updated_at, password_inserted_at) VALUES(?,?,?,?,NOW(),NOW(), NOW())"
+		"created_at, updated_at, password_inserted_at) VALUES(?,?,?,?,NOW(),NOW(), UTC_TIMESTAMP())"
 
 	tests := []struct {
 		name           string
@@ -55,7 +56,7 @@ func TestRegisterHandler(t *testing.T) {
 			name: "successful registration",
 			payload: entities.UserPayload{
 				Email:           "test@example.com",
-				PhoneNumber:     "1234567890",
+				PhoneNumber:     "0712 345 678",
 				IsVendor:        "false",
 				Password:        "password123",
 				ConfirmPassword: "password123",
@@ -68,7 +69,7 @@ func TestRegisterHandler(t *testing.T) {
 
 				mock.ExpectPrepare(insertQuery).
 					ExpectExec().
-					WithArgs("test@example.com", "1234567890", "false", sqlmock.AnyArg()).
+					WithArgs("test@example.com", "+254712345678", "false", sqlmock.AnyArg()).
 					WillReturnResult(sqlmock.NewResult(1, 1))
 			},
 
@@ -81,7 +82,7 @@ func TestRegisterHandler(t *testing.T) {
 			name: "invalid email",
 			payload: entities.UserPayload{
 				Email:           "invalid-email",
-				PhoneNumber:     "1234567890",
+				PhoneNumber:     "0712 345 678",
 				IsVendor:        "false",
 				Password:        "password123",
 				ConfirmPassword: "password123",
@@ -93,11 +94,27 @@ func TestRegisterHandler(t *testing.T) {
 				"message": "valid email needed",
 			},
 		},
+		{
+			name: "invalid phone number",
+			payload: entities.UserPayload{
+				Email:           "test@example.com",
+				PhoneNumber:     "1234567890",
+				IsVendor:        "false",
+				Password:        "password123",
+				ConfirmPassword: "password123",
+			},
+			setupMock:      func(mock sqlmock.Sqlmock) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody: map[string]any{
+				"error":   true,
+				"message": phone.ErrInvalidNumber.Error(),
+			},
+		},
 		{
 			name: "user already exists",
 			payload: entities.UserPayload{
 				Email:           "existing@example.com",
-				PhoneNumber:     "1234567890",
+				PhoneNumber:     "0712 345 678",
 				IsVendor:        "false",
 				Password:        "password123",
 				ConfirmPassword: "password123",
@@ -161,18 +178,24 @@ func TestLoginHandler(t *testing.T) {
 					WithArgs("test@gmail.com").
 					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
 
-				mock.ExpectPrepare("SELECT * FROM user WHERE email = ?").
+				mock.ExpectPrepare("SELECT user_id, email, phone_number, isVender, hashed_password, password_reset_token, created_at, updated_at, password_inserted_at, name, phone_verified_at FROM user WHERE email = ?").
 					ExpectQuery().
 					WithArgs("test@gmail.com").
 					WillReturnRows(sqlmock.NewRows([]string{
 						"id", "email", "phone_number", "isVender",
 						"password", "password_reset_token",
 						"created_at", "updated_at", "password_inserted_at",
+						"name", "phone_verified_at",
 					}).AddRow(
 						"3", "test@gmail.com", "0704961755", "NO",
 						"$2a$10$/r5qIMP1AkNOMdr495Ff0eCdrZWyW79Q5E3RxFVgCbk0ret4j4mDa", "",
-						mockTime, mockTime, mockTime,
+						mockTime, mockTime, mockTime, "", nil,
 					))
+
+				mock.ExpectPrepare("SELECT user_id, secret, enabled_at, last_step FROM user_mfa WHERE user_id = ?").
+					ExpectQuery().
+					WithArgs(3).
+					WillReturnRows(sqlmock.NewRows([]string{"user_id", "secret", "enabled_at", "last_step"}))
 			},
 			expectedStatus: http.StatusOK,
 		},
@@ -222,70 +245,41 @@ func TestLoginHandler(t *testing.T) {
 
 func TestProfileHandler(t *testing.T) {
 	mockTime := time.Now()
+	query := "SELECT user_id, email, phone_number, isVender, hashed_password, password_reset_token, created_at, updated_at, password_inserted_at, name, phone_verified_at FROM user WHERE user_id = ? AND deleted_at IS NULL"
+	columns := []string{
+		"id", "email", "phone_number", "isVender",
+		"password", "password_reset_token",
+		"created_at", "updated_at", "password_inserted_at",
+		"name", "phone_verified_at",
+	}
 	tests := []struct {
 		name           string
-		setupContext   func(context.Context) context.Context
 		setupMock      func(sqlmock.Sqlmock)
 		expectedStatus int
-		expectedBody   map[string]interface{}
 	}{
 		{
 			name: "successful profile retrieval",
-			setupContext: func(ctx context.Context) context.Context {
-				return context.WithValue(ctx, entities.UsernameKeyValue, "test@gmail.com")
-			},
 			setupMock: func(mock sqlmock.Sqlmock) {
-				mock.ExpectPrepare("SELECT * FROM user WHERE email = ?").
+				mock.ExpectPrepare(query).
 					ExpectQuery().
-					WithArgs("test@gmail.com").
-					WillReturnRows(sqlmock.NewRows([]string{
-						"id", "email", "phone_number", "isVender",
-						"password", "password_reset_token",
-						"created_at", "updated_at", "password_inserted_at",
-					}).AddRow(
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows(columns).AddRow(
 						"1", "test@gmail.com", "0704961755", "NO",
-						"$2a$10$/r5qIMP1AkNOMdr495Ff0eCdrZWyW79Q5E3RxFVgCbk0ret4j4mDa", "", mockTime, mockTime, mockTime,
+						"$2a$10$/r5qIMP1AkNOMdr495Ff0eCdrZWyW79Q5E3RxFVgCbk0ret4j4mDa", "reset-token",
+						mockTime, mockTime, mockTime, "Test User", nil,
 					))
 			},
 			expectedStatus: http.StatusOK,
-			expectedBody: map[string]interface{}{
-				"user": map[string]interface{}{
-					"id":                   "1",
-					"email":                "test@gmail.com",
-					"phone_number":         "0704961755",
-					"isVender":             "NO",
-					"password":             "$2a$10$/r5qIMP1AkNOMdr495Ff0eCdrZWyW79Q5E3RxFVgCbk0ret4j4mDa",
-					"password_reset_token": "",
-					"created_at":           "2025-03-02T09:20:55.482005+03:00",
-					"updated_at":           "2025-03-02T09:20:55.482005+03:00",
-					"password_inserted_at": "2025-03-02T09:20:55.482005+03:00",
-				},
-			},
 		},
 		{
-			name: "does not exist",
-			setupContext: func(ctx context.Context) context.Context {
-				return context.WithValue(ctx, entities.UsernameKeyValue, "testone@gmail.com")
-			},
+			name: "deleted account",
 			setupMock: func(mock sqlmock.Sqlmock) {
-				mock.ExpectPrepare("SELECT *  FROM user WHERE email = ?").
+				mock.ExpectPrepare(query).
 					ExpectQuery().
-					WithArgs("testone@gmail.com").
-					WillReturnRows(sqlmock.NewRows([]string{
-						"id", "email", "phone_number", "isVender",
-						"password", "password_reset_token",
-						"created_at", "updated_at", "password_inserted_at",
-					}).AddRow(
-						"", "", "", "", "", "", mockTime, mockTime, mockTime,
-					))
-			},
-			expectedStatus: http.StatusOK,
-			expectedBody: map[string]interface{}{
-				"user": map[string]interface{}{
-					"error":   true,
-					"message": "user is not available",
-				},
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows(columns))
 			},
+			expectedStatus: http.StatusNotFound,
 		},
 	}
 
@@ -294,18 +288,14 @@ func TestProfileHandler(t *testing.T) {
 			base, mock := setupTestBase()
 			tt.setupMock(mock)
 
-			req := httptest.NewRequest(http.MethodGet, "/profile", nil)
-			ctx := tt.setupContext(req.Context())
-			req = req.WithContext(ctx)
+			req := withUserID(httptest.NewRequest(http.MethodGet, "/profile", nil), "1")
 			w := httptest.NewRecorder()
 
 			base.ProfileHandler(w, req)
 
 			assert.Equal(t, tt.expectedStatus, w.Code)
-
-			// var response map[string]interface{}
-			// json.Unmarshal(w.Body.Bytes(), &response)
-			// assert.Equal(t, tt.expectedBody, response)
+			assert.NotContains(t, w.Body.String(), "reset-token")
+			assert.NotContains(t, w.Body.String(), "$2a$10$")
 
 			if err := mock.ExpectationsWereMet(); err != nil {
 				t.Errorf("there were unfulfilled expectations: %s", err)
@@ -315,3 +305,62 @@ func TestProfileHandler(t *testing.T) {
 }
 
 // Additional tests for GenerateResetTokenHandler and ResetPasswordHandler would follow the same pattern
+
+func TestLoginHandler_Locked(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	client, redisMock := redismock.NewClientMock()
+	redisMock.ExpectPTTL("login:lock:test@gmail.com").SetVal(90*time.Second + 200*time.Millisecond)
+
+	base := &Base{
+		userService: service.NewUserService(*repo.NewDBRepository(db, client), entities.LockoutConfig{}),
+		contentType: "application/json",
+		jwtKeys:     jwtkeys.NewHMAC("test-secret"),
+	}
+
+	payload, _ := json.Marshal(entities.UserPayload{Email: "test@gmail.com", Password: "1234"})
+	req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewBuffer(payload))
+	w := httptest.NewRecorder()
+
+	base.LoginHandler(w, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "91", w.Header().Get("Retry-After"))
+
+	var response map[string]any
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, entities.ErrAccountLocked.Error(), response["message"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.NoError(t, redisMock.ExpectationsWereMet())
+}
+
+func TestUnlockAccountHandler(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	client, redisMock := redismock.NewClientMock()
+	redisMock.ExpectDel("login:failures:test@gmail.com", "login:lock:test@gmail.com").SetVal(2)
+	expectAuditInsert(mock, entities.AuditUserUnlock)
+
+	base := &Base{
+		userService: service.NewUserService(*repo.NewDBRepository(db, client), entities.LockoutConfig{}),
+		contentType: "application/json",
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/users/unlock", bytes.NewBufferString(`{"email":"test@gmail.com"}`))
+	req = withUserID(req, "1")
+	w := httptest.NewRecorder()
+	base.UnlockAccountHandler(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/admin/users/unlock", bytes.NewBufferString(`{"email":"not-an-email"}`))
+	w = httptest.NewRecorder()
+	base.UnlockAccountHandler(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	assert.NoError(t, redisMock.ExpectationsWereMet())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
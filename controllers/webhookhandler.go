@@ -0,0 +1,292 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+// webhookDeliveriesLimit caps how many deliveries the delivery log returns.
+const webhookDeliveriesLimit = 100
+
+// webhookError writes err with the status matching it.
+func webhookError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, entities.ErrNoRecord):
+		utils.ErrorJSON(w, errors.New("webhook not found"), http.StatusNotFound)
+	case errors.Is(err, entities.ErrInvalidWebhook):
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+	default:
+		utils.ErrorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// Create webhook godoc
+// @Summary subscribe a URL to booking events
+// @Description Registers an HTTPS endpoint notified when bookings of the vendor's rooms are created, confirmed, cancelled or checked out. An empty events list subscribes to all of them. Each delivery is signed with the returned secret, which is only shown once.
+// @ID create-webhook
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param payload body entities.WebhookPayload true "{"url":"https://pms.example.com/hooks","events":["booking.created"]}"
+// @Success 201 {object} entities.Webhook "Created"
+// @Failure 400 {object} entities.JSONResponse "Bad request"
+// @Failure 401 {object} entities.JSONResponse "Unauthorized"
+// @Failure 500 {object} entities.JSONResponse "Internal server error"
+// @Router /api/admin/webhooks [post]
+func (b *Base) CreateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", b.contentType)
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	var payload entities.WebhookPayload
+	err := utils.SerializeJSON(w, r, &payload)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "create webhook failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := r.Context().Value(entities.UseridKeyValue).(string)
+	if !ok {
+		slog.ErrorContext(r.Context(), "could not get user_id from context")
+		utils.ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
+		return
+	}
+	vendorID, _ := strconv.Atoi(userID)
+
+	hook, err := b.webhookService.CreateWebhook(ctx, vendorID, payload)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "create webhook failed", "error", err)
+		webhookError(w, err)
+		return
+	}
+
+	_ = utils.DeserializeJSON(w, http.StatusCreated, map[string]any{"msg": "webhook created", "data": hook})
+}
+
+// List webhooks godoc
+// @Summary list the vendor's webhooks
+// @Description Returns the webhooks of the logged in vendor, without their secrets
+// @ID list-webhooks
+// @Tags webhooks
+// @Produce json
+// @Success 200 {array} entities.Webhook "Success"
+// @Failure 401 {object} entities.JSONResponse "Unauthorized"
+// @Failure 500 {object} entities.JSONResponse "Internal server error"
+// @Router /api/admin/webhooks [get]
+func (b *Base) GetWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", b.contentType)
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	userID, ok := r.Context().Value(entities.UseridKeyValue).(string)
+	if !ok {
+		slog.ErrorContext(r.Context(), "could not get user_id from context")
+		utils.ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
+		return
+	}
+	vendorID, _ := strconv.Atoi(userID)
+
+	hooks, err := b.webhookService.GetWebhooks(ctx, vendorID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "list webhooks failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	_ = utils.DeserializeJSON(w, http.StatusOK, map[string]any{"data": hooks})
+}
+
+// Update webhook godoc
+// @Summary update a webhook
+// @Description Changes the URL, event filter or active flag of one of the vendor's webhooks. Fields left out are kept.
+// @ID update-webhook
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param webhook_id path string true "Webhook ID"
+// @Param payload body entities.WebhookPayload true "{"active":false}"
+// @Success 200 {object} entities.Webhook "Success"
+// @Failure 400 {object} entities.JSONResponse "Bad request"
+// @Failure 401 {object} entities.JSONResponse "Unauthorized"
+// @Failure 404 {object} entities.JSONResponse "Not found"
+// @Router /api/admin/webhooks/{webhook_id} [put]
+func (b *Base) UpdateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", b.contentType)
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	webhookID, err := strconv.Atoi(chi.URLParam(r, "webhook_id"))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "update webhook failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	var payload entities.WebhookPayload
+	err = utils.SerializeJSON(w, r, &payload)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "update webhook failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := r.Context().Value(entities.UseridKeyValue).(string)
+	if !ok {
+		slog.ErrorContext(r.Context(), "could not get user_id from context")
+		utils.ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
+		return
+	}
+	vendorID, _ := strconv.Atoi(userID)
+
+	hook, err := b.webhookService.UpdateWebhook(ctx, vendorID, webhookID, payload)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "update webhook failed", "error", err)
+		webhookError(w, err)
+		return
+	}
+
+	_ = utils.DeserializeJSON(w, http.StatusOK, map[string]any{"msg": "webhook updated", "data": hook})
+}
+
+// Delete webhook godoc
+// @Summary delete a webhook
+// @Description Removes one of the vendor's webhooks along with its delivery log
+// @ID delete-webhook
+// @Tags webhooks
+// @Produce json
+// @Param webhook_id path string true "Webhook ID"
+// @Success 200 {object} entities.JSONResponse "Success"
+// @Failure 400 {object} entities.JSONResponse "Bad request"
+// @Failure 401 {object} entities.JSONResponse "Unauthorized"
+// @Failure 404 {object} entities.JSONResponse "Not found"
+// @Router /api/admin/webhooks/{webhook_id} [delete]
+func (b *Base) DeleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", b.contentType)
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	webhookID, err := strconv.Atoi(chi.URLParam(r, "webhook_id"))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "delete webhook failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := r.Context().Value(entities.UseridKeyValue).(string)
+	if !ok {
+		slog.ErrorContext(r.Context(), "could not get user_id from context")
+		utils.ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
+		return
+	}
+	vendorID, _ := strconv.Atoi(userID)
+
+	err = b.webhookService.DeleteWebhook(ctx, vendorID, webhookID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "delete webhook failed", "error", err)
+		webhookError(w, err)
+		return
+	}
+
+	_ = utils.DeserializeJSON(w, http.StatusOK, map[string]any{"msg": "webhook deleted"})
+}
+
+// Webhook deliveries godoc
+// @Summary list a webhook's deliveries
+// @Description Returns the latest deliveries of one of the vendor's webhooks, newest first, with their status, attempts and last response code
+// @ID webhook-deliveries
+// @Tags webhooks
+// @Produce json
+// @Param webhook_id path string true "Webhook ID"
+// @Success 200 {array} entities.WebhookDelivery "Success"
+// @Failure 400 {object} entities.JSONResponse "Bad request"
+// @Failure 401 {object} entities.JSONResponse "Unauthorized"
+// @Failure 404 {object} entities.JSONResponse "Not found"
+// @Router /api/admin/webhooks/{webhook_id}/deliveries [get]
+func (b *Base) GetWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", b.contentType)
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	webhookID, err := strconv.Atoi(chi.URLParam(r, "webhook_id"))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "list webhook deliveries failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := r.Context().Value(entities.UseridKeyValue).(string)
+	if !ok {
+		slog.ErrorContext(r.Context(), "could not get user_id from context")
+		utils.ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
+		return
+	}
+	vendorID, _ := strconv.Atoi(userID)
+
+	deliveries, err := b.webhookService.GetDeliveries(ctx, vendorID, webhookID, webhookDeliveriesLimit)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "list webhook deliveries failed", "error", err)
+		webhookError(w, err)
+		return
+	}
+
+	_ = utils.DeserializeJSON(w, http.StatusOK, map[string]any{"data": deliveries})
+}
+
+// Redeliver webhook godoc
+// @Summary resend a webhook delivery
+// @Description Sends a delivery again right away, whatever its status, and returns the outcome. A failed manual attempt is not retried.
+// @ID redeliver-webhook
+// @Tags webhooks
+// @Produce json
+// @Param webhook_id path string true "Webhook ID"
+// @Param delivery_id path string true "Delivery ID"
+// @Success 200 {object} entities.WebhookDelivery "Success"
+// @Failure 400 {object} entities.JSONResponse "Bad request"
+// @Failure 401 {object} entities.JSONResponse "Unauthorized"
+// @Failure 404 {object} entities.JSONResponse "Not found"
+// @Router /api/admin/webhooks/{webhook_id}/deliveries/{delivery_id}/redeliver [post]
+func (b *Base) RedeliverWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", b.contentType)
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	webhookID, err := strconv.Atoi(chi.URLParam(r, "webhook_id"))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "redeliver webhook failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	deliveryID, err := strconv.Atoi(chi.URLParam(r, "delivery_id"))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "redeliver webhook failed", "error", err)
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := r.Context().Value(entities.UseridKeyValue).(string)
+	if !ok {
+		slog.ErrorContext(r.Context(), "could not get user_id from context")
+		utils.ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
+		return
+	}
+	vendorID, _ := strconv.Atoi(userID)
+
+	delivery, err := b.webhookService.Redeliver(ctx, vendorID, webhookID, deliveryID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "redeliver webhook failed", "error", err)
+		webhookError(w, err)
+		return
+	}
+
+	_ = utils.DeserializeJSON(w, http.StatusOK, map[string]any{"msg": "delivery attempted", "data": delivery})
+}
@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/repo"
+	"github.com/bicosteve/booking-system/service"
+	"github.com/go-redis/redismock/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupWebhookBase(t *testing.T) (*Base, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock db: %v", err)
+	}
+	rdb, _ := redismock.NewClientMock()
+	repository := *repo.NewDBRepository(db, rdb)
+
+	base := &Base{
+		webhookService: service.NewWebhookService(repository, entities.WebhookConfig{}),
+		contentType:    "application/json",
+		DB:             db,
+	}
+	return base, mock
+}
+
+func TestCreateWebhookHandler(t *testing.T) {
+	t.Run("created", func(t *testing.T) {
+		base, mock := setupWebhookBase(t)
+		mock.ExpectPrepare("INSERT INTO webhook").ExpectExec().
+			WithArgs(7, "https://pms.example.com/hooks", sqlmock.AnyArg(), "", true).
+			WillReturnResult(sqlmock.NewResult(3, 1))
+
+		req := httptest.NewRequest(http.MethodPost, "/admin/webhooks", strings.NewReader(`{"url":"https://pms.example.com/hooks"}`))
+		req = withUserID(req, "7")
+		w := httptest.NewRecorder()
+
+		base.CreateWebhookHandler(w, req)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var resp struct {
+			Data entities.Webhook `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Contains(t, resp.Data.Secret, "whsec_")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("invalid url", func(t *testing.T) {
+		base, _ := setupWebhookBase(t)
+
+		req := httptest.NewRequest(http.MethodPost, "/admin/webhooks", strings.NewReader(`{"url":"ftp://pms.example.com"}`))
+		req = withUserID(req, "7")
+		w := httptest.NewRecorder()
+
+		base.CreateWebhookHandler(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestGetWebhooksHandler(t *testing.T) {
+	base, mock := setupWebhookBase(t)
+	now := time.Now()
+	mock.ExpectPrepare("SELECT webhook_id").ExpectQuery().WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"webhook_id", "vendor_id", "url", "events", "active", "created_at", "updated_at"}).
+			AddRow(3, 7, "https://pms.example.com/hooks", "booking.created", true, now, now))
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/webhooks", nil)
+	req = withUserID(req, "7")
+	w := httptest.NewRecorder()
+
+	base.GetWebhooksHandler(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Data []entities.Webhook `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, []string{"booking.created"}, resp.Data[0].Events)
+	assert.NotContains(t, w.Body.String(), "secret")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteWebhookHandler_NotFound(t *testing.T) {
+	base, mock := setupWebhookBase(t)
+	mock.ExpectPrepare("DELETE FROM webhook").ExpectExec().WithArgs(3, 7).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	req := httptest.NewRequest(http.MethodDelete, "/admin/webhooks/3", nil)
+	req = withUserID(withURLParam(req, "webhook_id", "3"), "7")
+	w := httptest.NewRecorder()
+
+	base.DeleteWebhookHandler(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
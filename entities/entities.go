@@ -1,39 +1,51 @@
 package entities
 
 import (
+	"encoding/json"
 	"errors"
-	"log"
-	"os"
 	"regexp"
 	"time"
 
+	"github.com/bicosteve/booking-system/pkg/money"
 	"github.com/golang-jwt/jwt/v5"
-	"github.com/streadway/amqp"
 )
 
 type User struct {
-	ID                 string    `json:"id"`
-	Email              string    `json:"email"`
-	PhoneNumber        string    `json:"phone_number"`
-	IsVender           string    `json:"isVender"`
-	Password           string    `json:"-"`
-	PasswordResetToken string    `json:"password_reset_token"`
-	CreatedAt          time.Time `json:"created_at"`
-	UpdatedAt          time.Time `json:"updated_at"`
-	PasswordInsertedAt time.Time `json:"password_inserted_at"`
+	ID                 string     `json:"id"`
+	Email              string     `json:"email"`
+	PhoneNumber        string     `json:"phone_number"`
+	Name               string     `json:"name"`
+	IsVender           string     `json:"isVender"`
+	Password           string     `json:"-"`
+	PasswordResetToken string     `json:"-"`
+	PhoneVerifiedAt    *time.Time `json:"phone_verified_at,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+	PasswordInsertedAt time.Time  `json:"password_inserted_at"`
 }
 
 type Config struct {
-	App     AppConfig        `toml:"app"`
-	Logger  LoggerConfig     `toml:"logger"`
-	Notify  NotifyConfig     `toml:"notify"`
-	Http    []HttpConfig     `toml:"http"`
-	Mysql   []MysqlConfig    `toml:"mysql"`
-	Redis   []RedisConfig    `toml:"redis"`
-	Kafka   []KakfaConfig    `toml:"kafka"`
-	Secrets []SecretConfig   `toml:"secrets"`
-	Stripe  []StripeConfig   `toml:"stripe"`
-	Rabbit  []RabbitMQConfig `toml:"rabbitmq"`
+	App      AppConfig          `toml:"app"`
+	Logger   LoggerConfig       `toml:"logger"`
+	Notify   NotifyConfig       `toml:"notify"`
+	Phone    PhoneConfig        `toml:"phone"`
+	Http     []HttpConfig       `toml:"http"`
+	Mysql    []MysqlConfig      `toml:"mysql"`
+	Redis    []RedisConfig      `toml:"redis"`
+	Kafka    []KakfaConfig      `toml:"kafka"`
+	Secrets  []SecretConfig     `toml:"secrets"`
+	Stripe   []StripeConfig     `toml:"stripe"`
+	Rabbit   []RabbitMQConfig   `toml:"rabbitmq"`
+	Payouts  []PayoutConfig     `toml:"payouts"`
+	Rates    []RatesConfig      `toml:"rates"`
+	Tracing  []TracingConfig    `toml:"tracing"`
+	Health   []HealthConfig     `toml:"health"`
+	Webhook  []WebhookConfig    `toml:"webhooks"`
+	Calendar []CalendarConfig   `toml:"calendars"`
+	Limits   []RateLimitConfig  `toml:"ratelimits"`
+	Lockout  []LockoutConfig    `toml:"lockout"`
+	OIDC     []OIDCConfig       `toml:"oidc"`
+	JWTKeys  []SigningKeyConfig `toml:"jwtkeys"`
 }
 
 type AppConfig struct {
@@ -41,6 +53,7 @@ type AppConfig struct {
 	Version   string   `toml:"version"`
 	Enable    bool     `toml:"enable"`
 	Developer []string `toml:"developer"`
+	Admins    []int    `toml:"admins"` // user ids of platform admins, who may unlock accounts and settle payouts
 	Args      args
 }
 
@@ -62,6 +75,13 @@ type NotifyConfig struct {
 	SmsClientSecret string       `toml:"sms_client_secret"`
 }
 
+// PhoneConfig sets the country national phone numbers are read as, e.g.
+// "0712345678" as +254712345678 for KE. Numbers given with a + or 00 are
+// taken as they are.
+type PhoneConfig struct {
+	DefaultRegion string `toml:"default_region"`
+}
+
 type PrefConfig struct {
 	Event   string   `toml:"event"`
 	Channel string   `toml:"channel"`
@@ -82,6 +102,9 @@ type HttpConfig struct {
 	} `toml:"cors"`
 	Args        args   `toml:"args"`
 	ContentType string `toml:"contenttype"`
+	// TrustProxy takes the client IP from the last X-Forwarded-For entry.
+	// Set it only behind a proxy that appends to that header.
+	TrustProxy bool `toml:"trustproxy"`
 }
 
 type MysqlConfig struct {
@@ -94,6 +117,69 @@ type MysqlConfig struct {
 	Args     args   `toml:"args"`
 }
 
+// TracingConfig selects where spans are exported. Exporter is "otlp",
+// "stdout" or "file"; empty turns tracing off.
+type TracingConfig struct {
+	Name        string  `toml:"name"`
+	ServiceName string  `toml:"servicename"`
+	Exporter    string  `toml:"exporter"`
+	Endpoint    string  `toml:"endpoint"` // OTLP/HTTP collector host:port
+	Insecure    bool    `toml:"insecure"`
+	File        string  `toml:"file"`
+	SampleRatio float64 `toml:"sampleratio"` // 0 samples everything
+}
+
+// HealthConfig tunes the background readiness checker. Optional lists the
+// dependencies whose outage degrades readiness instead of failing it.
+type HealthConfig struct {
+	Name     string   `toml:"name"`
+	Interval string   `toml:"interval"` // e.g. "15s"
+	Optional []string `toml:"optional"` // e.g. ["kafka"]
+}
+
+type WebhookConfig struct {
+	Name         string `toml:"name"`
+	Interval     string `toml:"interval"`     // how often due deliveries are sent, e.g. "10s"
+	Timeout      string `toml:"timeout"`      // per request, e.g. "10s"
+	MaxAttempts  int    `toml:"maxattempts"`  // before a delivery is marked FAILED
+	AllowPrivate bool   `toml:"allowprivate"` // allow local URLs; development only
+}
+
+type CalendarConfig struct {
+	Name         string `toml:"name"`
+	Interval     string `toml:"interval"`     // how often external calendars are imported, e.g. "30m"
+	Timeout      string `toml:"timeout"`      // per fetch, e.g. "20s"
+	AllowPrivate bool   `toml:"allowprivate"` // allow local URLs; development only
+}
+
+// OIDCConfig is an OpenID Connect provider users can log in with. Name is
+// used in the login URL; a provider named "google" needs no Issuer.
+type OIDCConfig struct {
+	Name         string   `toml:"name"`
+	Issuer       string   `toml:"issuer"`
+	ClientID     string   `toml:"clientid"`
+	ClientSecret string   `toml:"clientsecret"`
+	RedirectURL  string   `toml:"redirecturl"` // our callback: <base>/api/user/oidc/<name>/callback
+	Scopes       []string `toml:"scopes"`      // asked for on top of openid, email and profile
+}
+
+// RateLimitConfig limits requests to one route. Each key is counted on its
+// own, so a rule keyed by ip and email allows Limit requests per IP and Limit
+// per email in every Window.
+type RateLimitConfig struct {
+	Name   string   `toml:"name"`   // route: login, register, reset, password-reset, mfa or profile
+	Limit  int      `toml:"limit"`  // requests allowed per window
+	Window string   `toml:"window"` // e.g. "1m"
+	Keys   []string `toml:"keys"`   // ip, user and/or email
+}
+
+type LockoutConfig struct {
+	Name      string `toml:"name"`
+	Threshold int    `toml:"threshold"` // failed logins in a row before the account locks
+	BaseDelay string `toml:"basedelay"` // first lock, doubled on every further failure, e.g. "1m"
+	MaxDelay  string `toml:"maxdelay"`  // longest lock, e.g. "1h"
+}
+
 type StripeConfig struct {
 	Name         string `toml:"name"`
 	StripeSecret string `toml:"stripesecret"`
@@ -121,8 +207,11 @@ type KakfaConfig struct {
 	SaslMechanism    string   `toml:"saslmechanism"`    // default "SCRAM-SHA-256"
 	SaslUsername     string   `toml:"saslusername"`
 	SaslPassword     string   `toml:"saslpassword"`
-	CaPem            string   `toml:"capem"`      // inline CA PEM (ssl.ca.pem)
-	CaLocation       string   `toml:"calocation"` // optional file path; precedence
+	CaPem            string   `toml:"capem"`          // inline CA PEM (ssl.ca.pem)
+	CaLocation       string   `toml:"calocation"`     // optional file path; precedence
+	Idempotent       bool     `toml:"idempotent"`     // enable.idempotence: no duplicates or reordering on retry
+	TopicKeys        []string `toml:"topickeys"`      // per-topic default keys, e.g. ["payment_two=payments"]
+	PublishTimeout   string   `toml:"publishtimeout"` // sync publish wait, e.g. "10s"
 }
 
 type RabbitMQConfig struct {
@@ -137,16 +226,26 @@ type RabbitMQConfig struct {
 	TLS        bool   `toml:"tls"`
 	CaPem      string `toml:"capem"`
 	CaLocation string `toml:"calocation"`
+	PoolSize   int    `toml:"poolsize"` // publisher channels kept open
+	Prefetch   int    `toml:"prefetch"` // unacked deliveries per consumer
+}
+
+type PayoutConfig struct {
+	Name          string `toml:"name"`
+	CommissionBps int    `toml:"commissionbps"` // platform cut in basis points, 1500 => 15%
+	Interval      string `toml:"interval"`      // payout batch interval, e.g. "24h"
+	Minimum       int64  `toml:"minimum"`       // smallest payout in minor units
 }
 
-type RabbitMQ struct {
-	Connection *amqp.Connection
-	Channel    *amqp.Channel
+type RatesConfig struct {
+	Name string `toml:"name"`
+	File string `toml:"file"` // JSON exchange rates used to present prices in a guest's currency
 }
 
 type SecretConfig struct {
 	Name           string `toml:"name"`
 	JWT            string `toml:"jwt"`
+	JWTUntil       string `toml:"jwtuntil"` // RFC 3339; HS256 tokens are refused from then on
 	Sendgrid       string `toml:"sendgrid"`
 	MailFrom       string `toml:"mailfrom"`
 	AfricasTalking string `toml:"atklng"`
@@ -156,6 +255,18 @@ type SecretConfig struct {
 	StripeSecret   string `toml:"stripesecret"`
 }
 
+// SigningKeyConfig is a key auth tokens are signed with. File, or PEM for
+// env vars, holds a PKCS#8 RSA or Ed25519 private key. ActiveFrom and
+// RetireAt are RFC 3339 times; the key signs from ActiveFrom and verifies
+// until RetireAt. Empty means straight away and never.
+type SigningKeyConfig struct {
+	ID         string `toml:"kid"`
+	File       string `toml:"file"`
+	PEM        string `toml:"pem"`
+	ActiveFrom string `toml:"activefrom"`
+	RetireAt   string `toml:"retireat"`
+}
+
 type UserPayload struct {
 	Email           string `json:"email"`
 	PhoneNumber     string `json:"phone_number"`
@@ -165,27 +276,26 @@ type UserPayload struct {
 }
 
 type RoomPayload struct {
-	Cost   string `json:"cost"`
-	Status string `json:"status"`
-	Vendor int    `json:"vendor"`
+	Cost     string `json:"cost"`     // nightly rate in major units, e.g. "7000" or "49.99"
+	Currency string `json:"currency"` // ISO 4217 code the vendor settles in, defaults to KES
+	Status   string `json:"status"`
+	Vendor   int    `json:"vendor"`
 }
 
 type Room struct {
-	ID        string    `json:"id"`
-	Cost      float64   `json:"cost"`
-	Status    string    `json:"status"`
-	VenderId  string    `json:"vender_id"`
-	CreateAt  time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID          string       `json:"id"`
+	Cost        money.Money  `json:"cost"`
+	DisplayCost *money.Money `json:"display_cost,omitempty"`
+	Status      string       `json:"status"`
+	Rating      float64      `json:"rating"`
+	ReviewCount int          `json:"review_count"`
+	VenderId    string       `json:"vender_id"`
+	CreateAt    time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
 }
 
 type Envelope map[string]interface{}
 
-type Message struct {
-	InfoLog  *log.Logger
-	ErrorLog *log.Logger
-}
-
 type JSONResponse struct {
 	Error   bool   `json:"error"`
 	Message string `json:"message"`
@@ -202,6 +312,11 @@ type Claims struct {
 	UserID      string `json:"user_id"`
 	IsVendor    string `json:"is_vendor"`
 	PhoneNumber string `json:"phone_number"`
+	// MFA is set once the user has passed a second factor. MFAPending marks
+	// the short-lived token returned by login until they do; it is only
+	// accepted for completing the login.
+	MFA        bool `json:"mfa,omitempty"`
+	MFAPending bool `json:"mfa_pending,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -225,21 +340,46 @@ type Filters struct {
 }
 
 type BookingPayload struct {
-	Days   *int     `json:"days,omitempty"`
-	UserID *int     `json:"user_id,omitempty"`
-	RoomID *int     `json:"room_id,omitempty"`
-	Amount *float64 `json:"amount,omitempty"`
-	Status *int     `json:"status,omitempty"`
+	CheckIn  *string `json:"check_in,omitempty"` // YYYY-MM-DD, defaults to today
+	Days     *int    `json:"days,omitempty"`
+	UserID   *int    `json:"user_id,omitempty"`
+	RoomID   *int    `json:"room_id,omitempty"`
+	Currency *string `json:"currency,omitempty"`
+	Status   *int    `json:"status,omitempty"`
 }
 
 type Booking struct {
-	ID        int       `json:"id"`
-	Days      int       `json:"days"`
-	UserID    int       `json:"user_id"`
-	RoomID    int       `json:"room_id"`
-	VenderID  int       `json:"vender_id,omitempty"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdateAt  time.Time `json:"updated_at"`
+	ID           int        `json:"id"`
+	Days         int        `json:"days"`
+	UserID       int        `json:"user_id"`
+	RoomID       int        `json:"room_id"`
+	Currency     string     `json:"currency"`
+	Status       int        `json:"status"`
+	VenderID     int        `json:"vender_id,omitempty"`
+	CheckedInAt  *time.Time `json:"checked_in_at,omitempty"`
+	CheckedOutAt *time.Time `json:"checked_out_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdateAt     time.Time  `json:"updated_at"`
+
+	History []*BookingStatusChange `json:"history,omitempty"`
+}
+
+// BookingActor is whoever moves a booking to a new status. ID is empty for
+// the system.
+type BookingActor struct {
+	Role string
+	ID   int
+}
+
+type BookingStatusChange struct {
+	ID         int       `json:"id"`
+	BookingID  int       `json:"booking_id"`
+	FromStatus int       `json:"from_status"`
+	ToStatus   int       `json:"to_status"`
+	Actor      string    `json:"actor"`
+	ActorID    *int      `json:"actor_id,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
 }
 
 type TRXPayload struct {
@@ -254,28 +394,31 @@ type TRXPayload struct {
 }
 
 type PaymentBody struct {
-	Amount      int64  `json:"amount"`
+	Amount      int64  `json:"amount"` // minor units of Currency
 	Currency    string `json:"currency"`
 	Customer    int    `json:"customer"`
 	Description string `json:"description"`
 }
 
 type Transaction struct {
-	ID        int       `json:"id"`
-	UserID    int       `json:"user_id"`
-	RoomID    int       `json:"room_id"`
-	Amount    float64   `json:"amount"`
-	Reference string    `json:"reference"`
-	Status    int       `json:"status"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        int         `json:"id"`
+	UserID    int         `json:"user_id"`
+	RoomID    int         `json:"room_id"`
+	OrderID   string      `json:"order_id"`
+	TrxID     string      `json:"trx_id"`
+	Amount    money.Money `json:"amount"`
+	Reference string      `json:"reference"`
+	Status    int         `json:"status"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
 }
 
 type Payment struct {
 	OrderID       string    `json:"order_id"`
 	UserID        int       `json:"user_id"`
 	PaymentId     string    `json:"payment_id"`
-	Amount        float64   `json:"amount"`
+	Amount        int64     `json:"amount"`
+	Currency      string    `json:"currency"`
 	ClientSecret  string    `json:"client_secret"`
 	TransactionID string    `json:"transaction_id"`
 	CustomerId    int       `json:"customer_id"`
@@ -288,10 +431,341 @@ type Payment struct {
 	UpdatedAt     time.Time `json:"updated_at"`
 }
 
+type LedgerEntry struct {
+	ID        int       `json:"id"`
+	Reference string    `json:"reference"`
+	VendorID  int       `json:"vendor_id"`
+	Account   string    `json:"account"`
+	Direction string    `json:"direction"`
+	Amount    int64     `json:"amount"`
+	Currency  string    `json:"currency"`
+	Kind      string    `json:"kind"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Payout struct {
+	ID        int        `json:"id"`
+	VendorID  int        `json:"vendor_id"`
+	Amount    int64      `json:"amount"`
+	Currency  string     `json:"currency"`
+	Status    string     `json:"status"`
+	CreatedAt time.Time  `json:"created_at"`
+	PaidAt    *time.Time `json:"paid_at,omitempty"`
+}
+
+type VendorBalance struct {
+	VendorID  int    `json:"vendor_id"`
+	Currency  string `json:"currency"`
+	Available int64  `json:"available"`
+	Pending   int64  `json:"pending"`
+	PaidOut   int64  `json:"paid_out"`
+}
+
+// ReviewRatings are a guest's star ratings per category, each from 1 to 5.
+type ReviewRatings struct {
+	Cleanliness int `json:"cleanliness"`
+	Comfort     int `json:"comfort"`
+	Location    int `json:"location"`
+	Value       int `json:"value"`
+}
+
+type ReviewPayload struct {
+	BookingID *int          `json:"booking_id,omitempty"`
+	Ratings   ReviewRatings `json:"ratings"`
+	Text      string        `json:"text"`
+}
+
+type Review struct {
+	ID          int           `json:"id"`
+	BookingID   int           `json:"booking_id"`
+	RoomID      int           `json:"room_id"`
+	VendorID    int           `json:"vendor_id"`
+	UserID      int           `json:"user_id"`
+	Ratings     ReviewRatings `json:"ratings"`
+	Rating      float64       `json:"rating"`
+	Text        string        `json:"text"`
+	VendorReply *string       `json:"vendor_reply,omitempty"`
+	RepliedAt   *time.Time    `json:"replied_at,omitempty"`
+	Flagged     bool          `json:"flagged"`
+	FlagReason  string        `json:"flag_reason,omitempty"`
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+}
+
+// RatingSummary aggregates the visible reviews of a room or vendor.
+type RatingSummary struct {
+	Rating      float64 `json:"rating"`
+	ReviewCount int     `json:"review_count"`
+	Cleanliness float64 `json:"cleanliness"`
+	Comfort     float64 `json:"comfort"`
+	Location    float64 `json:"location"`
+	Value       float64 `json:"value"`
+}
+
+// WebhookPayload creates or updates a webhook subscription. An empty Events
+// list subscribes to every booking event.
+type WebhookPayload struct {
+	URL    *string  `json:"url,omitempty"`
+	Events []string `json:"events,omitempty"`
+	Active *bool    `json:"active,omitempty"`
+}
+
+// Webhook is a vendor's subscription to booking events. Secret signs the
+// deliveries; it is only returned when the subscription is created.
+type Webhook struct {
+	ID        int       `json:"id"`
+	VendorID  int       `json:"vendor_id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"`
+	Events    []string  `json:"events"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// WebhookDelivery is one event sent, or to be sent, to a webhook.
+// ResponseCode is the receiver's answer to the last attempt.
+type WebhookDelivery struct {
+	ID            int        `json:"id"`
+	WebhookID     int        `json:"webhook_id"`
+	EventID       string     `json:"event_id"`
+	EventType     string     `json:"event_type"`
+	Payload       string     `json:"-"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	ResponseCode  *int       `json:"response_code,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+	DeliveredAt   *time.Time `json:"delivered_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+
+	// URL and Secret of the webhook, loaded for sending.
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
+
+// RoomBlock takes a room off sale from Start up to, but not including, End.
+// Blocks imported from an external calendar carry its CalendarID and the UID
+// of the event they came from.
+type RoomBlock struct {
+	ID          int       `json:"id"`
+	RoomID      int       `json:"room_id"`
+	Start       time.Time `json:"start_date"`
+	End         time.Time `json:"end_date"`
+	Reason      string    `json:"reason"`
+	Note        string    `json:"note,omitempty"`
+	CalendarID  *int      `json:"calendar_id,omitempty"`
+	ExternalUID string    `json:"external_uid,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// RoomCalendar is an external iCalendar feed, e.g. from Airbnb, imported
+// into a room's blocks.
+type RoomCalendar struct {
+	ID           int        `json:"id"`
+	RoomID       int        `json:"room_id"`
+	URL          string     `json:"url"`
+	LastSyncedAt *time.Time `json:"last_synced_at,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// RoomBlockPayload creates or updates a room block. Dates are YYYY-MM-DD;
+// end_date is the first day the room is available again.
+type RoomBlockPayload struct {
+	StartDate *string `json:"start_date,omitempty"`
+	EndDate   *string `json:"end_date,omitempty"`
+	Reason    *string `json:"reason,omitempty"`
+	Note      *string `json:"note,omitempty"`
+}
+
+// CalendarEntry is a confirmed stay or a block in a room's calendar. Status
+// is set for stays, Reason for blocks.
+type CalendarEntry struct {
+	Kind       string    `json:"kind"`
+	ID         int       `json:"id"`
+	Start      time.Time `json:"start_date"`
+	End        time.Time `json:"end_date"`
+	Status     string    `json:"status,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+	Note       string    `json:"note,omitempty"`
+	CalendarID *int      `json:"calendar_id,omitempty"`
+}
+
+// DateRange is a span of nights from Start up to, but not including, End.
+type DateRange struct {
+	Start time.Time `json:"start_date"`
+	End   time.Time `json:"end_date"`
+}
+
+// LockoutError is returned for logins to an account locked after too many
+// failed attempts. It matches ErrAccountLocked.
+type LockoutError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockoutError) Error() string { return ErrAccountLocked.Error() }
+
+func (e *LockoutError) Unwrap() error { return ErrAccountLocked }
+
+// UserMFA is a user's TOTP second factor. It is enrolled but not in use
+// until EnabledAt is set by a first valid code. LastStep is the time step
+// of the last accepted code, so a code cannot be used twice.
+type UserMFA struct {
+	UserID    int
+	Secret    string
+	EnabledAt *time.Time
+	LastStep  int64
+}
+
+// MFAEnrolment is what an authenticator app needs to add the account. URI
+// is shown as a QR code.
+type MFAEnrolment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// MFAPayload carries a TOTP code, or a recovery code in its place. MFAToken
+// is the pending token from login when finishing a login.
+type MFAPayload struct {
+	MFAToken     string `json:"mfa_token"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// OIDCState is kept between sending a user to a provider and the callback.
+// Verifier is the PKCE secret and Nonce is checked against the ID token.
+type OIDCState struct {
+	Provider string `json:"provider"`
+	Verifier string `json:"verifier"`
+	Nonce    string `json:"nonce"`
+}
+
+// APIKey lets a vendor's scripts call the admin API. Key is the full
+// bk_<prefix>_<secret> value and is only set when the key is created.
+type APIKey struct {
+	ID         int        `json:"id"`
+	VendorID   int        `json:"vendor_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	SecretHash string     `json:"-"`
+	Key        string     `json:"key,omitempty"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// ProfilePayload changes the logged-in user's profile; fields left out are
+// kept. A new phone number replaces the old one only once the code sent to
+// it is confirmed.
+type ProfilePayload struct {
+	Name        *string `json:"name"`
+	PhoneNumber *string `json:"phone_number"`
+}
+
+// EmailChangePayload asks to move an account to a new email. The change is
+// made once the link mailed to the new address is followed.
+type EmailChangePayload struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// PasswordChangePayload changes the password of a logged-in user.
+type PasswordChangePayload struct {
+	CurrentPassword string `json:"current_password"`
+	Password        string `json:"password"`
+	ConfirmPassword string `json:"confirm_password"`
+}
+
+// PendingChange is a new email or phone number waiting to be confirmed.
+// CodeHash is the SHA-256 hex of the code sent to Value.
+type PendingChange struct {
+	UserID   int    `json:"user_id"`
+	Value    string `json:"value"`
+	CodeHash string `json:"code_hash"`
+	Attempts int    `json:"attempts"`
+}
+
+// UserIdentity is an external login linked to an account.
+type UserIdentity struct {
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OutboxMessage is a text message queued for a user.
+type OutboxMessage struct {
+	ID        int       `json:"id"`
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PersonalDataExport is everything stored about a user, returned for data
+// subject access requests under Kenya's Data Protection Act.
+type PersonalDataExport struct {
+	ExportedAt   time.Time        `json:"exported_at"`
+	Profile      *User            `json:"profile"`
+	TwoFactor    bool             `json:"two_factor_enabled"`
+	Identities   []*UserIdentity  `json:"linked_identities"`
+	Bookings     []*Booking       `json:"bookings"`
+	Reviews      []*Review        `json:"reviews"`
+	Transactions []*Transaction   `json:"transactions"`
+	Messages     []*OutboxMessage `json:"messages"`
+	APIKeys      []*APIKey        `json:"api_keys,omitempty"`
+}
+
+// AuditEntry records one privileged or financial change: who made it, from
+// where, to which entity, and the fields it changed. Before and After hold
+// only the changed fields; Before is empty for creations and After for
+// deletions. VendorID is the vendor whose data changed, when there is one.
+type AuditEntry struct {
+	ID         int64           `json:"id"`
+	ActorType  string          `json:"actor_type"`
+	ActorID    *int            `json:"actor_id,omitempty"`
+	APIKeyID   *int            `json:"api_key_id,omitempty"`
+	VendorID   *int            `json:"vendor_id,omitempty"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entity_type"`
+	EntityID   string          `json:"entity_id"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	IP         string          `json:"ip,omitempty"`
+	RequestID  string          `json:"request_id,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// AuditFilter narrows an audit log query to one vendor's entries, plus every
+// platform admin's entries when PlatformAdmin is set. Empty fields match
+// everything; From is inclusive and To exclusive. Entries come newest first,
+// at most Limit of them older than BeforeID when it is set.
+type AuditFilter struct {
+	VendorID      int
+	PlatformAdmin bool
+	ActorID       *int
+	Action        string
+	EntityType    string
+	EntityID      string
+	From          time.Time
+	To            time.Time
+	BeforeID      int64
+	Limit         int
+}
+
+// APIKeyPayload creates an API key. expires_at is YYYY-MM-DD and defaults
+// to 90 days from now.
+type APIKeyPayload struct {
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	ExpiresAt *string  `json:"expires_at,omitempty"`
+}
+
 type args map[string]interface{}
 
-var InfoLog = log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
-var ErrorLog = log.New(os.Stdout, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
 var EmailRegex = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
 
 var ErrNoRecord = errors.New("MODELS: no matching record found")
@@ -299,6 +773,46 @@ var ErrDuplicateEmail = errors.New("MODELS: user already exists")
 var ErrorInvalidCredentials = errors.New("MODELS: incorrect password or email")
 var ErrorDBConnection = errors.New("DB: could not connect db becacuse ")
 var ErrorDBPing = errors.New("DB: could not ping db because ")
+var ErrInvalidTransition = errors.New("BOOKING: invalid status transition")
+var ErrTransitionForbidden = errors.New("BOOKING: not allowed to make this status change")
+var ErrReviewExists = errors.New("REVIEW: booking has already been reviewed")
+var ErrReviewNotAllowed = errors.New("REVIEW: only checked out bookings can be reviewed")
+var ErrUnbalancedJournal = errors.New("LEDGER: journal debits and credits do not balance")
+var ErrDuplicateTransaction = errors.New("PAYMENT: transaction already stored")
+var ErrTransactionNotPaid = errors.New("PAYMENT: only paid transactions can be refunded")
+var ErrAlreadyRefunded = errors.New("PAYMENT: payment already refunded")
+var ErrJournalExists = errors.New("LEDGER: journal already posted")
+var ErrPayoutExceedsBalance = errors.New("LEDGER: payout is more than the vendor's payable balance")
+var ErrInvalidWebhook = errors.New("WEBHOOK: invalid subscription")
+var ErrRoomBlocked = errors.New("BOOKING: room is not available for those dates")
+var ErrBookingLocked = errors.New("BOOKING: nights can only be shortened while the booking is unpaid")
+var ErrInvalidCalendar = errors.New("CALENDAR: invalid calendar")
+var ErrInvalidBlock = errors.New("BLOCK: invalid room block")
+var ErrBlockOverlapsStay = errors.New("BLOCK: room has confirmed stays on those dates")
+var ErrBlockImported = errors.New("BLOCK: imported blocks change only through their calendar")
+var ErrAccountLocked = errors.New("AUTH: too many failed logins, try again later")
+var ErrMFARequired = errors.New("AUTH: two-factor authentication required")
+var ErrInvalidMFACode = errors.New("AUTH: invalid two-factor code")
+var ErrMFAEnabled = errors.New("AUTH: two-factor authentication is already enabled")
+var ErrMFANotEnrolled = errors.New("AUTH: two-factor authentication is not set up")
+var ErrOIDCState = errors.New("AUTH: login expired or was already used, start again")
+var ErrOIDCLogin = errors.New("AUTH: external login failed")
+var ErrEmailNotVerified = errors.New("AUTH: the provider has not verified this email")
+var ErrNoAccount = errors.New("AUTH: no account with this email, register first")
+var ErrInvalidAPIKey = errors.New("AUTH: invalid api key")
+var ErrInvalidAPIKeyRequest = errors.New("AUTH: invalid api key request")
+var ErrScopeMissing = errors.New("AUTH: api key lacks the scope for this request")
+var ErrAPIKeyNotAllowed = errors.New("AUTH: api keys cannot be used here")
+var ErrPlatformAdminOnly = errors.New("AUTH: only platform admins can do this")
+var ErrSessionRevoked = errors.New("AUTH: session has ended, log in again")
+var ErrWrongPassword = errors.New("AUTH: current password is incorrect")
+var ErrDuplicatePhone = errors.New("MODELS: phone number already in use")
+var ErrInvalidProfile = errors.New("PROFILE: invalid profile change")
+var ErrNoPendingChange = errors.New("PROFILE: nothing waiting for confirmation, request the change again")
+var ErrInvalidCode = errors.New("PROFILE: invalid confirmation code")
+var ErrPhoneVerified = errors.New("PROFILE: phone number is already verified")
+var ErrAccountInUse = errors.New("PROFILE: account has bookings in progress, finish or cancel them first")
+var ErrVendorDeletion = errors.New("PROFILE: vendor accounts are closed by support once payouts are settled")
 var SuccessDBPing = "MYSQL: successfully connected to db"
 var ContextTime = time.Second * 3
 
@@ -306,14 +820,135 @@ type usernameKey string
 type isVendorKey string
 type phoneNumber string
 type useridKey int
+type mfaKey string
+type scopesKey string
+type apiKeyIDKey string
+type platformAdminKey string
 
 const (
 	UsernameKeyValue    usernameKey = "username"
 	IsVendorKeyValue    isVendorKey = "isvendor"
 	PhoneNumberKeyValue phoneNumber = "phonenumber"
 	UseridKeyValue      useridKey   = 0
+	MFAKeyValue         mfaKey      = "mfa"
+	ScopesKeyValue      scopesKey   = "scopes"
+	APIKeyIDKeyValue    apiKeyIDKey = "apikeyid"
 )
 
+// PlatformAdminKeyValue is set on requests RequirePlatformAdmin let through.
+const PlatformAdminKeyValue platformAdminKey = "platformadmin"
+
 var BookingStatusPending = 0
 var BookingStatusConfirmed = 1
 var BookingStatusCheckedOut = 2
+var BookingStatusCheckedIn = 3
+var BookingStatusCancelled = 4
+var BookingStatusNoShow = 5
+
+var BookingStatusNames = map[int]string{
+	BookingStatusPending:    "pending",
+	BookingStatusConfirmed:  "confirmed",
+	BookingStatusCheckedIn:  "checked in",
+	BookingStatusCheckedOut: "checked out",
+	BookingStatusCancelled:  "cancelled",
+	BookingStatusNoShow:     "no show",
+}
+
+const (
+	ActorGuest  = "guest"
+	ActorVendor = "vendor"
+	ActorSystem = "system"
+	ActorAPIKey = "api_key"
+	// ActorPlatformAdmin is a platform operator acting on a platform admin
+	// route, whatever their own account is.
+	ActorPlatformAdmin = "platform_admin"
+)
+
+// Audit log actions recorded with a before/after diff. Other admin changes
+// are recorded by method and route, e.g. "PUT /api/admin/reviews/{review_id}/flag".
+const (
+	AuditRoomCreate        = "room.create"
+	AuditRoomUpdate        = "room.update"
+	AuditRoomDelete        = "room.delete"
+	AuditBookingDelete     = "booking.delete"
+	AuditBookingStatus     = "booking.status"
+	AuditTransactionCreate = "transaction.create"
+	AuditTransactionStatus = "transaction.status"
+	AuditPayoutSettle      = "payout.settle"
+	AuditUserUnlock        = "user.unlock"
+)
+
+const (
+	WebhookDeliveryPending   = "PENDING"
+	WebhookDeliverySucceeded = "SUCCEEDED"
+	WebhookDeliveryFailed    = "FAILED"
+)
+
+// DateLayout is how dates are written in payloads and query strings.
+const DateLayout = "2006-01-02"
+
+const (
+	BlockReasonMaintenance     = "maintenance"
+	BlockReasonOwnerUse        = "owner_use"
+	BlockReasonExternalBooking = "external_booking"
+)
+
+var BlockReasons = []string{BlockReasonMaintenance, BlockReasonOwnerUse, BlockReasonExternalBooking}
+
+// API key scopes. A write scope does not imply the matching read scope.
+const (
+	ScopeRoomsRead     = "rooms:read"
+	ScopeRoomsWrite    = "rooms:write"
+	ScopeBookingsRead  = "bookings:read"
+	ScopeBookingsWrite = "bookings:write"
+	ScopePayoutsRead   = "payouts:read"
+	ScopePayoutsWrite  = "payouts:write"
+	ScopeReviewsRead   = "reviews:read"
+	ScopeReviewsWrite  = "reviews:write"
+	ScopeWebhooksRead  = "webhooks:read"
+	ScopeWebhooksWrite = "webhooks:write"
+)
+
+var APIKeyScopes = []string{
+	ScopeRoomsRead, ScopeRoomsWrite,
+	ScopeBookingsRead, ScopeBookingsWrite,
+	ScopePayoutsRead, ScopePayoutsWrite,
+	ScopeReviewsRead, ScopeReviewsWrite,
+	ScopeWebhooksRead, ScopeWebhooksWrite,
+}
+
+const (
+	CalendarEntryBooking = "booking"
+	CalendarEntryBlock   = "block"
+)
+
+var TransactionStatusPending = 0
+var TransactionStatusPaid = 1
+var TransactionStatusRefunded = 2
+
+// Ledger accounts. CASH is the platform's Stripe balance, PLATFORM_COMMISSION
+// its revenue, VENDOR_PAYABLE what is owed to a vendor and PAYOUT_CLEARING
+// money batched for a payout but not yet received by the vendor.
+const (
+	AccountCash               = "CASH"
+	AccountPlatformCommission = "PLATFORM_COMMISSION"
+	AccountVendorPayable      = "VENDOR_PAYABLE"
+	AccountPayoutClearing     = "PAYOUT_CLEARING"
+)
+
+const (
+	Debit  = "DEBIT"
+	Credit = "CREDIT"
+)
+
+const (
+	LedgerKindPayment       = "PAYMENT"
+	LedgerKindRefund        = "REFUND"
+	LedgerKindPayout        = "PAYOUT"
+	LedgerKindPayoutSettled = "PAYOUT_SETTLED"
+)
+
+const (
+	PayoutStatusPending = "PENDING"
+	PayoutStatusPaid    = "PAID"
+)
@@ -1,22 +1,36 @@
 CREATE TABLE `user` (
     `user_id` BIGINT PRIMARY KEY AUTO_INCREMENT,
     `email` VARCHAR(255) NOT NULL UNIQUE,
-    `phone_number` VARCHAR(11) NOT NULL UNIQUE,
+    -- E.164, e.g. +254712345678.
+    `phone_number` VARCHAR(16) NOT NULL UNIQUE,
     `isVender` ENUM('YES', 'NO') NOT NULL DEFAULT 'NO',
     `hashed_password` VARCHAR(100) NOT NULL,
     `password_reset_token` VARCHAR(250) DEFAULT '',
     `created_at` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     `updated_at` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    `password_inserted_at` TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    `password_inserted_at` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    `name` VARCHAR(100) NOT NULL DEFAULT '',
+    -- Set when the user confirms a code sent to phone_number; NULL for
+    -- numbers given at registration until they are confirmed.
+    `phone_verified_at` TIMESTAMP NULL DEFAULT NULL,
+    -- Set when the account is deleted. Its personal data is anonymized but
+    -- the row stays for the bookings and transactions that reference it.
+    `deleted_at` TIMESTAMP NULL DEFAULT NULL
 );
 
 CREATE INDEX idx_user_id ON user(user_id);
 
+-- Money columns (cost, amount) hold minor units of the row's ISO 4217
+-- currency, e.g. 700000 KES is KES 7,000.00 and 7000 JPY is JPY 7,000.
 CREATE TABLE `room` (
     `room_id` BIGINT PRIMARY KEY AUTO_INCREMENT,
-    `cost` DECIMAL (10, 2) NOT NULL,
+    `cost` BIGINT NOT NULL,
+    `currency` CHAR(3) NOT NULL DEFAULT 'KES',
     `status` ENUM('BOOKED', 'VACANT') NOT NULL DEFAULT 'VACANT',
     `vender_id` BIGINT NOT NULL,
+    -- Secret token of the room's exported iCalendar feed; NULL until the
+    -- vendor asks for the feed URL.
+    `ics_token` VARCHAR(64) NULL DEFAULT NULL,
     `created_at` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     `updated_at` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     FOREIGN KEY (vender_id) REFERENCES user(user_id)
@@ -29,6 +43,13 @@ CREATE TABLE `booking` (
     `days` BIGINT NOT NULL,
     `user_id` BIGINT NOT NULL,
     `room_id` BIGINT NOT NULL,
+    `currency` CHAR(3) NOT NULL DEFAULT 'KES',
+    `status` INT NOT NULL DEFAULT 0,
+    -- First night of the stay. Bookings made before it existed are read as
+    -- starting on the day they were created.
+    `check_in` DATE NULL DEFAULT NULL,
+    `checked_in_at` TIMESTAMP NULL DEFAULT NULL,
+    `checked_out_at` TIMESTAMP NULL DEFAULT NULL,
     `created_at` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     `updated_at` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     FOREIGN KEY (user_id) REFERENCES user(user_id),
@@ -36,6 +57,24 @@ CREATE TABLE `booking` (
 );
 
 CREATE INDEX idx_booking_id ON booking(booking_id);
+CREATE INDEX idx_booking_status ON booking(status, checked_in_at);
+CREATE INDEX idx_booking_room_check_in ON booking(room_id, check_in);
+
+-- Every booking status change, who made it and why. actor is guest, vendor or
+-- system; actor_id is NULL for system changes such as the nightly checkout.
+CREATE TABLE `booking_status_history`(
+    `history_id` BIGINT PRIMARY KEY AUTO_INCREMENT,
+    `booking_id` BIGINT NOT NULL,
+    `from_status` INT NOT NULL,
+    `to_status` INT NOT NULL,
+    `actor` VARCHAR(10) NOT NULL,
+    `actor_id` BIGINT NULL DEFAULT NULL,
+    `reason` VARCHAR(255) NOT NULL DEFAULT '',
+    `created_at` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (booking_id) REFERENCES booking(booking_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_booking_history ON booking_status_history(booking_id, created_at);
 
 CREATE TABLE `transaction`(
     `transaction_id` BIGINT PRIMARY KEY AUTO_INCREMENT,
@@ -44,7 +83,8 @@ CREATE TABLE `transaction`(
     `order_id` VARCHAR(100) NOT NULL,
     `trx_id` VARCHAR(100) NOT NULL,
     `reference` VARCHAR(100) NOT NULL,
-    `amount` DECIMAL(10, 2) NOT NULL,
+    `amount` BIGINT NOT NULL,
+    `currency` CHAR(3) NOT NULL DEFAULT 'KES',
     `status` INT NOT NULL DEFAULT 0,
     `created_at` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     `updated_at` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
@@ -63,4 +103,236 @@ CREATE TABLE `sms_outbox`(
     FOREIGN KEY (user_id) REFERENCES user(user_id)
 );
 
-CREATE INDEX idx_sms_out_outbox ON sms_outbox(sms_id);
\ No newline at end of file
+CREATE INDEX idx_sms_out_outbox ON sms_outbox(sms_id);
+-- One row per payment intent, so a redelivered payment event cannot be stored
+-- twice. Existing databases keep the first row of each trx_id, then add the key:
+--   DELETE t1 FROM transaction t1 JOIN transaction t2
+--     ON t1.trx_id = t2.trx_id AND t1.transaction_id > t2.transaction_id;
+--   ALTER TABLE transaction ADD UNIQUE KEY uq_transaction_trx_id (trx_id);
+CREATE UNIQUE INDEX uq_transaction_trx_id ON transaction(trx_id);
+
+-- Double-entry ledger. Every journal (one payment, refund or payout) is a set
+-- of lines sharing a reference whose debits equal its credits. Amounts are
+-- stored in minor units.
+CREATE TABLE `ledger_entry`(
+    `entry_id` BIGINT PRIMARY KEY AUTO_INCREMENT,
+    `reference` VARCHAR(100) NOT NULL,
+    `vendor_id` BIGINT NOT NULL,
+    `account` ENUM('CASH', 'PLATFORM_COMMISSION', 'VENDOR_PAYABLE', 'PAYOUT_CLEARING') NOT NULL,
+    `direction` ENUM('DEBIT', 'CREDIT') NOT NULL,
+    `amount` BIGINT NOT NULL,
+    `currency` CHAR(3) NOT NULL DEFAULT 'KES',
+    `kind` ENUM('PAYMENT', 'REFUND', 'PAYOUT', 'PAYOUT_SETTLED') NOT NULL,
+    `created_at` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (vendor_id) REFERENCES user(user_id)
+);
+
+CREATE INDEX idx_ledger_vendor_account ON ledger_entry(vendor_id, account);
+CREATE UNIQUE INDEX uq_ledger_line ON ledger_entry(reference, kind, account, direction);
+
+CREATE TABLE `payout`(
+    `payout_id` BIGINT PRIMARY KEY AUTO_INCREMENT,
+    `vendor_id` BIGINT NOT NULL,
+    `amount` BIGINT NOT NULL,
+    `currency` CHAR(3) NOT NULL DEFAULT 'KES',
+    `status` ENUM('PENDING', 'PAID') NOT NULL DEFAULT 'PENDING',
+    `created_at` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    `paid_at` TIMESTAMP NULL DEFAULT NULL,
+    FOREIGN KEY (vendor_id) REFERENCES user(user_id)
+);
+
+CREATE INDEX idx_payout_vendor ON payout(vendor_id, status);
+
+-- Guest reviews, one per checked-out booking. Each category is rated 1-5 and
+-- rating holds their mean. Flagged reviews are hidden from guests and left
+-- out of room and vendor ratings until a vendor clears the flag.
+CREATE TABLE `review`(
+    `review_id` BIGINT PRIMARY KEY AUTO_INCREMENT,
+    `booking_id` BIGINT NOT NULL UNIQUE,
+    `room_id` BIGINT NOT NULL,
+    `vendor_id` BIGINT NOT NULL,
+    `user_id` BIGINT NOT NULL,
+    `cleanliness` TINYINT NOT NULL,
+    `comfort` TINYINT NOT NULL,
+    `location` TINYINT NOT NULL,
+    `value` TINYINT NOT NULL,
+    `rating` DECIMAL(3,2) NOT NULL,
+    `body` TEXT NOT NULL,
+    `vendor_reply` TEXT NULL DEFAULT NULL,
+    `replied_at` TIMESTAMP NULL DEFAULT NULL,
+    `flagged` BOOLEAN NOT NULL DEFAULT FALSE,
+    `flag_reason` VARCHAR(255) NOT NULL DEFAULT '',
+    `created_at` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (booking_id) REFERENCES booking(booking_id) ON DELETE CASCADE,
+    FOREIGN KEY (room_id) REFERENCES room(room_id),
+    FOREIGN KEY (vendor_id) REFERENCES user(user_id),
+    FOREIGN KEY (user_id) REFERENCES user(user_id)
+);
+
+CREATE INDEX idx_review_room ON review(room_id, flagged);
+CREATE INDEX idx_review_vendor ON review(vendor_id, flagged);
+
+-- Events a queue consumer has finished handling, so redeliveries are skipped.
+-- consumer names the handler group, letting several consumers of the same
+-- event each process it once.
+CREATE TABLE `processed_event`(
+    `consumer` VARCHAR(64) NOT NULL,
+    `event_id` VARCHAR(64) NOT NULL,
+    `event_type` VARCHAR(64) NOT NULL,
+    `processed_at` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (consumer, event_id)
+);
+
+CREATE INDEX idx_processed_event_at ON processed_event(processed_at);
+
+-- Vendor webhook subscriptions. events is a comma separated list of booking
+-- event types; empty means all of them.
+CREATE TABLE `webhook`(
+    `webhook_id` BIGINT PRIMARY KEY AUTO_INCREMENT,
+    `vendor_id` BIGINT NOT NULL,
+    `url` VARCHAR(2048) NOT NULL,
+    `secret` VARCHAR(100) NOT NULL,
+    `events` VARCHAR(255) NOT NULL DEFAULT '',
+    `active` BOOLEAN NOT NULL DEFAULT TRUE,
+    `created_at` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (vendor_id) REFERENCES user(user_id)
+);
+
+CREATE INDEX idx_webhook_vendor ON webhook(vendor_id, active);
+
+-- One row per event per webhook, doubling as the delivery log. Pending rows
+-- are sent once next_attempt_at has passed.
+CREATE TABLE `webhook_delivery`(
+    `delivery_id` BIGINT PRIMARY KEY AUTO_INCREMENT,
+    `webhook_id` BIGINT NOT NULL,
+    `event_id` VARCHAR(64) NOT NULL,
+    `event_type` VARCHAR(64) NOT NULL,
+    `payload` TEXT NOT NULL,
+    `status` ENUM('PENDING', 'SUCCEEDED', 'FAILED') NOT NULL DEFAULT 'PENDING',
+    `attempts` INT NOT NULL DEFAULT 0,
+    `response_code` INT NULL DEFAULT NULL,
+    `last_error` VARCHAR(255) NOT NULL DEFAULT '',
+    `next_attempt_at` TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+    `delivered_at` TIMESTAMP NULL DEFAULT NULL,
+    `created_at` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_webhook_delivery_event (webhook_id, event_id),
+    FOREIGN KEY (webhook_id) REFERENCES webhook(webhook_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_webhook_delivery_due ON webhook_delivery(status, next_attempt_at);
+
+-- External iCalendar feeds (Airbnb, Booking.com, ...) imported into a room's
+-- blocks by the calendar sync worker.
+CREATE TABLE `room_calendar`(
+    `calendar_id` BIGINT PRIMARY KEY AUTO_INCREMENT,
+    `room_id` BIGINT NOT NULL,
+    `url` VARCHAR(2048) NOT NULL,
+    `last_synced_at` TIMESTAMP NULL DEFAULT NULL,
+    `last_error` VARCHAR(255) NOT NULL DEFAULT '',
+    `created_at` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (room_id) REFERENCES room(room_id) ON DELETE CASCADE
+);
+
+-- Dates a room cannot be booked, from start_date up to but not including
+-- end_date. reason is maintenance, owner_use or external_booking. Blocks
+-- imported from a room_calendar are replaced on every sync.
+CREATE TABLE `room_block`(
+    `block_id` BIGINT PRIMARY KEY AUTO_INCREMENT,
+    `room_id` BIGINT NOT NULL,
+    `start_date` DATE NOT NULL,
+    `end_date` DATE NOT NULL,
+    `reason` VARCHAR(32) NOT NULL,
+    `note` VARCHAR(255) NOT NULL DEFAULT '',
+    `calendar_id` BIGINT NULL DEFAULT NULL,
+    `external_uid` VARCHAR(255) NOT NULL DEFAULT '',
+    `created_at` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (room_id) REFERENCES room(room_id) ON DELETE CASCADE,
+    FOREIGN KEY (calendar_id) REFERENCES room_calendar(calendar_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_room_block_dates ON room_block(room_id, start_date, end_date);
+
+-- TOTP second factor. The secret is in use once enabled_at is set; last_step
+-- is the time step of the last accepted code so it cannot be replayed.
+CREATE TABLE `user_mfa`(
+    `user_id` BIGINT PRIMARY KEY,
+    `secret` VARCHAR(64) NOT NULL,
+    `enabled_at` TIMESTAMP NULL DEFAULT NULL,
+    `last_step` BIGINT NOT NULL DEFAULT 0,
+    `created_at` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES user(user_id) ON DELETE CASCADE
+);
+
+-- One-time recovery codes for a lost authenticator, stored as SHA-256 hex.
+CREATE TABLE `user_recovery_code`(
+    `code_id` BIGINT PRIMARY KEY AUTO_INCREMENT,
+    `user_id` BIGINT NOT NULL,
+    `code_hash` CHAR(64) NOT NULL,
+    `used_at` TIMESTAMP NULL DEFAULT NULL,
+    UNIQUE KEY uq_user_recovery_code (user_id, code_hash),
+    FOREIGN KEY (user_id) REFERENCES user(user_id) ON DELETE CASCADE
+);
+
+-- External logins (Google, other OpenID Connect providers) linked to a user.
+-- subject is the provider's stable id for the user; email is what it was
+-- linked by.
+CREATE TABLE `user_identity`(
+    `identity_id` BIGINT PRIMARY KEY AUTO_INCREMENT,
+    `user_id` BIGINT NOT NULL,
+    `provider` VARCHAR(64) NOT NULL,
+    `subject` VARCHAR(255) NOT NULL,
+    `email` VARCHAR(255) NOT NULL,
+    `created_at` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_user_identity (provider, subject),
+    FOREIGN KEY (user_id) REFERENCES user(user_id) ON DELETE CASCADE
+);
+
+-- Vendor API keys. A key is bk_<prefix>_<secret>; only the prefix, used to
+-- look the key up, and the SHA-256 hex of the secret are stored. scopes is a
+-- comma separated list such as rooms:write,bookings:read.
+CREATE TABLE `api_key`(
+    `key_id` BIGINT PRIMARY KEY AUTO_INCREMENT,
+    `vendor_id` BIGINT NOT NULL,
+    `name` VARCHAR(100) NOT NULL,
+    `prefix` CHAR(12) NOT NULL,
+    `secret_hash` CHAR(64) NOT NULL,
+    `scopes` VARCHAR(255) NOT NULL,
+    `expires_at` TIMESTAMP NOT NULL,
+    `last_used_at` TIMESTAMP NULL DEFAULT NULL,
+    `revoked_at` TIMESTAMP NULL DEFAULT NULL,
+    `created_at` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_api_key_prefix (prefix),
+    FOREIGN KEY (vendor_id) REFERENCES user(user_id) ON DELETE CASCADE
+);
+
+-- Append-only log of admin changes and payment state changes. vendor_id is
+-- the vendor whose data changed and scopes who may read the entry;
+-- before_state and after_state hold only the fields that changed. There are
+-- no foreign keys so entries outlive the users, keys and rows they name.
+CREATE TABLE `audit_log`(
+    `audit_id` BIGINT PRIMARY KEY AUTO_INCREMENT,
+    `actor_type` VARCHAR(20) NOT NULL,
+    `actor_id` BIGINT NULL DEFAULT NULL,
+    `api_key_id` BIGINT NULL DEFAULT NULL,
+    `vendor_id` BIGINT NULL DEFAULT NULL,
+    `action` VARCHAR(100) NOT NULL,
+    `entity_type` VARCHAR(50) NOT NULL,
+    `entity_id` VARCHAR(255) NOT NULL,
+    `before_state` JSON NULL,
+    `after_state` JSON NULL,
+    `ip` VARCHAR(45) NOT NULL DEFAULT '',
+    `request_id` VARCHAR(128) NOT NULL DEFAULT '',
+    `created_at` TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3)
+);
+
+CREATE INDEX idx_audit_vendor ON audit_log(vendor_id, audit_id);
+CREATE INDEX idx_audit_entity ON audit_log(entity_type, entity_id);
+
+CREATE TRIGGER audit_log_no_update BEFORE UPDATE ON audit_log FOR EACH ROW
+    SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_log is append-only';
+CREATE TRIGGER audit_log_no_delete BEFORE DELETE ON audit_log FOR EACH ROW
+    SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_log is append-only';
@@ -1,11 +1,11 @@
 package app
 
 import (
+	"log/slog"
 	"os"
 
 	"github.com/BurntSushi/toml"
 	"github.com/bicosteve/booking-system/entities"
-	"github.com/bicosteve/booking-system/pkg/utils"
 )
 
 func LoadConfigs(file string) (entities.Config, error) {
@@ -13,14 +13,14 @@ func LoadConfigs(file string) (entities.Config, error) {
 
 	data, err := os.ReadFile(file)
 	if err != nil {
-		utils.LogError("Could not read toml file due to "+err.Error(), entities.ErrorLog)
+		slog.Error("reading config file failed", "file", file, "error", err)
 		return entities.Config{}, err
 
 	}
 
 	_, err = toml.Decode(string(data), &config)
 	if err != nil {
-		utils.LogError("Error logging config because of "+err.Error(), entities.ErrorLog)
+		slog.Error("decoding config file failed", "file", file, "error", err)
 		return entities.Config{}, err
 
 	}
@@ -0,0 +1,234 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/ratelimit"
+	"github.com/bicosteve/booking-system/pkg/utils"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Store writes audit entries. Entries are only ever added, never changed.
+type Store interface {
+	RecordAudit(ctx context.Context, entries []entities.AuditEntry) error
+}
+
+// writeTimeout bounds writing a request's entries, which happens after the
+// handler is done and so outlives its deadline.
+const writeTimeout = 5 * time.Second
+
+type contextKey struct{}
+
+// trail collects the entries recorded while handling one request.
+type trail struct {
+	ip      string
+	mu      sync.Mutex
+	entries []entities.AuditEntry
+}
+
+// Middleware keeps the entries services record while handling a request and
+// writes them to store once the handler returns. With everyMutation set, a
+// successful POST, PUT, PATCH or DELETE that recorded nothing gets an entry
+// naming its route, so no change goes unlogged. It must run after the
+// authentication middleware, which tells it who the actor is.
+func Middleware(store Store, trustProxy, everyMutation bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t := &trail{ip: ratelimit.ClientIP(r, trustProxy)}
+			ctx := context.WithValue(r.Context(), contextKey{}, t)
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			next.ServeHTTP(ww, r.WithContext(ctx))
+
+			t.mu.Lock()
+			entries := t.entries
+			t.entries = nil
+			t.mu.Unlock()
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			if len(entries) == 0 && everyMutation && mutates(r.Method) && status < http.StatusBadRequest {
+				entries = append(entries, routeEntry(ctx, r))
+			}
+
+			if len(entries) == 0 {
+				return
+			}
+
+			// The client may be gone by now; the entries are written anyway.
+			writeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), writeTimeout)
+			defer cancel()
+
+			err := store.RecordAudit(writeCtx, entries)
+			if err != nil {
+				slog.ErrorContext(ctx, "writing audit entries failed", "entries", len(entries), "error", err)
+			}
+		})
+	}
+}
+
+// Stamp fills in who made the change recorded by e, from where and in which
+// request, taken from ctx. Changes made outside a request are the system's.
+func Stamp(ctx context.Context, e *entities.AuditEntry) {
+	e.ActorType = entities.ActorSystem
+	e.RequestID = utils.RequestIDFromContext(ctx)
+
+	if t, ok := ctx.Value(contextKey{}).(*trail); ok {
+		e.IP = t.ip
+	}
+
+	id, ok := ctx.Value(entities.UseridKeyValue).(string)
+	if !ok {
+		return
+	}
+
+	userID, err := strconv.Atoi(id)
+	if err != nil {
+		return
+	}
+
+	e.ActorID = &userID
+	e.ActorType = entities.ActorGuest
+
+	if vendor, _ := ctx.Value(entities.IsVendorKeyValue).(string); vendor == "YES" {
+		e.ActorType = entities.ActorVendor
+	}
+
+	if keyID, ok := ctx.Value(entities.APIKeyIDKeyValue).(int); ok {
+		e.ActorType = entities.ActorAPIKey
+		e.APIKeyID = &keyID
+	}
+
+	if admin, _ := ctx.Value(entities.PlatformAdminKeyValue).(bool); admin {
+		e.ActorType = entities.ActorPlatformAdmin
+	}
+}
+
+// Add keeps e to be written once the request in ctx is handled. It reports
+// false when ctx did not come through Middleware, leaving e to the caller.
+func Add(ctx context.Context, e entities.AuditEntry) bool {
+	t, ok := ctx.Value(contextKey{}).(*trail)
+	if !ok {
+		return false
+	}
+
+	t.mu.Lock()
+	t.entries = append(t.entries, e)
+	t.mu.Unlock()
+
+	return true
+}
+
+// Diff returns the JSON fields of before and after whose values differ.
+// A nil side, for a creation or deletion, gives the whole other side.
+func Diff(before, after any) (json.RawMessage, json.RawMessage) {
+	b, a := fields(before), fields(after)
+
+	switch {
+	case b == nil:
+		return nil, encode(a)
+	case a == nil:
+		return encode(b), nil
+	}
+
+	changedBefore := map[string]json.RawMessage{}
+	changedAfter := map[string]json.RawMessage{}
+
+	for k, v := range b {
+		if !bytes.Equal(v, a[k]) {
+			changedBefore[k] = v
+		}
+	}
+
+	for k, v := range a {
+		if !bytes.Equal(v, b[k]) {
+			changedAfter[k] = v
+		}
+	}
+
+	return encode(changedBefore), encode(changedAfter)
+}
+
+func fields(v any) map[string]json.RawMessage {
+	if v == nil {
+		return nil
+	}
+
+	raw, err := json.Marshal(v)
+	if err != nil || bytes.Equal(raw, []byte("null")) {
+		return nil
+	}
+
+	var m map[string]json.RawMessage
+	if json.Unmarshal(raw, &m) != nil {
+		return map[string]json.RawMessage{"value": raw}
+	}
+
+	return m
+}
+
+func encode(m map[string]json.RawMessage) json.RawMessage {
+	if m == nil {
+		return nil
+	}
+
+	raw, _ := json.Marshal(m)
+	return raw
+}
+
+func mutates(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+
+	return false
+}
+
+// routeEntry records a request by its method and route, with the route's
+// parameters, e.g. "review_id=5", standing in for the entity.
+func routeEntry(ctx context.Context, r *http.Request) entities.AuditEntry {
+	e := entities.AuditEntry{EntityType: "route"}
+	pattern := r.URL.Path
+
+	if rc := chi.RouteContext(ctx); rc != nil {
+		if p := rc.RoutePattern(); p != "" {
+			pattern = p
+		}
+
+		params := make([]string, 0, len(rc.URLParams.Keys))
+		for i, k := range rc.URLParams.Keys {
+			if k == "*" {
+				continue
+			}
+			params = append(params, k+"="+rc.URLParams.Values[i])
+		}
+		sort.Strings(params)
+		e.EntityID = strings.Join(params, ",")
+	}
+
+	e.Action = r.Method + " " + pattern
+	Stamp(ctx, &e)
+
+	// Vendor admin routes only touch the acting vendor's data. Platform
+	// admin routes act on someone else's, so their entries have no vendor.
+	if e.ActorType != entities.ActorPlatformAdmin {
+		e.VendorID = e.ActorID
+	}
+
+	return e
+}
@@ -0,0 +1,194 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+type memStore struct {
+	entries []entities.AuditEntry
+	err     error
+}
+
+func (s *memStore) RecordAudit(_ context.Context, entries []entities.AuditEntry) error {
+	s.entries = append(s.entries, entries...)
+	return s.err
+}
+
+// asVendor stands in for the auth middleware.
+func asVendor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), entities.UseridKeyValue, "7")
+		ctx = context.WithValue(ctx, entities.IsVendorKeyValue, "YES")
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func TestDiff(t *testing.T) {
+	type room struct {
+		Cost   int    `json:"cost"`
+		Status string `json:"status"`
+		Vendor string `json:"vendor"`
+	}
+
+	before, after := Diff(room{100, "VACANT", "7"}, room{150, "VACANT", "7"})
+	assert.JSONEq(t, `{"cost":100}`, string(before))
+	assert.JSONEq(t, `{"cost":150}`, string(after))
+
+	before, after = Diff(nil, room{100, "VACANT", "7"})
+	assert.Nil(t, before)
+	assert.JSONEq(t, `{"cost":100,"status":"VACANT","vendor":"7"}`, string(after))
+
+	before, after = Diff(&room{100, "VACANT", "7"}, nil)
+	assert.JSONEq(t, `{"cost":100,"status":"VACANT","vendor":"7"}`, string(before))
+	assert.Nil(t, after)
+
+	before, after = Diff(map[string]string{"status": "PENDING"}, map[string]string{"status": "PENDING", "reason": "late"})
+	assert.JSONEq(t, `{}`, string(before))
+	assert.JSONEq(t, `{"reason":"late"}`, string(after))
+
+	before, after = Diff(1, 2)
+	assert.JSONEq(t, `{"value":1}`, string(before))
+	assert.JSONEq(t, `{"value":2}`, string(after))
+}
+
+func TestStamp(t *testing.T) {
+	t.Run("outside a request", func(t *testing.T) {
+		var e entities.AuditEntry
+		Stamp(context.Background(), &e)
+		assert.Equal(t, entities.ActorSystem, e.ActorType)
+		assert.Nil(t, e.ActorID)
+	})
+
+	t.Run("guest", func(t *testing.T) {
+		var e entities.AuditEntry
+		Stamp(context.WithValue(context.Background(), entities.UseridKeyValue, "5"), &e)
+		assert.Equal(t, entities.ActorGuest, e.ActorType)
+		assert.Equal(t, 5, *e.ActorID)
+	})
+
+	t.Run("api key", func(t *testing.T) {
+		ctx := context.WithValue(context.Background(), entities.UseridKeyValue, "7")
+		ctx = context.WithValue(ctx, entities.IsVendorKeyValue, "YES")
+		ctx = context.WithValue(ctx, entities.APIKeyIDKeyValue, 4)
+
+		var e entities.AuditEntry
+		Stamp(ctx, &e)
+		assert.Equal(t, entities.ActorAPIKey, e.ActorType)
+		assert.Equal(t, 7, *e.ActorID)
+		assert.Equal(t, 4, *e.APIKeyID)
+	})
+
+	t.Run("platform admin", func(t *testing.T) {
+		ctx := context.WithValue(context.Background(), entities.UseridKeyValue, "1")
+		ctx = context.WithValue(ctx, entities.IsVendorKeyValue, "YES")
+		ctx = context.WithValue(ctx, entities.PlatformAdminKeyValue, true)
+
+		var e entities.AuditEntry
+		Stamp(ctx, &e)
+		assert.Equal(t, entities.ActorPlatformAdmin, e.ActorType)
+		assert.Equal(t, 1, *e.ActorID)
+	})
+}
+
+func TestMiddleware(t *testing.T) {
+	newRouter := func(store Store, everyMutation bool, status int, record bool) http.Handler {
+		r := chi.NewRouter()
+		r.Use(asVendor)
+		r.Use(Middleware(store, false, everyMutation))
+		r.Put("/admin/reviews/{review_id}/flag", func(w http.ResponseWriter, r *http.Request) {
+			if record {
+				e := entities.AuditEntry{Action: "review.flag", EntityType: "review", EntityID: "5"}
+				Stamp(r.Context(), &e)
+				assert.True(t, Add(r.Context(), e))
+			}
+			w.WriteHeader(status)
+		})
+		r.Get("/admin/reviews", func(w http.ResponseWriter, r *http.Request) {})
+		return r
+	}
+
+	newReq := func(method, path string) *http.Request {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = "10.0.0.1:4000"
+		return req
+	}
+
+	t.Run("recorded entries", func(t *testing.T) {
+		store := &memStore{}
+		newRouter(store, true, http.StatusOK, true).ServeHTTP(httptest.NewRecorder(), newReq(http.MethodPut, "/admin/reviews/5/flag"))
+
+		assert.Len(t, store.entries, 1)
+		assert.Equal(t, "review.flag", store.entries[0].Action)
+		assert.Equal(t, entities.ActorVendor, store.entries[0].ActorType)
+		assert.Equal(t, "10.0.0.1", store.entries[0].IP)
+	})
+
+	t.Run("route entry", func(t *testing.T) {
+		store := &memStore{}
+		newRouter(store, true, http.StatusOK, false).ServeHTTP(httptest.NewRecorder(), newReq(http.MethodPut, "/admin/reviews/5/flag"))
+
+		assert.Len(t, store.entries, 1)
+		e := store.entries[0]
+		assert.Equal(t, "PUT /admin/reviews/{review_id}/flag", e.Action)
+		assert.Equal(t, "route", e.EntityType)
+		assert.Equal(t, "review_id=5", e.EntityID)
+		assert.Equal(t, 7, *e.VendorID)
+	})
+
+	t.Run("platform admin route entry", func(t *testing.T) {
+		store := &memStore{}
+		r := chi.NewRouter()
+		r.Use(asVendor)
+		r.Use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), entities.PlatformAdminKeyValue, true)))
+			})
+		})
+		r.Use(Middleware(store, false, true))
+		r.Put("/admin/payouts/{payout_id}/paid", func(w http.ResponseWriter, r *http.Request) {})
+		r.ServeHTTP(httptest.NewRecorder(), newReq(http.MethodPut, "/admin/payouts/3/paid"))
+
+		assert.Len(t, store.entries, 1)
+		e := store.entries[0]
+		assert.Equal(t, entities.ActorPlatformAdmin, e.ActorType)
+		assert.Equal(t, 7, *e.ActorID)
+		assert.Nil(t, e.VendorID)
+	})
+
+	t.Run("failed or read only requests", func(t *testing.T) {
+		store := &memStore{}
+		router := newRouter(store, true, http.StatusForbidden, false)
+		router.ServeHTTP(httptest.NewRecorder(), newReq(http.MethodPut, "/admin/reviews/5/flag"))
+		router.ServeHTTP(httptest.NewRecorder(), newReq(http.MethodGet, "/admin/reviews"))
+
+		assert.Empty(t, store.entries)
+	})
+
+	t.Run("only recorded entries", func(t *testing.T) {
+		store := &memStore{}
+		newRouter(store, false, http.StatusOK, false).ServeHTTP(httptest.NewRecorder(), newReq(http.MethodPut, "/admin/reviews/5/flag"))
+
+		assert.Empty(t, store.entries)
+	})
+
+	t.Run("store error", func(t *testing.T) {
+		store := &memStore{err: errors.New("db down")}
+		w := httptest.NewRecorder()
+		newRouter(store, true, http.StatusOK, true).ServeHTTP(w, newReq(http.MethodPut, "/admin/reviews/5/flag"))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Len(t, store.entries, 1)
+	})
+}
+
+func TestAddOutsideRequest(t *testing.T) {
+	assert.False(t, Add(context.Background(), entities.AuditEntry{}))
+}
@@ -0,0 +1,150 @@
+// Package events defines the envelope every booking and payment event is
+// wrapped in, the typed payloads carried inside it and the JSON Schemas they
+// are validated against when published and when consumed.
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event types. The version each type is currently published at is in
+// versions.
+const (
+	BookingCreated    = "booking.created"
+	BookingConfirmed  = "booking.confirmed"
+	BookingCancelled  = "booking.cancelled"
+	BookingCheckedOut = "booking.checked_out"
+	PaymentSucceeded  = "payment.succeeded"
+	PaymentFailed     = "payment.failed"
+	PaymentRefunded   = "payment.refunded"
+)
+
+// Producer names this service in the envelopes it publishes.
+const Producer = "booking-system"
+
+var versions = map[string]int{
+	BookingCreated:    1,
+	BookingConfirmed:  1,
+	BookingCancelled:  1,
+	BookingCheckedOut: 1,
+	PaymentSucceeded:  1,
+	PaymentFailed:     1,
+	PaymentRefunded:   1,
+}
+
+var (
+	// ErrUnknownType is returned for an event type this service does not know.
+	ErrUnknownType = errors.New("events: unknown event type")
+	// ErrUnsupportedVersion is returned for an event newer than this service
+	// understands.
+	ErrUnsupportedVersion = errors.New("events: unsupported event version")
+	// ErrInvalid wraps schema validation failures.
+	ErrInvalid = errors.New("events: invalid event")
+)
+
+// Envelope wraps the payload of an event with what consumers need to route,
+// deduplicate and order it.
+type Envelope struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Version    int             `json:"version"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Producer   string          `json:"producer"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// BookingEvent is the payload of the booking.* events.
+type BookingEvent struct {
+	BookingID int    `json:"booking_id"`
+	RoomID    int    `json:"room_id"`
+	UserID    int    `json:"user_id"`
+	Status    string `json:"status"`
+	Days      int    `json:"days"`
+	OrderID   string `json:"order_id,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// PaymentEvent is the payload of the payment.* events. Amount is in minor
+// units of Currency.
+type PaymentEvent struct {
+	OrderID   string `json:"order_id"`
+	TrxID     string `json:"trx_id"`
+	Reference string `json:"reference,omitempty"`
+	RoomID    int    `json:"room_id"`
+	UserID    int    `json:"user_id"`
+	Days      int    `json:"days,omitempty"`
+	Amount    int64  `json:"amount"`
+	Currency  string `json:"currency"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// New wraps payload in an envelope of eventType at its current version and
+// validates it.
+func New(eventType string, payload any) (Envelope, error) {
+	version, ok := versions[eventType]
+	if !ok {
+		return Envelope{}, fmt.Errorf("%w: %q", ErrUnknownType, eventType)
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	e := Envelope{
+		ID:         uuid.New().String(),
+		Type:       eventType,
+		Version:    version,
+		OccurredAt: time.Now().UTC(),
+		Producer:   Producer,
+		Payload:    body,
+	}
+
+	err = Validate(e)
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	return e, nil
+}
+
+// Decode parses and validates an event taken off a queue. Events published
+// at an older version are upgraded to the current one, so handlers only see
+// current payloads. A body without an envelope is read as the bare
+// transaction payload published before envelopes existed.
+func Decode(data []byte) (Envelope, error) {
+	var e Envelope
+	err := json.Unmarshal(data, &e)
+	if err != nil {
+		return Envelope{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	if e.Type == "" {
+		return legacyPayment(data)
+	}
+
+	current, ok := versions[e.Type]
+	if !ok {
+		return Envelope{}, fmt.Errorf("%w: %q", ErrUnknownType, e.Type)
+	}
+	if e.Version > current {
+		return Envelope{}, fmt.Errorf("%w: %s v%d", ErrUnsupportedVersion, e.Type, e.Version)
+	}
+
+	err = validateJSON("envelope.json", data)
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	return upgrade(e)
+}
+
+// Decode unmarshals the payload of e into v.
+func (e Envelope) Decode(v any) error {
+	return json.Unmarshal(e.Payload, v)
+}
@@ -0,0 +1,126 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testPayment() PaymentEvent {
+	return PaymentEvent{
+		OrderID:  "order-1",
+		TrxID:    "pi_123",
+		RoomID:   10,
+		UserID:   5,
+		Days:     2,
+		Amount:   700000,
+		Currency: "KES",
+	}
+}
+
+func TestNew_RoundTrip(t *testing.T) {
+	e, err := New(PaymentSucceeded, testPayment())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, e.Version)
+	assert.Equal(t, Producer, e.Producer)
+	assert.NotEmpty(t, e.ID)
+
+	data, err := json.Marshal(e)
+	assert.NoError(t, err)
+
+	decoded, err := Decode(data)
+	assert.NoError(t, err)
+	assert.Equal(t, e.ID, decoded.ID)
+
+	var p PaymentEvent
+	assert.NoError(t, decoded.Decode(&p))
+	assert.Equal(t, testPayment(), p)
+}
+
+func TestNew_RejectsInvalidPayloads(t *testing.T) {
+	p := testPayment()
+	p.Currency = "kes"
+	_, err := New(PaymentSucceeded, p)
+	assert.ErrorIs(t, err, ErrInvalid)
+
+	// payment.failed needs a reason.
+	_, err = New(PaymentFailed, testPayment())
+	assert.ErrorIs(t, err, ErrInvalid)
+
+	// The status must match the event type.
+	_, err = New(BookingCancelled, BookingEvent{BookingID: 1, RoomID: 1, UserID: 1, Days: 1, Status: "confirmed"})
+	assert.ErrorIs(t, err, ErrInvalid)
+
+	_, err = New("booking.teleported", BookingEvent{})
+	assert.ErrorIs(t, err, ErrUnknownType)
+}
+
+func TestDecode_UpgradesLegacyPayments(t *testing.T) {
+	legacy := `{"room_id":10,"user_id":5,"order_id":"order-1","reference":"ref","trx_id":"pi_123","status":1,"days":2,"payment":{"amount":700000,"currency":"KES"}}`
+
+	e, err := Decode([]byte(legacy))
+	assert.NoError(t, err)
+	assert.Equal(t, PaymentSucceeded, e.Type)
+	assert.Equal(t, 1, e.Version)
+
+	var p PaymentEvent
+	assert.NoError(t, e.Decode(&p))
+	want := testPayment()
+	want.Reference = "ref"
+	assert.Equal(t, want, p)
+
+	// Redeliveries of the same payment keep the same id.
+	again, err := Decode([]byte(legacy))
+	assert.NoError(t, err)
+	assert.Equal(t, e.ID, again.ID)
+}
+
+func TestDecode_RejectsNewerVersions(t *testing.T) {
+	e, err := New(PaymentSucceeded, testPayment())
+	assert.NoError(t, err)
+	e.Version = 2
+
+	data, _ := json.Marshal(e)
+	_, err = Decode(data)
+	assert.ErrorIs(t, err, ErrUnsupportedVersion)
+}
+
+func TestDecode_RejectsBadEnvelopes(t *testing.T) {
+	_, err := Decode([]byte(`{"type":"payment.succeeded","version":1,"payload":{}}`))
+	assert.ErrorIs(t, err, ErrInvalid)
+
+	_, err = Decode([]byte(`not json`))
+	assert.ErrorIs(t, err, ErrInvalid)
+}
+
+func TestRouter_DispatchesOnType(t *testing.T) {
+	var got []string
+	r := NewRouter()
+	r.Handle(PaymentSucceeded, func(_ context.Context, e Envelope) error {
+		got = append(got, e.Type)
+		return nil
+	})
+	r.Handle(PaymentRefunded, func(context.Context, Envelope) error {
+		return errors.New("refund failed")
+	})
+
+	succeeded, _ := New(PaymentSucceeded, testPayment())
+	refunded, _ := New(PaymentRefunded, testPayment())
+	cancelled, _ := New(BookingCancelled, BookingEvent{BookingID: 1, RoomID: 1, UserID: 1, Days: 1, Status: "cancelled"})
+
+	for _, e := range []Envelope{succeeded, cancelled} {
+		data, _ := json.Marshal(e)
+		assert.NoError(t, r.Dispatch(context.Background(), data))
+	}
+
+	data, _ := json.Marshal(refunded)
+	assert.EqualError(t, r.Dispatch(context.Background(), data), "refund failed")
+
+	unknown := `{"id":"1","type":"room.renamed","version":1,"payload":{}}`
+	assert.NoError(t, r.Dispatch(context.Background(), []byte(unknown)))
+
+	assert.Equal(t, []string{PaymentSucceeded}, got)
+}
@@ -0,0 +1,41 @@
+package events
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/bicosteve/booking-system/pkg/metrics"
+)
+
+// ProcessedStore remembers which events a consumer has handled.
+type ProcessedStore interface {
+	Processed(ctx context.Context, consumer, eventID string) (bool, error)
+	MarkProcessed(ctx context.Context, consumer, eventID, eventType string) error
+}
+
+// Idempotent wraps h so that an event redelivered to consumer after it was
+// handled is skipped. The event is marked only once h succeeds, so h must
+// itself tolerate running twice for an event whose first run failed after
+// some of its effects were stored; unique keys on those effects make that
+// safe and together give exactly-once effects on at-least-once delivery.
+func Idempotent(store ProcessedStore, consumer string, h Handler) Handler {
+	return func(ctx context.Context, e Envelope) error {
+		done, err := store.Processed(ctx, consumer, e.ID)
+		if err != nil {
+			return err
+		}
+
+		if done {
+			metrics.EventsDeduplicated.WithLabelValues(consumer, e.Type).Inc()
+			slog.InfoContext(ctx, "event already processed, skipping it", "consumer", consumer, "event_type", e.Type, "event_id", e.ID)
+			return nil
+		}
+
+		err = h(ctx, e)
+		if err != nil {
+			return err
+		}
+
+		return store.MarkProcessed(ctx, consumer, e.ID, e.Type)
+	}
+}
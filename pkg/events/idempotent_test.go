@@ -0,0 +1,52 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryStore is a ProcessedStore backed by a map.
+type memoryStore map[string]bool
+
+func (s memoryStore) Processed(_ context.Context, consumer, eventID string) (bool, error) {
+	return s[consumer+"/"+eventID], nil
+}
+
+func (s memoryStore) MarkProcessed(_ context.Context, consumer, eventID, _ string) error {
+	s[consumer+"/"+eventID] = true
+	return nil
+}
+
+func TestIdempotent_SkipsRedeliveries(t *testing.T) {
+	store := memoryStore{}
+	calls := 0
+	fail := true
+	h := Idempotent(store, "payments", func(context.Context, Envelope) error {
+		calls++
+		if fail {
+			return errors.New("database down")
+		}
+		return nil
+	})
+
+	e, err := New(PaymentSucceeded, testPayment())
+	assert.NoError(t, err)
+
+	// A failed attempt is not marked, so the redelivery runs the handler again.
+	assert.Error(t, h(context.Background(), e))
+	fail = false
+	assert.NoError(t, h(context.Background(), e))
+	assert.NoError(t, h(context.Background(), e))
+	assert.Equal(t, 2, calls)
+
+	// Another consumer processes the same event independently.
+	other := Idempotent(store, "analytics", func(context.Context, Envelope) error {
+		calls++
+		return nil
+	})
+	assert.NoError(t, other(context.Background(), e))
+	assert.Equal(t, 3, calls)
+}
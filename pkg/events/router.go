@@ -0,0 +1,48 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+)
+
+// Handler processes one decoded event.
+type Handler func(ctx context.Context, e Envelope) error
+
+// Router decodes events and hands them to the handler registered for their
+// type. Events of other types are skipped.
+type Router struct {
+	handlers map[string]Handler
+}
+
+// NewRouter returns a Router with no handlers.
+func NewRouter() *Router {
+	return &Router{handlers: make(map[string]Handler)}
+}
+
+// Handle registers h for eventType, replacing any earlier handler.
+func (r *Router) Handle(eventType string, h Handler) {
+	r.handlers[eventType] = h
+}
+
+// Dispatch decodes data and runs the handler for its type. It returns the
+// decode error for malformed events and nil for events nobody handles,
+// including types this service does not know yet.
+func (r *Router) Dispatch(ctx context.Context, data []byte) error {
+	e, err := Decode(data)
+	if errors.Is(err, ErrUnknownType) {
+		slog.WarnContext(ctx, "unknown event type, skipping it", "error", err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	h, ok := r.handlers[e.Type]
+	if !ok {
+		slog.DebugContext(ctx, "no handler for event, skipping it", "event_type", e.Type, "event_id", e.ID)
+		return nil
+	}
+
+	return h(ctx, e)
+}
@@ -0,0 +1,103 @@
+package events
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+//go:embed schemas/*.json
+var schemaFiles embed.FS
+
+const schemaBase = "https://booking-system/schemas/"
+
+var (
+	schemasOnce sync.Once
+	schemas     map[string]*jsonschema.Schema
+	schemasErr  error
+)
+
+// compileSchemas compiles every embedded schema once, keyed by file name.
+func compileSchemas() (map[string]*jsonschema.Schema, error) {
+	schemasOnce.Do(func() {
+		c := jsonschema.NewCompiler()
+		c.AssertFormat = true
+		c.LoadURL = func(url string) (io.ReadCloser, error) {
+			data, err := schemaFiles.ReadFile("schemas/" + strings.TrimPrefix(url, schemaBase))
+			if err != nil {
+				return nil, err
+			}
+			return io.NopCloser(bytes.NewReader(data)), nil
+		}
+
+		entries, err := schemaFiles.ReadDir("schemas")
+		if err != nil {
+			schemasErr = err
+			return
+		}
+
+		schemas = make(map[string]*jsonschema.Schema, len(entries))
+		for _, entry := range entries {
+			s, err := c.Compile(schemaBase + entry.Name())
+			if err != nil {
+				schemasErr = err
+				return
+			}
+			schemas[entry.Name()] = s
+		}
+	})
+
+	return schemas, schemasErr
+}
+
+// schemaName is the file holding the payload schema of eventType at version.
+func schemaName(eventType string, version int) string {
+	return fmt.Sprintf("%s.v%d.json", eventType, version)
+}
+
+// Validate checks the envelope and its payload against their schemas.
+func Validate(e Envelope) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	err = validateJSON("envelope.json", data)
+	if err != nil {
+		return err
+	}
+
+	return validateJSON(schemaName(e.Type, e.Version), e.Payload)
+}
+
+// validateJSON validates data against the named schema.
+func validateJSON(name string, data []byte) error {
+	all, err := compileSchemas()
+	if err != nil {
+		return err
+	}
+
+	s, ok := all[name]
+	if !ok {
+		return fmt.Errorf("%w: no schema %s", ErrUnknownType, name)
+	}
+
+	var v any
+	err = json.Unmarshal(data, &v)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	err = s.Validate(v)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalid, name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// upcaster turns a payload of one version into the next version.
+type upcaster func(json.RawMessage) (json.RawMessage, error)
+
+// upcasters holds, per event type, the step from each old version to the
+// next. Add one whenever a type's version is bumped.
+var upcasters = map[string]map[int]upcaster{
+	PaymentSucceeded: {0: paymentV0ToV1},
+}
+
+// upgrade validates the payload of e against the schema of its version and
+// applies upcasters until it reaches the current version.
+func upgrade(e Envelope) (Envelope, error) {
+	err := validateJSON(schemaName(e.Type, e.Version), e.Payload)
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	for e.Version < versions[e.Type] {
+		step, ok := upcasters[e.Type][e.Version]
+		if !ok {
+			return Envelope{}, fmt.Errorf("%w: no upgrade from %s v%d", ErrUnsupportedVersion, e.Type, e.Version)
+		}
+
+		e.Payload, err = step(e.Payload)
+		if err != nil {
+			return Envelope{}, fmt.Errorf("%w: upgrading %s v%d: %v", ErrInvalid, e.Type, e.Version, err)
+		}
+		e.Version++
+	}
+
+	err = validateJSON(schemaName(e.Type, e.Version), e.Payload)
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	return e, nil
+}
+
+// legacyPayment wraps a bare transaction payload as payment.succeeded v0.
+// Its id is derived from the payment intent so redeliveries share it.
+func legacyPayment(data []byte) (Envelope, error) {
+	var trx struct {
+		TrxID string `json:"trx_id"`
+	}
+	err := json.Unmarshal(data, &trx)
+	if err != nil {
+		return Envelope{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	return upgrade(Envelope{
+		ID:         uuid.NewSHA1(uuid.NameSpaceURL, []byte("payment:"+trx.TrxID)).String(),
+		Type:       PaymentSucceeded,
+		Version:    0,
+		OccurredAt: time.Now().UTC(),
+		Producer:   Producer,
+		Payload:    data,
+	})
+}
+
+// paymentV0ToV1 moves the amount and currency out of the nested payment
+// object of a bare transaction payload.
+func paymentV0ToV1(payload json.RawMessage) (json.RawMessage, error) {
+	var v0 struct {
+		RoomID    int    `json:"room_id"`
+		UserID    int    `json:"user_id"`
+		OrderID   string `json:"order_id"`
+		Reference string `json:"reference"`
+		TrxID     string `json:"trx_id"`
+		Days      int    `json:"days"`
+		Payment   struct {
+			Amount   int64  `json:"amount"`
+			Currency string `json:"currency"`
+		} `json:"payment"`
+	}
+	err := json.Unmarshal(payload, &v0)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(PaymentEvent{
+		OrderID:   v0.OrderID,
+		TrxID:     v0.TrxID,
+		Reference: v0.Reference,
+		RoomID:    v0.RoomID,
+		UserID:    v0.UserID,
+		Days:      v0.Days,
+		Amount:    v0.Payment.Amount,
+		Currency:  v0.Payment.Currency,
+	})
+}
@@ -14,6 +14,10 @@ type Checker struct {
 	// Disabled marks the dependency as intentionally off. Disabled checkers
 	// are reported as "disabled" and never affect overall health.
 	Disabled bool
+	// Optional marks a dependency the app can run without for a while (e.g.
+	// Kafka). When it is down the report is "degraded" rather than
+	// "unhealthy".
+	Optional bool
 	// Ping is invoked with a context carrying a per-checker timeout. A nil
 	// Ping is treated as an unreachable dependency.
 	Ping func(context.Context) error
@@ -21,15 +25,19 @@ type Checker struct {
 
 // Result is the outcome of a single checker.
 type Result struct {
-	Name   string `json:"name"`
-	Status string `json:"status"` // "up" | "down" | "disabled"
-	Error  string `json:"error,omitempty"`
+	Name        string     `json:"name"`
+	Status      string     `json:"status"` // "up" | "down" | "disabled"
+	Optional    bool       `json:"optional,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	LatencyMs   float64    `json:"latency_ms"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
 }
 
 // Report aggregates checker results.
 type Report struct {
-	Status string   `json:"status"` // "healthy" | "unhealthy"
-	Checks []Result `json:"checks"`
+	Status    string    `json:"status"` // "healthy" | "degraded" | "unhealthy"
+	CheckedAt time.Time `json:"checked_at"`
+	Checks    []Result  `json:"checks"`
 }
 
 // checkTimeout is the per-checker probe budget. Some auth failures take ~3s
@@ -38,46 +46,58 @@ type Report struct {
 const checkTimeout = 5 * time.Second
 
 // Check runs each checker's Ping (bounded by checkTimeout) and returns a
-// Report. Overall Status is "healthy" iff every enabled checker is "up".
+// Report. Overall Status is "healthy" iff every enabled checker is "up",
+// "degraded" if only optional checkers are down and "unhealthy" otherwise.
 // Disabled checkers are not executed.
 func Check(ctx context.Context, checkers []Checker) Report {
-	report := Report{Checks: make([]Result, 0, len(checkers))}
-	healthy := true
+	report := Report{CheckedAt: time.Now(), Checks: make([]Result, 0, len(checkers))}
+	healthy, degraded := true, false
 
 	for _, c := range checkers {
 		if c.Disabled {
-			report.Checks = append(report.Checks, Result{Name: c.Name, Status: "disabled"})
+			report.Checks = append(report.Checks, Result{Name: c.Name, Status: "disabled", Optional: c.Optional})
 			continue
 		}
-		status := "up"
-		errMsg := ""
+		result := Result{Name: c.Name, Status: "up", Optional: c.Optional}
 		if c.Ping == nil {
-			status = "down"
-			errMsg = "ping function not configured"
-			healthy = false
+			result.Status = "down"
+			result.Error = "ping function not configured"
 		} else {
 			pingCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+			start := time.Now()
 			err := c.Ping(pingCtx)
+			result.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
 			cancel()
 			if err != nil {
-				status = "down"
-				errMsg = err.Error()
+				result.Status = "down"
+				result.Error = err.Error()
+			} else {
+				result.LastSuccess = &start
+			}
+		}
+		if result.Status == "down" {
+			if c.Optional {
+				degraded = true
+			} else {
 				healthy = false
 			}
 		}
-		report.Checks = append(report.Checks, Result{Name: c.Name, Status: status, Error: errMsg})
+		report.Checks = append(report.Checks, result)
 	}
 
-	if healthy {
-		report.Status = "healthy"
-	} else {
+	switch {
+	case !healthy:
 		report.Status = "unhealthy"
+	case degraded:
+		report.Status = "degraded"
+	default:
+		report.Status = "healthy"
 	}
 	return report
 }
 
-// Await calls Check every interval until all enabled checkers are up or
-// timeout elapses. Returns nil once healthy. On timeout it returns an error
+// Await calls Check every interval until all enabled required checkers are up
+// or timeout elapses. Returns nil once healthy. On timeout it returns an error
 // summarizing the failing checkers by name (never a bare "context deadline
 // exceeded"), so callers can see which dependency is down. Respects ctx
 // cancellation; if ctx is itself a timeout context the timeout still surfaces
@@ -92,7 +112,7 @@ func Await(ctx context.Context, checkers []Checker, interval, timeout time.Durat
 	}
 	for {
 		last := Check(ctx, checkers)
-		if last.Status == "healthy" {
+		if last.Status != "unhealthy" {
 			return nil
 		}
 		if time.Now().After(deadline) {
@@ -127,3 +127,36 @@ func TestAwait_TimeoutCtx_NamesFailingDep(t *testing.T) {
 	assert.Contains(t, err.Error(), "not ready after")
 	assert.NotContains(t, err.Error(), "context deadline exceeded")
 }
+
+func TestCheck_OptionalDownDegrades(t *testing.T) {
+	checkers := []Checker{
+		{Name: "mysql", Ping: func(context.Context) error { return nil }},
+		{Name: "kafka", Optional: true, Ping: func(context.Context) error { return errors.New("broker down") }},
+	}
+	r := Check(context.Background(), checkers)
+	assert.Equal(t, "degraded", r.Status)
+	assert.Equal(t, "down", r.Checks[1].Status)
+	assert.True(t, r.Checks[1].Optional)
+	assert.Nil(t, r.Checks[1].LastSuccess)
+
+	checkers[0].Ping = func(context.Context) error { return errors.New("db down") }
+	r = Check(context.Background(), checkers)
+	assert.Equal(t, "unhealthy", r.Status)
+}
+
+func TestCheck_RecordsLatencyAndLastSuccess(t *testing.T) {
+	checkers := []Checker{{Name: "redis", Ping: func(context.Context) error {
+		time.Sleep(5 * time.Millisecond)
+		return nil
+	}}}
+	r := Check(context.Background(), checkers)
+	assert.GreaterOrEqual(t, r.Checks[0].LatencyMs, 5.0)
+	assert.NotNil(t, r.Checks[0].LastSuccess)
+	assert.False(t, r.CheckedAt.IsZero())
+}
+
+func TestAwait_OptionalDownIsReady(t *testing.T) {
+	c := Checker{Name: "kafka", Optional: true, Ping: func(context.Context) error { return errors.New("nope") }}
+	err := Await(context.Background(), []Checker{c}, 10*time.Millisecond, 50*time.Millisecond)
+	assert.NoError(t, err)
+}
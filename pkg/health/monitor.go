@@ -0,0 +1,98 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Monitor runs the checkers in the background and keeps the latest Report,
+// so readiness probes answer from memory instead of pinging every dependency
+// on each request.
+type Monitor struct {
+	checkers []Checker
+	interval time.Duration
+
+	mu          sync.RWMutex
+	report      Report
+	checked     bool
+	started     bool
+	lastSuccess map[string]time.Time
+}
+
+// NewMonitor returns a Monitor that re-checks every interval once Run is
+// called. Until the first check completes, Ready reports false.
+func NewMonitor(checkers []Checker, interval time.Duration) *Monitor {
+	return &Monitor{
+		checkers:    checkers,
+		interval:    interval,
+		lastSuccess: make(map[string]time.Time),
+	}
+}
+
+// Run checks immediately and then every interval until ctx is cancelled.
+func (m *Monitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		m.Refresh(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Refresh runs the checkers once and stores the report. A check that is down
+// keeps the time it was last up.
+func (m *Monitor) Refresh(ctx context.Context) Report {
+	report := Check(ctx, m.checkers)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, c := range report.Checks {
+		if c.LastSuccess != nil {
+			m.lastSuccess[c.Name] = *c.LastSuccess
+			continue
+		}
+		if t, ok := m.lastSuccess[c.Name]; ok {
+			report.Checks[i].LastSuccess = &t
+		}
+	}
+
+	m.report = report
+	m.checked = true
+	if report.Status != "unhealthy" {
+		m.started = true
+	}
+
+	return report
+}
+
+// Report returns the latest report and whether a check has completed yet.
+func (m *Monitor) Report() (Report, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.report, m.checked
+}
+
+// Started reports whether any check so far has found every required
+// dependency up. Once true it stays true; later outages affect Ready only.
+func (m *Monitor) Started() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.started
+}
+
+// Ready reports whether the last check found every required dependency up.
+// Optional dependencies being down leaves the app ready but degraded.
+func (m *Monitor) Ready() bool {
+	report, checked := m.Report()
+	return checked && report.Status != "unhealthy"
+}
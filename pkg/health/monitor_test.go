@@ -0,0 +1,73 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMonitor_NotReadyBeforeFirstCheck(t *testing.T) {
+	m := NewMonitor([]Checker{{Name: "mysql", Ping: func(context.Context) error { return nil }}}, time.Minute)
+
+	_, checked := m.Report()
+	assert.False(t, checked)
+	assert.False(t, m.Ready())
+	assert.False(t, m.Started())
+}
+
+func TestMonitor_KeepsLastSuccess(t *testing.T) {
+	var down atomic.Bool
+	m := NewMonitor([]Checker{{Name: "mysql", Ping: func(context.Context) error {
+		if down.Load() {
+			return errors.New("db down")
+		}
+		return nil
+	}}}, time.Minute)
+
+	first := m.Refresh(context.Background())
+	assert.True(t, m.Ready())
+	assert.True(t, m.Started())
+
+	down.Store(true)
+	second := m.Refresh(context.Background())
+	assert.False(t, m.Ready())
+	assert.True(t, m.Started(), "started stays latched after an outage")
+	assert.Equal(t, "down", second.Checks[0].Status)
+	if assert.NotNil(t, second.Checks[0].LastSuccess) {
+		assert.Equal(t, *first.Checks[0].LastSuccess, *second.Checks[0].LastSuccess)
+	}
+}
+
+func TestMonitor_DegradedIsReady(t *testing.T) {
+	m := NewMonitor([]Checker{
+		{Name: "mysql", Ping: func(context.Context) error { return nil }},
+		{Name: "kafka", Optional: true, Ping: func(context.Context) error { return errors.New("broker down") }},
+	}, time.Minute)
+
+	report := m.Refresh(context.Background())
+	assert.Equal(t, "degraded", report.Status)
+	assert.True(t, m.Ready())
+}
+
+func TestMonitor_RunRefreshesUntilCancelled(t *testing.T) {
+	var calls atomic.Int32
+	m := NewMonitor([]Checker{{Name: "redis", Ping: func(context.Context) error {
+		calls.Add(1)
+		return nil
+	}}}, 10*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		m.Run(ctx)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool { return calls.Load() >= 3 }, time.Second, 5*time.Millisecond)
+	cancel()
+	<-done
+}
@@ -0,0 +1,217 @@
+// Package ical reads and writes the subset of iCalendar (RFC 5545) used to
+// sync room availability with other booking sites: a VCALENDAR of VEVENTs
+// with a UID, a summary and a start and end date.
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	dateLayout     = "20060102"
+	dateTimeLayout = "20060102T150405"
+	// lineLimit is the longest content line allowed, in octets, before it is
+	// folded.
+	lineLimit = 75
+)
+
+// ErrInvalid is returned by Parse for input that is not an iCalendar object.
+var ErrInvalid = errors.New("ical: invalid calendar")
+
+// Event is a VEVENT. Start and End are dates: End is exclusive, so a one
+// night stay ends on the day of departure.
+type Event struct {
+	UID     string
+	Summary string
+	Start   time.Time
+	End     time.Time
+}
+
+// Write writes events as a calendar named name. prodID identifies the
+// product that made it.
+func Write(w io.Writer, prodID, name string, events []Event, stamp time.Time) error {
+	bw := bufio.NewWriter(w)
+
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:" + escape(prodID),
+		"CALSCALE:GREGORIAN",
+		"METHOD:PUBLISH",
+		"X-WR-CALNAME:" + escape(name),
+	}
+
+	for _, e := range events {
+		lines = append(lines,
+			"BEGIN:VEVENT",
+			"UID:"+escape(e.UID),
+			"DTSTAMP:"+stamp.UTC().Format(dateTimeLayout)+"Z",
+			"DTSTART;VALUE=DATE:"+e.Start.Format(dateLayout),
+			"DTEND;VALUE=DATE:"+e.End.Format(dateLayout),
+			"SUMMARY:"+escape(e.Summary),
+			"TRANSP:OPAQUE",
+			"END:VEVENT",
+		)
+	}
+
+	lines = append(lines, "END:VCALENDAR")
+
+	for _, line := range lines {
+		_, err := bw.WriteString(fold(line))
+		if err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// fold splits line into CRLF terminated chunks of at most lineLimit octets,
+// continuing each on a line starting with a space. Multi-byte characters
+// are not split.
+func fold(line string) string {
+	var b strings.Builder
+	limit := lineLimit
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		limit = lineLimit - 1
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+	return b.String()
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+var unescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+
+// Parse reads the VEVENTs of a calendar. Events without a start are skipped;
+// an event without an end lasts one day. Times are truncated to their date.
+func Parse(r io.Reader) ([]Event, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(lines) == 0 || !strings.EqualFold(lines[0], "BEGIN:VCALENDAR") {
+		return nil, ErrInvalid
+	}
+
+	var events []Event
+	var cur *Event
+	for _, line := range lines {
+		name, params, value, ok := property(line)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case name == "BEGIN" && strings.EqualFold(value, "VEVENT"):
+			cur = &Event{}
+		case name == "END" && strings.EqualFold(value, "VEVENT"):
+			if cur != nil && !cur.Start.IsZero() {
+				if cur.End.IsZero() || !cur.End.After(cur.Start) {
+					cur.End = cur.Start.AddDate(0, 0, 1)
+				}
+				events = append(events, *cur)
+			}
+			cur = nil
+		case cur == nil:
+		case name == "UID":
+			cur.UID = unescaper.Replace(value)
+		case name == "SUMMARY":
+			cur.Summary = unescaper.Replace(value)
+		case name == "DTSTART":
+			cur.Start, err = parseDate(value, params)
+			if err != nil {
+				return nil, err
+			}
+		case name == "DTEND":
+			cur.End, err = parseDate(value, params)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return events, nil
+}
+
+// unfold joins continuation lines and drops empty ones.
+func unfold(r io.Reader) ([]string, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var lines []string
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	return lines, sc.Err()
+}
+
+// property splits "NAME;PARAM=x:value" into its name, parameters and value.
+func property(line string) (string, map[string]string, string, bool) {
+	head, value, ok := strings.Cut(line, ":")
+	if !ok {
+		return "", nil, "", false
+	}
+
+	parts := strings.Split(head, ";")
+	params := make(map[string]string, len(parts)-1)
+	for _, p := range parts[1:] {
+		k, v, _ := strings.Cut(p, "=")
+		params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+	}
+
+	return strings.ToUpper(parts[0]), params, value, true
+}
+
+// parseDate reads a DATE or DATE-TIME value as a date in UTC. Date-times
+// with a TZID are read in that zone, floating ones in UTC.
+func parseDate(value string, params map[string]string) (time.Time, error) {
+	if len(value) == len(dateLayout) {
+		t, err := time.Parse(dateLayout, value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+		return t, nil
+	}
+
+	loc := time.UTC
+	if tzid := params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+
+	t, err := time.ParseInLocation(dateTimeLayout, strings.TrimSuffix(value, "Z"), loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+}
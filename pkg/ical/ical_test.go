@@ -0,0 +1,80 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestWriteParse_RoundTrip(t *testing.T) {
+	events := []Event{
+		{UID: "booking-1@booking-system", Summary: "Booked", Start: date(2026, 11, 1), End: date(2026, 11, 4)},
+		{UID: "block-2@booking-system", Summary: "Maintenance; repaint, new\nfloor " + strings.Repeat("é", 60), Start: date(2026, 12, 24), End: date(2026, 12, 26)},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, "-//booking-system//rooms//EN", "Room 10", events, time.Now()))
+
+	out := buf.String()
+	assert.True(t, strings.HasPrefix(out, "BEGIN:VCALENDAR\r\n"))
+	assert.Contains(t, out, "DTSTART;VALUE=DATE:20261101\r\n")
+	for _, line := range strings.Split(out, "\r\n") {
+		assert.LessOrEqual(t, len(line), lineLimit, line)
+	}
+
+	parsed, err := Parse(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, events, parsed)
+}
+
+func TestParse_ExternalFeed(t *testing.T) {
+	feed := "BEGIN:VCALENDAR\r\n" +
+		"PRODID:-//Airbnb Inc//Hosting Calendar 0.8.8//EN\r\n" +
+		"VERSION:2.0\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"DTEND;VALUE=DATE:20261110\r\n" +
+		"DTSTART;VALUE=DATE:20261105\r\n" +
+		"UID:1418fb94e984-abc@airbnb.com\r\n" +
+		"SUMMARY:Reserved\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"DTSTART;TZID=Africa/Nairobi:20261201T140000\r\n" +
+		"DTEND;TZID=Africa/Nairobi:20261203T100000\r\n" +
+		"UID:long-uid-that-is-\r\n" +
+		" folded\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"DTSTART:20261220T120000Z\r\n" +
+		"UID:no-end\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:no-start\r\n" +
+		"END:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+
+	events, err := Parse(strings.NewReader(feed))
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+
+	assert.Equal(t, Event{UID: "1418fb94e984-abc@airbnb.com", Summary: "Reserved", Start: date(2026, 11, 5), End: date(2026, 11, 10)}, events[0])
+	assert.Equal(t, "long-uid-that-is-folded", events[1].UID)
+	assert.Equal(t, date(2026, 12, 1), events[1].Start)
+	assert.Equal(t, date(2026, 12, 3), events[1].End)
+	assert.Equal(t, date(2026, 12, 21), events[2].End)
+}
+
+func TestParse_Invalid(t *testing.T) {
+	_, err := Parse(strings.NewReader("<html>not a calendar</html>"))
+	assert.ErrorIs(t, err, ErrInvalid)
+
+	_, err = Parse(strings.NewReader("BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART:2026-11-01\nEND:VEVENT\nEND:VCALENDAR\n"))
+	assert.ErrorIs(t, err, ErrInvalid)
+}
@@ -0,0 +1,317 @@
+// Package jwtkeys holds the keys auth tokens are signed and verified with.
+//
+// Tokens are signed with RS256 or EdDSA keys identified by a kid header.
+// Each key has a schedule: it signs from ActiveFrom until a newer key takes
+// over, and keeps verifying until RetireAt. A key is published in the JWKS
+// as soon as it is configured, so other services learn it before it signs.
+// The old HMAC secret, if set, still verifies HS256 tokens and signs when no
+// key is active, until its cutoff; after that HS256 tokens are refused.
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+	AlgHS256 = "HS256"
+)
+
+// minRSABits is the smallest RSA key accepted for signing.
+const minRSABits = 2048
+
+var ErrNoSigningKey = errors.New("jwtkeys: no active signing key")
+
+// Key is one signing key. A zero ActiveFrom signs right away and a zero
+// RetireAt never retires.
+type Key struct {
+	ID         string
+	Alg        string
+	ActiveFrom time.Time
+	RetireAt   time.Time
+	signer     crypto.Signer
+}
+
+// Keyring signs tokens with the newest active key and verifies them with
+// any key that has not retired.
+type Keyring struct {
+	keys        []*Key
+	secret      []byte
+	secretUntil time.Time
+	now         func() time.Time
+}
+
+// JWK is a public key in the JSON Web Key format.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS is the document served at /.well-known/jwks.json.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// New loads the configured keys. secret is the HMAC secret of tokens issued
+// before asymmetric keys were set up. secretUntil, an RFC 3339 time, is when
+// it stops being accepted; empty accepts it until it is removed.
+func New(configs []entities.SigningKeyConfig, secret, secretUntil string) (*Keyring, error) {
+	k := &Keyring{now: time.Now}
+	if secret != "" {
+		k.secret = []byte(secret)
+	}
+
+	var err error
+	k.secretUntil, err = parseTime(secretUntil)
+	if err != nil {
+		return nil, fmt.Errorf("jwtkeys: secret cutoff: %w", err)
+	}
+
+	seen := map[string]bool{}
+	for _, c := range configs {
+		if c.ID == "" {
+			return nil, errors.New("jwtkeys: signing key without kid")
+		}
+		if seen[c.ID] {
+			return nil, fmt.Errorf("jwtkeys: duplicate kid %q", c.ID)
+		}
+		seen[c.ID] = true
+
+		key, err := loadKey(c)
+		if err != nil {
+			return nil, fmt.Errorf("jwtkeys: key %q: %w", c.ID, err)
+		}
+		k.keys = append(k.keys, key)
+	}
+
+	sort.SliceStable(k.keys, func(i, j int) bool {
+		return k.keys[i].ActiveFrom.Before(k.keys[j].ActiveFrom)
+	})
+
+	if len(k.keys) == 0 && k.secret == nil {
+		return nil, ErrNoSigningKey
+	}
+
+	return k, nil
+}
+
+// NewHMAC returns a keyring that signs and verifies with secret only.
+func NewHMAC(secret string) *Keyring {
+	return &Keyring{secret: []byte(secret), now: time.Now}
+}
+
+func loadKey(c entities.SigningKeyConfig) (*Key, error) {
+	data := []byte(c.PEM)
+	if c.File != "" {
+		var err error
+		data, err = os.ReadFile(c.File)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	signer, err := ParsePrivateKey(data)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &Key{ID: c.ID, signer: signer}
+	switch s := signer.(type) {
+	case *rsa.PrivateKey:
+		if s.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("rsa key must be at least %d bits", minRSABits)
+		}
+		key.Alg = AlgRS256
+	case ed25519.PrivateKey:
+		key.Alg = AlgEdDSA
+	}
+
+	key.ActiveFrom, err = parseTime(c.ActiveFrom)
+	if err != nil {
+		return nil, fmt.Errorf("activefrom: %w", err)
+	}
+
+	key.RetireAt, err = parseTime(c.RetireAt)
+	if err != nil {
+		return nil, fmt.Errorf("retireat: %w", err)
+	}
+
+	if !key.RetireAt.IsZero() && !key.RetireAt.After(key.ActiveFrom) {
+		return nil, errors.New("retireat must be after activefrom")
+	}
+
+	return key, nil
+}
+
+func parseTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+// ParsePrivateKey reads a PEM encoded PKCS#8 RSA or Ed25519 key, or a PKCS#1
+// RSA key.
+func ParsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	if block.Type == "RSA PRIVATE KEY" {
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return k, nil
+	case ed25519.PrivateKey:
+		return k, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+}
+
+func (k *Key) retired(now time.Time) bool {
+	return !k.RetireAt.IsZero() && !now.Before(k.RetireAt)
+}
+
+func (k *Key) active(now time.Time) bool {
+	return !now.Before(k.ActiveFrom) && !k.retired(now)
+}
+
+// SecretAccepted reports whether the HMAC secret still signs and verifies
+// HS256 tokens.
+func (k *Keyring) SecretAccepted() bool {
+	return k.secret != nil && (k.secretUntil.IsZero() || k.now().Before(k.secretUntil))
+}
+
+// SecretUntil returns when the HMAC secret stops being accepted, or the zero
+// time if it has no cutoff.
+func (k *Keyring) SecretUntil() time.Time {
+	return k.secretUntil
+}
+
+// Current returns the key tokens are signed with now: the active key that
+// became active last. It is nil when only the HMAC secret can sign.
+func (k *Keyring) Current() *Key {
+	now := k.now()
+	for i := len(k.keys) - 1; i >= 0; i-- {
+		if k.keys[i].active(now) {
+			return k.keys[i]
+		}
+	}
+	return nil
+}
+
+// Sign returns claims as a token signed with the current key.
+func (k *Keyring) Sign(claims jwt.Claims) (string, error) {
+	key := k.Current()
+	if key == nil {
+		if !k.SecretAccepted() {
+			return "", ErrNoSigningKey
+		}
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(k.secret)
+	}
+
+	var method jwt.SigningMethod = jwt.SigningMethodRS256
+	if key.Alg == AlgEdDSA {
+		method = jwt.SigningMethodEdDSA
+	}
+
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = key.ID
+
+	return token.SignedString(key.signer)
+}
+
+// Parse verifies tokenString with the key its header names and fills
+// claims. HS256 is only a valid method while the HMAC secret is accepted.
+func (k *Keyring) Parse(tokenString string, claims jwt.Claims) (*jwt.Token, error) {
+	methods := []string{AlgRS256, AlgEdDSA}
+	if k.SecretAccepted() {
+		methods = append(methods, AlgHS256)
+	}
+
+	return jwt.ParseWithClaims(tokenString, claims, k.keyFor, jwt.WithValidMethods(methods))
+}
+
+func (k *Keyring) keyFor(t *jwt.Token) (interface{}, error) {
+	if t.Method.Alg() == AlgHS256 {
+		if !k.SecretAccepted() {
+			return nil, errors.New("hmac tokens are no longer accepted")
+		}
+		slog.Warn("accepting a legacy hs256 auth token", "until", k.secretUntil)
+		return k.secret, nil
+	}
+
+	kid, _ := t.Header["kid"].(string)
+	now := k.now()
+	for _, key := range k.keys {
+		if key.ID != kid {
+			continue
+		}
+		if key.retired(now) {
+			return nil, fmt.Errorf("signing key %q is retired", kid)
+		}
+		if key.Alg != t.Method.Alg() {
+			return nil, fmt.Errorf("signing key %q is not %s", kid, t.Method.Alg())
+		}
+		return key.signer.Public(), nil
+	}
+
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// JWKS returns the public half of every key that has not retired.
+func (k *Keyring) JWKS() JWKS {
+	now := k.now()
+	set := JWKS{Keys: []JWK{}}
+	for _, key := range k.keys {
+		if key.retired(now) {
+			continue
+		}
+
+		jwk := JWK{Kid: key.ID, Use: "sig", Alg: key.Alg}
+		switch pub := key.signer.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set
+}
@@ -0,0 +1,228 @@
+package jwtkeys
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func rsaPEM(t *testing.T, bits int) string {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+func ed25519PEM(t *testing.T) string {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+func at(ts time.Time) func() time.Time {
+	return func() time.Time { return ts }
+}
+
+func sign(t *testing.T, k *Keyring) string {
+	t.Helper()
+	token, err := k.Sign(jwt.RegisteredClaims{Subject: "7", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))})
+	require.NoError(t, err)
+	return token
+}
+
+func TestSignAndParse(t *testing.T) {
+	for name, key := range map[string]string{AlgRS256: rsaPEM(t, 2048), AlgEdDSA: ed25519PEM(t)} {
+		t.Run(name, func(t *testing.T) {
+			k, err := New([]entities.SigningKeyConfig{{ID: "k1", PEM: key}}, "", "")
+			require.NoError(t, err)
+
+			token := sign(t, k)
+			parsed, err := k.Parse(token, &jwt.RegisteredClaims{})
+			require.NoError(t, err)
+			assert.Equal(t, "k1", parsed.Header["kid"])
+			assert.Equal(t, name, parsed.Header["alg"])
+
+			sub, _ := parsed.Claims.GetSubject()
+			assert.Equal(t, "7", sub)
+		})
+	}
+}
+
+func TestRotation(t *testing.T) {
+	t0 := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	configs := []entities.SigningKeyConfig{
+		{ID: "new", PEM: ed25519PEM(t), ActiveFrom: "2026-10-10T00:00:00Z"},
+		{ID: "old", PEM: ed25519PEM(t), RetireAt: "2026-10-11T00:00:00Z"},
+	}
+
+	k, err := New(configs, "", "")
+	require.NoError(t, err)
+
+	// Before the switch the old key signs and the new one is already published.
+	k.now = at(t0)
+	assert.Equal(t, "old", k.Current().ID)
+	assert.Len(t, k.JWKS().Keys, 2)
+	oldToken := sign(t, k)
+
+	// After the switch the new key signs, and old tokens still verify.
+	k.now = at(t0.Add(9*24*time.Hour + time.Hour))
+	assert.Equal(t, "new", k.Current().ID)
+	_, err = k.Parse(oldToken, &jwt.RegisteredClaims{})
+	assert.NoError(t, err)
+
+	// Once the old key retires its tokens are refused and it leaves the JWKS.
+	k.now = at(t0.Add(10*24*time.Hour + time.Hour))
+	_, err = k.Parse(oldToken, &jwt.RegisteredClaims{})
+	assert.Error(t, err)
+	assert.Equal(t, []string{"new"}, kids(k.JWKS()))
+}
+
+func kids(set JWKS) []string {
+	out := []string{}
+	for _, k := range set.Keys {
+		out = append(out, k.Kid)
+	}
+	return out
+}
+
+func TestHMAC(t *testing.T) {
+	legacy := NewHMAC("secret")
+	token := sign(t, legacy)
+
+	parsed, err := legacy.Parse(token, &jwt.RegisteredClaims{})
+	require.NoError(t, err)
+	assert.Equal(t, AlgHS256, parsed.Header["alg"])
+	assert.Empty(t, legacy.JWKS().Keys)
+
+	// Keys take over signing but tokens from the secret still verify.
+	k, err := New([]entities.SigningKeyConfig{{ID: "k1", PEM: ed25519PEM(t)}}, "secret", "")
+	require.NoError(t, err)
+	_, err = k.Parse(token, &jwt.RegisteredClaims{})
+	assert.NoError(t, err)
+	assert.Equal(t, AlgEdDSA, k.Current().Alg)
+
+	// Without the secret they do not.
+	k, err = New([]entities.SigningKeyConfig{{ID: "k1", PEM: ed25519PEM(t)}}, "", "")
+	require.NoError(t, err)
+	_, err = k.Parse(token, &jwt.RegisteredClaims{})
+	assert.Error(t, err)
+
+	// Nor after the secret's cutoff, even while it is still configured.
+	k, err = New([]entities.SigningKeyConfig{{ID: "k1", PEM: ed25519PEM(t)}}, "secret", "2026-11-01T00:00:00Z")
+	require.NoError(t, err)
+	k.now = func() time.Time { return time.Date(2026, 10, 31, 0, 0, 0, 0, time.UTC) }
+	assert.True(t, k.SecretAccepted())
+	_, err = k.Parse(token, &jwt.RegisteredClaims{})
+	assert.NoError(t, err)
+
+	k.now = func() time.Time { return time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC) }
+	assert.False(t, k.SecretAccepted())
+	_, err = k.Parse(token, &jwt.RegisteredClaims{})
+	assert.Error(t, err)
+
+	_, err = New(nil, "secret", "next week")
+	assert.Error(t, err)
+}
+
+func TestParseRejects(t *testing.T) {
+	k, err := New([]entities.SigningKeyConfig{{ID: "k1", PEM: ed25519PEM(t)}}, "", "")
+	require.NoError(t, err)
+	other, err := New([]entities.SigningKeyConfig{{ID: "k2", PEM: ed25519PEM(t)}}, "", "")
+	require.NoError(t, err)
+	sameKid, err := New([]entities.SigningKeyConfig{{ID: "k1", PEM: ed25519PEM(t)}}, "", "")
+	require.NoError(t, err)
+
+	for name, token := range map[string]string{
+		"unknown kid":   sign(t, other),
+		"wrong key":     sign(t, sameKid),
+		"none alg":      "eyJhbGciOiJub25lIiwidHlwIjoiSldUIn0.eyJzdWIiOiI3In0.",
+		"not a token":   "garbage",
+		"hmac disabled": sign(t, NewHMAC("secret")),
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := k.Parse(token, &jwt.RegisteredClaims{})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	rsaKey := rsaPEM(t, 2048)
+	k, err := New([]entities.SigningKeyConfig{{ID: "r1", PEM: rsaKey}, {ID: "e1", PEM: ed25519PEM(t)}}, "secret", "")
+	require.NoError(t, err)
+
+	set := k.JWKS()
+	require.Len(t, set.Keys, 2)
+
+	signer, err := ParsePrivateKey([]byte(rsaKey))
+	require.NoError(t, err)
+	pub := signer.Public().(*rsa.PublicKey)
+
+	r := set.Keys[0]
+	assert.Equal(t, "RSA", r.Kty)
+	assert.Equal(t, AlgRS256, r.Alg)
+	assert.Equal(t, "sig", r.Use)
+	n, err := base64.RawURLEncoding.DecodeString(r.N)
+	require.NoError(t, err)
+	assert.Equal(t, 0, new(big.Int).SetBytes(n).Cmp(pub.N))
+	assert.Equal(t, "AQAB", r.E)
+
+	e := set.Keys[1]
+	assert.Equal(t, "OKP", e.Kty)
+	assert.Equal(t, "Ed25519", e.Crv)
+	assert.Empty(t, e.N)
+}
+
+func TestNew(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(file, []byte(ed25519PEM(t)), 0o600))
+
+	k, err := New([]entities.SigningKeyConfig{{ID: "f1", File: file}}, "", "")
+	require.NoError(t, err)
+	assert.Equal(t, "f1", k.Current().ID)
+
+	invalid := map[string][]entities.SigningKeyConfig{
+		"no keys":       nil,
+		"missing kid":   {{PEM: ed25519PEM(t)}},
+		"duplicate kid": {{ID: "a", PEM: ed25519PEM(t)}, {ID: "a", PEM: ed25519PEM(t)}},
+		"not pem":       {{ID: "a", PEM: "nope"}},
+		"small rsa":     {{ID: "a", PEM: rsaPEM(t, 1024)}},
+		"bad time":      {{ID: "a", PEM: ed25519PEM(t), ActiveFrom: "tomorrow"}},
+		"retires first": {{ID: "a", PEM: ed25519PEM(t), ActiveFrom: "2026-10-10T00:00:00Z", RetireAt: "2026-10-01T00:00:00Z"}},
+		"missing file":  {{ID: "a", File: filepath.Join(dir, "missing.pem")}},
+	}
+	for name, configs := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(configs, "", "")
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestSignWithoutActiveKey(t *testing.T) {
+	k, err := New([]entities.SigningKeyConfig{{ID: "later", PEM: ed25519PEM(t), ActiveFrom: "2099-01-01T00:00:00Z"}}, "", "")
+	require.NoError(t, err)
+
+	assert.Nil(t, k.Current())
+	_, err = k.Sign(jwt.RegisteredClaims{})
+	assert.ErrorIs(t, err, ErrNoSigningKey)
+}
@@ -0,0 +1,154 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/redis/go-redis/v9"
+)
+
+const namespace = "booking"
+
+// Registry holds every metric the service exposes. It is separate from the
+// prometheus default registry so tests and libraries cannot leak into it.
+var Registry = prometheus.NewRegistry()
+
+var (
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests served, by chi route pattern.",
+	}, []string{"server", "method", "route", "status"})
+
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency, by chi route pattern.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"server", "method", "route"})
+
+	// KafkaDeliveries counts delivery reports from the Kafka producer. result
+	// is "success" or "failure".
+	KafkaDeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "kafka_deliveries_total",
+		Help:      "Kafka producer delivery reports, by topic and result.",
+	}, []string{"topic", "result"})
+
+	// KafkaDeliveryDuration is the time from handing a message to the
+	// producer until its delivery report arrived.
+	KafkaDeliveryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "kafka_delivery_duration_seconds",
+		Help:      "Time until a Kafka delivery report arrived, by topic.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"topic"})
+
+	// EventsDeduplicated counts redelivered events a consumer skipped because
+	// it had already processed them.
+	EventsDeduplicated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_deduplicated_total",
+		Help:      "Redelivered events skipped by consumers, by consumer and event type.",
+	}, []string{"consumer", "type"})
+
+	// WebhookDeliveries counts webhook delivery attempts. status is
+	// "succeeded", "pending" (will be retried) or "failed".
+	WebhookDeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_deliveries_total",
+		Help:      "Webhook delivery attempts, by event type and resulting status.",
+	}, []string{"type", "status"})
+
+	// RateLimited counts requests refused by a rate limit, by route and the
+	// key (ip, user or email) that hit its limit.
+	RateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_total",
+		Help:      "Requests refused by rate limits, by route and key.",
+	}, []string{"route", "key"})
+
+	// LoginLockouts counts accounts locked after repeated failed logins.
+	LoginLockouts = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "login_lockouts_total",
+		Help:      "Accounts locked after repeated failed logins.",
+	})
+
+	// RabbitMessages counts messages taken off a RabbitMQ queue. outcome is
+	// "consumed" for every delivery, then "ack" or "nack".
+	RabbitMessages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rabbitmq_messages_total",
+		Help:      "RabbitMQ messages consumed, acked and nacked, by queue.",
+	}, []string{"queue", "outcome"})
+
+	RabbitReconnects = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rabbitmq_reconnects_total",
+		Help:      "Times the RabbitMQ connection was re-established after dropping.",
+	})
+
+	BookingsCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "bookings_created_total",
+		Help:      "Bookings created by guests.",
+	})
+
+	BookingsConfirmed = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "bookings_confirmed_total",
+		Help:      "Bookings confirmed after a successful payment.",
+	})
+
+	// Payments counts verified guest payments. result is "succeeded" or
+	// "failed", so the failure rate is failed over the sum of both.
+	Payments = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "payments_total",
+		Help:      "Guest payments verified with the payment provider, by result.",
+	}, []string{"result"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPDuration,
+		KafkaDeliveries,
+		KafkaDeliveryDuration,
+		EventsDeduplicated,
+		WebhookDeliveries,
+		RateLimited,
+		LoginLockouts,
+		RabbitMessages,
+		RabbitReconnects,
+		BookingsCreated,
+		BookingsConfirmed,
+		Payments,
+	)
+
+	// Pre-create both payment results so the failure rate is defined before
+	// the first failure.
+	Payments.WithLabelValues("succeeded")
+	Payments.WithLabelValues("failed")
+}
+
+// Handler serves the registry in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// RegisterDB exposes the connection pool stats of db.
+func RegisterDB(db *sql.DB, name string) error {
+	return Registry.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// RegisterRedis exposes the connection pool stats of client.
+func RegisterRedis(client *redis.Client) error {
+	return Registry.Register(newRedisPoolCollector(client))
+}
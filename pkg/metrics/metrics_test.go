@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware_LabelsByRoutePattern(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(Middleware("test"))
+	r.Get("/rooms/{room_id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	for _, path := range []string{"/rooms/1", "/rooms/2"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(HTTPRequests.WithLabelValues("test", "GET", "/rooms/{room_id}", "418")))
+	assert.Equal(t, 1, testutil.CollectAndCount(HTTPDuration.MustCurryWith(prometheus.Labels{"server": "test"})))
+}
+
+func TestMiddleware_UnmatchedRoute(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(Middleware("unmatched-test"))
+	r.Get("/rooms", func(w http.ResponseWriter, r *http.Request) {})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/wp-admin/login.php", nil))
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(HTTPRequests.WithLabelValues("unmatched-test", "GET", unmatchedRoute, "404")))
+}
+
+func TestRedisPoolCollector(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	assert.NoError(t, client.Ping(t.Context()).Err())
+
+	collector := newRedisPoolCollector(client)
+	assert.Equal(t, 6, testutil.CollectAndCount(collector))
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(`
+# HELP booking_redis_pool_total_connections Connections in the pool.
+# TYPE booking_redis_pool_total_connections gauge
+booking_redis_pool_total_connections 1
+`), "booking_redis_pool_total_connections"))
+}
+
+func TestHandler_ExposesPaymentResults(t *testing.T) {
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `booking_payments_total{result="failed"}`)
+	assert.Contains(t, w.Body.String(), `booking_payments_total{result="succeeded"}`)
+	assert.Contains(t, w.Body.String(), "booking_bookings_created_total")
+}
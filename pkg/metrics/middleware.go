@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// unmatchedRoute labels requests that did not match any route, so scanners
+// probing random paths cannot blow up the label cardinality.
+const unmatchedRoute = "unmatched"
+
+// Middleware records the count and latency of every request served by a chi
+// router, labelled with the matched route pattern rather than the raw path.
+func Middleware(server string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			next.ServeHTTP(ww, r)
+
+			route := unmatchedRoute
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			HTTPRequests.WithLabelValues(server, r.Method, route, strconv.Itoa(status)).Inc()
+			HTTPDuration.WithLabelValues(server, r.Method, route).Observe(time.Since(start).Seconds())
+		})
+	}
+}
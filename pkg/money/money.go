@@ -0,0 +1,127 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// DefaultCurrency is used for rooms and payments that do not specify one.
+const DefaultCurrency = "KES"
+
+// Money is an amount in the currency's minor unit (cents for KES/USD, whole
+// yen for JPY) together with its ISO 4217 code. Never use floats for money.
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+var ErrInvalidCurrency = errors.New("currency must be a 3 letter ISO 4217 code")
+var ErrCurrencyMismatch = errors.New("cannot combine amounts in different currencies")
+
+// zeroDecimal lists the currencies Stripe charges without a minor unit.
+var zeroDecimal = map[string]bool{
+	"BIF": true, "CLP": true, "DJF": true, "GNF": true, "JPY": true, "KMF": true,
+	"KRW": true, "MGA": true, "PYG": true, "RWF": true, "UGX": true, "VND": true,
+	"VUV": true, "XAF": true, "XOF": true, "XPF": true,
+}
+
+// threeDecimal lists the currencies with a thousandth minor unit.
+var threeDecimal = map[string]bool{
+	"BHD": true, "JOD": true, "KWD": true, "OMR": true, "TND": true,
+}
+
+func New(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: strings.ToUpper(currency)}
+}
+
+// NormalizeCurrency upper-cases code, falling back to DefaultCurrency when it
+// is empty, and rejects anything that is not three letters.
+func NormalizeCurrency(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		return DefaultCurrency, nil
+	}
+
+	if len(code) != 3 {
+		return "", ErrInvalidCurrency
+	}
+
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return "", ErrInvalidCurrency
+		}
+	}
+
+	return code, nil
+}
+
+// Exponent returns the number of decimal places of currency's minor unit.
+func Exponent(currency string) int {
+	currency = strings.ToUpper(currency)
+	switch {
+	case zeroDecimal[currency]:
+		return 0
+	case threeDecimal[currency]:
+		return 3
+	default:
+		return 2
+	}
+}
+
+// FromMajor parses a decimal amount such as "7000" or "49.99" expressed in
+// major units. Amounts with more decimals than the currency allows are
+// rejected rather than silently rounded.
+func FromMajor(major, currency string) (Money, error) {
+	currency, err := NormalizeCurrency(currency)
+	if err != nil {
+		return Money{}, err
+	}
+
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(major))
+	if !ok {
+		return Money{}, fmt.Errorf("invalid amount %q", major)
+	}
+
+	if r.Sign() < 0 {
+		return Money{}, fmt.Errorf("amount %q must not be negative", major)
+	}
+
+	r.Mul(r, new(big.Rat).SetInt(pow10(Exponent(currency))))
+	if !r.IsInt() {
+		return Money{}, fmt.Errorf("amount %q has too many decimals for %s", major, currency)
+	}
+
+	if !r.Num().IsInt64() {
+		return Money{}, fmt.Errorf("amount %q is too large", major)
+	}
+
+	return Money{Amount: r.Num().Int64(), Currency: currency}, nil
+}
+
+// Major formats the amount in major units, e.g. 4999 KES => "49.99".
+func (m Money) Major() string {
+	exp := Exponent(m.Currency)
+	return new(big.Rat).SetFrac(big.NewInt(m.Amount), pow10(exp)).FloatString(exp)
+}
+
+func (m Money) String() string {
+	return m.Currency + " " + m.Major()
+}
+
+// Mul multiplies the amount, e.g. a nightly rate by the number of nights.
+func (m Money) Mul(n int64) Money {
+	return Money{Amount: m.Amount * n, Currency: m.Currency}
+}
+
+func (m Money) Add(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, ErrCurrencyMismatch
+	}
+	return Money{Amount: m.Amount + o.Amount, Currency: m.Currency}, nil
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
@@ -0,0 +1,81 @@
+package money
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeCurrency(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "", want: DefaultCurrency},
+		{in: "usd", want: "USD"},
+		{in: " KES ", want: "KES"},
+		{in: "US", wantErr: true},
+		{in: "U5D", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := NormalizeCurrency(tt.in)
+		if tt.wantErr {
+			assert.ErrorIs(t, err, ErrInvalidCurrency)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, got)
+	}
+}
+
+func TestFromMajor(t *testing.T) {
+	tests := []struct {
+		name     string
+		major    string
+		currency string
+		want     Money
+		wantErr  string
+	}{
+		{name: "whole shillings", major: "7000", currency: "KES", want: Money{Amount: 700000, Currency: "KES"}},
+		{name: "cents", major: "49.99", currency: "usd", want: Money{Amount: 4999, Currency: "USD"}},
+		{name: "zero decimal", major: "7000", currency: "JPY", want: Money{Amount: 7000, Currency: "JPY"}},
+		{name: "three decimal", major: "1.5", currency: "KWD", want: Money{Amount: 1500, Currency: "KWD"}},
+		{name: "too precise", major: "0.001", currency: "KES", wantErr: `amount "0.001" has too many decimals for KES`},
+		{name: "fraction of yen", major: "10.5", currency: "JPY", wantErr: `amount "10.5" has too many decimals for JPY`},
+		{name: "negative", major: "-1", currency: "KES", wantErr: `amount "-1" must not be negative`},
+		{name: "not a number", major: "ten", currency: "KES", wantErr: `invalid amount "ten"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FromMajor(tt.major, tt.currency)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMoney_Major(t *testing.T) {
+	assert.Equal(t, "49.99", New(4999, "USD").Major())
+	assert.Equal(t, "7000", New(7000, "JPY").Major())
+	assert.Equal(t, "1.500", New(1500, "KWD").Major())
+	assert.Equal(t, "KES 7000.00", New(700000, "KES").String())
+}
+
+func TestMoney_Arithmetic(t *testing.T) {
+	nightly := New(700000, "KES")
+	assert.Equal(t, New(2100000, "KES"), nightly.Mul(3))
+
+	sum, err := nightly.Add(New(50, "KES"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(700050), sum.Amount)
+
+	_, err = nightly.Add(New(50, "USD"))
+	assert.ErrorIs(t, err, ErrCurrencyMismatch)
+}
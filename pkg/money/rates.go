@@ -0,0 +1,110 @@
+package money
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+)
+
+// RateProvider returns how many units of to one unit of from buys.
+type RateProvider interface {
+	Rate(ctx context.Context, from, to string) (*big.Rat, error)
+}
+
+// FileRateProvider serves rates from a JSON file of the form
+//
+//	{"base": "USD", "rates": {"KES": "129.25", "EUR": "0.92"}}
+//
+// Cross rates between two non-base currencies are derived through the base.
+// It is a stub for local use until a live rates feed is wired in.
+type FileRateProvider struct {
+	base  string
+	rates map[string]*big.Rat
+}
+
+type rateFile struct {
+	Base  string            `json:"base"`
+	Rates map[string]string `json:"rates"`
+}
+
+func NewFileRateProvider(path string) (*FileRateProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var f rateFile
+	err = json.Unmarshal(data, &f)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rates file %s: %w", path, err)
+	}
+
+	base, err := NormalizeCurrency(f.Base)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &FileRateProvider{base: base, rates: map[string]*big.Rat{base: big.NewRat(1, 1)}}
+	for code, value := range f.Rates {
+		r, ok := new(big.Rat).SetString(value)
+		if !ok || r.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid rate %q for %s", value, code)
+		}
+		p.rates[strings.ToUpper(code)] = r
+	}
+
+	return p, nil
+}
+
+func (p *FileRateProvider) Rate(_ context.Context, from, to string) (*big.Rat, error) {
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+
+	fromRate, ok := p.rates[from]
+	if !ok {
+		return nil, fmt.Errorf("no exchange rate for %s", from)
+	}
+
+	toRate, ok := p.rates[to]
+	if !ok {
+		return nil, fmt.Errorf("no exchange rate for %s", to)
+	}
+
+	return new(big.Rat).Quo(toRate, fromRate), nil
+}
+
+// Convert converts m into currency to using p, rounding half away from zero
+// to the target currency's minor unit. It is meant for presentment only;
+// charges are always made in the room's own currency.
+func Convert(ctx context.Context, p RateProvider, m Money, to string) (Money, error) {
+	to, err := NormalizeCurrency(to)
+	if err != nil {
+		return Money{}, err
+	}
+
+	if to == m.Currency {
+		return m, nil
+	}
+
+	rate, err := p.Rate(ctx, m.Currency, to)
+	if err != nil {
+		return Money{}, err
+	}
+
+	// minor(to) = minor(from) * rate * 10^(exp(to) - exp(from))
+	v := new(big.Rat).Mul(new(big.Rat).SetInt64(m.Amount), rate)
+	v.Mul(v, new(big.Rat).SetInt(pow10(Exponent(to))))
+	v.Quo(v, new(big.Rat).SetInt(pow10(Exponent(m.Currency))))
+
+	return Money{Amount: roundHalfAway(v), Currency: to}, nil
+}
+
+func roundHalfAway(r *big.Rat) int64 {
+	q, rem := new(big.Int).QuoRem(r.Num(), r.Denom(), new(big.Int))
+	if new(big.Int).Abs(rem).Lsh(new(big.Int).Abs(rem), 1).Cmp(r.Denom()) >= 0 {
+		q.Add(q, big.NewInt(int64(r.Num().Sign())))
+	}
+	return q.Int64()
+}
@@ -0,0 +1,70 @@
+package money
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeRates(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "rates.json")
+	err := os.WriteFile(path, []byte(body), 0o600)
+	assert.NoError(t, err)
+	return path
+}
+
+func TestNewFileRateProvider(t *testing.T) {
+	t.Run("missing file", func(t *testing.T) {
+		_, err := NewFileRateProvider(filepath.Join(t.TempDir(), "nope.json"))
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid rate", func(t *testing.T) {
+		path := writeRates(t, `{"base":"USD","rates":{"KES":"0"}}`)
+		_, err := NewFileRateProvider(path)
+		assert.EqualError(t, err, `invalid rate "0" for KES`)
+	})
+
+	t.Run("ships with a valid stub", func(t *testing.T) {
+		_, err := NewFileRateProvider("../../files/rates/rates.json")
+		assert.NoError(t, err)
+	})
+}
+
+func TestConvert(t *testing.T) {
+	path := writeRates(t, `{"base":"USD","rates":{"KES":"125","JPY":"150","EUR":"0.9"}}`)
+	p, err := NewFileRateProvider(path)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name string
+		from Money
+		to   string
+		want Money
+	}{
+		{name: "same currency", from: New(700000, "KES"), to: "KES", want: New(700000, "KES")},
+		{name: "to base", from: New(700000, "KES"), to: "USD", want: New(5600, "USD")},
+		{name: "into zero decimal", from: New(1000, "USD"), to: "jpy", want: New(1500, "JPY")},
+		{name: "from zero decimal", from: New(1500, "JPY"), to: "USD", want: New(1000, "USD")},
+		{name: "cross rate", from: New(12500, "KES"), to: "EUR", want: New(90, "EUR")},
+		{name: "rounds half away from zero", from: New(1, "USD"), to: "JPY", want: New(2, "JPY")},
+		{name: "rounds down below half", from: New(1, "KES"), to: "JPY", want: New(0, "JPY")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Convert(context.Background(), p, tt.from, tt.to)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("unknown currency", func(t *testing.T) {
+		_, err := Convert(context.Background(), p, New(100, "KES"), "GBP")
+		assert.EqualError(t, err, "no exchange rate for GBP")
+	})
+}
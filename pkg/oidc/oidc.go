@@ -0,0 +1,182 @@
+// Package oidc logs users in with OpenID Connect providers using the
+// authorization code flow with PKCE, and verifies the ID tokens they issue.
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+)
+
+// GoogleIssuer is used for a provider named "google" with no issuer set.
+const GoogleIssuer = "https://accounts.google.com"
+
+// ErrUnknownProvider is returned for a provider name that is not configured.
+var ErrUnknownProvider = errors.New("oidc: unknown provider")
+
+// Identity is who the provider says logged in.
+type Identity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Provider is one configured OpenID Connect provider. Its discovery
+// document is fetched on first use, so a provider being down does not stop
+// the app from starting.
+type Provider struct {
+	name   string
+	cfg    entities.OIDCConfig
+	client *http.Client
+
+	mu       sync.Mutex
+	oauth    *oauth2.Config
+	issuer   string
+	keys     *keySet
+	verifier *jwt.Parser
+}
+
+func NewProvider(cfg entities.OIDCConfig, client *http.Client) *Provider {
+	if cfg.Issuer == "" && cfg.Name == "google" {
+		cfg.Issuer = GoogleIssuer
+	}
+
+	return &Provider{name: cfg.Name, cfg: cfg, client: client}
+}
+
+func (p *Provider) Name() string { return p.name }
+
+// discover fetches the issuer's endpoints once.
+func (p *Provider) discover(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.oauth != nil {
+		return nil
+	}
+
+	var doc struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		JWKSURI               string `json:"jwks_uri"`
+	}
+
+	issuer := strings.TrimSuffix(p.cfg.Issuer, "/")
+
+	err := getJSON(ctx, p.client, issuer+"/.well-known/openid-configuration", &doc)
+	if err != nil {
+		return fmt.Errorf("oidc %s: discovery: %w", p.name, err)
+	}
+
+	if strings.TrimSuffix(doc.Issuer, "/") != issuer {
+		return fmt.Errorf("oidc %s: discovery issuer %q does not match %q", p.name, doc.Issuer, p.cfg.Issuer)
+	}
+
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return fmt.Errorf("oidc %s: discovery document is missing endpoints", p.name)
+	}
+
+	p.issuer = doc.Issuer
+	p.keys = &keySet{url: doc.JWKSURI, client: p.client}
+	p.verifier = jwt.NewParser(
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384"}),
+		jwt.WithIssuer(doc.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	p.oauth = &oauth2.Config{
+		ClientID:     p.cfg.ClientID,
+		ClientSecret: p.cfg.ClientSecret,
+		RedirectURL:  p.cfg.RedirectURL,
+		Endpoint:     oauth2.Endpoint{AuthURL: doc.AuthorizationEndpoint, TokenURL: doc.TokenEndpoint},
+		Scopes:       append([]string{"openid", "email", "profile"}, p.cfg.Scopes...),
+	}
+
+	return nil
+}
+
+// AuthURL is where to send the user to log in. state and nonce are checked
+// on the way back; verifier is the PKCE secret for Exchange.
+func (p *Provider) AuthURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return p.oauth.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier), oauth2.SetAuthURLParam("nonce", nonce)), nil
+}
+
+// Exchange trades the code from the callback for the user's identity,
+// verifying the ID token's signature, issuer, audience, expiry and nonce.
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error) {
+	err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := p.oauth.Exchange(context.WithValue(ctx, oauth2.HTTPClient, p.client), code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("oidc %s: exchanging code: %w", p.name, err)
+	}
+
+	raw, ok := token.Extra("id_token").(string)
+	if !ok || raw == "" {
+		return nil, fmt.Errorf("oidc %s: no id_token in token response", p.name)
+	}
+
+	return p.verify(ctx, raw, nonce)
+}
+
+type idClaims struct {
+	Nonce         string `json:"nonce"`
+	Email         string `json:"email"`
+	EmailVerified any    `json:"email_verified"`
+	Name          string `json:"name"`
+	jwt.RegisteredClaims
+}
+
+func (p *Provider) verify(ctx context.Context, raw, nonce string) (*Identity, error) {
+	var claims idClaims
+
+	_, err := p.verifier.ParseWithClaims(raw, &claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.keys.key(ctx, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("oidc %s: invalid id_token: %w", p.name, err)
+	}
+
+	if claims.Nonce != nonce {
+		return nil, fmt.Errorf("oidc %s: id_token nonce does not match", p.name)
+	}
+
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("oidc %s: id_token has no subject", p.name)
+	}
+
+	// A token for several audiences must have been issued to us.
+	if len(claims.Audience) > 1 && !slices.Contains(claims.Audience, p.cfg.ClientID) {
+		return nil, fmt.Errorf("oidc %s: id_token not issued to this client", p.name)
+	}
+
+	return &Identity{
+		Provider:      p.name,
+		Subject:       claims.Subject,
+		Email:         strings.ToLower(claims.Email),
+		EmailVerified: claims.EmailVerified == true || claims.EmailVerified == "true",
+		Name:          claims.Name,
+	}, nil
+}
@@ -0,0 +1,107 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/oidc/oidctest"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func newTestProvider(t *testing.T) (*Provider, *oidctest.Issuer) {
+	iss := oidctest.NewIssuer(t, "client-1")
+	p := NewProvider(entities.OIDCConfig{
+		Name:         "test",
+		Issuer:       iss.URL,
+		ClientID:     "client-1",
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost/callback",
+	}, &http.Client{Timeout: 5 * time.Second})
+
+	return p, iss
+}
+
+func TestProvider_Login(t *testing.T) {
+	p, iss := newTestProvider(t)
+	ctx := context.Background()
+	verifier := oauth2.GenerateVerifier()
+
+	authURL, err := p.AuthURL(ctx, "state-1", "nonce-1", verifier)
+	assert.NoError(t, err)
+
+	code, state := iss.Authorize(t, authURL, oidctest.User{Subject: "sub-1", Email: "Guest@Gmail.com", EmailVerified: true, Name: "Guest"})
+	assert.Equal(t, "state-1", state)
+
+	identity, err := p.Exchange(ctx, code, verifier, "nonce-1")
+	assert.NoError(t, err)
+	assert.Equal(t, &Identity{Provider: "test", Subject: "sub-1", Email: "guest@gmail.com", EmailVerified: true, Name: "Guest"}, identity)
+}
+
+func TestProvider_Exchange_WrongVerifier(t *testing.T) {
+	p, iss := newTestProvider(t)
+	ctx := context.Background()
+
+	authURL, err := p.AuthURL(ctx, "state-1", "nonce-1", oauth2.GenerateVerifier())
+	assert.NoError(t, err)
+
+	code, _ := iss.Authorize(t, authURL, oidctest.User{Subject: "sub-1"})
+
+	_, err = p.Exchange(ctx, code, oauth2.GenerateVerifier(), "nonce-1")
+	assert.ErrorContains(t, err, "exchanging code")
+}
+
+func TestProvider_Verify(t *testing.T) {
+	p, iss := newTestProvider(t)
+	assert.NoError(t, p.discover(context.Background()))
+
+	user := oidctest.User{Subject: "sub-1", Email: "guest@gmail.com", EmailVerified: true}
+
+	tests := []struct {
+		name   string
+		token  string
+		nonce  string
+		errMsg string
+	}{
+		{"valid", iss.IDToken(user, "n", nil), "n", ""},
+		{"wrong nonce", iss.IDToken(user, "n", nil), "other", "nonce does not match"},
+		{"wrong audience", iss.IDToken(user, "n", jwt.MapClaims{"aud": "client-2"}), "n", "invalid id_token"},
+		{"wrong issuer", iss.IDToken(user, "n", jwt.MapClaims{"iss": "https://evil.example"}), "n", "invalid id_token"},
+		{"expired", iss.IDToken(user, "n", jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}), "n", "invalid id_token"},
+		{"unknown key", func() string {
+			tok := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "x"})
+			s, _ := tok.SignedString([]byte("k"))
+			return s
+		}(), "", "invalid id_token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, err := p.verify(context.Background(), tt.token, tt.nonce)
+			if tt.errMsg != "" {
+				assert.ErrorContains(t, err, tt.errMsg)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, "sub-1", identity.Subject)
+		})
+	}
+}
+
+func TestProvider_DiscoveryIssuerMismatch(t *testing.T) {
+	iss := oidctest.NewIssuer(t, "client-1")
+	p := NewProvider(entities.OIDCConfig{Name: "test", Issuer: iss.URL + "/tenant", ClientID: "client-1"}, http.DefaultClient)
+
+	_, err := p.AuthURL(context.Background(), "s", "n", "v")
+	assert.Error(t, err)
+}
+
+func TestNewProvider_GoogleDefaults(t *testing.T) {
+	p := NewProvider(entities.OIDCConfig{Name: "google"}, http.DefaultClient)
+	assert.Equal(t, GoogleIssuer, p.cfg.Issuer)
+}
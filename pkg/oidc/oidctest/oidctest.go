@@ -0,0 +1,163 @@
+// Package oidctest runs a fake OpenID Connect issuer for tests. It serves
+// discovery, a JWKS and a token endpoint that checks PKCE and signs ID
+// tokens for whoever the test says logged in.
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+)
+
+// User is who logs in at the fake issuer.
+type User struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Issuer is a running fake issuer.
+type Issuer struct {
+	*httptest.Server
+	ClientID string
+
+	key *rsa.PrivateKey
+	kid string
+
+	mu    sync.Mutex
+	codes map[string]grant
+}
+
+type grant struct {
+	user      User
+	challenge string
+	nonce     string
+}
+
+func NewIssuer(t *testing.T, clientID string) *Issuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	iss := &Issuer{ClientID: clientID, key: key, kid: "test-key", codes: map[string]grant{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", iss.discovery)
+	mux.HandleFunc("/keys", iss.jwks)
+	mux.HandleFunc("/token", iss.token)
+
+	iss.Server = httptest.NewServer(mux)
+	t.Cleanup(iss.Close)
+
+	return iss
+}
+
+// Authorize stands in for the user logging in at authURL: it returns the
+// code and state the provider would redirect back with.
+func (iss *Issuer) Authorize(t *testing.T, authURL string, user User) (code, state string) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("client_id") != iss.ClientID {
+		t.Fatalf("oidctest: unexpected auth request %s", authURL)
+	}
+
+	code = oauth2.GenerateVerifier()
+
+	iss.mu.Lock()
+	iss.codes[code] = grant{user: user, challenge: q.Get("code_challenge"), nonce: q.Get("nonce")}
+	iss.mu.Unlock()
+
+	return code, q.Get("state")
+}
+
+// IDToken signs an ID token for user, for tests that tamper with claims.
+func (iss *Issuer) IDToken(user User, nonce string, claims jwt.MapClaims) string {
+	c := jwt.MapClaims{
+		"iss":            iss.URL,
+		"aud":            iss.ClientID,
+		"sub":            user.Subject,
+		"email":          user.Email,
+		"email_verified": user.EmailVerified,
+		"name":           user.Name,
+		"nonce":          nonce,
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range claims {
+		c[k] = v
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, c)
+	token.Header["kid"] = iss.kid
+
+	signed, _ := token.SignedString(iss.key)
+	return signed
+}
+
+func (iss *Issuer) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]string{
+		"issuer":                 iss.URL,
+		"authorization_endpoint": iss.URL + "/authorize",
+		"token_endpoint":         iss.URL + "/token",
+		"jwks_uri":               iss.URL + "/keys",
+	})
+}
+
+func (iss *Issuer) jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]any{"keys": []map[string]string{{
+		"kid": iss.kid,
+		"kty": "RSA",
+		"use": "sig",
+		"alg": "RS256",
+		"n":   base64.RawURLEncoding.EncodeToString(iss.key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(iss.key.E)).Bytes()),
+	}}})
+}
+
+func (iss *Issuer) token(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	iss.mu.Lock()
+	g, ok := iss.codes[r.PostForm.Get("code")]
+	delete(iss.codes, r.PostForm.Get("code"))
+	iss.mu.Unlock()
+
+	if !ok || oauth2.S256ChallengeFromVerifier(r.PostForm.Get("code_verifier")) != g.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	writeJSON(w, map[string]any{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     iss.IDToken(g.user, g.nonce, nil),
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -3,15 +3,16 @@ package payments
 import (
 	"errors"
 	"fmt"
+	"log/slog"
+	"strings"
 
-	"github.com/bicosteve/booking-system/entities"
-	"github.com/bicosteve/booking-system/pkg/utils"
+	"github.com/bicosteve/booking-system/pkg/money"
 	"github.com/stripe/stripe-go/v72"
 	"github.com/stripe/stripe-go/v72/paymentintent"
 )
 
 type PaymentClient interface {
-	CreatePayment(amount float64, userId, orderId int) (*stripe.PaymentIntent, error)
+	CreatePayment(amount money.Money, userId, orderId int) (*stripe.PaymentIntent, error)
 	GetPaymentStatus(paymentId string) (*stripe.PaymentIntent, error)
 }
 
@@ -29,16 +30,14 @@ func NewPaymentClient(stripeSecretKey, successURL, cancelURL string) PaymentClie
 	}
 }
 
-func (p payment) CreatePayment(amount float64, userId int, orderId int) (*stripe.PaymentIntent, error) {
+func (p payment) CreatePayment(amount money.Money, userId int, orderId int) (*stripe.PaymentIntent, error) {
 
 	stripe.Key = p.stripeSecretKey
-	amountInCents := amount * 100
-	_ = amountInCents
 
 	// Customize the checkout page
 	params := &stripe.PaymentIntentParams{
-		Amount:             stripe.Int64(int64(amountInCents)),
-		Currency:           stripe.String(string(stripe.CurrencyKES)),
+		Amount:             stripe.Int64(amount.Amount),
+		Currency:           stripe.String(strings.ToLower(amount.Currency)),
 		PaymentMethodTypes: stripe.StringSlice([]string{"card"}),
 	}
 
@@ -47,8 +46,8 @@ func (p payment) CreatePayment(amount float64, userId int, orderId int) (*stripe
 		LineItems: []*stripe.CheckoutSessionLineItemParams{
 			{
 				PriceData: &stripe.CheckoutSessionLineItemPriceDataParams{
-					UnitAmount: stripe.Int64(int64(amountInCents)),
-					Currency:   stripe.String("kes"),
+					UnitAmount: stripe.Int64(amount.Amount),
+					Currency:   stripe.String(strings.ToLower(amount.Currency)),
 					ProductData: &stripe.CheckoutSessionLineItemPriceDataProductDataParams{
 						Name: stripe.String("Room Booking"),
 					},
@@ -66,7 +65,7 @@ func (p payment) CreatePayment(amount float64, userId int, orderId int) (*stripe
 
 	pi, err := paymentintent.New(params)
 	if err != nil {
-		utils.LogError(err.Error(), entities.ErrorLog)
+		slog.Error("creating stripe payment intent failed", "order_id", orderId, "error", err)
 		return nil, errors.New("payment create intent failed")
 	}
 
@@ -78,7 +77,7 @@ func (p payment) GetPaymentStatus(paymentId string) (*stripe.PaymentIntent, erro
 	params := &stripe.PaymentIntentParams{}
 	result, err := paymentintent.Get(paymentId, params)
 	if err != nil {
-		utils.LogError(err.Error(), entities.ErrorLog)
+		slog.Error("getting stripe payment intent failed", "payment_id", paymentId, "error", err)
 		return nil, errors.New("payment get paymentintent failed")
 	}
 	return result, nil
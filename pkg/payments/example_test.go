@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"testing"
 
+	"github.com/bicosteve/booking-system/pkg/money"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -28,7 +29,7 @@ func TestPaymentClient_CreatePayment(t *testing.T) {
 		defer cleanup()
 
 		client := NewPaymentClient("sk_test", "s", "c")
-		pi, err := client.CreatePayment(50, 1, 2)
+		pi, err := client.CreatePayment(money.New(5000, "KES"), 1, 2)
 		assert.NoError(t, err)
 		assert.Equal(t, "pi_abc", pi.ID)
 	})
@@ -42,7 +43,7 @@ func TestPaymentClient_CreatePayment(t *testing.T) {
 		defer cleanup()
 
 		client := NewPaymentClient("sk_test", "s", "c")
-		pi, err := client.CreatePayment(50, 1, 2)
+		pi, err := client.CreatePayment(money.New(5000, "KES"), 1, 2)
 		assert.Error(t, err)
 		assert.Nil(t, pi)
 		assert.EqualError(t, err, "payment create intent failed")
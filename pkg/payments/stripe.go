@@ -1,44 +1,97 @@
 package payments
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"log/slog"
+	"strings"
 
 	"github.com/bicosteve/booking-system/entities"
-	"github.com/bicosteve/booking-system/pkg/utils"
+	"github.com/bicosteve/booking-system/pkg/tracing"
 	"github.com/stripe/stripe-go/v72"
 	"github.com/stripe/stripe-go/v72/paymentintent"
+	"github.com/stripe/stripe-go/v72/refund"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func CreateStripePayment(conf entities.StripeConfig, data entities.TRXPayload) (*stripe.PaymentIntent, error) {
+// startSpan starts a client span around a Stripe API call.
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, "stripe."+name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(append(attrs, attribute.String("peer.service", "stripe"))...),
+	)
+}
+
+func CreateStripePayment(ctx context.Context, conf entities.StripeConfig, data entities.TRXPayload) (*stripe.PaymentIntent, error) {
+	ctx, span := startSpan(ctx, "payment_intent.create", attribute.String("order_id", data.OrderID))
+	defer span.End()
+
 	stripe.Key = conf.StripeSecret
-	amountInCents := data.Payment.Amount * 100
 
+	// Stripe takes amounts in the currency's smallest unit, which is what
+	// PaymentBody already carries, and lower-case currency codes.
 	params := &stripe.PaymentIntentParams{
-		Amount:             stripe.Int64(int64(amountInCents)),
-		Currency:           stripe.String(string(stripe.CurrencyKES)),
+		Amount:             stripe.Int64(data.Payment.Amount),
+		Currency:           stripe.String(strings.ToLower(data.Payment.Currency)),
 		PaymentMethodTypes: stripe.StringSlice([]string{"card"}),
 	}
 
 	params.AddMetadata("order_id", fmt.Sprintf("order_%s", data.OrderID))
 	params.AddMetadata("user_id", fmt.Sprintf("user_%d", data.UserID))
+	params.Context = ctx
 
 	pi, err := paymentintent.New(params)
 	if err != nil {
+		tracing.RecordError(span, err)
 		return nil, errors.New("stripe payment create session failed")
 	}
 
 	return pi, nil
 }
 
-func GetPaymentStatus(stripeKey, paymentId string) (*stripe.PaymentIntent, error) {
+func GetPaymentStatus(ctx context.Context, stripeKey, paymentId string) (*stripe.PaymentIntent, error) {
+	ctx, span := startSpan(ctx, "payment_intent.get", attribute.String("payment_id", paymentId))
+	defer span.End()
+
 	stripe.Key = stripeKey
 	params := &stripe.PaymentIntentParams{}
+	params.Context = ctx
 	result, err := paymentintent.Get(paymentId, params)
 	if err != nil {
-		utils.LogError(err.Error(), entities.ErrorLog)
+		slog.ErrorContext(ctx, "getting stripe payment intent failed", "payment_id", paymentId, "error", err)
+		tracing.RecordError(span, err)
 		return nil, errors.New("stripe payment get session failed")
 	}
 
 	return result, nil
 }
+
+// RefundStripePayment refunds the full amount captured on paymentId. It
+// returns ErrAlreadyRefunded when Stripe has refunded the payment before, so
+// a retried refund can go on to finish the bookkeeping.
+func RefundStripePayment(ctx context.Context, stripeKey, paymentId string) (*stripe.Refund, error) {
+	ctx, span := startSpan(ctx, "refund.create", attribute.String("payment_id", paymentId))
+	defer span.End()
+
+	stripe.Key = stripeKey
+	params := &stripe.RefundParams{
+		PaymentIntent: stripe.String(paymentId),
+	}
+	params.Context = ctx
+
+	result, err := refund.New(params)
+	var stripeErr *stripe.Error
+	if errors.As(err, &stripeErr) && stripeErr.Code == stripe.ErrorCodeChargeAlreadyRefunded {
+		slog.WarnContext(ctx, "stripe payment already refunded", "payment_id", paymentId)
+		return nil, entities.ErrAlreadyRefunded
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "stripe refund failed", "payment_id", paymentId, "error", err)
+		tracing.RecordError(span, err)
+		return nil, errors.New("stripe payment refund failed")
+	}
+
+	return result, nil
+}
@@ -1,8 +1,10 @@
 package payments
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 
 	"github.com/bicosteve/booking-system/entities"
@@ -43,13 +45,44 @@ func TestCreateStripePayment_Success(t *testing.T) {
 		Payment: entities.PaymentBody{Amount: 100},
 	}
 
-	pi, err := CreateStripePayment(conf, data)
+	pi, err := CreateStripePayment(context.Background(), conf, data)
 	assert.NoError(t, err)
 	assert.NotNil(t, pi)
 	assert.Equal(t, "pi_123", pi.ID)
 	assert.Equal(t, "pi_123_secret", pi.ClientSecret)
 }
 
+func TestCreateStripePayment_SendsMinorUnits(t *testing.T) {
+	tests := []struct {
+		name     string
+		payment  entities.PaymentBody
+		amount   string
+		currency string
+	}{
+		{name: "two decimal", payment: entities.PaymentBody{Amount: 700000, Currency: "KES"}, amount: "700000", currency: "kes"},
+		{name: "zero decimal", payment: entities.PaymentBody{Amount: 7000, Currency: "JPY"}, amount: "7000", currency: "jpy"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var form url.Values
+			cleanup := withMockStripeBackend(t, func(w http.ResponseWriter, r *http.Request) {
+				_ = r.ParseForm()
+				form = r.PostForm
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{"id":"pi_123","object":"payment_intent"}`))
+			})
+			defer cleanup()
+
+			_, err := CreateStripePayment(context.Background(), entities.StripeConfig{StripeSecret: "sk_test_dummy"}, entities.TRXPayload{Payment: tt.payment})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.amount, form.Get("amount"))
+			assert.Equal(t, tt.currency, form.Get("currency"))
+		})
+	}
+}
+
 func TestCreateStripePayment_Error(t *testing.T) {
 	cleanup := withMockStripeBackend(t, func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -61,7 +94,7 @@ func TestCreateStripePayment_Error(t *testing.T) {
 	conf := entities.StripeConfig{StripeSecret: "sk_test_dummy"}
 	data := entities.TRXPayload{Payment: entities.PaymentBody{Amount: -1}}
 
-	pi, err := CreateStripePayment(conf, data)
+	pi, err := CreateStripePayment(context.Background(), conf, data)
 	assert.Error(t, err)
 	assert.Nil(t, pi)
 	assert.EqualError(t, err, "stripe payment create session failed")
@@ -75,7 +108,7 @@ func TestGetPaymentStatus_Success(t *testing.T) {
 	})
 	defer cleanup()
 
-	pi, err := GetPaymentStatus("sk_test_dummy", "pi_999")
+	pi, err := GetPaymentStatus(context.Background(), "sk_test_dummy", "pi_999")
 	assert.NoError(t, err)
 	assert.NotNil(t, pi)
 	assert.Equal(t, "pi_999", pi.ID)
@@ -90,8 +123,50 @@ func TestGetPaymentStatus_Error(t *testing.T) {
 	})
 	defer cleanup()
 
-	pi, err := GetPaymentStatus("sk_test_dummy", "pi_missing")
+	pi, err := GetPaymentStatus(context.Background(), "sk_test_dummy", "pi_missing")
 	assert.Error(t, err)
 	assert.Nil(t, pi)
 	assert.EqualError(t, err, "stripe payment get session failed")
 }
+
+func TestRefundStripePayment_Success(t *testing.T) {
+	cleanup := withMockStripeBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/refunds", r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"id":"re_1","object":"refund","status":"succeeded","amount":1000}`))
+	})
+	defer cleanup()
+
+	re, err := RefundStripePayment(context.Background(), "sk_test_dummy", "pi_999")
+	assert.NoError(t, err)
+	assert.Equal(t, "re_1", re.ID)
+	assert.Equal(t, int64(1000), re.Amount)
+}
+
+func TestRefundStripePayment_Error(t *testing.T) {
+	cleanup := withMockStripeBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"message":"charge already refunded","type":"invalid_request_error"}}`))
+	})
+	defer cleanup()
+
+	re, err := RefundStripePayment(context.Background(), "sk_test_dummy", "pi_999")
+	assert.Error(t, err)
+	assert.Nil(t, re)
+	assert.EqualError(t, err, "stripe payment refund failed")
+}
+
+func TestRefundStripePayment_AlreadyRefunded(t *testing.T) {
+	cleanup := withMockStripeBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"code":"charge_already_refunded","message":"Charge has already been refunded.","type":"invalid_request_error"}}`))
+	})
+	defer cleanup()
+
+	re, err := RefundStripePayment(context.Background(), "sk_test_dummy", "pi_999")
+	assert.Nil(t, re)
+	assert.ErrorIs(t, err, entities.ErrAlreadyRefunded)
+}
@@ -0,0 +1,162 @@
+package phone
+
+import (
+	"errors"
+	"strings"
+)
+
+// DefaultRegion is used for national numbers when no region is configured.
+const DefaultRegion = "KE"
+
+var ErrInvalidNumber = errors.New("phone number must be a valid international (+254712345678) or national number")
+var ErrUnknownRegion = errors.New("phone region must be a supported ISO 3166 country code")
+
+// region describes how a country writes its numbers: the calling code, the
+// trunk prefix dialled before national numbers and how many digits follow
+// the calling code.
+type region struct {
+	code     string
+	trunk    string
+	min, max int
+}
+
+// regions lists the countries whose national numbers can be parsed. Numbers
+// written with a + or 00 are accepted for any calling code.
+var regions = map[string]region{
+	"KE": {"254", "0", 9, 9},
+	"UG": {"256", "0", 9, 9},
+	"TZ": {"255", "0", 9, 9},
+	"RW": {"250", "0", 9, 9},
+	"BI": {"257", "", 8, 8},
+	"ET": {"251", "0", 9, 9},
+	"SS": {"211", "0", 9, 9},
+	"SO": {"252", "0", 7, 9},
+	"NG": {"234", "0", 8, 10},
+	"GH": {"233", "0", 9, 9},
+	"ZA": {"27", "0", 9, 9},
+	"EG": {"20", "0", 8, 10},
+	"AE": {"971", "0", 8, 9},
+	"IN": {"91", "0", 10, 10},
+	"CN": {"86", "0", 10, 11},
+	"GB": {"44", "0", 9, 10},
+	"DE": {"49", "0", 6, 13},
+	"FR": {"33", "0", 9, 9},
+	"NL": {"31", "0", 9, 9},
+	"ES": {"34", "", 9, 9},
+	"AU": {"61", "0", 9, 9},
+	"US": {"1", "1", 10, 10},
+	"CA": {"1", "1", 10, 10},
+}
+
+// ValidRegion reports whether national numbers from region can be parsed.
+func ValidRegion(code string) bool {
+	_, ok := regions[strings.ToUpper(code)]
+	return ok
+}
+
+// Normalize returns number in E.164 form, e.g. +254712345678. Numbers
+// starting with + or 00 are read as international; anything else is read
+// as a national number of defaultRegion, falling back to DefaultRegion when
+// it is empty. Spaces, dashes, dots and brackets are ignored.
+func Normalize(number, defaultRegion string) (string, error) {
+	number = strings.TrimSpace(number)
+
+	international := false
+	switch {
+	case strings.HasPrefix(number, "+"):
+		number, international = number[1:], true
+	case strings.HasPrefix(number, "00"):
+		number, international = number[2:], true
+	}
+
+	digits, ok := stripSeparators(number)
+	if !ok || digits == "" {
+		return "", ErrInvalidNumber
+	}
+
+	if international {
+		return normalizeInternational(digits)
+	}
+
+	if defaultRegion == "" {
+		defaultRegion = DefaultRegion
+	}
+
+	r, ok := regions[strings.ToUpper(defaultRegion)]
+	if !ok {
+		return "", ErrUnknownRegion
+	}
+
+	national := strings.TrimPrefix(digits, r.trunk)
+	if !r.fits(national) {
+		return "", ErrInvalidNumber
+	}
+
+	return "+" + r.code + national, nil
+}
+
+// IsE164 reports whether number is already in the form Normalize returns.
+func IsE164(number string) bool {
+	if !strings.HasPrefix(number, "+") {
+		return false
+	}
+
+	n, err := normalizeInternational(number[1:])
+	return err == nil && n == number
+}
+
+func normalizeInternational(digits string) (string, error) {
+	// E.164 caps numbers at 15 digits, and no calling code starts with 0.
+	if len(digits) < 8 || len(digits) > 15 || digits[0] == '0' {
+		return "", ErrInvalidNumber
+	}
+
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return "", ErrInvalidNumber
+		}
+	}
+
+	// Calling codes are prefix free, so at most one known code matches.
+	for _, r := range regions {
+		national, ok := strings.CutPrefix(digits, r.code)
+		if !ok {
+			continue
+		}
+
+		// "+254 0712 345678" is a common way to mix both forms.
+		if r.trunk == "0" && strings.HasPrefix(national, "0") {
+			national = national[1:]
+		}
+
+		if !r.fits(national) {
+			return "", ErrInvalidNumber
+		}
+
+		return "+" + r.code + national, nil
+	}
+
+	return "+" + digits, nil
+}
+
+func (r region) fits(national string) bool {
+	return len(national) >= r.min && len(national) <= r.max && national[0] != '0'
+}
+
+// stripSeparators drops the characters people use to group digits and
+// reports whether only digits were left.
+func stripSeparators(s string) (string, bool) {
+	var b strings.Builder
+
+	for _, c := range s {
+		switch {
+		case c >= '0' && c <= '9':
+			b.WriteRune(c)
+		case c == ' ', c == '-', c == '.', c == '(', c == ')':
+		default:
+			return "", false
+		}
+	}
+
+	return b.String(), true
+}
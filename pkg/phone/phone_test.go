@@ -0,0 +1,60 @@
+package phone
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name    string
+		number  string
+		region  string
+		want    string
+		wantErr error
+	}{
+		{name: "kenyan national", number: "0712345678", want: "+254712345678"},
+		{name: "kenyan without trunk", number: "712 345 678", region: "KE", want: "+254712345678"},
+		{name: "international", number: "+254 712-345-678", want: "+254712345678"},
+		{name: "double zero", number: "00254712345678", want: "+254712345678"},
+		{name: "trunk after code", number: "+254 (0)712 345678", want: "+254712345678"},
+		{name: "other region", number: "0772 123456", region: "ug", want: "+256772123456"},
+		{name: "us national", number: "(202) 555-0100", region: "US", want: "+12025550100"},
+		{name: "foreign guest", number: "+44 7911 123456", region: "KE", want: "+447911123456"},
+		{name: "unlisted calling code", number: "+81 90 1234 5678", want: "+819012345678"},
+		{name: "too short", number: "0712345", wantErr: ErrInvalidNumber},
+		{name: "too long", number: "+2547123456789", wantErr: ErrInvalidNumber},
+		{name: "over fifteen digits", number: "+8190123456789012", wantErr: ErrInvalidNumber},
+		{name: "letters", number: "07123abc78", wantErr: ErrInvalidNumber},
+		{name: "empty", number: " ", wantErr: ErrInvalidNumber},
+		{name: "unknown region", number: "0712345678", region: "XX", wantErr: ErrUnknownRegion},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Normalize(tt.number, tt.region)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.True(t, IsE164(got))
+		})
+	}
+}
+
+func TestIsE164(t *testing.T) {
+	assert.True(t, IsE164("+254712345678"))
+	assert.False(t, IsE164("0712345678"))
+	assert.False(t, IsE164("+254 712 345 678"))
+	assert.False(t, IsE164("+2540712345678"))
+	assert.False(t, IsE164("d7"))
+}
+
+func TestValidRegion(t *testing.T) {
+	assert.True(t, ValidRegion("KE"))
+	assert.True(t, ValidRegion("gb"))
+	assert.False(t, ValidRegion("XX"))
+}
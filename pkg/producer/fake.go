@@ -0,0 +1,59 @@
+package producer
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Fake records published messages instead of sending them. Set Err to make
+// every publish fail.
+type Fake struct {
+	mu       sync.Mutex
+	messages []Message
+	closed   bool
+	Err      error
+}
+
+// NewFake returns an empty Fake.
+func NewFake() *Fake {
+	return &Fake{}
+}
+
+// Publish implements Producer.
+func (f *Fake) Publish(_ context.Context, msg Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return ErrClosed
+	}
+	if f.Err != nil {
+		return f.Err
+	}
+	f.messages = append(f.messages, msg)
+
+	return nil
+}
+
+// PublishSync implements Producer.
+func (f *Fake) PublishSync(ctx context.Context, msg Message, _ time.Duration) error {
+	return f.Publish(ctx, msg)
+}
+
+// Close implements Producer.
+func (f *Fake) Close(time.Duration) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.closed = true
+	return 0
+}
+
+// Messages returns what has been published so far.
+func (f *Fake) Messages() []Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]Message(nil), f.messages...)
+}
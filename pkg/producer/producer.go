@@ -0,0 +1,232 @@
+// Package producer publishes to Kafka through one long-lived producer shared
+// by the whole app. Publish hands messages to librdkafka and returns; their
+// delivery reports are counted in the background. PublishSync waits for the
+// report of a single message.
+package producer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/bicosteve/booking-system/pkg/metrics"
+	"github.com/bicosteve/booking-system/pkg/tracing"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	// ErrClosed is returned once Close has been called.
+	ErrClosed = errors.New("producer: closed")
+	// ErrTimeout is returned by PublishSync when no delivery report arrived in time.
+	ErrTimeout = errors.New("producer: timed out waiting for delivery report")
+)
+
+// Message is a record to publish. An empty Key falls back to the key
+// configured for Topic.
+type Message struct {
+	Topic   string
+	Key     string
+	Value   []byte
+	Headers map[string]string
+}
+
+// NewJSONMessage marshals v into the value of a message for topic.
+func NewJSONMessage(topic, key string, v any) (Message, error) {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		Topic:   topic,
+		Key:     key,
+		Value:   value,
+		Headers: map[string]string{"content-type": "application/json"},
+	}, nil
+}
+
+// Producer is what handlers depend on; Kafka is the real implementation and
+// Fake the one used in tests.
+type Producer interface {
+	// Publish queues msg and returns without waiting for the broker.
+	Publish(ctx context.Context, msg Message) error
+	// PublishSync publishes msg and waits up to timeout for its delivery report.
+	PublishSync(ctx context.Context, msg Message, timeout time.Duration) error
+	// Close flushes queued messages for up to timeout and releases the
+	// producer. It returns the number of messages left undelivered.
+	Close(timeout time.Duration) int
+}
+
+// client is the subset of *kafka.Producer that Kafka uses.
+type client interface {
+	Produce(msg *kafka.Message, deliveryChan chan kafka.Event) error
+	Events() chan kafka.Event
+	Flush(timeoutMs int) int
+	Close()
+}
+
+// Kafka publishes through a shared *kafka.Producer.
+type Kafka struct {
+	p client
+	// keys maps a topic to the key used when a message has none.
+	keys       map[string]string
+	defaultKey string
+
+	mu     sync.RWMutex
+	closed bool
+	done   chan struct{}
+}
+
+// New wraps p and starts reading its delivery reports. keys holds per-topic
+// keys; defaultKey is used for topics missing from it.
+func New(p *kafka.Producer, defaultKey string, keys map[string]string) *Kafka {
+	return newKafka(p, defaultKey, keys)
+}
+
+func newKafka(p client, defaultKey string, keys map[string]string) *Kafka {
+	k := &Kafka{
+		p:          p,
+		keys:       keys,
+		defaultKey: defaultKey,
+		done:       make(chan struct{}),
+	}
+	go k.handleEvents()
+
+	return k
+}
+
+// Publish implements Producer.
+func (k *Kafka) Publish(ctx context.Context, msg Message) error {
+	return k.produce(ctx, msg, nil)
+}
+
+// PublishSync implements Producer.
+func (k *Kafka) PublishSync(ctx context.Context, msg Message, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	delivery := make(chan kafka.Event, 1)
+	err := k.produce(ctx, msg, delivery)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case e := <-delivery:
+		m, ok := e.(*kafka.Message)
+		if !ok {
+			return errors.New(e.String())
+		}
+		k.report(m)
+		return m.TopicPartition.Error
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return ErrTimeout
+		}
+		return ctx.Err()
+	}
+}
+
+func (k *Kafka) produce(ctx context.Context, msg Message, delivery chan kafka.Event) error {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if k.closed {
+		return ErrClosed
+	}
+
+	ctx, span := tracing.Tracer().Start(ctx, msg.Topic+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.String("messaging.system", "kafka"), attribute.String("messaging.destination.name", msg.Topic)),
+	)
+	defer span.End()
+
+	topic := msg.Topic
+	km := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+		Key:            []byte(k.keyFor(msg)),
+		Value:          msg.Value,
+		Opaque:         time.Now(),
+	}
+	for name, value := range msg.Headers {
+		km.Headers = append(km.Headers, kafka.Header{Key: name, Value: []byte(value)})
+	}
+	tracing.InjectKafka(ctx, km)
+
+	err := k.p.Produce(km, delivery)
+	if err != nil {
+		tracing.RecordError(span, err)
+		metrics.KafkaDeliveries.WithLabelValues(msg.Topic, "failure").Inc()
+		slog.ErrorContext(ctx, "queueing kafka message failed", "topic", msg.Topic, "error", err)
+		return err
+	}
+
+	return nil
+}
+
+func (k *Kafka) keyFor(msg Message) string {
+	if msg.Key != "" {
+		return msg.Key
+	}
+	if key, ok := k.keys[msg.Topic]; ok {
+		return key
+	}
+	return k.defaultKey
+}
+
+// handleEvents records the delivery reports of messages sent with Publish
+// until the producer is closed.
+func (k *Kafka) handleEvents() {
+	defer close(k.done)
+
+	for e := range k.p.Events() {
+		switch ev := e.(type) {
+		case *kafka.Message:
+			k.report(ev)
+		case kafka.Error:
+			slog.Error("kafka producer error", "error", ev, "fatal", ev.IsFatal())
+		}
+	}
+}
+
+// report counts a delivery report and observes how long it took.
+func (k *Kafka) report(m *kafka.Message) {
+	topic := ""
+	if m.TopicPartition.Topic != nil {
+		topic = *m.TopicPartition.Topic
+	}
+	if sent, ok := m.Opaque.(time.Time); ok {
+		metrics.KafkaDeliveryDuration.WithLabelValues(topic).Observe(time.Since(sent).Seconds())
+	}
+
+	if m.TopicPartition.Error != nil {
+		metrics.KafkaDeliveries.WithLabelValues(topic, "failure").Inc()
+		slog.Error("kafka message not delivered", "topic", topic, "key", string(m.Key), "error", m.TopicPartition.Error)
+		return
+	}
+
+	metrics.KafkaDeliveries.WithLabelValues(topic, "success").Inc()
+	slog.Debug("kafka message delivered", "topic", topic, "partition", m.TopicPartition.Partition, "offset", m.TopicPartition.Offset.String(), "key", string(m.Key))
+}
+
+// Close implements Producer.
+func (k *Kafka) Close(timeout time.Duration) int {
+	k.mu.Lock()
+	if k.closed {
+		k.mu.Unlock()
+		return 0
+	}
+	k.closed = true
+	k.mu.Unlock()
+
+	remaining := k.p.Flush(int(timeout.Milliseconds()))
+	k.p.Close()
+	<-k.done
+
+	return remaining
+}
@@ -0,0 +1,128 @@
+package producer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClient stands in for *kafka.Producer. Every produced message gets a
+// delivery report unless hold is set; fail makes the reports carry an error.
+type fakeClient struct {
+	mu       sync.Mutex
+	produced []*kafka.Message
+	events   chan kafka.Event
+	fail     error
+	hold     bool
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{events: make(chan kafka.Event, 10)}
+}
+
+func (c *fakeClient) Produce(msg *kafka.Message, delivery chan kafka.Event) error {
+	c.mu.Lock()
+	c.produced = append(c.produced, msg)
+	fail, hold := c.fail, c.hold
+	c.mu.Unlock()
+
+	if hold {
+		return nil
+	}
+
+	report := *msg
+	report.TopicPartition.Error = fail
+	if delivery != nil {
+		delivery <- &report
+	} else {
+		c.events <- &report
+	}
+	return nil
+}
+
+func (c *fakeClient) Events() chan kafka.Event { return c.events }
+func (c *fakeClient) Flush(int) int            { return 0 }
+func (c *fakeClient) Close()                   { close(c.events) }
+
+func (c *fakeClient) last() *kafka.Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.produced[len(c.produced)-1]
+}
+
+func header(m *kafka.Message, key string) string {
+	for _, h := range m.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func TestPublish_UsesTopicKeysAndHeaders(t *testing.T) {
+	client := newFakeClient()
+	k := newKafka(client, "default", map[string]string{"payments": "payment-key"})
+	defer k.Close(time.Second)
+
+	msg, err := NewJSONMessage("payments", "", map[string]int{"amount": 100})
+	assert.NoError(t, err)
+	assert.NoError(t, k.Publish(context.Background(), msg))
+
+	sent := client.last()
+	assert.Equal(t, "payments", *sent.TopicPartition.Topic)
+	assert.Equal(t, "payment-key", string(sent.Key))
+	assert.Equal(t, `{"amount":100}`, string(sent.Value))
+	assert.Equal(t, "application/json", header(sent, "content-type"))
+
+	assert.NoError(t, k.Publish(context.Background(), Message{Topic: "other"}))
+	assert.Equal(t, "default", string(client.last().Key))
+
+	assert.NoError(t, k.Publish(context.Background(), Message{Topic: "payments", Key: "booking-1"}))
+	assert.Equal(t, "booking-1", string(client.last().Key))
+}
+
+func TestPublishSync_ReturnsDeliveryError(t *testing.T) {
+	client := newFakeClient()
+	k := newKafka(client, "", nil)
+	defer k.Close(time.Second)
+
+	assert.NoError(t, k.PublishSync(context.Background(), Message{Topic: "payments"}, time.Second))
+
+	client.fail = errors.New("broker unavailable")
+	err := k.PublishSync(context.Background(), Message{Topic: "payments"}, time.Second)
+	assert.EqualError(t, err, "broker unavailable")
+}
+
+func TestPublishSync_TimesOut(t *testing.T) {
+	client := newFakeClient()
+	client.hold = true
+	k := newKafka(client, "", nil)
+	defer k.Close(time.Second)
+
+	err := k.PublishSync(context.Background(), Message{Topic: "payments"}, 10*time.Millisecond)
+	assert.ErrorIs(t, err, ErrTimeout)
+}
+
+func TestClose_RejectsLaterPublishes(t *testing.T) {
+	k := newKafka(newFakeClient(), "", nil)
+
+	assert.Equal(t, 0, k.Close(time.Second))
+	assert.ErrorIs(t, k.Publish(context.Background(), Message{Topic: "payments"}), ErrClosed)
+}
+
+func TestFake_RecordsMessages(t *testing.T) {
+	var p Producer = NewFake()
+	fake := p.(*Fake)
+
+	assert.NoError(t, p.Publish(context.Background(), Message{Topic: "payments", Key: "a"}))
+	assert.NoError(t, p.PublishSync(context.Background(), Message{Topic: "payments", Key: "b"}, time.Second))
+	assert.Len(t, fake.Messages(), 2)
+
+	fake.Err = errors.New("down")
+	assert.EqualError(t, p.Publish(context.Background(), Message{Topic: "payments"}), "down")
+}
@@ -0,0 +1,547 @@
+// Package rabbitmq wraps an AMQP connection that heals itself: it reconnects
+// with backoff when the broker drops it, re-declares the queues it knows
+// about, resumes consumers and publishes with broker confirms.
+package rabbitmq
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/bicosteve/booking-system/pkg/metrics"
+	"github.com/bicosteve/booking-system/pkg/tracing"
+	"github.com/google/uuid"
+	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	// ErrClosed is returned once Close has been called.
+	ErrClosed = errors.New("rabbitmq: client closed")
+	// ErrNotConnected is returned while the client is reconnecting.
+	ErrNotConnected = errors.New("rabbitmq: not connected")
+	// ErrNacked is returned when the broker refuses a published message.
+	ErrNacked = errors.New("rabbitmq: message nacked by broker")
+)
+
+// Config tunes a Client. Zero values fall back to the defaults below.
+type Config struct {
+	URL string
+	TLS *tls.Config
+	// PoolSize is the number of publisher channels kept open.
+	PoolSize int
+	// Prefetch caps the unacked deliveries a consumer holds at once.
+	Prefetch int
+	// MinBackoff and MaxBackoff bound the wait between reconnect attempts;
+	// the wait doubles after each failure.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// ConfirmTimeout bounds how long Publish waits for the broker's confirm.
+	ConfirmTimeout time.Duration
+}
+
+func (c Config) withDefaults() Config {
+	if c.PoolSize <= 0 {
+		c.PoolSize = 4
+	}
+	if c.Prefetch <= 0 {
+		c.Prefetch = 10
+	}
+	if c.MinBackoff <= 0 {
+		c.MinBackoff = 500 * time.Millisecond
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = 30 * time.Second
+	}
+	if c.ConfirmTimeout <= 0 {
+		c.ConfirmTimeout = 5 * time.Second
+	}
+	return c
+}
+
+// channel is the subset of *amqp.Channel the client uses.
+type channel interface {
+	Qos(prefetchCount, prefetchSize int, global bool) error
+	QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error)
+	Confirm(noWait bool) error
+	NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation
+	NotifyClose(c chan *amqp.Error) chan *amqp.Error
+	Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+	Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error)
+	Cancel(consumer string, noWait bool) error
+	Close() error
+}
+
+// connection is the subset of *amqp.Connection the client uses.
+type connection interface {
+	channel() (channel, error)
+	NotifyClose(c chan *amqp.Error) chan *amqp.Error
+	Close() error
+}
+
+type amqpConnection struct {
+	*amqp.Connection
+}
+
+func (c amqpConnection) channel() (channel, error) {
+	ch, err := c.Channel()
+	if err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
+
+// dialAMQP opens a real broker connection.
+func dialAMQP(cfg Config) (connection, error) {
+	var conn *amqp.Connection
+	var err error
+	if cfg.TLS != nil {
+		conn, err = amqp.DialTLS(cfg.URL, cfg.TLS)
+	} else {
+		conn, err = amqp.Dial(cfg.URL)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return amqpConnection{conn}, nil
+}
+
+// pubChannel is a publisher channel in confirm mode.
+type pubChannel struct {
+	ch       channel
+	confirms chan amqp.Confirmation
+	closed   chan *amqp.Error
+}
+
+func (p *pubChannel) broken() bool {
+	select {
+	case <-p.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// Client owns one AMQP connection and the channels opened on it.
+type Client struct {
+	cfg  Config
+	dial func(Config) (connection, error)
+
+	mu     sync.RWMutex
+	conn   connection
+	queues []string
+	closed bool
+
+	// pool holds up to PoolSize publisher channels; nil slots are opened on
+	// first use.
+	pool chan *pubChannel
+	done chan struct{}
+}
+
+// New dials the broker and starts watching the connection.
+func New(cfg Config) (*Client, error) {
+	return newClient(cfg, dialAMQP)
+}
+
+func newClient(cfg Config, dial func(Config) (connection, error)) (*Client, error) {
+	cfg = cfg.withDefaults()
+	c := &Client{
+		cfg:  cfg,
+		dial: dial,
+		pool: make(chan *pubChannel, cfg.PoolSize),
+		done: make(chan struct{}),
+	}
+	for i := 0; i < cfg.PoolSize; i++ {
+		c.pool <- nil
+	}
+
+	conn, err := dial(cfg)
+	if err != nil {
+		return nil, err
+	}
+	c.conn = conn
+	go c.watch(conn.NotifyClose(make(chan *amqp.Error, 1)))
+
+	slog.Info("rabbitmq connected")
+
+	return c, nil
+}
+
+// watch waits for the connection behind closed to drop and reconnects,
+// unless Close dropped it. closed is registered before the connection is
+// published so a drop cannot go unnoticed.
+func (c *Client) watch(closed chan *amqp.Error) {
+	err, ok := <-closed
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return
+	}
+	c.conn = nil
+	c.mu.Unlock()
+
+	if ok && err != nil {
+		slog.Warn("rabbitmq connection lost, reconnecting", "error", err)
+	} else {
+		slog.Warn("rabbitmq connection closed, reconnecting")
+	}
+
+	c.reconnect()
+}
+
+// reconnect dials with exponential backoff until it succeeds or the client is
+// closed, then re-declares the known queues.
+func (c *Client) reconnect() {
+	backoff := c.cfg.MinBackoff
+
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-c.done:
+			return
+		case <-time.After(backoff):
+		}
+
+		conn, err := c.dial(c.cfg)
+		var closed chan *amqp.Error
+		if err == nil {
+			closed = conn.NotifyClose(make(chan *amqp.Error, 1))
+			err = c.declareAll(conn)
+			if err != nil {
+				conn.Close()
+			}
+		}
+		if err != nil {
+			slog.Warn("rabbitmq reconnect failed", "attempt", attempt, "retry_in", backoff.String(), "error", err)
+			backoff = min(backoff*2, c.cfg.MaxBackoff)
+			continue
+		}
+
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			conn.Close()
+			return
+		}
+		c.conn = conn
+		c.mu.Unlock()
+
+		metrics.RabbitReconnects.Inc()
+		slog.Info("rabbitmq reconnected", "attempt", attempt)
+
+		go c.watch(closed)
+		return
+	}
+}
+
+// connection returns the live connection, or an error while reconnecting.
+func (c *Client) connection() (connection, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed {
+		return nil, ErrClosed
+	}
+	if c.conn == nil {
+		return nil, ErrNotConnected
+	}
+	return c.conn, nil
+}
+
+// Connected reports whether the client currently holds a connection.
+func (c *Client) Connected() bool {
+	_, err := c.connection()
+	return err == nil
+}
+
+// DeclareQueue declares a durable queue now and again after every reconnect.
+func (c *Client) DeclareQueue(name string) error {
+	conn, err := c.connection()
+	if err != nil {
+		return err
+	}
+
+	err = declareQueues(conn, name)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, q := range c.queues {
+		if q == name {
+			return nil
+		}
+	}
+	c.queues = append(c.queues, name)
+
+	return nil
+}
+
+func (c *Client) declareAll(conn connection) error {
+	c.mu.RLock()
+	queues := append([]string(nil), c.queues...)
+	c.mu.RUnlock()
+
+	return declareQueues(conn, queues...)
+}
+
+func declareQueues(conn connection, names ...string) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	ch, err := conn.channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	for _, name := range names {
+		_, err = ch.QueueDeclare(
+			name,
+			true,  // durable
+			false, // delete when unused
+			false, // exclusive
+			false, // no-wait
+			nil,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Publish sends msg to queue through the default exchange and waits for the
+// broker to confirm it.
+func (c *Client) Publish(ctx context.Context, queue string, msg amqp.Publishing) error {
+	var pc *pubChannel
+	select {
+	case pc = <-c.pool:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	pc, err := c.publish(pc, queue, msg)
+	c.pool <- pc
+
+	return err
+}
+
+// PublishJSON publishes data as a persistent JSON message with a fresh
+// message id, carrying the trace context of ctx in the message headers.
+func (c *Client) PublishJSON(ctx context.Context, queue string, data any) error {
+	ctx, span := tracing.Tracer().Start(ctx, queue+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.String("messaging.system", "rabbitmq"), attribute.String("messaging.destination.name", queue)),
+	)
+	defer span.End()
+
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	messageID := uuid.New().String()
+	err = c.Publish(ctx, queue, amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		MessageId:    messageID,
+		Headers:      tracing.InjectAMQP(ctx, nil),
+		Body:         body,
+	})
+	if err != nil {
+		tracing.RecordError(span, err)
+		return err
+	}
+
+	slog.InfoContext(ctx, "rabbitmq message published", "queue", queue, "message_id", messageID)
+
+	return nil
+}
+
+// publish runs one publish on pc, opening a fresh channel when pc is missing
+// or broken. It returns the channel to put back into the pool.
+func (c *Client) publish(pc *pubChannel, queue string, msg amqp.Publishing) (*pubChannel, error) {
+	if pc == nil || pc.broken() {
+		if pc != nil {
+			pc.ch.Close()
+		}
+
+		var err error
+		pc, err = c.openPublisher()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := pc.ch.Publish(
+		"",    // exchange
+		queue, // routing key, here queue name
+		false, // mandatory
+		false, // immediate
+		msg,
+	)
+	if err != nil {
+		pc.ch.Close()
+		return nil, err
+	}
+
+	select {
+	case confirm, ok := <-pc.confirms:
+		if !ok {
+			return nil, ErrNotConnected
+		}
+		if !confirm.Ack {
+			return pc, ErrNacked
+		}
+		return pc, nil
+	case <-time.After(c.cfg.ConfirmTimeout):
+		// A late confirm would be read by the next publish; drop the channel.
+		pc.ch.Close()
+		return nil, errors.New("rabbitmq: timed out waiting for publish confirm")
+	}
+}
+
+func (c *Client) openPublisher() (*pubChannel, error) {
+	conn, err := c.connection()
+	if err != nil {
+		return nil, err
+	}
+
+	ch, err := conn.channel()
+	if err != nil {
+		return nil, err
+	}
+
+	err = ch.Confirm(false)
+	if err != nil {
+		ch.Close()
+		return nil, err
+	}
+
+	return &pubChannel{
+		ch:       ch,
+		confirms: ch.NotifyPublish(make(chan amqp.Confirmation, 1)),
+		closed:   ch.NotifyClose(make(chan *amqp.Error, 1)),
+	}, nil
+}
+
+// Consume delivers messages from queue to handle one at a time until ctx is
+// cancelled. When the connection drops it waits for the client to reconnect
+// and subscribes again. On cancellation it stops new deliveries, lets the
+// message in hand finish and returns; prefetched messages are requeued by the
+// broker.
+func (c *Client) Consume(ctx context.Context, queue, tag string, handle func(amqp.Delivery)) error {
+	backoff := c.cfg.MinBackoff
+
+	for {
+		ch, deliveries, err := c.subscribe(queue, tag)
+		if errors.Is(err, ErrClosed) {
+			return err
+		}
+		if err != nil {
+			slog.Warn("rabbitmq subscribe failed", "queue", queue, "retry_in", backoff.String(), "error", err)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, c.cfg.MaxBackoff)
+			continue
+		}
+
+		backoff = c.cfg.MinBackoff
+		slog.Info("rabbitmq consumer subscribed", "queue", queue)
+
+		if c.drain(ctx, ch, tag, deliveries, handle) {
+			return nil
+		}
+		slog.Warn("rabbitmq deliveries stopped, resubscribing", "queue", queue)
+	}
+}
+
+func (c *Client) subscribe(queue, tag string) (channel, <-chan amqp.Delivery, error) {
+	conn, err := c.connection()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ch, err := conn.channel()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = ch.Qos(c.cfg.Prefetch, 0, false)
+	if err == nil {
+		_, err = ch.QueueDeclare(queue, true, false, false, false, nil)
+	}
+	if err != nil {
+		ch.Close()
+		return nil, nil, err
+	}
+
+	deliveries, err := ch.Consume(
+		queue,
+		tag,
+		false, // auto-ack
+		false, // exclusive
+		false, // no-local
+		false, // no-wait
+		nil,
+	)
+	if err != nil {
+		ch.Close()
+		return nil, nil, err
+	}
+
+	return ch, deliveries, nil
+}
+
+// drain hands deliveries to handle until ctx is cancelled (returns true) or
+// the delivery channel closes because the connection dropped (returns false).
+func (c *Client) drain(ctx context.Context, ch channel, tag string, deliveries <-chan amqp.Delivery, handle func(amqp.Delivery)) bool {
+	defer ch.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			err := ch.Cancel(tag, false)
+			if err != nil {
+				slog.Error("cancelling rabbitmq consumer failed", "tag", tag, "error", err)
+			}
+			return true
+		case d, ok := <-deliveries:
+			if !ok {
+				return false
+			}
+			handle(d)
+		}
+	}
+}
+
+// Close stops reconnecting and closes the connection and its channels.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	conn := c.conn
+	c.conn = nil
+	close(c.done)
+	c.mu.Unlock()
+
+	if conn == nil {
+		return nil
+	}
+	return conn.Close()
+}
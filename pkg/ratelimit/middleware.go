@@ -0,0 +1,147 @@
+package ratelimit
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/metrics"
+	"github.com/bicosteve/booking-system/pkg/utils"
+)
+
+// maxPeekBody caps how much of a request body is read to find its email.
+const maxPeekBody = 1 << 20
+
+// Policy applies rules to the routes they name.
+type Policy struct {
+	limiter    Limiter
+	rules      map[string][]Rule
+	trustProxy bool
+}
+
+// NewPolicy counts requests with limiter. With trustProxy set the client IP
+// is the last X-Forwarded-For entry instead of the connection's address.
+func NewPolicy(limiter Limiter, rules []Rule, trustProxy bool) *Policy {
+	byRoute := map[string][]Rule{}
+	for _, r := range rules {
+		byRoute[r.Route] = append(byRoute[r.Route], r)
+	}
+
+	return &Policy{limiter: limiter, rules: byRoute, trustProxy: trustProxy}
+}
+
+// For returns middleware enforcing the rules of route. Requests over a limit
+// get 429 with a Retry-After header. If the limiter fails, requests are let
+// through: an outage of Redis should not take logins down with it.
+func (p *Policy) For(route string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if p == nil || len(p.rules[route]) == 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var retryAfter time.Duration
+			var limited string
+
+			for _, rule := range p.rules[route] {
+				for _, kind := range rule.Keys {
+					value := p.keyValue(r, kind)
+					if value == "" {
+						continue
+					}
+
+					res, err := p.limiter.Allow(r.Context(), route+":"+kind+":"+value, rule.Limit, rule.Window)
+					if err != nil {
+						slog.WarnContext(r.Context(), "rate limit check failed", "route", route, "error", err)
+						continue
+					}
+
+					if !res.Allowed && res.RetryAfter > retryAfter {
+						retryAfter = res.RetryAfter
+						limited = kind
+					}
+				}
+			}
+
+			if limited != "" {
+				metrics.RateLimited.WithLabelValues(route, limited).Inc()
+				slog.WarnContext(r.Context(), "request rate limited", "route", route, "key", limited, "retry_after", retryAfter.String())
+				utils.SetRetryAfter(w, retryAfter)
+				utils.ErrorJSON(w, errors.New("too many requests, try again later"), http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (p *Policy) keyValue(r *http.Request, kind string) string {
+	switch kind {
+	case KeyIP:
+		return ClientIP(r, p.trustProxy)
+	case KeyUser:
+		id, _ := r.Context().Value(entities.UseridKeyValue).(string)
+		return id
+	case KeyEmail:
+		email := peekEmail(r)
+		if email == "" {
+			return ""
+		}
+		// Hashed so addresses are not kept in Redis key names.
+		sum := sha256.Sum256([]byte(email))
+		return hex.EncodeToString(sum[:16])
+	}
+
+	return ""
+}
+
+// ClientIP returns the IP a request came from.
+func ClientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		forwarded := r.Header.Get("X-Forwarded-For")
+		if forwarded != "" {
+			parts := strings.Split(forwarded, ",")
+			return strings.TrimSpace(parts[len(parts)-1])
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// peekEmail reads the email field of a JSON body and puts the body back for
+// the handler.
+func peekEmail(r *http.Request) string {
+	if r.Body == nil {
+		return ""
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxPeekBody))
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+
+	var payload struct {
+		Email string `json:"email"`
+	}
+
+	_ = json.Unmarshal(body, &payload)
+
+	return strings.ToLower(strings.TrimSpace(payload.Email))
+}
@@ -0,0 +1,80 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type failingLimiter struct{}
+
+func (failingLimiter) Allow(context.Context, string, int, time.Duration) (Result, error) {
+	return Result{}, errors.New("redis down")
+}
+
+func echoBody(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	w.Write(body)
+}
+
+func loginRequest(email string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/api/user/login", strings.NewReader(`{"email":"`+email+`","password":"secret"}`))
+	req.RemoteAddr = "10.0.0.1:5000"
+	return req
+}
+
+func TestPolicy_For(t *testing.T) {
+	l, _ := newTestLimiter(t)
+	rules := []Rule{{Route: "login", Limit: 2, Window: time.Minute, Keys: []string{KeyEmail}}}
+	handler := NewPolicy(l, rules, false).For("login")(http.HandlerFunc(echoBody))
+
+	for i := 0; i < 2; i++ {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, loginRequest("Guest@Gmail.com"))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), "Guest@Gmail.com", "body is restored for the handler")
+	}
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, loginRequest("guest@gmail.com"))
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "60", rr.Header().Get("Retry-After"))
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, loginRequest("other@gmail.com"))
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestPolicy_For_FailsOpen(t *testing.T) {
+	rules := []Rule{{Route: "login", Limit: 1, Window: time.Minute, Keys: []string{KeyIP}}}
+	handler := NewPolicy(failingLimiter{}, rules, false).For("login")(http.HandlerFunc(echoBody))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, loginRequest("guest@gmail.com"))
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestPolicy_For_NilPolicy(t *testing.T) {
+	var p *Policy
+	handler := p.For("login")(http.HandlerFunc(echoBody))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, loginRequest("guest@gmail.com"))
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestClientIP(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:5000"
+	req.Header.Set("X-Forwarded-For", "1.1.1.1, 2.2.2.2")
+
+	assert.Equal(t, "10.0.0.1", ClientIP(req, false))
+	assert.Equal(t, "2.2.2.2", ClientIP(req, true))
+}
@@ -0,0 +1,150 @@
+// Package ratelimit throttles requests with sliding windows kept in Redis, so
+// every instance of the app shares the same counts.
+package ratelimit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/redis/go-redis/v9"
+)
+
+// Key kinds a rule can count requests by.
+const (
+	KeyIP    = "ip"
+	KeyUser  = "user"
+	KeyEmail = "email"
+)
+
+// Rule allows Limit requests per Window to a route for every value of each
+// of its Keys.
+type Rule struct {
+	Route  string
+	Limit  int
+	Window time.Duration
+	Keys   []string
+}
+
+// Result is the outcome of one request against a limit.
+type Result struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration
+}
+
+// Limiter counts requests against limits.
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit int, window time.Duration) (Result, error)
+}
+
+// slidingWindow keeps the time of every request in the window in a sorted
+// set. A request is let in while fewer than limit are left after dropping
+// the expired ones; otherwise it is told when the oldest one expires.
+var slidingWindow = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+
+local count = redis.call('ZCARD', key)
+if count < limit then
+	redis.call('ZADD', key, now, ARGV[4])
+	redis.call('PEXPIRE', key, window)
+	return {1, limit - count - 1, 0}
+end
+
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+return {0, 0, tonumber(oldest[2]) + window - now}
+`)
+
+// RedisLimiter is a Limiter backed by Redis.
+type RedisLimiter struct {
+	client redis.Scripter
+	now    func() time.Time
+}
+
+func NewRedisLimiter(client redis.Scripter) *RedisLimiter {
+	return &RedisLimiter{client: client, now: time.Now}
+}
+
+func (l *RedisLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (Result, error) {
+	b := make([]byte, 8)
+	_, err := rand.Read(b)
+	if err != nil {
+		return Result{}, err
+	}
+
+	now := l.now().UnixMilli()
+	member := fmt.Sprintf("%d-%s", now, hex.EncodeToString(b))
+
+	res, err := slidingWindow.Run(ctx, l.client, []string{"ratelimit:" + key}, now, window.Milliseconds(), limit, member).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+
+	if len(res) != 3 {
+		return Result{}, fmt.Errorf("ratelimit: unexpected script result %v", res)
+	}
+
+	return Result{
+		Allowed:    res[0] == 1,
+		Remaining:  int(res[1]),
+		RetryAfter: time.Duration(res[2]) * time.Millisecond,
+	}, nil
+}
+
+// DefaultRules are used for routes with no configured rule.
+var DefaultRules = []Rule{
+	{Route: "login", Limit: 10, Window: time.Minute, Keys: []string{KeyIP, KeyEmail}},
+	{Route: "register", Limit: 5, Window: time.Hour, Keys: []string{KeyIP}},
+	{Route: "reset", Limit: 3, Window: 15 * time.Minute, Keys: []string{KeyIP, KeyUser}},
+	{Route: "password-reset", Limit: 5, Window: 15 * time.Minute, Keys: []string{KeyIP, KeyUser}},
+	{Route: "mfa", Limit: 10, Window: 5 * time.Minute, Keys: []string{KeyIP, KeyUser}},
+	{Route: "profile", Limit: 10, Window: 15 * time.Minute, Keys: []string{KeyIP, KeyUser}},
+}
+
+// Rules turns configured limits into rules, adding DefaultRules for routes
+// that have none. A configured rule with a bad window, limit or key is an
+// error rather than silently unlimited.
+func Rules(configs []entities.RateLimitConfig) ([]Rule, error) {
+	rules := []Rule{}
+	configured := map[string]bool{}
+
+	for _, c := range configs {
+		window, err := time.ParseDuration(c.Window)
+		if err != nil || window <= 0 {
+			return nil, fmt.Errorf("ratelimit %s: invalid window %q", c.Name, c.Window)
+		}
+
+		if c.Limit < 1 {
+			return nil, fmt.Errorf("ratelimit %s: limit must be at least 1", c.Name)
+		}
+
+		if len(c.Keys) == 0 {
+			return nil, fmt.Errorf("ratelimit %s: no keys", c.Name)
+		}
+
+		for _, k := range c.Keys {
+			if k != KeyIP && k != KeyUser && k != KeyEmail {
+				return nil, fmt.Errorf("ratelimit %s: unknown key %q", c.Name, k)
+			}
+		}
+
+		rules = append(rules, Rule{Route: c.Name, Limit: c.Limit, Window: window, Keys: c.Keys})
+		configured[c.Name] = true
+	}
+
+	for _, r := range DefaultRules {
+		if !configured[r.Route] {
+			rules = append(rules, r)
+		}
+	}
+
+	return rules, nil
+}
@@ -0,0 +1,86 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestLimiter(t *testing.T) (*RedisLimiter, *time.Time) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	now := time.Date(2026, 11, 1, 12, 0, 0, 0, time.UTC)
+	l := NewRedisLimiter(client)
+	l.now = func() time.Time { return now }
+
+	return l, &now
+}
+
+func TestRedisLimiter_Allow(t *testing.T) {
+	l, now := newTestLimiter(t)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		res, err := l.Allow(ctx, "login:ip:1.2.3.4", 3, time.Minute)
+		assert.NoError(t, err)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, 2-i, res.Remaining)
+		*now = now.Add(10 * time.Second)
+	}
+
+	res, err := l.Allow(ctx, "login:ip:1.2.3.4", 3, time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 30*time.Second, res.RetryAfter)
+
+	// Other keys have their own counts.
+	res, err = l.Allow(ctx, "login:ip:5.6.7.8", 3, time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, res.Allowed)
+
+	// Once the first request leaves the window there is room again.
+	*now = now.Add(31 * time.Second)
+	res, err = l.Allow(ctx, "login:ip:1.2.3.4", 3, time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, res.Allowed)
+}
+
+func TestRules(t *testing.T) {
+	rules, err := Rules([]entities.RateLimitConfig{
+		{Name: "login", Limit: 3, Window: "30s", Keys: []string{KeyEmail}},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, rules, len(DefaultRules))
+	assert.Equal(t, Rule{Route: "login", Limit: 3, Window: 30 * time.Second, Keys: []string{KeyEmail}}, rules[0])
+
+	for _, r := range rules[1:] {
+		assert.NotEqual(t, "login", r.Route)
+	}
+}
+
+func TestRules_Invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config entities.RateLimitConfig
+		errMsg string
+	}{
+		{"bad window", entities.RateLimitConfig{Name: "login", Limit: 3, Window: "soon", Keys: []string{KeyIP}}, "invalid window"},
+		{"zero limit", entities.RateLimitConfig{Name: "login", Window: "1m", Keys: []string{KeyIP}}, "limit must be at least 1"},
+		{"no keys", entities.RateLimitConfig{Name: "login", Limit: 3, Window: "1m"}, "no keys"},
+		{"unknown key", entities.RateLimitConfig{Name: "login", Limit: 3, Window: "1m", Keys: []string{"phone"}}, "unknown key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Rules([]entities.RateLimitConfig{tt.config})
+			assert.ErrorContains(t, err, tt.errMsg)
+		})
+	}
+}
@@ -0,0 +1,75 @@
+// Package safehttp builds HTTP clients for calling URLs supplied by users,
+// such as webhook endpoints and external calendars, without letting them
+// reach services on the internal network.
+package safehttp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateAddress is returned when a URL resolves to an address that is not
+// publicly routable.
+var ErrPrivateAddress = errors.New("safehttp: private address not allowed")
+
+// ValidateURL checks that raw is an absolute http(s) URL of at most 2048
+// characters. Where it may point is checked when connecting.
+func ValidateURL(raw string) error {
+	if len(raw) > 2048 {
+		return errors.New("url must be at most 2048 characters")
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return errors.New("url must use http or https")
+	}
+
+	if u.Host == "" {
+		return errors.New("url must have a host")
+	}
+
+	return nil
+}
+
+// NewClient returns a client whose requests time out after timeout and that
+// ignores proxy settings. Unless allowPrivate is set, it refuses to connect
+// to loopback, private and link-local addresses. The check runs on every
+// connection, redirects included.
+func NewClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = refusePrivate
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// refusePrivate runs after DNS resolution, so it also catches public names
+// that resolve to internal addresses.
+func refusePrivate(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsUnspecified() || ip.IsMulticast() {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, host)
+	}
+
+	return nil
+}
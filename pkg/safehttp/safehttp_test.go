@@ -0,0 +1,41 @@
+package safehttp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewClient_RefusesPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request reached a loopback server")
+	}))
+	defer srv.Close()
+
+	_, err := NewClient(time.Second, false).Get(srv.URL)
+	assert.True(t, errors.Is(err, ErrPrivateAddress), "got %v", err)
+}
+
+func TestNewClient_AllowPrivate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	resp, err := NewClient(time.Second, true).Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
+
+func TestRefusePrivate(t *testing.T) {
+	for _, addr := range []string{"127.0.0.1:80", "10.0.0.1:443", "192.168.1.1:80", "169.254.169.254:80", "[::1]:80", "0.0.0.0:80"} {
+		assert.ErrorIs(t, refusePrivate("tcp", addr, nil), ErrPrivateAddress, addr)
+	}
+	assert.NoError(t, refusePrivate("tcp", "93.184.216.34:443", nil))
+}
@@ -0,0 +1,134 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// withRecorder installs a tracer provider that keeps finished spans in memory.
+func withRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+
+	recorder := tracetest.NewSpanRecorder()
+	prevProvider := otel.GetTracerProvider()
+	prevPropagator := otel.GetTextMapPropagator()
+
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+
+	return recorder
+}
+
+func TestMiddleware_NamesSpanAfterRoute(t *testing.T) {
+	recorder := withRecorder(t)
+
+	r := chi.NewRouter()
+	r.Use(Middleware("user"))
+	r.Get("/rooms/{room_id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/rooms/7", nil))
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "GET /rooms/{room_id}", spans[0].Name())
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+}
+
+func TestMiddleware_ContinuesIncomingTrace(t *testing.T) {
+	recorder := withRecorder(t)
+
+	r := chi.NewRouter()
+	r.Use(Middleware("user"))
+	r.Get("/rooms", func(w http.ResponseWriter, r *http.Request) {})
+
+	req := httptest.NewRequest(http.MethodGet, "/rooms", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", spans[0].Parent().SpanID().String())
+}
+
+func TestKafkaPropagation(t *testing.T) {
+	withRecorder(t)
+
+	ctx, span := Tracer().Start(context.Background(), "publish")
+	defer span.End()
+
+	msg := &kafka.Message{Headers: []kafka.Header{{Key: "other", Value: []byte("kept")}}}
+	InjectKafka(ctx, msg)
+
+	assert.Len(t, msg.Headers, 2)
+
+	extracted := ExtractKafka(context.Background(), msg)
+	assert.Equal(t, span.SpanContext().TraceID(), trace.SpanContextFromContext(extracted).TraceID())
+}
+
+func TestAMQPPropagation(t *testing.T) {
+	withRecorder(t)
+
+	ctx, span := Tracer().Start(context.Background(), "publish")
+	defer span.End()
+
+	headers := InjectAMQP(ctx, nil)
+	assert.Contains(t, headers, "traceparent")
+
+	extracted := ExtractAMQP(context.Background(), headers)
+	assert.Equal(t, span.SpanContext().TraceID(), trace.SpanContextFromContext(extracted).TraceID())
+}
+
+func TestSetup(t *testing.T) {
+	prevProvider := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(prevProvider) })
+
+	t.Run("disabled", func(t *testing.T) {
+		shutdown, err := Setup(context.Background(), entities.TracingConfig{})
+		assert.NoError(t, err)
+		assert.NoError(t, shutdown(context.Background()))
+	})
+
+	t.Run("unknown exporter", func(t *testing.T) {
+		_, err := Setup(context.Background(), entities.TracingConfig{Exporter: "zipkin"})
+		assert.EqualError(t, err, `unknown trace exporter "zipkin"`)
+	})
+
+	t.Run("file exporter", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "spans.json")
+
+		shutdown, err := Setup(context.Background(), entities.TracingConfig{Exporter: ExporterFile, File: file})
+		assert.NoError(t, err)
+
+		_, span := Tracer().Start(context.Background(), "written-to-file")
+		span.End()
+
+		assert.NoError(t, shutdown(context.Background()))
+
+		data, err := os.ReadFile(file)
+		assert.NoError(t, err)
+		assert.Contains(t, string(data), "written-to-file")
+	})
+}
@@ -5,11 +5,17 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"html"
+	"log/slog"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/jwtkeys"
+	"github.com/bicosteve/booking-system/pkg/money"
+	"github.com/bicosteve/booking-system/pkg/phone"
 	"github.com/edwinwalela/africastalking-go/pkg/sms"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/sendgrid/sendgrid-go"
@@ -17,7 +23,9 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
-func ValidateUser(data *entities.UserPayload) error {
+// ValidateUser checks a registration and rewrites its phone number in E.164
+// form, reading national numbers as numbers of region.
+func ValidateUser(data *entities.UserPayload, region string) error {
 
 	if data.Email == "" {
 		return errors.New("email is required")
@@ -31,6 +39,12 @@ func ValidateUser(data *entities.UserPayload) error {
 		return errors.New("phone number is required")
 	}
 
+	number, err := phone.Normalize(data.PhoneNumber, region)
+	if err != nil {
+		return err
+	}
+	data.PhoneNumber = number
+
 	if data.IsVendor == "" {
 		return errors.New("isVendor is required")
 	}
@@ -50,6 +64,22 @@ func ValidateUser(data *entities.UserPayload) error {
 	return nil
 }
 
+// ValidateProfile rewrites a new phone number in E.164 form, reading
+// national numbers as numbers of region.
+func ValidateProfile(data *entities.ProfilePayload, region string) error {
+	if data.PhoneNumber == nil {
+		return nil
+	}
+
+	number, err := phone.Normalize(*data.PhoneNumber, region)
+	if err != nil {
+		return fmt.Errorf("%w: %v", entities.ErrInvalidProfile, err)
+	}
+	data.PhoneNumber = &number
+
+	return nil
+}
+
 func ValidateLogin(data *entities.UserPayload) error {
 
 	if data.Email == "" {
@@ -76,6 +106,18 @@ func ValidateRoom(data *entities.RoomPayload) error {
 		return errors.New("room status required")
 	}
 
+	currency, err := money.NormalizeCurrency(data.Currency)
+	if err != nil {
+		return err
+	}
+
+	_, err = money.FromMajor(data.Cost, currency)
+	if err != nil {
+		return err
+	}
+
+	data.Currency = currency
+
 	return nil
 }
 
@@ -88,8 +130,54 @@ func ValidateBooking(data *entities.BookingPayload) error {
 		return errors.New("room id is required")
 	}
 
-	if data.Amount == nil {
-		return errors.New("amount is required")
+	if *data.Days < 1 {
+		return errors.New("days must be at least 1")
+	}
+
+	if data.CheckIn != nil {
+		checkIn, err := time.Parse(entities.DateLayout, *data.CheckIn)
+		if err != nil {
+			return errors.New("check in must be a YYYY-MM-DD date")
+		}
+
+		if checkIn.Before(time.Now().UTC().Truncate(24 * time.Hour)) {
+			return errors.New("check in cannot be in the past")
+		}
+	}
+
+	return nil
+}
+
+const maxReviewLength = 2000
+
+func ValidateReview(data *entities.ReviewPayload) error {
+	if data.BookingID == nil {
+		return errors.New("booking id is required")
+	}
+
+	ratings := []struct {
+		category string
+		stars    int
+	}{
+		{"cleanliness", data.Ratings.Cleanliness},
+		{"comfort", data.Ratings.Comfort},
+		{"location", data.Ratings.Location},
+		{"value", data.Ratings.Value},
+	}
+
+	for _, r := range ratings {
+		if r.stars < 1 || r.stars > 5 {
+			return fmt.Errorf("%s rating must be between 1 and 5", r.category)
+		}
+	}
+
+	data.Text = strings.TrimSpace(data.Text)
+	if data.Text == "" {
+		return errors.New("review text is required")
+	}
+
+	if len(data.Text) > maxReviewLength {
+		return fmt.Errorf("review text must be at most %d characters", maxReviewLength)
 	}
 
 	return nil
@@ -98,7 +186,7 @@ func ValidateBooking(data *entities.BookingPayload) error {
 func GeneratePasswordHash(p string) (string, error) {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(p), bcrypt.DefaultCost)
 	if err != nil {
-		LogError(err.Error(), entities.ErrorLog)
+		slog.Error("hashing password failed", "error", err)
 		return "", err
 	}
 
@@ -108,7 +196,7 @@ func GeneratePasswordHash(p string) (string, error) {
 func ComparePasswordWithHash(password string, hash *string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(*hash), []byte(password))
 	if err != nil {
-		LogError(err.Error(), entities.ErrorLog)
+		slog.Debug("password does not match hash", "error", err)
 		return false
 
 	}
@@ -116,52 +204,79 @@ func ComparePasswordWithHash(password string, hash *string) bool {
 	return true
 }
 
-func GenerateAuthToken(user entities.User, secret string) (string, error) {
+func GenerateAuthToken(user entities.User, keys *jwtkeys.Keyring) (string, error) {
+	return signAuthToken(user, keys, false, false, time.Hour*24)
+}
+
+// GenerateMFAAuthToken returns an auth token for a user who has passed a
+// second factor. Only these tokens are let into the admin router.
+func GenerateMFAAuthToken(user entities.User, keys *jwtkeys.Keyring) (string, error) {
+	return signAuthToken(user, keys, true, false, time.Hour*24)
+}
+
+// GenerateMFAPendingToken returns the token login hands out when a second
+// factor is still needed. AuthMiddleware refuses it; it can only be traded
+// for an auth token with a valid code.
+func GenerateMFAPendingToken(user entities.User, keys *jwtkeys.Keyring) (string, error) {
+	return signAuthToken(user, keys, false, true, time.Minute*5)
+}
+
+// VerifyMFAPendingToken returns the claims of a pending token from
+// GenerateMFAPendingToken.
+func VerifyMFAPendingToken(tokenString string, keys *jwtkeys.Keyring) (*entities.Claims, error) {
+	claims, err := verifyAuthToken(tokenString, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	if !claims.MFAPending {
+		return nil, fmt.Errorf("token is not an mfa token")
+	}
+
+	return claims, nil
+}
+
+func signAuthToken(user entities.User, keys *jwtkeys.Keyring, mfa, pending bool, ttl time.Duration) (string, error) {
 	type claims entities.Claims
 	c := &claims{
 		Username:    user.Email,
 		UserID:      user.ID,
 		IsVendor:    user.IsVender,
 		PhoneNumber: user.PhoneNumber,
+		MFA:         mfa,
+		MFAPending:  pending,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 24)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, c)
 
-	tokenString, err := token.SignedString([]byte(secret))
+	tokenString, err := keys.Sign(c)
 	if err != nil {
-		LogError(err.Error(), entities.ErrorLog)
+		slog.Error("signing auth token failed", "error", err)
 		return "", err
 	}
 
 	return tokenString, nil
 }
 
-func verifyAuthToken(tokenString, secret string) (*entities.Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &entities.Claims{}, func(t *jwt.Token) (interface{}, error) {
-		_, ok := t.Method.(*jwt.SigningMethodHMAC)
-		if !ok {
-			LogError("error occurred while signing token", entities.ErrorLog)
-			return nil, fmt.Errorf("error occurent while signing token")
-		}
-
-		return []byte(secret), nil
-	})
-
+// verifyAuthToken accepts tokens signed with any key in keys that has not
+// retired.
+func verifyAuthToken(tokenString string, keys *jwtkeys.Keyring) (*entities.Claims, error) {
+	token, err := keys.Parse(tokenString, &entities.Claims{})
 	if err != nil {
-		LogError(err.Error(), entities.ErrorLog)
+		slog.Warn("parsing auth token failed", "error", err)
 		return &entities.Claims{}, err
 	}
 
 	if !token.Valid {
-		LogError("token is invalid", entities.ErrorLog)
+		slog.Warn("auth token is invalid")
 		return nil, fmt.Errorf("token is invalid")
 	}
 
 	claims, ok := token.Claims.(*entities.Claims)
 	if !ok {
-		LogError("invalid claims", entities.ErrorLog)
+		slog.Warn("auth token has invalid claims")
 		return nil, fmt.Errorf("invalid claims")
 	}
 
@@ -174,7 +289,7 @@ func GenerateResetToken(userId string) (string, error) {
 	tknBytes := make([]byte, 32)
 	_, err := rand.Read(tknBytes)
 	if err != nil {
-		LogError(err.Error(), entities.ErrorLog)
+		slog.Error("generating reset token failed", "error", err)
 		return "", err
 	}
 
@@ -196,7 +311,7 @@ func IsValidResetToken(token string) (bool, string, error) {
 	// 1. Split token string into 3 parts to separate randStr, timeInMillis, userID
 	parts := strings.Split(token, "|")
 	if len(parts) < 3 {
-		LogError("invalid reset token", entities.ErrorLog)
+		slog.Warn("reset token is malformed")
 		return false, "", errors.New("invalid reset token")
 	}
 
@@ -206,7 +321,7 @@ func IsValidResetToken(token string) (bool, string, error) {
 	// 2. Convert expiration time from string to int
 	timeInInt, err := strconv.Atoi(tokenExpirationStr)
 	if err != nil {
-		LogError(err.Error(), entities.ErrorLog)
+		slog.Warn("reset token expiry is not a number", "error", err)
 		return false, "", err
 	}
 
@@ -224,11 +339,21 @@ func IsValidResetToken(token string) (bool, string, error) {
 }
 
 func SendMail(key, from, subject, to, token string) (int, error) {
+	plainTextContent := fmt.Sprintf("Your reset token %s. Expires in 10 minutes", token)
+	html := "<h1>Hello there! From Booking System</h1>"
+
+	return sendMail(key, from, subject, to, plainTextContent, html)
+}
+
+// SendMailText mails text as it is, in both the plain and HTML parts.
+func SendMailText(key, from, subject, to, text string) (int, error) {
+	return sendMail(key, from, subject, to, text, "<p>"+html.EscapeString(text)+"</p>")
+}
+
+func sendMail(key, from, subject, to, plainTextContent, html string) (int, error) {
 	client := sendgrid.NewSendClient(key)
 	mail_from := mail.NewEmail("Booking System", from)
 	mail_to := mail.NewEmail("User", to)
-	plainTextContent := fmt.Sprintf("Your reset token %s. Expires in 10 minutes", token)
-	html := "<h1>Hello there! From Booking System</h1>"
 
 	message := mail.NewSingleEmail(mail_from, subject, mail_to, plainTextContent, html)
 
@@ -248,10 +373,13 @@ func SendSMS(key, username, phoneNumber, msg string) (string, error) {
 		IsSandbox: true,
 	}
 
-	number := fmt.Sprintf("+254%s", phoneNumber)
+	// Numbers are stored in E.164 form since registration normalizes them.
+	if !phone.IsE164(phoneNumber) {
+		return "", fmt.Errorf("sending sms to %q: %w", phoneNumber, phone.ErrInvalidNumber)
+	}
 
 	request := &sms.BulkRequest{
-		To:            []string{number}, // can have more than one number
+		To:            []string{phoneNumber}, // can have more than one number
 		Message:       msg,
 		From:          username,      // app username
 		BulkSMSMode:   true,          // set to true to avoid overchaging
@@ -261,7 +389,7 @@ func SendSMS(key, username, phoneNumber, msg string) (string, error) {
 
 	res, err := client.SendBulk(request)
 	if err != nil {
-		LogError(err.Error(), entities.ErrorLog)
+		slog.Error("sending sms failed", "error", err)
 		return "", err
 	}
 
@@ -281,7 +409,7 @@ func ValidateFilters(f entities.Filters) error {
 		return nil
 	}
 
-	sortSafeList := []string{"id", "cost", "created_at"}
+	sortSafeList := []string{"id", "cost", "created_at", "rating"}
 
 	for _, list := range sortSafeList {
 		if list == f.Sort {
@@ -292,6 +420,64 @@ func ValidateFilters(f entities.Filters) error {
 	return errors.New("provided sort parameter is not allowed")
 }
 
+// maxDateWindow is the most days a calendar or availability query may span.
+const maxDateWindow = 366
+
+// ParseDateWindow parses the from and to query params of calendar queries.
+// from defaults to today and to to 90 days after from.
+func ParseDateWindow(from, to string, now time.Time) (time.Time, time.Time, error) {
+	start := now.UTC().Truncate(24 * time.Hour)
+	if from != "" {
+		var err error
+		start, err = time.Parse(entities.DateLayout, from)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("from must be a YYYY-MM-DD date")
+		}
+	}
+
+	end := start.AddDate(0, 0, 90)
+	if to != "" {
+		var err error
+		end, err = time.Parse(entities.DateLayout, to)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("to must be a YYYY-MM-DD date")
+		}
+	}
+
+	if !end.After(start) {
+		return time.Time{}, time.Time{}, errors.New("to must be after from")
+	}
+
+	if end.Sub(start) > maxDateWindow*24*time.Hour {
+		return time.Time{}, time.Time{}, fmt.Errorf("date range cannot exceed %d days", maxDateWindow)
+	}
+
+	return start, end, nil
+}
+
+// ParseStay parses the check_in and days query params of a room search into
+// the nights of the stay. days defaults to 1.
+func ParseStay(checkIn, days string, now time.Time) (time.Time, time.Time, error) {
+	start, err := time.Parse(entities.DateLayout, checkIn)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("check in must be a YYYY-MM-DD date")
+	}
+
+	if start.Before(now.UTC().Truncate(24 * time.Hour)) {
+		return time.Time{}, time.Time{}, errors.New("check in cannot be in the past")
+	}
+
+	n := 1
+	if days != "" {
+		n, err = strconv.Atoi(days)
+		if err != nil || n < 1 || n > maxDateWindow {
+			return time.Time{}, time.Time{}, fmt.Errorf("days must be between 1 and %d", maxDateWindow)
+		}
+	}
+
+	return start, start.AddDate(0, 0, n), nil
+}
+
 func FilterRoomByID(rooms []*entities.Room, targetID string) (*entities.Room, bool) {
 	for _, item := range rooms {
 		if item.ID == targetID {
@@ -319,3 +505,41 @@ func FilterRoomByStatus(rooms []*entities.Room, status string) ([]*entities.Room
 
 	return _rooms, true
 }
+
+// SortRooms orders rooms by one of the keys accepted by ValidateFilters.
+// Rating puts the best rated rooms first, breaking ties by review count, and
+// cost uses the display price when rooms have been converted for a guest.
+func SortRooms(rooms []*entities.Room, by string) {
+	cost := func(room *entities.Room) int64 {
+		if room.DisplayCost != nil {
+			return room.DisplayCost.Amount
+		}
+		return room.Cost.Amount
+	}
+
+	var less func(a, b *entities.Room) bool
+
+	switch by {
+	case "id":
+		less = func(a, b *entities.Room) bool {
+			x, _ := strconv.Atoi(a.ID)
+			y, _ := strconv.Atoi(b.ID)
+			return x < y
+		}
+	case "cost":
+		less = func(a, b *entities.Room) bool { return cost(a) < cost(b) }
+	case "created_at":
+		less = func(a, b *entities.Room) bool { return a.CreateAt.After(b.CreateAt) }
+	case "rating":
+		less = func(a, b *entities.Room) bool {
+			if a.Rating != b.Rating {
+				return a.Rating > b.Rating
+			}
+			return a.ReviewCount > b.ReviewCount
+		}
+	default:
+		return
+	}
+
+	sort.SliceStable(rooms, func(i, j int) bool { return less(rooms[i], rooms[j]) })
+}
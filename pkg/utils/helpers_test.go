@@ -7,19 +7,21 @@ import (
 	"time"
 
 	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/jwtkeys"
+	"github.com/bicosteve/booking-system/pkg/money"
+	"github.com/bicosteve/booking-system/pkg/phone"
 	"github.com/stretchr/testify/assert"
 )
 
-func intPtr(i int) *int { return &i }
-func f64Ptr(f float64) *float64 {
-	return &f
-}
+func intPtr(i int) *int       { return &i }
+func strPtr(s string) *string { return &s }
 
 func TestValidateUser(t *testing.T) {
 	tests := []struct {
-		name    string
-		payload entities.UserPayload
-		wantErr string
+		name      string
+		payload   entities.UserPayload
+		wantPhone string
+		wantErr   string
 	}{
 		{
 			name: "valid user",
@@ -30,7 +32,28 @@ func TestValidateUser(t *testing.T) {
 				Password:        "secret",
 				ConfirmPassword: "secret",
 			},
-			wantErr: "",
+			wantPhone: "+254700000000",
+			wantErr:   "",
+		},
+		{
+			name: "international phone",
+			payload: entities.UserPayload{
+				Email:           "user@example.com",
+				PhoneNumber:     "+44 7911 123456",
+				IsVendor:        "NO",
+				Password:        "secret",
+				ConfirmPassword: "secret",
+			},
+			wantPhone: "+447911123456",
+			wantErr:   "",
+		},
+		{
+			name: "invalid phone",
+			payload: entities.UserPayload{
+				Email:       "user@example.com",
+				PhoneNumber: "07000",
+			},
+			wantErr: phone.ErrInvalidNumber.Error(),
 		},
 		{
 			name:    "missing email",
@@ -94,9 +117,10 @@ func TestValidateUser(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			p := tt.payload
-			err := ValidateUser(&p)
+			err := ValidateUser(&p, phone.DefaultRegion)
 			if tt.wantErr == "" {
 				assert.NoError(t, err)
+				assert.Equal(t, tt.wantPhone, p.PhoneNumber)
 			} else {
 				assert.EqualError(t, err, tt.wantErr)
 			}
@@ -104,6 +128,17 @@ func TestValidateUser(t *testing.T) {
 	}
 }
 
+func TestValidateProfile(t *testing.T) {
+	assert.NoError(t, ValidateProfile(&entities.ProfilePayload{Name: strPtr("Jane")}, "KE"))
+
+	data := entities.ProfilePayload{PhoneNumber: strPtr("0772 123456")}
+	assert.NoError(t, ValidateProfile(&data, "UG"))
+	assert.Equal(t, "+256772123456", *data.PhoneNumber)
+
+	data = entities.ProfilePayload{PhoneNumber: strPtr("+254 712")}
+	assert.ErrorIs(t, ValidateProfile(&data, "KE"), entities.ErrInvalidProfile)
+}
+
 func TestValidateLogin(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -166,6 +201,16 @@ func TestValidateRoom(t *testing.T) {
 			payload: entities.RoomPayload{Cost: "100"},
 			wantErr: "room status required",
 		},
+		{
+			name:    "invalid currency",
+			payload: entities.RoomPayload{Cost: "100", Currency: "shillings", Status: "VACANT"},
+			wantErr: "currency must be a 3 letter ISO 4217 code",
+		},
+		{
+			name:    "too many decimals for currency",
+			payload: entities.RoomPayload{Cost: "100.5", Currency: "JPY", Status: "VACANT"},
+			wantErr: `amount "100.5" has too many decimals for JPY`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -192,7 +237,6 @@ func TestValidateBooking(t *testing.T) {
 			payload: entities.BookingPayload{
 				Days:   intPtr(2),
 				RoomID: intPtr(1),
-				Amount: f64Ptr(100),
 			},
 			wantErr: "",
 		},
@@ -207,9 +251,24 @@ func TestValidateBooking(t *testing.T) {
 			wantErr: "room id is required",
 		},
 		{
-			name:    "missing amount",
-			payload: entities.BookingPayload{Days: intPtr(2), RoomID: intPtr(1)},
-			wantErr: "amount is required",
+			name:    "zero days",
+			payload: entities.BookingPayload{Days: intPtr(0), RoomID: intPtr(1)},
+			wantErr: "days must be at least 1",
+		},
+		{
+			name:    "check in date",
+			payload: entities.BookingPayload{Days: intPtr(2), RoomID: intPtr(1), CheckIn: strPtr(time.Now().AddDate(0, 0, 7).Format(entities.DateLayout))},
+			wantErr: "",
+		},
+		{
+			name:    "malformed check in",
+			payload: entities.BookingPayload{Days: intPtr(2), RoomID: intPtr(1), CheckIn: strPtr("01/11/2026")},
+			wantErr: "check in must be a YYYY-MM-DD date",
+		},
+		{
+			name:    "check in in the past",
+			payload: entities.BookingPayload{Days: intPtr(2), RoomID: intPtr(1), CheckIn: strPtr("2020-01-01")},
+			wantErr: "check in cannot be in the past",
 		},
 	}
 
@@ -252,7 +311,7 @@ func TestGenerateAuthToken(t *testing.T) {
 		PhoneNumber: "0700000000",
 	}
 
-	token, err := GenerateAuthToken(user, "secret")
+	token, err := GenerateAuthToken(user, jwtkeys.NewHMAC("secret"))
 	assert.NoError(t, err)
 	assert.NotEmpty(t, token)
 
@@ -268,7 +327,7 @@ func TestVerifyAuthToken(t *testing.T) {
 		IsVender:    "YES",
 		PhoneNumber: "0700000000",
 	}
-	secret := "topsecret"
+	secret := jwtkeys.NewHMAC("topsecret")
 
 	token, err := GenerateAuthToken(user, secret)
 	assert.NoError(t, err)
@@ -282,7 +341,7 @@ func TestVerifyAuthToken(t *testing.T) {
 	})
 
 	t.Run("wrong secret", func(t *testing.T) {
-		_, err := verifyAuthToken(token, "wrong-secret")
+		_, err := verifyAuthToken(token, jwtkeys.NewHMAC("wrong-secret"))
 		assert.Error(t, err)
 	})
 
@@ -292,6 +351,31 @@ func TestVerifyAuthToken(t *testing.T) {
 	})
 }
 
+func TestMFATokens(t *testing.T) {
+	user := entities.User{ID: "7", Email: "user@example.com", IsVender: "YES"}
+	secret := jwtkeys.NewHMAC("topsecret")
+
+	pending, err := GenerateMFAPendingToken(user, secret)
+	assert.NoError(t, err)
+
+	claims, err := VerifyMFAPendingToken(pending, secret)
+	assert.NoError(t, err)
+	assert.Equal(t, "7", claims.UserID)
+	assert.True(t, claims.MFAPending)
+	assert.False(t, claims.MFA)
+
+	full, err := GenerateMFAAuthToken(user, secret)
+	assert.NoError(t, err)
+
+	claims, err = verifyAuthToken(full, secret)
+	assert.NoError(t, err)
+	assert.True(t, claims.MFA)
+
+	// An auth token cannot stand in for a pending one.
+	_, err = VerifyMFAPendingToken(full, secret)
+	assert.Error(t, err)
+}
+
 func TestGenerateResetToken(t *testing.T) {
 	token, err := GenerateResetToken("42")
 	assert.NoError(t, err)
@@ -335,6 +419,42 @@ func TestIsValidResetToken(t *testing.T) {
 	})
 }
 
+func TestValidateReview(t *testing.T) {
+	valid := func() *entities.ReviewPayload {
+		return &entities.ReviewPayload{
+			BookingID: intPtr(100),
+			Ratings:   entities.ReviewRatings{Cleanliness: 5, Comfort: 4, Location: 4, Value: 3},
+			Text:      "  Great stay  ",
+		}
+	}
+
+	t.Run("valid review is trimmed", func(t *testing.T) {
+		data := valid()
+		assert.NoError(t, ValidateReview(data))
+		assert.Equal(t, "Great stay", data.Text)
+	})
+
+	tests := []struct {
+		name    string
+		modify  func(p *entities.ReviewPayload)
+		wantErr string
+	}{
+		{name: "missing booking", modify: func(p *entities.ReviewPayload) { p.BookingID = nil }, wantErr: "booking id is required"},
+		{name: "missing rating", modify: func(p *entities.ReviewPayload) { p.Ratings.Comfort = 0 }, wantErr: "comfort rating must be between 1 and 5"},
+		{name: "rating too high", modify: func(p *entities.ReviewPayload) { p.Ratings.Value = 6 }, wantErr: "value rating must be between 1 and 5"},
+		{name: "blank text", modify: func(p *entities.ReviewPayload) { p.Text = "   " }, wantErr: "review text is required"},
+		{name: "text too long", modify: func(p *entities.ReviewPayload) { p.Text = strings.Repeat("a", 2001) }, wantErr: "review text must be at most 2000 characters"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := valid()
+			tt.modify(data)
+			assert.EqualError(t, ValidateReview(data), tt.wantErr)
+		})
+	}
+}
+
 func TestValidateFilters(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -361,6 +481,11 @@ func TestValidateFilters(t *testing.T) {
 			filters: entities.Filters{Page: 1, PageSize: 10, Sort: "created_at"},
 			wantErr: "",
 		},
+		{
+			name:    "valid sort by rating",
+			filters: entities.Filters{Page: 1, PageSize: 10, Sort: "rating"},
+			wantErr: "",
+		},
 		{
 			name:    "page too large",
 			filters: entities.Filters{Page: 101, PageSize: 10, Sort: "id"},
@@ -447,3 +572,93 @@ func TestFilterRoomByStatus(t *testing.T) {
 		assert.Nil(t, result)
 	})
 }
+
+func TestSortRooms(t *testing.T) {
+	newRooms := func() []*entities.Room {
+		return []*entities.Room{
+			{ID: "10", Rating: 3.5, ReviewCount: 4, Cost: money.New(20000, "KES")},
+			{ID: "2", Rating: 4.75, ReviewCount: 2, Cost: money.New(5000, "KES")},
+			{ID: "7", Rating: 4.75, ReviewCount: 9, Cost: money.New(15000, "KES")},
+		}
+	}
+
+	ids := func(rooms []*entities.Room) []string {
+		var out []string
+		for _, room := range rooms {
+			out = append(out, room.ID)
+		}
+		return out
+	}
+
+	tests := []struct {
+		by   string
+		want []string
+	}{
+		{by: "rating", want: []string{"7", "2", "10"}},
+		{by: "id", want: []string{"2", "7", "10"}},
+		{by: "cost", want: []string{"2", "7", "10"}},
+		{by: "", want: []string{"10", "2", "7"}},
+	}
+
+	for _, tt := range tests {
+		t.Run("sort by "+tt.by, func(t *testing.T) {
+			rooms := newRooms()
+			SortRooms(rooms, tt.by)
+			assert.Equal(t, tt.want, ids(rooms))
+		})
+	}
+}
+
+func TestParseDateWindow(t *testing.T) {
+	now := time.Date(2026, 10, 19, 15, 4, 0, 0, time.UTC)
+	day := func(s string) time.Time {
+		d, _ := time.Parse(entities.DateLayout, s)
+		return d
+	}
+
+	tests := []struct {
+		name      string
+		from, to  string
+		wantStart time.Time
+		wantEnd   time.Time
+		wantErr   string
+	}{
+		{name: "defaults", wantStart: day("2026-10-19"), wantEnd: day("2027-01-17")},
+		{name: "explicit", from: "2026-11-01", to: "2026-12-01", wantStart: day("2026-11-01"), wantEnd: day("2026-12-01")},
+		{name: "malformed from", from: "01/11/2026", wantErr: "from must be a YYYY-MM-DD date"},
+		{name: "to before from", from: "2026-11-01", to: "2026-11-01", wantErr: "to must be after from"},
+		{name: "too long", from: "2026-11-01", to: "2027-12-01", wantErr: "date range cannot exceed 366 days"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, err := ParseDateWindow(tt.from, tt.to, now)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantStart, start)
+			assert.Equal(t, tt.wantEnd, end)
+		})
+	}
+}
+
+func TestParseStay(t *testing.T) {
+	now := time.Date(2026, 10, 19, 15, 4, 0, 0, time.UTC)
+
+	start, end, err := ParseStay("2026-11-01", "3", now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC), start)
+	assert.Equal(t, time.Date(2026, 11, 4, 0, 0, 0, 0, time.UTC), end)
+
+	_, end, err = ParseStay("2026-11-01", "", now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 11, 2, 0, 0, 0, 0, time.UTC), end)
+
+	_, _, err = ParseStay("2026-10-18", "1", now)
+	assert.EqualError(t, err, "check in cannot be in the past")
+
+	_, _, err = ParseStay("2026-11-01", "0", now)
+	assert.EqualError(t, err, "days must be between 1 and 366")
+}
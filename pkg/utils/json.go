@@ -5,8 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/bicosteve/booking-system/entities"
 )
@@ -103,3 +106,13 @@ func ErrorJSON(w http.ResponseWriter, err error, status ...int) error {
 
 	return nil
 }
+
+// SetRetryAfter tells the client how many whole seconds to wait before
+// trying again.
+func SetRetryAfter(w http.ResponseWriter, d time.Duration) {
+	secs := int64(math.Ceil(d.Seconds()))
+	if secs < 1 {
+		secs = 1
+	}
+	w.Header().Set("Retry-After", strconv.FormatInt(secs, 10))
+}
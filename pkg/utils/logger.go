@@ -1,45 +1,151 @@
 package utils
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
 	rotateLogs "github.com/lestrrat-go/file-rotatelogs"
 )
 
-func InitLogger(logFolder string) error {
-	// Check if the environment is not prod then use terminal
-	if os.Getenv("ENV") != "prod" {
-		log.SetOutput(os.Stderr)
-		return nil
-	}
+// InitLogger makes a leveled slog logger the default. Lines are JSON unless
+// cfg.Handler is "text", and go to stdout unless cfg.Writer asks for the
+// rotating log file in cfg.Folder, or cfg.Path when no folder is set ("file"),
+// or both ("both").
+func InitLogger(cfg entities.LoggerConfig) error {
+	var out io.Writer = os.Stdout
+
+	if cfg.Writer == "file" || cfg.Writer == "both" {
+		folder := cfg.Folder
+		if folder == "" {
+			folder = cfg.Path
+		}
 
-	writer, err := rotateLogs.New(
-		fmt.Sprintf("%s/app-%s.log", logFolder, "%Y-%m-%d"),
-		rotateLogs.WithLinkName(logFolder+"link"),
-		rotateLogs.WithRotationTime(time.Hour*24),
-	)
+		writer, err := rotateLogs.New(
+			filepath.Join(folder, "app-%Y-%m-%d.log"),
+			rotateLogs.WithLinkName(filepath.Join(folder, "app.log")),
+			rotateLogs.WithRotationTime(time.Hour*24),
+		)
+		if err != nil {
+			slog.SetDefault(slog.New(NewLogHandler(os.Stdout, cfg)))
+			return fmt.Errorf("unable to initialize log file, logging on stdout: %w", err)
+		}
 
-	if err != nil {
-		fmt.Printf("unable to initialize writer, logging on stderr")
-		log.SetOutput(os.Stderr)
-		return err
+		out = writer
+		if cfg.Writer == "both" {
+			out = io.MultiWriter(os.Stdout, writer)
+		}
 	}
 
-	log.SetOutput(writer)
+	slog.SetDefault(slog.New(NewLogHandler(out, cfg)))
+
 	return nil
 }
 
-func logger(level *log.Logger, msg string, params ...any) {
-	level.Printf(msg, params...)
+// NewLogHandler returns a handler writing to w at the level in cfg, which adds
+// the request, user, route and message ids carried by the context to every
+// record.
+func NewLogHandler(w io.Writer, cfg entities.LoggerConfig) slog.Handler {
+	opts := &slog.HandlerOptions{Level: logLevel(cfg.Level)}
+
+	var handler slog.Handler
+	if strings.EqualFold(cfg.Handler, "text") {
+		handler = slog.NewTextHandler(w, opts)
+	} else {
+		handler = slog.NewJSONHandler(w, opts)
+	}
+
+	return contextHandler{handler}
+}
+
+func logLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// contextHandler decorates records with the correlation ids found in the
+// context the record was logged with.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestIDFromContext(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+
+	if userID, ok := ctx.Value(entities.UseridKeyValue).(string); ok && userID != "" {
+		r.AddAttrs(slog.String("user_id", userID))
+	}
+
+	if rctx := chi.RouteContext(ctx); rctx != nil && rctx.RoutePattern() != "" {
+		r.AddAttrs(slog.String("route", rctx.RoutePattern()))
+	}
+
+	if id, ok := ctx.Value(messageIDKey).(string); ok && id != "" {
+		r.AddAttrs(slog.String("message_id", id))
+	}
+
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+type logContextKey string
+
+const (
+	requestIDKey logContextKey = "request_id"
+	messageIDKey logContextKey = "message_id"
+)
+
+// RequestIDHeader carries the request id in and out of the API.
+const RequestIDHeader = "X-Request-ID"
+
+// RequestID gives every request an id, reusing the caller's X-Request-ID when
+// it sent one, and echoes it in the response so clients can quote it.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if id == "" || len(id) > 128 {
+			id = uuid.New().String()
+		}
+
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey, id)))
+	})
 }
 
-func LogInfo(msg string, inf *log.Logger, params ...any) {
-	logger(inf, msg, params...)
+// RequestIDFromContext returns the id RequestID gave the request, if any.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
 }
 
-func LogError(msg string, err *log.Logger, params ...any) {
-	logger(err, msg, params...)
+// WithMessageID tags ctx with the id of the queue message being handled, so
+// every line logged while handling it can be found.
+func WithMessageID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, messageIDKey, id)
 }
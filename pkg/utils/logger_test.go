@@ -2,41 +2,149 @@ package utils
 
 import (
 	"bytes"
-	"log"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
 )
 
-func TestLogInfoAndError(t *testing.T) {
+// decodeLines parses every JSON log line written to buf.
+func decodeLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+
+	var lines []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+
+		var entry map[string]any
+		assert.NoError(t, json.Unmarshal([]byte(line), &entry))
+		lines = append(lines, entry)
+	}
+
+	return lines
+}
+
+func TestNewLogHandler_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewLogHandler(&buf, entities.LoggerConfig{}))
+
+	logger.Info("room created", "room_id", 7)
+
+	lines := decodeLines(t, &buf)
+	assert.Len(t, lines, 1)
+	assert.Equal(t, "INFO", lines[0]["level"])
+	assert.Equal(t, "room created", lines[0]["msg"])
+	assert.Equal(t, float64(7), lines[0]["room_id"])
+}
+
+func TestNewLogHandler_Level(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewLogHandler(&buf, entities.LoggerConfig{Level: "warn"}))
+
+	logger.Debug("hidden")
+	logger.Info("hidden")
+	logger.Warn("shown")
+	logger.Error("shown")
+
+	assert.Len(t, decodeLines(t, &buf), 2)
+}
+
+func TestNewLogHandler_Text(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewLogHandler(&buf, entities.LoggerConfig{Handler: "text"}))
+
+	logger.Info("room created")
+
+	assert.Contains(t, buf.String(), `msg="room created"`)
+}
+
+func TestNewLogHandler_ContextIDs(t *testing.T) {
 	var buf bytes.Buffer
-	l := log.New(&buf, "", 0)
+	logger := slog.New(NewLogHandler(&buf, entities.LoggerConfig{}))
+
+	r := chi.NewRouter()
+	r.Use(RequestID)
+	r.Get("/rooms/{room_id}", func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), entities.UseridKeyValue, "42")
+		logger.InfoContext(ctx, "room fetched")
+	})
 
-	LogInfo("hello %s", l, "world")
-	assert.Contains(t, buf.String(), "hello world")
+	req := httptest.NewRequest(http.MethodGet, "/rooms/7", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := decodeLines(t, &buf)
+	assert.Len(t, lines, 1)
+	assert.Equal(t, "req-1", lines[0]["request_id"])
+	assert.Equal(t, "42", lines[0]["user_id"])
+	assert.Equal(t, "/rooms/{room_id}", lines[0]["route"])
 
 	buf.Reset()
-	LogError("error %d", l, 42)
-	assert.Contains(t, buf.String(), "error 42")
+	logger.InfoContext(WithMessageID(context.Background(), "msg-1"), "payment consumed")
+
+	lines = decodeLines(t, &buf)
+	assert.Equal(t, "msg-1", lines[0]["message_id"])
+	assert.NotContains(t, lines[0], "request_id")
 }
 
-func TestInitLogger_NonProd(t *testing.T) {
-	// default (no ENV=prod) should just point log output at stderr and return nil
-	old := os.Getenv("ENV")
-	defer os.Setenv("ENV", old)
+func TestRequestID(t *testing.T) {
+	var seen string
+	handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestIDFromContext(r.Context())
+	}))
 
-	os.Unsetenv("ENV")
-	err := InitLogger("")
-	assert.NoError(t, err)
+	t.Run("reuses incoming id", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(RequestIDHeader, "abc")
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, "abc", seen)
+		assert.Equal(t, "abc", rr.Header().Get(RequestIDHeader))
+	})
+
+	t.Run("generates id", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		assert.NotEmpty(t, seen)
+		assert.Equal(t, seen, rr.Header().Get(RequestIDHeader))
+	})
+
+	t.Run("replaces oversized id", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(RequestIDHeader, strings.Repeat("a", 200))
+
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		assert.Len(t, seen, 36)
+	})
 }
 
-func TestInitLogger_ProdInvalidFolder(t *testing.T) {
-	old := os.Getenv("ENV")
-	defer os.Setenv("ENV", old)
+func TestInitLogger_File(t *testing.T) {
+	prev := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(prev) })
 
-	os.Setenv("ENV", "prod")
-	// rotatelogs typically still constructs a writer for a relative folder, so we
-	// only assert it does not panic and returns without crashing.
-	_ = InitLogger(t.TempDir())
+	dir := t.TempDir()
+	err := InitLogger(entities.LoggerConfig{Writer: "file", Folder: dir})
+	assert.NoError(t, err)
+
+	slog.Info("written to file")
+
+	data, err := os.ReadFile(filepath.Join(dir, "app.log"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"msg":"written to file"`)
 }
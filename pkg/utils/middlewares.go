@@ -3,41 +3,81 @@ package utils
 import (
 	"context"
 	"errors"
+	"log/slog"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/jwtkeys"
 )
 
-func AuthMiddleware(secret string) func(http.Handler) http.Handler {
+// SessionChecker reports when a user's auth tokens start being accepted:
+// tokens issued before their last password change or account deletion are
+// refused. It returns entities.ErrNoRecord for deleted accounts.
+type SessionChecker interface {
+	SessionsValidFrom(ctx context.Context, userID int) (time.Time, error)
+}
+
+func AuthMiddleware(keys *jwtkeys.Keyring, sessions SessionChecker) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			header := r.Header.Get("Authorization")
 
 			if len(header) == 0 {
-				LogError("missing authorization header", entities.ErrorLog, http.StatusUnauthorized)
+				slog.WarnContext(r.Context(), "missing authorization header")
 				ErrorJSON(w, errors.New("missing authorization header"), http.StatusUnauthorized)
 				return
 			}
 
 			parts := strings.Split(header, " ")
 			if len(parts) != 2 || parts[0] != "Bearer" {
-				LogError("Invalid authorization header", entities.ErrorLog, http.StatusUnauthorized)
+				slog.WarnContext(r.Context(), "invalid authorization header")
 				ErrorJSON(w, errors.New("invalid authorization header"), http.StatusUnauthorized)
 				return
 			}
 
-			claims, err := verifyAuthToken(parts[1], secret)
+			claims, err := verifyAuthToken(parts[1], keys)
 			if err != nil {
-				LogError("Invalid authorization token", entities.ErrorLog)
+				slog.WarnContext(r.Context(), "invalid authorization token", "error", err)
 				ErrorJSON(w, errors.New("invalid authorization token"), http.StatusUnauthorized)
 				return
 			}
 
+			if claims.MFAPending {
+				slog.WarnContext(r.Context(), "mfa pending token used as auth token")
+				ErrorJSON(w, entities.ErrMFARequired, http.StatusUnauthorized)
+				return
+			}
+
+			userID, err := strconv.Atoi(claims.UserID)
+			if err != nil {
+				slog.WarnContext(r.Context(), "auth token has invalid user id", "error", err)
+				ErrorJSON(w, errors.New("invalid authorization token"), http.StatusUnauthorized)
+				return
+			}
+
+			validFrom, err := sessions.SessionsValidFrom(r.Context(), userID)
+			if err != nil && !errors.Is(err, entities.ErrNoRecord) {
+				slog.ErrorContext(r.Context(), "could not check session", "error", err)
+				ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
+				return
+			}
+
+			// Token times are whole seconds in UTC, as is validFrom.
+			if err != nil || claims.IssuedAt == nil || claims.IssuedAt.Before(validFrom) {
+				slog.WarnContext(r.Context(), "revoked auth token used", "user_id", userID)
+				ErrorJSON(w, entities.ErrSessionRevoked, http.StatusUnauthorized)
+				return
+			}
+
 			ctx := context.WithValue(r.Context(), entities.UsernameKeyValue, claims.Username)
 			ctx = context.WithValue(ctx, entities.IsVendorKeyValue, claims.IsVendor)
 			ctx = context.WithValue(ctx, entities.UseridKeyValue, claims.UserID)
 			ctx = context.WithValue(ctx, entities.PhoneNumberKeyValue, claims.PhoneNumber)
+			ctx = context.WithValue(ctx, entities.MFAKeyValue, claims.MFA)
 
 			next.ServeHTTP(w, r.WithContext(ctx))
 
@@ -45,23 +85,129 @@ func AuthMiddleware(secret string) func(http.Handler) http.Handler {
 	}
 }
 
+// AdminMiddlware lets through vendors whose token was issued after a second
+// factor.
 func AdminMiddlware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		isVendor, ok := r.Context().Value(entities.IsVendorKeyValue).(string)
 		if !ok {
-			LogError("error getting role from context", entities.ErrorLog)
+			slog.ErrorContext(r.Context(), "role missing from context")
 			ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
 			return
 		}
 
 		if isVendor != "YES" {
-			LogError("unauthorized access for user", entities.ErrorLog)
+			slog.WarnContext(r.Context(), "non-vendor denied admin access")
 			ErrorJSON(w, errors.New("unauthorized access"), http.StatusForbidden)
 			return
 
 		}
 
+		// API keys are created from a two-factor session, so requests made
+		// with one count as having passed it.
+		_, apiKey := r.Context().Value(entities.ScopesKeyValue).([]string)
+		mfa, _ := r.Context().Value(entities.MFAKeyValue).(bool)
+		if !mfa && !apiKey {
+			slog.WarnContext(r.Context(), "vendor without mfa denied admin access")
+			ErrorJSON(w, entities.ErrMFARequired, http.StatusForbidden)
+			return
+		}
+
 		next.ServeHTTP(w, r)
 
 	})
 }
+
+// APIKeyVerifier resolves an X-API-Key header to a live key.
+type APIKeyVerifier interface {
+	VerifyAPIKey(ctx context.Context, key string) (*entities.APIKey, error)
+}
+
+// AuthOrAPIKeyMiddleware authenticates with the X-API-Key header when it is
+// set, acting as the key's vendor limited to its scopes, and with a bearer
+// token like AuthMiddleware otherwise.
+func AuthOrAPIKeyMiddleware(tokenKeys *jwtkeys.Keyring, sessions SessionChecker, keys APIKeyVerifier) func(http.Handler) http.Handler {
+	bearer := AuthMiddleware(tokenKeys, sessions)
+
+	return func(next http.Handler) http.Handler {
+		withToken := bearer(next)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := r.Header.Get("X-API-Key")
+			if len(header) == 0 {
+				withToken.ServeHTTP(w, r)
+				return
+			}
+
+			key, err := keys.VerifyAPIKey(r.Context(), header)
+			if errors.Is(err, entities.ErrInvalidAPIKey) {
+				slog.WarnContext(r.Context(), "invalid api key")
+				ErrorJSON(w, err, http.StatusUnauthorized)
+				return
+			}
+			if err != nil {
+				slog.ErrorContext(r.Context(), "could not verify api key", "error", err)
+				ErrorJSON(w, errors.New("an error occured"), http.StatusInternalServerError)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), entities.IsVendorKeyValue, "YES")
+			ctx = context.WithValue(ctx, entities.UseridKeyValue, strconv.Itoa(key.VendorID))
+			ctx = context.WithValue(ctx, entities.ScopesKeyValue, key.Scopes)
+			ctx = context.WithValue(ctx, entities.APIKeyIDKeyValue, key.ID)
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequireScope rejects API key requests whose key lacks scope. Requests
+// made with a bearer token are not limited by scopes.
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scopes, apiKey := r.Context().Value(entities.ScopesKeyValue).([]string)
+			if apiKey && !slices.Contains(scopes, scope) {
+				slog.WarnContext(r.Context(), "api key missing scope", "scope", scope)
+				ErrorJSON(w, entities.ErrScopeMissing, http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequirePlatformAdmin lets through only the users in admins, the platform's
+// own operators. Being a vendor is not enough, since anyone can sign up as one.
+func RequirePlatformAdmin(admins []int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, _ := r.Context().Value(entities.UseridKeyValue).(string)
+			id, err := strconv.Atoi(userID)
+			if err != nil || !slices.Contains(admins, id) {
+				slog.WarnContext(r.Context(), "non-admin denied platform admin route", "user_id", userID)
+				ErrorJSON(w, entities.ErrPlatformAdminOnly, http.StatusForbidden)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), entities.PlatformAdminKeyValue, true)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequireSession rejects API key requests, for routes that only a logged
+// in vendor may use, such as managing the keys themselves.
+func RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, apiKey := r.Context().Value(entities.ScopesKeyValue).([]string)
+		if apiKey {
+			slog.WarnContext(r.Context(), "api key used on session only route")
+			ErrorJSON(w, entities.ErrAPIKeyNotAllowed, http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -2,11 +2,14 @@ package utils
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/jwtkeys"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -14,8 +17,17 @@ func contextWithVendor(r *http.Request, val string) context.Context {
 	return context.WithValue(r.Context(), entities.IsVendorKeyValue, val)
 }
 
+type stubSessions struct {
+	validFrom time.Time
+	err       error
+}
+
+func (s stubSessions) SessionsValidFrom(ctx context.Context, userID int) (time.Time, error) {
+	return s.validFrom, s.err
+}
+
 func TestAuthMiddleware(t *testing.T) {
-	secret := "test-secret"
+	secret := jwtkeys.NewHMAC("test-secret")
 
 	// a downstream handler that records the context values it received
 	makeNext := func(captured *entities.Claims) http.Handler {
@@ -33,7 +45,7 @@ func TestAuthMiddleware(t *testing.T) {
 		w := httptest.NewRecorder()
 
 		var captured entities.Claims
-		AuthMiddleware(secret)(makeNext(&captured)).ServeHTTP(w, req)
+		AuthMiddleware(secret, stubSessions{})(makeNext(&captured)).ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusUnauthorized, w.Code)
 	})
@@ -44,7 +56,7 @@ func TestAuthMiddleware(t *testing.T) {
 		w := httptest.NewRecorder()
 
 		var captured entities.Claims
-		AuthMiddleware(secret)(makeNext(&captured)).ServeHTTP(w, req)
+		AuthMiddleware(secret, stubSessions{})(makeNext(&captured)).ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusUnauthorized, w.Code)
 	})
@@ -55,7 +67,7 @@ func TestAuthMiddleware(t *testing.T) {
 		w := httptest.NewRecorder()
 
 		var captured entities.Claims
-		AuthMiddleware(secret)(makeNext(&captured)).ServeHTTP(w, req)
+		AuthMiddleware(secret, stubSessions{})(makeNext(&captured)).ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusUnauthorized, w.Code)
 	})
@@ -75,7 +87,7 @@ func TestAuthMiddleware(t *testing.T) {
 		w := httptest.NewRecorder()
 
 		var captured entities.Claims
-		AuthMiddleware(secret)(makeNext(&captured)).ServeHTTP(w, req)
+		AuthMiddleware(secret, stubSessions{})(makeNext(&captured)).ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusOK, w.Code)
 		assert.Equal(t, "user@example.com", captured.Username)
@@ -83,6 +95,47 @@ func TestAuthMiddleware(t *testing.T) {
 		assert.Equal(t, "5", captured.UserID)
 		assert.Equal(t, "0700000000", captured.PhoneNumber)
 	})
+
+	t.Run("mfa pending token refused", func(t *testing.T) {
+		token, err := GenerateMFAPendingToken(entities.User{ID: "5", Email: "user@example.com", IsVender: "YES"}, secret)
+		assert.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+
+		var captured entities.Claims
+		AuthMiddleware(secret, stubSessions{})(makeNext(&captured)).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Empty(t, captured.UserID)
+	})
+
+	t.Run("ended sessions", func(t *testing.T) {
+		token, err := GenerateAuthToken(entities.User{ID: "5", Email: "user@example.com"}, secret)
+		assert.NoError(t, err)
+
+		cases := map[string]struct {
+			sessions stubSessions
+			want     int
+		}{
+			"password changed since":  {stubSessions{validFrom: time.Now().Add(time.Minute)}, http.StatusUnauthorized},
+			"account deleted":         {stubSessions{err: entities.ErrNoRecord}, http.StatusUnauthorized},
+			"lookup failed":           {stubSessions{err: errors.New("db down")}, http.StatusInternalServerError},
+			"password changed before": {stubSessions{validFrom: time.Now().Add(-time.Minute)}, http.StatusOK},
+		}
+		for name, tc := range cases {
+			t.Run(name, func(t *testing.T) {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.Header.Set("Authorization", "Bearer "+token)
+				w := httptest.NewRecorder()
+
+				var captured entities.Claims
+				AuthMiddleware(secret, tc.sessions)(makeNext(&captured)).ServeHTTP(w, req)
+				assert.Equal(t, tc.want, w.Code)
+			})
+		}
+	})
 }
 
 func TestAdminMiddleware(t *testing.T) {
@@ -107,12 +160,157 @@ func TestAdminMiddleware(t *testing.T) {
 		assert.Equal(t, http.StatusForbidden, w.Code)
 	})
 
-	t.Run("vendor allowed", func(t *testing.T) {
+	t.Run("vendor without mfa forbidden", func(t *testing.T) {
 		req := httptest.NewRequest(http.MethodGet, "/", nil)
 		ctx := contextWithVendor(req, "YES")
 		w := httptest.NewRecorder()
 
 		AdminMiddlware(next).ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("vendor allowed", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		ctx := context.WithValue(contextWithVendor(req, "YES"), entities.MFAKeyValue, true)
+		w := httptest.NewRecorder()
+
+		AdminMiddlware(next).ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("api key allowed", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		ctx := context.WithValue(contextWithVendor(req, "YES"), entities.ScopesKeyValue, []string{})
+		w := httptest.NewRecorder()
+
+		AdminMiddlware(next).ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
+type stubKeys struct {
+	key *entities.APIKey
+	err error
+}
+
+func (s stubKeys) VerifyAPIKey(ctx context.Context, key string) (*entities.APIKey, error) {
+	return s.key, s.err
+}
+
+func TestAuthOrAPIKeyMiddleware(t *testing.T) {
+	secret := jwtkeys.NewHMAC("test-secret")
+
+	var userID string
+	var scopes []string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, _ = r.Context().Value(entities.UseridKeyValue).(string)
+		scopes, _ = r.Context().Value(entities.ScopesKeyValue).([]string)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	t.Run("api key sets vendor and scopes", func(t *testing.T) {
+		keys := stubKeys{key: &entities.APIKey{VendorID: 7, Scopes: []string{entities.ScopeRoomsRead}}}
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-API-Key", "bk_abcdef123456_secret")
+		w := httptest.NewRecorder()
+
+		AuthOrAPIKeyMiddleware(secret, stubSessions{}, keys)(next).ServeHTTP(w, req)
 		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "7", userID)
+		assert.Equal(t, []string{entities.ScopeRoomsRead}, scopes)
+	})
+
+	t.Run("invalid api key", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-API-Key", "bk_abcdef123456_wrong")
+		w := httptest.NewRecorder()
+
+		AuthOrAPIKeyMiddleware(secret, stubSessions{}, stubKeys{err: entities.ErrInvalidAPIKey})(next).ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("falls back to bearer token", func(t *testing.T) {
+		token, err := GenerateMFAAuthToken(entities.User{ID: "5", Email: "user@example.com", IsVender: "YES"}, secret)
+		assert.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+
+		AuthOrAPIKeyMiddleware(secret, stubSessions{}, stubKeys{err: entities.ErrInvalidAPIKey})(next).ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "5", userID)
+		assert.Nil(t, scopes)
+	})
+
+	t.Run("neither", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		w := httptest.NewRecorder()
+
+		AuthOrAPIKeyMiddleware(secret, stubSessions{}, stubKeys{})(next).ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
 	})
 }
+
+func TestRequireScope(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	cases := map[string]struct {
+		ctx  context.Context
+		want int
+	}{
+		"session":        {context.Background(), http.StatusOK},
+		"key with scope": {context.WithValue(context.Background(), entities.ScopesKeyValue, []string{entities.ScopeRoomsWrite}), http.StatusOK},
+		"key without":    {context.WithValue(context.Background(), entities.ScopesKeyValue, []string{entities.ScopeRoomsRead}), http.StatusForbidden},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", nil).WithContext(tc.ctx)
+			w := httptest.NewRecorder()
+
+			RequireScope(entities.ScopeRoomsWrite)(next).ServeHTTP(w, req)
+			assert.Equal(t, tc.want, w.Code)
+		})
+	}
+
+	t.Run("session only", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		ctx := context.WithValue(req.Context(), entities.ScopesKeyValue, []string{entities.ScopeRoomsWrite})
+		w := httptest.NewRecorder()
+
+		RequireSession(next).ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
+
+func TestRequirePlatformAdmin(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		admin, _ := r.Context().Value(entities.PlatformAdminKeyValue).(bool)
+		assert.True(t, admin)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	cases := map[string]struct {
+		userID any
+		want   int
+	}{
+		"admin":      {"1", http.StatusOK},
+		"vendor":     {"7", http.StatusForbidden},
+		"no user id": {nil, http.StatusForbidden},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			ctx := contextWithVendor(req, "YES")
+			if tc.userID != nil {
+				ctx = context.WithValue(ctx, entities.UseridKeyValue, tc.userID)
+			}
+			w := httptest.NewRecorder()
+
+			RequirePlatformAdmin([]int{1, 2})(next).ServeHTTP(w, req.WithContext(ctx))
+			assert.Equal(t, tc.want, w.Code)
+		})
+	}
+}
@@ -0,0 +1,186 @@
+// Package webhook signs and sends webhook requests to URLs registered by
+// vendors.
+//
+// Every request carries a signature header of the form
+//
+//	t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>" keyed by the secret>
+//
+// so receivers can check both who sent it and that it is recent.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bicosteve/booking-system/pkg/safehttp"
+)
+
+const (
+	// SignatureHeader holds the timestamp and HMAC of the request.
+	SignatureHeader = "X-Webhook-Signature"
+	// EventHeader holds the event type, e.g. booking.created.
+	EventHeader = "X-Webhook-Event"
+	// DeliveryHeader identifies the delivery; retries of one delivery share it.
+	DeliveryHeader = "X-Webhook-Delivery"
+)
+
+var (
+	// ErrInvalidSignature is returned by Verify for a missing or wrong signature.
+	ErrInvalidSignature = errors.New("webhook: invalid signature")
+	// ErrPrivateAddress is returned when a URL resolves to an address that is
+	// not publicly routable.
+	ErrPrivateAddress = safehttp.ErrPrivateAddress
+)
+
+// NewSecret returns a random signing secret.
+func NewSecret() (string, error) {
+	b := make([]byte, 24)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// Sign returns the signature header value for body sent at t.
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + mac(secret, ts, body)
+}
+
+func mac(secret, ts string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(ts))
+	h.Write([]byte("."))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Verify checks a signature header against body and rejects signatures
+// older than tolerance. It is what receivers are expected to run.
+func Verify(secret, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	var ts, sig string
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(part, "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sig = v
+		}
+	}
+
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || sig == "" {
+		return ErrInvalidSignature
+	}
+
+	if now.Sub(time.Unix(sec, 0)).Abs() > tolerance {
+		return fmt.Errorf("%w: timestamp outside tolerance", ErrInvalidSignature)
+	}
+
+	if !hmac.Equal([]byte(sig), []byte(mac(secret, ts, body))) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// Backoff is the wait before retrying after attempt failed attempts: one
+// minute, doubling each time, capped at six hours.
+func Backoff(attempt int) time.Duration {
+	const max = 6 * time.Hour
+	if attempt < 1 {
+		attempt = 1
+	}
+	if attempt > 10 {
+		return max
+	}
+	return min(time.Minute<<(attempt-1), max)
+}
+
+// ValidateURL checks that raw is an absolute http(s) URL.
+func ValidateURL(raw string) error {
+	return safehttp.ValidateURL(raw)
+}
+
+// Request is one delivery attempt.
+type Request struct {
+	URL        string
+	Secret     string
+	DeliveryID string
+	EventType  string
+	Body       []byte
+}
+
+// maxResponseBody is how much of the receiver's answer is kept for the
+// delivery log.
+const maxResponseBody = 255
+
+// Response is what the receiver answered. Body is truncated.
+type Response struct {
+	StatusCode int
+	Body       string
+}
+
+// OK reports whether the receiver accepted the delivery.
+func (r Response) OK() bool {
+	return r.StatusCode >= 200 && r.StatusCode < 300
+}
+
+// Sender posts signed webhook requests.
+type Sender struct {
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewSender returns a Sender whose requests time out after timeout. Unless
+// allowPrivate is set, it refuses to connect to loopback, private and
+// link-local addresses, so vendors cannot make the app call internal
+// services.
+func NewSender(timeout time.Duration, allowPrivate bool) *Sender {
+	client := safehttp.NewClient(timeout, allowPrivate)
+	// Redirects could point anywhere; receivers must answer directly.
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	return &Sender{client: client, now: time.Now}
+}
+
+// Send posts req and returns the receiver's response. A non-2xx response is
+// not an error; err is set only when no response was received.
+func (s *Sender) Send(ctx context.Context, req Request) (Response, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, req.URL, bytes.NewReader(req.Body))
+	if err != nil {
+		return Response{}, err
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("User-Agent", "booking-system-webhooks/1")
+	httpReq.Header.Set(SignatureHeader, Sign(req.Secret, s.now(), req.Body))
+	httpReq.Header.Set(EventHeader, req.EventType)
+	httpReq.Header.Set(DeliveryHeader, req.DeliveryID)
+
+	resp, err := s.client.Do(httpReq)
+	if err != nil {
+		return Response{}, err
+	}
+
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+
+	return Response{StatusCode: resp.StatusCode, Body: string(body)}, nil
+}
@@ -0,0 +1,127 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"type":"booking.created"}`)
+	header := Sign("whsec_test", now, body)
+
+	assert.True(t, strings.HasPrefix(header, "t=1700000000,v1="))
+	assert.NoError(t, Verify("whsec_test", header, body, 5*time.Minute, now.Add(time.Minute)))
+
+	assert.ErrorIs(t, Verify("whsec_other", header, body, 5*time.Minute, now), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify("whsec_test", header, []byte(`{}`), 5*time.Minute, now), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify("whsec_test", header, body, 5*time.Minute, now.Add(10*time.Minute)), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify("whsec_test", "", body, 5*time.Minute, now), ErrInvalidSignature)
+}
+
+func TestNewSecret(t *testing.T) {
+	a, err := NewSecret()
+	require.NoError(t, err)
+	b, err := NewSecret()
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(a, "whsec_"))
+	assert.Len(t, a, len("whsec_")+48)
+	assert.NotEqual(t, a, b)
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, time.Minute, Backoff(0))
+	assert.Equal(t, time.Minute, Backoff(1))
+	assert.Equal(t, 2*time.Minute, Backoff(2))
+	assert.Equal(t, 16*time.Minute, Backoff(5))
+	assert.Equal(t, 6*time.Hour, Backoff(10))
+	assert.Equal(t, 6*time.Hour, Backoff(100))
+}
+
+func TestValidateURL(t *testing.T) {
+	assert.NoError(t, ValidateURL("https://pms.example.com/hooks"))
+	assert.NoError(t, ValidateURL("http://pms.example.com:8080/hooks"))
+	assert.Error(t, ValidateURL("ftp://pms.example.com"))
+	assert.Error(t, ValidateURL("https://"))
+	assert.Error(t, ValidateURL("not a url"))
+	assert.Error(t, ValidateURL("https://example.com/"+strings.Repeat("a", 2048)))
+}
+
+func TestSender_Send(t *testing.T) {
+	var got *http.Request
+	var gotBody []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	s := NewSender(time.Second, true)
+	body := []byte(`{"type":"booking.created"}`)
+
+	resp, err := s.Send(context.Background(), Request{
+		URL:        srv.URL,
+		Secret:     "whsec_test",
+		DeliveryID: "42",
+		EventType:  "booking.created",
+		Body:       body,
+	})
+	require.NoError(t, err)
+
+	assert.True(t, resp.OK())
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	assert.Equal(t, "ok", resp.Body)
+	assert.Equal(t, body, gotBody)
+	assert.Equal(t, "42", got.Header.Get(DeliveryHeader))
+	assert.Equal(t, "booking.created", got.Header.Get(EventHeader))
+	assert.NoError(t, Verify("whsec_test", got.Header.Get(SignatureHeader), gotBody, time.Minute, time.Now()))
+}
+
+func TestSender_SendErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(strings.Repeat("x", 1000)))
+	}))
+	defer srv.Close()
+
+	resp, err := NewSender(time.Second, true).Send(context.Background(), Request{URL: srv.URL, Body: []byte(`{}`)})
+	require.NoError(t, err)
+
+	assert.False(t, resp.OK())
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Len(t, resp.Body, maxResponseBody)
+}
+
+func TestSender_NoRedirects(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://169.254.169.254/", http.StatusFound)
+	}))
+	defer srv.Close()
+
+	resp, err := NewSender(time.Second, true).Send(context.Background(), Request{URL: srv.URL, Body: []byte(`{}`)})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+	assert.False(t, resp.OK())
+}
+
+func TestSender_RefusesPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request reached a loopback server")
+	}))
+	defer srv.Close()
+
+	_, err := NewSender(time.Second, false).Send(context.Background(), Request{URL: srv.URL, Body: []byte(`{}`)})
+	assert.True(t, errors.Is(err, ErrPrivateAddress), "got %v", err)
+}
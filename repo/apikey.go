@@ -0,0 +1,168 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/bicosteve/booking-system/entities"
+)
+
+type APIKeyRepository interface {
+	CreateAPIKey(ctx context.Context, key *entities.APIKey) (int, error)
+	GetVendorAPIKeys(ctx context.Context, vendorID int) ([]*entities.APIKey, error)
+	GetAPIKeyByPrefix(ctx context.Context, prefix string) (*entities.APIKey, error)
+	RevokeAPIKey(ctx context.Context, keyID, vendorID int) error
+	TouchAPIKey(ctx context.Context, keyID int) error
+}
+
+const selectAPIKey = `SELECT key_id, vendor_id, name, prefix, secret_hash, scopes, expires_at, last_used_at, revoked_at, created_at
+	FROM api_key`
+
+func scanAPIKey(row interface{ Scan(...any) error }) (*entities.APIKey, error) {
+	var k entities.APIKey
+	var scopes string
+	var lastUsedAt, revokedAt sql.NullTime
+
+	err := row.Scan(&k.ID, &k.VendorID, &k.Name, &k.Prefix, &k.SecretHash, &scopes, &k.ExpiresAt, &lastUsedAt, &revokedAt, &k.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	k.Scopes = splitEvents(scopes)
+	if lastUsedAt.Valid {
+		k.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		k.RevokedAt = &revokedAt.Time
+	}
+
+	return &k, nil
+}
+
+func (r *Repository) CreateAPIKey(ctx context.Context, key *entities.APIKey) (int, error) {
+	q := `INSERT INTO api_key(vendor_id, name, prefix, secret_hash, scopes, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, NOW())`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return 0, err
+	}
+
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, key.VendorID, key.Name, key.Prefix, key.SecretHash, strings.Join(key.Scopes, ","), key.ExpiresAt)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// GetVendorAPIKeys returns all of vendorID's keys, revoked and expired ones
+// included, newest first.
+func (r *Repository) GetVendorAPIKeys(ctx context.Context, vendorID int) ([]*entities.APIKey, error) {
+	stmt, err := r.db.PrepareContext(ctx, selectAPIKey+` WHERE vendor_id = ? ORDER BY key_id DESC`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, vendorID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	keys := []*entities.APIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+
+	return keys, rows.Err()
+}
+
+// GetAPIKeyByPrefix returns the key with prefix, or ErrNoRecord. Revoked and
+// expired keys are returned too; the caller decides whether they are usable.
+// Keys of deleted accounts are never returned.
+func (r *Repository) GetAPIKeyByPrefix(ctx context.Context, prefix string) (*entities.APIKey, error) {
+	stmt, err := r.db.PrepareContext(ctx, selectAPIKey+` WHERE prefix = ?
+		AND EXISTS (SELECT 1 FROM user WHERE user.user_id = api_key.vendor_id AND user.deleted_at IS NULL)`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	k, err := scanAPIKey(stmt.QueryRowContext(ctx, prefix))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, entities.ErrNoRecord
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
+
+// RevokeAPIKey revokes one of vendorID's keys. It returns ErrNoRecord if the
+// vendor has no such key or it was already revoked.
+func (r *Repository) RevokeAPIKey(ctx context.Context, keyID, vendorID int) error {
+	q := `UPDATE api_key SET revoked_at = NOW() WHERE key_id = ? AND vendor_id = ? AND revoked_at IS NULL`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, keyID, vendorID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return entities.ErrNoRecord
+	}
+
+	return nil
+}
+
+// TouchAPIKey records that the key was just used. It writes at most once a
+// minute per key so busy scripts do not turn every request into a write.
+func (r *Repository) TouchAPIKey(ctx context.Context, keyID int) error {
+	q := `UPDATE api_key SET last_used_at = NOW()
+		WHERE key_id = ? AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL 1 MINUTE)`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, keyID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
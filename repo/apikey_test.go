@@ -0,0 +1,131 @@
+package repo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+var apiKeyColumns = []string{"key_id", "vendor_id", "name", "prefix", "secret_hash", "scopes", "expires_at",
+	"last_used_at", "revoked_at", "created_at"}
+
+func TestCreateAPIKey(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewDBRepository(db, nil)
+	expires := time.Now().Add(24 * time.Hour)
+
+	mock.ExpectPrepare("INSERT INTO api_key").
+		ExpectExec().
+		WithArgs(7, "pms", "abcdef123456", "hash", "rooms:write,bookings:read", expires).
+		WillReturnResult(sqlmock.NewResult(4, 1))
+
+	id, err := repo.CreateAPIKey(context.Background(), &entities.APIKey{
+		VendorID:   7,
+		Name:       "pms",
+		Prefix:     "abcdef123456",
+		SecretHash: "hash",
+		Scopes:     []string{"rooms:write", "bookings:read"},
+		ExpiresAt:  expires,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, id)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetAPIKeyByPrefix(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewDBRepository(db, nil)
+	now := time.Now()
+
+	mock.ExpectPrepare("SELECT key_id(.|\\s)+WHERE prefix = \\?(.|\\s)+user.deleted_at IS NULL").
+		ExpectQuery().
+		WithArgs("abcdef123456").
+		WillReturnRows(sqlmock.NewRows(apiKeyColumns).
+			AddRow(4, 7, "pms", "abcdef123456", "hash", "rooms:write", now, nil, now, now))
+	mock.ExpectPrepare("SELECT key_id").
+		ExpectQuery().
+		WithArgs("missing").
+		WillReturnRows(sqlmock.NewRows(apiKeyColumns))
+
+	k, err := repo.GetAPIKeyByPrefix(context.Background(), "abcdef123456")
+	assert.NoError(t, err)
+	assert.Equal(t, 7, k.VendorID)
+	assert.Equal(t, []string{"rooms:write"}, k.Scopes)
+	assert.Nil(t, k.LastUsedAt)
+	assert.NotNil(t, k.RevokedAt)
+
+	_, err = repo.GetAPIKeyByPrefix(context.Background(), "missing")
+	assert.ErrorIs(t, err, entities.ErrNoRecord)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetVendorAPIKeys(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewDBRepository(db, nil)
+	now := time.Now()
+
+	mock.ExpectPrepare("SELECT key_id").
+		ExpectQuery().
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows(apiKeyColumns).
+			AddRow(5, 7, "ci", "bbbbbbbbbbbb", "hash", "", now, now, nil, now).
+			AddRow(4, 7, "pms", "abcdef123456", "hash", "rooms:write,rooms:read", now, nil, nil, now))
+
+	keys, err := repo.GetVendorAPIKeys(context.Background(), 7)
+	assert.NoError(t, err)
+	assert.Len(t, keys, 2)
+	assert.Equal(t, []string{}, keys[0].Scopes)
+	assert.NotNil(t, keys[0].LastUsedAt)
+	assert.Equal(t, []string{"rooms:write", "rooms:read"}, keys[1].Scopes)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewDBRepository(db, nil)
+
+	mock.ExpectPrepare("UPDATE api_key SET revoked_at").
+		ExpectExec().
+		WithArgs(4, 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectPrepare("UPDATE api_key SET revoked_at").
+		ExpectExec().
+		WithArgs(4, 8).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, repo.RevokeAPIKey(context.Background(), 4, 7))
+	assert.ErrorIs(t, repo.RevokeAPIKey(context.Background(), 4, 8), entities.ErrNoRecord)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTouchAPIKey(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewDBRepository(db, nil)
+
+	mock.ExpectPrepare("UPDATE api_key SET last_used_at = NOW\\(\\) (.+) INTERVAL 1 MINUTE").
+		ExpectExec().
+		WithArgs(4).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, repo.TouchAPIKey(context.Background(), 4))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,155 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/bicosteve/booking-system/entities"
+)
+
+type AuditRepository interface {
+	InsertAuditEntries(ctx context.Context, entries []entities.AuditEntry) error
+	GetAuditEntries(ctx context.Context, filter entities.AuditFilter) ([]*entities.AuditEntry, error)
+}
+
+// InsertAuditEntries appends entries to the audit log in one statement. The
+// table has no update or delete path; triggers reject both.
+func (r *Repository) InsertAuditEntries(ctx context.Context, entries []entities.AuditEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	q := `INSERT INTO audit_log(actor_type, actor_id, api_key_id, vendor_id, action, entity_type, entity_id,
+			before_state, after_state, ip, request_id, created_at) VALUES `
+
+	rows := make([]string, 0, len(entries))
+	args := make([]any, 0, len(entries)*11)
+
+	for _, e := range entries {
+		rows = append(rows, "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NOW(3))")
+		args = append(args, e.ActorType, e.ActorID, e.APIKeyID, e.VendorID, e.Action, e.EntityType, e.EntityID,
+			nullJSON(e.Before), nullJSON(e.After), e.IP, e.RequestID)
+	}
+
+	stmt, err := r.db.PrepareContext(ctx, q+strings.Join(rows, ", "))
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, args...)
+
+	return err
+}
+
+// GetAuditEntries returns the vendor's audit entries matching filter,
+// newest first, along with platform admins' entries for a platform admin.
+func (r *Repository) GetAuditEntries(ctx context.Context, filter entities.AuditFilter) ([]*entities.AuditEntry, error) {
+	q := `SELECT audit_id, actor_type, actor_id, api_key_id, vendor_id, action, entity_type, entity_id,
+			before_state, after_state, ip, request_id, created_at
+			FROM audit_log`
+	args := []any{filter.VendorID}
+
+	if filter.PlatformAdmin {
+		q += ` WHERE (vendor_id = ? OR actor_type = ?)`
+		args = append(args, entities.ActorPlatformAdmin)
+	} else {
+		q += ` WHERE vendor_id = ?`
+	}
+
+	if filter.ActorID != nil {
+		q += ` AND actor_id = ?`
+		args = append(args, *filter.ActorID)
+	}
+
+	if filter.Action != "" {
+		q += ` AND action = ?`
+		args = append(args, filter.Action)
+	}
+
+	if filter.EntityType != "" {
+		q += ` AND entity_type = ?`
+		args = append(args, filter.EntityType)
+	}
+
+	if filter.EntityID != "" {
+		q += ` AND entity_id = ?`
+		args = append(args, filter.EntityID)
+	}
+
+	if !filter.From.IsZero() {
+		q += ` AND created_at >= ?`
+		args = append(args, filter.From)
+	}
+
+	if !filter.To.IsZero() {
+		q += ` AND created_at < ?`
+		args = append(args, filter.To)
+	}
+
+	if filter.BeforeID > 0 {
+		q += ` AND audit_id < ?`
+		args = append(args, filter.BeforeID)
+	}
+
+	q += ` ORDER BY audit_id DESC LIMIT ?`
+	args = append(args, filter.Limit)
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	entries := []*entities.AuditEntry{}
+	for rows.Next() {
+		var e entities.AuditEntry
+		var actorID, keyID, vendorID sql.NullInt64
+		var before, after []byte
+
+		err = rows.Scan(&e.ID, &e.ActorType, &actorID, &keyID, &vendorID, &e.Action, &e.EntityType, &e.EntityID,
+			&before, &after, &e.IP, &e.RequestID, &e.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		e.ActorID = nullIntPtr(actorID)
+		e.APIKeyID = nullIntPtr(keyID)
+		e.VendorID = nullIntPtr(vendorID)
+		e.Before = before
+		e.After = after
+
+		entries = append(entries, &e)
+	}
+
+	return entries, rows.Err()
+}
+
+// nullJSON stores an empty diff side as NULL rather than an empty string,
+// which a JSON column rejects.
+func nullJSON(raw []byte) any {
+	if len(raw) == 0 {
+		return nil
+	}
+
+	return string(raw)
+}
+
+func nullIntPtr(n sql.NullInt64) *int {
+	if !n.Valid {
+		return nil
+	}
+
+	v := int(n.Int64)
+	return &v
+}
@@ -0,0 +1,114 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInsertAuditEntries(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewDBRepository(db, nil)
+	actorID, keyID, vendorID := 7, 4, 7
+
+	mock.ExpectPrepare("INSERT INTO audit_log\\(.+\\s+.+\\) VALUES \\(.+NOW\\(3\\)\\), \\(.+NOW\\(3\\)\\)$").
+		ExpectExec().
+		WithArgs(entities.ActorAPIKey, actorID, keyID, vendorID, entities.AuditRoomUpdate, "room", "3",
+			`{"cost":100}`, `{"cost":150}`, "10.0.0.1", "req-1",
+			entities.ActorSystem, nil, nil, vendorID, entities.AuditPayoutSettle, "payout", "9",
+			nil, `{"status":"PAID"}`, "", "").
+		WillReturnResult(sqlmock.NewResult(1, 2))
+
+	err = repo.InsertAuditEntries(context.Background(), []entities.AuditEntry{
+		{ActorType: entities.ActorAPIKey, ActorID: &actorID, APIKeyID: &keyID, VendorID: &vendorID,
+			Action: entities.AuditRoomUpdate, EntityType: "room", EntityID: "3",
+			Before: json.RawMessage(`{"cost":100}`), After: json.RawMessage(`{"cost":150}`), IP: "10.0.0.1", RequestID: "req-1"},
+		{ActorType: entities.ActorSystem, VendorID: &vendorID, Action: entities.AuditPayoutSettle, EntityType: "payout",
+			EntityID: "9", After: json.RawMessage(`{"status":"PAID"}`)},
+	})
+	assert.NoError(t, err)
+
+	// Nothing to write makes no query.
+	assert.NoError(t, repo.InsertAuditEntries(context.Background(), nil))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetAuditEntries(t *testing.T) {
+	columns := []string{"audit_id", "actor_type", "actor_id", "api_key_id", "vendor_id", "action", "entity_type", "entity_id",
+		"before_state", "after_state", "ip", "request_id", "created_at"}
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+	actorID := 7
+
+	t.Run("all filters", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		repo := NewDBRepository(db, nil)
+		mock.ExpectPrepare("WHERE vendor_id = \\? AND actor_id = \\? AND action = \\? AND entity_type = \\? AND entity_id = \\? "+
+			"AND created_at >= \\? AND created_at < \\? AND audit_id < \\? ORDER BY audit_id DESC LIMIT \\?").
+			ExpectQuery().
+			WithArgs(7, 7, entities.AuditBookingStatus, "booking", "100", from, to, int64(50), 20).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow(49, entities.ActorSystem, nil, nil, 7, entities.AuditBookingStatus, "booking", "100",
+					`{"status":"checked in"}`, `{"status":"checked out"}`, "", "", from))
+
+		entries, err := repo.GetAuditEntries(context.Background(), entities.AuditFilter{
+			VendorID: 7, ActorID: &actorID, Action: entities.AuditBookingStatus, EntityType: "booking", EntityID: "100",
+			From: from, To: to, BeforeID: 50, Limit: 20,
+		})
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1)
+		assert.Equal(t, int64(49), entries[0].ID)
+		assert.Nil(t, entries[0].ActorID)
+		assert.Equal(t, 7, *entries[0].VendorID)
+		assert.JSONEq(t, `{"status":"checked out"}`, string(entries[0].After))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("platform admin", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		repo := NewDBRepository(db, nil)
+		mock.ExpectPrepare("WHERE \\(vendor_id = \\? OR actor_type = \\?\\) AND action = \\? ORDER BY audit_id DESC LIMIT \\?").
+			ExpectQuery().
+			WithArgs(1, entities.ActorPlatformAdmin, entities.AuditUserUnlock, 20).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow(50, entities.ActorPlatformAdmin, 1, nil, nil, entities.AuditUserUnlock, "user", "5",
+					nil, `{"email":"guest@gmail.com","user_id":"5"}`, "", "", from))
+
+		entries, err := repo.GetAuditEntries(context.Background(), entities.AuditFilter{
+			VendorID: 1, PlatformAdmin: true, Action: entities.AuditUserUnlock, Limit: 20,
+		})
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1)
+		assert.Equal(t, 1, *entries[0].ActorID)
+		assert.Nil(t, entries[0].VendorID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		repo := NewDBRepository(db, nil)
+		mock.ExpectPrepare("FROM audit_log").ExpectQuery().WillReturnError(sql.ErrConnDone)
+
+		entries, err := repo.GetAuditEntries(context.Background(), entities.AuditFilter{VendorID: 7, Limit: 20})
+		assert.Error(t, err)
+		assert.Nil(t, entries)
+	})
+}
@@ -2,46 +2,72 @@ package repo
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/bicosteve/booking-system/entities"
 )
 
 type BookingRepository interface {
-	CreateABooking(ctx context.Context, data entities.BookingPayload) error
+	CreateABooking(ctx context.Context, data entities.BookingPayload) (int, error)
 	GetABooking(ctx context.Context, roomId, userId int) (*entities.Booking, error)
+	GetBookingByID(ctx context.Context, bookingID int) (*entities.Booking, error)
 	GetUserBookings(ctx context.Context, userID int) ([]*entities.Booking, error)
 	GetVendorBookings(ctx context.Context, vendorID int) ([]*entities.Booking, error)
 	UpdateABooking(ctx context.Context, data *entities.BookingPayload, bookingID int) error
+	IsRoomBooked(ctx context.Context, roomID int, start, end time.Time) (bool, error)
+	UpdateBookingStatus(ctx context.Context, booking *entities.Booking, change *entities.BookingStatusChange) (bool, error)
+	GetBookingHistory(ctx context.Context, bookingID int) ([]*entities.BookingStatusChange, error)
+	GetOverdueStays(ctx context.Context) ([]*entities.Booking, error)
 	DeleteABooking(ctx context.Context, bookingID, vendorID, roomID int) error
 }
 
-func (r *Repository) CreateABooking(ctx context.Context, data entities.BookingPayload) error {
+// CreateABooking books the room from data.CheckIn (today when unset) for
+// data.Days nights and returns the new booking's id. New bookings are always
+// pending, whatever data.Status says, and the guest's booking starts their
+// status history. It returns ErrRoomBlocked when a block or another active
+// booking overlaps the stay.
+func (r *Repository) CreateABooking(ctx context.Context, data entities.BookingPayload) (int, error) {
+	checkIn := time.Now()
+	if data.CheckIn != nil {
+		var err error
+		checkIn, err = time.Parse(entities.DateLayout, *data.CheckIn)
+		if err != nil {
+			return 0, err
+		}
+	}
 
 	tx, err := r.db.Begin()
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	defer tx.Rollback()
 
+	err = lockRoomDates(ctx, tx, *data.RoomID, 0, checkIn, *data.Days)
+	if err != nil {
+		return 0, err
+	}
+
 	updateQuery := `UPDATE room 
 		SET status = 'BOOKED', updated_at = NOW() WHERE room_id = ?`
 
 	updateRoomSTM, err := tx.PrepareContext(ctx, updateQuery)
 	if err != nil {
 		_ = tx.Rollback()
-		return err
+		return 0, err
 	}
 
 	defer updateRoomSTM.Close()
 
-	insertQuery := `INSERT INTO booking(days,user_id,room_id,status,created_at, updated_at)VALUES (?, ?, ?, ?, NOW(), NOW())`
+	insertQuery := `INSERT INTO booking(days,user_id,room_id,currency,status,check_in,created_at, updated_at)VALUES (?, ?, ?, ?, ?, ?, NOW(), NOW())`
 
 	insertRoomSTM, err := tx.PrepareContext(ctx, insertQuery)
 	if err != nil {
 		_ = tx.Rollback()
-		return err
+		return 0, err
 	}
 
 	defer insertRoomSTM.Close()
@@ -49,45 +75,60 @@ func (r *Repository) CreateABooking(ctx context.Context, data entities.BookingPa
 	updateResult, err := updateRoomSTM.ExecContext(ctx, data.RoomID)
 	if err != nil {
 		_ = tx.Rollback()
-		return err
+		return 0, err
 	}
 
 	roomsAffected, err := updateResult.RowsAffected()
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	if roomsAffected < 1 {
-		return fmt.Errorf("no room for room id %d or room not found", data.RoomID)
+		return 0, fmt.Errorf("no room for room id %d or room not found", data.RoomID)
 	}
 
-	args := []interface{}{data.Days, data.UserID, data.RoomID, data.Status}
+	args := []interface{}{data.Days, data.UserID, data.RoomID, data.Currency, entities.BookingStatusPending, checkIn.Format(entities.DateLayout)}
 
 	insertResult, err := insertRoomSTM.ExecContext(ctx, args...)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	bookingsAffected, err := insertResult.RowsAffected()
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	if bookingsAffected < 1 {
-		return fmt.Errorf("no booking done for user %d and room %d", data.UserID, data.RoomID)
+		return 0, fmt.Errorf("no booking done for user %d and room %d", data.UserID, data.RoomID)
+	}
+
+	bookingID, err := insertResult.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	q := `INSERT INTO booking_status_history (booking_id, from_status, to_status, actor, actor_id, reason)
+			VALUES (?, ?, ?, ?, ?, ?)`
+
+	_, err = tx.ExecContext(ctx, q, bookingID, entities.BookingStatusPending, entities.BookingStatusPending,
+		entities.ActorGuest, data.UserID, "booked")
+	if err != nil {
+		return 0, err
 	}
 
 	err = tx.Commit()
 	if err != nil {
 		_ = tx.Rollback()
-		return err
+		return 0, err
 	}
 
-	return nil
+	return int(bookingID), nil
 }
 
 func (r *Repository) GetABooking(ctx context.Context, roomID, userId int) (*entities.Booking, error) {
-	q := `SELECT * FROM booking 
+	q := `SELECT booking_id, days, user_id, room_id, currency, status, checked_in_at, checked_out_at, created_at, updated_at
+			FROM booking
 			WHERE status = 0 
 			AND booking_id = ? AND user_id = ?
 			ORDER BY created_at DESC LIMIT 1`
@@ -100,20 +141,54 @@ func (r *Repository) GetABooking(ctx context.Context, roomID, userId int) (*enti
 	defer stmt.Close()
 
 	var booking entities.Booking
+	var checkedIn, checkedOut sql.NullTime
 
 	row := stmt.QueryRowContext(ctx, roomID, userId)
 
-	err = row.Scan(&booking.ID, &booking.Days, &booking.UserID, &booking.RoomID, &booking.CreatedAt, &booking.UpdateAt)
+	err = row.Scan(&booking.ID, &booking.Days, &booking.UserID, &booking.RoomID, &booking.Currency, &booking.Status, &checkedIn, &checkedOut, &booking.CreatedAt, &booking.UpdateAt)
 	if err != nil {
 		return nil, err
 	}
 
+	setStayTimes(&booking, checkedIn, checkedOut)
+
+	return &booking, nil
+}
+
+// GetBookingByID returns a booking in any status together with the vendor
+// owning its room.
+func (r *Repository) GetBookingByID(ctx context.Context, bookingID int) (*entities.Booking, error) {
+	q := `SELECT b.booking_id, b.days, b.user_id, b.room_id, b.currency, b.status, r.vender_id,
+				b.checked_in_at, b.checked_out_at, b.created_at, b.updated_at
+			FROM booking b JOIN room r ON b.room_id = r.room_id
+			WHERE b.booking_id = ?`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	var booking entities.Booking
+	var checkedIn, checkedOut sql.NullTime
+
+	row := stmt.QueryRowContext(ctx, bookingID)
+
+	err = row.Scan(&booking.ID, &booking.Days, &booking.UserID, &booking.RoomID, &booking.Currency, &booking.Status, &booking.VenderID, &checkedIn, &checkedOut, &booking.CreatedAt, &booking.UpdateAt)
+	if err != nil {
+		return nil, err
+	}
+
+	setStayTimes(&booking, checkedIn, checkedOut)
+
 	return &booking, nil
 }
 
 func (r *Repository) GetUserBookings(ctx context.Context, userID int) ([]*entities.Booking, error) {
 
-	q := `SELECT * FROM booking WHERE user_id = ?`
+	q := `SELECT booking_id, days, user_id, room_id, currency, status, checked_in_at, checked_out_at, created_at, updated_at
+			FROM booking WHERE user_id = ?`
 
 	stmt, err := r.db.PrepareContext(ctx, q)
 	if err != nil {
@@ -133,12 +208,14 @@ func (r *Repository) GetUserBookings(ctx context.Context, userID int) ([]*entiti
 
 	for rows.Next() {
 		var booking entities.Booking
-		err = rows.Scan(&booking.ID, &booking.Days, &booking.UserID, &booking.RoomID, &booking.CreatedAt, &booking.UpdateAt)
+		var checkedIn, checkedOut sql.NullTime
+		err = rows.Scan(&booking.ID, &booking.Days, &booking.UserID, &booking.RoomID, &booking.Currency, &booking.Status, &checkedIn, &checkedOut, &booking.CreatedAt, &booking.UpdateAt)
 
 		if err != nil {
 			return nil, err
 		}
 
+		setStayTimes(&booking, checkedIn, checkedOut)
 		bookings = append(bookings, &booking)
 
 	}
@@ -147,8 +224,8 @@ func (r *Repository) GetUserBookings(ctx context.Context, userID int) ([]*entiti
 }
 
 func (r *Repository) GetVendorBookings(ctx context.Context, vendorID int) ([]*entities.Booking, error) {
-	q := `SELECT b.booking_id, b.days, b.user_id, b.room_id, r.vender_id,
-				b.created_at, b.updated_at
+	q := `SELECT b.booking_id, b.days, b.user_id, b.room_id, b.currency, b.status, r.vender_id,
+				b.checked_in_at, b.checked_out_at, b.created_at, b.updated_at
 			FROM booking b JOIN room r ON b.room_id = r.room_id 
 			WHERE r.vender_id = ?`
 
@@ -170,12 +247,14 @@ func (r *Repository) GetVendorBookings(ctx context.Context, vendorID int) ([]*en
 
 	for rows.Next() {
 		var booking entities.Booking
-		err = rows.Scan(&booking.ID, &booking.Days, &booking.UserID, &booking.RoomID, &booking.VenderID, &booking.CreatedAt, &booking.UpdateAt)
+		var checkedIn, checkedOut sql.NullTime
+		err = rows.Scan(&booking.ID, &booking.Days, &booking.UserID, &booking.RoomID, &booking.Currency, &booking.Status, &booking.VenderID, &checkedIn, &checkedOut, &booking.CreatedAt, &booking.UpdateAt)
 
 		if err != nil {
 			return nil, err
 		}
 
+		setStayTimes(&booking, checkedIn, checkedOut)
 		bookings = append(bookings, &booking)
 
 	}
@@ -184,28 +263,235 @@ func (r *Repository) GetVendorBookings(ctx context.Context, vendorID int) ([]*en
 
 }
 
-func (r *Repository) UpdateABooking(ctx context.Context, data *entities.BookingPayload, bookingID int) error {
+// roomBookedQuery counts the pending, confirmed and checked in bookings of a
+// room, other than the given booking, that overlap [start, end).
+const roomBookedQuery = `SELECT COUNT(*) FROM booking
+		WHERE room_id = ? AND booking_id <> ? AND status IN (?, ?, ?)
+			AND COALESCE(check_in, DATE(created_at)) < ?
+			AND DATE_ADD(COALESCE(check_in, DATE(created_at)), INTERVAL days DAY) > ?`
+
+// IsRoomBooked reports whether an active booking overlaps the nights from
+// start up to, but not including, end.
+func (r *Repository) IsRoomBooked(ctx context.Context, roomID int, start, end time.Time) (bool, error) {
+	stmt, err := r.db.PrepareContext(ctx, roomBookedQuery)
+	if err != nil {
+		return false, err
+	}
 
-	q := `UPDATE booking SET days = ?, status = ?, updated_at = NOW()
-		  WHERE booking_id = ? AND user_id = ?`
+	defer stmt.Close()
 
-	stmt, err := r.db.PrepareContext(ctx, q)
+	var count int
+	err = stmt.QueryRowContext(ctx, roomID, 0, entities.BookingStatusPending, entities.BookingStatusConfirmed,
+		entities.BookingStatusCheckedIn, end.Format(entities.DateLayout), start.Format(entities.DateLayout)).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// lockRoomDates locks the room's row for the rest of tx, then returns
+// ErrRoomBlocked when a block or an active booking other than bookingID
+// overlaps the days nights from checkIn. Holding the room row makes bookings
+// of the same room wait for each other, so two guests cannot take the same
+// nights.
+func lockRoomDates(ctx context.Context, tx *sql.Tx, roomID, bookingID int, checkIn time.Time, days int) error {
+	var id int
+	err := tx.QueryRowContext(ctx, `SELECT room_id FROM room WHERE room_id = ? FOR UPDATE`, roomID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("no room for room id %d or room not found", roomID)
+	}
 	if err != nil {
 		return err
 	}
 
-	defer stmt.Close()
+	start := checkIn.Format(entities.DateLayout)
+	end := checkIn.AddDate(0, 0, days).Format(entities.DateLayout)
 
-	args := []interface{}{data.Days, data.Status, bookingID, data.UserID}
+	var blocks int
+	err = tx.QueryRowContext(ctx, roomBlockedQuery, roomID, end, start).Scan(&blocks)
+	if err != nil {
+		return err
+	}
 
-	_, err = stmt.ExecContext(ctx, args...)
+	var bookings int
+	err = tx.QueryRowContext(ctx, roomBookedQuery, roomID, bookingID, entities.BookingStatusPending,
+		entities.BookingStatusConfirmed, entities.BookingStatusCheckedIn, end, start).Scan(&bookings)
 	if err != nil {
 		return err
 	}
 
+	if blocks > 0 || bookings > 0 {
+		return entities.ErrRoomBlocked
+	}
+
 	return nil
 }
 
+// UpdateABooking changes the nights of the guest's booking. The held payment
+// was priced for the original stay, so nights can only be shortened, and only
+// while the booking is still pending; anything else returns
+// ErrBookingLocked. The new stay is checked against the room's blocks and
+// bookings under the room lock.
+func (r *Repository) UpdateABooking(ctx context.Context, data *entities.BookingPayload, bookingID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	q := `SELECT room_id, days, status, COALESCE(check_in, DATE(created_at))
+			FROM booking WHERE booking_id = ? AND user_id = ? FOR UPDATE`
+
+	var roomID, days, status int
+	var checkIn time.Time
+	err = tx.QueryRowContext(ctx, q, bookingID, data.UserID).Scan(&roomID, &days, &status, &checkIn)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entities.ErrNoRecord
+	}
+	if err != nil {
+		return err
+	}
+
+	if status != entities.BookingStatusPending || *data.Days > days {
+		return entities.ErrBookingLocked
+	}
+
+	err = lockRoomDates(ctx, tx, roomID, bookingID, checkIn, *data.Days)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE booking SET days = ?, updated_at = NOW() WHERE booking_id = ?`, data.Days, bookingID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpdateBookingStatus moves a booking to status to, stamping check-in and
+// check-out times and freeing the room once the stay is over. The update only
+// applies while the booking is still in booking.Status, so it reports false
+// when a concurrent change got there first.
+func (r *Repository) UpdateBookingStatus(ctx context.Context, booking *entities.Booking, change *entities.BookingStatusChange) (bool, error) {
+	to := change.ToStatus
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+
+	defer tx.Rollback()
+
+	stamp := ""
+	switch to {
+	case entities.BookingStatusCheckedIn:
+		stamp = "checked_in_at = NOW(), "
+	case entities.BookingStatusCheckedOut:
+		stamp = "checked_out_at = NOW(), "
+	}
+
+	q := `UPDATE booking SET status = ?, ` + stamp + `updated_at = NOW()
+			WHERE booking_id = ? AND status = ?`
+
+	result, err := tx.ExecContext(ctx, q, to, booking.ID, booking.Status)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	if affected < 1 {
+		return false, nil
+	}
+
+	if to == entities.BookingStatusCheckedOut || to == entities.BookingStatusCancelled || to == entities.BookingStatusNoShow {
+		_, err = tx.ExecContext(ctx, `UPDATE room SET status = 'VACANT', updated_at = NOW() WHERE room_id = ?`, booking.RoomID)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	var actorID sql.NullInt64
+	if change.ActorID != nil {
+		actorID = sql.NullInt64{Int64: int64(*change.ActorID), Valid: true}
+	}
+
+	q = `INSERT INTO booking_status_history (booking_id, from_status, to_status, actor, actor_id, reason)
+			VALUES (?, ?, ?, ?, ?, ?)`
+
+	_, err = tx.ExecContext(ctx, q, booking.ID, change.FromStatus, to, change.Actor, actorID, change.Reason)
+	if err != nil {
+		return false, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// GetOverdueStays returns checked in bookings whose check-out day has
+// passed. The stay ends days nights after the booked check-in date, however
+// late the guest actually arrived.
+func (r *Repository) GetOverdueStays(ctx context.Context) ([]*entities.Booking, error) {
+	q := `SELECT b.booking_id, b.days, b.user_id, b.room_id, b.currency, b.status, r.vender_id,
+				b.checked_in_at, b.checked_out_at, b.created_at, b.updated_at
+			FROM booking b JOIN room r ON b.room_id = r.room_id
+			WHERE b.status = ? AND DATE_ADD(COALESCE(b.check_in, DATE(b.created_at)), INTERVAL b.days DAY) < CURDATE()`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, entities.BookingStatusCheckedIn)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var bookings []*entities.Booking
+
+	for rows.Next() {
+		var booking entities.Booking
+		var checkedIn, checkedOut sql.NullTime
+		err = rows.Scan(&booking.ID, &booking.Days, &booking.UserID, &booking.RoomID, &booking.Currency, &booking.Status, &booking.VenderID, &checkedIn, &checkedOut, &booking.CreatedAt, &booking.UpdateAt)
+		if err != nil {
+			return nil, err
+		}
+
+		setStayTimes(&booking, checkedIn, checkedOut)
+		bookings = append(bookings, &booking)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %v", err)
+	}
+
+	return bookings, nil
+}
+
+func setStayTimes(booking *entities.Booking, checkedIn, checkedOut sql.NullTime) {
+	if checkedIn.Valid {
+		booking.CheckedInAt = &checkedIn.Time
+	}
+
+	if checkedOut.Valid {
+		booking.CheckedOutAt = &checkedOut.Time
+	}
+}
+
 func (r *Repository) DeleteABooking(ctx context.Context, bookingID, vendorID, roomID int) error {
 	tx, err := r.db.Begin()
 	if err != nil {
@@ -238,6 +524,17 @@ func (r *Repository) DeleteABooking(ctx context.Context, bookingID, vendorID, ro
 		return err
 	}
 
+	// Existing databases may lack the ON DELETE CASCADE on these tables.
+	for _, q := range []string{
+		`DELETE FROM booking_status_history WHERE booking_id = ?`,
+		`DELETE FROM review WHERE booking_id = ?`,
+	} {
+		if _, err = tx.ExecContext(ctx, q, bookingID); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
 	_, err = booking_stmt.ExecContext(ctx, bookingID)
 	if err != nil {
 		_ = tx.Rollback()
@@ -251,3 +548,50 @@ func (r *Repository) DeleteABooking(ctx context.Context, bookingID, vendorID, ro
 
 	return nil
 }
+
+// GetBookingHistory returns the status changes of a booking, oldest first.
+func (r *Repository) GetBookingHistory(ctx context.Context, bookingID int) ([]*entities.BookingStatusChange, error) {
+	q := `SELECT history_id, booking_id, from_status, to_status, actor, actor_id, reason, created_at
+			FROM booking_status_history
+			WHERE booking_id = ?
+			ORDER BY created_at, history_id`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var history []*entities.BookingStatusChange
+
+	for rows.Next() {
+		var change entities.BookingStatusChange
+		var actorID sql.NullInt64
+
+		err = rows.Scan(&change.ID, &change.BookingID, &change.FromStatus, &change.ToStatus, &change.Actor, &actorID, &change.Reason, &change.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		if actorID.Valid {
+			id := int(actorID.Int64)
+			change.ActorID = &id
+		}
+
+		history = append(history, &change)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
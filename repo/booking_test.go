@@ -15,6 +15,7 @@ func bkIntPtr(i int) *int { return &i }
 
 func TestCreateABooking(t *testing.T) {
 	days, userID, roomID, status := 2, 5, 10, 0
+	currency, checkIn := "KES", "2026-11-01"
 
 	t.Run("success", func(t *testing.T) {
 		db, mock, err := sqlmock.New()
@@ -22,25 +23,85 @@ func TestCreateABooking(t *testing.T) {
 		defer db.Close()
 
 		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT room_id FROM room WHERE room_id = \\? FOR UPDATE").WithArgs(roomID).
+			WillReturnRows(sqlmock.NewRows([]string{"room_id"}).AddRow(roomID))
+		mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM room_block").
+			WithArgs(roomID, "2026-11-03", checkIn).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM booking").
+			WithArgs(roomID, 0, entities.BookingStatusPending, entities.BookingStatusConfirmed, entities.BookingStatusCheckedIn, "2026-11-03", checkIn).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
 		mock.ExpectPrepare("UPDATE room")
 		mock.ExpectPrepare("INSERT INTO booking")
 		mock.ExpectExec("UPDATE room").
 			WithArgs(roomID).
 			WillReturnResult(sqlmock.NewResult(0, 1))
-		mock.ExpectExec("INSERT INTO booking").
-			WithArgs(days, userID, roomID, status).
+		mock.ExpectExec("INSERT INTO booking\\(").
+			WithArgs(days, userID, roomID, currency, entities.BookingStatusPending, checkIn).
+			WillReturnResult(sqlmock.NewResult(42, 1))
+		mock.ExpectExec("INSERT INTO booking_status_history").
+			WithArgs(int64(42), entities.BookingStatusPending, entities.BookingStatusPending, entities.ActorGuest, userID, "booked").
 			WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
 
+		// A status asked for by the guest is ignored.
+		checkedOut := entities.BookingStatusCheckedOut
 		repo := &Repository{db: db}
 		data := entities.BookingPayload{
-			Days:   &days,
-			UserID: &userID,
-			RoomID: &roomID,
-			Status: &status,
+			CheckIn:  &checkIn,
+			Days:     &days,
+			UserID:   &userID,
+			RoomID:   &roomID,
+			Currency: &currency,
+			Status:   &checkedOut,
 		}
-		err = repo.CreateABooking(context.Background(), data)
+		id, err := repo.CreateABooking(context.Background(), data)
 		assert.NoError(t, err)
+		assert.Equal(t, 42, id)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("blocked dates", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT room_id FROM room WHERE room_id = \\? FOR UPDATE").WithArgs(roomID).
+			WillReturnRows(sqlmock.NewRows([]string{"room_id"}).AddRow(roomID))
+		mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM room_block").
+			WithArgs(roomID, "2026-11-03", checkIn).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM booking").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectRollback()
+
+		repo := &Repository{db: db}
+		data := entities.BookingPayload{CheckIn: &checkIn, Days: &days, UserID: &userID, RoomID: &roomID, Status: &status}
+		_, err = repo.CreateABooking(context.Background(), data)
+		assert.ErrorIs(t, err, entities.ErrRoomBlocked)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("overlapping booking", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT room_id FROM room WHERE room_id = \\? FOR UPDATE").WithArgs(roomID).
+			WillReturnRows(sqlmock.NewRows([]string{"room_id"}).AddRow(roomID))
+		mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM room_block").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM booking").
+			WithArgs(roomID, 0, entities.BookingStatusPending, entities.BookingStatusConfirmed, entities.BookingStatusCheckedIn, "2026-11-03", checkIn).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectRollback()
+
+		repo := &Repository{db: db}
+		data := entities.BookingPayload{CheckIn: &checkIn, Days: &days, UserID: &userID, RoomID: &roomID}
+		_, err = repo.CreateABooking(context.Background(), data)
+		assert.ErrorIs(t, err, entities.ErrRoomBlocked)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 
@@ -53,7 +114,7 @@ func TestCreateABooking(t *testing.T) {
 
 		repo := &Repository{db: db}
 		data := entities.BookingPayload{Days: &days, UserID: &userID, RoomID: &roomID, Status: &status}
-		err = repo.CreateABooking(context.Background(), data)
+		_, err = repo.CreateABooking(context.Background(), data)
 		assert.Error(t, err)
 	})
 
@@ -63,6 +124,12 @@ func TestCreateABooking(t *testing.T) {
 		defer db.Close()
 
 		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT room_id FROM room WHERE room_id = \\? FOR UPDATE").WithArgs(roomID).
+			WillReturnRows(sqlmock.NewRows([]string{"room_id"}).AddRow(roomID))
+		mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM room_block").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM booking").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
 		mock.ExpectPrepare("UPDATE room")
 		mock.ExpectPrepare("INSERT INTO booking")
 		mock.ExpectExec("UPDATE room").
@@ -72,7 +139,7 @@ func TestCreateABooking(t *testing.T) {
 
 		repo := &Repository{db: db}
 		data := entities.BookingPayload{Days: &days, UserID: &userID, RoomID: &roomID, Status: &status}
-		err = repo.CreateABooking(context.Background(), data)
+		_, err = repo.CreateABooking(context.Background(), data)
 		assert.Error(t, err)
 	})
 }
@@ -85,11 +152,11 @@ func TestGetABooking(t *testing.T) {
 		assert.NoError(t, err)
 		defer db.Close()
 
-		mock.ExpectPrepare("SELECT \\* FROM booking").
+		mock.ExpectPrepare("SELECT booking_id, days, user_id, room_id, currency").
 			ExpectQuery().
 			WithArgs(1, 2).
-			WillReturnRows(sqlmock.NewRows([]string{"id", "days", "user_id", "room_id", "created_at", "updated_at"}).
-				AddRow(1, 3, 2, 1, mockTime, mockTime))
+			WillReturnRows(sqlmock.NewRows([]string{"id", "days", "user_id", "room_id", "currency", "status", "checked_in_at", "checked_out_at", "created_at", "updated_at"}).
+				AddRow(1, 3, 2, 1, "KES", 0, nil, nil, mockTime, mockTime))
 
 		repo := &Repository{db: db}
 		booking, err := repo.GetABooking(context.Background(), 1, 2)
@@ -103,7 +170,7 @@ func TestGetABooking(t *testing.T) {
 		assert.NoError(t, err)
 		defer db.Close()
 
-		mock.ExpectPrepare("SELECT \\* FROM booking").
+		mock.ExpectPrepare("SELECT booking_id, days, user_id, room_id, currency").
 			ExpectQuery().
 			WithArgs(1, 2).
 			WillReturnError(sql.ErrNoRows)
@@ -115,6 +182,44 @@ func TestGetABooking(t *testing.T) {
 	})
 }
 
+func TestGetBookingByID(t *testing.T) {
+	mockTime := time.Now()
+
+	t.Run("found", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectPrepare("SELECT b.booking_id, (.+) WHERE b.booking_id = \\?").
+			ExpectQuery().
+			WithArgs(100).
+			WillReturnRows(sqlmock.NewRows([]string{"booking_id", "days", "user_id", "room_id", "currency", "status", "vender_id", "checked_in_at", "checked_out_at", "created_at", "updated_at"}).
+				AddRow(100, 2, 5, 10, "KES", entities.BookingStatusCheckedOut, 7, nil, nil, mockTime, mockTime))
+
+		repo := &Repository{db: db}
+		booking, err := repo.GetBookingByID(context.Background(), 100)
+		assert.NoError(t, err)
+		assert.Equal(t, entities.BookingStatusCheckedOut, booking.Status)
+		assert.Equal(t, 7, booking.VenderID)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectPrepare("SELECT b.booking_id").
+			ExpectQuery().
+			WithArgs(100).
+			WillReturnError(sql.ErrNoRows)
+
+		repo := &Repository{db: db}
+		booking, err := repo.GetBookingByID(context.Background(), 100)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		assert.Nil(t, booking)
+	})
+}
+
 func TestGetUserBookings(t *testing.T) {
 	mockTime := time.Now()
 
@@ -123,12 +228,12 @@ func TestGetUserBookings(t *testing.T) {
 		assert.NoError(t, err)
 		defer db.Close()
 
-		mock.ExpectPrepare("SELECT \\* FROM booking WHERE user_id = ?").
+		mock.ExpectPrepare("SELECT booking_id, days, user_id, room_id, currency, status, checked_in_at, checked_out_at, created_at, updated_at\\s+FROM booking WHERE user_id = ?").
 			ExpectQuery().
 			WithArgs(5).
-			WillReturnRows(sqlmock.NewRows([]string{"id", "days", "user_id", "room_id", "created_at", "updated_at"}).
-				AddRow(1, 2, 5, 10, mockTime, mockTime).
-				AddRow(2, 3, 5, 11, mockTime, mockTime))
+			WillReturnRows(sqlmock.NewRows([]string{"id", "days", "user_id", "room_id", "currency", "status", "checked_in_at", "checked_out_at", "created_at", "updated_at"}).
+				AddRow(1, 2, 5, 10, "KES", 0, nil, nil, mockTime, mockTime).
+				AddRow(2, 3, 5, 11, "KES", 0, nil, nil, mockTime, mockTime))
 
 		repo := &Repository{db: db}
 		bookings, err := repo.GetUserBookings(context.Background(), 5)
@@ -141,7 +246,7 @@ func TestGetUserBookings(t *testing.T) {
 		assert.NoError(t, err)
 		defer db.Close()
 
-		mock.ExpectPrepare("SELECT \\* FROM booking WHERE user_id = ?").
+		mock.ExpectPrepare("SELECT booking_id, days, user_id, room_id, currency, status, checked_in_at, checked_out_at, created_at, updated_at\\s+FROM booking WHERE user_id = ?").
 			ExpectQuery().
 			WithArgs(5).
 			WillReturnError(sql.ErrConnDone)
@@ -164,8 +269,8 @@ func TestGetVendorBookings(t *testing.T) {
 		mock.ExpectPrepare("SELECT b.booking_id").
 			ExpectQuery().
 			WithArgs(7).
-			WillReturnRows(sqlmock.NewRows([]string{"booking_id", "days", "user_id", "room_id", "vender_id", "created_at", "updated_at"}).
-				AddRow(1, 2, 5, 10, 7, mockTime, mockTime))
+			WillReturnRows(sqlmock.NewRows([]string{"booking_id", "days", "user_id", "room_id", "currency", "status", "vender_id", "checked_in_at", "checked_out_at", "created_at", "updated_at"}).
+				AddRow(1, 2, 5, 10, "KES", 0, 7, nil, nil, mockTime, mockTime))
 
 		repo := &Repository{db: db}
 		bookings, err := repo.GetVendorBookings(context.Background(), 7)
@@ -189,36 +294,80 @@ func TestGetVendorBookings(t *testing.T) {
 }
 
 func TestUpdateABooking(t *testing.T) {
+	checkIn := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	selectBooking := func(mock sqlmock.Sqlmock, status int) {
+		mock.ExpectQuery("SELECT room_id, days, status, COALESCE\\(check_in, DATE\\(created_at\\)\\)(.|\\s)+FOR UPDATE").
+			WithArgs(100, 5).
+			WillReturnRows(sqlmock.NewRows([]string{"room_id", "days", "status", "check_in"}).AddRow(10, 5, status, checkIn))
+	}
+	lockRoom := func(mock sqlmock.Sqlmock, booked int) {
+		mock.ExpectQuery("SELECT room_id FROM room WHERE room_id = \\? FOR UPDATE").WithArgs(10).
+			WillReturnRows(sqlmock.NewRows([]string{"room_id"}).AddRow(10))
+		mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM room_block").WithArgs(10, "2026-11-05", "2026-11-01").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM booking").
+			WithArgs(10, 100, entities.BookingStatusPending, entities.BookingStatusConfirmed, entities.BookingStatusCheckedIn, "2026-11-05", "2026-11-01").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(booked))
+	}
+
 	tests := []struct {
 		name    string
-		wantErr bool
+		days    int
+		wantErr error
 		setup   func(mock sqlmock.Sqlmock)
 	}{
 		{
-			name:    "success",
-			wantErr: false,
+			name: "shortens a pending booking",
+			days: 4,
 			setup: func(mock sqlmock.Sqlmock) {
-				mock.ExpectPrepare("UPDATE booking SET days").
-					ExpectExec().
-					WithArgs(4, 1, 100, 5).
+				mock.ExpectBegin()
+				selectBooking(mock, entities.BookingStatusPending)
+				lockRoom(mock, 0)
+				mock.ExpectExec("UPDATE booking SET days = \\?, updated_at = NOW\\(\\) WHERE booking_id = \\?").
+					WithArgs(4, 100).
 					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
 			},
 		},
 		{
-			name:    "prepare error",
-			wantErr: true,
+			name:    "more nights need a new payment",
+			days:    6,
+			wantErr: entities.ErrBookingLocked,
 			setup: func(mock sqlmock.Sqlmock) {
-				mock.ExpectPrepare("UPDATE booking SET days").WillReturnError(sql.ErrConnDone)
+				mock.ExpectBegin()
+				selectBooking(mock, entities.BookingStatusPending)
+				mock.ExpectRollback()
 			},
 		},
 		{
-			name:    "exec error",
-			wantErr: true,
+			name:    "paid booking is locked",
+			days:    4,
+			wantErr: entities.ErrBookingLocked,
 			setup: func(mock sqlmock.Sqlmock) {
-				mock.ExpectPrepare("UPDATE booking SET days").
-					ExpectExec().
-					WithArgs(4, 1, 100, 5).
-					WillReturnError(sql.ErrNoRows)
+				mock.ExpectBegin()
+				selectBooking(mock, entities.BookingStatusConfirmed)
+				mock.ExpectRollback()
+			},
+		},
+		{
+			name:    "overlapping booking",
+			days:    4,
+			wantErr: entities.ErrRoomBlocked,
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				selectBooking(mock, entities.BookingStatusPending)
+				lockRoom(mock, 1)
+				mock.ExpectRollback()
+			},
+		},
+		{
+			name:    "not the guest's booking",
+			days:    4,
+			wantErr: entities.ErrNoRecord,
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("SELECT room_id, days, status").WithArgs(100, 5).WillReturnError(sql.ErrNoRows)
+				mock.ExpectRollback()
 			},
 		},
 	}
@@ -232,14 +381,13 @@ func TestUpdateABooking(t *testing.T) {
 			tt.setup(mock)
 			repo := &Repository{db: db}
 			data := &entities.BookingPayload{
-				Days:   bkIntPtr(4),
+				Days:   bkIntPtr(tt.days),
 				UserID: bkIntPtr(5),
-				Status: bkIntPtr(1),
 			}
 			err = repo.UpdateABooking(context.Background(), data, 100)
 
-			if tt.wantErr {
-				assert.Error(t, err)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
 			} else {
 				assert.NoError(t, err)
 			}
@@ -260,7 +408,13 @@ func TestDeleteABooking(t *testing.T) {
 		mock.ExpectExec("UPDATE room SET status = 'VACANT'").
 			WithArgs(10, 7).
 			WillReturnResult(sqlmock.NewResult(0, 1))
-		mock.ExpectExec("DELETE FROM booking WHERE booking_id = ?").
+		mock.ExpectExec("DELETE FROM booking_status_history WHERE booking_id = \\?").
+			WithArgs(100).
+			WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectExec("DELETE FROM review WHERE booking_id = \\?").
+			WithArgs(100).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("DELETE FROM booking WHERE booking_id = \\?").
 			WithArgs(100).
 			WillReturnResult(sqlmock.NewResult(0, 1))
 		mock.ExpectCommit()
@@ -300,4 +454,144 @@ func TestDeleteABooking(t *testing.T) {
 		err = repo.DeleteABooking(context.Background(), 100, 7, 10)
 		assert.Error(t, err)
 	})
+
+	t.Run("history delete error keeps the booking", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectPrepare("UPDATE room SET status = 'VACANT'")
+		mock.ExpectPrepare("DELETE FROM booking WHERE booking_id = ?")
+		mock.ExpectExec("UPDATE room SET status = 'VACANT'").
+			WithArgs(10, 7).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("DELETE FROM booking_status_history WHERE booking_id = \\?").
+			WithArgs(100).
+			WillReturnError(sql.ErrConnDone)
+		mock.ExpectRollback()
+
+		repo := &Repository{db: db}
+		err = repo.DeleteABooking(context.Background(), 100, 7, 10)
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestUpdateBookingStatus(t *testing.T) {
+	historyInsert := "INSERT INTO booking_status_history \\(booking_id, from_status, to_status, actor, actor_id, reason\\)"
+	vendorID := 7
+
+	t.Run("check in stamps the time", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE booking SET status = \\?, checked_in_at = NOW\\(\\), updated_at = NOW\\(\\)").
+			WithArgs(entities.BookingStatusCheckedIn, 100, entities.BookingStatusConfirmed).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(historyInsert).
+			WithArgs(100, entities.BookingStatusConfirmed, entities.BookingStatusCheckedIn, entities.ActorVendor, sql.NullInt64{Int64: 7, Valid: true}, "").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		repo := &Repository{db: db}
+		booking := &entities.Booking{ID: 100, RoomID: 10, Status: entities.BookingStatusConfirmed}
+		change := &entities.BookingStatusChange{FromStatus: entities.BookingStatusConfirmed, ToStatus: entities.BookingStatusCheckedIn, Actor: entities.ActorVendor, ActorID: &vendorID}
+		updated, err := repo.UpdateBookingStatus(context.Background(), booking, change)
+		assert.NoError(t, err)
+		assert.True(t, updated)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("check out frees the room", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE booking SET status = \\?, checked_out_at = NOW\\(\\)").
+			WithArgs(entities.BookingStatusCheckedOut, 100, entities.BookingStatusCheckedIn).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE room SET status = 'VACANT'").
+			WithArgs(10).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(historyInsert).
+			WithArgs(100, entities.BookingStatusCheckedIn, entities.BookingStatusCheckedOut, entities.ActorSystem, sql.NullInt64{}, "stay ended").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		repo := &Repository{db: db}
+		booking := &entities.Booking{ID: 100, RoomID: 10, Status: entities.BookingStatusCheckedIn}
+		change := &entities.BookingStatusChange{FromStatus: entities.BookingStatusCheckedIn, ToStatus: entities.BookingStatusCheckedOut, Actor: entities.ActorSystem, Reason: "stay ended"}
+		updated, err := repo.UpdateBookingStatus(context.Background(), booking, change)
+		assert.NoError(t, err)
+		assert.True(t, updated)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("status changed concurrently", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE booking SET status").
+			WithArgs(entities.BookingStatusCancelled, 100, entities.BookingStatusConfirmed).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		repo := &Repository{db: db}
+		booking := &entities.Booking{ID: 100, RoomID: 10, Status: entities.BookingStatusConfirmed}
+		change := &entities.BookingStatusChange{FromStatus: entities.BookingStatusConfirmed, ToStatus: entities.BookingStatusCancelled, Actor: entities.ActorVendor, ActorID: &vendorID}
+		updated, err := repo.UpdateBookingStatus(context.Background(), booking, change)
+		assert.NoError(t, err)
+		assert.False(t, updated)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGetOverdueStays(t *testing.T) {
+	mockTime := time.Now()
+
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectPrepare("WHERE b.status = \\? AND DATE_ADD\\(COALESCE\\(b.check_in, DATE\\(b.created_at\\)\\), INTERVAL b.days DAY\\) < CURDATE\\(\\)").
+		ExpectQuery().
+		WithArgs(entities.BookingStatusCheckedIn).
+		WillReturnRows(sqlmock.NewRows([]string{"booking_id", "days", "user_id", "room_id", "currency", "status", "vender_id", "checked_in_at", "checked_out_at", "created_at", "updated_at"}).
+			AddRow(100, 2, 5, 10, "KES", entities.BookingStatusCheckedIn, 7, mockTime.AddDate(0, 0, -3), nil, mockTime, mockTime))
+
+	repo := &Repository{db: db}
+	bookings, err := repo.GetOverdueStays(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, bookings, 1)
+	assert.NotNil(t, bookings[0].CheckedInAt)
+	assert.Nil(t, bookings[0].CheckedOutAt)
+}
+
+func TestGetBookingHistory(t *testing.T) {
+	mockTime := time.Now()
+
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectPrepare("FROM booking_status_history").
+		ExpectQuery().
+		WithArgs(100).
+		WillReturnRows(sqlmock.NewRows([]string{"history_id", "booking_id", "from_status", "to_status", "actor", "actor_id", "reason", "created_at"}).
+			AddRow(1, 100, entities.BookingStatusPending, entities.BookingStatusConfirmed, entities.ActorSystem, nil, "payment succeeded", mockTime).
+			AddRow(2, 100, entities.BookingStatusConfirmed, entities.BookingStatusCheckedIn, entities.ActorVendor, 7, "", mockTime))
+
+	repo := &Repository{db: db}
+	history, err := repo.GetBookingHistory(context.Background(), 100)
+	assert.NoError(t, err)
+	assert.Len(t, history, 2)
+	assert.Nil(t, history[0].ActorID)
+	assert.Equal(t, 7, *history[1].ActorID)
+	assert.Equal(t, entities.ActorVendor, history[1].Actor)
 }
@@ -22,6 +22,7 @@ func (r *Repository) CreatePayment(ctx context.Context, p *entities.Payment) err
 		"OrderId":        p.OrderID,
 		"UserId":         p.UserID,
 		"Amount":         p.Amount,
+		"Currency":       p.Currency,
 		"Status":         p.Status,
 		"PaymentUrl":     p.PaymentUrl,
 		"PaymentId":      p.PaymentId,
@@ -68,8 +69,10 @@ func (r *Repository) FindPayment(ctx context.Context, userId string) (*entities.
 			room_id, _ := strconv.Atoi(value)
 			payment.RoomID = room_id
 		case "Amount":
-			amount, _ := strconv.ParseFloat(value, 64)
+			amount, _ := strconv.ParseInt(value, 10, 64)
 			payment.Amount = amount
+		case "Currency":
+			payment.Currency = value
 		case "ClientSecret":
 			payment.ClientSecret = value
 		case "TransactionID":
@@ -16,7 +16,8 @@ func TestCreatePayment(t *testing.T) {
 	payment := &entities.Payment{
 		OrderID:       "order-1",
 		UserID:        5,
-		Amount:        100,
+		Amount:        10000,
+		Currency:      "KES",
 		Status:        "initial",
 		PaymentUrl:    "http://pay",
 		PaymentId:     "pi_1",
@@ -34,6 +35,7 @@ func TestCreatePayment(t *testing.T) {
 		"OrderId":        payment.OrderID,
 		"UserId":         payment.UserID,
 		"Amount":         payment.Amount,
+		"Currency":       payment.Currency,
 		"Status":         payment.Status,
 		"PaymentUrl":     payment.PaymentUrl,
 		"PaymentId":      payment.PaymentId,
@@ -75,7 +77,8 @@ func TestFindPayment(t *testing.T) {
 			"UserID":    "5",
 			"PaymentId": "pi_1",
 			"RoomID":    "10",
-			"Amount":    "100.5",
+			"Amount":    "10050",
+			"Currency":  "KES",
 			"Status":    "initial",
 		})
 
@@ -86,7 +89,8 @@ func TestFindPayment(t *testing.T) {
 		assert.Equal(t, 5, payment.UserID)
 		assert.Equal(t, "pi_1", payment.PaymentId)
 		assert.Equal(t, 10, payment.RoomID)
-		assert.Equal(t, 100.5, payment.Amount)
+		assert.Equal(t, int64(10050), payment.Amount)
+		assert.Equal(t, "KES", payment.Currency)
 		assert.Equal(t, "initial", payment.Status)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
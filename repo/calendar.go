@@ -0,0 +1,335 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bicosteve/booking-system/entities"
+)
+
+type CalendarRepository interface {
+	IsRoomBlocked(ctx context.Context, roomID int, start, end time.Time) (bool, error)
+	GetRoomFeedToken(ctx context.Context, roomID int) (string, error)
+	SetRoomFeedToken(ctx context.Context, roomID, vendorID int, token string) error
+	GetRoomCalendarEntries(ctx context.Context, roomID int, from, to time.Time) ([]entities.CalendarEntry, error)
+	CreateRoomCalendar(ctx context.Context, calendar *entities.RoomCalendar, vendorID int) (int, error)
+	GetRoomCalendars(ctx context.Context, roomID, vendorID int) ([]*entities.RoomCalendar, error)
+	GetAllRoomCalendars(ctx context.Context) ([]*entities.RoomCalendar, error)
+	DeleteRoomCalendar(ctx context.Context, calendarID, roomID, vendorID int) error
+	ReplaceCalendarBlocks(ctx context.Context, calendar *entities.RoomCalendar, blocks []entities.RoomBlock) error
+	RecordCalendarSync(ctx context.Context, calendarID int, syncErr string) error
+}
+
+// roomBlockedQuery counts the blocks overlapping [start, end) for a room.
+const roomBlockedQuery = `SELECT COUNT(*) FROM room_block
+		WHERE room_id = ? AND start_date < ? AND end_date > ?`
+
+// IsRoomBlocked reports whether any block overlaps the nights from start up
+// to, but not including, end.
+func (r *Repository) IsRoomBlocked(ctx context.Context, roomID int, start, end time.Time) (bool, error) {
+	stmt, err := r.db.PrepareContext(ctx, roomBlockedQuery)
+	if err != nil {
+		return false, err
+	}
+
+	defer stmt.Close()
+
+	var count int
+	err = stmt.QueryRowContext(ctx, roomID, end.Format(entities.DateLayout), start.Format(entities.DateLayout)).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// GetRoomFeedToken returns the token of the room's calendar feed, or
+// ErrNoRecord when the room has none.
+func (r *Repository) GetRoomFeedToken(ctx context.Context, roomID int) (string, error) {
+	q := `SELECT ics_token FROM room WHERE room_id = ?`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return "", err
+	}
+
+	defer stmt.Close()
+
+	var token sql.NullString
+	err = stmt.QueryRowContext(ctx, roomID).Scan(&token)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !token.Valid) {
+		return "", entities.ErrNoRecord
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return token.String, nil
+}
+
+// SetRoomFeedToken replaces the feed token of one of vendorID's rooms,
+// invalidating the previous feed URL.
+func (r *Repository) SetRoomFeedToken(ctx context.Context, roomID, vendorID int, token string) error {
+	q := `UPDATE room SET ics_token = ?, updated_at = NOW() WHERE room_id = ? AND vender_id = ?`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, token, roomID, vendorID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return entities.ErrNoRecord
+	}
+
+	return nil
+}
+
+// GetRoomCalendarEntries returns the confirmed stays and the blocks of a room
+// that overlap the nights from from up to, but not including, to, ordered by
+// start.
+func (r *Repository) GetRoomCalendarEntries(ctx context.Context, roomID int, from, to time.Time) ([]entities.CalendarEntry, error) {
+	q := `SELECT 'booking', booking_id, start_date, end_date, status, '', '', NULL FROM (
+				SELECT booking_id, status, COALESCE(check_in, DATE(created_at)) AS start_date,
+					DATE_ADD(COALESCE(check_in, DATE(created_at)), INTERVAL days DAY) AS end_date
+				FROM booking
+				WHERE room_id = ? AND status IN (?, ?)
+			) stays
+			WHERE start_date < ? AND end_date > ?
+		UNION ALL
+		SELECT 'block', block_id, start_date, end_date, NULL, reason, note, calendar_id
+			FROM room_block
+			WHERE room_id = ? AND start_date < ? AND end_date > ?
+		ORDER BY 3`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	first, last := from.Format(entities.DateLayout), to.Format(entities.DateLayout)
+	rows, err := stmt.QueryContext(ctx, roomID, entities.BookingStatusConfirmed, entities.BookingStatusCheckedIn, last, first,
+		roomID, last, first)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	entries := []entities.CalendarEntry{}
+	for rows.Next() {
+		var e entities.CalendarEntry
+		var status, calendarID sql.NullInt64
+
+		err = rows.Scan(&e.Kind, &e.ID, &e.Start, &e.End, &status, &e.Reason, &e.Note, &calendarID)
+		if err != nil {
+			return nil, err
+		}
+
+		if status.Valid {
+			e.Status = entities.BookingStatusNames[int(status.Int64)]
+		}
+		if calendarID.Valid {
+			id := int(calendarID.Int64)
+			e.CalendarID = &id
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+// CreateRoomCalendar adds an external calendar to one of vendorID's rooms.
+// It returns ErrNoRecord when the room is not the vendor's.
+func (r *Repository) CreateRoomCalendar(ctx context.Context, calendar *entities.RoomCalendar, vendorID int) (int, error) {
+	q := `INSERT INTO room_calendar(room_id, url, created_at)
+		SELECT room_id, ?, NOW() FROM room WHERE room_id = ? AND vender_id = ?`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return 0, err
+	}
+
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, calendar.URL, calendar.RoomID, vendorID)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if n == 0 {
+		return 0, entities.ErrNoRecord
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+const selectRoomCalendar = `SELECT c.calendar_id, c.room_id, c.url, c.last_synced_at, c.last_error, c.created_at
+			FROM room_calendar c`
+
+func (r *Repository) GetRoomCalendars(ctx context.Context, roomID, vendorID int) ([]*entities.RoomCalendar, error) {
+	q := selectRoomCalendar + `
+			JOIN room r ON r.room_id = c.room_id
+			WHERE c.room_id = ? AND r.vender_id = ?
+			ORDER BY c.calendar_id`
+
+	return r.queryRoomCalendars(ctx, q, roomID, vendorID)
+}
+
+// GetAllRoomCalendars returns every external calendar, for syncing.
+func (r *Repository) GetAllRoomCalendars(ctx context.Context) ([]*entities.RoomCalendar, error) {
+	return r.queryRoomCalendars(ctx, selectRoomCalendar+` ORDER BY c.calendar_id`)
+}
+
+func (r *Repository) queryRoomCalendars(ctx context.Context, q string, args ...any) ([]*entities.RoomCalendar, error) {
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	calendars := []*entities.RoomCalendar{}
+	for rows.Next() {
+		var c entities.RoomCalendar
+		var synced sql.NullTime
+
+		err = rows.Scan(&c.ID, &c.RoomID, &c.URL, &synced, &c.LastError, &c.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		if synced.Valid {
+			c.LastSyncedAt = &synced.Time
+		}
+
+		calendars = append(calendars, &c)
+	}
+
+	return calendars, rows.Err()
+}
+
+// DeleteRoomCalendar removes an external calendar of one of vendorID's rooms
+// along with the blocks imported from it.
+func (r *Repository) DeleteRoomCalendar(ctx context.Context, calendarID, roomID, vendorID int) error {
+	q := `DELETE c FROM room_calendar c
+			JOIN room r ON r.room_id = c.room_id
+			WHERE c.calendar_id = ? AND c.room_id = ? AND r.vender_id = ?`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, calendarID, roomID, vendorID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return entities.ErrNoRecord
+	}
+
+	return nil
+}
+
+// ReplaceCalendarBlocks swaps the blocks imported from calendar for blocks,
+// so events removed from the external calendar free the room again.
+func (r *Repository) ReplaceCalendarBlocks(ctx context.Context, calendar *entities.RoomCalendar, blocks []entities.RoomBlock) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM room_block WHERE calendar_id = ?`, calendar.ID)
+	if err != nil {
+		return err
+	}
+
+	if len(blocks) > 0 {
+		q := `INSERT INTO room_block(room_id, start_date, end_date, reason, note, calendar_id, external_uid, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, NOW(), NOW())`
+
+		stmt, err := tx.PrepareContext(ctx, q)
+		if err != nil {
+			return err
+		}
+
+		defer stmt.Close()
+
+		for _, b := range blocks {
+			_, err = stmt.ExecContext(ctx, calendar.RoomID, b.Start.Format(entities.DateLayout), b.End.Format(entities.DateLayout),
+				b.Reason, b.Note, calendar.ID, b.ExternalUID)
+			if err != nil {
+				return fmt.Errorf("importing event %q: %w", b.ExternalUID, err)
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// RecordCalendarSync stores the outcome of a sync. syncErr is empty when it
+// succeeded, which also stamps last_synced_at.
+func (r *Repository) RecordCalendarSync(ctx context.Context, calendarID int, syncErr string) error {
+	q := `UPDATE room_calendar
+			SET last_synced_at = IF(? = '', NOW(), last_synced_at), last_error = ?
+			WHERE calendar_id = ?`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, syncErr, syncErr, calendarID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
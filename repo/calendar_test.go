@@ -0,0 +1,158 @@
+package repo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsRoomBlocked(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewDBRepository(db, nil)
+	start := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectPrepare("SELECT COUNT\\(\\*\\) FROM room_block").
+		ExpectQuery().
+		WithArgs(10, "2026-11-04", "2026-11-01").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+	blocked, err := repo.IsRoomBlocked(context.Background(), 10, start, start.AddDate(0, 0, 3))
+	assert.NoError(t, err)
+	assert.True(t, blocked)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetRoomFeedToken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewDBRepository(db, nil)
+
+	mock.ExpectPrepare("SELECT ics_token FROM room").ExpectQuery().WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"ics_token"}).AddRow("abc"))
+	mock.ExpectPrepare("SELECT ics_token FROM room").ExpectQuery().WithArgs(11).
+		WillReturnRows(sqlmock.NewRows([]string{"ics_token"}).AddRow(nil))
+	mock.ExpectPrepare("SELECT ics_token FROM room").ExpectQuery().WithArgs(12).
+		WillReturnRows(sqlmock.NewRows([]string{"ics_token"}))
+
+	token, err := repo.GetRoomFeedToken(context.Background(), 10)
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", token)
+
+	_, err = repo.GetRoomFeedToken(context.Background(), 11)
+	assert.ErrorIs(t, err, entities.ErrNoRecord)
+
+	_, err = repo.GetRoomFeedToken(context.Background(), 12)
+	assert.ErrorIs(t, err, entities.ErrNoRecord)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetRoomFeedToken_NotVendorsRoom(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewDBRepository(db, nil)
+
+	mock.ExpectPrepare("UPDATE room SET ics_token").ExpectExec().WithArgs("abc", 10, 8).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = repo.SetRoomFeedToken(context.Background(), 10, 8, "abc")
+	assert.ErrorIs(t, err, entities.ErrNoRecord)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetRoomCalendarEntries(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewDBRepository(db, nil)
+	from := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	start := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectPrepare("UNION ALL").
+		ExpectQuery().
+		WithArgs(10, entities.BookingStatusConfirmed, entities.BookingStatusCheckedIn, "2026-12-19", "2026-10-19",
+			10, "2026-12-19", "2026-10-19").
+		WillReturnRows(sqlmock.NewRows([]string{"kind", "id", "start_date", "end_date", "status", "reason", "note", "calendar_id"}).
+			AddRow("booking", 100, start, start.AddDate(0, 0, 2), entities.BookingStatusConfirmed, "", "", nil).
+			AddRow("block", 3, start.AddDate(0, 0, 5), start.AddDate(0, 0, 6), nil, entities.BlockReasonExternalBooking, "Reserved", 4))
+
+	entries, err := repo.GetRoomCalendarEntries(context.Background(), 10, from, from.AddDate(0, 2, 0))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, entities.CalendarEntry{Kind: entities.CalendarEntryBooking, ID: 100, Start: start, End: start.AddDate(0, 0, 2),
+		Status: "confirmed"}, entries[0])
+	assert.Equal(t, entities.BlockReasonExternalBooking, entries[1].Reason)
+	assert.Equal(t, 4, *entries[1].CalendarID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateRoomCalendar(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewDBRepository(db, nil)
+	calendar := &entities.RoomCalendar{RoomID: 10, URL: "https://www.airbnb.com/calendar/ical/1.ics"}
+
+	mock.ExpectPrepare("INSERT INTO room_calendar").ExpectExec().WithArgs(calendar.URL, 10, 7).
+		WillReturnResult(sqlmock.NewResult(4, 1))
+	mock.ExpectPrepare("INSERT INTO room_calendar").ExpectExec().WithArgs(calendar.URL, 10, 8).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	id, err := repo.CreateRoomCalendar(context.Background(), calendar, 7)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, id)
+
+	_, err = repo.CreateRoomCalendar(context.Background(), calendar, 8)
+	assert.ErrorIs(t, err, entities.ErrNoRecord)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReplaceCalendarBlocks(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewDBRepository(db, nil)
+	calendar := &entities.RoomCalendar{ID: 4, RoomID: 10}
+	start := time.Date(2026, 11, 5, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM room_block WHERE calendar_id = ?").WithArgs(4).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectPrepare("INSERT INTO room_block").ExpectExec().
+		WithArgs(10, "2026-11-05", "2026-11-10", entities.BlockReasonExternalBooking, "Reserved", 4, "abc@airbnb.com").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err = repo.ReplaceCalendarBlocks(context.Background(), calendar, []entities.RoomBlock{
+		{Start: start, End: start.AddDate(0, 0, 5), Reason: entities.BlockReasonExternalBooking, Note: "Reserved", ExternalUID: "abc@airbnb.com"},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecordCalendarSync(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewDBRepository(db, nil)
+
+	mock.ExpectPrepare("UPDATE room_calendar").ExpectExec().WithArgs("HTTP 404", "HTTP 404", 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = repo.RecordCalendarSync(context.Background(), 4, "HTTP 404")
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
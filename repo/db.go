@@ -2,10 +2,15 @@ package repo
 
 import (
 	"database/sql"
+	"errors"
 
+	"github.com/go-sql-driver/mysql"
 	"github.com/redis/go-redis/v9"
 )
 
+// errDuplicateEntry is MySQL's ER_DUP_ENTRY.
+const errDuplicateEntry = 1062
+
 type Repository struct {
 	db    *sql.DB
 	cache *redis.Client
@@ -14,3 +19,9 @@ type Repository struct {
 func NewDBRepository(db *sql.DB, ch *redis.Client) *Repository {
 	return &Repository{db: db, cache: ch}
 }
+
+// isDuplicateKey reports whether err is a unique constraint violation.
+func isDuplicateKey(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == errDuplicateEntry
+}
@@ -0,0 +1,55 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+type EventRepository interface {
+	IsEventProcessed(ctx context.Context, consumer, eventID string) (bool, error)
+	MarkEventProcessed(ctx context.Context, consumer, eventID, eventType string) error
+}
+
+// IsEventProcessed reports whether consumer has already handled eventID.
+func (r *Repository) IsEventProcessed(ctx context.Context, consumer, eventID string) (bool, error) {
+	q := `SELECT 1 FROM processed_event WHERE consumer = ? AND event_id = ?`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return false, err
+	}
+
+	defer stmt.Close()
+
+	var found int
+	err = stmt.QueryRowContext(ctx, consumer, eventID).Scan(&found)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// MarkEventProcessed records that consumer has handled eventID. Marking an
+// event twice is not an error.
+func (r *Repository) MarkEventProcessed(ctx context.Context, consumer, eventID, eventType string) error {
+	q := `INSERT IGNORE INTO processed_event(consumer, event_id, event_type, processed_at) VALUES (?, ?, ?, NOW())`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, consumer, eventID, eventType)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package repo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsEventProcessed(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewDBRepository(db, nil)
+
+	mock.ExpectPrepare("SELECT 1 FROM processed_event").
+		ExpectQuery().
+		WithArgs("payments", "event-1").
+		WillReturnRows(sqlmock.NewRows([]string{"1"}).AddRow(1))
+	mock.ExpectPrepare("SELECT 1 FROM processed_event").
+		ExpectQuery().
+		WithArgs("payments", "event-2").
+		WillReturnRows(sqlmock.NewRows([]string{"1"}))
+
+	done, err := repo.IsEventProcessed(context.Background(), "payments", "event-1")
+	assert.NoError(t, err)
+	assert.True(t, done)
+
+	done, err = repo.IsEventProcessed(context.Background(), "payments", "event-2")
+	assert.NoError(t, err)
+	assert.False(t, done)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMarkEventProcessed(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewDBRepository(db, nil)
+
+	mock.ExpectPrepare("INSERT IGNORE INTO processed_event").
+		ExpectExec().
+		WithArgs("payments", "event-1", "payment.succeeded").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = repo.MarkEventProcessed(context.Background(), "payments", "event-1", "payment.succeeded")
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,396 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/money"
+)
+
+type LedgerRepository interface {
+	PostJournal(ctx context.Context, entries []entities.LedgerEntry) error
+	RefundTransaction(ctx context.Context, trxID string, entries []entities.LedgerEntry) error
+	GetJournal(ctx context.Context, reference, kind string) ([]*entities.LedgerEntry, error)
+	GetVendorBalances(ctx context.Context, vendorID int) ([]*entities.VendorBalance, error)
+	GetVendorsDueForPayout(ctx context.Context, minimum int64) ([]*entities.VendorBalance, error)
+	CreatePayout(ctx context.Context, vendorID int, amount money.Money) (int, error)
+	SettlePayout(ctx context.Context, payoutID int) (int, error)
+	GetVendorPayouts(ctx context.Context, vendorID int) ([]*entities.Payout, error)
+	GetVendorStatement(ctx context.Context, vendorID int, from, to time.Time) ([]*entities.LedgerEntry, error)
+}
+
+const insertLedgerEntry = `INSERT INTO ledger_entry(reference, vendor_id, account, direction, amount, currency, kind, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, NOW())`
+
+// PostJournal writes all lines of a journal in one transaction so that a
+// partially booked payment can never be observed.
+func (r *Repository) PostJournal(ctx context.Context, entries []entities.LedgerEntry) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	err = postLines(ctx, tx, entries)
+	if isDuplicateKey(err) {
+		return entities.ErrJournalExists
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RefundTransaction marks a paid transaction refunded and posts the reversing
+// journal in the same transaction. It returns ErrTransactionNotPaid when the
+// transaction is no longer paid, so a refund is only ever booked once per
+// trx_id.
+func (r *Repository) RefundTransaction(ctx context.Context, trxID string, entries []entities.LedgerEntry) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	q := `UPDATE transaction SET status = ?, updated_at = NOW() WHERE trx_id = ? AND status = ?`
+
+	result, err := tx.ExecContext(ctx, q, entities.TransactionStatusRefunded, trxID, entities.TransactionStatusPaid)
+	if err != nil {
+		return err
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if updated < 1 {
+		return entities.ErrTransactionNotPaid
+	}
+
+	err = postLines(ctx, tx, entries)
+	if isDuplicateKey(err) {
+		return entities.ErrJournalExists
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func postLines(ctx context.Context, tx *sql.Tx, entries []entities.LedgerEntry) error {
+	stmt, err := tx.PrepareContext(ctx, insertLedgerEntry)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	for _, e := range entries {
+		args := []interface{}{e.Reference, e.VendorID, e.Account, e.Direction, e.Amount, e.Currency, e.Kind}
+		_, err = stmt.ExecContext(ctx, args...)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Repository) GetJournal(ctx context.Context, reference, kind string) ([]*entities.LedgerEntry, error) {
+	q := `SELECT entry_id, reference, vendor_id, account, direction, amount, currency, kind, created_at
+			FROM ledger_entry WHERE reference = ? AND kind = ? ORDER BY entry_id`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, reference, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	return scanLedgerEntries(rows)
+}
+
+// GetVendorBalances derives the available balance from the VENDOR_PAYABLE
+// account and the pending/paid amounts from the payout table, one row per
+// currency the vendor has been paid in.
+func (r *Repository) GetVendorBalances(ctx context.Context, vendorID int) ([]*entities.VendorBalance, error) {
+	q := `SELECT currency, SUM(available), SUM(pending), SUM(paid) FROM (
+			SELECT currency, CASE WHEN direction = 'CREDIT' THEN amount ELSE -amount END AS available,
+				0 AS pending, 0 AS paid
+				FROM ledger_entry WHERE vendor_id = ? AND account = 'VENDOR_PAYABLE'
+			UNION ALL
+			SELECT currency, 0, CASE WHEN status = 'PENDING' THEN amount ELSE 0 END,
+				CASE WHEN status = 'PAID' THEN amount ELSE 0 END
+				FROM payout WHERE vendor_id = ?
+		) balances GROUP BY currency ORDER BY currency`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, vendorID, vendorID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var balances []*entities.VendorBalance
+
+	for rows.Next() {
+		balance := entities.VendorBalance{VendorID: vendorID}
+		err = rows.Scan(&balance.Currency, &balance.Available, &balance.Pending, &balance.PaidOut)
+		if err != nil {
+			return nil, err
+		}
+
+		balances = append(balances, &balance)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %v", err)
+	}
+
+	return balances, nil
+}
+
+func (r *Repository) GetVendorsDueForPayout(ctx context.Context, minimum int64) ([]*entities.VendorBalance, error) {
+	q := `SELECT vendor_id, currency, SUM(CASE WHEN direction = 'CREDIT' THEN amount ELSE -amount END) AS available
+			FROM ledger_entry WHERE account = 'VENDOR_PAYABLE'
+			GROUP BY vendor_id, currency HAVING available >= ? AND available > 0`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, minimum)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var balances []*entities.VendorBalance
+
+	for rows.Next() {
+		var balance entities.VendorBalance
+		err = rows.Scan(&balance.VendorID, &balance.Currency, &balance.Available)
+		if err != nil {
+			return nil, err
+		}
+
+		balances = append(balances, &balance)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %v", err)
+	}
+
+	return balances, nil
+}
+
+// CreatePayout batches amount out of the vendor's payable balance into a
+// PENDING payout and moves it to the clearing account. The balance is read
+// again under lock, so two runs cannot pay out the same balance twice.
+func (r *Repository) CreatePayout(ctx context.Context, vendorID int, amount money.Money) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	defer tx.Rollback()
+
+	var available int64
+	q := `SELECT COALESCE(SUM(CASE WHEN direction = 'CREDIT' THEN amount ELSE -amount END), 0)
+			FROM ledger_entry WHERE vendor_id = ? AND account = 'VENDOR_PAYABLE' AND currency = ? FOR UPDATE`
+
+	err = tx.QueryRowContext(ctx, q, vendorID, amount.Currency).Scan(&available)
+	if err != nil {
+		return 0, err
+	}
+
+	if available < amount.Amount {
+		return 0, entities.ErrPayoutExceedsBalance
+	}
+
+	q = `INSERT INTO payout(vendor_id, amount, currency, status, created_at) VALUES (?, ?, ?, 'PENDING', NOW())`
+
+	result, err := tx.ExecContext(ctx, q, vendorID, amount.Amount, amount.Currency)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	reference := fmt.Sprintf("payout_%d", id)
+	entries := []entities.LedgerEntry{
+		{Reference: reference, VendorID: vendorID, Account: entities.AccountVendorPayable, Direction: entities.Debit, Amount: amount.Amount, Currency: amount.Currency, Kind: entities.LedgerKindPayout},
+		{Reference: reference, VendorID: vendorID, Account: entities.AccountPayoutClearing, Direction: entities.Credit, Amount: amount.Amount, Currency: amount.Currency, Kind: entities.LedgerKindPayout},
+	}
+
+	err = postLines(ctx, tx, entries)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// SettlePayout marks a PENDING payout as PAID and releases the clearing
+// account against cash. It returns the id of the vendor paid.
+func (r *Repository) SettlePayout(ctx context.Context, payoutID int) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	defer tx.Rollback()
+
+	var vendorID int
+	var amount int64
+	var currency string
+
+	q := `SELECT vendor_id, amount, currency FROM payout WHERE payout_id = ? AND status = 'PENDING' FOR UPDATE`
+	err = tx.QueryRowContext(ctx, q, payoutID).Scan(&vendorID, &amount, &currency)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("no pending payout %d", payoutID)
+		}
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE payout SET status = 'PAID', paid_at = NOW() WHERE payout_id = ?`, payoutID)
+	if err != nil {
+		return 0, err
+	}
+
+	reference := fmt.Sprintf("payout_%d", payoutID)
+	entries := []entities.LedgerEntry{
+		{Reference: reference, VendorID: vendorID, Account: entities.AccountPayoutClearing, Direction: entities.Debit, Amount: amount, Currency: currency, Kind: entities.LedgerKindPayoutSettled},
+		{Reference: reference, VendorID: vendorID, Account: entities.AccountCash, Direction: entities.Credit, Amount: amount, Currency: currency, Kind: entities.LedgerKindPayoutSettled},
+	}
+
+	err = postLines(ctx, tx, entries)
+	if isDuplicateKey(err) {
+		return 0, entities.ErrJournalExists
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return vendorID, tx.Commit()
+}
+
+func (r *Repository) GetVendorPayouts(ctx context.Context, vendorID int) ([]*entities.Payout, error) {
+	q := `SELECT payout_id, vendor_id, amount, currency, status, created_at, paid_at
+			FROM payout WHERE vendor_id = ? ORDER BY payout_id DESC`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, vendorID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var payouts []*entities.Payout
+
+	for rows.Next() {
+		var payout entities.Payout
+		var paidAt sql.NullTime
+		err = rows.Scan(&payout.ID, &payout.VendorID, &payout.Amount, &payout.Currency, &payout.Status, &payout.CreatedAt, &paidAt)
+		if err != nil {
+			return nil, err
+		}
+
+		if paidAt.Valid {
+			payout.PaidAt = &paidAt.Time
+		}
+
+		payouts = append(payouts, &payout)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %v", err)
+	}
+
+	return payouts, nil
+}
+
+// GetVendorStatement returns the vendor's ledger lines in [from, to).
+func (r *Repository) GetVendorStatement(ctx context.Context, vendorID int, from, to time.Time) ([]*entities.LedgerEntry, error) {
+	q := `SELECT entry_id, reference, vendor_id, account, direction, amount, currency, kind, created_at
+			FROM ledger_entry WHERE vendor_id = ? AND created_at >= ? AND created_at < ?
+			ORDER BY created_at, entry_id`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, vendorID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	return scanLedgerEntries(rows)
+}
+
+func scanLedgerEntries(rows *sql.Rows) ([]*entities.LedgerEntry, error) {
+	var entries []*entities.LedgerEntry
+
+	for rows.Next() {
+		var e entities.LedgerEntry
+		err := rows.Scan(&e.ID, &e.Reference, &e.VendorID, &e.Account, &e.Direction, &e.Amount, &e.Currency, &e.Kind, &e.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, &e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %v", err)
+	}
+
+	return entries, nil
+}
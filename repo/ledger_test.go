@@ -0,0 +1,275 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/money"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostJournal(t *testing.T) {
+	entries := []entities.LedgerEntry{
+		{Reference: "pi_1", VendorID: 7, Account: entities.AccountCash, Direction: entities.Debit, Amount: 1000, Currency: "KES", Kind: entities.LedgerKindPayment},
+		{Reference: "pi_1", VendorID: 7, Account: entities.AccountVendorPayable, Direction: entities.Credit, Amount: 1000, Currency: "KES", Kind: entities.LedgerKindPayment},
+	}
+
+	t.Run("success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		prep := mock.ExpectPrepare("INSERT INTO ledger_entry")
+		prep.ExpectExec().WithArgs("pi_1", 7, "CASH", "DEBIT", int64(1000), "KES", "PAYMENT").WillReturnResult(sqlmock.NewResult(1, 1))
+		prep.ExpectExec().WithArgs("pi_1", 7, "VENDOR_PAYABLE", "CREDIT", int64(1000), "KES", "PAYMENT").WillReturnResult(sqlmock.NewResult(2, 1))
+		mock.ExpectCommit()
+
+		repo := &Repository{db: db}
+		err = repo.PostJournal(context.Background(), entries)
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("failed line rolls back", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		prep := mock.ExpectPrepare("INSERT INTO ledger_entry")
+		prep.ExpectExec().WillReturnResult(sqlmock.NewResult(1, 1))
+		prep.ExpectExec().WillReturnError(sql.ErrConnDone)
+		mock.ExpectRollback()
+
+		repo := &Repository{db: db}
+		err = repo.PostJournal(context.Background(), entries)
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("journal already posted", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		prep := mock.ExpectPrepare("INSERT INTO ledger_entry")
+		prep.ExpectExec().WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"})
+		mock.ExpectRollback()
+
+		repo := &Repository{db: db}
+		err = repo.PostJournal(context.Background(), entries)
+		assert.ErrorIs(t, err, entities.ErrJournalExists)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGetJournal(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	now := time.Now()
+	mock.ExpectPrepare("SELECT entry_id, reference, vendor_id").
+		ExpectQuery().
+		WithArgs("pi_1", "PAYMENT").
+		WillReturnRows(sqlmock.NewRows([]string{"entry_id", "reference", "vendor_id", "account", "direction", "amount", "currency", "kind", "created_at"}).
+			AddRow(1, "pi_1", 7, "CASH", "DEBIT", 1000, "KES", "PAYMENT", now).
+			AddRow(2, "pi_1", 7, "VENDOR_PAYABLE", "CREDIT", 1000, "KES", "PAYMENT", now))
+
+	repo := &Repository{db: db}
+	lines, err := repo.GetJournal(context.Background(), "pi_1", "PAYMENT")
+	assert.NoError(t, err)
+	assert.Len(t, lines, 2)
+	assert.Equal(t, "VENDOR_PAYABLE", lines[1].Account)
+}
+
+func TestGetVendorBalances(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectPrepare("SELECT currency").
+			ExpectQuery().
+			WithArgs(7, 7).
+			WillReturnRows(sqlmock.NewRows([]string{"currency", "available", "pending", "paid"}).
+				AddRow("KES", 8500, 1000, 20000).
+				AddRow("USD", 300, 0, 0))
+
+		repo := &Repository{db: db}
+		balances, err := repo.GetVendorBalances(context.Background(), 7)
+		assert.NoError(t, err)
+		assert.Len(t, balances, 2)
+		assert.Equal(t, entities.VendorBalance{VendorID: 7, Currency: "KES", Available: 8500, Pending: 1000, PaidOut: 20000}, *balances[0])
+	})
+
+	t.Run("error", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectPrepare("SELECT").WillReturnError(sql.ErrConnDone)
+
+		repo := &Repository{db: db}
+		balances, err := repo.GetVendorBalances(context.Background(), 7)
+		assert.Error(t, err)
+		assert.Nil(t, balances)
+	})
+}
+
+func TestGetVendorsDueForPayout(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectPrepare("SELECT vendor_id").
+		ExpectQuery().
+		WithArgs(int64(500)).
+		WillReturnRows(sqlmock.NewRows([]string{"vendor_id", "currency", "available"}).AddRow(7, "KES", 8500).AddRow(9, "USD", 600))
+
+	repo := &Repository{db: db}
+	due, err := repo.GetVendorsDueForPayout(context.Background(), 500)
+	assert.NoError(t, err)
+	assert.Len(t, due, 2)
+	assert.Equal(t, int64(8500), due[0].Available)
+	assert.Equal(t, "USD", due[1].Currency)
+}
+
+func TestCreatePayout(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("FROM ledger_entry WHERE vendor_id = \\? AND account = 'VENDOR_PAYABLE' AND currency = \\? FOR UPDATE").
+			WithArgs(7, "KES").
+			WillReturnRows(sqlmock.NewRows([]string{"available"}).AddRow(8500))
+		mock.ExpectExec("INSERT INTO payout").WithArgs(7, int64(8500), "KES").WillReturnResult(sqlmock.NewResult(3, 1))
+		prep := mock.ExpectPrepare("INSERT INTO ledger_entry")
+		prep.ExpectExec().WithArgs("payout_3", 7, "VENDOR_PAYABLE", "DEBIT", int64(8500), "KES", "PAYOUT").WillReturnResult(sqlmock.NewResult(1, 1))
+		prep.ExpectExec().WithArgs("payout_3", 7, "PAYOUT_CLEARING", "CREDIT", int64(8500), "KES", "PAYOUT").WillReturnResult(sqlmock.NewResult(2, 1))
+		mock.ExpectCommit()
+
+		repo := &Repository{db: db}
+		id, err := repo.CreatePayout(context.Background(), 7, money.New(8500, "KES"))
+		assert.NoError(t, err)
+		assert.Equal(t, 3, id)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("insert error", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("FOR UPDATE").WithArgs(7, "KES").WillReturnRows(sqlmock.NewRows([]string{"available"}).AddRow(8500))
+		mock.ExpectExec("INSERT INTO payout").WillReturnError(sql.ErrConnDone)
+		mock.ExpectRollback()
+
+		repo := &Repository{db: db}
+		_, err = repo.CreatePayout(context.Background(), 7, money.New(8500, "KES"))
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("balance already paid out", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("FOR UPDATE").WithArgs(7, "KES").WillReturnRows(sqlmock.NewRows([]string{"available"}).AddRow(0))
+		mock.ExpectRollback()
+
+		repo := &Repository{db: db}
+		_, err = repo.CreatePayout(context.Background(), 7, money.New(8500, "KES"))
+		assert.ErrorIs(t, err, entities.ErrPayoutExceedsBalance)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestSettlePayout(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT vendor_id, amount, currency FROM payout").WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"vendor_id", "amount", "currency"}).AddRow(7, 8500, "KES"))
+		mock.ExpectExec("UPDATE payout SET status = 'PAID'").WithArgs(3).WillReturnResult(sqlmock.NewResult(0, 1))
+		prep := mock.ExpectPrepare("INSERT INTO ledger_entry")
+		prep.ExpectExec().WithArgs("payout_3", 7, "PAYOUT_CLEARING", "DEBIT", int64(8500), "KES", "PAYOUT_SETTLED").WillReturnResult(sqlmock.NewResult(1, 1))
+		prep.ExpectExec().WithArgs("payout_3", 7, "CASH", "CREDIT", int64(8500), "KES", "PAYOUT_SETTLED").WillReturnResult(sqlmock.NewResult(2, 1))
+		mock.ExpectCommit()
+
+		repo := &Repository{db: db}
+		vendorID, err := repo.SettlePayout(context.Background(), 3)
+		assert.NoError(t, err)
+		assert.Equal(t, 7, vendorID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("not pending", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT vendor_id, amount, currency FROM payout").WithArgs(3).WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
+
+		repo := &Repository{db: db}
+		_, err = repo.SettlePayout(context.Background(), 3)
+		assert.EqualError(t, err, "no pending payout 3")
+	})
+}
+
+func TestGetVendorPayouts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	now := time.Now()
+	mock.ExpectPrepare("SELECT payout_id").
+		ExpectQuery().
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"payout_id", "vendor_id", "amount", "currency", "status", "created_at", "paid_at"}).
+			AddRow(4, 7, 500, "KES", "PENDING", now, nil).
+			AddRow(3, 7, 8500, "KES", "PAID", now, now))
+
+	repo := &Repository{db: db}
+	payouts, err := repo.GetVendorPayouts(context.Background(), 7)
+	assert.NoError(t, err)
+	assert.Len(t, payouts, 2)
+	assert.Nil(t, payouts[0].PaidAt)
+	assert.NotNil(t, payouts[1].PaidAt)
+}
+
+func TestGetVendorStatement(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+	mock.ExpectPrepare("SELECT entry_id, reference, vendor_id").
+		ExpectQuery().
+		WithArgs(7, from, to).
+		WillReturnRows(sqlmock.NewRows([]string{"entry_id", "reference", "vendor_id", "account", "direction", "amount", "currency", "kind", "created_at"}).
+			AddRow(2, "pi_1", 7, "VENDOR_PAYABLE", "CREDIT", 850, "KES", "PAYMENT", from))
+
+	repo := &Repository{db: db}
+	entries, err := repo.GetVendorStatement(context.Background(), 7, from, to)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, int64(850), entries[0].Amount)
+}
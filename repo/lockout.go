@@ -0,0 +1,67 @@
+package repo
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// LoginLockRepository keeps failed login counts and account locks in Redis,
+// keyed by email so unknown addresses are treated like real ones.
+type LoginLockRepository interface {
+	GetLoginLock(ctx context.Context, email string) (time.Duration, error)
+	RecordFailedLogin(ctx context.Context, email string, ttl time.Duration) (int, error)
+	LockLogin(ctx context.Context, email string, d time.Duration) error
+	ClearLoginFailures(ctx context.Context, email string) error
+}
+
+func loginFailuresKey(email string) string {
+	return "login:failures:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func loginLockKey(email string) string {
+	return "login:lock:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// GetLoginLock returns how long the account stays locked, or 0 when it is
+// not locked.
+func (r *Repository) GetLoginLock(ctx context.Context, email string) (time.Duration, error) {
+	ttl, err := r.cache.PTTL(ctx, loginLockKey(email)).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	// Missing keys report negative TTLs.
+	if ttl < 0 {
+		return 0, nil
+	}
+
+	return ttl, nil
+}
+
+// RecordFailedLogin counts a failed login and returns the failures in a row.
+// The count is forgotten ttl after the last failure.
+func (r *Repository) RecordFailedLogin(ctx context.Context, email string, ttl time.Duration) (int, error) {
+	key := loginFailuresKey(email)
+
+	n, err := r.cache.Incr(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	err = r.cache.Expire(ctx, key, ttl).Err()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), nil
+}
+
+func (r *Repository) LockLogin(ctx context.Context, email string, d time.Duration) error {
+	return r.cache.Set(ctx, loginLockKey(email), 1, d).Err()
+}
+
+// ClearLoginFailures unlocks the account and resets its failure count.
+func (r *Repository) ClearLoginFailures(ctx context.Context, email string) error {
+	return r.cache.Del(ctx, loginFailuresKey(email), loginLockKey(email)).Err()
+}
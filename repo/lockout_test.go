@@ -0,0 +1,55 @@
+package repo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetLoginLock(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+	repo := NewDBRepository(nil, client)
+
+	mock.ExpectPTTL("login:lock:guest@gmail.com").SetVal(90 * time.Second)
+	mock.ExpectPTTL("login:lock:other@gmail.com").SetVal(-2 * time.Millisecond)
+
+	wait, err := repo.GetLoginLock(context.Background(), " Guest@Gmail.com")
+	assert.NoError(t, err)
+	assert.Equal(t, 90*time.Second, wait)
+
+	wait, err = repo.GetLoginLock(context.Background(), "other@gmail.com")
+	assert.NoError(t, err)
+	assert.Zero(t, wait)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecordFailedLogin(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+	repo := NewDBRepository(nil, client)
+
+	mock.ExpectIncr("login:failures:guest@gmail.com").SetVal(3)
+	mock.ExpectExpire("login:failures:guest@gmail.com", 24*time.Hour).SetVal(true)
+
+	failures, err := repo.RecordFailedLogin(context.Background(), "guest@gmail.com", 24*time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, failures)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLockAndClearLogin(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+	repo := NewDBRepository(nil, client)
+
+	mock.ExpectSet("login:lock:guest@gmail.com", 1, 2*time.Minute).SetVal("OK")
+	mock.ExpectDel("login:failures:guest@gmail.com", "login:lock:guest@gmail.com").SetVal(2)
+
+	err := repo.LockLogin(context.Background(), "guest@gmail.com", 2*time.Minute)
+	assert.NoError(t, err)
+
+	err = repo.ClearLoginFailures(context.Background(), "guest@gmail.com")
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
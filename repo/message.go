@@ -3,13 +3,14 @@ package repo
 import (
 	"context"
 	"fmt"
+	"log/slog"
 
 	"github.com/bicosteve/booking-system/entities"
-	"github.com/bicosteve/booking-system/pkg/utils"
 )
 
 type SMSRepository interface {
 	AddSMSOutbox(ctx context.Context, msg entities.SMSPayload) error
+	GetUserMessages(ctx context.Context, userID int) ([]*entities.OutboxMessage, error)
 }
 
 func (r *Repository) AddSMSOutbox(ctx context.Context, msg entities.SMSPayload) error {
@@ -17,7 +18,7 @@ func (r *Repository) AddSMSOutbox(ctx context.Context, msg entities.SMSPayload)
 
 	stmt, err := r.db.PrepareContext(ctx, q)
 	if err != nil {
-		utils.LogError(err.Error(), entities.ErrorLog)
+		slog.ErrorContext(ctx, "preparing sms outbox insert failed", "error", err)
 		return err
 	}
 
@@ -29,9 +30,42 @@ func (r *Repository) AddSMSOutbox(ctx context.Context, msg entities.SMSPayload)
 
 	_, err = stmt.ExecContext(ctx, args...)
 	if err != nil {
-		utils.LogError(err.Error(), entities.ErrorLog)
+		slog.ErrorContext(ctx, "inserting sms outbox message failed", "user_id", msg.UserID, "error", err)
 		return err
 	}
 
 	return nil
 }
+
+// GetUserMessages returns the text messages queued for a user.
+func (r *Repository) GetUserMessages(ctx context.Context, userID int) ([]*entities.OutboxMessage, error) {
+	q := `SELECT sms_id, msg, created_at FROM sms_outbox WHERE user_id = ? ORDER BY sms_id`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var messages []*entities.OutboxMessage
+
+	for rows.Next() {
+		var msg entities.OutboxMessage
+		err = rows.Scan(&msg.ID, &msg.Message, &msg.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, &msg)
+	}
+
+	return messages, rows.Err()
+}
@@ -0,0 +1,191 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/bicosteve/booking-system/entities"
+)
+
+type MFARepository interface {
+	GetUserMFA(ctx context.Context, userID int) (*entities.UserMFA, error)
+	SaveMFASecret(ctx context.Context, userID int, secret string) error
+	EnableMFA(ctx context.Context, userID int, step int64, codeHashes []string) error
+	UseMFAStep(ctx context.Context, userID int, step int64) (bool, error)
+	UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error)
+	DeleteMFA(ctx context.Context, userID int) error
+}
+
+// GetUserMFA returns the user's second factor, or ErrNoRecord if they have
+// not started enrolling.
+func (r *Repository) GetUserMFA(ctx context.Context, userID int) (*entities.UserMFA, error) {
+	q := `SELECT user_id, secret, enabled_at, last_step FROM user_mfa WHERE user_id = ?`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	var mfa entities.UserMFA
+	var enabledAt sql.NullTime
+
+	err = stmt.QueryRowContext(ctx, userID).Scan(&mfa.UserID, &mfa.Secret, &enabledAt, &mfa.LastStep)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, entities.ErrNoRecord
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if enabledAt.Valid {
+		mfa.EnabledAt = &enabledAt.Time
+	}
+
+	return &mfa, nil
+}
+
+// SaveMFASecret starts enrolment with a new secret, replacing one that was
+// never enabled. An enabled secret is left alone.
+func (r *Repository) SaveMFASecret(ctx context.Context, userID int, secret string) error {
+	q := `INSERT INTO user_mfa(user_id, secret, created_at) VALUES (?, ?, NOW())
+		ON DUPLICATE KEY UPDATE
+			secret = IF(enabled_at IS NULL, VALUES(secret), secret),
+			created_at = IF(enabled_at IS NULL, NOW(), created_at)`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, userID, secret)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// EnableMFA turns on the enrolled secret, recording step as used, and
+// replaces the user's recovery codes. It returns ErrMFAEnabled if the
+// secret is already on.
+func (r *Repository) EnableMFA(ctx context.Context, userID int, step int64, codeHashes []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `UPDATE user_mfa SET enabled_at = NOW(), last_step = ? WHERE user_id = ? AND enabled_at IS NULL`,
+		step, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return entities.ErrMFAEnabled
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM user_recovery_code WHERE user_id = ?`, userID)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO user_recovery_code(user_id, code_hash) VALUES (?, ?)`)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	for _, h := range codeHashes {
+		_, err = stmt.ExecContext(ctx, userID, h)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UseMFAStep records step as the last code used. It returns false if a code
+// from that step or a later one was already accepted.
+func (r *Repository) UseMFAStep(ctx context.Context, userID int, step int64) (bool, error) {
+	q := `UPDATE user_mfa SET last_step = ? WHERE user_id = ? AND last_step < ? AND enabled_at IS NOT NULL`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return false, err
+	}
+
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, step, userID, step)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// UseRecoveryCode marks a recovery code used. It returns false if the user
+// has no unused code with that hash.
+func (r *Repository) UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error) {
+	q := `UPDATE user_recovery_code SET used_at = NOW() WHERE user_id = ? AND code_hash = ? AND used_at IS NULL`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return false, err
+	}
+
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// DeleteMFA removes the user's second factor and recovery codes.
+func (r *Repository) DeleteMFA(ctx context.Context, userID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM user_recovery_code WHERE user_id = ?`, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM user_mfa WHERE user_id = ?`, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,112 @@
+package repo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+var userMFAColumns = []string{"user_id", "secret", "enabled_at", "last_step"}
+
+func TestGetUserMFA(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewDBRepository(db, nil)
+	now := time.Now()
+
+	mock.ExpectPrepare("FROM user_mfa").ExpectQuery().WithArgs(5).
+		WillReturnRows(sqlmock.NewRows(userMFAColumns).AddRow(5, "JBSWY3DPEHPK3PXP", now, 100))
+	mock.ExpectPrepare("FROM user_mfa").ExpectQuery().WithArgs(6).
+		WillReturnRows(sqlmock.NewRows(userMFAColumns))
+
+	mfa, err := repo.GetUserMFA(context.Background(), 5)
+	assert.NoError(t, err)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", mfa.Secret)
+	assert.NotNil(t, mfa.EnabledAt)
+	assert.Equal(t, int64(100), mfa.LastStep)
+
+	_, err = repo.GetUserMFA(context.Background(), 6)
+	assert.ErrorIs(t, err, entities.ErrNoRecord)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEnableMFA(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewDBRepository(db, nil)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE user_mfa SET enabled_at").WithArgs(int64(100), 5).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM user_recovery_code").WithArgs(5).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectPrepare("INSERT INTO user_recovery_code")
+	mock.ExpectExec("INSERT INTO user_recovery_code").WithArgs(5, "h1").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO user_recovery_code").WithArgs(5, "h2").WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+
+	err = repo.EnableMFA(context.Background(), 5, 100, []string{"h1", "h2"})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEnableMFA_AlreadyEnabled(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewDBRepository(db, nil)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE user_mfa SET enabled_at").WithArgs(int64(100), 5).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	err = repo.EnableMFA(context.Background(), 5, 100, []string{"h1"})
+	assert.ErrorIs(t, err, entities.ErrMFAEnabled)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUseMFAStep(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewDBRepository(db, nil)
+
+	mock.ExpectPrepare("UPDATE user_mfa SET last_step").ExpectExec().WithArgs(int64(101), 5, int64(101)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectPrepare("UPDATE user_mfa SET last_step").ExpectExec().WithArgs(int64(101), 5, int64(101)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	ok, err := repo.UseMFAStep(context.Background(), 5, 101)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// The same code again is a replay.
+	ok, err = repo.UseMFAStep(context.Background(), 5, 101)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUseRecoveryCode(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewDBRepository(db, nil)
+
+	mock.ExpectPrepare("UPDATE user_recovery_code SET used_at").ExpectExec().WithArgs(5, "h1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	ok, err := repo.UseRecoveryCode(context.Background(), 5, "h1")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
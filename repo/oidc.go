@@ -0,0 +1,133 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/redis/go-redis/v9"
+)
+
+type OIDCRepository interface {
+	SaveOIDCState(ctx context.Context, state string, data entities.OIDCState, ttl time.Duration) error
+	TakeOIDCState(ctx context.Context, state string) (*entities.OIDCState, error)
+	FindIdentityEmail(ctx context.Context, provider, subject string) (string, error)
+	LinkIdentity(ctx context.Context, userID int, provider, subject, email string) error
+	GetUserIdentities(ctx context.Context, userID int) ([]*entities.UserIdentity, error)
+}
+
+func oidcStateKey(state string) string {
+	return "oidc:state:" + state
+}
+
+// SaveOIDCState keeps a login in progress until its callback, for at most
+// ttl.
+func (r *Repository) SaveOIDCState(ctx context.Context, state string, data entities.OIDCState, ttl time.Duration) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	return r.cache.Set(ctx, oidcStateKey(state), b, ttl).Err()
+}
+
+// TakeOIDCState returns and deletes a login in progress, so a callback
+// cannot be replayed. It returns ErrNoRecord if there is none.
+func (r *Repository) TakeOIDCState(ctx context.Context, state string) (*entities.OIDCState, error) {
+	b, err := r.cache.GetDel(ctx, oidcStateKey(state)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, entities.ErrNoRecord
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var data entities.OIDCState
+
+	err = json.Unmarshal(b, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &data, nil
+}
+
+// FindIdentityEmail returns the current email of the user an external
+// identity is linked to, or ErrNoRecord if it is not linked.
+func (r *Repository) FindIdentityEmail(ctx context.Context, provider, subject string) (string, error) {
+	q := `SELECT u.email FROM user_identity i JOIN user u ON u.user_id = i.user_id
+		WHERE i.provider = ? AND i.subject = ?`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return "", err
+	}
+
+	defer stmt.Close()
+
+	var email string
+
+	err = stmt.QueryRowContext(ctx, provider, subject).Scan(&email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", entities.ErrNoRecord
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return email, nil
+}
+
+func (r *Repository) LinkIdentity(ctx context.Context, userID int, provider, subject, email string) error {
+	q := `INSERT INTO user_identity(user_id, provider, subject, email, created_at) VALUES (?, ?, ?, ?, NOW())`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, userID, provider, subject, email)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetUserIdentities returns the external logins linked to a user.
+func (r *Repository) GetUserIdentities(ctx context.Context, userID int) ([]*entities.UserIdentity, error) {
+	q := `SELECT provider, subject, email, created_at FROM user_identity WHERE user_id = ? ORDER BY identity_id`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var identities []*entities.UserIdentity
+
+	for rows.Next() {
+		var identity entities.UserIdentity
+		err = rows.Scan(&identity.Provider, &identity.Subject, &identity.Email, &identity.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		identities = append(identities, &identity)
+	}
+
+	return identities, rows.Err()
+}
@@ -0,0 +1,69 @@
+package repo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/go-redis/redismock/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOIDCState(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+	repo := NewDBRepository(nil, client)
+	data := entities.OIDCState{Provider: "google", Verifier: "v", Nonce: "n"}
+
+	mock.ExpectSet("oidc:state:abc", []byte(`{"provider":"google","verifier":"v","nonce":"n"}`), 10*time.Minute).SetVal("OK")
+	mock.ExpectGetDel("oidc:state:abc").SetVal(`{"provider":"google","verifier":"v","nonce":"n"}`)
+	mock.ExpectGetDel("oidc:state:abc").RedisNil()
+
+	err := repo.SaveOIDCState(context.Background(), "abc", data, 10*time.Minute)
+	assert.NoError(t, err)
+
+	got, err := repo.TakeOIDCState(context.Background(), "abc")
+	assert.NoError(t, err)
+	assert.Equal(t, data, *got)
+
+	_, err = repo.TakeOIDCState(context.Background(), "abc")
+	assert.ErrorIs(t, err, entities.ErrNoRecord)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFindIdentityEmail(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewDBRepository(db, nil)
+
+	mock.ExpectPrepare("FROM user_identity i JOIN user u").ExpectQuery().WithArgs("google", "sub-1").
+		WillReturnRows(sqlmock.NewRows([]string{"email"}).AddRow("guest@gmail.com"))
+	mock.ExpectPrepare("FROM user_identity i JOIN user u").ExpectQuery().WithArgs("google", "sub-2").
+		WillReturnRows(sqlmock.NewRows([]string{"email"}))
+
+	email, err := repo.FindIdentityEmail(context.Background(), "google", "sub-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "guest@gmail.com", email)
+
+	_, err = repo.FindIdentityEmail(context.Background(), "google", "sub-2")
+	assert.ErrorIs(t, err, entities.ErrNoRecord)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLinkIdentity(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewDBRepository(db, nil)
+
+	mock.ExpectPrepare("INSERT INTO user_identity").ExpectExec().WithArgs(5, "google", "sub-1", "guest@gmail.com").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err = repo.LinkIdentity(context.Background(), 5, "google", "sub-1", "guest@gmail.com")
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
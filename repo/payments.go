@@ -9,11 +9,13 @@ import (
 type PayRepository interface {
 	SaveTransactions(ctx context.Context, data *entities.TRXPayload) error
 	UpdateTransactions(ctx context.Context, data *entities.TRXPayload) error
+	FindTransaction(ctx context.Context, trxID string) (*entities.Transaction, error)
+	GetUserTransactions(ctx context.Context, userID int) ([]*entities.Transaction, error)
 }
 
 func (r *Repository) SaveTransactions(ctx context.Context, data *entities.TRXPayload) error {
 
-	q := `INSERT INTO transaction(room_id,user_id,order_id, trx_id,reference,amount,status,created_at,updated_at) VALUES(?,?,?,?,?,?,?,NOW(),NOW())`
+	q := `INSERT INTO transaction(room_id,user_id,order_id, trx_id,reference,amount,currency,status,created_at,updated_at) VALUES(?,?,?,?,?,?,?,?,NOW(),NOW())`
 
 	stmt, err := r.db.PrepareContext(ctx, q)
 	if err != nil {
@@ -22,9 +24,12 @@ func (r *Repository) SaveTransactions(ctx context.Context, data *entities.TRXPay
 
 	defer stmt.Close()
 
-	args := []interface{}{data.RoomID, data.UserID, data.OrderID, data.TrxID, data.Reference, data.Payment.Amount, data.Status}
+	args := []interface{}{data.RoomID, data.UserID, data.OrderID, data.TrxID, data.Reference, data.Payment.Amount, data.Payment.Currency, data.Status}
 
 	_, err = stmt.ExecContext(ctx, args...)
+	if isDuplicateKey(err) {
+		return entities.ErrDuplicateTransaction
+	}
 	if err != nil {
 		return err
 	}
@@ -49,3 +54,59 @@ func (r *Repository) UpdateTransactions(ctx context.Context, status int, trx_id
 
 	return nil
 }
+
+func (r *Repository) FindTransaction(ctx context.Context, trxID string) (*entities.Transaction, error) {
+	q := `SELECT transaction_id, user_id, room_id, order_id, trx_id, reference, amount, currency, status, created_at, updated_at
+			FROM transaction WHERE trx_id = ? ORDER BY transaction_id DESC LIMIT 1`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	var trx entities.Transaction
+
+	row := stmt.QueryRowContext(ctx, trxID)
+	err = row.Scan(&trx.ID, &trx.UserID, &trx.RoomID, &trx.OrderID, &trx.TrxID, &trx.Reference, &trx.Amount.Amount, &trx.Amount.Currency, &trx.Status, &trx.CreatedAt, &trx.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &trx, nil
+}
+
+// GetUserTransactions returns the user's payments, oldest first.
+func (r *Repository) GetUserTransactions(ctx context.Context, userID int) ([]*entities.Transaction, error) {
+	q := `SELECT transaction_id, user_id, room_id, order_id, trx_id, reference, amount, currency, status, created_at, updated_at
+			FROM transaction WHERE user_id = ? ORDER BY transaction_id`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var transactions []*entities.Transaction
+
+	for rows.Next() {
+		var trx entities.Transaction
+		err = rows.Scan(&trx.ID, &trx.UserID, &trx.RoomID, &trx.OrderID, &trx.TrxID, &trx.Reference, &trx.Amount.Amount, &trx.Amount.Currency, &trx.Status, &trx.CreatedAt, &trx.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		transactions = append(transactions, &trx)
+	}
+
+	return transactions, rows.Err()
+}
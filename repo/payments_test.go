@@ -4,9 +4,12 @@ import (
 	"context"
 	"database/sql"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/money"
+	"github.com/go-sql-driver/mysql"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -18,7 +21,7 @@ func TestSaveTransactions(t *testing.T) {
 		TrxID:     "trx-1",
 		Reference: "ref-1",
 		Status:    1,
-		Payment:   entities.PaymentBody{Amount: 200},
+		Payment:   entities.PaymentBody{Amount: 200, Currency: "KES"},
 	}
 
 	tests := []struct {
@@ -32,7 +35,7 @@ func TestSaveTransactions(t *testing.T) {
 			setup: func(mock sqlmock.Sqlmock) {
 				mock.ExpectPrepare("INSERT INTO transaction").
 					ExpectExec().
-					WithArgs(10, 5, "order-1", "trx-1", "ref-1", int64(200), 1).
+					WithArgs(10, 5, "order-1", "trx-1", "ref-1", int64(200), "KES", 1).
 					WillReturnResult(sqlmock.NewResult(1, 1))
 			},
 		},
@@ -49,7 +52,7 @@ func TestSaveTransactions(t *testing.T) {
 			setup: func(mock sqlmock.Sqlmock) {
 				mock.ExpectPrepare("INSERT INTO transaction").
 					ExpectExec().
-					WithArgs(10, 5, "order-1", "trx-1", "ref-1", int64(200), 1).
+					WithArgs(10, 5, "order-1", "trx-1", "ref-1", int64(200), "KES", 1).
 					WillReturnError(sql.ErrNoRows)
 			},
 		},
@@ -75,6 +78,21 @@ func TestSaveTransactions(t *testing.T) {
 	}
 }
 
+func TestSaveTransactions_Duplicate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectPrepare("INSERT INTO transaction").
+		ExpectExec().
+		WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'trx-1' for key 'uq_transaction_trx_id'"})
+
+	repo := &Repository{db: db}
+	err = repo.SaveTransactions(context.Background(), &entities.TRXPayload{TrxID: "trx-1"})
+	assert.ErrorIs(t, err, entities.ErrDuplicateTransaction)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestUpdateTransactions(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -129,3 +147,42 @@ func TestUpdateTransactions(t *testing.T) {
 		})
 	}
 }
+
+func TestFindTransaction(t *testing.T) {
+	now := time.Now()
+
+	t.Run("found", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectPrepare("SELECT transaction_id").
+			ExpectQuery().
+			WithArgs("pi_1").
+			WillReturnRows(sqlmock.NewRows([]string{"transaction_id", "user_id", "room_id", "order_id", "trx_id", "reference", "amount", "currency", "status", "created_at", "updated_at"}).
+				AddRow(1, 5, 10, "order-1", "pi_1", "ref-1", 200, "KES", 1, now, now))
+
+		repo := &Repository{db: db}
+		trx, err := repo.FindTransaction(context.Background(), "pi_1")
+		assert.NoError(t, err)
+		assert.Equal(t, "pi_1", trx.TrxID)
+		assert.Equal(t, 10, trx.RoomID)
+		assert.Equal(t, money.New(200, "KES"), trx.Amount)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectPrepare("SELECT transaction_id").
+			ExpectQuery().
+			WithArgs("pi_1").
+			WillReturnError(sql.ErrNoRows)
+
+		repo := &Repository{db: db}
+		trx, err := repo.FindTransaction(context.Background(), "pi_1")
+		assert.Error(t, err)
+		assert.Nil(t, trx)
+	})
+}
@@ -0,0 +1,199 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/redis/go-redis/v9"
+)
+
+type ProfileRepository interface {
+	UpdateUserName(ctx context.Context, userID int, name string) error
+	UpdateUserPhone(ctx context.Context, userID int, phone string) error
+	UpdateUserEmail(ctx context.Context, userID int, email string) error
+	SavePendingChange(ctx context.Context, kind, id string, change entities.PendingChange, ttl time.Duration) error
+	GetPendingChange(ctx context.Context, kind, id string) (*entities.PendingChange, error)
+	UpdatePendingChange(ctx context.Context, kind, id string, change entities.PendingChange) error
+	DeletePendingChange(ctx context.Context, kind, id string) error
+	CountActiveBookings(ctx context.Context, userID int) (int, error)
+	AnonymizeUser(ctx context.Context, userID int) error
+}
+
+func pendingChangeKey(kind, id string) string {
+	return "profile:" + kind + ":" + id
+}
+
+func (r *Repository) UpdateUserName(ctx context.Context, userID int, name string) error {
+	return r.updateUser(ctx, `UPDATE user SET name = ?, updated_at = NOW() WHERE user_id = ? AND deleted_at IS NULL`,
+		name, userID)
+}
+
+// UpdateUserPhone sets a phone number the user has just confirmed. It
+// returns ErrDuplicatePhone if another account uses it.
+func (r *Repository) UpdateUserPhone(ctx context.Context, userID int, phone string) error {
+	err := r.updateUser(ctx, `UPDATE user SET phone_number = ?, phone_verified_at = NOW(), updated_at = NOW()
+		WHERE user_id = ? AND deleted_at IS NULL`, phone, userID)
+	if isDuplicateKey(err) {
+		return entities.ErrDuplicatePhone
+	}
+
+	return err
+}
+
+// UpdateUserEmail returns ErrDuplicateEmail if another account uses email.
+func (r *Repository) UpdateUserEmail(ctx context.Context, userID int, email string) error {
+	err := r.updateUser(ctx, `UPDATE user SET email = ?, updated_at = NOW() WHERE user_id = ? AND deleted_at IS NULL`,
+		email, userID)
+	if isDuplicateKey(err) {
+		return entities.ErrDuplicateEmail
+	}
+
+	return err
+}
+
+// updateUser runs an update of one user, returning ErrNoRecord if it
+// matched no row.
+func (r *Repository) updateUser(ctx context.Context, q string, args ...any) error {
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, args...)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return entities.ErrNoRecord
+	}
+
+	return nil
+}
+
+// SavePendingChange keeps a change waiting for confirmation for at most
+// ttl, replacing an earlier one with the same kind and id.
+func (r *Repository) SavePendingChange(ctx context.Context, kind, id string, change entities.PendingChange, ttl time.Duration) error {
+	b, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+
+	return r.cache.Set(ctx, pendingChangeKey(kind, id), b, ttl).Err()
+}
+
+// GetPendingChange returns ErrNoRecord if there is no change waiting or it
+// has expired.
+func (r *Repository) GetPendingChange(ctx context.Context, kind, id string) (*entities.PendingChange, error) {
+	b, err := r.cache.Get(ctx, pendingChangeKey(kind, id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, entities.ErrNoRecord
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var change entities.PendingChange
+
+	err = json.Unmarshal(b, &change)
+	if err != nil {
+		return nil, err
+	}
+
+	return &change, nil
+}
+
+// UpdatePendingChange rewrites a waiting change without extending its
+// expiry.
+func (r *Repository) UpdatePendingChange(ctx context.Context, kind, id string, change entities.PendingChange) error {
+	b, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+
+	return r.cache.SetArgs(ctx, pendingChangeKey(kind, id), b, redis.SetArgs{Mode: "XX", KeepTTL: true}).Err()
+}
+
+func (r *Repository) DeletePendingChange(ctx context.Context, kind, id string) error {
+	return r.cache.Del(ctx, pendingChangeKey(kind, id)).Err()
+}
+
+// CountActiveBookings counts the user's confirmed and checked in bookings.
+func (r *Repository) CountActiveBookings(ctx context.Context, userID int) (int, error) {
+	q := `SELECT COUNT(*) FROM booking WHERE user_id = ? AND status IN (?, ?)`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return 0, err
+	}
+
+	defer stmt.Close()
+
+	var count int
+
+	err = stmt.QueryRowContext(ctx, userID, entities.BookingStatusConfirmed, entities.BookingStatusCheckedIn).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// AnonymizeUser deletes an account. The user row stays, since bookings,
+// transactions and reviews reference it, but its email, phone number, name
+// and password are replaced. Second factors, linked logins and queued
+// messages are removed and API keys revoked. It returns ErrNoRecord if the account is already
+// deleted.
+func (r *Repository) AnonymizeUser(ctx context.Context, userID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	id := strconv.Itoa(userID)
+
+	res, err := tx.ExecContext(ctx, `UPDATE user SET email = ?, phone_number = ?, name = '', hashed_password = '',
+			password_reset_token = '', phone_verified_at = NULL, password_inserted_at = UTC_TIMESTAMP(), deleted_at = NOW(), updated_at = NOW()
+		WHERE user_id = ? AND deleted_at IS NULL`,
+		"deleted-"+id+"@deleted.invalid", "d"+id, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return entities.ErrNoRecord
+	}
+
+	for _, q := range []string{
+		`DELETE FROM user_recovery_code WHERE user_id = ?`,
+		`DELETE FROM user_mfa WHERE user_id = ?`,
+		`DELETE FROM user_identity WHERE user_id = ?`,
+		`DELETE FROM sms_outbox WHERE user_id = ?`,
+		`UPDATE api_key SET revoked_at = NOW() WHERE vendor_id = ? AND revoked_at IS NULL`,
+	} {
+		_, err = tx.ExecContext(ctx, q, userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
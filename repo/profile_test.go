@@ -0,0 +1,142 @@
+package repo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/go-redis/redismock/v9"
+	"github.com/go-sql-driver/mysql"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindProfileByID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewDBRepository(db, nil)
+	now := time.Now()
+	columns := []string{"user_id", "email", "phone_number", "isVender", "hashed_password", "password_reset_token",
+		"created_at", "updated_at", "password_inserted_at", "name", "phone_verified_at"}
+
+	mock.ExpectPrepare("SELECT user_id, email").ExpectQuery().WithArgs(7).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("7", "guest@gmail.com", "0712345678", "NO", "hash", "", now, now, now, "Jane", now))
+	mock.ExpectPrepare("SELECT user_id, email").ExpectQuery().WithArgs(8).
+		WillReturnRows(sqlmock.NewRows(columns))
+
+	user, err := repo.FindProfileByID(context.Background(), 7)
+	assert.NoError(t, err)
+	assert.Equal(t, "Jane", user.Name)
+	assert.NotNil(t, user.PhoneVerifiedAt)
+
+	_, err = repo.FindProfileByID(context.Background(), 8)
+	assert.ErrorIs(t, err, entities.ErrNoRecord)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateUserPhone(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewDBRepository(db, nil)
+
+	mock.ExpectPrepare("UPDATE user SET phone_number").ExpectExec().WithArgs("0712345678", 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectPrepare("UPDATE user SET phone_number").ExpectExec().WithArgs("0799999999", 7).
+		WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry '0799999999' for key 'phone_number'"})
+	mock.ExpectPrepare("UPDATE user SET phone_number").ExpectExec().WithArgs("0712345678", 8).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, repo.UpdateUserPhone(context.Background(), 7, "0712345678"))
+	assert.ErrorIs(t, repo.UpdateUserPhone(context.Background(), 7, "0799999999"), entities.ErrDuplicatePhone)
+	assert.ErrorIs(t, repo.UpdateUserPhone(context.Background(), 8, "0712345678"), entities.ErrNoRecord)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPendingChange(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+	repo := NewDBRepository(nil, client)
+	change := entities.PendingChange{UserID: 7, Value: "0712345678", CodeHash: "h"}
+	stored := `{"user_id":7,"value":"0712345678","code_hash":"h","attempts":0}`
+	retried := `{"user_id":7,"value":"0712345678","code_hash":"h","attempts":1}`
+
+	mock.ExpectSet("profile:phone:7", []byte(stored), 10*time.Minute).SetVal("OK")
+	mock.ExpectGet("profile:phone:7").SetVal(stored)
+	mock.ExpectSetArgs("profile:phone:7", []byte(retried), redis.SetArgs{Mode: "XX", KeepTTL: true}).SetVal("OK")
+	mock.ExpectDel("profile:phone:7").SetVal(1)
+	mock.ExpectGet("profile:phone:7").RedisNil()
+
+	ctx := context.Background()
+	assert.NoError(t, repo.SavePendingChange(ctx, "phone", "7", change, 10*time.Minute))
+
+	got, err := repo.GetPendingChange(ctx, "phone", "7")
+	assert.NoError(t, err)
+	assert.Equal(t, change, *got)
+
+	got.Attempts++
+	assert.NoError(t, repo.UpdatePendingChange(ctx, "phone", "7", *got))
+	assert.NoError(t, repo.DeletePendingChange(ctx, "phone", "7"))
+
+	_, err = repo.GetPendingChange(ctx, "phone", "7")
+	assert.ErrorIs(t, err, entities.ErrNoRecord)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAnonymizeUser(t *testing.T) {
+	t.Run("anonymized", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE user SET email(.|\\s)+password_inserted_at = UTC_TIMESTAMP\\(\\), deleted_at = NOW\\(\\)").
+			WithArgs("deleted-7@deleted.invalid", "d7", 7).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("DELETE FROM user_recovery_code").WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 10))
+		mock.ExpectExec("DELETE FROM user_mfa").WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("DELETE FROM user_identity").WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("DELETE FROM sms_outbox").WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec("UPDATE api_key SET revoked_at = NOW\\(\\) WHERE vendor_id = \\? AND revoked_at IS NULL").
+			WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err = NewDBRepository(db, nil).AnonymizeUser(context.Background(), 7)
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("already deleted", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE user SET email").WithArgs("deleted-7@deleted.invalid", "d7", 7).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		err = NewDBRepository(db, nil).AnonymizeUser(context.Background(), 7)
+		assert.ErrorIs(t, err, entities.ErrNoRecord)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestCountActiveBookings(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectPrepare("SELECT COUNT.* FROM booking").ExpectQuery().
+		WithArgs(7, entities.BookingStatusConfirmed, entities.BookingStatusCheckedIn).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+	n, err := NewDBRepository(db, nil).CountActiveBookings(context.Background(), 7)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,264 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/bicosteve/booking-system/entities"
+)
+
+type ReviewRepository interface {
+	CreateReview(ctx context.Context, review entities.Review) error
+	ReviewExists(ctx context.Context, bookingID int) (bool, error)
+	GetReviewByID(ctx context.Context, reviewID int) (*entities.Review, error)
+	GetRoomReviews(ctx context.Context, roomID int) ([]*entities.Review, error)
+	GetVendorReviews(ctx context.Context, vendorID int) ([]*entities.Review, error)
+	GetUserReviews(ctx context.Context, userID int) ([]*entities.Review, error)
+	GetVendorRating(ctx context.Context, vendorID int) (*entities.RatingSummary, error)
+	ReplyToReview(ctx context.Context, reviewID, vendorID int, reply string) error
+	FlagReview(ctx context.Context, reviewID int, flagged bool, reason string) error
+}
+
+const selectReview = `SELECT review_id, booking_id, room_id, vendor_id, user_id,
+				cleanliness, comfort, location, value, rating, body,
+				vendor_reply, replied_at, flagged, flag_reason, created_at, updated_at
+			FROM review`
+
+func (r *Repository) CreateReview(ctx context.Context, review entities.Review) error {
+	q := `INSERT INTO review(booking_id, room_id, vendor_id, user_id, cleanliness, comfort, location, value,
+			rating, body, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NOW(), NOW())`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	args := []interface{}{
+		review.BookingID, review.RoomID, review.VendorID, review.UserID,
+		review.Ratings.Cleanliness, review.Ratings.Comfort, review.Ratings.Location, review.Ratings.Value,
+		review.Rating, review.Text,
+	}
+
+	_, err = stmt.ExecContext(ctx, args...)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *Repository) ReviewExists(ctx context.Context, bookingID int) (bool, error) {
+	q := `SELECT COUNT(*) FROM review WHERE booking_id = ?`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return false, err
+	}
+
+	defer stmt.Close()
+
+	var count int
+	err = stmt.QueryRowContext(ctx, bookingID).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+func (r *Repository) GetReviewByID(ctx context.Context, reviewID int) (*entities.Review, error) {
+	q := selectReview + ` WHERE review_id = ?`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	review, err := scanReview(stmt.QueryRowContext(ctx, reviewID))
+	if err != nil {
+		return nil, err
+	}
+
+	return review, nil
+}
+
+// GetRoomReviews returns the reviews guests may see, newest first.
+func (r *Repository) GetRoomReviews(ctx context.Context, roomID int) ([]*entities.Review, error) {
+	q := selectReview + ` WHERE room_id = ? AND flagged = FALSE ORDER BY created_at DESC`
+
+	return r.queryReviews(ctx, q, roomID)
+}
+
+// GetVendorReviews returns every review on a vendor's rooms, including the
+// flagged ones, for moderation.
+func (r *Repository) GetVendorReviews(ctx context.Context, vendorID int) ([]*entities.Review, error) {
+	q := selectReview + ` WHERE vendor_id = ? ORDER BY created_at DESC`
+
+	return r.queryReviews(ctx, q, vendorID)
+}
+
+// GetUserReviews returns the reviews a guest wrote, newest first.
+func (r *Repository) GetUserReviews(ctx context.Context, userID int) ([]*entities.Review, error) {
+	q := selectReview + ` WHERE user_id = ? ORDER BY created_at DESC`
+
+	return r.queryReviews(ctx, q, userID)
+}
+
+func (r *Repository) GetVendorRating(ctx context.Context, vendorID int) (*entities.RatingSummary, error) {
+	q := `SELECT COALESCE(ROUND(AVG(rating), 2), 0), COUNT(*),
+				COALESCE(ROUND(AVG(cleanliness), 2), 0), COALESCE(ROUND(AVG(comfort), 2), 0),
+				COALESCE(ROUND(AVG(location), 2), 0), COALESCE(ROUND(AVG(value), 2), 0)
+			FROM review WHERE vendor_id = ? AND flagged = FALSE`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	var summary entities.RatingSummary
+
+	row := stmt.QueryRowContext(ctx, vendorID)
+
+	err = row.Scan(&summary.Rating, &summary.ReviewCount, &summary.Cleanliness, &summary.Comfort, &summary.Location, &summary.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	return &summary, nil
+}
+
+func (r *Repository) ReplyToReview(ctx context.Context, reviewID, vendorID int, reply string) error {
+	q := `UPDATE review SET vendor_reply = ?, replied_at = NOW(), updated_at = NOW()
+			WHERE review_id = ? AND vendor_id = ?`
+
+	return r.updateReview(ctx, q, reviewID, vendorID, reply)
+}
+
+// FlagReview flags or unflags any review. Only platform admins get here, so
+// unlike replies it is not limited to one vendor's reviews.
+func (r *Repository) FlagReview(ctx context.Context, reviewID int, flagged bool, reason string) error {
+	q := `UPDATE review SET flagged = ?, flag_reason = ?, updated_at = NOW() WHERE review_id = ?`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	result, err := stmt.ExecContext(ctx, flagged, reason, reviewID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected < 1 {
+		return fmt.Errorf("review %d not found", reviewID)
+	}
+
+	return nil
+}
+
+// updateReview runs q with values followed by the review and vendor ids, so a
+// vendor can only change reviews of their own rooms.
+func (r *Repository) updateReview(ctx context.Context, q string, reviewID, vendorID int, values ...interface{}) error {
+	args := append(values, reviewID, vendorID)
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	result, err := stmt.ExecContext(ctx, args...)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected < 1 {
+		return fmt.Errorf("review %d not found for vendor %d", reviewID, vendorID)
+	}
+
+	return nil
+}
+
+func (r *Repository) queryReviews(ctx context.Context, q string, id int) ([]*entities.Review, error) {
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var reviews []*entities.Review
+
+	for rows.Next() {
+		review, err := scanReview(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		reviews = append(reviews, review)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %v", err)
+	}
+
+	return reviews, nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanReview(row scanner) (*entities.Review, error) {
+	var review entities.Review
+	var reply sql.NullString
+	var repliedAt sql.NullTime
+
+	err := row.Scan(
+		&review.ID, &review.BookingID, &review.RoomID, &review.VendorID, &review.UserID,
+		&review.Ratings.Cleanliness, &review.Ratings.Comfort, &review.Ratings.Location, &review.Ratings.Value,
+		&review.Rating, &review.Text, &reply, &repliedAt, &review.Flagged, &review.FlagReason,
+		&review.CreatedAt, &review.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if reply.Valid {
+		review.VendorReply = &reply.String
+	}
+
+	if repliedAt.Valid {
+		review.RepliedAt = &repliedAt.Time
+	}
+
+	return &review, nil
+}
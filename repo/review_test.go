@@ -0,0 +1,184 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+var reviewColumns = []string{
+	"review_id", "booking_id", "room_id", "vendor_id", "user_id",
+	"cleanliness", "comfort", "location", "value", "rating", "body",
+	"vendor_reply", "replied_at", "flagged", "flag_reason", "created_at", "updated_at",
+}
+
+func TestCreateReview(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectPrepare("INSERT INTO review").
+		ExpectExec().
+		WithArgs(100, 10, 7, 5, 5, 4, 4, 3, 4.0, "Great stay").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	repo := &Repository{db: db}
+	err = repo.CreateReview(context.Background(), entities.Review{
+		BookingID: 100, RoomID: 10, VendorID: 7, UserID: 5,
+		Ratings: entities.ReviewRatings{Cleanliness: 5, Comfort: 4, Location: 4, Value: 3},
+		Rating:  4.0, Text: "Great stay",
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReviewExists(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectPrepare("SELECT COUNT\\(\\*\\) FROM review WHERE booking_id = \\?").
+		ExpectQuery().
+		WithArgs(100).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	repo := &Repository{db: db}
+	exists, err := repo.ReviewExists(context.Background(), 100)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func TestGetRoomReviews(t *testing.T) {
+	mockTime := time.Now()
+
+	t.Run("returns visible reviews", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectPrepare("SELECT review_id, (.+) FROM review WHERE room_id = \\? AND flagged = FALSE").
+			ExpectQuery().
+			WithArgs(10).
+			WillReturnRows(sqlmock.NewRows(reviewColumns).
+				AddRow(1, 100, 10, 7, 5, 5, 4, 4, 3, 4.0, "Great stay", "Thanks!", mockTime, false, "", mockTime, mockTime).
+				AddRow(2, 101, 10, 7, 6, 3, 3, 3, 3, 3.0, "Fine", nil, nil, false, "", mockTime, mockTime))
+
+		repo := &Repository{db: db}
+		reviews, err := repo.GetRoomReviews(context.Background(), 10)
+		assert.NoError(t, err)
+		assert.Len(t, reviews, 2)
+		assert.Equal(t, "Thanks!", *reviews[0].VendorReply)
+		assert.NotNil(t, reviews[0].RepliedAt)
+		assert.Equal(t, entities.ReviewRatings{Cleanliness: 5, Comfort: 4, Location: 4, Value: 3}, reviews[0].Ratings)
+		assert.Nil(t, reviews[1].VendorReply)
+		assert.Nil(t, reviews[1].RepliedAt)
+	})
+
+	t.Run("query error", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectPrepare("SELECT review_id").
+			ExpectQuery().
+			WithArgs(10).
+			WillReturnError(sql.ErrConnDone)
+
+		repo := &Repository{db: db}
+		reviews, err := repo.GetRoomReviews(context.Background(), 10)
+		assert.Error(t, err)
+		assert.Nil(t, reviews)
+	})
+}
+
+func TestGetVendorReviews(t *testing.T) {
+	mockTime := time.Now()
+
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectPrepare("SELECT review_id, (.+) FROM review WHERE vendor_id = \\? ORDER BY created_at DESC").
+		ExpectQuery().
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows(reviewColumns).
+			AddRow(1, 100, 10, 7, 5, 1, 1, 1, 1, 1.0, "Spam", nil, nil, true, "spam", mockTime, mockTime))
+
+	repo := &Repository{db: db}
+	reviews, err := repo.GetVendorReviews(context.Background(), 7)
+	assert.NoError(t, err)
+	assert.Len(t, reviews, 1)
+	assert.True(t, reviews[0].Flagged)
+	assert.Equal(t, "spam", reviews[0].FlagReason)
+}
+
+func TestGetVendorRating(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectPrepare("FROM review WHERE vendor_id = \\? AND flagged = FALSE").
+		ExpectQuery().
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"rating", "count", "cleanliness", "comfort", "location", "value"}).
+			AddRow(4.25, 8, 4.5, 4.0, 4.75, 3.75))
+
+	repo := &Repository{db: db}
+	summary, err := repo.GetVendorRating(context.Background(), 7)
+	assert.NoError(t, err)
+	assert.Equal(t, &entities.RatingSummary{Rating: 4.25, ReviewCount: 8, Cleanliness: 4.5, Comfort: 4.0, Location: 4.75, Value: 3.75}, summary)
+}
+
+func TestReplyToReview(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectPrepare("UPDATE review SET vendor_reply = \\?").
+			ExpectExec().
+			WithArgs("Thanks!", 1, 7).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		repo := &Repository{db: db}
+		err = repo.ReplyToReview(context.Background(), 1, 7, "Thanks!")
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("review of another vendor", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectPrepare("UPDATE review SET vendor_reply = \\?").
+			ExpectExec().
+			WithArgs("Thanks!", 1, 8).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		repo := &Repository{db: db}
+		err = repo.ReplyToReview(context.Background(), 1, 8, "Thanks!")
+		assert.EqualError(t, err, "review 1 not found for vendor 8")
+	})
+}
+
+func TestFlagReview(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectPrepare("UPDATE review SET flagged = \\?, flag_reason = \\?").
+		ExpectExec().
+		WithArgs(true, "abusive", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	repo := &Repository{db: db}
+	err = repo.FlagReview(context.Background(), 1, true, "abusive")
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -11,38 +11,45 @@ import (
 )
 
 type RoomRepository interface {
-	CreateRoom(ctx context.Context, room entities.RoomPayload) error
+	CreateRoom(ctx context.Context, room entities.Room) (int, error)
 	FindRoomByID(ctx context.Context, roomID int) (*entities.Room, error)
 	UpdateARoom(ctx context.Context, room entities.Room, roomID int) error
 	DeleteARoom(ctx context.Context, roomID int) error
 }
 
-func (r *Repository) CreateRoom(ctx context.Context, room entities.RoomPayload) error {
+// CreateRoom returns the new room's id.
+func (r *Repository) CreateRoom(ctx context.Context, room entities.Room) (int, error) {
 	q := `
-		INSERT INTO room(cost, status, vender_id, created_at, updated_at) 
-		VALUES (?,?,?,NOW(),NOW())
+		INSERT INTO room(cost, currency, status, vender_id, created_at, updated_at) 
+		VALUES (?,?,?,?,NOW(),NOW())
 	`
 
 	stmt, err := r.db.PrepareContext(ctx, q)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	defer stmt.Close()
 
-	args := []interface{}{room.Cost, room.Status, room.Vendor}
+	args := []interface{}{room.Cost.Amount, room.Cost.Currency, room.Status, room.VenderId}
 
-	_, err = stmt.ExecContext(ctx, args...)
+	res, err := stmt.ExecContext(ctx, args...)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	return nil
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
 }
 
 func (r *Repository) FindRoomByID(ctx context.Context, roomID int) (*entities.Room, error) {
 
-	q := `SELECT * FROM room WHERE room_id = ?`
+	q := `SELECT room_id, cost, currency, status, vender_id, created_at, updated_at
+			FROM room WHERE room_id = ?`
 
 	stmt, err := r.db.PrepareContext(ctx, q)
 
@@ -59,7 +66,7 @@ func (r *Repository) FindRoomByID(ctx context.Context, roomID int) (*entities.Ro
 
 	row := stmt.QueryRowContext(ctx, roomID)
 
-	err = row.Scan(&room.ID, &room.Cost, &room.Status, &room.VenderId, &room.CreateAt, &room.UpdatedAt)
+	err = row.Scan(&room.ID, &room.Cost.Amount, &room.Cost.Currency, &room.Status, &room.VenderId, &room.CreateAt, &room.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -68,7 +75,14 @@ func (r *Repository) FindRoomByID(ctx context.Context, roomID int) (*entities.Ro
 }
 
 func (r *Repository) AllRooms(ctx context.Context) ([]*entities.Room, error) {
-	q := `SELECT * FROM room ORDER BY room_id DESC`
+	q := `SELECT r.room_id, r.cost, r.currency, r.status, r.vender_id, r.created_at, r.updated_at,
+				COALESCE(rv.rating, 0), COALESCE(rv.review_count, 0)
+			FROM room r
+			LEFT JOIN (
+				SELECT room_id, ROUND(AVG(rating), 2) AS rating, COUNT(*) AS review_count
+				FROM review WHERE flagged = FALSE GROUP BY room_id
+			) rv ON rv.room_id = r.room_id
+			ORDER BY r.room_id DESC`
 	stmt, err := r.db.PrepareContext(ctx, q)
 	if err != nil {
 		return nil, err
@@ -86,7 +100,7 @@ func (r *Repository) AllRooms(ctx context.Context) ([]*entities.Room, error) {
 	var rooms []*entities.Room
 	for rows.Next() {
 		var room entities.Room
-		err = rows.Scan(&room.ID, &room.Cost, &room.Status, &room.VenderId, &room.CreateAt, &room.UpdatedAt)
+		err = rows.Scan(&room.ID, &room.Cost.Amount, &room.Cost.Currency, &room.Status, &room.VenderId, &room.CreateAt, &room.UpdatedAt, &room.Rating, &room.ReviewCount)
 		if err != nil {
 			return nil, err
 		}
@@ -103,7 +117,7 @@ func (r *Repository) AllRooms(ctx context.Context) ([]*entities.Room, error) {
 
 func (r *Repository) UpdateARoom(ctx context.Context, data *entities.Room, roomId, venderID int) error {
 	q := `
-		UPDATE room SET cost = ?, status = ?, updated_at = ? WHERE room_id = ? AND vender_id = ?
+		UPDATE room SET cost = ?, currency = ?, status = ?, updated_at = ? WHERE room_id = ? AND vender_id = ?
 	`
 	stmt, err := r.db.PrepareContext(ctx, q)
 	if err != nil {
@@ -112,7 +126,7 @@ func (r *Repository) UpdateARoom(ctx context.Context, data *entities.Room, roomI
 
 	defer stmt.Close()
 
-	args := []interface{}{data.Cost, data.Status, time.Now(), roomId, venderID}
+	args := []interface{}{data.Cost.Amount, data.Cost.Currency, data.Status, time.Now(), roomId, venderID}
 
 	_, err = stmt.ExecContext(ctx, args...)
 	if err != nil {
@@ -8,30 +8,31 @@ import (
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/money"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestCreateRoom(t *testing.T) {
 	tests := []struct {
 		name    string
-		room    entities.RoomPayload
+		room    entities.Room
 		wantErr bool
 		setup   func(mock sqlmock.Sqlmock)
 	}{
 		{
 			name:    "successful create",
-			room:    entities.RoomPayload{Cost: "100", Status: "VACANT", Vendor: 1},
+			room:    entities.Room{Cost: money.New(10000, "KES"), Status: "VACANT", VenderId: "1"},
 			wantErr: false,
 			setup: func(mock sqlmock.Sqlmock) {
 				mock.ExpectPrepare("INSERT INTO room").
 					ExpectExec().
-					WithArgs("100", "VACANT", 1).
+					WithArgs(int64(10000), "KES", "VACANT", "1").
 					WillReturnResult(sqlmock.NewResult(1, 1))
 			},
 		},
 		{
 			name:    "prepare error",
-			room:    entities.RoomPayload{Cost: "100", Status: "VACANT", Vendor: 1},
+			room:    entities.Room{Cost: money.New(10000, "KES"), Status: "VACANT", VenderId: "1"},
 			wantErr: true,
 			setup: func(mock sqlmock.Sqlmock) {
 				mock.ExpectPrepare("INSERT INTO room").WillReturnError(sql.ErrConnDone)
@@ -39,12 +40,12 @@ func TestCreateRoom(t *testing.T) {
 		},
 		{
 			name:    "exec error",
-			room:    entities.RoomPayload{Cost: "100", Status: "VACANT", Vendor: 1},
+			room:    entities.Room{Cost: money.New(10000, "KES"), Status: "VACANT", VenderId: "1"},
 			wantErr: true,
 			setup: func(mock sqlmock.Sqlmock) {
 				mock.ExpectPrepare("INSERT INTO room").
 					ExpectExec().
-					WithArgs("100", "VACANT", 1).
+					WithArgs(int64(10000), "KES", "VACANT", "1").
 					WillReturnError(sql.ErrNoRows)
 			},
 		},
@@ -58,12 +59,13 @@ func TestCreateRoom(t *testing.T) {
 
 			tt.setup(mock)
 			repo := &Repository{db: db}
-			err = repo.CreateRoom(context.Background(), tt.room)
+			id, err := repo.CreateRoom(context.Background(), tt.room)
 
 			if tt.wantErr {
 				assert.Error(t, err)
 			} else {
 				assert.NoError(t, err)
+				assert.Equal(t, 1, id)
 			}
 			assert.NoError(t, mock.ExpectationsWereMet())
 		})
@@ -78,17 +80,17 @@ func TestFindRoomByID(t *testing.T) {
 		assert.NoError(t, err)
 		defer db.Close()
 
-		mock.ExpectPrepare("SELECT \\* FROM room WHERE room_id = ?").
+		mock.ExpectPrepare("SELECT room_id, cost, currency, status, vender_id, created_at, updated_at\\s+FROM room WHERE room_id = ?").
 			ExpectQuery().
 			WithArgs(1).
-			WillReturnRows(sqlmock.NewRows([]string{"id", "cost", "status", "vender_id", "created_at", "updated_at"}).
-				AddRow("1", 100.0, "VACANT", "2", mockTime, mockTime))
+			WillReturnRows(sqlmock.NewRows([]string{"id", "cost", "currency", "status", "vender_id", "created_at", "updated_at"}).
+				AddRow("1", 10000, "KES", "VACANT", "2", mockTime, mockTime))
 
 		repo := &Repository{db: db}
 		room, err := repo.FindRoomByID(context.Background(), 1)
 		assert.NoError(t, err)
 		assert.Equal(t, "1", room.ID)
-		assert.Equal(t, 100.0, room.Cost)
+		assert.Equal(t, money.New(10000, "KES"), room.Cost)
 		assert.Equal(t, "VACANT", room.Status)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
@@ -98,7 +100,7 @@ func TestFindRoomByID(t *testing.T) {
 		assert.NoError(t, err)
 		defer db.Close()
 
-		mock.ExpectPrepare("SELECT \\* FROM room WHERE room_id = ?").
+		mock.ExpectPrepare("SELECT room_id, cost, currency, status, vender_id, created_at, updated_at\\s+FROM room WHERE room_id = ?").
 			ExpectQuery().
 			WithArgs(99).
 			WillReturnError(sql.ErrNoRows)
@@ -118,16 +120,18 @@ func TestAllRooms(t *testing.T) {
 		assert.NoError(t, err)
 		defer db.Close()
 
-		mock.ExpectPrepare("SELECT \\* FROM room ORDER BY room_id DESC").
+		mock.ExpectPrepare("SELECT r.room_id, r.cost, r.currency, r.status, r.vender_id, r.created_at, r.updated_at,\\s+COALESCE\\(rv.rating, 0\\), COALESCE\\(rv.review_count, 0\\)").
 			ExpectQuery().
-			WillReturnRows(sqlmock.NewRows([]string{"id", "cost", "status", "vender_id", "created_at", "updated_at"}).
-				AddRow("2", 200.0, "BOOKED", "1", mockTime, mockTime).
-				AddRow("1", 100.0, "VACANT", "1", mockTime, mockTime))
+			WillReturnRows(sqlmock.NewRows([]string{"id", "cost", "currency", "status", "vender_id", "created_at", "updated_at", "rating", "review_count"}).
+				AddRow("2", 20000, "KES", "BOOKED", "1", mockTime, mockTime, 4.25, 2).
+				AddRow("1", 10000, "KES", "VACANT", "1", mockTime, mockTime, 0, 0))
 
 		repo := &Repository{db: db}
 		rooms, err := repo.AllRooms(context.Background())
 		assert.NoError(t, err)
 		assert.Len(t, rooms, 2)
+		assert.Equal(t, 4.25, rooms[0].Rating)
+		assert.Equal(t, 2, rooms[0].ReviewCount)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 
@@ -136,7 +140,7 @@ func TestAllRooms(t *testing.T) {
 		assert.NoError(t, err)
 		defer db.Close()
 
-		mock.ExpectPrepare("SELECT \\* FROM room ORDER BY room_id DESC").
+		mock.ExpectPrepare("SELECT r.room_id, r.cost, r.currency, r.status, r.vender_id, r.created_at, r.updated_at,\\s+COALESCE\\(rv.rating, 0\\), COALESCE\\(rv.review_count, 0\\)").
 			ExpectQuery().
 			WillReturnError(sql.ErrConnDone)
 
@@ -152,7 +156,7 @@ func TestAllRooms(t *testing.T) {
 		defer db.Close()
 
 		// too few columns -> scan fails
-		mock.ExpectPrepare("SELECT \\* FROM room ORDER BY room_id DESC").
+		mock.ExpectPrepare("SELECT r.room_id, r.cost, r.currency, r.status, r.vender_id, r.created_at, r.updated_at,\\s+COALESCE\\(rv.rating, 0\\), COALESCE\\(rv.review_count, 0\\)").
 			ExpectQuery().
 			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("1"))
 
@@ -175,7 +179,7 @@ func TestUpdateARoom(t *testing.T) {
 			setup: func(mock sqlmock.Sqlmock) {
 				mock.ExpectPrepare("UPDATE room SET cost").
 					ExpectExec().
-					WithArgs(150.0, "BOOKED", sqlmock.AnyArg(), 1, 2).
+					WithArgs(int64(15000), "KES", "BOOKED", sqlmock.AnyArg(), 1, 2).
 					WillReturnResult(sqlmock.NewResult(1, 1))
 			},
 		},
@@ -192,7 +196,7 @@ func TestUpdateARoom(t *testing.T) {
 			setup: func(mock sqlmock.Sqlmock) {
 				mock.ExpectPrepare("UPDATE room SET cost").
 					ExpectExec().
-					WithArgs(150.0, "BOOKED", sqlmock.AnyArg(), 1, 2).
+					WithArgs(int64(15000), "KES", "BOOKED", sqlmock.AnyArg(), 1, 2).
 					WillReturnError(sql.ErrNoRows)
 			},
 		},
@@ -206,7 +210,7 @@ func TestUpdateARoom(t *testing.T) {
 
 			tt.setup(mock)
 			repo := &Repository{db: db}
-			data := &entities.Room{Cost: 150.0, Status: "BOOKED"}
+			data := &entities.Room{Cost: money.New(15000, "KES"), Status: "BOOKED"}
 			err = repo.UpdateARoom(context.Background(), data, 1, 2)
 
 			if tt.wantErr {
@@ -0,0 +1,255 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/bicosteve/booking-system/entities"
+)
+
+type RoomBlockRepository interface {
+	CreateRoomBlock(ctx context.Context, block *entities.RoomBlock, vendorID int) (int, error)
+	GetRoomBlocks(ctx context.Context, roomID, vendorID int, from, to time.Time) ([]*entities.RoomBlock, error)
+	GetRoomBlock(ctx context.Context, blockID, roomID, vendorID int) (*entities.RoomBlock, error)
+	UpdateRoomBlock(ctx context.Context, block *entities.RoomBlock, vendorID int) error
+	DeleteRoomBlock(ctx context.Context, blockID, roomID, vendorID int) error
+	GetBlockedRoomIDs(ctx context.Context, start, end time.Time) (map[int]bool, error)
+	HasRoomStays(ctx context.Context, roomID int, start, end time.Time) (bool, error)
+}
+
+// CreateRoomBlock adds a block to one of vendorID's rooms. It returns
+// ErrNoRecord when the room is not the vendor's.
+func (r *Repository) CreateRoomBlock(ctx context.Context, block *entities.RoomBlock, vendorID int) (int, error) {
+	q := `INSERT INTO room_block(room_id, start_date, end_date, reason, note, created_at, updated_at)
+		SELECT room_id, ?, ?, ?, ?, NOW(), NOW() FROM room WHERE room_id = ? AND vender_id = ?`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return 0, err
+	}
+
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, block.Start.Format(entities.DateLayout), block.End.Format(entities.DateLayout),
+		block.Reason, block.Note, block.RoomID, vendorID)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if n == 0 {
+		return 0, entities.ErrNoRecord
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+const selectRoomBlock = `SELECT b.block_id, b.room_id, b.start_date, b.end_date, b.reason, b.note, b.calendar_id,
+				b.external_uid, b.created_at, b.updated_at
+			FROM room_block b
+			JOIN room r ON r.room_id = b.room_id`
+
+// GetRoomBlocks returns the blocks of one of vendorID's rooms that overlap
+// the nights from from up to, but not including, to. Blocks imported from
+// external calendars are included.
+func (r *Repository) GetRoomBlocks(ctx context.Context, roomID, vendorID int, from, to time.Time) ([]*entities.RoomBlock, error) {
+	q := selectRoomBlock + `
+			WHERE b.room_id = ? AND r.vender_id = ? AND b.start_date < ? AND b.end_date > ?
+			ORDER BY b.start_date, b.block_id`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, roomID, vendorID, to.Format(entities.DateLayout), from.Format(entities.DateLayout))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	blocks := []*entities.RoomBlock{}
+	for rows.Next() {
+		b, err := scanRoomBlock(rows)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, b)
+	}
+
+	return blocks, rows.Err()
+}
+
+func (r *Repository) GetRoomBlock(ctx context.Context, blockID, roomID, vendorID int) (*entities.RoomBlock, error) {
+	q := selectRoomBlock + `
+			WHERE b.block_id = ? AND b.room_id = ? AND r.vender_id = ?`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	b, err := scanRoomBlock(stmt.QueryRowContext(ctx, blockID, roomID, vendorID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, entities.ErrNoRecord
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+func scanRoomBlock(row interface{ Scan(...any) error }) (*entities.RoomBlock, error) {
+	var b entities.RoomBlock
+	var calendarID sql.NullInt64
+
+	err := row.Scan(&b.ID, &b.RoomID, &b.Start, &b.End, &b.Reason, &b.Note, &calendarID, &b.ExternalUID, &b.CreatedAt, &b.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if calendarID.Valid {
+		id := int(calendarID.Int64)
+		b.CalendarID = &id
+	}
+
+	return &b, nil
+}
+
+// UpdateRoomBlock saves the dates, reason and note of a block the vendor
+// created. Imported blocks are left alone; they change with their calendar.
+func (r *Repository) UpdateRoomBlock(ctx context.Context, block *entities.RoomBlock, vendorID int) error {
+	q := `UPDATE room_block b
+			JOIN room r ON r.room_id = b.room_id
+			SET b.start_date = ?, b.end_date = ?, b.reason = ?, b.note = ?, b.updated_at = NOW()
+			WHERE b.block_id = ? AND b.room_id = ? AND r.vender_id = ? AND b.calendar_id IS NULL`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, block.Start.Format(entities.DateLayout), block.End.Format(entities.DateLayout),
+		block.Reason, block.Note, block.ID, block.RoomID, vendorID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return entities.ErrNoRecord
+	}
+
+	return nil
+}
+
+// DeleteRoomBlock removes a block the vendor created, putting the room back
+// on sale for those dates.
+func (r *Repository) DeleteRoomBlock(ctx context.Context, blockID, roomID, vendorID int) error {
+	q := `DELETE b FROM room_block b
+			JOIN room r ON r.room_id = b.room_id
+			WHERE b.block_id = ? AND b.room_id = ? AND r.vender_id = ? AND b.calendar_id IS NULL`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, blockID, roomID, vendorID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return entities.ErrNoRecord
+	}
+
+	return nil
+}
+
+// GetBlockedRoomIDs returns the rooms with a block overlapping the nights
+// from start up to, but not including, end.
+func (r *Repository) GetBlockedRoomIDs(ctx context.Context, start, end time.Time) (map[int]bool, error) {
+	q := `SELECT DISTINCT room_id FROM room_block WHERE start_date < ? AND end_date > ?`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, end.Format(entities.DateLayout), start.Format(entities.DateLayout))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	blocked := map[int]bool{}
+	for rows.Next() {
+		var id int
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		blocked[id] = true
+	}
+
+	return blocked, rows.Err()
+}
+
+// HasRoomStays reports whether a confirmed or checked in stay overlaps the
+// nights from start up to, but not including, end.
+func (r *Repository) HasRoomStays(ctx context.Context, roomID int, start, end time.Time) (bool, error) {
+	q := `SELECT COUNT(*) FROM booking
+			WHERE room_id = ? AND status IN (?, ?)
+				AND COALESCE(check_in, DATE(created_at)) < ?
+				AND DATE_ADD(COALESCE(check_in, DATE(created_at)), INTERVAL days DAY) > ?`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return false, err
+	}
+
+	defer stmt.Close()
+
+	var count int
+	err = stmt.QueryRowContext(ctx, roomID, entities.BookingStatusConfirmed, entities.BookingStatusCheckedIn,
+		end.Format(entities.DateLayout), start.Format(entities.DateLayout)).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
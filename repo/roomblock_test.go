@@ -0,0 +1,153 @@
+package repo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+var roomBlockColumns = []string{"block_id", "room_id", "start_date", "end_date", "reason", "note", "calendar_id",
+	"external_uid", "created_at", "updated_at"}
+
+func TestCreateRoomBlock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewDBRepository(db, nil)
+	start := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	block := &entities.RoomBlock{RoomID: 10, Start: start, End: start.AddDate(0, 0, 3), Reason: entities.BlockReasonMaintenance, Note: "Repainting"}
+
+	mock.ExpectPrepare("INSERT INTO room_block").ExpectExec().
+		WithArgs("2026-11-01", "2026-11-04", entities.BlockReasonMaintenance, "Repainting", 10, 7).
+		WillReturnResult(sqlmock.NewResult(12, 1))
+	mock.ExpectPrepare("INSERT INTO room_block").ExpectExec().
+		WithArgs("2026-11-01", "2026-11-04", entities.BlockReasonMaintenance, "Repainting", 10, 8).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	id, err := repo.CreateRoomBlock(context.Background(), block, 7)
+	assert.NoError(t, err)
+	assert.Equal(t, 12, id)
+
+	_, err = repo.CreateRoomBlock(context.Background(), block, 8)
+	assert.ErrorIs(t, err, entities.ErrNoRecord)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetRoomBlocks(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewDBRepository(db, nil)
+	from := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	now := time.Now()
+
+	mock.ExpectPrepare("FROM room_block b").ExpectQuery().
+		WithArgs(10, 7, "2026-12-01", "2026-11-01").
+		WillReturnRows(sqlmock.NewRows(roomBlockColumns).
+			AddRow(12, 10, from, from.AddDate(0, 0, 3), entities.BlockReasonMaintenance, "Repainting", nil, "", now, now).
+			AddRow(13, 10, from.AddDate(0, 0, 5), from.AddDate(0, 0, 7), entities.BlockReasonExternalBooking, "Reserved", 4, "abc@airbnb.com", now, now))
+
+	blocks, err := repo.GetRoomBlocks(context.Background(), 10, 7, from, from.AddDate(0, 1, 0))
+	assert.NoError(t, err)
+	assert.Len(t, blocks, 2)
+	assert.Nil(t, blocks[0].CalendarID)
+	assert.Equal(t, 4, *blocks[1].CalendarID)
+	assert.Equal(t, "abc@airbnb.com", blocks[1].ExternalUID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetRoomBlock_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewDBRepository(db, nil)
+
+	mock.ExpectPrepare("FROM room_block b").ExpectQuery().WithArgs(12, 10, 8).
+		WillReturnRows(sqlmock.NewRows(roomBlockColumns))
+
+	_, err = repo.GetRoomBlock(context.Background(), 12, 10, 8)
+	assert.ErrorIs(t, err, entities.ErrNoRecord)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateRoomBlock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewDBRepository(db, nil)
+	start := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	block := &entities.RoomBlock{ID: 12, RoomID: 10, Start: start, End: start.AddDate(0, 0, 2), Reason: entities.BlockReasonOwnerUse}
+
+	mock.ExpectPrepare("UPDATE room_block b").ExpectExec().
+		WithArgs("2026-11-01", "2026-11-03", entities.BlockReasonOwnerUse, "", 12, 10, 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectPrepare("UPDATE room_block b").ExpectExec().
+		WithArgs("2026-11-01", "2026-11-03", entities.BlockReasonOwnerUse, "", 12, 10, 8).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = repo.UpdateRoomBlock(context.Background(), block, 7)
+	assert.NoError(t, err)
+
+	err = repo.UpdateRoomBlock(context.Background(), block, 8)
+	assert.ErrorIs(t, err, entities.ErrNoRecord)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteRoomBlock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewDBRepository(db, nil)
+
+	mock.ExpectPrepare("DELETE b FROM room_block b").ExpectExec().WithArgs(12, 10, 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = repo.DeleteRoomBlock(context.Background(), 12, 10, 7)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetBlockedRoomIDs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewDBRepository(db, nil)
+	start := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectPrepare("SELECT DISTINCT room_id FROM room_block").ExpectQuery().
+		WithArgs("2026-11-03", "2026-11-01").
+		WillReturnRows(sqlmock.NewRows([]string{"room_id"}).AddRow(10).AddRow(14))
+
+	blocked, err := repo.GetBlockedRoomIDs(context.Background(), start, start.AddDate(0, 0, 2))
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]bool{10: true, 14: true}, blocked)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHasRoomStays(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewDBRepository(db, nil)
+	start := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectPrepare("SELECT COUNT\\(\\*\\) FROM booking").ExpectQuery().
+		WithArgs(10, entities.BookingStatusConfirmed, entities.BookingStatusCheckedIn, "2026-11-03", "2026-11-01").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	booked, err := repo.HasRoomStays(context.Background(), 10, start, start.AddDate(0, 0, 2))
+	assert.NoError(t, err)
+	assert.True(t, booked)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
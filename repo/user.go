@@ -3,6 +3,7 @@ package repo
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"log/slog"
 	"time"
 
@@ -16,20 +17,20 @@ type UserRepository interface {
 	FindUserByEmail(ctx context.Context, email string) error
 	UpdatePassword(ctx context.Context, user entities.UserPayload) error
 	FindAProfile(ctx context.Context, email string) (*entities.User, error)
+	FindProfileByID(ctx context.Context, userID int) (*entities.User, error)
 	InsertPasswordResetToken(ctx context.Context, resetToken string, userId int) error
 }
 
 func (r *Repository) CreateUser(ctx context.Context, user entities.UserPayload) error {
-	requestID := ctx.Value("request_id")
 	q := `
 			INSERT INTO 
 			user(email,phone_number,isVender,hashed_password, created_at, 
-			updated_at, password_inserted_at) VALUES(?,?,?,?,NOW(),NOW(), NOW())
+			updated_at, password_inserted_at) VALUES(?,?,?,?,NOW(),NOW(), UTC_TIMESTAMP())
 		`
 
 	stmt, err := r.db.PrepareContext(ctx, q)
 	if err != nil {
-		slog.Error("%s failed to prepare because of %s", "error", requestID, slog.String(err.Error(), "register"))
+		slog.ErrorContext(ctx, "register prepare failed", "error", err)
 		return err
 	}
 
@@ -37,7 +38,7 @@ func (r *Repository) CreateUser(ctx context.Context, user entities.UserPayload)
 
 	hash, err := utils.GeneratePasswordHash(user.Password)
 	if err != nil {
-		slog.Error("failed to insert user because of %v", "error", err)
+		slog.ErrorContext(ctx, "register password hash failed", "error", err)
 		return err
 
 	}
@@ -46,7 +47,7 @@ func (r *Repository) CreateUser(ctx context.Context, user entities.UserPayload)
 
 	_, err = stmt.ExecContext(ctx, args...)
 	if err != nil {
-		slog.Error("failed to insert user because of %v", "error", err)
+		slog.ErrorContext(ctx, "register insert failed", "error", err)
 		return err
 	}
 
@@ -62,7 +63,7 @@ func (r *Repository) FindUserByEmail(ctx context.Context, email string) (bool, e
 
 	stmt, err := r.db.PrepareContext(ctx, q)
 	if err != nil {
-		slog.Error("failed to prepare the statement due to %v", "error", err)
+		slog.ErrorContext(ctx, "find user by email prepare failed", "error", err)
 		return false, err
 	}
 
@@ -74,17 +75,35 @@ func (r *Repository) FindUserByEmail(ctx context.Context, email string) (bool, e
 		if err == sql.ErrNoRows {
 			return false, sql.ErrNoRows
 		}
-		slog.Error("failed because of %v ", "error", err)
+		slog.ErrorContext(ctx, "find user by email failed", "error", err)
 		return false, err
 	}
 
 	return count > 0, nil
 }
 
-func (r *Repository) FindAProfile(ctx context.Context, email string) (*entities.User, error) {
+// selectUser reads the columns scanUser expects.
+const selectUser = `SELECT user_id, email, phone_number, isVender, hashed_password, password_reset_token, created_at, updated_at, password_inserted_at, name, phone_verified_at FROM user`
+
+func scanUser(row scanner) (*entities.User, error) {
 	var user entities.User
+	var phoneVerifiedAt sql.NullTime
 
-	q := `SELECT * FROM user WHERE email = ?`
+	err := row.Scan(&user.ID, &user.Email, &user.PhoneNumber, &user.IsVender, &user.Password, &user.PasswordResetToken,
+		&user.CreatedAt, &user.UpdatedAt, &user.PasswordInsertedAt, &user.Name, &phoneVerifiedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if phoneVerifiedAt.Valid {
+		user.PhoneVerifiedAt = &phoneVerifiedAt.Time
+	}
+
+	return &user, nil
+}
+
+func (r *Repository) FindAProfile(ctx context.Context, email string) (*entities.User, error) {
+	q := selectUser + ` WHERE email = ?`
 	stmt, err := r.db.PrepareContext(ctx, q)
 	if err != nil {
 		return nil, err
@@ -92,15 +111,55 @@ func (r *Repository) FindAProfile(ctx context.Context, email string) (*entities.
 
 	defer stmt.Close()
 
-	row := stmt.QueryRowContext(ctx, email)
+	user, err := scanUser(stmt.QueryRowContext(ctx, email))
+	if err != nil {
+		slog.ErrorContext(ctx, "find profile failed", "error", err)
+		return nil, err
+	}
 
-	err = row.Scan(&user.ID, &user.Email, &user.PhoneNumber, &user.IsVender, &user.Password, &user.PasswordResetToken, &user.CreatedAt, &user.UpdatedAt, &user.PasswordInsertedAt)
+	return user, nil
+}
+
+// FindProfileByID returns a user by id, or ErrNoRecord if there is none or
+// the account was deleted.
+func (r *Repository) FindProfileByID(ctx context.Context, userID int) (*entities.User, error) {
+	q := selectUser + ` WHERE user_id = ? AND deleted_at IS NULL`
+	stmt, err := r.db.PrepareContext(ctx, q)
 	if err != nil {
-		slog.Error("failed to execute statement due to %v ", "error", err)
 		return nil, err
 	}
 
-	return &user, nil
+	defer stmt.Close()
+
+	user, err := scanUser(stmt.QueryRowContext(ctx, userID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, entities.ErrNoRecord
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// SessionsValidFrom returns when the user last set their password; auth
+// tokens issued before then are no longer accepted. password_inserted_at is
+// written with UTC_TIMESTAMP() and the connection reads times as UTC, so it
+// compares directly with token times. It returns ErrNoRecord for deleted
+// accounts.
+func (r *Repository) SessionsValidFrom(ctx context.Context, userID int) (time.Time, error) {
+	var validFrom sql.NullTime
+
+	q := `SELECT password_inserted_at FROM user WHERE user_id = ? AND deleted_at IS NULL`
+	err := r.db.QueryRowContext(ctx, q, userID).Scan(&validFrom)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, entities.ErrNoRecord
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return validFrom.Time, nil
 }
 
 func (r *Repository) InsertPasswordResetToken(ctx context.Context, resetToken string, email string) error {
@@ -125,8 +184,9 @@ func (r *Repository) InsertPasswordResetToken(ctx context.Context, resetToken st
 
 func (r *Repository) UpdatePassword(ctx context.Context, newPassword *string, userId int) error {
 
+	// password_inserted_at also ends the user's existing sessions.
 	q := `
-		UPDATE user SET hashed_password = ?, updated_at = ?, password_inserted_at = ? WHERE user_id = ?
+		UPDATE user SET hashed_password = ?, updated_at = NOW(), password_inserted_at = UTC_TIMESTAMP() WHERE user_id = ?
 	`
 	stmt, err := r.db.PrepareContext(ctx, q)
 	if err != nil {
@@ -140,7 +200,7 @@ func (r *Repository) UpdatePassword(ctx context.Context, newPassword *string, us
 		return err
 	}
 
-	args := []interface{}{hash, time.Now(), time.Now(), userId}
+	args := []interface{}{hash, userId}
 
 	_, err = stmt.ExecContext(ctx, args...)
 	if err != nil {
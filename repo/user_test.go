@@ -188,17 +188,18 @@ func TestFindAProfile(t *testing.T) {
 			},
 			wantErr: false,
 			setup: func(mock sqlmock.Sqlmock) {
-				mock.ExpectPrepare("SELECT \\* FROM user").
+				mock.ExpectPrepare("SELECT user_id, email, phone_number").
 					ExpectQuery().
 					WithArgs("test@example.com").
 					WillReturnRows(sqlmock.NewRows([]string{
 						"id", "email", "phone_number", "isVender",
 						"password", "password_reset_token",
 						"created_at", "updated_at", "password_inserted_at",
+						"name", "phone_verified_at",
 					}).AddRow(
 						"1", "test@example.com", "1234567890", "false",
 						"hashedpassword", "",
-						mockTime, mockTime, mockTime,
+						mockTime, mockTime, mockTime, "", nil,
 					))
 			},
 		},
@@ -208,7 +209,7 @@ func TestFindAProfile(t *testing.T) {
 			want:    nil,
 			wantErr: true,
 			setup: func(mock sqlmock.Sqlmock) {
-				mock.ExpectPrepare("SELECT \\* FROM user").
+				mock.ExpectPrepare("SELECT user_id, email, phone_number").
 					ExpectQuery().
 					WithArgs("nonexistent@example.com").
 					WillReturnError(sql.ErrNoRows)
@@ -313,6 +314,35 @@ func TestInsertPasswordResetToken(t *testing.T) {
 	}
 }
 
+func TestSessionsValidFrom(t *testing.T) {
+	changed := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	q := "SELECT password_inserted_at FROM user WHERE user_id = \\? AND deleted_at IS NULL"
+
+	t.Run("active account", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectQuery(q).WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"password_inserted_at"}).AddRow(changed))
+
+		validFrom, err := NewDBRepository(db, nil).SessionsValidFrom(context.Background(), 7)
+		assert.NoError(t, err)
+		assert.Equal(t, changed, validFrom)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("deleted account", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectQuery(q).WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"password_inserted_at"}))
+
+		_, err = NewDBRepository(db, nil).SessionsValidFrom(context.Background(), 7)
+		assert.ErrorIs(t, err, entities.ErrNoRecord)
+	})
+}
+
 func TestUpdatePassword(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -327,9 +357,9 @@ func TestUpdatePassword(t *testing.T) {
 			userID:      1,
 			wantErr:     false,
 			setup: func(mock sqlmock.Sqlmock) {
-				mock.ExpectPrepare("UPDATE user SET hashed_password").
+				mock.ExpectPrepare("UPDATE user SET hashed_password = \\?, updated_at = NOW\\(\\), password_inserted_at = UTC_TIMESTAMP\\(\\)").
 					ExpectExec().
-					WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 1).
+					WithArgs(sqlmock.AnyArg(), 1).
 					WillReturnResult(sqlmock.NewResult(1, 1))
 			},
 		},
@@ -351,7 +381,7 @@ func TestUpdatePassword(t *testing.T) {
 			setup: func(mock sqlmock.Sqlmock) {
 				mock.ExpectPrepare("UPDATE user SET hashed_password").
 					ExpectExec().
-					WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 1).
+					WithArgs(sqlmock.AnyArg(), 1).
 					WillReturnError(sql.ErrNoRows)
 			},
 		},
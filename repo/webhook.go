@@ -0,0 +1,387 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/bicosteve/booking-system/entities"
+)
+
+type WebhookRepository interface {
+	CreateWebhook(ctx context.Context, webhook *entities.Webhook) (int, error)
+	GetWebhook(ctx context.Context, webhookID, vendorID int) (*entities.Webhook, error)
+	GetVendorWebhooks(ctx context.Context, vendorID int) ([]*entities.Webhook, error)
+	GetRoomWebhooks(ctx context.Context, roomID int) ([]*entities.Webhook, error)
+	UpdateWebhook(ctx context.Context, webhook *entities.Webhook) error
+	DeleteWebhook(ctx context.Context, webhookID, vendorID int) error
+	CreateWebhookDeliveries(ctx context.Context, deliveries []entities.WebhookDelivery) error
+	ClaimDueWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*entities.WebhookDelivery, error)
+	GetWebhookDelivery(ctx context.Context, deliveryID, webhookID, vendorID int) (*entities.WebhookDelivery, error)
+	GetWebhookDeliveries(ctx context.Context, webhookID, vendorID, limit int) ([]*entities.WebhookDelivery, error)
+	RecordWebhookAttempt(ctx context.Context, delivery *entities.WebhookDelivery, retryIn time.Duration) error
+}
+
+const selectWebhook = `SELECT webhook_id, vendor_id, url, events, active, created_at, updated_at FROM webhook`
+
+func scanWebhook(row interface{ Scan(...any) error }) (*entities.Webhook, error) {
+	var w entities.Webhook
+	var events string
+
+	err := row.Scan(&w.ID, &w.VendorID, &w.URL, &events, &w.Active, &w.CreatedAt, &w.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	w.Events = splitEvents(events)
+
+	return &w, nil
+}
+
+func splitEvents(events string) []string {
+	out := []string{}
+	for _, e := range strings.Split(events, ",") {
+		if e != "" {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func (r *Repository) CreateWebhook(ctx context.Context, webhook *entities.Webhook) (int, error) {
+	q := `INSERT INTO webhook(vendor_id, url, secret, events, active, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, NOW(), NOW())`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return 0, err
+	}
+
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, webhook.VendorID, webhook.URL, webhook.Secret, strings.Join(webhook.Events, ","), webhook.Active)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// GetWebhook returns one of vendorID's webhooks, or ErrNoRecord.
+func (r *Repository) GetWebhook(ctx context.Context, webhookID, vendorID int) (*entities.Webhook, error) {
+	q := selectWebhook + ` WHERE webhook_id = ? AND vendor_id = ?`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	w, err := scanWebhook(stmt.QueryRowContext(ctx, webhookID, vendorID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, entities.ErrNoRecord
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (r *Repository) GetVendorWebhooks(ctx context.Context, vendorID int) ([]*entities.Webhook, error) {
+	return r.queryWebhooks(ctx, selectWebhook+` WHERE vendor_id = ? ORDER BY webhook_id`, vendorID)
+}
+
+// GetRoomWebhooks returns the active webhooks of the vendor owning roomID.
+func (r *Repository) GetRoomWebhooks(ctx context.Context, roomID int) ([]*entities.Webhook, error) {
+	q := `SELECT w.webhook_id, w.vendor_id, w.url, w.events, w.active, w.created_at, w.updated_at
+			FROM webhook w
+			JOIN room r ON r.vender_id = w.vendor_id
+			WHERE r.room_id = ? AND w.active = TRUE`
+
+	return r.queryWebhooks(ctx, q, roomID)
+}
+
+func (r *Repository) queryWebhooks(ctx context.Context, q string, args ...any) ([]*entities.Webhook, error) {
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	webhooks := []*entities.Webhook{}
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, w)
+	}
+
+	return webhooks, rows.Err()
+}
+
+func (r *Repository) UpdateWebhook(ctx context.Context, webhook *entities.Webhook) error {
+	q := `UPDATE webhook SET url = ?, events = ?, active = ?, updated_at = NOW()
+		WHERE webhook_id = ? AND vendor_id = ?`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, webhook.URL, strings.Join(webhook.Events, ","), webhook.Active, webhook.ID, webhook.VendorID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteWebhook removes one of vendorID's webhooks and its delivery log.
+func (r *Repository) DeleteWebhook(ctx context.Context, webhookID, vendorID int) error {
+	q := `DELETE FROM webhook WHERE webhook_id = ? AND vendor_id = ?`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, webhookID, vendorID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return entities.ErrNoRecord
+	}
+
+	return nil
+}
+
+// CreateWebhookDeliveries queues deliveries to be sent right away. An event
+// already queued for a webhook is not queued again.
+func (r *Repository) CreateWebhookDeliveries(ctx context.Context, deliveries []entities.WebhookDelivery) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	q := `INSERT IGNORE INTO webhook_delivery(webhook_id, event_id, event_type, payload, status, next_attempt_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, 'PENDING', NOW(), NOW(), NOW())`
+
+	stmt, err := tx.PrepareContext(ctx, q)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	for _, d := range deliveries {
+		_, err = stmt.ExecContext(ctx, d.WebhookID, d.EventID, d.EventType, d.Payload)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+const selectDelivery = `SELECT d.delivery_id, d.webhook_id, d.event_id, d.event_type, d.payload, d.status, d.attempts,
+				d.response_code, d.last_error, d.next_attempt_at, d.delivered_at, d.created_at, d.updated_at,
+				w.url, w.secret
+			FROM webhook_delivery d
+			JOIN webhook w ON w.webhook_id = d.webhook_id`
+
+func scanDelivery(row interface{ Scan(...any) error }) (*entities.WebhookDelivery, error) {
+	var d entities.WebhookDelivery
+	var code sql.NullInt64
+	var next, delivered sql.NullTime
+
+	err := row.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts,
+		&code, &d.LastError, &next, &delivered, &d.CreatedAt, &d.UpdatedAt, &d.URL, &d.Secret)
+	if err != nil {
+		return nil, err
+	}
+
+	if code.Valid {
+		c := int(code.Int64)
+		d.ResponseCode = &c
+	}
+	if next.Valid {
+		d.NextAttemptAt = &next.Time
+	}
+	if delivered.Valid {
+		d.DeliveredAt = &delivered.Time
+	}
+
+	return &d, nil
+}
+
+// ClaimDueWebhookDeliveries returns pending deliveries of active webhooks
+// whose next attempt is due, oldest first, and pushes their next attempt
+// lease into the future so other workers skip them while they are sent. If
+// the worker dies, they are picked up again once the lease runs out.
+func (r *Repository) ClaimDueWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*entities.WebhookDelivery, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	q := selectDelivery + ` WHERE d.status = 'PENDING' AND d.next_attempt_at <= NOW() AND w.active = TRUE
+			ORDER BY d.next_attempt_at LIMIT ? FOR UPDATE OF d SKIP LOCKED`
+
+	rows, err := tx.QueryContext(ctx, q, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	deliveries := []*entities.WebhookDelivery{}
+	args := []any{int(lease.Seconds())}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+		args = append(args, d.ID)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(deliveries) == 0 {
+		return deliveries, nil
+	}
+
+	q = `UPDATE webhook_delivery SET next_attempt_at = DATE_ADD(NOW(), INTERVAL ? SECOND)
+			WHERE delivery_id IN (?` + strings.Repeat(", ?", len(deliveries)-1) + `)`
+
+	_, err = tx.ExecContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return deliveries, tx.Commit()
+}
+
+// GetWebhookDelivery returns a delivery of one of vendorID's webhooks, or
+// ErrNoRecord.
+func (r *Repository) GetWebhookDelivery(ctx context.Context, deliveryID, webhookID, vendorID int) (*entities.WebhookDelivery, error) {
+	q := selectDelivery + ` WHERE d.delivery_id = ? AND d.webhook_id = ? AND w.vendor_id = ?`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	d, err := scanDelivery(stmt.QueryRowContext(ctx, deliveryID, webhookID, vendorID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, entities.ErrNoRecord
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// GetWebhookDeliveries returns the latest deliveries of one of vendorID's
+// webhooks, newest first.
+func (r *Repository) GetWebhookDeliveries(ctx context.Context, webhookID, vendorID, limit int) ([]*entities.WebhookDelivery, error) {
+	q := selectDelivery + ` WHERE d.webhook_id = ? AND w.vendor_id = ? ORDER BY d.delivery_id DESC LIMIT ?`
+
+	return r.queryDeliveries(ctx, q, webhookID, vendorID, limit)
+}
+
+func (r *Repository) queryDeliveries(ctx context.Context, q string, args ...any) ([]*entities.WebhookDelivery, error) {
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	deliveries := []*entities.WebhookDelivery{}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// RecordWebhookAttempt stores the outcome of an attempt. A pending delivery
+// is tried again retryIn from now; a succeeded one is stamped delivered.
+func (r *Repository) RecordWebhookAttempt(ctx context.Context, delivery *entities.WebhookDelivery, retryIn time.Duration) error {
+	q := `UPDATE webhook_delivery
+			SET status = ?, attempts = ?, response_code = ?, last_error = ?,
+				next_attempt_at = IF(? = 'PENDING', DATE_ADD(NOW(), INTERVAL ? SECOND), NULL),
+				delivered_at = IF(? = 'SUCCEEDED', NOW(), delivered_at),
+				updated_at = NOW()
+			WHERE delivery_id = ?`
+
+	stmt, err := r.db.PrepareContext(ctx, q)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	var code sql.NullInt64
+	if delivery.ResponseCode != nil {
+		code = sql.NullInt64{Int64: int64(*delivery.ResponseCode), Valid: true}
+	}
+
+	_, err = stmt.ExecContext(ctx, delivery.Status, delivery.Attempts, code, delivery.LastError,
+		delivery.Status, int(retryIn.Seconds()), delivery.Status, delivery.ID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
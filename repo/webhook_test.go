@@ -0,0 +1,217 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+var webhookColumns = []string{"webhook_id", "vendor_id", "url", "events", "active", "created_at", "updated_at"}
+
+var deliveryColumns = []string{"delivery_id", "webhook_id", "event_id", "event_type", "payload", "status", "attempts",
+	"response_code", "last_error", "next_attempt_at", "delivered_at", "created_at", "updated_at", "url", "secret"}
+
+func TestCreateWebhook(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewDBRepository(db, nil)
+
+	mock.ExpectPrepare("INSERT INTO webhook").
+		ExpectExec().
+		WithArgs(7, "https://pms.example.com/hooks", "whsec_test", "booking.created,booking.cancelled", true).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+
+	id, err := repo.CreateWebhook(context.Background(), &entities.Webhook{
+		VendorID: 7,
+		URL:      "https://pms.example.com/hooks",
+		Secret:   "whsec_test",
+		Events:   []string{"booking.created", "booking.cancelled"},
+		Active:   true,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, id)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetWebhook(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewDBRepository(db, nil)
+	now := time.Now()
+
+	mock.ExpectPrepare("SELECT webhook_id").
+		ExpectQuery().
+		WithArgs(3, 7).
+		WillReturnRows(sqlmock.NewRows(webhookColumns).AddRow(3, 7, "https://pms.example.com/hooks", "", true, now, now))
+	mock.ExpectPrepare("SELECT webhook_id").
+		ExpectQuery().
+		WithArgs(3, 8).
+		WillReturnRows(sqlmock.NewRows(webhookColumns))
+
+	w, err := repo.GetWebhook(context.Background(), 3, 7)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://pms.example.com/hooks", w.URL)
+	assert.Equal(t, []string{}, w.Events)
+	assert.Empty(t, w.Secret)
+
+	_, err = repo.GetWebhook(context.Background(), 3, 8)
+	assert.ErrorIs(t, err, entities.ErrNoRecord)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteWebhook(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewDBRepository(db, nil)
+
+	mock.ExpectPrepare("DELETE FROM webhook").ExpectExec().WithArgs(3, 7).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectPrepare("DELETE FROM webhook").ExpectExec().WithArgs(3, 8).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, repo.DeleteWebhook(context.Background(), 3, 7))
+	assert.ErrorIs(t, repo.DeleteWebhook(context.Background(), 3, 8), entities.ErrNoRecord)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetRoomWebhooks(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewDBRepository(db, nil)
+	now := time.Now()
+
+	mock.ExpectPrepare("JOIN room r ON r.vender_id = w.vendor_id").
+		ExpectQuery().
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows(webhookColumns).
+			AddRow(3, 7, "https://a.example.com", "booking.created", true, now, now).
+			AddRow(4, 7, "https://b.example.com", "", true, now, now))
+
+	hooks, err := repo.GetRoomWebhooks(context.Background(), 10)
+	assert.NoError(t, err)
+	assert.Len(t, hooks, 2)
+	assert.Equal(t, []string{"booking.created"}, hooks[0].Events)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateWebhookDeliveries(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewDBRepository(db, nil)
+
+	mock.ExpectBegin()
+	prep := mock.ExpectPrepare("INSERT IGNORE INTO webhook_delivery")
+	prep.ExpectExec().WithArgs(3, "event-1", "booking.created", `{}`).WillReturnResult(sqlmock.NewResult(1, 1))
+	prep.ExpectExec().WithArgs(4, "event-1", "booking.created", `{}`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	err = repo.CreateWebhookDeliveries(context.Background(), []entities.WebhookDelivery{
+		{WebhookID: 3, EventID: "event-1", EventType: "booking.created", Payload: `{}`},
+		{WebhookID: 4, EventID: "event-1", EventType: "booking.created", Payload: `{}`},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClaimDueWebhookDeliveries(t *testing.T) {
+	due := "WHERE d.status = 'PENDING' AND d.next_attempt_at <= NOW\\(\\)(.|\\s)+FOR UPDATE OF d SKIP LOCKED"
+
+	t.Run("claims due deliveries", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		repo := NewDBRepository(db, nil)
+		now := time.Now()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(due).
+			WithArgs(50).
+			WillReturnRows(sqlmock.NewRows(deliveryColumns).
+				AddRow(1, 3, "event-1", "booking.created", `{}`, "PENDING", 2, 500, "HTTP 500", now, nil, now, now, "https://a.example.com", "whsec_test").
+				AddRow(2, 3, "event-2", "booking.created", `{}`, "PENDING", 0, nil, "", now, nil, now, now, "https://a.example.com", "whsec_test"))
+		mock.ExpectExec("UPDATE webhook_delivery SET next_attempt_at = DATE_ADD\\(NOW\\(\\), INTERVAL \\? SECOND\\)\\s+WHERE delivery_id IN \\(\\?, \\?\\)").
+			WithArgs(300, 1, 2).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+
+		claimed, err := repo.ClaimDueWebhookDeliveries(context.Background(), 50, 5*time.Minute)
+		assert.NoError(t, err)
+		assert.Len(t, claimed, 2)
+		assert.Equal(t, 500, *claimed[0].ResponseCode)
+		assert.Nil(t, claimed[0].DeliveredAt)
+		assert.Equal(t, "whsec_test", claimed[0].Secret)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("nothing due", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(due).WithArgs(50).WillReturnRows(sqlmock.NewRows(deliveryColumns))
+		mock.ExpectRollback()
+
+		claimed, err := NewDBRepository(db, nil).ClaimDueWebhookDeliveries(context.Background(), 50, 5*time.Minute)
+		assert.NoError(t, err)
+		assert.Empty(t, claimed)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("lease error", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		now := time.Now()
+		mock.ExpectBegin()
+		mock.ExpectQuery(due).WithArgs(50).
+			WillReturnRows(sqlmock.NewRows(deliveryColumns).
+				AddRow(1, 3, "event-1", "booking.created", `{}`, "PENDING", 0, nil, "", now, nil, now, now, "https://a.example.com", "whsec_test"))
+		mock.ExpectExec("UPDATE webhook_delivery SET next_attempt_at").WithArgs(300, 1).WillReturnError(sql.ErrConnDone)
+		mock.ExpectRollback()
+
+		claimed, err := NewDBRepository(db, nil).ClaimDueWebhookDeliveries(context.Background(), 50, 5*time.Minute)
+		assert.Error(t, err)
+		assert.Nil(t, claimed)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestRecordWebhookAttempt(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewDBRepository(db, nil)
+	code := 503
+
+	mock.ExpectPrepare("UPDATE webhook_delivery").
+		ExpectExec().
+		WithArgs("PENDING", 2, int64(503), "HTTP 503", "PENDING", 120, "PENDING", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = repo.RecordWebhookAttempt(context.Background(), &entities.WebhookDelivery{
+		ID:           1,
+		Status:       entities.WebhookDeliveryPending,
+		Attempts:     2,
+		ResponseCode: &code,
+		LastError:    "HTTP 503",
+	}, 2*time.Minute)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
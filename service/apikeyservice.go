@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bicosteve/booking-system/entities"
+)
+
+const (
+	apiKeyPrefix     = "bk_"
+	apiKeyPrefixLen  = 12
+	apiKeyMaxName    = 100
+	apiKeyDefaultTTL = 90 * 24 * time.Hour
+	apiKeyMaxTTL     = 365 * 24 * time.Hour
+)
+
+// CreateAPIKey issues a key for the vendor. The returned key carries the
+// full secret in Key, which is not shown again.
+func (s *APIKeyService) CreateAPIKey(ctx context.Context, vendorID int, data entities.APIKeyPayload) (*entities.APIKey, error) {
+	name := strings.TrimSpace(data.Name)
+	if name == "" || len(name) > apiKeyMaxName {
+		return nil, fmt.Errorf("%w: name is required and at most %d characters", entities.ErrInvalidAPIKeyRequest, apiKeyMaxName)
+	}
+
+	if len(data.Scopes) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required", entities.ErrInvalidAPIKeyRequest)
+	}
+
+	scopes := []string{}
+	for _, sc := range data.Scopes {
+		if !slices.Contains(entities.APIKeyScopes, sc) {
+			return nil, fmt.Errorf("%w: unknown scope %q, expected one of %s", entities.ErrInvalidAPIKeyRequest, sc, strings.Join(entities.APIKeyScopes, ", "))
+		}
+		if !slices.Contains(scopes, sc) {
+			scopes = append(scopes, sc)
+		}
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(apiKeyDefaultTTL)
+	if data.ExpiresAt != nil {
+		d, err := time.Parse(entities.DateLayout, *data.ExpiresAt)
+		if err != nil {
+			return nil, fmt.Errorf("%w: expires_at must be YYYY-MM-DD", entities.ErrInvalidAPIKeyRequest)
+		}
+		if !d.After(now) || d.After(now.Add(apiKeyMaxTTL)) {
+			return nil, fmt.Errorf("%w: expires_at must be within the next 365 days", entities.ErrInvalidAPIKeyRequest)
+		}
+		expiresAt = d
+	}
+
+	prefix, err := randomHex(apiKeyPrefixLen / 2)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	key := &entities.APIKey{
+		VendorID:   vendorID,
+		Name:       name,
+		Prefix:     prefix,
+		SecretHash: hashAPIKeySecret(secret),
+		Key:        apiKeyPrefix + prefix + "_" + secret,
+		Scopes:     scopes,
+		ExpiresAt:  expiresAt,
+		CreatedAt:  now,
+	}
+
+	key.ID, err = s.apiKeyRepository.CreateAPIKey(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+func (s *APIKeyService) GetAPIKeys(ctx context.Context, vendorID int) ([]*entities.APIKey, error) {
+	return s.apiKeyRepository.GetVendorAPIKeys(ctx, vendorID)
+}
+
+func (s *APIKeyService) RevokeAPIKey(ctx context.Context, vendorID, keyID int) error {
+	return s.apiKeyRepository.RevokeAPIKey(ctx, keyID, vendorID)
+}
+
+// VerifyAPIKey returns the key matching key if it is live, and records its
+// use. Unknown, revoked and expired keys all give ErrInvalidAPIKey.
+func (s *APIKeyService) VerifyAPIKey(ctx context.Context, key string) (*entities.APIKey, error) {
+	prefix, secret, ok := parseAPIKey(key)
+	if !ok {
+		return nil, entities.ErrInvalidAPIKey
+	}
+
+	k, err := s.apiKeyRepository.GetAPIKeyByPrefix(ctx, prefix)
+	if errors.Is(err, entities.ErrNoRecord) {
+		return nil, entities.ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashAPIKeySecret(secret)), []byte(k.SecretHash)) != 1 {
+		return nil, entities.ErrInvalidAPIKey
+	}
+
+	if k.RevokedAt != nil || !time.Now().Before(k.ExpiresAt) {
+		return nil, entities.ErrInvalidAPIKey
+	}
+
+	err = s.apiKeyRepository.TouchAPIKey(ctx, k.ID)
+	if err != nil {
+		slog.WarnContext(ctx, "could not record api key use", "key_id", k.ID, "error", err)
+	}
+
+	return k, nil
+}
+
+// parseAPIKey splits bk_<prefix>_<secret>. The prefix is hex, so the secret
+// is everything after the second underscore.
+func parseAPIKey(key string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(key, apiKeyPrefix)
+	if !ok || len(rest) < apiKeyPrefixLen+2 || rest[apiKeyPrefixLen] != '_' {
+		return "", "", false
+	}
+
+	return rest[:apiKeyPrefixLen], rest[apiKeyPrefixLen+1:], true
+}
+
+func hashAPIKeySecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/repo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testAPIKeyColumns = []string{"key_id", "vendor_id", "name", "prefix", "secret_hash", "scopes", "expires_at",
+	"last_used_at", "revoked_at", "created_at"}
+
+func newAPIKeyService(t *testing.T) (*APIKeyService, sqlmock.Sqlmock, func()) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock db: %v", err)
+	}
+	return NewAPIKeyService(*repo.NewDBRepository(db, nil)), mock, func() { db.Close() }
+}
+
+func TestAPIKeyService_CreateAPIKey(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		svc, mock, cleanup := newAPIKeyService(t)
+		defer cleanup()
+
+		mock.ExpectPrepare("INSERT INTO api_key").ExpectExec().
+			WithArgs(7, "pms", sqlmock.AnyArg(), sqlmock.AnyArg(), "rooms:write,bookings:read", sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(4, 1))
+
+		k, err := svc.CreateAPIKey(context.Background(), 7, entities.APIKeyPayload{
+			Name:   " pms ",
+			Scopes: []string{entities.ScopeRoomsWrite, entities.ScopeBookingsRead, entities.ScopeRoomsWrite},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 4, k.ID)
+		assert.True(t, strings.HasPrefix(k.Key, "bk_"+k.Prefix+"_"))
+		assert.Len(t, k.Prefix, 12)
+		assert.WithinDuration(t, time.Now().Add(90*24*time.Hour), k.ExpiresAt, time.Minute)
+
+		prefix, secret, ok := parseAPIKey(k.Key)
+		assert.True(t, ok)
+		assert.Equal(t, k.Prefix, prefix)
+		assert.Equal(t, k.SecretHash, hashAPIKeySecret(secret))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	badDate, pastDate := "next year", "2020-01-01"
+	farDate := time.Now().AddDate(2, 0, 0).Format(entities.DateLayout)
+	invalid := map[string]entities.APIKeyPayload{
+		"missing name":  {Scopes: []string{entities.ScopeRoomsRead}},
+		"no scopes":     {Name: "pms"},
+		"unknown scope": {Name: "pms", Scopes: []string{"users:write"}},
+		"bad date":      {Name: "pms", Scopes: []string{entities.ScopeRoomsRead}, ExpiresAt: &badDate},
+		"past expiry":   {Name: "pms", Scopes: []string{entities.ScopeRoomsRead}, ExpiresAt: &pastDate},
+		"too far":       {Name: "pms", Scopes: []string{entities.ScopeRoomsRead}, ExpiresAt: &farDate},
+	}
+	for name, payload := range invalid {
+		t.Run(name, func(t *testing.T) {
+			svc, _, cleanup := newAPIKeyService(t)
+			defer cleanup()
+
+			_, err := svc.CreateAPIKey(context.Background(), 7, payload)
+			assert.ErrorIs(t, err, entities.ErrInvalidAPIKeyRequest)
+		})
+	}
+}
+
+func TestAPIKeyService_VerifyAPIKey(t *testing.T) {
+	secret := "s3cr3t_with-underscore"
+	key := "bk_abcdef123456_" + secret
+	now := time.Now()
+
+	t.Run("valid", func(t *testing.T) {
+		svc, mock, cleanup := newAPIKeyService(t)
+		defer cleanup()
+
+		mock.ExpectPrepare("SELECT key_id").ExpectQuery().WithArgs("abcdef123456").
+			WillReturnRows(sqlmock.NewRows(testAPIKeyColumns).
+				AddRow(4, 7, "pms", "abcdef123456", hashAPIKeySecret(secret), "rooms:write", now.Add(time.Hour), nil, nil, now))
+		mock.ExpectPrepare("UPDATE api_key SET last_used_at").ExpectExec().WithArgs(4).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		k, err := svc.VerifyAPIKey(context.Background(), key)
+		require.NoError(t, err)
+		assert.Equal(t, 7, k.VendorID)
+		assert.Equal(t, []string{entities.ScopeRoomsWrite}, k.Scopes)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	rejected := map[string]struct {
+		hash      string
+		expiresAt time.Time
+		revokedAt any
+	}{
+		"wrong secret": {hashAPIKeySecret("other"), now.Add(time.Hour), nil},
+		"expired":      {hashAPIKeySecret(secret), now.Add(-time.Hour), nil},
+		"revoked":      {hashAPIKeySecret(secret), now.Add(time.Hour), now},
+	}
+	for name, tc := range rejected {
+		t.Run(name, func(t *testing.T) {
+			svc, mock, cleanup := newAPIKeyService(t)
+			defer cleanup()
+
+			mock.ExpectPrepare("SELECT key_id").ExpectQuery().WithArgs("abcdef123456").
+				WillReturnRows(sqlmock.NewRows(testAPIKeyColumns).
+					AddRow(4, 7, "pms", "abcdef123456", tc.hash, "rooms:write", tc.expiresAt, nil, tc.revokedAt, now))
+
+			_, err := svc.VerifyAPIKey(context.Background(), key)
+			assert.ErrorIs(t, err, entities.ErrInvalidAPIKey)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+
+	t.Run("unknown prefix", func(t *testing.T) {
+		svc, mock, cleanup := newAPIKeyService(t)
+		defer cleanup()
+
+		mock.ExpectPrepare("SELECT key_id").ExpectQuery().WithArgs("abcdef123456").
+			WillReturnRows(sqlmock.NewRows(testAPIKeyColumns))
+
+		_, err := svc.VerifyAPIKey(context.Background(), key)
+		assert.ErrorIs(t, err, entities.ErrInvalidAPIKey)
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		svc, _, cleanup := newAPIKeyService(t)
+		defer cleanup()
+
+		for _, k := range []string{"", "abcdef123456_secret", "bk_short_secret", "bk_abcdef123456secret"} {
+			_, err := svc.VerifyAPIKey(context.Background(), k)
+			assert.ErrorIs(t, err, entities.ErrInvalidAPIKey, k)
+		}
+	})
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/audit"
+	"github.com/bicosteve/booking-system/repo"
+)
+
+const (
+	auditDefaultLimit = 50
+	auditMaxLimit     = 200
+	// AuditExportLimit caps the entries in one CSV export.
+	AuditExportLimit = 10000
+)
+
+// RecordAudit appends entries to the audit log.
+func (s *AuditService) RecordAudit(ctx context.Context, entries []entities.AuditEntry) error {
+	return s.auditRepository.InsertAuditEntries(ctx, entries)
+}
+
+// GetAuditLog returns a page of the vendor's audit log, newest first, and
+// the BeforeID of the next page, or 0 on the last one. filter.Limit
+// defaults to 50 and is capped at 200.
+func (s *AuditService) GetAuditLog(ctx context.Context, filter entities.AuditFilter) ([]*entities.AuditEntry, int64, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = auditDefaultLimit
+	}
+
+	filter.Limit = min(filter.Limit, auditMaxLimit)
+
+	entries, err := s.auditRepository.GetAuditEntries(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var next int64
+	if len(entries) == filter.Limit {
+		next = entries[len(entries)-1].ID
+	}
+
+	return entries, next, nil
+}
+
+// ExportAuditLog returns up to AuditExportLimit of the vendor's entries
+// matching filter, newest first.
+func (s *AuditService) ExportAuditLog(ctx context.Context, filter entities.AuditFilter) ([]*entities.AuditEntry, error) {
+	filter.Limit = AuditExportLimit
+	return s.auditRepository.GetAuditEntries(ctx, filter)
+}
+
+// recordAudit logs a change to an entity of vendorID's (0 for none) with the
+// fields that differ between before and after. Inside a request the entry is
+// written once the request is handled; background work writes it right away.
+// A failed write is logged but does not undo the change.
+func recordAudit(ctx context.Context, r repo.Repository, action, entityType, entityID string, vendorID int, before, after any) {
+	e := entities.AuditEntry{Action: action, EntityType: entityType, EntityID: entityID}
+	if vendorID != 0 {
+		e.VendorID = &vendorID
+	}
+
+	e.Before, e.After = audit.Diff(before, after)
+	audit.Stamp(ctx, &e)
+
+	if audit.Add(ctx, e) {
+		return
+	}
+
+	err := r.InsertAuditEntries(ctx, []entities.AuditEntry{e})
+	if err != nil {
+		slog.ErrorContext(ctx, "writing audit entry failed", "action", action, "entity_id", entityID, "error", err)
+	}
+}
+
+// roomVendor returns the id of the vendor owning roomID, or 0 when it cannot
+// be found, for audit entries about the room's payments.
+func roomVendor(ctx context.Context, r repo.Repository, roomID int) int {
+	room, err := r.FindRoomByID(ctx, roomID)
+	if err != nil {
+		slog.WarnContext(ctx, "finding room vendor for audit failed", "room_id", roomID, "error", err)
+		return 0
+	}
+
+	vendorID, _ := strconv.Atoi(room.VenderId)
+	return vendorID
+}
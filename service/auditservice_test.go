@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/audit"
+	"github.com/bicosteve/booking-system/repo"
+	"github.com/go-redis/redismock/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func newAuditService(t *testing.T) (*AuditService, sqlmock.Sqlmock, func()) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock db: %v", err)
+	}
+	rdb, _ := redismock.NewClientMock()
+	repository := *repo.NewDBRepository(db, rdb)
+	return NewAuditService(repository), mock, func() { db.Close() }
+}
+
+// expectAudit expects a change made outside a request to be written to the
+// audit log straight away, as the system's.
+func expectAudit(mock sqlmock.Sqlmock, action, entityID string, vendorID int) {
+	mock.ExpectPrepare("INSERT INTO audit_log").
+		ExpectExec().
+		WithArgs(entities.ActorSystem, nil, nil, vendorID, action, sqlmock.AnyArg(), entityID,
+			sqlmock.AnyArg(), sqlmock.AnyArg(), "", "").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+}
+
+func TestAuditService_GetAuditLog(t *testing.T) {
+	columns := []string{"audit_id", "actor_type", "actor_id", "api_key_id", "vendor_id", "action", "entity_type", "entity_id",
+		"before_state", "after_state", "ip", "request_id", "created_at"}
+
+	t.Run("default limit", func(t *testing.T) {
+		svc, mock, cleanup := newAuditService(t)
+		defer cleanup()
+
+		mock.ExpectPrepare("FROM audit_log WHERE vendor_id = \\?").
+			ExpectQuery().
+			WithArgs(7, 50).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow(3, entities.ActorVendor, 7, nil, 7, entities.AuditRoomUpdate, "room", "1",
+					`{"status":"VACANT"}`, `{"status":"BOOKED"}`, "10.0.0.1", "req-1", time.Now()))
+
+		entries, next, err := svc.GetAuditLog(context.Background(), entities.AuditFilter{VendorID: 7})
+		assert.NoError(t, err)
+		assert.Zero(t, next)
+		assert.Len(t, entries, 1)
+		assert.Equal(t, 7, *entries[0].ActorID)
+		assert.Nil(t, entries[0].APIKeyID)
+		assert.JSONEq(t, `{"status":"BOOKED"}`, string(entries[0].After))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("capped limit", func(t *testing.T) {
+		svc, mock, cleanup := newAuditService(t)
+		defer cleanup()
+
+		mock.ExpectPrepare("FROM audit_log").
+			ExpectQuery().
+			WithArgs(7, 200).
+			WillReturnRows(sqlmock.NewRows(columns))
+
+		entries, _, err := svc.GetAuditLog(context.Background(), entities.AuditFilter{VendorID: 7, Limit: 5000})
+		assert.NoError(t, err)
+		assert.Empty(t, entries)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("full page", func(t *testing.T) {
+		svc, mock, cleanup := newAuditService(t)
+		defer cleanup()
+
+		mock.ExpectPrepare("AND action = \\? AND audit_id < \\? ORDER BY audit_id DESC LIMIT \\?").
+			ExpectQuery().
+			WithArgs(7, entities.AuditRoomUpdate, int64(40), 2).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow(39, entities.ActorAPIKey, 7, 4, 7, entities.AuditRoomUpdate, "room", "1", nil, nil, "", "", time.Now()).
+				AddRow(35, entities.ActorAPIKey, 7, 4, 7, entities.AuditRoomUpdate, "room", "2", nil, nil, "", "", time.Now()))
+
+		entries, next, err := svc.GetAuditLog(context.Background(),
+			entities.AuditFilter{VendorID: 7, Action: entities.AuditRoomUpdate, BeforeID: 40, Limit: 2})
+		assert.NoError(t, err)
+		assert.Len(t, entries, 2)
+		assert.Equal(t, int64(35), next)
+		assert.Equal(t, 4, *entries[0].APIKeyID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error", func(t *testing.T) {
+		svc, mock, cleanup := newAuditService(t)
+		defer cleanup()
+
+		mock.ExpectPrepare("FROM audit_log").WillReturnError(sql.ErrConnDone)
+
+		entries, _, err := svc.GetAuditLog(context.Background(), entities.AuditFilter{VendorID: 7})
+		assert.Error(t, err)
+		assert.Nil(t, entries)
+	})
+}
+
+func TestAuditService_ExportAuditLog(t *testing.T) {
+	svc, mock, cleanup := newAuditService(t)
+	defer cleanup()
+
+	mock.ExpectPrepare("FROM audit_log").
+		ExpectQuery().
+		WithArgs(7, AuditExportLimit).
+		WillReturnRows(sqlmock.NewRows([]string{"audit_id"}))
+
+	entries, err := svc.ExportAuditLog(context.Background(), entities.AuditFilter{VendorID: 7, Limit: 10})
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecordAudit_InRequest(t *testing.T) {
+	svc, mock, cleanup := newRoomService(t)
+	defer cleanup()
+
+	store := &auditStore{}
+	handler := audit.Middleware(store, false, false)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), entities.UseridKeyValue, "7")
+		ctx = context.WithValue(ctx, entities.IsVendorKeyValue, "YES")
+		recordAudit(ctx, svc.roomRepository, entities.AuditRoomDelete, "room", "1", 7, map[string]string{"status": "VACANT"}, nil)
+	}))
+
+	req := httptest.NewRequest(http.MethodDelete, "/admin/room/1", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	// Nothing is written to the database until the request is done.
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Len(t, store.entries, 1)
+
+	e := store.entries[0]
+	assert.Equal(t, entities.ActorVendor, e.ActorType)
+	assert.Equal(t, 7, *e.ActorID)
+	assert.Equal(t, "10.0.0.1", e.IP)
+	assert.JSONEq(t, `{"status":"VACANT"}`, string(e.Before))
+	assert.Nil(t, e.After)
+}
+
+type auditStore struct {
+	entries []entities.AuditEntry
+}
+
+func (s *auditStore) RecordAudit(_ context.Context, entries []entities.AuditEntry) error {
+	s.entries = append(s.entries, entries...)
+	return nil
+}
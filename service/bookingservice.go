@@ -2,17 +2,91 @@ package service
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/metrics"
 )
 
-func (b *BookingService) MakeBooking(ctx context.Context, data entities.BookingPayload) error {
+// bookingTransitions is the booking state machine. For each status it lists
+// the statuses a booking may move to and who may move it there. Checked out,
+// cancelled and no-show bookings are final. Guests may only cancel before
+// paying; a paid booking is cancelled by the vendor, who handles the refund.
+var bookingTransitions = map[int]map[int][]string{
+	entities.BookingStatusPending: {
+		entities.BookingStatusConfirmed: {entities.ActorSystem},
+		entities.BookingStatusCancelled: {entities.ActorGuest, entities.ActorVendor},
+	},
+	entities.BookingStatusConfirmed: {
+		entities.BookingStatusCheckedIn: {entities.ActorVendor},
+		entities.BookingStatusCancelled: {entities.ActorVendor},
+		entities.BookingStatusNoShow:    {entities.ActorVendor},
+	},
+	entities.BookingStatusCheckedIn: {
+		entities.BookingStatusCheckedOut: {entities.ActorVendor, entities.ActorSystem},
+	},
+}
+
+// CanTransitionBooking reports whether a booking may ever move from one
+// status to the other.
+func CanTransitionBooking(from, to int) bool {
+	_, ok := bookingTransitions[from][to]
+	return ok
+}
+
+// CanTriggerTransition reports whether role may move a booking from one
+// status to the other.
+func CanTriggerTransition(from, to int, role string) bool {
+	for _, allowed := range bookingTransitions[from][to] {
+		if allowed == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MakeBooking books a room and returns the new booking's id.
+func (b *BookingService) MakeBooking(ctx context.Context, data entities.BookingPayload) (int, error) {
+
+	bookingID, err := b.bookingRepository.CreateABooking(ctx, data)
+	if err != nil {
+		return 0, err
+	}
+
+	metrics.BookingsCreated.Inc()
+
+	return bookingID, nil
+}
+
+// CheckAvailability returns ErrRoomBlocked when the room is blocked or
+// already booked on any of the days nights from checkIn.
+func (b *BookingService) CheckAvailability(ctx context.Context, roomID int, checkIn time.Time, days int) error {
+	checkOut := checkIn.AddDate(0, 0, days)
+
+	blocked, err := b.bookingRepository.IsRoomBlocked(ctx, roomID, checkIn, checkOut)
+	if err != nil {
+		return err
+	}
+
+	if blocked {
+		return entities.ErrRoomBlocked
+	}
 
-	err := b.bookingRepository.CreateABooking(ctx, data)
+	booked, err := b.bookingRepository.IsRoomBooked(ctx, roomID, checkIn, checkOut)
 	if err != nil {
 		return err
 	}
 
+	if booked {
+		return entities.ErrRoomBlocked
+	}
+
 	return nil
 }
 
@@ -52,10 +126,142 @@ func (b *BookingService) UpdateABooking(ctx context.Context, data *entities.Book
 	return nil
 }
 
+// DeleteABooking deletes a booking, frees its room and records the deleted
+// booking in the audit log.
 func (b *BookingService) DeleteABooking(ctx context.Context, bookingID, vendorID, roomID int) error {
-	err := b.bookingRepository.DeleteABooking(ctx, bookingID, vendorID, roomID)
+	before, err := b.bookingRepository.GetBookingByID(ctx, bookingID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	err = b.bookingRepository.DeleteABooking(ctx, bookingID, vendorID, roomID)
 	if err != nil {
 		return err
 	}
+
+	if before != nil {
+		recordAudit(ctx, b.bookingRepository, entities.AuditBookingDelete, "booking", strconv.Itoa(before.ID), before.VenderID, before, nil)
+	}
+
 	return nil
 }
+
+// GetVendorBooking returns a booking on one of the vendor's rooms.
+func (b *BookingService) GetVendorBooking(ctx context.Context, bookingID, vendorID int) (*entities.Booking, error) {
+	booking, err := b.bookingRepository.GetBookingByID(ctx, bookingID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, entities.ErrNoRecord
+		}
+		return nil, err
+	}
+
+	if booking.VenderID != vendorID {
+		return nil, entities.ErrNoRecord
+	}
+
+	return booking, nil
+}
+
+// GetGuestBooking returns one of the user's own bookings in any status.
+func (b *BookingService) GetGuestBooking(ctx context.Context, bookingID, userID int) (*entities.Booking, error) {
+	booking, err := b.bookingRepository.GetBookingByID(ctx, bookingID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, entities.ErrNoRecord
+		}
+		return nil, err
+	}
+
+	if booking.UserID != userID {
+		return nil, entities.ErrNoRecord
+	}
+
+	return booking, nil
+}
+
+// GetBookingHistory returns every status change of a booking, oldest first.
+func (b *BookingService) GetBookingHistory(ctx context.Context, bookingID int) ([]*entities.BookingStatusChange, error) {
+	return b.bookingRepository.GetBookingHistory(ctx, bookingID)
+}
+
+// ChangeBookingStatus moves booking to status to if the state machine allows
+// it and actor may trigger the change, and records it in the booking's
+// history. Callers are expected to have checked that the actor owns the
+// booking.
+func (b *BookingService) ChangeBookingStatus(ctx context.Context, booking *entities.Booking, to int, actor entities.BookingActor, reason string) error {
+	if !CanTransitionBooking(booking.Status, to) {
+		return fmt.Errorf("%w: cannot move a %s booking to %s", entities.ErrInvalidTransition,
+			entities.BookingStatusNames[booking.Status], entities.BookingStatusNames[to])
+	}
+
+	if !CanTriggerTransition(booking.Status, to, actor.Role) {
+		return fmt.Errorf("%w: a %s cannot move a %s booking to %s", entities.ErrTransitionForbidden,
+			actor.Role, entities.BookingStatusNames[booking.Status], entities.BookingStatusNames[to])
+	}
+
+	reason = strings.TrimSpace(reason)
+	if len(reason) > 255 {
+		return errors.New("reason must be at most 255 characters")
+	}
+
+	change := &entities.BookingStatusChange{
+		BookingID:  booking.ID,
+		FromStatus: booking.Status,
+		ToStatus:   to,
+		Actor:      actor.Role,
+		Reason:     reason,
+	}
+
+	if actor.ID != 0 {
+		change.ActorID = &actor.ID
+	}
+
+	updated, err := b.bookingRepository.UpdateBookingStatus(ctx, booking, change)
+	if err != nil {
+		return err
+	}
+
+	if !updated {
+		return fmt.Errorf("%w: booking %d was changed by someone else", entities.ErrInvalidTransition, booking.ID)
+	}
+
+	recordAudit(ctx, b.bookingRepository, entities.AuditBookingStatus, "booking", strconv.Itoa(booking.ID), booking.VenderID,
+		map[string]any{"status": entities.BookingStatusNames[booking.Status]},
+		map[string]any{"status": entities.BookingStatusNames[to], "reason": reason})
+
+	booking.Status = to
+
+	if to == entities.BookingStatusConfirmed {
+		metrics.BookingsConfirmed.Inc()
+	}
+
+	return nil
+}
+
+var systemActor = entities.BookingActor{Role: entities.ActorSystem}
+
+// CompleteOverdueStays checks out every guest whose stay has ended and frees
+// their rooms. It carries on past individual failures and returns the stays
+// that were completed.
+func (b *BookingService) CompleteOverdueStays(ctx context.Context) ([]*entities.Booking, error) {
+	bookings, err := b.bookingRepository.GetOverdueStays(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var completed []*entities.Booking
+	var errs []error
+
+	for _, booking := range bookings {
+		err = b.ChangeBookingStatus(ctx, booking, entities.BookingStatusCheckedOut, systemActor, "stay ended")
+		if err != nil {
+			errs = append(errs, fmt.Errorf("booking %d: %w", booking.ID, err))
+			continue
+		}
+
+		completed = append(completed, booking)
+	}
+
+	return completed, errors.Join(errs...)
+}
@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"database/sql"
+	"strings"
 	"testing"
 	"time"
 
@@ -28,22 +29,31 @@ func bsIntPtr(i int) *int { return &i }
 
 func TestBookingService_MakeBooking(t *testing.T) {
 	days, userID, roomID, status := 2, 5, 10, 0
+	currency, checkIn := "KES", "2026-11-01"
 
 	t.Run("success", func(t *testing.T) {
 		svc, mock, cleanup := newBookingService(t)
 		defer cleanup()
 
 		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT room_id FROM room WHERE room_id = \\? FOR UPDATE").WithArgs(roomID).
+			WillReturnRows(sqlmock.NewRows([]string{"room_id"}).AddRow(roomID))
+		mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM room_block").WithArgs(roomID, "2026-11-03", checkIn).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM booking").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
 		mock.ExpectPrepare("UPDATE room")
 		mock.ExpectPrepare("INSERT INTO booking")
 		mock.ExpectExec("UPDATE room").WithArgs(roomID).WillReturnResult(sqlmock.NewResult(0, 1))
-		mock.ExpectExec("INSERT INTO booking").WithArgs(days, userID, roomID, status).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT INTO booking").WithArgs(days, userID, roomID, currency, status, checkIn).WillReturnResult(sqlmock.NewResult(42, 1))
+		mock.ExpectExec("INSERT INTO booking_status_history").WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
 
-		err := svc.MakeBooking(context.Background(), entities.BookingPayload{
-			Days: &days, UserID: &userID, RoomID: &roomID, Status: &status,
+		id, err := svc.MakeBooking(context.Background(), entities.BookingPayload{
+			CheckIn: &checkIn, Days: &days, UserID: &userID, RoomID: &roomID, Currency: &currency, Status: &status,
 		})
 		assert.NoError(t, err)
+		assert.Equal(t, 42, id)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 
@@ -53,13 +63,37 @@ func TestBookingService_MakeBooking(t *testing.T) {
 
 		mock.ExpectBegin().WillReturnError(sql.ErrConnDone)
 
-		err := svc.MakeBooking(context.Background(), entities.BookingPayload{
-			Days: &days, UserID: &userID, RoomID: &roomID, Status: &status,
+		_, err := svc.MakeBooking(context.Background(), entities.BookingPayload{
+			Days: &days, UserID: &userID, RoomID: &roomID, Currency: &currency, Status: &status,
 		})
 		assert.Error(t, err)
 	})
 }
 
+func TestBookingService_CheckAvailability(t *testing.T) {
+	svc, mock, cleanup := newBookingService(t)
+	defer cleanup()
+
+	checkIn := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectPrepare("SELECT COUNT\\(\\*\\) FROM room_block").ExpectQuery().WithArgs(10, "2026-11-03", "2026-11-01").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectPrepare("SELECT COUNT\\(\\*\\) FROM booking").ExpectQuery().
+		WithArgs(10, 0, entities.BookingStatusPending, entities.BookingStatusConfirmed, entities.BookingStatusCheckedIn, "2026-11-03", "2026-11-01").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectPrepare("SELECT COUNT\\(\\*\\) FROM room_block").ExpectQuery().WithArgs(10, "2026-11-08", "2026-11-01").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectPrepare("SELECT COUNT\\(\\*\\) FROM room_block").ExpectQuery().WithArgs(10, "2026-11-05", "2026-11-01").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectPrepare("SELECT COUNT\\(\\*\\) FROM booking").ExpectQuery().
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	assert.NoError(t, svc.CheckAvailability(context.Background(), 10, checkIn, 2))
+	assert.ErrorIs(t, svc.CheckAvailability(context.Background(), 10, checkIn, 7), entities.ErrRoomBlocked)
+	// A room taken by another guest is as unavailable as a blocked one.
+	assert.ErrorIs(t, svc.CheckAvailability(context.Background(), 10, checkIn, 4), entities.ErrRoomBlocked)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestBookingService_GetUserBooking(t *testing.T) {
 	mockTime := time.Now()
 
@@ -67,11 +101,11 @@ func TestBookingService_GetUserBooking(t *testing.T) {
 		svc, mock, cleanup := newBookingService(t)
 		defer cleanup()
 
-		mock.ExpectPrepare("SELECT \\* FROM booking").
+		mock.ExpectPrepare("SELECT booking_id, days, user_id, room_id, currency").
 			ExpectQuery().
 			WithArgs(1, 2).
-			WillReturnRows(sqlmock.NewRows([]string{"id", "days", "user_id", "room_id", "created_at", "updated_at"}).
-				AddRow(1, 3, 2, 1, mockTime, mockTime))
+			WillReturnRows(sqlmock.NewRows([]string{"id", "days", "user_id", "room_id", "currency", "status", "checked_in_at", "checked_out_at", "created_at", "updated_at"}).
+				AddRow(1, 3, 2, 1, "KES", 0, nil, nil, mockTime, mockTime))
 
 		booking, err := svc.GetUserBooking(context.Background(), 1, 2)
 		assert.NoError(t, err)
@@ -82,7 +116,7 @@ func TestBookingService_GetUserBooking(t *testing.T) {
 		svc, mock, cleanup := newBookingService(t)
 		defer cleanup()
 
-		mock.ExpectPrepare("SELECT \\* FROM booking").
+		mock.ExpectPrepare("SELECT booking_id, days, user_id, room_id, currency").
 			ExpectQuery().
 			WithArgs(1, 2).
 			WillReturnError(sql.ErrNoRows)
@@ -100,11 +134,11 @@ func TestBookingService_GetUserBookings(t *testing.T) {
 		svc, mock, cleanup := newBookingService(t)
 		defer cleanup()
 
-		mock.ExpectPrepare("SELECT \\* FROM booking WHERE user_id = ?").
+		mock.ExpectPrepare("SELECT booking_id, days, user_id, room_id, currency, status, checked_in_at, checked_out_at, created_at, updated_at\\s+FROM booking WHERE user_id = ?").
 			ExpectQuery().
 			WithArgs(5).
-			WillReturnRows(sqlmock.NewRows([]string{"id", "days", "user_id", "room_id", "created_at", "updated_at"}).
-				AddRow(1, 2, 5, 10, mockTime, mockTime))
+			WillReturnRows(sqlmock.NewRows([]string{"id", "days", "user_id", "room_id", "currency", "status", "checked_in_at", "checked_out_at", "created_at", "updated_at"}).
+				AddRow(1, 2, 5, 10, "KES", 0, nil, nil, mockTime, mockTime))
 
 		bookings, err := svc.GetUserBookings(context.Background(), 5)
 		assert.NoError(t, err)
@@ -115,7 +149,7 @@ func TestBookingService_GetUserBookings(t *testing.T) {
 		svc, mock, cleanup := newBookingService(t)
 		defer cleanup()
 
-		mock.ExpectPrepare("SELECT \\* FROM booking WHERE user_id = ?").
+		mock.ExpectPrepare("SELECT booking_id, days, user_id, room_id, currency, status, checked_in_at, checked_out_at, created_at, updated_at\\s+FROM booking WHERE user_id = ?").
 			ExpectQuery().
 			WithArgs(5).
 			WillReturnError(sql.ErrConnDone)
@@ -136,8 +170,8 @@ func TestBookingService_GetVendoerBookings(t *testing.T) {
 		mock.ExpectPrepare("SELECT b.booking_id").
 			ExpectQuery().
 			WithArgs(7).
-			WillReturnRows(sqlmock.NewRows([]string{"booking_id", "days", "user_id", "room_id", "vender_id", "created_at", "updated_at"}).
-				AddRow(1, 2, 5, 10, 7, mockTime, mockTime))
+			WillReturnRows(sqlmock.NewRows([]string{"booking_id", "days", "user_id", "room_id", "currency", "status", "vender_id", "checked_in_at", "checked_out_at", "created_at", "updated_at"}).
+				AddRow(1, 2, 5, 10, "KES", 0, 7, nil, nil, mockTime, mockTime))
 
 		bookings, err := svc.GetVendoerBookings(context.Background(), 7)
 		assert.NoError(t, err)
@@ -161,12 +195,20 @@ func TestBookingService_UpdateABooking(t *testing.T) {
 		svc, mock, cleanup := newBookingService(t)
 		defer cleanup()
 
-		mock.ExpectPrepare("UPDATE booking SET days").
-			ExpectExec().
-			WithArgs(4, 1, 100, 5).
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT room_id, days, status").WithArgs(100, 5).
+			WillReturnRows(sqlmock.NewRows([]string{"room_id", "days", "status", "check_in"}).
+				AddRow(10, 5, entities.BookingStatusPending, time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)))
+		mock.ExpectQuery("FROM room WHERE room_id = \\? FOR UPDATE").WithArgs(10).
+			WillReturnRows(sqlmock.NewRows([]string{"room_id"}).AddRow(10))
+		mock.ExpectQuery("FROM room_block").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery("FROM booking").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectExec("UPDATE booking SET days").
+			WithArgs(4, 100).
 			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
 
-		data := &entities.BookingPayload{Days: bsIntPtr(4), UserID: bsIntPtr(5), Status: bsIntPtr(1)}
+		data := &entities.BookingPayload{Days: bsIntPtr(4), UserID: bsIntPtr(5)}
 		err := svc.UpdateABooking(context.Background(), data, 100)
 		assert.NoError(t, err)
 		assert.NoError(t, mock.ExpectationsWereMet())
@@ -176,25 +218,35 @@ func TestBookingService_UpdateABooking(t *testing.T) {
 		svc, mock, cleanup := newBookingService(t)
 		defer cleanup()
 
-		mock.ExpectPrepare("UPDATE booking SET days").WillReturnError(sql.ErrConnDone)
+		mock.ExpectBegin().WillReturnError(sql.ErrConnDone)
 
-		data := &entities.BookingPayload{Days: bsIntPtr(4), UserID: bsIntPtr(5), Status: bsIntPtr(1)}
+		data := &entities.BookingPayload{Days: bsIntPtr(4), UserID: bsIntPtr(5)}
 		err := svc.UpdateABooking(context.Background(), data, 100)
 		assert.Error(t, err)
 	})
 }
 
 func TestBookingService_DeleteABooking(t *testing.T) {
+	mockTime := time.Now()
+
 	t.Run("success", func(t *testing.T) {
 		svc, mock, cleanup := newBookingService(t)
 		defer cleanup()
 
+		mock.ExpectPrepare("FROM booking b JOIN room r").
+			ExpectQuery().
+			WithArgs(100).
+			WillReturnRows(sqlmock.NewRows([]string{"booking_id", "days", "user_id", "room_id", "currency", "status", "vender_id", "checked_in_at", "checked_out_at", "created_at", "updated_at"}).
+				AddRow(100, 2, 5, 10, "KES", entities.BookingStatusConfirmed, 7, nil, nil, mockTime, mockTime))
 		mock.ExpectBegin()
 		mock.ExpectPrepare("UPDATE room SET status = 'VACANT'")
 		mock.ExpectPrepare("DELETE FROM booking WHERE booking_id = ?")
 		mock.ExpectExec("UPDATE room SET status = 'VACANT'").WithArgs(10, 7).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("DELETE FROM booking_status_history").WithArgs(100).WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec("DELETE FROM review").WithArgs(100).WillReturnResult(sqlmock.NewResult(0, 0))
 		mock.ExpectExec("DELETE FROM booking WHERE booking_id = ?").WithArgs(100).WillReturnResult(sqlmock.NewResult(0, 1))
 		mock.ExpectCommit()
+		expectAudit(mock, entities.AuditBookingDelete, "100", 7)
 
 		err := svc.DeleteABooking(context.Background(), 100, 7, 10)
 		assert.NoError(t, err)
@@ -205,9 +257,185 @@ func TestBookingService_DeleteABooking(t *testing.T) {
 		svc, mock, cleanup := newBookingService(t)
 		defer cleanup()
 
+		mock.ExpectPrepare("FROM booking b JOIN room r").
+			ExpectQuery().
+			WillReturnError(sql.ErrNoRows)
 		mock.ExpectBegin().WillReturnError(sql.ErrConnDone)
 
 		err := svc.DeleteABooking(context.Background(), 100, 7, 10)
 		assert.Error(t, err)
 	})
 }
+
+func TestCanTransitionBooking(t *testing.T) {
+	tests := []struct {
+		from, to int
+		want     bool
+	}{
+		{entities.BookingStatusPending, entities.BookingStatusConfirmed, true},
+		{entities.BookingStatusPending, entities.BookingStatusCancelled, true},
+		{entities.BookingStatusPending, entities.BookingStatusCheckedIn, false},
+		{entities.BookingStatusConfirmed, entities.BookingStatusCheckedIn, true},
+		{entities.BookingStatusConfirmed, entities.BookingStatusNoShow, true},
+		{entities.BookingStatusConfirmed, entities.BookingStatusCheckedOut, false},
+		{entities.BookingStatusCheckedIn, entities.BookingStatusCheckedOut, true},
+		{entities.BookingStatusCheckedIn, entities.BookingStatusCancelled, false},
+		{entities.BookingStatusCheckedOut, entities.BookingStatusCheckedIn, false},
+		{entities.BookingStatusCancelled, entities.BookingStatusConfirmed, false},
+		{entities.BookingStatusNoShow, entities.BookingStatusCheckedIn, false},
+	}
+
+	for _, tt := range tests {
+		name := entities.BookingStatusNames[tt.from] + " to " + entities.BookingStatusNames[tt.to]
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, CanTransitionBooking(tt.from, tt.to))
+		})
+	}
+}
+
+func TestCanTriggerTransition(t *testing.T) {
+	assert.True(t, CanTriggerTransition(entities.BookingStatusPending, entities.BookingStatusCancelled, entities.ActorGuest))
+	assert.False(t, CanTriggerTransition(entities.BookingStatusConfirmed, entities.BookingStatusCancelled, entities.ActorGuest))
+	assert.False(t, CanTriggerTransition(entities.BookingStatusPending, entities.BookingStatusConfirmed, entities.ActorGuest))
+	assert.True(t, CanTriggerTransition(entities.BookingStatusPending, entities.BookingStatusConfirmed, entities.ActorSystem))
+	assert.False(t, CanTriggerTransition(entities.BookingStatusConfirmed, entities.BookingStatusCheckedIn, entities.ActorSystem))
+	assert.True(t, CanTriggerTransition(entities.BookingStatusCheckedIn, entities.BookingStatusCheckedOut, entities.ActorSystem))
+}
+
+func TestBookingService_ChangeBookingStatus(t *testing.T) {
+	vendor := entities.BookingActor{Role: entities.ActorVendor, ID: 7}
+
+	t.Run("valid transition", func(t *testing.T) {
+		svc, mock, cleanup := newBookingService(t)
+		defer cleanup()
+
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE booking SET status").
+			WithArgs(entities.BookingStatusCheckedIn, 100, entities.BookingStatusConfirmed).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO booking_status_history").
+			WithArgs(100, entities.BookingStatusConfirmed, entities.BookingStatusCheckedIn, entities.ActorVendor, sql.NullInt64{Int64: 7, Valid: true}, "early arrival").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectPrepare("INSERT INTO audit_log").
+			ExpectExec().
+			WithArgs(entities.ActorSystem, nil, nil, 7, entities.AuditBookingStatus, "booking", "100",
+				`{"status":"confirmed"}`, `{"reason":"early arrival","status":"checked in"}`, "", "").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		booking := &entities.Booking{ID: 100, RoomID: 10, Status: entities.BookingStatusConfirmed, VenderID: 7}
+		err := svc.ChangeBookingStatus(context.Background(), booking, entities.BookingStatusCheckedIn, vendor, "  early arrival ")
+		assert.NoError(t, err)
+		assert.Equal(t, entities.BookingStatusCheckedIn, booking.Status)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("invalid transition", func(t *testing.T) {
+		svc, _, cleanup := newBookingService(t)
+		defer cleanup()
+
+		booking := &entities.Booking{ID: 100, RoomID: 10, Status: entities.BookingStatusPending}
+		err := svc.ChangeBookingStatus(context.Background(), booking, entities.BookingStatusCheckedOut, vendor, "")
+		assert.ErrorIs(t, err, entities.ErrInvalidTransition)
+		assert.EqualError(t, err, "BOOKING: invalid status transition: cannot move a pending booking to checked out")
+	})
+
+	t.Run("actor not allowed", func(t *testing.T) {
+		svc, _, cleanup := newBookingService(t)
+		defer cleanup()
+
+		guest := entities.BookingActor{Role: entities.ActorGuest, ID: 5}
+		booking := &entities.Booking{ID: 100, RoomID: 10, Status: entities.BookingStatusConfirmed}
+		err := svc.ChangeBookingStatus(context.Background(), booking, entities.BookingStatusCancelled, guest, "")
+		assert.ErrorIs(t, err, entities.ErrTransitionForbidden)
+		assert.Equal(t, entities.BookingStatusConfirmed, booking.Status)
+	})
+
+	t.Run("reason too long", func(t *testing.T) {
+		svc, _, cleanup := newBookingService(t)
+		defer cleanup()
+
+		booking := &entities.Booking{ID: 100, RoomID: 10, Status: entities.BookingStatusConfirmed}
+		err := svc.ChangeBookingStatus(context.Background(), booking, entities.BookingStatusCancelled, vendor, strings.Repeat("a", 256))
+		assert.EqualError(t, err, "reason must be at most 255 characters")
+	})
+
+	t.Run("lost race", func(t *testing.T) {
+		svc, mock, cleanup := newBookingService(t)
+		defer cleanup()
+
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE booking SET status").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		booking := &entities.Booking{ID: 100, RoomID: 10, Status: entities.BookingStatusConfirmed}
+		err := svc.ChangeBookingStatus(context.Background(), booking, entities.BookingStatusNoShow, vendor, "")
+		assert.ErrorIs(t, err, entities.ErrInvalidTransition)
+		assert.Equal(t, entities.BookingStatusConfirmed, booking.Status)
+	})
+}
+
+func TestBookingService_GetGuestBooking(t *testing.T) {
+	mockTime := time.Now()
+	rows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"booking_id", "days", "user_id", "room_id", "currency", "status", "vender_id", "checked_in_at", "checked_out_at", "created_at", "updated_at"}).
+			AddRow(100, 2, 5, 10, "KES", entities.BookingStatusCheckedOut, 7, mockTime, mockTime, mockTime, mockTime)
+	}
+
+	t.Run("own booking", func(t *testing.T) {
+		svc, mock, cleanup := newBookingService(t)
+		defer cleanup()
+
+		mock.ExpectPrepare("WHERE b.booking_id = \\?").ExpectQuery().WithArgs(100).WillReturnRows(rows())
+
+		booking, err := svc.GetGuestBooking(context.Background(), 100, 5)
+		assert.NoError(t, err)
+		assert.Equal(t, entities.BookingStatusCheckedOut, booking.Status)
+	})
+
+	t.Run("someone else's booking", func(t *testing.T) {
+		svc, mock, cleanup := newBookingService(t)
+		defer cleanup()
+
+		mock.ExpectPrepare("WHERE b.booking_id = \\?").ExpectQuery().WithArgs(100).WillReturnRows(rows())
+
+		_, err := svc.GetGuestBooking(context.Background(), 100, 6)
+		assert.ErrorIs(t, err, entities.ErrNoRecord)
+	})
+}
+
+func TestBookingService_CompleteOverdueStays(t *testing.T) {
+	mockTime := time.Now()
+	svc, mock, cleanup := newBookingService(t)
+	defer cleanup()
+
+	mock.ExpectPrepare("DATE_ADD").
+		ExpectQuery().
+		WithArgs(entities.BookingStatusCheckedIn).
+		WillReturnRows(sqlmock.NewRows([]string{"booking_id", "days", "user_id", "room_id", "currency", "status", "vender_id", "checked_in_at", "checked_out_at", "created_at", "updated_at"}).
+			AddRow(100, 2, 5, 10, "KES", entities.BookingStatusCheckedIn, 7, mockTime, nil, mockTime, mockTime).
+			AddRow(101, 1, 6, 11, "KES", entities.BookingStatusCheckedIn, 7, mockTime, nil, mockTime, mockTime))
+
+	// The first stay is completed, the second fails but does not stop the run.
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE booking SET status").
+		WithArgs(entities.BookingStatusCheckedOut, 100, entities.BookingStatusCheckedIn).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE room SET status = 'VACANT'").WithArgs(10).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO booking_status_history").
+		WithArgs(100, entities.BookingStatusCheckedIn, entities.BookingStatusCheckedOut, entities.ActorSystem, sql.NullInt64{}, "stay ended").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	expectAudit(mock, entities.AuditBookingStatus, "100", 7)
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE booking SET status").
+		WithArgs(entities.BookingStatusCheckedOut, 101, entities.BookingStatusCheckedIn).
+		WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
+
+	completed, err := svc.CompleteOverdueStays(context.Background())
+	assert.Len(t, completed, 1)
+	assert.Equal(t, 100, completed[0].ID)
+	assert.ErrorIs(t, err, sql.ErrConnDone)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/ical"
+	"github.com/bicosteve/booking-system/pkg/safehttp"
+)
+
+const (
+	// CalendarProdID identifies this app in exported calendars.
+	CalendarProdID = "-//booking-system//rooms//EN"
+	// maxCalendarSize caps how much of an external calendar is read.
+	maxCalendarSize = 5 << 20
+	// feedHorizon is how far ahead exported feeds reach.
+	feedHorizon = 2 * 365 * 24 * time.Hour
+)
+
+// RoomFeed returns the calendar of a room for its feed, checking token
+// against the room's feed token. It returns ErrNoRecord for a wrong token.
+func (cs *CalendarService) RoomFeed(ctx context.Context, roomID int, token string) ([]ical.Event, error) {
+	want, err := cs.calendarRepository.GetRoomFeedToken(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(want)) != 1 {
+		return nil, entities.ErrNoRecord
+	}
+
+	now := time.Now()
+	entries, err := cs.calendarRepository.GetRoomCalendarEntries(ctx, roomID, now, now.Add(feedHorizon))
+	if err != nil {
+		return nil, err
+	}
+
+	// Guests and other channels only need to know the room is taken, not why.
+	feed := make([]ical.Event, 0, len(entries))
+	for _, e := range entries {
+		summary := "Not available"
+		if e.Kind == entities.CalendarEntryBooking {
+			summary = "Booked"
+		}
+
+		feed = append(feed, ical.Event{
+			UID:     fmt.Sprintf("%s-%d-room-%d@booking-system", e.Kind, e.ID, roomID),
+			Summary: summary,
+			Start:   e.Start,
+			End:     e.End,
+		})
+	}
+
+	return feed, nil
+}
+
+// RotateFeedToken gives one of the vendor's rooms a new feed token. The old
+// feed URL stops working.
+func (cs *CalendarService) RotateFeedToken(ctx context.Context, roomID, vendorID int) (string, error) {
+	b := make([]byte, 24)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	token := hex.EncodeToString(b)
+
+	err = cs.calendarRepository.SetRoomFeedToken(ctx, roomID, vendorID, token)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// AddCalendar imports an external calendar into one of the vendor's rooms.
+// It is synced right away so the vendor sees whether the URL works.
+func (cs *CalendarService) AddCalendar(ctx context.Context, roomID, vendorID int, url string) (*entities.RoomCalendar, error) {
+	url = strings.TrimSpace(url)
+
+	err := safehttp.ValidateURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", entities.ErrInvalidCalendar, err)
+	}
+
+	calendar := &entities.RoomCalendar{RoomID: roomID, URL: url, CreatedAt: time.Now()}
+
+	calendar.ID, err = cs.calendarRepository.CreateRoomCalendar(ctx, calendar, vendorID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cs.Sync(ctx, calendar)
+	if err != nil {
+		slog.WarnContext(ctx, "first calendar sync failed", "calendar_id", calendar.ID, "error", err)
+	}
+
+	return calendar, nil
+}
+
+func (cs *CalendarService) GetCalendars(ctx context.Context, roomID, vendorID int) ([]*entities.RoomCalendar, error) {
+	return cs.calendarRepository.GetRoomCalendars(ctx, roomID, vendorID)
+}
+
+func (cs *CalendarService) DeleteCalendar(ctx context.Context, calendarID, roomID, vendorID int) error {
+	return cs.calendarRepository.DeleteRoomCalendar(ctx, calendarID, roomID, vendorID)
+}
+
+// SyncAll imports every external calendar. A calendar that fails is logged
+// and recorded, and the others are still synced. It returns how many synced.
+func (cs *CalendarService) SyncAll(ctx context.Context) (int, error) {
+	calendars, err := cs.calendarRepository.GetAllRoomCalendars(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	synced := 0
+	for _, c := range calendars {
+		err = cs.Sync(ctx, c)
+		if err != nil {
+			slog.WarnContext(ctx, "calendar sync failed", "calendar_id", c.ID, "room_id", c.RoomID, "error", err)
+			continue
+		}
+		synced++
+	}
+
+	return synced, nil
+}
+
+// Sync fetches calendar and replaces the room's blocks imported from it with
+// its current events. Events that have already ended are skipped. The
+// outcome is recorded on the calendar.
+func (cs *CalendarService) Sync(ctx context.Context, calendar *entities.RoomCalendar) error {
+	err := cs.sync(ctx, calendar)
+
+	now := time.Now()
+	calendar.LastError = ""
+	if err != nil {
+		calendar.LastError = truncate(err.Error(), 255)
+	} else {
+		calendar.LastSyncedAt = &now
+	}
+
+	recordErr := cs.calendarRepository.RecordCalendarSync(ctx, calendar.ID, calendar.LastError)
+	if recordErr != nil {
+		slog.ErrorContext(ctx, "recording calendar sync failed", "calendar_id", calendar.ID, "error", recordErr)
+	}
+
+	return err
+}
+
+func (cs *CalendarService) sync(ctx context.Context, calendar *entities.RoomCalendar) error {
+	events, err := cs.fetch(ctx, calendar.URL)
+	if err != nil {
+		return err
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	blocks := []entities.RoomBlock{}
+	for _, e := range events {
+		if !e.End.After(today) {
+			continue
+		}
+
+		blocks = append(blocks, entities.RoomBlock{
+			RoomID:      calendar.RoomID,
+			Start:       e.Start,
+			End:         e.End,
+			Reason:      entities.BlockReasonExternalBooking,
+			Note:        truncate(e.Summary, 255),
+			ExternalUID: truncate(e.UID, 255),
+		})
+	}
+
+	return cs.calendarRepository.ReplaceCalendarBlocks(ctx, calendar, blocks)
+}
+
+func (cs *CalendarService) fetch(ctx context.Context, url string) ([]ical.Event, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "text/calendar")
+	req.Header.Set("User-Agent", "booking-system-calendar/1")
+
+	resp, err := cs.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching calendar: HTTP %d", resp.StatusCode)
+	}
+
+	return ical.Parse(io.LimitReader(resp.Body, maxCalendarSize))
+}
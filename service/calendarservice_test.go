@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/repo"
+	"github.com/go-redis/redismock/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCalendarService(t *testing.T) (*CalendarService, sqlmock.Sqlmock, func()) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock db: %v", err)
+	}
+	rdb, _ := redismock.NewClientMock()
+	repository := *repo.NewDBRepository(db, rdb)
+	cfg := entities.CalendarConfig{Timeout: "1s", AllowPrivate: true}
+	return NewCalendarService(repository, cfg), mock, func() { db.Close() }
+}
+
+// icsServer serves a calendar with one past and one upcoming stay.
+func icsServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	next := time.Now().UTC().AddDate(0, 0, 10).Format("20060102")
+	after := time.Now().UTC().AddDate(0, 0, 13).Format("20060102")
+
+	feed := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n" +
+		"BEGIN:VEVENT\r\nUID:past@airbnb.com\r\nDTSTART;VALUE=DATE:20200101\r\nDTEND;VALUE=DATE:20200105\r\nSUMMARY:Reserved\r\nEND:VEVENT\r\n" +
+		fmt.Sprintf("BEGIN:VEVENT\r\nUID:next@airbnb.com\r\nDTSTART;VALUE=DATE:%s\r\nDTEND;VALUE=DATE:%s\r\nSUMMARY:Reserved\r\nEND:VEVENT\r\n", next, after) +
+		"END:VCALENDAR\r\n"
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/calendar.ics" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/calendar")
+		_, _ = w.Write([]byte(feed))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestCalendarService_Sync(t *testing.T) {
+	srv := icsServer(t)
+	next := time.Now().UTC().AddDate(0, 0, 10).Format(entities.DateLayout)
+	after := time.Now().UTC().AddDate(0, 0, 13).Format(entities.DateLayout)
+
+	t.Run("imports upcoming events", func(t *testing.T) {
+		svc, mock, cleanup := newCalendarService(t)
+		defer cleanup()
+
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE FROM room_block WHERE calendar_id = ?").WithArgs(4).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectPrepare("INSERT INTO room_block").ExpectExec().
+			WithArgs(10, next, after, entities.BlockReasonExternalBooking, "Reserved", 4, "next@airbnb.com").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectPrepare("UPDATE room_calendar").ExpectExec().WithArgs("", "", 4).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		calendar := &entities.RoomCalendar{ID: 4, RoomID: 10, URL: srv.URL + "/calendar.ics"}
+		err := svc.Sync(context.Background(), calendar)
+		assert.NoError(t, err)
+		assert.NotNil(t, calendar.LastSyncedAt)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("records fetch errors", func(t *testing.T) {
+		svc, mock, cleanup := newCalendarService(t)
+		defer cleanup()
+
+		mock.ExpectPrepare("UPDATE room_calendar").ExpectExec().
+			WithArgs("fetching calendar: HTTP 404", "fetching calendar: HTTP 404", 5).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		calendar := &entities.RoomCalendar{ID: 5, RoomID: 10, URL: srv.URL + "/missing.ics"}
+		err := svc.Sync(context.Background(), calendar)
+		assert.Error(t, err)
+		assert.Equal(t, "fetching calendar: HTTP 404", calendar.LastError)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestCalendarService_SyncAll(t *testing.T) {
+	srv := icsServer(t)
+	svc, mock, cleanup := newCalendarService(t)
+	defer cleanup()
+
+	now := time.Now()
+	mock.ExpectPrepare("FROM room_calendar c ORDER BY").ExpectQuery().
+		WillReturnRows(sqlmock.NewRows([]string{"calendar_id", "room_id", "url", "last_synced_at", "last_error", "created_at"}).
+			AddRow(5, 10, srv.URL+"/missing.ics", nil, "", now).
+			AddRow(4, 10, srv.URL+"/calendar.ics", nil, "", now))
+	mock.ExpectPrepare("UPDATE room_calendar").ExpectExec().WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 5).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM room_block").WithArgs(4).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectPrepare("INSERT INTO room_block").ExpectExec().WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectPrepare("UPDATE room_calendar").ExpectExec().WithArgs("", "", 4).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	synced, err := svc.SyncAll(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, synced)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCalendarService_RoomFeed(t *testing.T) {
+	t.Run("valid token", func(t *testing.T) {
+		svc, mock, cleanup := newCalendarService(t)
+		defer cleanup()
+
+		start := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+		mock.ExpectPrepare("SELECT ics_token FROM room").ExpectQuery().WithArgs(10).
+			WillReturnRows(sqlmock.NewRows([]string{"ics_token"}).AddRow("secret"))
+		mock.ExpectPrepare("UNION ALL").ExpectQuery().
+			WillReturnRows(sqlmock.NewRows([]string{"kind", "id", "start_date", "end_date", "status", "reason", "note", "calendar_id"}).
+				AddRow("booking", 100, start, start.AddDate(0, 0, 2), entities.BookingStatusConfirmed, "", "", nil))
+
+		feed, err := svc.RoomFeed(context.Background(), 10, "secret")
+		require.NoError(t, err)
+		require.Len(t, feed, 1)
+		assert.Equal(t, "booking-100-room-10@booking-system", feed[0].UID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("wrong token", func(t *testing.T) {
+		svc, mock, cleanup := newCalendarService(t)
+		defer cleanup()
+
+		mock.ExpectPrepare("SELECT ics_token FROM room").ExpectQuery().WithArgs(10).
+			WillReturnRows(sqlmock.NewRows([]string{"ics_token"}).AddRow("secret"))
+
+		_, err := svc.RoomFeed(context.Background(), 10, "guess")
+		assert.ErrorIs(t, err, entities.ErrNoRecord)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestCalendarService_AddCalendar_InvalidURL(t *testing.T) {
+	svc, _, cleanup := newCalendarService(t)
+	defer cleanup()
+
+	_, err := svc.AddCalendar(context.Background(), 10, 7, "file:///etc/passwd")
+	assert.ErrorIs(t, err, entities.ErrInvalidCalendar)
+}
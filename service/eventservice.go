@@ -0,0 +1,15 @@
+package service
+
+import (
+	"context"
+)
+
+// Processed reports whether consumer has already handled eventID.
+func (es *EventService) Processed(ctx context.Context, consumer, eventID string) (bool, error) {
+	return es.eventRepository.IsEventProcessed(ctx, consumer, eventID)
+}
+
+// MarkProcessed records that consumer has handled eventID.
+func (es *EventService) MarkProcessed(ctx context.Context, consumer, eventID, eventType string) error {
+	return es.eventRepository.MarkEventProcessed(ctx, consumer, eventID, eventType)
+}
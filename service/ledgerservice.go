@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/money"
+)
+
+// SplitCommission divides amount (minor units) into the platform commission
+// and the vendor share. The commission is rounded half up so the two parts
+// always add back to amount.
+func SplitCommission(amount int64, bps int) (commission, vendorShare int64) {
+	commission = (amount*int64(bps) + 5_000) / 10_000
+	return commission, amount - commission
+}
+
+// RecordPayment books a successful guest payment: cash comes in, the
+// platform keeps its commission and the rest is owed to the room's vendor.
+func (ls *LedgerService) RecordPayment(ctx context.Context, data *entities.TRXPayload) error {
+	room, err := ls.ledgerRepository.FindRoomByID(ctx, data.RoomID)
+	if err != nil {
+		return err
+	}
+
+	vendorID, err := strconv.Atoi(room.VenderId)
+	if err != nil {
+		return fmt.Errorf("room %d has invalid vendor id %q", data.RoomID, room.VenderId)
+	}
+
+	// The journal is booked in the currency the guest actually paid, which is
+	// the room's currency when the booking was made and may differ from it
+	// now. Payments stored without a currency fall back to the room's.
+	currency := data.Payment.Currency
+	if currency == "" {
+		currency = room.Cost.Currency
+	}
+
+	amount := data.Payment.Amount
+	commission, vendorShare := SplitCommission(amount, ls.commissionBps)
+
+	entries := []entities.LedgerEntry{
+		{Reference: data.TrxID, VendorID: vendorID, Account: entities.AccountCash, Direction: entities.Debit, Amount: amount, Currency: currency, Kind: entities.LedgerKindPayment},
+		{Reference: data.TrxID, VendorID: vendorID, Account: entities.AccountPlatformCommission, Direction: entities.Credit, Amount: commission, Currency: currency, Kind: entities.LedgerKindPayment},
+		{Reference: data.TrxID, VendorID: vendorID, Account: entities.AccountVendorPayable, Direction: entities.Credit, Amount: vendorShare, Currency: currency, Kind: entities.LedgerKindPayment},
+	}
+
+	return ls.post(ctx, entries)
+}
+
+// RecordRefund marks the paid transaction refunded and reverses the journal
+// of its payment in one database transaction, so a refund claws back exactly
+// what was booked regardless of later commission changes. It returns
+// ErrTransactionNotPaid when the transaction was refunded already.
+func (ls *LedgerService) RecordRefund(ctx context.Context, trxID string) error {
+	lines, err := ls.ledgerRepository.GetJournal(ctx, trxID, entities.LedgerKindPayment)
+	if err != nil {
+		return err
+	}
+
+	if len(lines) == 0 {
+		return fmt.Errorf("no payment recorded in ledger for %s", trxID)
+	}
+
+	entries := make([]entities.LedgerEntry, 0, len(lines))
+	for _, line := range lines {
+		direction := entities.Debit
+		if line.Direction == entities.Debit {
+			direction = entities.Credit
+		}
+
+		entries = append(entries, entities.LedgerEntry{
+			Reference: line.Reference,
+			VendorID:  line.VendorID,
+			Account:   line.Account,
+			Direction: direction,
+			Amount:    line.Amount,
+			Currency:  line.Currency,
+			Kind:      entities.LedgerKindRefund,
+		})
+	}
+
+	err = ls.ledgerRepository.RefundTransaction(ctx, trxID, entries)
+	if err != nil {
+		return err
+	}
+
+	recordAudit(ctx, ls.ledgerRepository, entities.AuditTransactionStatus, "transaction", trxID, lines[0].VendorID,
+		map[string]int{"status": entities.TransactionStatusPaid}, map[string]int{"status": entities.TransactionStatusRefunded})
+
+	return nil
+}
+
+func (ls *LedgerService) post(ctx context.Context, entries []entities.LedgerEntry) error {
+	var debits, credits int64
+	for _, e := range entries {
+		if e.Amount < 0 {
+			return errors.New("ledger amounts must not be negative")
+		}
+
+		if e.Direction == entities.Debit {
+			debits += e.Amount
+		} else {
+			credits += e.Amount
+		}
+	}
+
+	if debits != credits {
+		return entities.ErrUnbalancedJournal
+	}
+
+	return ls.ledgerRepository.PostJournal(ctx, entries)
+}
+
+// SchedulePayouts batches every vendor balance at or above the configured
+// minimum into a pending payout, one per settlement currency. It returns the
+// number of payouts created.
+func (ls *LedgerService) SchedulePayouts(ctx context.Context) (int, error) {
+	due, err := ls.ledgerRepository.GetVendorsDueForPayout(ctx, ls.minimumPayout)
+	if err != nil {
+		return 0, err
+	}
+
+	created := 0
+	for _, balance := range due {
+		amount := money.New(balance.Available, balance.Currency)
+		_, err = ls.ledgerRepository.CreatePayout(ctx, balance.VendorID, amount)
+		if errors.Is(err, entities.ErrPayoutExceedsBalance) {
+			// Paid out by another run since the balances were read.
+			slog.WarnContext(ctx, "skipping payout, balance changed", "vendor_id", balance.VendorID, "amount", amount.String())
+			continue
+		}
+		if err != nil {
+			return created, fmt.Errorf("payout of %s for vendor %d failed: %w", amount, balance.VendorID, err)
+		}
+		created++
+	}
+
+	return created, nil
+}
+
+// SettlePayout marks a pending payout paid once the platform has sent the
+// money, and records it in the audit log.
+func (ls *LedgerService) SettlePayout(ctx context.Context, payoutID int) error {
+	vendorID, err := ls.ledgerRepository.SettlePayout(ctx, payoutID)
+	if err != nil {
+		return err
+	}
+
+	recordAudit(ctx, ls.ledgerRepository, entities.AuditPayoutSettle, "payout", strconv.Itoa(payoutID), vendorID,
+		map[string]string{"status": entities.PayoutStatusPending}, map[string]string{"status": entities.PayoutStatusPaid})
+
+	return nil
+}
+
+func (ls *LedgerService) GetVendorBalances(ctx context.Context, vendorID int) ([]*entities.VendorBalance, error) {
+	balances, err := ls.ledgerRepository.GetVendorBalances(ctx, vendorID)
+	if err != nil {
+		return nil, err
+	}
+
+	return balances, nil
+}
+
+func (ls *LedgerService) GetVendorPayouts(ctx context.Context, vendorID int) ([]*entities.Payout, error) {
+	payouts, err := ls.ledgerRepository.GetVendorPayouts(ctx, vendorID)
+	if err != nil {
+		return nil, err
+	}
+
+	return payouts, nil
+}
+
+func (ls *LedgerService) GetVendorStatement(ctx context.Context, vendorID int, from, to time.Time) ([]*entities.LedgerEntry, error) {
+	if !from.Before(to) {
+		return nil, errors.New("statement start must be before its end")
+	}
+
+	entries, err := ls.ledgerRepository.GetVendorStatement(ctx, vendorID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,239 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/repo"
+	"github.com/go-redis/redismock/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func newLedgerService(t *testing.T) (*LedgerService, sqlmock.Sqlmock, func()) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock db: %v", err)
+	}
+	rdb, _ := redismock.NewClientMock()
+	repository := *repo.NewDBRepository(db, rdb)
+	cfg := entities.PayoutConfig{CommissionBps: 1500, Minimum: 500}
+	return NewLedgerService(repository, cfg), mock, func() { db.Close() }
+}
+
+func TestSplitCommission(t *testing.T) {
+	tests := []struct {
+		amount     int64
+		bps        int
+		commission int64
+		vendor     int64
+	}{
+		{amount: 10000, bps: 1500, commission: 1500, vendor: 8500},
+		{amount: 333, bps: 1500, commission: 50, vendor: 283},
+		{amount: 1000, bps: 0, commission: 0, vendor: 1000},
+		{amount: 1, bps: 5000, commission: 1, vendor: 0},
+	}
+
+	for _, tt := range tests {
+		commission, vendor := SplitCommission(tt.amount, tt.bps)
+		assert.Equal(t, tt.commission, commission)
+		assert.Equal(t, tt.vendor, vendor)
+		assert.Equal(t, tt.amount, commission+vendor)
+	}
+}
+
+func TestLedgerService_RecordPayment(t *testing.T) {
+	now := time.Now()
+	trx := &entities.TRXPayload{RoomID: 10, UserID: 5, TrxID: "pi_1", Payment: entities.PaymentBody{Amount: 10000, Currency: "KES"}}
+
+	t.Run("books commission and vendor share", func(t *testing.T) {
+		svc, mock, cleanup := newLedgerService(t)
+		defer cleanup()
+
+		mock.ExpectPrepare("SELECT room_id, cost, currency").
+			ExpectQuery().
+			WithArgs(10).
+			WillReturnRows(sqlmock.NewRows([]string{"room_id", "cost", "currency", "status", "vender_id", "created_at", "updated_at"}).
+				AddRow("10", 10000, "KES", "BOOKED", "7", now, now))
+		mock.ExpectBegin()
+		prep := mock.ExpectPrepare("INSERT INTO ledger_entry")
+		prep.ExpectExec().WithArgs("pi_1", 7, "CASH", "DEBIT", int64(10000), "KES", "PAYMENT").WillReturnResult(sqlmock.NewResult(1, 1))
+		prep.ExpectExec().WithArgs("pi_1", 7, "PLATFORM_COMMISSION", "CREDIT", int64(1500), "KES", "PAYMENT").WillReturnResult(sqlmock.NewResult(2, 1))
+		prep.ExpectExec().WithArgs("pi_1", 7, "VENDOR_PAYABLE", "CREDIT", int64(8500), "KES", "PAYMENT").WillReturnResult(sqlmock.NewResult(3, 1))
+		mock.ExpectCommit()
+
+		err := svc.RecordPayment(context.Background(), trx)
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("zero decimal currency is not scaled", func(t *testing.T) {
+		svc, mock, cleanup := newLedgerService(t)
+		defer cleanup()
+
+		yen := &entities.TRXPayload{RoomID: 10, UserID: 5, TrxID: "pi_2", Payment: entities.PaymentBody{Amount: 7000, Currency: "JPY"}}
+		mock.ExpectPrepare("SELECT room_id, cost, currency").
+			ExpectQuery().
+			WithArgs(10).
+			WillReturnRows(sqlmock.NewRows([]string{"room_id", "cost", "currency", "status", "vender_id", "created_at", "updated_at"}).
+				AddRow("10", 7000, "JPY", "BOOKED", "7", now, now))
+		mock.ExpectBegin()
+		prep := mock.ExpectPrepare("INSERT INTO ledger_entry")
+		prep.ExpectExec().WithArgs("pi_2", 7, "CASH", "DEBIT", int64(7000), "JPY", "PAYMENT").WillReturnResult(sqlmock.NewResult(1, 1))
+		prep.ExpectExec().WithArgs("pi_2", 7, "PLATFORM_COMMISSION", "CREDIT", int64(1050), "JPY", "PAYMENT").WillReturnResult(sqlmock.NewResult(2, 1))
+		prep.ExpectExec().WithArgs("pi_2", 7, "VENDOR_PAYABLE", "CREDIT", int64(5950), "JPY", "PAYMENT").WillReturnResult(sqlmock.NewResult(3, 1))
+		mock.ExpectCommit()
+
+		err := svc.RecordPayment(context.Background(), yen)
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("room lookup fails", func(t *testing.T) {
+		svc, mock, cleanup := newLedgerService(t)
+		defer cleanup()
+
+		mock.ExpectPrepare("SELECT room_id, cost, currency").ExpectQuery().WithArgs(10).WillReturnError(sql.ErrNoRows)
+
+		err := svc.RecordPayment(context.Background(), trx)
+		assert.Error(t, err)
+	})
+}
+
+func TestLedgerService_RecordRefund(t *testing.T) {
+	now := time.Now()
+
+	t.Run("reverses the payment journal", func(t *testing.T) {
+		svc, mock, cleanup := newLedgerService(t)
+		defer cleanup()
+
+		mock.ExpectPrepare("SELECT entry_id").
+			ExpectQuery().
+			WithArgs("pi_1", "PAYMENT").
+			WillReturnRows(sqlmock.NewRows([]string{"entry_id", "reference", "vendor_id", "account", "direction", "amount", "currency", "kind", "created_at"}).
+				AddRow(1, "pi_1", 7, "CASH", "DEBIT", 10000, "KES", "PAYMENT", now).
+				AddRow(2, "pi_1", 7, "PLATFORM_COMMISSION", "CREDIT", 1500, "KES", "PAYMENT", now).
+				AddRow(3, "pi_1", 7, "VENDOR_PAYABLE", "CREDIT", 8500, "KES", "PAYMENT", now))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE transaction SET status").
+			WithArgs(entities.TransactionStatusRefunded, "pi_1", entities.TransactionStatusPaid).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		prep := mock.ExpectPrepare("INSERT INTO ledger_entry")
+		prep.ExpectExec().WithArgs("pi_1", 7, "CASH", "CREDIT", int64(10000), "KES", "REFUND").WillReturnResult(sqlmock.NewResult(4, 1))
+		prep.ExpectExec().WithArgs("pi_1", 7, "PLATFORM_COMMISSION", "DEBIT", int64(1500), "KES", "REFUND").WillReturnResult(sqlmock.NewResult(5, 1))
+		prep.ExpectExec().WithArgs("pi_1", 7, "VENDOR_PAYABLE", "DEBIT", int64(8500), "KES", "REFUND").WillReturnResult(sqlmock.NewResult(6, 1))
+		mock.ExpectCommit()
+		expectAudit(mock, entities.AuditTransactionStatus, "pi_1", 7)
+
+		err := svc.RecordRefund(context.Background(), "pi_1")
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("already refunded", func(t *testing.T) {
+		svc, mock, cleanup := newLedgerService(t)
+		defer cleanup()
+
+		mock.ExpectPrepare("SELECT entry_id").
+			ExpectQuery().
+			WithArgs("pi_1", "PAYMENT").
+			WillReturnRows(sqlmock.NewRows([]string{"entry_id", "reference", "vendor_id", "account", "direction", "amount", "currency", "kind", "created_at"}).
+				AddRow(1, "pi_1", 7, "CASH", "DEBIT", 10000, "KES", "PAYMENT", now).
+				AddRow(3, "pi_1", 7, "VENDOR_PAYABLE", "CREDIT", 10000, "KES", "PAYMENT", now))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE transaction SET status").
+			WithArgs(entities.TransactionStatusRefunded, "pi_1", entities.TransactionStatusPaid).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		err := svc.RecordRefund(context.Background(), "pi_1")
+		assert.ErrorIs(t, err, entities.ErrTransactionNotPaid)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("no payment recorded", func(t *testing.T) {
+		svc, mock, cleanup := newLedgerService(t)
+		defer cleanup()
+
+		mock.ExpectPrepare("SELECT entry_id").
+			ExpectQuery().
+			WithArgs("pi_1", "PAYMENT").
+			WillReturnRows(sqlmock.NewRows([]string{"entry_id", "reference", "vendor_id", "account", "direction", "amount", "currency", "kind", "created_at"}))
+
+		err := svc.RecordRefund(context.Background(), "pi_1")
+		assert.EqualError(t, err, "no payment recorded in ledger for pi_1")
+	})
+}
+
+func TestLedgerService_RejectsUnbalancedJournal(t *testing.T) {
+	svc, mock, cleanup := newLedgerService(t)
+	defer cleanup()
+
+	err := svc.post(context.Background(), []entities.LedgerEntry{
+		{Account: entities.AccountCash, Direction: entities.Debit, Amount: 100},
+		{Account: entities.AccountVendorPayable, Direction: entities.Credit, Amount: 99},
+	})
+	assert.ErrorIs(t, err, entities.ErrUnbalancedJournal)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLedgerService_SchedulePayouts(t *testing.T) {
+	svc, mock, cleanup := newLedgerService(t)
+	defer cleanup()
+
+	mock.ExpectPrepare("SELECT vendor_id").
+		ExpectQuery().
+		WithArgs(int64(500)).
+		WillReturnRows(sqlmock.NewRows([]string{"vendor_id", "currency", "available"}).
+			AddRow(7, "KES", 8500).
+			AddRow(8, "KES", 9000))
+	mock.ExpectBegin()
+	mock.ExpectQuery("FOR UPDATE").WithArgs(7, "KES").WillReturnRows(sqlmock.NewRows([]string{"available"}).AddRow(8500))
+	mock.ExpectExec("INSERT INTO payout").WithArgs(7, int64(8500), "KES").WillReturnResult(sqlmock.NewResult(1, 1))
+	prep := mock.ExpectPrepare("INSERT INTO ledger_entry")
+	prep.ExpectExec().WillReturnResult(sqlmock.NewResult(1, 1))
+	prep.ExpectExec().WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+	// Vendor 8 was paid out by another run in the meantime.
+	mock.ExpectBegin()
+	mock.ExpectQuery("FOR UPDATE").WithArgs(8, "KES").WillReturnRows(sqlmock.NewRows([]string{"available"}).AddRow(0))
+	mock.ExpectRollback()
+
+	created, err := svc.SchedulePayouts(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, created)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLedgerService_SettlePayout(t *testing.T) {
+	svc, mock, cleanup := newLedgerService(t)
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT vendor_id, amount, currency FROM payout").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"vendor_id", "amount", "currency"}).AddRow(7, 8500, "KES"))
+	mock.ExpectExec("UPDATE payout SET status = 'PAID'").WithArgs(3).WillReturnResult(sqlmock.NewResult(0, 1))
+	prep := mock.ExpectPrepare("INSERT INTO ledger_entry")
+	prep.ExpectExec().WillReturnResult(sqlmock.NewResult(1, 1))
+	prep.ExpectExec().WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+	expectAudit(mock, entities.AuditPayoutSettle, "3", 7)
+
+	err := svc.SettlePayout(context.Background(), 3)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLedgerService_GetVendorStatement(t *testing.T) {
+	svc, _, cleanup := newLedgerService(t)
+	defer cleanup()
+
+	day := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, err := svc.GetVendorStatement(context.Background(), 7, day, day)
+	assert.EqualError(t, err, "statement start must be before its end")
+}
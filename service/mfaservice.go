@@ -0,0 +1,253 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/jwtkeys"
+	"github.com/bicosteve/booking-system/pkg/totp"
+	"github.com/bicosteve/booking-system/pkg/utils"
+)
+
+const (
+	mfaIssuer         = "Booking System"
+	recoveryCodeCount = 10
+)
+
+// EnrollMFA starts TOTP enrolment with a new secret. It is not used for
+// logins until ConfirmMFA gets a valid code from it.
+func (s *UserService) EnrollMFA(ctx context.Context, userID int, email string) (*entities.MFAEnrolment, error) {
+	mfa, err := s.userRepository.GetUserMFA(ctx, userID)
+	if err != nil && !errors.Is(err, entities.ErrNoRecord) {
+		return nil, err
+	}
+
+	if mfa != nil && mfa.EnabledAt != nil {
+		return nil, entities.ErrMFAEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.userRepository.SaveMFASecret(ctx, userID, secret)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entities.MFAEnrolment{Secret: secret, URI: totp.ProvisioningURI(mfaIssuer, email, secret)}, nil
+}
+
+// ConfirmMFA enables the enrolled secret once code matches it. It returns
+// the recovery codes, shown to the user only this once, and a new auth
+// token that passes the admin router.
+func (s *UserService) ConfirmMFA(ctx context.Context, user entities.User, code string, keys *jwtkeys.Keyring) ([]string, string, error) {
+	userID, err := strconv.Atoi(user.ID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	mfa, err := s.userRepository.GetUserMFA(ctx, userID)
+	if errors.Is(err, entities.ErrNoRecord) {
+		return nil, "", entities.ErrMFANotEnrolled
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	if mfa.EnabledAt != nil {
+		return nil, "", entities.ErrMFAEnabled
+	}
+
+	step, ok := totp.Validate(mfa.Secret, code, time.Now())
+	if !ok {
+		return nil, "", entities.ErrInvalidMFACode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = s.userRepository.EnableMFA(ctx, userID, step, hashes)
+	if err != nil {
+		return nil, "", err
+	}
+
+	token, err := utils.GenerateMFAAuthToken(user, keys)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return codes, token, nil
+}
+
+// CompleteMFALogin trades the pending token from SubmitLoginRequest and a
+// TOTP or recovery code for an auth token. Wrong codes count as failed
+// logins towards the account lockout.
+func (s *UserService) CompleteMFALogin(ctx context.Context, data entities.MFAPayload, keys *jwtkeys.Keyring) (string, error) {
+	claims, err := utils.VerifyMFAPendingToken(data.MFAToken, keys)
+	if err != nil {
+		return "", entities.ErrMFARequired
+	}
+
+	wait, err := s.userRepository.GetLoginLock(ctx, claims.Username)
+	if err != nil {
+		slog.WarnContext(ctx, "checking login lock failed", "error", err)
+	}
+
+	if wait > 0 {
+		return "", &entities.LockoutError{RetryAfter: wait}
+	}
+
+	userID, err := strconv.Atoi(claims.UserID)
+	if err != nil {
+		return "", err
+	}
+
+	// A password change or account deletion since the password step ends
+	// the pending login too, as it does issued auth tokens.
+	validFrom, err := s.userRepository.SessionsValidFrom(ctx, userID)
+	if errors.Is(err, entities.ErrNoRecord) {
+		return "", entities.ErrSessionRevoked
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if claims.IssuedAt == nil || claims.IssuedAt.Before(validFrom) {
+		return "", entities.ErrSessionRevoked
+	}
+
+	ok, err := s.checkSecondFactor(ctx, userID, data)
+	if err != nil {
+		return "", err
+	}
+
+	if !ok {
+		lockErr := s.loginFailed(ctx, claims.Username)
+		if lockErr != nil {
+			return "", lockErr
+		}
+		return "", entities.ErrInvalidMFACode
+	}
+
+	err = s.userRepository.ClearLoginFailures(ctx, claims.Username)
+	if err != nil {
+		slog.WarnContext(ctx, "clearing failed logins failed", "error", err)
+	}
+
+	user := entities.User{
+		ID:          claims.UserID,
+		Email:       claims.Username,
+		IsVender:    claims.IsVendor,
+		PhoneNumber: claims.PhoneNumber,
+	}
+
+	return utils.GenerateMFAAuthToken(user, keys)
+}
+
+// DisableMFA turns two-factor off after checking a code. Vendors need it for
+// the admin router, so they cannot turn it off.
+func (s *UserService) DisableMFA(ctx context.Context, userID int, isVendor string, data entities.MFAPayload) error {
+	if isVendor == "YES" {
+		return entities.ErrMFARequired
+	}
+
+	ok, err := s.checkSecondFactor(ctx, userID, data)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return entities.ErrInvalidMFACode
+	}
+
+	return s.userRepository.DeleteMFA(ctx, userID)
+}
+
+// enabledMFA returns the user's second factor if it is on, or nil.
+func (s *UserService) enabledMFA(ctx context.Context, userID string) (*entities.UserMFA, error) {
+	id, err := strconv.Atoi(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	mfa, err := s.userRepository.GetUserMFA(ctx, id)
+	if errors.Is(err, entities.ErrNoRecord) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if mfa.EnabledAt == nil {
+		return nil, nil
+	}
+
+	return mfa, nil
+}
+
+// checkSecondFactor reports whether data holds a valid, unused TOTP code or
+// recovery code. Either is spent by a successful check.
+func (s *UserService) checkSecondFactor(ctx context.Context, userID int, data entities.MFAPayload) (bool, error) {
+	mfa, err := s.enabledMFA(ctx, strconv.Itoa(userID))
+	if err != nil {
+		return false, err
+	}
+
+	if mfa == nil {
+		return false, entities.ErrMFANotEnrolled
+	}
+
+	if data.RecoveryCode != "" {
+		return s.userRepository.UseRecoveryCode(ctx, userID, hashRecoveryCode(data.RecoveryCode))
+	}
+
+	step, ok := totp.Validate(mfa.Secret, data.Code, time.Now())
+	if !ok {
+		return false, nil
+	}
+
+	return s.userRepository.UseMFAStep(ctx, userID, step)
+}
+
+// newRecoveryCodes returns fresh recovery codes, formatted "xxxxx-xxxxx",
+// and their hashes for storage.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+
+	for i := range codes {
+		b := make([]byte, 7)
+		_, err := rand.Read(b)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		raw := strings.ToLower(base32.StdEncoding.EncodeToString(b))[:10]
+		codes[i] = raw[:5] + "-" + raw[5:]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode hashes a recovery code ignoring case, spaces and dashes.
+// The codes are random enough that a fast hash does not make guessing them
+// practical.
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,219 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/totp"
+	"github.com/bicosteve/booking-system/pkg/utils"
+	"github.com/bicosteve/booking-system/repo"
+	"github.com/go-redis/redismock/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+const testMFASecret = "JBSWY3DPEHPK3PXP"
+
+var userMFAColumns = []string{"user_id", "secret", "enabled_at", "last_step"}
+
+func TestEnrollMFA(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectPrepare("FROM user_mfa").ExpectQuery().WithArgs(5).
+		WillReturnRows(sqlmock.NewRows(userMFAColumns))
+	mock.ExpectPrepare("INSERT INTO user_mfa").ExpectExec().WithArgs(5, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectPrepare("FROM user_mfa").ExpectQuery().WithArgs(6).
+		WillReturnRows(sqlmock.NewRows(userMFAColumns).AddRow(6, testMFASecret, time.Now(), 0))
+
+	_db, _ := redismock.NewClientMock()
+	service := NewUserService(*repo.NewDBRepository(db, _db), entities.LockoutConfig{})
+
+	enrolment, err := service.EnrollMFA(context.Background(), 5, "vendor@gmail.com")
+	assert.NoError(t, err)
+	assert.Len(t, enrolment.Secret, 32)
+	assert.Contains(t, enrolment.URI, "secret="+enrolment.Secret)
+
+	_, err = service.EnrollMFA(context.Background(), 6, "other@gmail.com")
+	assert.ErrorIs(t, err, entities.ErrMFAEnabled)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestConfirmMFA(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	now := time.Now()
+	code, _ := totp.Code(testMFASecret, totp.Step(now))
+
+	mock.ExpectPrepare("FROM user_mfa").ExpectQuery().WithArgs(5).
+		WillReturnRows(sqlmock.NewRows(userMFAColumns).AddRow(5, testMFASecret, nil, 0))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE user_mfa SET enabled_at").WithArgs(sqlmock.AnyArg(), 5).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM user_recovery_code").WithArgs(5).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectPrepare("INSERT INTO user_recovery_code")
+	for i := 0; i < recoveryCodeCount; i++ {
+		mock.ExpectExec("INSERT INTO user_recovery_code").WithArgs(5, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+	mock.ExpectCommit()
+
+	_db, _ := redismock.NewClientMock()
+	service := NewUserService(*repo.NewDBRepository(db, _db), entities.LockoutConfig{})
+
+	user := entities.User{ID: "5", Email: "vendor@gmail.com", IsVender: "YES"}
+	codes, token, err := service.ConfirmMFA(context.Background(), user, code, testKeys)
+	assert.NoError(t, err)
+	assert.Len(t, codes, recoveryCodeCount)
+	assert.Regexp(t, `^[a-z2-7]{5}-[a-z2-7]{5}$`, codes[0])
+	assert.NotEmpty(t, token)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestConfirmMFA_InvalidCode(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectPrepare("FROM user_mfa").ExpectQuery().WithArgs(5).
+		WillReturnRows(sqlmock.NewRows(userMFAColumns).AddRow(5, testMFASecret, nil, 0))
+
+	_db, _ := redismock.NewClientMock()
+	service := NewUserService(*repo.NewDBRepository(db, _db), entities.LockoutConfig{})
+
+	_, _, err = service.ConfirmMFA(context.Background(), entities.User{ID: "5"}, "000000x", testKeys)
+	assert.ErrorIs(t, err, entities.ErrInvalidMFACode)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSubmitLoginRequest_MFAPending(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	now := time.Now()
+	mock.ExpectPrepare("SELECT COUNT.* FROM user").ExpectQuery().WithArgs("test@gmail.com").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectPrepare("SELECT user_id, email, phone_number").ExpectQuery().WithArgs("test@gmail.com").
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "email", "phone_number", "isVender",
+			"password", "password_reset_token",
+			"created_at", "updated_at", "password_inserted_at",
+			"name", "phone_verified_at",
+		}).AddRow(
+			"1", "test@gmail.com", "0704961755", "YES",
+			"$2a$10$/r5qIMP1AkNOMdr495Ff0eCdrZWyW79Q5E3RxFVgCbk0ret4j4mDa", "",
+			now, now, now, "", nil,
+		))
+	mock.ExpectPrepare("FROM user_mfa").ExpectQuery().WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(userMFAColumns).AddRow(1, testMFASecret, now, 0))
+
+	client, redisMock := redismock.NewClientMock()
+	redisMock.ExpectPTTL("login:lock:test@gmail.com").SetVal(-2)
+
+	service := NewUserService(*repo.NewDBRepository(db, client), entities.LockoutConfig{})
+
+	token, pending, err := service.SubmitLoginRequest(context.Background(), entities.UserPayload{Email: "test@gmail.com", Password: "1234"}, testKeys)
+	assert.NoError(t, err)
+	assert.True(t, pending)
+
+	claims, err := utils.VerifyMFAPendingToken(token, testKeys)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", claims.UserID)
+
+	// Failed logins are not cleared until the second factor is passed.
+	assert.NoError(t, redisMock.ExpectationsWereMet())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCompleteMFALogin(t *testing.T) {
+	user := entities.User{ID: "1", Email: "test@gmail.com", IsVender: "YES"}
+	pending, _ := utils.GenerateMFAPendingToken(user, testKeys)
+	now := time.Now()
+
+	t.Run("recovery code", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectQuery("SELECT password_inserted_at FROM user").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"password_inserted_at"}).AddRow(now.Add(-time.Hour)))
+		mock.ExpectPrepare("FROM user_mfa").ExpectQuery().WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(userMFAColumns).AddRow(1, testMFASecret, now, 0))
+		mock.ExpectPrepare("UPDATE user_recovery_code").ExpectExec().WithArgs(1, hashRecoveryCode("abcde-fghij")).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		client, redisMock := redismock.NewClientMock()
+		redisMock.ExpectPTTL("login:lock:test@gmail.com").SetVal(-2)
+		redisMock.ExpectDel("login:failures:test@gmail.com", "login:lock:test@gmail.com").SetVal(0)
+
+		service := NewUserService(*repo.NewDBRepository(db, client), entities.LockoutConfig{})
+
+		token, err := service.CompleteMFALogin(context.Background(), entities.MFAPayload{MFAToken: pending, RecoveryCode: "ABCDE FGHIJ"}, testKeys)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, token)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.NoError(t, redisMock.ExpectationsWereMet())
+	})
+
+	t.Run("wrong code counts as a failed login", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectQuery("SELECT password_inserted_at FROM user").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"password_inserted_at"}).AddRow(now.Add(-time.Hour)))
+		mock.ExpectPrepare("FROM user_mfa").ExpectQuery().WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(userMFAColumns).AddRow(1, testMFASecret, now, 0))
+
+		client, redisMock := redismock.NewClientMock()
+		redisMock.ExpectPTTL("login:lock:test@gmail.com").SetVal(-2)
+		redisMock.ExpectIncr("login:failures:test@gmail.com").SetVal(1)
+		redisMock.ExpectExpire("login:failures:test@gmail.com", 24*time.Hour).SetVal(true)
+
+		service := NewUserService(*repo.NewDBRepository(db, client), entities.LockoutConfig{})
+
+		_, err = service.CompleteMFALogin(context.Background(), entities.MFAPayload{MFAToken: pending, Code: "12345x"}, testKeys)
+		assert.ErrorIs(t, err, entities.ErrInvalidMFACode)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.NoError(t, redisMock.ExpectationsWereMet())
+	})
+
+	t.Run("password changed since the password step", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectQuery("SELECT password_inserted_at FROM user").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"password_inserted_at"}).AddRow(now.Add(time.Minute)))
+
+		client, redisMock := redismock.NewClientMock()
+		redisMock.ExpectPTTL("login:lock:test@gmail.com").SetVal(-2)
+
+		service := NewUserService(*repo.NewDBRepository(db, client), entities.LockoutConfig{})
+
+		_, err = service.CompleteMFALogin(context.Background(), entities.MFAPayload{MFAToken: pending, RecoveryCode: "ABCDE FGHIJ"}, testKeys)
+		assert.ErrorIs(t, err, entities.ErrSessionRevoked)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.NoError(t, redisMock.ExpectationsWereMet())
+	})
+
+	t.Run("auth token is not a pending token", func(t *testing.T) {
+		full, _ := utils.GenerateAuthToken(user, testKeys)
+		service := NewUserService(*repo.NewDBRepository(nil, nil), entities.LockoutConfig{})
+
+		_, err := service.CompleteMFALogin(context.Background(), entities.MFAPayload{MFAToken: full, Code: "123456"}, testKeys)
+		assert.ErrorIs(t, err, entities.ErrMFARequired)
+	})
+}
+
+func TestDisableMFA_Vendor(t *testing.T) {
+	service := NewUserService(*repo.NewDBRepository(nil, nil), entities.LockoutConfig{})
+
+	err := service.DisableMFA(context.Background(), 1, "YES", entities.MFAPayload{Code: "123456"})
+	assert.ErrorIs(t, err, entities.ErrMFARequired)
+}
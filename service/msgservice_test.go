@@ -77,7 +77,7 @@ func TestSubmitMessage(t *testing.T) {
 
 			// Create a new repository and service
 			repository := *repo.NewDBRepository(db, _db)
-			service := NewUserService(repository)
+			service := NewUserService(repository, entities.LockoutConfig{})
 
 			// Execute the function
 			err = service.SubmitMessage(context.Background(), tt.message)
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/oidc"
+	"github.com/bicosteve/booking-system/pkg/oidc/oidctest"
+	"github.com/bicosteve/booking-system/repo"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+var profileColumns = []string{
+	"id", "email", "phone_number", "isVender",
+	"password", "password_reset_token",
+	"created_at", "updated_at", "password_inserted_at",
+	"name", "phone_verified_at",
+}
+
+func newTestOIDCService(t *testing.T) (*OIDCService, sqlmock.Sqlmock, *oidctest.Issuer) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	iss := oidctest.NewIssuer(t, "client-1")
+	repository := *repo.NewDBRepository(db, client)
+	users := NewUserService(repository, entities.LockoutConfig{})
+
+	s := NewOIDCService(repository, users, []entities.OIDCConfig{
+		{Name: "test", Issuer: iss.URL, ClientID: "client-1", ClientSecret: "secret", RedirectURL: "http://localhost/callback"},
+	})
+
+	return s, mock, iss
+}
+
+func TestOIDCLogin_LinksByVerifiedEmail(t *testing.T) {
+	s, mock, iss := newTestOIDCService(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	authURL, err := s.StartLogin(ctx, "test")
+	assert.NoError(t, err)
+
+	code, state := iss.Authorize(t, authURL, oidctest.User{Subject: "sub-1", Email: "guest@gmail.com", EmailVerified: true})
+
+	mock.ExpectPrepare("FROM user_identity").ExpectQuery().WithArgs("test", "sub-1").
+		WillReturnRows(sqlmock.NewRows([]string{"email"}))
+	mock.ExpectPrepare("SELECT COUNT.* FROM user").ExpectQuery().WithArgs("guest@gmail.com").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectPrepare("SELECT user_id, email, phone_number").ExpectQuery().WithArgs("guest@gmail.com").
+		WillReturnRows(sqlmock.NewRows(profileColumns).AddRow("5", "guest@gmail.com", "0704961755", "NO", "hash", "", now, now, now, "", nil))
+	mock.ExpectPrepare("INSERT INTO user_identity").ExpectExec().WithArgs(5, "test", "sub-1", "guest@gmail.com").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectPrepare("FROM user_mfa").ExpectQuery().WithArgs(5).
+		WillReturnRows(sqlmock.NewRows(userMFAColumns))
+
+	token, pending, err := s.FinishLogin(ctx, "test", state, code, testKeys)
+	assert.NoError(t, err)
+	assert.False(t, pending)
+	assert.NotEmpty(t, token)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// The state is used up.
+	_, _, err = s.FinishLogin(ctx, "test", state, code, testKeys)
+	assert.ErrorIs(t, err, entities.ErrOIDCState)
+}
+
+func TestOIDCLogin_LinkedIdentityWithMFA(t *testing.T) {
+	s, mock, iss := newTestOIDCService(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	authURL, err := s.StartLogin(ctx, "test")
+	assert.NoError(t, err)
+
+	// The provider email changed since linking; the link still holds.
+	code, state := iss.Authorize(t, authURL, oidctest.User{Subject: "sub-1", Email: "new@gmail.com"})
+
+	mock.ExpectPrepare("FROM user_identity").ExpectQuery().WithArgs("test", "sub-1").
+		WillReturnRows(sqlmock.NewRows([]string{"email"}).AddRow("guest@gmail.com"))
+	mock.ExpectPrepare("SELECT user_id, email, phone_number").ExpectQuery().WithArgs("guest@gmail.com").
+		WillReturnRows(sqlmock.NewRows(profileColumns).AddRow("5", "guest@gmail.com", "0704961755", "YES", "hash", "", now, now, now, "", nil))
+	mock.ExpectPrepare("FROM user_mfa").ExpectQuery().WithArgs(5).
+		WillReturnRows(sqlmock.NewRows(userMFAColumns).AddRow(5, testMFASecret, now, 0))
+
+	token, pending, err := s.FinishLogin(ctx, "test", state, code, testKeys)
+	assert.NoError(t, err)
+	assert.True(t, pending)
+	assert.NotEmpty(t, token)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOIDCLogin_Refused(t *testing.T) {
+	tests := []struct {
+		name    string
+		user    oidctest.User
+		setup   func(sqlmock.Sqlmock)
+		wantErr error
+	}{
+		{
+			name: "unverified email",
+			user: oidctest.User{Subject: "sub-1", Email: "guest@gmail.com"},
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectPrepare("FROM user_identity").ExpectQuery().WithArgs("test", "sub-1").
+					WillReturnRows(sqlmock.NewRows([]string{"email"}))
+			},
+			wantErr: entities.ErrEmailNotVerified,
+		},
+		{
+			name: "no account",
+			user: oidctest.User{Subject: "sub-1", Email: "guest@gmail.com", EmailVerified: true},
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectPrepare("FROM user_identity").ExpectQuery().WithArgs("test", "sub-1").
+					WillReturnRows(sqlmock.NewRows([]string{"email"}))
+				mock.ExpectPrepare("SELECT COUNT.* FROM user").ExpectQuery().WithArgs("guest@gmail.com").
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+			},
+			wantErr: entities.ErrNoAccount,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock, iss := newTestOIDCService(t)
+			ctx := context.Background()
+
+			authURL, err := s.StartLogin(ctx, "test")
+			assert.NoError(t, err)
+
+			code, state := iss.Authorize(t, authURL, tt.user)
+			tt.setup(mock)
+
+			_, _, err = s.FinishLogin(ctx, "test", state, code, testKeys)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestOIDCLogin_BadRequests(t *testing.T) {
+	s, _, iss := newTestOIDCService(t)
+	ctx := context.Background()
+
+	_, err := s.StartLogin(ctx, "facebook")
+	assert.ErrorIs(t, err, oidc.ErrUnknownProvider)
+
+	_, _, err = s.FinishLogin(ctx, "test", "made-up", "code", testKeys)
+	assert.ErrorIs(t, err, entities.ErrOIDCState)
+
+	authURL, err := s.StartLogin(ctx, "test")
+	assert.NoError(t, err)
+	_, state := iss.Authorize(t, authURL, oidctest.User{Subject: "sub-1"})
+
+	_, _, err = s.FinishLogin(ctx, "test", state, "wrong-code", testKeys)
+	assert.ErrorIs(t, err, entities.ErrOIDCLogin)
+}
@@ -2,6 +2,8 @@ package service
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"time"
 
 	"github.com/bicosteve/booking-system/entities"
@@ -21,7 +23,8 @@ func (ps PaymentService) HoldPayment(ctx context.Context, pi *stripe.PaymentInte
 		OrderID:       data.OrderID,
 		UserID:        data.UserID,
 		PaymentId:     pi.ID,
-		Amount:        float64(data.Payment.Amount),
+		Amount:        data.Payment.Amount,
+		Currency:      data.Payment.Currency,
 		ClientSecret:  pi.ClientSecret,
 		TransactionID: pi.ID,
 		CustomerId:    data.UserID,
@@ -49,6 +52,7 @@ func (ps PaymentService) RemovePayment(ctx context.Context, userId string) error
 	return nil
 }
 
+// AddPayment stores a transaction and records it in the audit log.
 func (ps PaymentService) AddPayment(ctx context.Context, data *entities.TRXPayload) error {
 
 	err := ps.paymentRepository.SaveTransactions(ctx, data)
@@ -56,13 +60,40 @@ func (ps PaymentService) AddPayment(ctx context.Context, data *entities.TRXPaylo
 		return err
 	}
 
+	recordAudit(ctx, ps.paymentRepository, entities.AuditTransactionCreate, "transaction", data.TrxID,
+		roomVendor(ctx, ps.paymentRepository, data.RoomID), nil, data)
+
 	return nil
 }
 
+// UpdatePayment sets a transaction's status and records the change in the
+// audit log.
 func (ps PaymentService) UpdatePayment(ctx context.Context, status int, trx_id string) error {
-	err := ps.paymentRepository.UpdateTransactions(ctx, status, trx_id)
+	before, err := ps.paymentRepository.FindTransaction(ctx, trx_id)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	err = ps.paymentRepository.UpdateTransactions(ctx, status, trx_id)
 	if err != nil {
 		return err
 	}
+
+	if before != nil {
+		after := *before
+		after.Status = status
+		recordAudit(ctx, ps.paymentRepository, entities.AuditTransactionStatus, "transaction", trx_id,
+			roomVendor(ctx, ps.paymentRepository, before.RoomID),
+			map[string]int{"status": before.Status}, map[string]int{"status": after.Status})
+	}
+
 	return nil
 }
+
+func (ps PaymentService) GetTransaction(ctx context.Context, trxID string) (*entities.Transaction, error) {
+	trx, err := ps.paymentRepository.FindTransaction(ctx, trxID)
+	if err != nil {
+		return nil, err
+	}
+	return trx, nil
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/bicosteve/booking-system/entities"
@@ -64,15 +66,15 @@ func TestPaymentService_HoldPayment(t *testing.T) {
 		OrderID: "order-1",
 		UserID:  5,
 		RoomID:  10,
-		Payment: entities.PaymentBody{Amount: 100},
+		Payment: entities.PaymentBody{Amount: 10000, Currency: "KES"},
 	}
 
 	// HoldPayment builds a map that includes time.Now() values. We match on the
 	// command name + key via a custom matcher and ignore the field values, but
 	// the expected arg list length must still equal the actual one, so we pass a
-	// map with the same 14 keys HoldPayment writes.
+	// map with the same 15 keys HoldPayment writes.
 	expectedFields := map[string]any{
-		"OrderId": "", "UserId": "", "Amount": "", "Status": "",
+		"OrderId": "", "UserId": "", "Amount": "", "Currency": "", "Status": "",
 		"PaymentUrl": "", "PaymentId": "", "ClientSecret": "", "TransactionId": "",
 		"CustomerId": "", "RoomID": "", "Response": "", "CapturedMethod": "",
 		"CreatedAt": "", "UpdatedAt": "",
@@ -138,7 +140,7 @@ func TestPaymentService_AddPayment(t *testing.T) {
 		TrxID:     "trx-1",
 		Reference: "ref-1",
 		Status:    1,
-		Payment:   entities.PaymentBody{Amount: 200},
+		Payment:   entities.PaymentBody{Amount: 200, Currency: "KES"},
 	}
 
 	t.Run("success", func(t *testing.T) {
@@ -147,8 +149,14 @@ func TestPaymentService_AddPayment(t *testing.T) {
 
 		dbMock.ExpectPrepare("INSERT INTO transaction").
 			ExpectExec().
-			WithArgs(10, 5, "order-1", "trx-1", "ref-1", int64(200), 1).
+			WithArgs(10, 5, "order-1", "trx-1", "ref-1", int64(200), "KES", 1).
 			WillReturnResult(sqlmock.NewResult(1, 1))
+		dbMock.ExpectPrepare("FROM room WHERE room_id").
+			ExpectQuery().
+			WithArgs(10).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "cost", "currency", "status", "vender_id", "created_at", "updated_at"}).
+				AddRow("10", 100, "KES", "BOOKED", "7", time.Now(), time.Now()))
+		expectAudit(dbMock, entities.AuditTransactionCreate, "trx-1", 7)
 
 		err := svc.AddPayment(context.Background(), data)
 		assert.NoError(t, err)
@@ -171,12 +179,27 @@ func TestPaymentService_UpdatePayment(t *testing.T) {
 		svc, dbMock, _, cleanup := newPaymentService(t)
 		defer cleanup()
 
+		dbMock.ExpectPrepare("FROM transaction WHERE trx_id").
+			ExpectQuery().
+			WithArgs("trx-1").
+			WillReturnRows(sqlmock.NewRows([]string{"transaction_id", "user_id", "room_id", "order_id", "trx_id", "reference", "amount", "currency", "status", "created_at", "updated_at"}).
+				AddRow(1, 5, 10, "order-1", "trx-1", "ref-1", 200, "KES", entities.TransactionStatusPaid, time.Now(), time.Now()))
 		dbMock.ExpectPrepare("UPDATE transaction SET status").
 			ExpectExec().
-			WithArgs(1, "trx-1").
+			WithArgs(entities.TransactionStatusRefunded, "trx-1").
 			WillReturnResult(sqlmock.NewResult(0, 1))
+		dbMock.ExpectPrepare("FROM room WHERE room_id").
+			ExpectQuery().
+			WithArgs(10).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "cost", "currency", "status", "vender_id", "created_at", "updated_at"}).
+				AddRow("10", 100, "KES", "BOOKED", "7", time.Now(), time.Now()))
+		dbMock.ExpectPrepare("INSERT INTO audit_log").
+			ExpectExec().
+			WithArgs(entities.ActorSystem, nil, nil, 7, entities.AuditTransactionStatus, "transaction", "trx-1",
+				fmt.Sprintf(`{"status":%d}`, entities.TransactionStatusPaid), fmt.Sprintf(`{"status":%d}`, entities.TransactionStatusRefunded), "", "").
+			WillReturnResult(sqlmock.NewResult(1, 1))
 
-		err := svc.UpdatePayment(context.Background(), 1, "trx-1")
+		err := svc.UpdatePayment(context.Background(), entities.TransactionStatusRefunded, "trx-1")
 		assert.NoError(t, err)
 		assert.NoError(t, dbMock.ExpectationsWereMet())
 	})
@@ -185,7 +208,7 @@ func TestPaymentService_UpdatePayment(t *testing.T) {
 		svc, dbMock, _, cleanup := newPaymentService(t)
 		defer cleanup()
 
-		dbMock.ExpectPrepare("UPDATE transaction SET status").WillReturnError(sql.ErrConnDone)
+		dbMock.ExpectPrepare("FROM transaction WHERE trx_id").WillReturnError(sql.ErrConnDone)
 
 		err := svc.UpdatePayment(context.Background(), 1, "trx-1")
 		assert.Error(t, err)
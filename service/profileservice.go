@@ -0,0 +1,422 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/jwtkeys"
+	"github.com/bicosteve/booking-system/pkg/phone"
+	"github.com/bicosteve/booking-system/pkg/utils"
+)
+
+const (
+	pendingEmail = "email"
+	pendingPhone = "phone"
+
+	emailChangeTTL     = 24 * time.Hour
+	phoneChangeTTL     = 10 * time.Minute
+	maxConfirmAttempts = 5
+	maxNameLength      = 100
+)
+
+// GetProfile returns ErrNoRecord for deleted accounts.
+func (s *UserService) GetProfile(ctx context.Context, userID int) (*entities.User, error) {
+	return s.userRepository.FindProfileByID(ctx, userID)
+}
+
+// UpdateProfile saves a new name right away. A new phone number waits for
+// confirmation: code is to be sent to it and passed to ConfirmPhoneChange,
+// and is empty when the number did not change.
+func (s *UserService) UpdateProfile(ctx context.Context, userID int, data entities.ProfilePayload) (user *entities.User, code string, err error) {
+	user, err = s.userRepository.FindProfileByID(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if data.Name != nil {
+		name := strings.TrimSpace(*data.Name)
+		if utf8.RuneCountInString(name) > maxNameLength {
+			return nil, "", fmt.Errorf("%w: name is longer than %d characters", entities.ErrInvalidProfile, maxNameLength)
+		}
+
+		if name != user.Name {
+			err = s.userRepository.UpdateUserName(ctx, userID, name)
+			if err != nil {
+				return nil, "", err
+			}
+			user.Name = name
+		}
+	}
+
+	if data.PhoneNumber == nil || strings.TrimSpace(*data.PhoneNumber) == user.PhoneNumber {
+		return user, "", nil
+	}
+
+	number := strings.TrimSpace(*data.PhoneNumber)
+	if !phone.IsE164(number) {
+		return nil, "", fmt.Errorf("%w: phone number must be in international form, e.g. +254712345678", entities.ErrInvalidProfile)
+	}
+
+	code, err = s.startPhoneChange(ctx, userID, number)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return user, code, nil
+}
+
+// RequestPhoneVerification returns a code to be texted to the account's
+// current phone number and passed to ConfirmPhoneChange, for numbers that
+// were never confirmed, such as those given at registration.
+func (s *UserService) RequestPhoneVerification(ctx context.Context, userID int) (number, code string, err error) {
+	user, err := s.userRepository.FindProfileByID(ctx, userID)
+	if err != nil {
+		return "", "", err
+	}
+
+	if user.PhoneVerifiedAt != nil {
+		return "", "", entities.ErrPhoneVerified
+	}
+
+	code, err = s.startPhoneChange(ctx, userID, user.PhoneNumber)
+	if err != nil {
+		return "", "", err
+	}
+
+	return user.PhoneNumber, code, nil
+}
+
+// ConfirmPhoneChange switches to the number waiting for confirmation once
+// code matches the one sent to it, marks it verified and returns a token
+// carrying it. After too many wrong codes the change has to be asked for again.
+func (s *UserService) ConfirmPhoneChange(ctx context.Context, userID int, code string, mfa bool, keys *jwtkeys.Keyring) (string, error) {
+	id := strconv.Itoa(userID)
+
+	change, err := s.userRepository.GetPendingChange(ctx, pendingPhone, id)
+	if errors.Is(err, entities.ErrNoRecord) {
+		return "", entities.ErrNoPendingChange
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashConfirmation(code)), []byte(change.CodeHash)) != 1 {
+		change.Attempts++
+		if change.Attempts >= maxConfirmAttempts {
+			err = s.userRepository.DeletePendingChange(ctx, pendingPhone, id)
+		} else {
+			err = s.userRepository.UpdatePendingChange(ctx, pendingPhone, id, *change)
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "recording phone code attempt failed", "error", err)
+		}
+
+		return "", entities.ErrInvalidCode
+	}
+
+	err = s.userRepository.DeletePendingChange(ctx, pendingPhone, id)
+	if err != nil {
+		return "", err
+	}
+
+	err = s.userRepository.UpdateUserPhone(ctx, userID, change.Value)
+	if err != nil {
+		return "", err
+	}
+
+	return s.sessionToken(ctx, userID, mfa, keys)
+}
+
+// RequestEmailChange checks the password and returns a token to be mailed
+// to the new address and passed to ConfirmEmailChange within a day.
+func (s *UserService) RequestEmailChange(ctx context.Context, userID int, data entities.EmailChangePayload) (string, error) {
+	email := strings.TrimSpace(data.Email)
+	if !entities.EmailRegex.MatchString(email) {
+		return "", fmt.Errorf("%w: valid email needed", entities.ErrInvalidProfile)
+	}
+
+	user, err := s.userRepository.FindProfileByID(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	err = s.checkPassword(ctx, user, data.Password)
+	if err != nil {
+		return "", err
+	}
+
+	if email == user.Email {
+		return "", fmt.Errorf("%w: that is already your email", entities.ErrInvalidProfile)
+	}
+
+	taken, err := s.userRepository.FindUserByEmail(ctx, email)
+	if err != nil {
+		return "", err
+	}
+
+	if taken {
+		return "", entities.ErrDuplicateEmail
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	change := entities.PendingChange{UserID: userID, Value: email}
+
+	err = s.userRepository.SavePendingChange(ctx, pendingEmail, hashConfirmation(token), change, emailChangeTTL)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// ConfirmEmailChange moves the account to the email token was sent to and
+// returns an auth token for the new address. The address is checked again,
+// since another account may have taken it since the change was requested,
+// and the token stays usable until the change is made.
+func (s *UserService) ConfirmEmailChange(ctx context.Context, userID int, token string, mfa bool, keys *jwtkeys.Keyring) (string, error) {
+	id := hashConfirmation(token)
+
+	change, err := s.userRepository.GetPendingChange(ctx, pendingEmail, id)
+	if errors.Is(err, entities.ErrNoRecord) {
+		return "", entities.ErrNoPendingChange
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if change.UserID != userID {
+		return "", entities.ErrNoPendingChange
+	}
+
+	taken, err := s.userRepository.FindUserByEmail(ctx, change.Value)
+	if err != nil {
+		return "", err
+	}
+
+	if taken {
+		return "", entities.ErrDuplicateEmail
+	}
+
+	// UpdateUserEmail maps a duplicate key to ErrDuplicateEmail if the
+	// address is taken between the check and the update.
+	err = s.userRepository.UpdateUserEmail(ctx, userID, change.Value)
+	if err != nil {
+		return "", err
+	}
+
+	err = s.userRepository.DeletePendingChange(ctx, pendingEmail, id)
+	if err != nil {
+		return "", err
+	}
+
+	return s.sessionToken(ctx, userID, mfa, keys)
+}
+
+// ChangePassword sets a new password after checking the current one. Every
+// auth token issued before the change, this session's included, stops
+// working.
+func (s *UserService) ChangePassword(ctx context.Context, userID int, data entities.PasswordChangePayload) error {
+	if data.CurrentPassword == "" || data.Password == "" {
+		return fmt.Errorf("%w: current and new password are required", entities.ErrInvalidProfile)
+	}
+
+	if data.Password != data.ConfirmPassword {
+		return fmt.Errorf("%w: password and confirm password must match", entities.ErrInvalidProfile)
+	}
+
+	user, err := s.userRepository.FindProfileByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	err = s.checkPassword(ctx, user, data.CurrentPassword)
+	if err != nil {
+		return err
+	}
+
+	return s.userRepository.UpdatePassword(ctx, &data.Password, userID)
+}
+
+// DeleteAccount anonymizes the account after checking the password and ends
+// its sessions. Its bookings and transactions are kept for accounting. Guests with stays in
+// progress cannot delete their account, and vendors have to ask support.
+func (s *UserService) DeleteAccount(ctx context.Context, userID int, password string) error {
+	user, err := s.userRepository.FindProfileByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	err = s.checkPassword(ctx, user, password)
+	if err != nil {
+		return err
+	}
+
+	if user.IsVender == "YES" {
+		return entities.ErrVendorDeletion
+	}
+
+	active, err := s.userRepository.CountActiveBookings(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if active > 0 {
+		return entities.ErrAccountInUse
+	}
+
+	err = s.userRepository.AnonymizeUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	err = s.userRepository.DeletePendingChange(ctx, pendingPhone, strconv.Itoa(userID))
+	if err != nil {
+		slog.WarnContext(ctx, "clearing pending phone change failed", "error", err)
+	}
+
+	return nil
+}
+
+// ExportPersonalData collects everything stored about the user.
+func (s *UserService) ExportPersonalData(ctx context.Context, userID int) (*entities.PersonalDataExport, error) {
+	user, err := s.userRepository.FindProfileByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	mfa, err := s.enabledMFA(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &entities.PersonalDataExport{ExportedAt: time.Now().UTC(), Profile: user, TwoFactor: mfa != nil}
+
+	export.Identities, err = s.userRepository.GetUserIdentities(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	export.Bookings, err = s.userRepository.GetUserBookings(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	export.Reviews, err = s.userRepository.GetUserReviews(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	export.Transactions, err = s.userRepository.GetUserTransactions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	export.Messages, err = s.userRepository.GetUserMessages(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.IsVender == "YES" {
+		export.APIKeys, err = s.userRepository.GetVendorAPIKeys(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return export, nil
+}
+
+// checkPassword compares password with the user's. Wrong passwords count
+// as failed logins, so a stolen token cannot be used to guess it.
+func (s *UserService) checkPassword(ctx context.Context, user *entities.User, password string) error {
+	wait, err := s.userRepository.GetLoginLock(ctx, user.Email)
+	if err != nil {
+		slog.WarnContext(ctx, "checking login lock failed", "error", err)
+	}
+
+	if wait > 0 {
+		return &entities.LockoutError{RetryAfter: wait}
+	}
+
+	if password == "" || !utils.ComparePasswordWithHash(password, &user.Password) {
+		lockErr := s.loginFailed(ctx, user.Email)
+		if lockErr != nil {
+			return lockErr
+		}
+		return entities.ErrWrongPassword
+	}
+
+	return nil
+}
+
+// SessionsValidFrom returns when userID's auth tokens start being accepted,
+// for the auth middleware. Deleted accounts get ErrNoRecord.
+func (s *UserService) SessionsValidFrom(ctx context.Context, userID int) (time.Time, error) {
+	return s.userRepository.SessionsValidFrom(ctx, userID)
+}
+
+// sessionToken returns a new auth token for the user's current details,
+// keeping the second factor of the session it replaces.
+func (s *UserService) sessionToken(ctx context.Context, userID int, mfa bool, keys *jwtkeys.Keyring) (string, error) {
+	user, err := s.userRepository.FindProfileByID(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	if mfa {
+		return utils.GenerateMFAAuthToken(*user, keys)
+	}
+
+	return utils.GenerateAuthToken(*user, keys)
+}
+
+// startPhoneChange saves number as waiting for confirmation and returns the
+// code to send to it.
+func (s *UserService) startPhoneChange(ctx context.Context, userID int, number string) (string, error) {
+	code, err := newConfirmationCode()
+	if err != nil {
+		return "", err
+	}
+
+	change := entities.PendingChange{UserID: userID, Value: number, CodeHash: hashConfirmation(code)}
+
+	err = s.userRepository.SavePendingChange(ctx, pendingPhone, strconv.Itoa(userID), change, phoneChangeTTL)
+	if err != nil {
+		return "", err
+	}
+
+	return code, nil
+}
+
+// newConfirmationCode returns a random six digit code.
+func newConfirmationCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// hashConfirmation hashes a confirmation code or token for storage. Codes
+// expire within minutes and allow few attempts, so a fast hash is enough.
+func hashConfirmation(v string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(v)))
+	return hex.EncodeToString(sum[:])
+}
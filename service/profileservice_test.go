@@ -0,0 +1,296 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/repo"
+	"github.com/go-sql-driver/mysql"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testPasswordHash is the bcrypt hash of "1234".
+const testPasswordHash = "$2a$10$/r5qIMP1AkNOMdr495Ff0eCdrZWyW79Q5E3RxFVgCbk0ret4j4mDa"
+
+func newTestProfileService(t *testing.T) (*UserService, sqlmock.Sqlmock, *miniredis.Miniredis) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	return NewUserService(*repo.NewDBRepository(db, client), entities.LockoutConfig{}), mock, mr
+}
+
+func expectProfile(mock sqlmock.Sqlmock, userID int, isVendor string) {
+	expectProfileVerified(mock, userID, isVendor, nil)
+}
+
+func expectProfileVerified(mock sqlmock.Sqlmock, userID int, isVendor string, verifiedAt *time.Time) {
+	now := time.Now()
+	mock.ExpectPrepare("SELECT user_id, email").ExpectQuery().WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows(profileColumns).
+			AddRow(userID, "guest@gmail.com", "+254704961755", isVendor, testPasswordHash, "", now, now, now, "Jane", verifiedAt))
+}
+
+func TestUpdateProfile_PhoneNeedsConfirmation(t *testing.T) {
+	s, mock, mr := newTestProfileService(t)
+	ctx := context.Background()
+
+	name, phone := " Jane Wanjiku ", "+254712345678"
+
+	expectProfile(mock, 7, "NO")
+	mock.ExpectPrepare("UPDATE user SET name").ExpectExec().WithArgs("Jane Wanjiku", 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	user, code, err := s.UpdateProfile(ctx, 7, entities.ProfilePayload{Name: &name, PhoneNumber: &phone})
+	require.NoError(t, err)
+	assert.Equal(t, "Jane Wanjiku", user.Name)
+	assert.Equal(t, "+254704961755", user.PhoneNumber, "the number changes only once confirmed")
+	assert.Len(t, code, 6)
+	assert.True(t, mr.Exists("profile:phone:7"))
+
+	_, err = s.ConfirmPhoneChange(ctx, 7, "not-it", false, testKeys)
+	assert.ErrorIs(t, err, entities.ErrInvalidCode)
+
+	mock.ExpectPrepare("UPDATE user SET phone_number").ExpectExec().WithArgs(phone, 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectProfile(mock, 7, "NO")
+
+	token, err := s.ConfirmPhoneChange(ctx, 7, code, false, testKeys)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, token)
+	assert.False(t, mr.Exists("profile:phone:7"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateProfile_Invalid(t *testing.T) {
+	s, mock, _ := newTestProfileService(t)
+
+	phone := "0712345678"
+	expectProfile(mock, 7, "NO")
+
+	_, _, err := s.UpdateProfile(context.Background(), 7, entities.ProfilePayload{PhoneNumber: &phone})
+	assert.ErrorIs(t, err, entities.ErrInvalidProfile)
+}
+
+func TestConfirmPhoneChange_TooManyAttempts(t *testing.T) {
+	s, mock, _ := newTestProfileService(t)
+	ctx := context.Background()
+
+	phone := "+254712345678"
+	expectProfile(mock, 7, "NO")
+
+	_, _, err := s.UpdateProfile(ctx, 7, entities.ProfilePayload{PhoneNumber: &phone})
+	require.NoError(t, err)
+
+	for range maxConfirmAttempts {
+		_, err = s.ConfirmPhoneChange(ctx, 7, "000000x", false, testKeys)
+		assert.ErrorIs(t, err, entities.ErrInvalidCode)
+	}
+
+	_, err = s.ConfirmPhoneChange(ctx, 7, "000000x", false, testKeys)
+	assert.ErrorIs(t, err, entities.ErrNoPendingChange)
+}
+
+func TestRequestPhoneVerification(t *testing.T) {
+	s, mock, mr := newTestProfileService(t)
+	ctx := context.Background()
+
+	expectProfile(mock, 7, "NO")
+
+	number, code, err := s.RequestPhoneVerification(ctx, 7)
+	require.NoError(t, err)
+	assert.Equal(t, "+254704961755", number)
+	assert.True(t, mr.Exists("profile:phone:7"))
+
+	mock.ExpectPrepare("UPDATE user SET phone_number").ExpectExec().WithArgs(number, 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectProfile(mock, 7, "NO")
+
+	_, err = s.ConfirmPhoneChange(ctx, 7, code, false, testKeys)
+	assert.NoError(t, err)
+
+	verified := time.Now()
+	expectProfileVerified(mock, 7, "NO", &verified)
+
+	_, _, err = s.RequestPhoneVerification(ctx, 7)
+	assert.ErrorIs(t, err, entities.ErrPhoneVerified)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEmailChange(t *testing.T) {
+	s, mock, _ := newTestProfileService(t)
+	ctx := context.Background()
+
+	expectProfile(mock, 7, "NO")
+	_, err := s.RequestEmailChange(ctx, 7, entities.EmailChangePayload{Email: "new@gmail.com", Password: "wrong"})
+	assert.ErrorIs(t, err, entities.ErrWrongPassword)
+
+	expectProfile(mock, 7, "NO")
+	mock.ExpectPrepare("SELECT COUNT.* FROM user").ExpectQuery().WithArgs("new@gmail.com").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+	token, err := s.RequestEmailChange(ctx, 7, entities.EmailChangePayload{Email: "new@gmail.com", Password: "1234"})
+	require.NoError(t, err)
+
+	// The token only works for the account that asked for it.
+	_, err = s.ConfirmEmailChange(ctx, 8, token, false, testKeys)
+	assert.ErrorIs(t, err, entities.ErrNoPendingChange)
+
+	mock.ExpectPrepare("SELECT COUNT.* FROM user").ExpectQuery().WithArgs("new@gmail.com").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectPrepare("UPDATE user SET email").ExpectExec().WithArgs("new@gmail.com", 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectProfile(mock, 7, "NO")
+
+	session, err := s.ConfirmEmailChange(ctx, 7, token, true, testKeys)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, session)
+
+	_, err = s.ConfirmEmailChange(ctx, 7, token, true, testKeys)
+	assert.ErrorIs(t, err, entities.ErrNoPendingChange)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEmailChange_Taken(t *testing.T) {
+	s, mock, _ := newTestProfileService(t)
+
+	expectProfile(mock, 7, "NO")
+	mock.ExpectPrepare("SELECT COUNT.* FROM user").ExpectQuery().WithArgs("taken@gmail.com").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	_, err := s.RequestEmailChange(context.Background(), 7, entities.EmailChangePayload{Email: "taken@gmail.com", Password: "1234"})
+	assert.ErrorIs(t, err, entities.ErrDuplicateEmail)
+}
+
+func TestConfirmEmailChange_TakenSinceRequest(t *testing.T) {
+	s, mock, _ := newTestProfileService(t)
+	ctx := context.Background()
+
+	expectProfile(mock, 7, "NO")
+	mock.ExpectPrepare("SELECT COUNT.* FROM user").ExpectQuery().WithArgs("new@gmail.com").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+	token, err := s.RequestEmailChange(ctx, 7, entities.EmailChangePayload{Email: "new@gmail.com", Password: "1234"})
+	require.NoError(t, err)
+
+	// Another account registered the address before the confirmation.
+	mock.ExpectPrepare("SELECT COUNT.* FROM user").ExpectQuery().WithArgs("new@gmail.com").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	_, err = s.ConfirmEmailChange(ctx, 7, token, false, testKeys)
+	assert.ErrorIs(t, err, entities.ErrDuplicateEmail)
+
+	// Or took it between the check and the update.
+	mock.ExpectPrepare("SELECT COUNT.* FROM user").ExpectQuery().WithArgs("new@gmail.com").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectPrepare("UPDATE user SET email").ExpectExec().WithArgs("new@gmail.com", 7).
+		WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"})
+
+	_, err = s.ConfirmEmailChange(ctx, 7, token, false, testKeys)
+	assert.ErrorIs(t, err, entities.ErrDuplicateEmail)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestChangePassword(t *testing.T) {
+	s, mock, _ := newTestProfileService(t)
+	ctx := context.Background()
+
+	err := s.ChangePassword(ctx, 7, entities.PasswordChangePayload{CurrentPassword: "1234", Password: "a", ConfirmPassword: "b"})
+	assert.ErrorIs(t, err, entities.ErrInvalidProfile)
+
+	expectProfile(mock, 7, "NO")
+	mock.ExpectPrepare("UPDATE user SET hashed_password = \\?, updated_at = NOW\\(\\), password_inserted_at = UTC_TIMESTAMP\\(\\)").ExpectExec().WithArgs(sqlmock.AnyArg(), 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = s.ChangePassword(ctx, 7, entities.PasswordChangePayload{CurrentPassword: "1234", Password: "new-pass", ConfirmPassword: "new-pass"})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteAccount(t *testing.T) {
+	t.Run("deleted", func(t *testing.T) {
+		s, mock, _ := newTestProfileService(t)
+
+		expectProfile(mock, 7, "NO")
+		mock.ExpectPrepare("SELECT COUNT.* FROM booking").ExpectQuery().WithArgs(7, entities.BookingStatusConfirmed, entities.BookingStatusCheckedIn).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE user SET email").WithArgs("deleted-7@deleted.invalid", "d7", 7).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		for range 4 {
+			mock.ExpectExec("DELETE FROM").WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 0))
+		}
+		mock.ExpectExec("UPDATE api_key SET revoked_at").WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		assert.NoError(t, s.DeleteAccount(context.Background(), 7, "1234"))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("stay in progress", func(t *testing.T) {
+		s, mock, _ := newTestProfileService(t)
+
+		expectProfile(mock, 7, "NO")
+		mock.ExpectPrepare("SELECT COUNT.* FROM booking").ExpectQuery().WithArgs(7, entities.BookingStatusConfirmed, entities.BookingStatusCheckedIn).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+		assert.ErrorIs(t, s.DeleteAccount(context.Background(), 7, "1234"), entities.ErrAccountInUse)
+	})
+
+	t.Run("vendor", func(t *testing.T) {
+		s, mock, _ := newTestProfileService(t)
+
+		expectProfile(mock, 7, "YES")
+
+		assert.ErrorIs(t, s.DeleteAccount(context.Background(), 7, "1234"), entities.ErrVendorDeletion)
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		s, mock, _ := newTestProfileService(t)
+
+		expectProfile(mock, 7, "NO")
+
+		assert.ErrorIs(t, s.DeleteAccount(context.Background(), 7, ""), entities.ErrWrongPassword)
+	})
+}
+
+func TestExportPersonalData(t *testing.T) {
+	s, mock, _ := newTestProfileService(t)
+	now := time.Now()
+
+	expectProfile(mock, 7, "NO")
+	mock.ExpectPrepare("FROM user_mfa").ExpectQuery().WithArgs(7).
+		WillReturnRows(sqlmock.NewRows(userMFAColumns).AddRow(7, testMFASecret, now, 0))
+	mock.ExpectPrepare("FROM user_identity").ExpectQuery().WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"provider", "subject", "email", "created_at"}).AddRow("google", "sub-1", "guest@gmail.com", now))
+	mock.ExpectPrepare("FROM booking").ExpectQuery().WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"booking_id", "days", "user_id", "room_id", "currency", "status", "checked_in_at", "checked_out_at", "created_at", "updated_at"}).
+			AddRow(1, 2, 7, 3, "KES", entities.BookingStatusCheckedOut, now, now, now, now))
+	mock.ExpectPrepare("FROM review").ExpectQuery().WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"review_id"}))
+	mock.ExpectPrepare("FROM transaction").ExpectQuery().WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"transaction_id", "user_id", "room_id", "order_id", "trx_id", "reference", "amount", "currency", "status", "created_at", "updated_at"}).
+			AddRow(1, 7, 3, "ord-1", "trx-1", "ref-1", 1400000, "KES", entities.TransactionStatusPaid, now, now))
+	mock.ExpectPrepare("FROM sms_outbox").ExpectQuery().WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"sms_id", "msg", "created_at"}))
+
+	export, err := s.ExportPersonalData(context.Background(), 7)
+	require.NoError(t, err)
+	assert.Equal(t, "Jane", export.Profile.Name)
+	assert.True(t, export.TwoFactor)
+	assert.Len(t, export.Identities, 1)
+	assert.Len(t, export.Bookings, 1)
+	assert.Len(t, export.Transactions, 1)
+	assert.Nil(t, export.APIKeys)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
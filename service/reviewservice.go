@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/bicosteve/booking-system/entities"
+)
+
+// SubmitReview records a guest's review of a stay. Only the guest who made
+// the booking can review it, once, and only after checking out.
+func (rs *ReviewService) SubmitReview(ctx context.Context, userID int, data entities.ReviewPayload) (*entities.Review, error) {
+	booking, err := rs.reviewRepository.GetBookingByID(ctx, *data.BookingID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, entities.ErrNoRecord
+		}
+		return nil, err
+	}
+
+	if booking.UserID != userID {
+		return nil, entities.ErrNoRecord
+	}
+
+	if booking.Status != entities.BookingStatusCheckedOut {
+		return nil, entities.ErrReviewNotAllowed
+	}
+
+	exists, err := rs.reviewRepository.ReviewExists(ctx, booking.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if exists {
+		return nil, entities.ErrReviewExists
+	}
+
+	review := entities.Review{
+		BookingID: booking.ID,
+		RoomID:    booking.RoomID,
+		VendorID:  booking.VenderID,
+		UserID:    userID,
+		Ratings:   data.Ratings,
+		Rating:    OverallRating(data.Ratings),
+		Text:      data.Text,
+	}
+
+	err = rs.reviewRepository.CreateReview(ctx, review)
+	if err != nil {
+		return nil, err
+	}
+
+	return &review, nil
+}
+
+// OverallRating is the mean of the category ratings.
+func OverallRating(r entities.ReviewRatings) float64 {
+	return float64(r.Cleanliness+r.Comfort+r.Location+r.Value) / 4
+}
+
+func (rs *ReviewService) GetRoomReviews(ctx context.Context, roomID int) ([]*entities.Review, error) {
+	reviews, err := rs.reviewRepository.GetRoomReviews(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	return reviews, nil
+}
+
+func (rs *ReviewService) GetVendorReviews(ctx context.Context, vendorID int) ([]*entities.Review, error) {
+	reviews, err := rs.reviewRepository.GetVendorReviews(ctx, vendorID)
+	if err != nil {
+		return nil, err
+	}
+
+	return reviews, nil
+}
+
+func (rs *ReviewService) GetVendorRating(ctx context.Context, vendorID int) (*entities.RatingSummary, error) {
+	summary, err := rs.reviewRepository.GetVendorRating(ctx, vendorID)
+	if err != nil {
+		return nil, err
+	}
+
+	return summary, nil
+}
+
+func (rs *ReviewService) ReplyToReview(ctx context.Context, reviewID, vendorID int, reply string) error {
+	reply = strings.TrimSpace(reply)
+	if reply == "" {
+		return errors.New("reply is required")
+	}
+
+	return rs.reviewRepository.ReplyToReview(ctx, reviewID, vendorID, reply)
+}
+
+// FlagReview hides a review from guests and from ratings until it is
+// unflagged. A reason is required when flagging. Only platform admins may
+// flag, so vendors cannot hide the reviews of their own rooms.
+func (rs *ReviewService) FlagReview(ctx context.Context, reviewID int, flagged bool, reason string) error {
+	reason = strings.TrimSpace(reason)
+	if flagged && reason == "" {
+		return errors.New("a reason is required to flag a review")
+	}
+
+	if !flagged {
+		reason = ""
+	}
+
+	return rs.reviewRepository.FlagReview(ctx, reviewID, flagged, reason)
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/repo"
+	"github.com/go-redis/redismock/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func newReviewService(t *testing.T) (*ReviewService, sqlmock.Sqlmock, func()) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock db: %v", err)
+	}
+	rdb, _ := redismock.NewClientMock()
+	repository := *repo.NewDBRepository(db, rdb)
+	return NewReviewService(repository), mock, func() { db.Close() }
+}
+
+func expectReviewBooking(mock sqlmock.Sqlmock, userID, status int) {
+	mockTime := time.Now()
+	mock.ExpectPrepare("SELECT b.booking_id").
+		ExpectQuery().
+		WithArgs(100).
+		WillReturnRows(sqlmock.NewRows([]string{"booking_id", "days", "user_id", "room_id", "currency", "status", "vender_id", "checked_in_at", "checked_out_at", "created_at", "updated_at"}).
+			AddRow(100, 2, userID, 10, "KES", status, 7, nil, nil, mockTime, mockTime))
+}
+
+func TestOverallRating(t *testing.T) {
+	assert.Equal(t, 4.0, OverallRating(entities.ReviewRatings{Cleanliness: 5, Comfort: 4, Location: 4, Value: 3}))
+	assert.Equal(t, 4.75, OverallRating(entities.ReviewRatings{Cleanliness: 5, Comfort: 5, Location: 5, Value: 4}))
+}
+
+func TestReviewService_SubmitReview(t *testing.T) {
+	bookingID := 100
+	payload := entities.ReviewPayload{
+		BookingID: &bookingID,
+		Ratings:   entities.ReviewRatings{Cleanliness: 5, Comfort: 4, Location: 4, Value: 3},
+		Text:      "Great stay",
+	}
+
+	t.Run("checked out booking", func(t *testing.T) {
+		svc, mock, cleanup := newReviewService(t)
+		defer cleanup()
+
+		expectReviewBooking(mock, 5, entities.BookingStatusCheckedOut)
+		mock.ExpectPrepare("SELECT COUNT").ExpectQuery().WithArgs(100).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectPrepare("INSERT INTO review").ExpectExec().
+			WithArgs(100, 10, 7, 5, 5, 4, 4, 3, 4.0, "Great stay").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		review, err := svc.SubmitReview(context.Background(), 5, payload)
+		assert.NoError(t, err)
+		assert.Equal(t, 7, review.VendorID)
+		assert.Equal(t, 4.0, review.Rating)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("booking not checked out", func(t *testing.T) {
+		svc, mock, cleanup := newReviewService(t)
+		defer cleanup()
+
+		expectReviewBooking(mock, 5, entities.BookingStatusConfirmed)
+
+		_, err := svc.SubmitReview(context.Background(), 5, payload)
+		assert.ErrorIs(t, err, entities.ErrReviewNotAllowed)
+	})
+
+	t.Run("booking of another guest", func(t *testing.T) {
+		svc, mock, cleanup := newReviewService(t)
+		defer cleanup()
+
+		expectReviewBooking(mock, 6, entities.BookingStatusCheckedOut)
+
+		_, err := svc.SubmitReview(context.Background(), 5, payload)
+		assert.ErrorIs(t, err, entities.ErrNoRecord)
+	})
+
+	t.Run("unknown booking", func(t *testing.T) {
+		svc, mock, cleanup := newReviewService(t)
+		defer cleanup()
+
+		mock.ExpectPrepare("SELECT b.booking_id").ExpectQuery().WithArgs(100).WillReturnError(sql.ErrNoRows)
+
+		_, err := svc.SubmitReview(context.Background(), 5, payload)
+		assert.ErrorIs(t, err, entities.ErrNoRecord)
+	})
+
+	t.Run("already reviewed", func(t *testing.T) {
+		svc, mock, cleanup := newReviewService(t)
+		defer cleanup()
+
+		expectReviewBooking(mock, 5, entities.BookingStatusCheckedOut)
+		mock.ExpectPrepare("SELECT COUNT").ExpectQuery().WithArgs(100).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+		_, err := svc.SubmitReview(context.Background(), 5, payload)
+		assert.ErrorIs(t, err, entities.ErrReviewExists)
+	})
+}
+
+func TestReviewService_ReplyToReview(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		svc, mock, cleanup := newReviewService(t)
+		defer cleanup()
+
+		mock.ExpectPrepare("UPDATE review SET vendor_reply").ExpectExec().
+			WithArgs("Thanks!", 1, 7).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := svc.ReplyToReview(context.Background(), 1, 7, "  Thanks!  ")
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("empty reply", func(t *testing.T) {
+		svc, _, cleanup := newReviewService(t)
+		defer cleanup()
+
+		err := svc.ReplyToReview(context.Background(), 1, 7, " ")
+		assert.EqualError(t, err, "reply is required")
+	})
+}
+
+func TestReviewService_FlagReview(t *testing.T) {
+	t.Run("flag requires reason", func(t *testing.T) {
+		svc, _, cleanup := newReviewService(t)
+		defer cleanup()
+
+		err := svc.FlagReview(context.Background(), 1, true, "")
+		assert.EqualError(t, err, "a reason is required to flag a review")
+	})
+
+	t.Run("unflag clears reason", func(t *testing.T) {
+		svc, mock, cleanup := newReviewService(t)
+		defer cleanup()
+
+		mock.ExpectPrepare("UPDATE review SET flagged").ExpectExec().
+			WithArgs(false, "", 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := svc.FlagReview(context.Background(), 1, false, "old reason")
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
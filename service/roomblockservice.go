@@ -0,0 +1,245 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bicosteve/booking-system/entities"
+)
+
+// maxBlockDays is the longest a vendor may take a room off sale at once.
+const maxBlockDays = 366
+
+// CreateBlock takes one of the vendor's rooms off sale. It refuses dates that
+// already have confirmed stays; those bookings must be cancelled first.
+func (cs *CalendarService) CreateBlock(ctx context.Context, roomID, vendorID int, payload entities.RoomBlockPayload) (*entities.RoomBlock, error) {
+	if payload.StartDate == nil || payload.EndDate == nil || payload.Reason == nil {
+		return nil, fmt.Errorf("%w: start_date, end_date and reason are required", entities.ErrInvalidBlock)
+	}
+
+	block := &entities.RoomBlock{RoomID: roomID}
+
+	err := applyBlockPayload(block, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cs.checkStays(ctx, block)
+	if err != nil {
+		return nil, err
+	}
+
+	block.ID, err = cs.calendarRepository.CreateRoomBlock(ctx, block, vendorID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	block.CreatedAt, block.UpdatedAt = now, now
+
+	return block, nil
+}
+
+// GetBlocks lists the blocks of one of the vendor's rooms between from and
+// to, including those imported from external calendars.
+func (cs *CalendarService) GetBlocks(ctx context.Context, roomID, vendorID int, from, to time.Time) ([]*entities.RoomBlock, error) {
+	return cs.calendarRepository.GetRoomBlocks(ctx, roomID, vendorID, from, to)
+}
+
+// UpdateBlock changes the fields set in payload on a block the vendor
+// created. Imported blocks return ErrBlockImported.
+func (cs *CalendarService) UpdateBlock(ctx context.Context, blockID, roomID, vendorID int, payload entities.RoomBlockPayload) (*entities.RoomBlock, error) {
+	block, err := cs.calendarRepository.GetRoomBlock(ctx, blockID, roomID, vendorID)
+	if err != nil {
+		return nil, err
+	}
+
+	if block.CalendarID != nil {
+		return nil, entities.ErrBlockImported
+	}
+
+	start, end := block.Start, block.End
+
+	err = applyBlockPayload(block, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only nights the block did not already cover can clash with new stays.
+	if !block.Start.Equal(start) || !block.End.Equal(end) {
+		err = cs.checkStays(ctx, block)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = cs.calendarRepository.UpdateRoomBlock(ctx, block, vendorID)
+	if err != nil {
+		return nil, err
+	}
+
+	block.UpdatedAt = time.Now()
+
+	return block, nil
+}
+
+// DeleteBlock puts the room back on sale for a block the vendor created.
+// Imported blocks return ErrBlockImported.
+func (cs *CalendarService) DeleteBlock(ctx context.Context, blockID, roomID, vendorID int) error {
+	block, err := cs.calendarRepository.GetRoomBlock(ctx, blockID, roomID, vendorID)
+	if err != nil {
+		return err
+	}
+
+	if block.CalendarID != nil {
+		return entities.ErrBlockImported
+	}
+
+	return cs.calendarRepository.DeleteRoomBlock(ctx, blockID, roomID, vendorID)
+}
+
+// VendorCalendar returns the stays and blocks of one of the vendor's rooms
+// between from and to, with the status of each stay and the reason of each
+// block.
+func (cs *CalendarService) VendorCalendar(ctx context.Context, roomID, vendorID int, from, to time.Time) ([]entities.CalendarEntry, error) {
+	room, err := cs.findRoom(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	if room.VenderId != strconv.Itoa(vendorID) {
+		return nil, entities.ErrNoRecord
+	}
+
+	return cs.calendarRepository.GetRoomCalendarEntries(ctx, roomID, from, to)
+}
+
+// Availability returns the date ranges between from and to on which a room
+// cannot be booked. Stays and blocks are merged so guests cannot tell them
+// apart.
+func (cs *CalendarService) Availability(ctx context.Context, roomID int, from, to time.Time) ([]entities.DateRange, error) {
+	_, err := cs.findRoom(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := cs.calendarRepository.GetRoomCalendarEntries(ctx, roomID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	// Entries are ordered by start, so each one either extends the last
+	// range or starts a new one.
+	ranges := []entities.DateRange{}
+	for _, e := range entries {
+		start, end := maxTime(e.Start, from), minTime(e.End, to)
+
+		if n := len(ranges); n > 0 && !start.After(ranges[n-1].End) {
+			ranges[n-1].End = maxTime(ranges[n-1].End, end)
+			continue
+		}
+
+		ranges = append(ranges, entities.DateRange{Start: start, End: end})
+	}
+
+	return ranges, nil
+}
+
+func (cs *CalendarService) findRoom(ctx context.Context, roomID int) (*entities.Room, error) {
+	room, err := cs.calendarRepository.FindRoomByID(ctx, roomID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, entities.ErrNoRecord
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return room, nil
+}
+
+func (cs *CalendarService) checkStays(ctx context.Context, block *entities.RoomBlock) error {
+	booked, err := cs.calendarRepository.HasRoomStays(ctx, block.RoomID, block.Start, block.End)
+	if err != nil {
+		return err
+	}
+
+	if booked {
+		return entities.ErrBlockOverlapsStay
+	}
+
+	return nil
+}
+
+// applyBlockPayload copies the fields set in payload onto block and checks
+// the result.
+func applyBlockPayload(block *entities.RoomBlock, payload entities.RoomBlockPayload) error {
+	var err error
+
+	if payload.StartDate != nil {
+		block.Start, err = time.Parse(entities.DateLayout, *payload.StartDate)
+		if err != nil {
+			return fmt.Errorf("%w: start_date must be a YYYY-MM-DD date", entities.ErrInvalidBlock)
+		}
+	}
+
+	if payload.EndDate != nil {
+		block.End, err = time.Parse(entities.DateLayout, *payload.EndDate)
+		if err != nil {
+			return fmt.Errorf("%w: end_date must be a YYYY-MM-DD date", entities.ErrInvalidBlock)
+		}
+	}
+
+	if payload.Reason != nil {
+		block.Reason = *payload.Reason
+	}
+
+	if payload.Note != nil {
+		block.Note = strings.TrimSpace(*payload.Note)
+	}
+
+	return validateBlock(block)
+}
+
+func validateBlock(block *entities.RoomBlock) error {
+	if !block.End.After(block.Start) {
+		return fmt.Errorf("%w: end_date must be after start_date", entities.ErrInvalidBlock)
+	}
+
+	if block.End.Sub(block.Start) > maxBlockDays*24*time.Hour {
+		return fmt.Errorf("%w: a block cannot be longer than %d days", entities.ErrInvalidBlock, maxBlockDays)
+	}
+
+	if !block.End.After(time.Now().UTC().Truncate(24 * time.Hour)) {
+		return fmt.Errorf("%w: block has already ended", entities.ErrInvalidBlock)
+	}
+
+	if !slices.Contains(entities.BlockReasons, block.Reason) {
+		return fmt.Errorf("%w: reason must be one of %s", entities.ErrInvalidBlock, strings.Join(entities.BlockReasons, ", "))
+	}
+
+	if len(block.Note) > 255 {
+		return fmt.Errorf("%w: note cannot be longer than 255 characters", entities.ErrInvalidBlock)
+	}
+
+	return nil
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func blockPayload(start, end time.Time, reason string) entities.RoomBlockPayload {
+	s, e := start.Format(entities.DateLayout), end.Format(entities.DateLayout)
+	return entities.RoomBlockPayload{StartDate: &s, EndDate: &e, Reason: &reason}
+}
+
+func TestCalendarService_CreateBlock(t *testing.T) {
+	start := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 10)
+	end := start.AddDate(0, 0, 3)
+
+	t.Run("success", func(t *testing.T) {
+		svc, mock, cleanup := newCalendarService(t)
+		defer cleanup()
+
+		mock.ExpectPrepare("SELECT COUNT\\(\\*\\) FROM booking").ExpectQuery().
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectPrepare("INSERT INTO room_block").ExpectExec().
+			WithArgs(start.Format(entities.DateLayout), end.Format(entities.DateLayout), entities.BlockReasonMaintenance, "", 10, 7).
+			WillReturnResult(sqlmock.NewResult(12, 1))
+
+		block, err := svc.CreateBlock(context.Background(), 10, 7, blockPayload(start, end, entities.BlockReasonMaintenance))
+		require.NoError(t, err)
+		assert.Equal(t, 12, block.ID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("overlaps a stay", func(t *testing.T) {
+		svc, mock, cleanup := newCalendarService(t)
+		defer cleanup()
+
+		mock.ExpectPrepare("SELECT COUNT\\(\\*\\) FROM booking").ExpectQuery().
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+		_, err := svc.CreateBlock(context.Background(), 10, 7, blockPayload(start, end, entities.BlockReasonOwnerUse))
+		assert.ErrorIs(t, err, entities.ErrBlockOverlapsStay)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		svc, _, cleanup := newCalendarService(t)
+		defer cleanup()
+
+		for name, payload := range map[string]entities.RoomBlockPayload{
+			"missing dates":  {},
+			"unknown reason": blockPayload(start, end, "BOOKED"),
+			"end before":     blockPayload(end, start, entities.BlockReasonMaintenance),
+			"in the past":    blockPayload(start.AddDate(0, -1, 0), start.AddDate(0, 0, -20), entities.BlockReasonMaintenance),
+			"too long":       blockPayload(start, start.AddDate(2, 0, 0), entities.BlockReasonMaintenance),
+		} {
+			_, err := svc.CreateBlock(context.Background(), 10, 7, payload)
+			assert.ErrorIs(t, err, entities.ErrInvalidBlock, name)
+		}
+	})
+}
+
+func TestCalendarService_UpdateBlock(t *testing.T) {
+	start := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 10)
+	now := time.Now()
+	columns := []string{"block_id", "room_id", "start_date", "end_date", "reason", "note", "calendar_id", "external_uid", "created_at", "updated_at"}
+
+	t.Run("note only", func(t *testing.T) {
+		svc, mock, cleanup := newCalendarService(t)
+		defer cleanup()
+
+		mock.ExpectPrepare("FROM room_block b").ExpectQuery().WithArgs(12, 10, 7).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow(12, 10, start, start.AddDate(0, 0, 3), entities.BlockReasonMaintenance, "", nil, "", now, now))
+		mock.ExpectPrepare("UPDATE room_block b").ExpectExec().
+			WithArgs(start.Format(entities.DateLayout), start.AddDate(0, 0, 3).Format(entities.DateLayout), entities.BlockReasonMaintenance, "New boiler", 12, 10, 7).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		note := " New boiler "
+		block, err := svc.UpdateBlock(context.Background(), 12, 10, 7, entities.RoomBlockPayload{Note: &note})
+		require.NoError(t, err)
+		assert.Equal(t, "New boiler", block.Note)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("imported", func(t *testing.T) {
+		svc, mock, cleanup := newCalendarService(t)
+		defer cleanup()
+
+		mock.ExpectPrepare("FROM room_block b").ExpectQuery().WithArgs(13, 10, 7).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow(13, 10, start, start.AddDate(0, 0, 3), entities.BlockReasonExternalBooking, "Reserved", 4, "abc@airbnb.com", now, now))
+
+		_, err := svc.UpdateBlock(context.Background(), 13, 10, 7, entities.RoomBlockPayload{})
+		assert.ErrorIs(t, err, entities.ErrBlockImported)
+
+		mock.ExpectPrepare("FROM room_block b").ExpectQuery().WithArgs(13, 10, 7).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow(13, 10, start, start.AddDate(0, 0, 3), entities.BlockReasonExternalBooking, "Reserved", 4, "abc@airbnb.com", now, now))
+
+		err = svc.DeleteBlock(context.Background(), 13, 10, 7)
+		assert.ErrorIs(t, err, entities.ErrBlockImported)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestCalendarService_Availability(t *testing.T) {
+	svc, mock, cleanup := newCalendarService(t)
+	defer cleanup()
+
+	from := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+	now := time.Now()
+
+	mock.ExpectPrepare("SELECT room_id, cost, currency").ExpectQuery().WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"room_id", "cost", "currency", "status", "vender_id", "created_at", "updated_at"}).
+			AddRow("10", 10000, "KES", "VACANT", "7", now, now))
+	mock.ExpectPrepare("UNION ALL").ExpectQuery().
+		WillReturnRows(sqlmock.NewRows([]string{"kind", "id", "start_date", "end_date", "status", "reason", "note", "calendar_id"}).
+			AddRow("booking", 100, from.AddDate(0, 0, -2), from.AddDate(0, 0, 2), entities.BookingStatusCheckedIn, "", "", nil).
+			AddRow("block", 3, from.AddDate(0, 0, 2), from.AddDate(0, 0, 4), nil, entities.BlockReasonMaintenance, "", nil).
+			AddRow("block", 4, from.AddDate(0, 0, 3), from.AddDate(0, 0, 5), nil, entities.BlockReasonExternalBooking, "", 4).
+			AddRow("booking", 101, from.AddDate(0, 0, 10), from.AddDate(0, 0, 12), entities.BookingStatusConfirmed, "", "", nil))
+
+	ranges, err := svc.Availability(context.Background(), 10, from, to)
+	require.NoError(t, err)
+	assert.Equal(t, []entities.DateRange{
+		{Start: from, End: from.AddDate(0, 0, 5)},
+		{Start: from.AddDate(0, 0, 10), End: from.AddDate(0, 0, 12)},
+	}, ranges)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCalendarService_VendorCalendar_NotOwner(t *testing.T) {
+	svc, mock, cleanup := newCalendarService(t)
+	defer cleanup()
+
+	now := time.Now()
+	mock.ExpectPrepare("SELECT room_id, cost, currency").ExpectQuery().WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"room_id", "cost", "currency", "status", "vender_id", "created_at", "updated_at"}).
+			AddRow("10", 10000, "KES", "VACANT", "7", now, now))
+
+	_, err := svc.VendorCalendar(context.Background(), 10, 8, now, now.AddDate(0, 1, 0))
+	assert.ErrorIs(t, err, entities.ErrNoRecord)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
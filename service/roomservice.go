@@ -2,16 +2,36 @@ package service
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
 
 	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/money"
 )
 
 func (rs *RoomService) CreateRoom(ctx context.Context, rp entities.RoomPayload) error {
-	err := rs.roomRepository.CreateRoom(ctx, rp)
+	cost, err := money.FromMajor(rp.Cost, rp.Currency)
 	if err != nil {
 		return err
 	}
 
+	room := entities.Room{
+		Cost:     cost,
+		Status:   rp.Status,
+		VenderId: strconv.Itoa(rp.Vendor),
+	}
+
+	id, err := rs.roomRepository.CreateRoom(ctx, room)
+	if err != nil {
+		return err
+	}
+
+	room.ID = strconv.Itoa(id)
+	recordAudit(ctx, rs.roomRepository, entities.AuditRoomCreate, "room", room.ID, rp.Vendor, nil,
+		map[string]any{"cost": room.Cost, "status": room.Status, "vender_id": room.VenderId})
+
 	return nil
 }
 
@@ -34,21 +54,85 @@ func (rs *RoomService) FindRooms(ctx context.Context) ([]*entities.Room, error)
 	return rooms, nil
 }
 
+// UpdateARoom sets the cost and status of one of the vendor's rooms and
+// records the change, such as a new price, in the audit log.
 func (rs *RoomService) UpdateARoom(ctx context.Context, data *entities.Room, roomID, vendorId int) error {
+	before, err := rs.ownRoom(ctx, roomID, vendorId)
+	if err != nil {
+		return err
+	}
 
-	err := rs.roomRepository.UpdateARoom(ctx, data, roomID, vendorId)
+	err = rs.roomRepository.UpdateARoom(ctx, data, roomID, vendorId)
 
 	if err != nil {
 		return err
 	}
 
+	if before != nil {
+		after := *before
+		after.Cost, after.Status = data.Cost, data.Status
+		recordAudit(ctx, rs.roomRepository, entities.AuditRoomUpdate, "room", before.ID, vendorId, before, after)
+	}
+
 	return nil
 }
 
 func (rs *RoomService) DeleteARoom(ctx context.Context, roomId, userId int) error {
-	err := rs.roomRepository.DeleteARoom(ctx, roomId, userId)
+	before, err := rs.ownRoom(ctx, roomId, userId)
+	if err != nil {
+		return err
+	}
+
+	err = rs.roomRepository.DeleteARoom(ctx, roomId, userId)
 	if err != nil {
 		return err
 	}
+
+	if before != nil {
+		recordAudit(ctx, rs.roomRepository, entities.AuditRoomDelete, "room", before.ID, userId, before, nil)
+	}
+
 	return nil
 }
+
+// ownRoom returns the room as it is before a change, or nil when it is not
+// the vendor's, in which case the change touches nothing.
+func (rs *RoomService) ownRoom(ctx context.Context, roomID, vendorID int) (*entities.Room, error) {
+	room, err := rs.roomRepository.FindRoomByID(ctx, roomID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if room.VenderId != strconv.Itoa(vendorID) {
+		return nil, nil
+	}
+
+	return room, nil
+}
+
+// FindAvailableRooms returns the rooms with no block overlapping the nights
+// from start up to, but not including, end.
+func (rs *RoomService) FindAvailableRooms(ctx context.Context, start, end time.Time) ([]*entities.Room, error) {
+	rooms, err := rs.roomRepository.AllRooms(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	blocked, err := rs.roomRepository.GetBlockedRoomIDs(ctx, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	available := make([]*entities.Room, 0, len(rooms))
+	for _, room := range rooms {
+		id, _ := strconv.Atoi(room.ID)
+		if !blocked[id] {
+			available = append(available, room)
+		}
+	}
+
+	return available, nil
+}
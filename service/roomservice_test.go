@@ -8,6 +8,7 @@ import (
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/money"
 	"github.com/bicosteve/booking-system/repo"
 	"github.com/go-redis/redismock/v9"
 	"github.com/stretchr/testify/assert"
@@ -31,14 +32,39 @@ func TestRoomService_CreateRoom(t *testing.T) {
 
 		mock.ExpectPrepare("INSERT INTO room").
 			ExpectExec().
-			WithArgs("100", "VACANT", 1).
+			WithArgs(int64(10000), "KES", "VACANT", "1").
 			WillReturnResult(sqlmock.NewResult(1, 1))
+		expectAudit(mock, entities.AuditRoomCreate, "1", 1)
 
 		err := svc.CreateRoom(context.Background(), entities.RoomPayload{Cost: "100", Status: "VACANT", Vendor: 1})
 		assert.NoError(t, err)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 
+	t.Run("zero decimal currency", func(t *testing.T) {
+		svc, mock, cleanup := newRoomService(t)
+		defer cleanup()
+
+		mock.ExpectPrepare("INSERT INTO room").
+			ExpectExec().
+			WithArgs(int64(7000), "JPY", "VACANT", "1").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		expectAudit(mock, entities.AuditRoomCreate, "1", 1)
+
+		err := svc.CreateRoom(context.Background(), entities.RoomPayload{Cost: "7000", Currency: "jpy", Status: "VACANT", Vendor: 1})
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("invalid cost", func(t *testing.T) {
+		svc, mock, cleanup := newRoomService(t)
+		defer cleanup()
+
+		err := svc.CreateRoom(context.Background(), entities.RoomPayload{Cost: "ten", Status: "VACANT", Vendor: 1})
+		assert.EqualError(t, err, `invalid amount "ten"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
 	t.Run("error", func(t *testing.T) {
 		svc, mock, cleanup := newRoomService(t)
 		defer cleanup()
@@ -57,11 +83,11 @@ func TestRoomService_FindARoom(t *testing.T) {
 		svc, mock, cleanup := newRoomService(t)
 		defer cleanup()
 
-		mock.ExpectPrepare("SELECT \\* FROM room WHERE room_id = ?").
+		mock.ExpectPrepare("SELECT room_id, cost, currency, status, vender_id, created_at, updated_at\\s+FROM room WHERE room_id = ?").
 			ExpectQuery().
 			WithArgs(1).
-			WillReturnRows(sqlmock.NewRows([]string{"id", "cost", "status", "vender_id", "created_at", "updated_at"}).
-				AddRow("1", 100.0, "VACANT", "2", mockTime, mockTime))
+			WillReturnRows(sqlmock.NewRows([]string{"id", "cost", "currency", "status", "vender_id", "created_at", "updated_at"}).
+				AddRow("1", 10000, "KES", "VACANT", "2", mockTime, mockTime))
 
 		room, err := svc.FindARoom(context.Background(), 1)
 		assert.NoError(t, err)
@@ -72,7 +98,7 @@ func TestRoomService_FindARoom(t *testing.T) {
 		svc, mock, cleanup := newRoomService(t)
 		defer cleanup()
 
-		mock.ExpectPrepare("SELECT \\* FROM room WHERE room_id = ?").
+		mock.ExpectPrepare("SELECT room_id, cost, currency, status, vender_id, created_at, updated_at\\s+FROM room WHERE room_id = ?").
 			ExpectQuery().
 			WithArgs(1).
 			WillReturnError(sql.ErrNoRows)
@@ -90,10 +116,10 @@ func TestRoomService_FindRooms(t *testing.T) {
 		svc, mock, cleanup := newRoomService(t)
 		defer cleanup()
 
-		mock.ExpectPrepare("SELECT \\* FROM room ORDER BY room_id DESC").
+		mock.ExpectPrepare("SELECT r.room_id, r.cost, r.currency, r.status, r.vender_id, r.created_at, r.updated_at,\\s+COALESCE\\(rv.rating, 0\\), COALESCE\\(rv.review_count, 0\\)").
 			ExpectQuery().
-			WillReturnRows(sqlmock.NewRows([]string{"id", "cost", "status", "vender_id", "created_at", "updated_at"}).
-				AddRow("1", 100.0, "VACANT", "1", mockTime, mockTime))
+			WillReturnRows(sqlmock.NewRows([]string{"id", "cost", "currency", "status", "vender_id", "created_at", "updated_at", "rating", "review_count"}).
+				AddRow("1", 10000, "KES", "VACANT", "1", mockTime, mockTime, 0, 0))
 
 		rooms, err := svc.FindRooms(context.Background())
 		assert.NoError(t, err)
@@ -104,7 +130,7 @@ func TestRoomService_FindRooms(t *testing.T) {
 		svc, mock, cleanup := newRoomService(t)
 		defer cleanup()
 
-		mock.ExpectPrepare("SELECT \\* FROM room ORDER BY room_id DESC").
+		mock.ExpectPrepare("SELECT r.room_id, r.cost, r.currency, r.status, r.vender_id, r.created_at, r.updated_at,\\s+COALESCE\\(rv.rating, 0\\), COALESCE\\(rv.review_count, 0\\)").
 			ExpectQuery().
 			WillReturnError(sql.ErrConnDone)
 
@@ -114,17 +140,72 @@ func TestRoomService_FindRooms(t *testing.T) {
 	})
 }
 
+func TestRoomService_FindAvailableRooms(t *testing.T) {
+	svc, mock, cleanup := newRoomService(t)
+	defer cleanup()
+
+	mockTime := time.Now()
+	start := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectPrepare("SELECT r.room_id, r.cost").
+		ExpectQuery().
+		WillReturnRows(sqlmock.NewRows([]string{"id", "cost", "currency", "status", "vender_id", "created_at", "updated_at", "rating", "review_count"}).
+			AddRow("1", 10000, "KES", "VACANT", "1", mockTime, mockTime, 0, 0).
+			AddRow("2", 12000, "KES", "VACANT", "1", mockTime, mockTime, 0, 0))
+	mock.ExpectPrepare("SELECT DISTINCT room_id FROM room_block").
+		ExpectQuery().
+		WithArgs("2026-11-03", "2026-11-01").
+		WillReturnRows(sqlmock.NewRows([]string{"room_id"}).AddRow(2))
+
+	rooms, err := svc.FindAvailableRooms(context.Background(), start, start.AddDate(0, 0, 2))
+	assert.NoError(t, err)
+	assert.Len(t, rooms, 1)
+	assert.Equal(t, "1", rooms[0].ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestRoomService_UpdateARoom(t *testing.T) {
+	mockTime := time.Now()
+
 	t.Run("success", func(t *testing.T) {
 		svc, mock, cleanup := newRoomService(t)
 		defer cleanup()
 
+		mock.ExpectPrepare("FROM room WHERE room_id = \\?").
+			ExpectQuery().
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "cost", "currency", "status", "vender_id", "created_at", "updated_at"}).
+				AddRow("1", 10000, "KES", "VACANT", "2", mockTime, mockTime))
 		mock.ExpectPrepare("UPDATE room SET cost").
 			ExpectExec().
-			WithArgs(150.0, "BOOKED", sqlmock.AnyArg(), 1, 2).
+			WithArgs(int64(15000), "KES", "BOOKED", sqlmock.AnyArg(), 1, 2).
 			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectPrepare("INSERT INTO audit_log").
+			ExpectExec().
+			WithArgs(entities.ActorSystem, nil, nil, 2, entities.AuditRoomUpdate, "room", "1",
+				`{"cost":{"amount":10000,"currency":"KES"},"status":"VACANT"}`, `{"cost":{"amount":15000,"currency":"KES"},"status":"BOOKED"}`, "", "").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		err := svc.UpdateARoom(context.Background(), &entities.Room{Cost: money.New(15000, "KES"), Status: "BOOKED"}, 1, 2)
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("not the vendor's room", func(t *testing.T) {
+		svc, mock, cleanup := newRoomService(t)
+		defer cleanup()
 
-		err := svc.UpdateARoom(context.Background(), &entities.Room{Cost: 150.0, Status: "BOOKED"}, 1, 2)
+		mock.ExpectPrepare("FROM room WHERE room_id = \\?").
+			ExpectQuery().
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "cost", "currency", "status", "vender_id", "created_at", "updated_at"}).
+				AddRow("1", 10000, "KES", "VACANT", "2", mockTime, mockTime))
+		mock.ExpectPrepare("UPDATE room SET cost").
+			ExpectExec().
+			WithArgs(int64(15000), "KES", "BOOKED", sqlmock.AnyArg(), 1, 3).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err := svc.UpdateARoom(context.Background(), &entities.Room{Cost: money.New(15000, "KES"), Status: "BOOKED"}, 1, 3)
 		assert.NoError(t, err)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
@@ -133,6 +214,9 @@ func TestRoomService_UpdateARoom(t *testing.T) {
 		svc, mock, cleanup := newRoomService(t)
 		defer cleanup()
 
+		mock.ExpectPrepare("FROM room WHERE room_id").
+			ExpectQuery().
+			WillReturnError(sql.ErrNoRows)
 		mock.ExpectPrepare("UPDATE room SET cost").WillReturnError(sql.ErrConnDone)
 
 		err := svc.UpdateARoom(context.Background(), &entities.Room{}, 1, 2)
@@ -141,14 +225,22 @@ func TestRoomService_UpdateARoom(t *testing.T) {
 }
 
 func TestRoomService_DeleteARoom(t *testing.T) {
+	mockTime := time.Now()
+
 	t.Run("success", func(t *testing.T) {
 		svc, mock, cleanup := newRoomService(t)
 		defer cleanup()
 
+		mock.ExpectPrepare("FROM room WHERE room_id = \\?").
+			ExpectQuery().
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "cost", "currency", "status", "vender_id", "created_at", "updated_at"}).
+				AddRow("1", 10000, "KES", "VACANT", "2", mockTime, mockTime))
 		mock.ExpectPrepare("DELETE FROM room WHERE room_id = \\? AND vender_id = \\?").
 			ExpectExec().
 			WithArgs(1, 2).
 			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectAudit(mock, entities.AuditRoomDelete, "1", 2)
 
 		err := svc.DeleteARoom(context.Background(), 1, 2)
 		assert.NoError(t, err)
@@ -159,7 +251,7 @@ func TestRoomService_DeleteARoom(t *testing.T) {
 		svc, mock, cleanup := newRoomService(t)
 		defer cleanup()
 
-		mock.ExpectPrepare("DELETE FROM room").WillReturnError(sql.ErrConnDone)
+		mock.ExpectPrepare("FROM room WHERE room_id").WillReturnError(sql.ErrConnDone)
 
 		err := svc.DeleteARoom(context.Background(), 1, 2)
 		assert.Error(t, err)
@@ -1,9 +1,27 @@
 package service
 
-import "github.com/bicosteve/booking-system/repo"
+import (
+	"net/http"
+	"time"
+
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/oidc"
+	"github.com/bicosteve/booking-system/pkg/safehttp"
+	"github.com/bicosteve/booking-system/pkg/webhook"
+	"github.com/bicosteve/booking-system/repo"
+)
 
 type UserService struct {
 	userRepository repo.Repository
+	lockout        lockoutPolicy
+}
+
+// lockoutPolicy locks an account for baseDelay after threshold failed logins
+// in a row, doubling the lock on every further failure up to maxDelay.
+type lockoutPolicy struct {
+	threshold int
+	baseDelay time.Duration
+	maxDelay  time.Duration
 }
 
 type RoomService struct {
@@ -18,8 +36,67 @@ type PaymentService struct {
 	paymentRepository repo.Repository
 }
 
-func NewUserService(userRepository repo.Repository) *UserService {
-	return &UserService{userRepository: userRepository}
+type ReviewService struct {
+	reviewRepository repo.Repository
+}
+
+type EventService struct {
+	eventRepository repo.Repository
+}
+
+type WebhookService struct {
+	webhookRepository repo.Repository
+	sender            *webhook.Sender
+	maxAttempts       int
+	lease             time.Duration // how long a worker holds the deliveries it claimed
+}
+
+type CalendarService struct {
+	calendarRepository repo.Repository
+	client             *http.Client
+}
+
+// OIDCService logs users in with external OpenID Connect providers, keyed
+// by provider name.
+type OIDCService struct {
+	oidcRepository repo.Repository
+	users          *UserService
+	providers      map[string]*oidc.Provider
+}
+
+type APIKeyService struct {
+	apiKeyRepository repo.Repository
+}
+
+type AuditService struct {
+	auditRepository repo.Repository
+}
+
+type LedgerService struct {
+	ledgerRepository repo.Repository
+	commissionBps    int
+	minimumPayout    int64
+}
+
+// NewUserService locks accounts after cfg.Threshold failed logins (default
+// 5) for cfg.BaseDelay (default 1m), doubling up to cfg.MaxDelay (default 1h).
+func NewUserService(userRepository repo.Repository, cfg entities.LockoutConfig) *UserService {
+	policy := lockoutPolicy{threshold: cfg.Threshold, baseDelay: time.Minute, maxDelay: time.Hour}
+	if policy.threshold <= 0 {
+		policy.threshold = 5
+	}
+
+	d, err := time.ParseDuration(cfg.BaseDelay)
+	if err == nil && d > 0 {
+		policy.baseDelay = d
+	}
+
+	d, err = time.ParseDuration(cfg.MaxDelay)
+	if err == nil && d > 0 {
+		policy.maxDelay = d
+	}
+
+	return &UserService{userRepository: userRepository, lockout: policy}
 }
 
 func NewRoomService(roomRepository repo.Repository) *RoomService {
@@ -34,3 +111,75 @@ func NewBookingService(bookingRepository repo.Repository) *BookingService {
 func NewPaymentService(paymentRepository repo.Repository) *PaymentService {
 	return &PaymentService{paymentRepository: paymentRepository}
 }
+
+func NewReviewService(reviewRepository repo.Repository) *ReviewService {
+	return &ReviewService{reviewRepository: reviewRepository}
+}
+
+func NewEventService(eventRepository repo.Repository) *EventService {
+	return &EventService{eventRepository: eventRepository}
+}
+
+// NewWebhookService sends deliveries with cfg's timeout and gives up on a
+// delivery after cfg.MaxAttempts attempts (default 8).
+func NewWebhookService(webhookRepository repo.Repository, cfg entities.WebhookConfig) *WebhookService {
+	timeout, err := time.ParseDuration(cfg.Timeout)
+	if err != nil || timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	maxAttempts := cfg.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 8
+	}
+
+	return &WebhookService{
+		webhookRepository: webhookRepository,
+		sender:            webhook.NewSender(timeout, cfg.AllowPrivate),
+		maxAttempts:       maxAttempts,
+		lease:             timeout*webhookBatch + time.Minute,
+	}
+}
+
+// NewCalendarService fetches external calendars with cfg's timeout
+// (default 20s).
+func NewCalendarService(calendarRepository repo.Repository, cfg entities.CalendarConfig) *CalendarService {
+	timeout, err := time.ParseDuration(cfg.Timeout)
+	if err != nil || timeout <= 0 {
+		timeout = 20 * time.Second
+	}
+
+	return &CalendarService{
+		calendarRepository: calendarRepository,
+		client:             safehttp.NewClient(timeout, cfg.AllowPrivate),
+	}
+}
+
+func NewLedgerService(ledgerRepository repo.Repository, cfg entities.PayoutConfig) *LedgerService {
+	return &LedgerService{
+		ledgerRepository: ledgerRepository,
+		commissionBps:    cfg.CommissionBps,
+		minimumPayout:    cfg.Minimum,
+	}
+}
+
+// NewOIDCService sets up a provider for each config. Logins end with a token
+// from users, so they get the same two-factor step as password logins.
+func NewOIDCService(oidcRepository repo.Repository, users *UserService, configs []entities.OIDCConfig) *OIDCService {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	providers := map[string]*oidc.Provider{}
+	for _, c := range configs {
+		providers[c.Name] = oidc.NewProvider(c, client)
+	}
+
+	return &OIDCService{oidcRepository: oidcRepository, users: users, providers: providers}
+}
+
+func NewAPIKeyService(apiKeyRepository repo.Repository) *APIKeyService {
+	return &APIKeyService{apiKeyRepository: apiKeyRepository}
+}
+
+func NewAuditService(auditRepository repo.Repository) *AuditService {
+	return &AuditService{auditRepository: auditRepository}
+}
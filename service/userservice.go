@@ -4,9 +4,13 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"log/slog"
 	"strconv"
+	"time"
 
 	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/jwtkeys"
+	"github.com/bicosteve/booking-system/pkg/metrics"
 	"github.com/bicosteve/booking-system/pkg/utils"
 )
 
@@ -28,32 +32,138 @@ func (s *UserService) SubmitRegistrationRequest(ctx context.Context, data entiti
 	return nil
 }
 
-func (s *UserService) SubmitLoginRequest(ctx context.Context, data entities.UserPayload, secret string) (string, error) {
+// SubmitLoginRequest returns an auth token for valid credentials, or an
+// empty token when the password is wrong. For users with two-factor on it
+// returns a pending token instead, with mfaPending set, to be completed by
+// CompleteMFALogin. After too many failures in a row the account is locked
+// and a *entities.LockoutError is returned until the lock expires, even for
+// the right password.
+func (s *UserService) SubmitLoginRequest(ctx context.Context, data entities.UserPayload, keys *jwtkeys.Keyring) (token string, mfaPending bool, err error) {
+	wait, err := s.userRepository.GetLoginLock(ctx, data.Email)
+	if err != nil {
+		slog.WarnContext(ctx, "checking login lock failed", "error", err)
+	}
+
+	if wait > 0 {
+		return "", false, &entities.LockoutError{RetryAfter: wait}
+	}
+
 	isAvailable, err := s.userRepository.FindUserByEmail(ctx, data.Email)
 	if err != nil {
-		return "", err
+		return "", false, err
 	}
 
 	if !isAvailable {
-		return "", errors.New("user is not available")
+		lockErr := s.loginFailed(ctx, data.Email)
+		if lockErr != nil {
+			return "", false, lockErr
+		}
+		return "", false, errors.New("user is not available")
 	}
 
 	user, err := s.userRepository.FindAProfile(ctx, data.Email)
 	if err != nil {
-		return "", err
+		return "", false, err
 	}
 
 	isValid := utils.ComparePasswordWithHash(data.Password, &user.Password)
 	if !isValid {
-		return "", err
+		return "", false, s.loginFailed(ctx, data.Email)
 	}
 
-	token, err := utils.GenerateAuthToken(*user, secret)
+	return s.issueLoginToken(ctx, user, keys)
+}
+
+// issueLoginToken finishes a login for user once their first factor is
+// checked: an auth token, or a pending token if two-factor is on.
+func (s *UserService) issueLoginToken(ctx context.Context, user *entities.User, keys *jwtkeys.Keyring) (string, bool, error) {
+	mfa, err := s.enabledMFA(ctx, user.ID)
 	if err != nil {
-		return "", err
+		return "", false, err
+	}
+
+	// Failures are only cleared once the second factor is passed too.
+	if mfa != nil {
+		token, err := utils.GenerateMFAPendingToken(*user, keys)
+		if err != nil {
+			return "", false, err
+		}
+
+		return token, true, nil
+	}
+
+	err = s.userRepository.ClearLoginFailures(ctx, user.Email)
+	if err != nil {
+		slog.WarnContext(ctx, "clearing failed logins failed", "error", err)
+	}
+
+	token, err := utils.GenerateAuthToken(*user, keys)
+	if err != nil {
+		return "", false, err
 	}
 
-	return token, nil
+	return token, false, nil
+}
+
+// loginFailed counts a failed login for email and locks the account once
+// the failures reach the threshold. It returns a *entities.LockoutError when
+// this failure locked it.
+func (s *UserService) loginFailed(ctx context.Context, email string) error {
+	failures, err := s.userRepository.RecordFailedLogin(ctx, email, 24*time.Hour)
+	if err != nil {
+		slog.WarnContext(ctx, "recording failed login failed", "error", err)
+		return nil
+	}
+
+	if failures < s.lockout.threshold {
+		return nil
+	}
+
+	wait := s.lockout.delay(failures)
+
+	err = s.userRepository.LockLogin(ctx, email, wait)
+	if err != nil {
+		slog.WarnContext(ctx, "locking account failed", "error", err)
+		return nil
+	}
+
+	metrics.LoginLockouts.Inc()
+	slog.WarnContext(ctx, "account locked after failed logins", "failures", failures, "locked_for", wait.String())
+
+	return &entities.LockoutError{RetryAfter: wait}
+}
+
+// delay is how long to lock an account after failures failed logins.
+func (p lockoutPolicy) delay(failures int) time.Duration {
+	shift := failures - p.threshold
+	if shift > 20 {
+		return p.maxDelay
+	}
+
+	return min(p.baseDelay<<shift, p.maxDelay)
+}
+
+// UnlockAccount lifts a login lock and resets the failed login count,
+// recording who unlocked which account in the audit log. Logins for unknown
+// emails are locked too, so the entry falls back to the email when no user
+// has it.
+func (s *UserService) UnlockAccount(ctx context.Context, email string) error {
+	err := s.userRepository.ClearLoginFailures(ctx, email)
+	if err != nil {
+		return err
+	}
+
+	target := map[string]any{"email": email}
+	entityID := email
+
+	user, err := s.userRepository.FindAProfile(ctx, email)
+	if err == nil {
+		target["user_id"] = user.ID
+		entityID = user.ID
+	}
+
+	recordAudit(ctx, s.userRepository, entities.AuditUserUnlock, "user", entityID, 0, nil, target)
+	return nil
 }
 
 func (s *UserService) SubmitProfileRequest(ctx context.Context, email string) (*entities.User, error) {
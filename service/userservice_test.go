@@ -8,11 +8,14 @@ import (
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/jwtkeys"
 	"github.com/bicosteve/booking-system/repo"
 	"github.com/go-redis/redismock/v9"
 	"github.com/stretchr/testify/assert"
 )
 
+var testKeys = jwtkeys.NewHMAC("secret")
+
 func TestSubmitRegistrationRequest(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -91,7 +94,7 @@ func TestSubmitRegistrationRequest(t *testing.T) {
 
 			tt.setupMock(mock)
 			repository := *repo.NewDBRepository(db, _db)
-			service := NewUserService(repository)
+			service := NewUserService(repository, entities.LockoutConfig{})
 
 			err = service.SubmitRegistrationRequest(context.Background(), tt.payload)
 
@@ -137,18 +140,24 @@ func TestSubmitLoginRequest(t *testing.T) {
 					WithArgs("test@gmail.com").
 					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
 
-				mock.ExpectPrepare("SELECT \\* FROM user").
+				mock.ExpectPrepare("SELECT user_id, email, phone_number").
 					ExpectQuery().
 					WithArgs("test@gmail.com").
 					WillReturnRows(sqlmock.NewRows([]string{
 						"id", "email", "phone_number", "isVender",
 						"password", "password_reset_token",
 						"created_at", "updated_at", "password_inserted_at",
+						"name", "phone_verified_at",
 					}).AddRow(
 						"1", "test@gmail.com", "0704961755", "NO",
 						"$2a$10$/r5qIMP1AkNOMdr495Ff0eCdrZWyW79Q5E3RxFVgCbk0ret4j4mDa", "",
-						mockTime, mockTime, mockTime,
+						mockTime, mockTime, mockTime, "", nil,
 					))
+
+				mock.ExpectPrepare("FROM user_mfa").
+					ExpectQuery().
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"user_id", "secret", "enabled_at", "last_step"}))
 			},
 			wantErr: false,
 		},
@@ -183,9 +192,9 @@ func TestSubmitLoginRequest(t *testing.T) {
 			_db, _ := redismock.NewClientMock()
 
 			repository := *repo.NewDBRepository(db, _db)
-			service := NewUserService(repository)
+			service := NewUserService(repository, entities.LockoutConfig{})
 
-			token, err := service.SubmitLoginRequest(context.Background(), tt.payload, tt.secret)
+			token, _, err := service.SubmitLoginRequest(context.Background(), tt.payload, jwtkeys.NewHMAC(tt.secret))
 
 			if tt.wantErr {
 				assert.Error(t, err)
@@ -235,16 +244,17 @@ func TestSubmitProfileRequest(t *testing.T) {
 			name:  "successful profile retrieval",
 			email: "test@gmail.com",
 			setupMock: func(m sqlmock.Sqlmock) {
-				m.ExpectPrepare("SELECT \\* FROM user WHERE email = ?").
+				m.ExpectPrepare("SELECT user_id, email, phone_number").
 					ExpectQuery().
 					WithArgs("test@gmail.com").
 					WillReturnRows(sqlmock.NewRows([]string{
 						"id", "email", "phone_number", "isVender",
 						"password", "password_reset_token",
 						"created_at", "updated_at", "password_inserted_at",
+						"name", "phone_verified_at",
 					}).AddRow(
 						"1", "test@gmail.com", "0704961755", "NO",
-						"$2a$10$/r5qIMP1AkNOMdr495Ff0eCdrZWyW79Q5E3RxFVgCbk0ret4j4mDa", "", tCreated, tUpdated, tPassword,
+						"$2a$10$/r5qIMP1AkNOMdr495Ff0eCdrZWyW79Q5E3RxFVgCbk0ret4j4mDa", "", tCreated, tUpdated, tPassword, "", nil,
 					))
 			},
 			want:    mockUser,
@@ -254,7 +264,7 @@ func TestSubmitProfileRequest(t *testing.T) {
 			name:  "profile not found",
 			email: "nonexistent@example.com",
 			setupMock: func(m sqlmock.Sqlmock) {
-				m.ExpectPrepare("SELECT \\* FROM user").
+				m.ExpectPrepare("SELECT user_id, email, phone_number").
 					ExpectQuery().
 					WithArgs("nonexistent@example.com").
 					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
@@ -278,7 +288,7 @@ func TestSubmitProfileRequest(t *testing.T) {
 			_db, _ := redismock.NewClientMock()
 
 			repository := *repo.NewDBRepository(db, _db)
-			service := NewUserService(repository)
+			service := NewUserService(repository, entities.LockoutConfig{})
 
 			user, err := service.SubmitProfileRequest(context.Background(), tt.email)
 
@@ -321,7 +331,7 @@ func TestInsertPasswordResetToken(t *testing.T) {
 		// 			WithArgs("tokens", time.Now(), "test@gmail.com").
 		// 			WillReturnResult(sqlmock.NewResult(1, 1))
 
-		// 		// m.ExpectPrepare("SELECT * FROM user WHERE email = ?").
+		// 		// m.ExpectPrepare("SELECT user_id, email, phone_number, isVender, hashed_password, password_reset_token, created_at, updated_at, password_inserted_at, name, phone_verified_at FROM user WHERE email = ?").
 		// 		// 	ExpectQuery().
 		// 		// 	WithArgs("test@gmail.com").
 		// 		// 	WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
@@ -369,7 +379,7 @@ func TestInsertPasswordResetToken(t *testing.T) {
 			tt.setupMock(mock)
 
 			repository := *repo.NewDBRepository(db, _db)
-			service := NewUserService(repository)
+			service := NewUserService(repository, entities.LockoutConfig{})
 
 			_, err = service.InsertPasswordResetToken(context.Background(), db, tt.user)
 
@@ -389,3 +399,112 @@ func TestInsertPasswordResetToken(t *testing.T) {
 		})
 	}
 }
+
+func TestSubmitLoginRequest_Locked(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	client, redisMock := redismock.NewClientMock()
+	redisMock.ExpectPTTL("login:lock:test@gmail.com").SetVal(45 * time.Second)
+
+	service := NewUserService(*repo.NewDBRepository(db, client), entities.LockoutConfig{})
+
+	// The right password does not get past a lock.
+	token, _, err := service.SubmitLoginRequest(context.Background(), entities.UserPayload{Email: "test@gmail.com", Password: "1234"}, testKeys)
+	assert.Empty(t, token)
+
+	var lockErr *entities.LockoutError
+	assert.ErrorAs(t, err, &lockErr)
+	assert.Equal(t, 45*time.Second, lockErr.RetryAfter)
+	assert.ErrorIs(t, err, entities.ErrAccountLocked)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.NoError(t, redisMock.ExpectationsWereMet())
+}
+
+func TestSubmitLoginRequest_LocksAfterThreshold(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectPrepare("SELECT COUNT.* FROM user").ExpectQuery().WithArgs("nobody@gmail.com").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+	client, redisMock := redismock.NewClientMock()
+	redisMock.ExpectPTTL("login:lock:nobody@gmail.com").SetVal(-2)
+	redisMock.ExpectIncr("login:failures:nobody@gmail.com").SetVal(4)
+	redisMock.ExpectExpire("login:failures:nobody@gmail.com", 24*time.Hour).SetVal(true)
+	redisMock.ExpectSet("login:lock:nobody@gmail.com", 1, 2*time.Minute).SetVal("OK")
+
+	service := NewUserService(*repo.NewDBRepository(db, client), entities.LockoutConfig{Threshold: 3, BaseDelay: "1m", MaxDelay: "10m"})
+
+	_, _, err = service.SubmitLoginRequest(context.Background(), entities.UserPayload{Email: "nobody@gmail.com", Password: "1234"}, testKeys)
+
+	var lockErr *entities.LockoutError
+	assert.ErrorAs(t, err, &lockErr)
+	assert.Equal(t, 2*time.Minute, lockErr.RetryAfter)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.NoError(t, redisMock.ExpectationsWereMet())
+}
+
+func TestLockoutPolicyDelay(t *testing.T) {
+	p := lockoutPolicy{threshold: 5, baseDelay: time.Minute, maxDelay: time.Hour}
+
+	assert.Equal(t, time.Minute, p.delay(5))
+	assert.Equal(t, 2*time.Minute, p.delay(6))
+	assert.Equal(t, 32*time.Minute, p.delay(10))
+	assert.Equal(t, time.Hour, p.delay(11))
+	assert.Equal(t, time.Hour, p.delay(500))
+}
+
+func TestUnlockAccount(t *testing.T) {
+	t.Run("records the platform admin and the account", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		now := time.Now()
+		client, redisMock := redismock.NewClientMock()
+		redisMock.ExpectDel("login:failures:test@gmail.com", "login:lock:test@gmail.com").SetVal(2)
+		mock.ExpectPrepare("FROM user WHERE email = \\?").ExpectQuery().WithArgs("test@gmail.com").
+			WillReturnRows(sqlmock.NewRows(profileColumns).
+				AddRow("5", "test@gmail.com", "0704961755", "NO", "hash", "", now, now, now, "", nil))
+		mock.ExpectPrepare("INSERT INTO audit_log").
+			ExpectExec().
+			WithArgs(entities.ActorPlatformAdmin, 1, nil, nil, entities.AuditUserUnlock, "user", "5",
+				nil, `{"email":"test@gmail.com","user_id":"5"}`, "", "").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		service := NewUserService(*repo.NewDBRepository(db, client), entities.LockoutConfig{})
+
+		ctx := context.WithValue(context.Background(), entities.UseridKeyValue, "1")
+		ctx = context.WithValue(ctx, entities.PlatformAdminKeyValue, true)
+		err = service.UnlockAccount(ctx, "test@gmail.com")
+		assert.NoError(t, err)
+		assert.NoError(t, redisMock.ExpectationsWereMet())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("no account has the email", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		client, redisMock := redismock.NewClientMock()
+		redisMock.ExpectDel("login:failures:test@gmail.com", "login:lock:test@gmail.com").SetVal(2)
+		mock.ExpectPrepare("FROM user WHERE email = \\?").ExpectQuery().WithArgs("test@gmail.com").
+			WillReturnRows(sqlmock.NewRows(profileColumns))
+		mock.ExpectPrepare("INSERT INTO audit_log").
+			ExpectExec().
+			WithArgs(entities.ActorSystem, nil, nil, nil, entities.AuditUserUnlock, "user", "test@gmail.com",
+				nil, `{"email":"test@gmail.com"}`, "", "").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		service := NewUserService(*repo.NewDBRepository(db, client), entities.LockoutConfig{})
+
+		err = service.UnlockAccount(context.Background(), "test@gmail.com")
+		assert.NoError(t, err)
+		assert.NoError(t, redisMock.ExpectationsWereMet())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,287 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/events"
+	"github.com/bicosteve/booking-system/pkg/metrics"
+	"github.com/bicosteve/booking-system/pkg/webhook"
+)
+
+// WebhookEvents are the event types vendors can subscribe to.
+var WebhookEvents = []string{
+	events.BookingCreated,
+	events.BookingConfirmed,
+	events.BookingCancelled,
+	events.BookingCheckedOut,
+}
+
+// webhookBatch caps the deliveries sent per DeliverDue run.
+const webhookBatch = 50
+
+// CreateWebhook subscribes url to the vendor's booking events. The returned
+// webhook carries the signing secret, which is not shown again.
+func (ws *WebhookService) CreateWebhook(ctx context.Context, vendorID int, data entities.WebhookPayload) (*entities.Webhook, error) {
+	if data.URL == nil {
+		return nil, fmt.Errorf("%w: url is required", entities.ErrInvalidWebhook)
+	}
+
+	w := &entities.Webhook{VendorID: vendorID, URL: strings.TrimSpace(*data.URL), Events: data.Events, Active: true}
+	if data.Active != nil {
+		w.Active = *data.Active
+	}
+
+	err := validateWebhook(w)
+	if err != nil {
+		return nil, err
+	}
+
+	w.Secret, err = webhook.NewSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	w.ID, err = ws.webhookRepository.CreateWebhook(ctx, w)
+	if err != nil {
+		return nil, err
+	}
+
+	w.CreatedAt = time.Now()
+	w.UpdatedAt = w.CreatedAt
+
+	return w, nil
+}
+
+// UpdateWebhook changes the fields set in data on one of the vendor's
+// webhooks.
+func (ws *WebhookService) UpdateWebhook(ctx context.Context, vendorID, webhookID int, data entities.WebhookPayload) (*entities.Webhook, error) {
+	w, err := ws.webhookRepository.GetWebhook(ctx, webhookID, vendorID)
+	if err != nil {
+		return nil, err
+	}
+
+	if data.URL != nil {
+		w.URL = strings.TrimSpace(*data.URL)
+	}
+	if data.Events != nil {
+		w.Events = data.Events
+	}
+	if data.Active != nil {
+		w.Active = *data.Active
+	}
+
+	err = validateWebhook(w)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ws.webhookRepository.UpdateWebhook(ctx, w)
+	if err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func validateWebhook(w *entities.Webhook) error {
+	err := webhook.ValidateURL(w.URL)
+	if err != nil {
+		return fmt.Errorf("%w: %v", entities.ErrInvalidWebhook, err)
+	}
+
+	if w.Events == nil {
+		w.Events = []string{}
+	}
+
+	for _, e := range w.Events {
+		if !slices.Contains(WebhookEvents, e) {
+			return fmt.Errorf("%w: unknown event %q, expected one of %s", entities.ErrInvalidWebhook, e, strings.Join(WebhookEvents, ", "))
+		}
+	}
+
+	return nil
+}
+
+func (ws *WebhookService) GetWebhooks(ctx context.Context, vendorID int) ([]*entities.Webhook, error) {
+	return ws.webhookRepository.GetVendorWebhooks(ctx, vendorID)
+}
+
+func (ws *WebhookService) DeleteWebhook(ctx context.Context, vendorID, webhookID int) error {
+	return ws.webhookRepository.DeleteWebhook(ctx, webhookID, vendorID)
+}
+
+// GetDeliveries returns the latest deliveries of one of the vendor's
+// webhooks.
+func (ws *WebhookService) GetDeliveries(ctx context.Context, vendorID, webhookID, limit int) ([]*entities.WebhookDelivery, error) {
+	_, err := ws.webhookRepository.GetWebhook(ctx, webhookID, vendorID)
+	if err != nil {
+		return nil, err
+	}
+
+	return ws.webhookRepository.GetWebhookDeliveries(ctx, webhookID, vendorID, limit)
+}
+
+// Enqueue queues e for every webhook of the room's vendor subscribed to it.
+// Events other than booking events are ignored. It returns how many
+// deliveries were queued.
+func (ws *WebhookService) Enqueue(ctx context.Context, e events.Envelope) (int, error) {
+	if !slices.Contains(WebhookEvents, e.Type) {
+		return 0, nil
+	}
+
+	var booking events.BookingEvent
+	err := e.Decode(&booking)
+	if err != nil {
+		return 0, err
+	}
+
+	webhooks, err := ws.webhookRepository.GetRoomWebhooks(ctx, booking.RoomID)
+	if err != nil {
+		return 0, err
+	}
+
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return 0, err
+	}
+
+	var deliveries []entities.WebhookDelivery
+	for _, w := range webhooks {
+		if len(w.Events) > 0 && !slices.Contains(w.Events, e.Type) {
+			continue
+		}
+
+		deliveries = append(deliveries, entities.WebhookDelivery{
+			WebhookID: w.ID,
+			EventID:   e.ID,
+			EventType: e.Type,
+			Payload:   string(payload),
+		})
+	}
+
+	if len(deliveries) == 0 {
+		return 0, nil
+	}
+
+	err = ws.webhookRepository.CreateWebhookDeliveries(ctx, deliveries)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(deliveries), nil
+}
+
+// DeliverDue claims and sends the deliveries whose next attempt is due, so
+// several instances can run it at once without sending one twice. Failed
+// attempts are retried with exponential backoff until maxAttempts is
+// reached, after which the delivery is marked FAILED. It returns how many
+// were delivered.
+func (ws *WebhookService) DeliverDue(ctx context.Context) (int, error) {
+	due, err := ws.webhookRepository.ClaimDueWebhookDeliveries(ctx, webhookBatch, ws.lease)
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	for _, d := range due {
+		// The lease retries a delivery whose outcome could not be recorded,
+		// so the rest of the batch still goes out.
+		err = ws.attempt(ctx, d, true)
+		if err != nil {
+			slog.ErrorContext(ctx, "recording webhook delivery failed", "delivery_id", d.ID, "error", err)
+			continue
+		}
+
+		if d.Status == entities.WebhookDeliverySucceeded {
+			delivered++
+		}
+	}
+
+	return delivered, nil
+}
+
+// Redeliver sends a delivery of one of the vendor's webhooks again right
+// away, whatever its status. A failed manual attempt is not retried.
+func (ws *WebhookService) Redeliver(ctx context.Context, vendorID, webhookID, deliveryID int) (*entities.WebhookDelivery, error) {
+	d, err := ws.webhookRepository.GetWebhookDelivery(ctx, deliveryID, webhookID, vendorID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ws.attempt(ctx, d, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// attempt sends d once and records the outcome on it.
+func (ws *WebhookService) attempt(ctx context.Context, d *entities.WebhookDelivery, retry bool) error {
+	resp, err := ws.sender.Send(ctx, webhook.Request{
+		URL:        d.URL,
+		Secret:     d.Secret,
+		DeliveryID: strconv.Itoa(d.ID),
+		EventType:  d.EventType,
+		Body:       []byte(d.Payload),
+	})
+
+	d.Attempts++
+	d.ResponseCode = nil
+	d.LastError = ""
+
+	switch {
+	case err != nil:
+		d.LastError = truncate(err.Error(), 255)
+	case !resp.OK():
+		code := resp.StatusCode
+		d.ResponseCode = &code
+		d.LastError = fmt.Sprintf("HTTP %d", code)
+		if resp.Body != "" {
+			d.LastError = truncate(d.LastError+": "+resp.Body, 255)
+		}
+	default:
+		code := resp.StatusCode
+		d.ResponseCode = &code
+	}
+
+	var retryIn time.Duration
+	switch {
+	case err == nil && resp.OK():
+		d.Status = entities.WebhookDeliverySucceeded
+	case retry && d.Attempts < ws.maxAttempts:
+		d.Status = entities.WebhookDeliveryPending
+		retryIn = webhook.Backoff(d.Attempts)
+	default:
+		d.Status = entities.WebhookDeliveryFailed
+	}
+
+	metrics.WebhookDeliveries.WithLabelValues(d.EventType, strings.ToLower(d.Status)).Inc()
+	if d.Status != entities.WebhookDeliverySucceeded {
+		slog.WarnContext(ctx, "webhook delivery failed", "delivery_id", d.ID, "webhook_id", d.WebhookID,
+			"event_type", d.EventType, "attempts", d.Attempts, "status", d.Status, "error", d.LastError)
+	}
+
+	return ws.webhookRepository.RecordWebhookAttempt(ctx, d, retryIn)
+}
+
+// truncate cuts s to at most n bytes without splitting a character.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/bicosteve/booking-system/entities"
+	"github.com/bicosteve/booking-system/pkg/events"
+	"github.com/bicosteve/booking-system/pkg/webhook"
+	"github.com/bicosteve/booking-system/repo"
+	"github.com/go-redis/redismock/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testWebhookColumns  = []string{"webhook_id", "vendor_id", "url", "events", "active", "created_at", "updated_at"}
+	testDeliveryColumns = []string{"delivery_id", "webhook_id", "event_id", "event_type", "payload", "status", "attempts",
+		"response_code", "last_error", "next_attempt_at", "delivered_at", "created_at", "updated_at", "url", "secret"}
+)
+
+func newWebhookService(t *testing.T, maxAttempts int) (*WebhookService, sqlmock.Sqlmock, func()) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock db: %v", err)
+	}
+	rdb, _ := redismock.NewClientMock()
+	repository := *repo.NewDBRepository(db, rdb)
+	cfg := entities.WebhookConfig{Timeout: "1s", MaxAttempts: maxAttempts, AllowPrivate: true}
+	return NewWebhookService(repository, cfg), mock, func() { db.Close() }
+}
+
+func TestWebhookService_CreateWebhook(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		svc, mock, cleanup := newWebhookService(t, 0)
+		defer cleanup()
+
+		mock.ExpectPrepare("INSERT INTO webhook").ExpectExec().
+			WithArgs(7, "https://pms.example.com/hooks", sqlmock.AnyArg(), "booking.created", true).
+			WillReturnResult(sqlmock.NewResult(3, 1))
+
+		url := " https://pms.example.com/hooks "
+		w, err := svc.CreateWebhook(context.Background(), 7, entities.WebhookPayload{URL: &url, Events: []string{events.BookingCreated}})
+		require.NoError(t, err)
+		assert.Equal(t, 3, w.ID)
+		assert.Equal(t, "https://pms.example.com/hooks", w.URL)
+		assert.Contains(t, w.Secret, "whsec_")
+		assert.True(t, w.Active)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("missing url", func(t *testing.T) {
+		svc, _, cleanup := newWebhookService(t, 0)
+		defer cleanup()
+
+		_, err := svc.CreateWebhook(context.Background(), 7, entities.WebhookPayload{})
+		assert.ErrorIs(t, err, entities.ErrInvalidWebhook)
+	})
+
+	t.Run("unknown event", func(t *testing.T) {
+		svc, _, cleanup := newWebhookService(t, 0)
+		defer cleanup()
+
+		url := "https://pms.example.com/hooks"
+		_, err := svc.CreateWebhook(context.Background(), 7, entities.WebhookPayload{URL: &url, Events: []string{events.PaymentSucceeded}})
+		assert.ErrorIs(t, err, entities.ErrInvalidWebhook)
+	})
+}
+
+func TestWebhookService_Enqueue(t *testing.T) {
+	e, err := events.New(events.BookingCreated, events.BookingEvent{BookingID: 100, RoomID: 10, UserID: 5, Status: "pending", Days: 2})
+	require.NoError(t, err)
+
+	t.Run("filters on subscribed events", func(t *testing.T) {
+		svc, mock, cleanup := newWebhookService(t, 0)
+		defer cleanup()
+
+		now := time.Now()
+		mock.ExpectPrepare("FROM webhook w").ExpectQuery().WithArgs(10).
+			WillReturnRows(sqlmock.NewRows(testWebhookColumns).
+				AddRow(3, 7, "https://a.example.com", "", true, now, now).
+				AddRow(4, 7, "https://b.example.com", "booking.cancelled", true, now, now).
+				AddRow(5, 7, "https://c.example.com", "booking.created,booking.cancelled", true, now, now))
+		mock.ExpectBegin()
+		prep := mock.ExpectPrepare("INSERT IGNORE INTO webhook_delivery")
+		prep.ExpectExec().WithArgs(3, e.ID, events.BookingCreated, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+		prep.ExpectExec().WithArgs(5, e.ID, events.BookingCreated, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(2, 1))
+		mock.ExpectCommit()
+
+		n, err := svc.Enqueue(context.Background(), e)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, n)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("ignores payment events", func(t *testing.T) {
+		svc, mock, cleanup := newWebhookService(t, 0)
+		defer cleanup()
+
+		p, err := events.New(events.PaymentRefunded, events.PaymentEvent{OrderID: "o-1", TrxID: "pi_1", RoomID: 10, UserID: 5, Amount: 100, Currency: "KES"})
+		require.NoError(t, err)
+
+		n, err := svc.Enqueue(context.Background(), p)
+		assert.NoError(t, err)
+		assert.Zero(t, n)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+// expectClaim expects DeliverDue to lease rows for the default 1s timeout.
+func expectClaim(mock sqlmock.Sqlmock, rows *sqlmock.Rows) {
+	mock.ExpectBegin()
+	mock.ExpectQuery("WHERE d.status = 'PENDING'(.|\\s)+SKIP LOCKED").WithArgs(webhookBatch).WillReturnRows(rows)
+	mock.ExpectExec("UPDATE webhook_delivery SET next_attempt_at").WithArgs(110, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+func TestWebhookService_DeliverDue(t *testing.T) {
+	status := http.StatusOK
+	var signature string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature = r.Header.Get(webhook.SignatureHeader)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	dueRow := func(attempts int) *sqlmock.Rows {
+		now := time.Now()
+		return sqlmock.NewRows(testDeliveryColumns).
+			AddRow(1, 3, "event-1", "booking.created", `{"type":"booking.created"}`, "PENDING", attempts, nil, "", now, nil, now, now, srv.URL, "whsec_test")
+	}
+
+	t.Run("success", func(t *testing.T) {
+		svc, mock, cleanup := newWebhookService(t, 3)
+		defer cleanup()
+		status = http.StatusOK
+
+		expectClaim(mock, dueRow(0))
+		mock.ExpectPrepare("UPDATE webhook_delivery").ExpectExec().
+			WithArgs("SUCCEEDED", 1, int64(200), "", "SUCCEEDED", 0, "SUCCEEDED", 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		n, err := svc.DeliverDue(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n)
+		assert.NoError(t, webhook.Verify("whsec_test", signature, []byte(`{"type":"booking.created"}`), time.Minute, time.Now()))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("failure is retried with backoff", func(t *testing.T) {
+		svc, mock, cleanup := newWebhookService(t, 3)
+		defer cleanup()
+		status = http.StatusServiceUnavailable
+
+		expectClaim(mock, dueRow(1))
+		mock.ExpectPrepare("UPDATE webhook_delivery").ExpectExec().
+			WithArgs("PENDING", 2, int64(503), "HTTP 503", "PENDING", 120, "PENDING", 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		n, err := svc.DeliverDue(context.Background())
+		assert.NoError(t, err)
+		assert.Zero(t, n)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		svc, mock, cleanup := newWebhookService(t, 3)
+		defer cleanup()
+		status = http.StatusInternalServerError
+
+		expectClaim(mock, dueRow(2))
+		mock.ExpectPrepare("UPDATE webhook_delivery").ExpectExec().
+			WithArgs("FAILED", 3, int64(500), "HTTP 500", "FAILED", 0, "FAILED", 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		_, err := svc.DeliverDue(context.Background())
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("a record error does not stop the batch", func(t *testing.T) {
+		svc, mock, cleanup := newWebhookService(t, 3)
+		defer cleanup()
+		status = http.StatusOK
+
+		now := time.Now()
+		mock.ExpectBegin()
+		mock.ExpectQuery("WHERE d.status = 'PENDING'(.|\\s)+SKIP LOCKED").WithArgs(webhookBatch).
+			WillReturnRows(dueRow(0).
+				AddRow(2, 3, "event-2", "booking.created", `{"type":"booking.created"}`, "PENDING", 0, nil, "", now, nil, now, now, srv.URL, "whsec_test"))
+		mock.ExpectExec("UPDATE webhook_delivery SET next_attempt_at").WithArgs(110, 1, 2).WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+		mock.ExpectPrepare("UPDATE webhook_delivery").ExpectExec().
+			WithArgs("SUCCEEDED", 1, int64(200), "", "SUCCEEDED", 0, "SUCCEEDED", 1).
+			WillReturnError(sql.ErrConnDone)
+		mock.ExpectPrepare("UPDATE webhook_delivery").ExpectExec().
+			WithArgs("SUCCEEDED", 1, int64(200), "", "SUCCEEDED", 0, "SUCCEEDED", 2).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		n, err := svc.DeliverDue(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestTruncate(t *testing.T) {
+	assert.Equal(t, "short", truncate("short", 10))
+	assert.Equal(t, "abc", truncate("abcdef", 3))
+	// "é" is two bytes; cutting inside it drops the whole character.
+	assert.Equal(t, "caf", truncate("café", 4))
+	assert.Equal(t, "café", truncate("café!", 5))
+}
+
+func TestWebhookService_Redeliver(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer srv.Close()
+
+	svc, mock, cleanup := newWebhookService(t, 3)
+	defer cleanup()
+
+	now := time.Now()
+	mock.ExpectPrepare("WHERE d.delivery_id = \\?").ExpectQuery().WithArgs(1, 3, 7).
+		WillReturnRows(sqlmock.NewRows(testDeliveryColumns).
+			AddRow(1, 3, "event-1", "booking.created", `{}`, "FAILED", 8, 500, "HTTP 500", nil, nil, now, now, srv.URL, "whsec_test"))
+	mock.ExpectPrepare("UPDATE webhook_delivery").ExpectExec().
+		WithArgs("FAILED", 9, int64(410), "HTTP 410", "FAILED", 0, "FAILED", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	d, err := svc.Redeliver(context.Background(), 7, 3, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, entities.WebhookDeliveryFailed, d.Status)
+	assert.Equal(t, http.StatusGone, *d.ResponseCode)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}